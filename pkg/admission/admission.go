@@ -8,13 +8,15 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"slices"
 	"time"
 
+	"github.com/rancher/webhook/pkg/identity"
+	"github.com/rancher/webhook/pkg/metrics"
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	v1 "k8s.io/api/admissionregistration/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -22,6 +24,8 @@ const (
 	webhookQualifier     = "rancher.cattle.io"
 	bypassServiceAccount = "system:serviceaccount:cattle-system:rancher-webhook-sudo"
 	systemMasters        = "system:masters"
+	validatingWebhook    = "validating"
+	mutatingWebhook      = "mutating"
 )
 
 var (
@@ -85,10 +89,41 @@ type MutatingAdmissionHandler interface {
 	MutatingWebhook(clientConfig v1.WebhookClientConfig) []v1.MutatingWebhook
 }
 
+// Resetter is optionally implemented by handlers holding shared resources, like caches or pools, that a stuck request
+// may leave in a bad state. The watchdog of the server calls Reset when canceled requests to the handler keep running.
+type Resetter interface {
+	// Reset releases and recreates the shared resources of the handler. It must be safe to call concurrently with Admit.
+	Reset()
+}
+
+// Wrapper is implemented by handlers decorating another handler, like those adding admitters to validators. Optional
+// interfaces of the decorated handler, like ReviewPrefetcher, are found through Unwrap without the decorator
+// forwarding them.
+type Wrapper interface {
+	// Unwrap returns the decorated handler.
+	Unwrap() WebhookHandler
+}
+
 // Request is a simple wrapper for an AdmissionRequest that includes the context from the original http.Request.
 type Request struct {
 	admissionv1.AdmissionRequest
 	Context context.Context
+
+	// lookups are the objects looked up while admitting the request, see Lookup.
+	lookups map[lookupKey]lookupResult
+	// metadata is the metadata of the objects of the request, see MetadataFromRequest.
+	metadata *requestMetadata
+	// owner is the cluster and project owning the object of the request, see OwnerFromRequest.
+	owner *Owner
+	// warnings are added to the response of the request, see relaxOldObject.
+	warnings []string
+}
+
+// IsDryRun returns true if the request is a dry run. Admitters must not have side effects, such as creating or
+// updating other objects, for dry runs. The context of dry run requests is marked too,
+// see IsDryRunContext.
+func (r *Request) IsDryRun() bool {
+	return r.DryRun != nil && *r.DryRun
 }
 
 // NewDefaultValidatingWebhook creates a new ValidatingWebhook based on the WebhookHandler provided.
@@ -184,21 +219,47 @@ func SubPath(gvr schema.GroupVersionResource) string {
 // If it encounters a failure or an error, it short-circuts and returns immediately.
 func NewValidatingHandlerFunc(handler ValidatingAdmissionHandler) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, req *http.Request) {
+		start := time.Now()
 		review, webReq, err := getReviewAndRequestForHandler(req, handler)
 		if err != nil {
 			sendError(responseWriter, review, err)
 			return
 		}
 
+		warnRemovedFields(webReq)
 		if bypassValidation(review.Request) {
+			audit.record(validatingWebhook, webReq, start, auditBypassed, nil, nil)
 			sendResponse(responseWriter, review, ResponseAllowed())
 			logrus.Debugf("admit bypassed: %s %s %s", webReq.Operation, webReq.Kind.String(), resourceString(webReq.Namespace, webReq.Name))
 			return
 		}
+		// shed requests aren't logged as denials, which would add to the load of an overloaded webhook
+		if response := loadShedding.admit(req.Context(), validatingWebhook, handler, webReq); response != nil {
+			recordDecision(validatingWebhook, webReq, start, response, nil)
+			sendResponse(responseWriter, review, response)
+			return
+		}
+		if response := earlyDenial(webReq); response != nil {
+			populateStatusDetails(webReq, response)
+			logDenial(webReq, response)
+			recordDecision(validatingWebhook, webReq, start, response, nil)
+			sendResponse(responseWriter, review, response)
+			return
+		}
+		normalizeValidatedRequest(webReq)
 
 		// save the response from the loop so we can return on success
 		var response *admissionv1.AdmissionResponse
-		for _, admitter := range handler.Admitters() {
+		// the first denial audit mode allowed, whose would-deny is recorded with the final response
+		var audited *admissionv1.AdmissionResponse
+		// the warnings of all the admitters are returned, not only the ones of the last response
+		budget := &WarningBudget{}
+		admitters := handler.Admitters()
+		// the external policies only see the requests the built-in admitters allow
+		if external := externalPolicies.admitterFor(webReq); external != nil {
+			admitters = append(slices.Clip(admitters), external)
+		}
+		for _, admitter := range admitters {
 			if admitter == nil {
 				continue
 			}
@@ -208,18 +269,42 @@ func NewValidatingHandlerFunc(handler ValidatingAdmissionHandler) http.HandlerFu
 			}
 			logrus.Debugf("admit result: %s %s %s user=%s allowed=%v err=%v", webReq.Operation, webReq.Kind.String(), resourceString(webReq.Namespace, webReq.Name), webReq.UserInfo.Username, response.Allowed, err)
 
+			applyWarnings(webReq, budget, response)
 			// if we get an error or are not allowed, short circuit the admits
 			if err != nil {
+				recordDecision(validatingWebhook, webReq, start, response, err)
 				review.Response = response
 				sendError(responseWriter, review, err)
 				return
 			}
 			if !response.Allowed {
+				if excepted := policyExceptions.except(webReq, response); excepted != nil {
+					response = excepted
+					applyWarnings(webReq, budget, response)
+					continue
+				}
+				if allowed := auditMode.allow(webReq, response); allowed != nil {
+					response = allowed
+					if audited == nil {
+						audited = allowed
+					}
+					applyWarnings(webReq, budget, response)
+					continue
+				}
+				if warning := policyExceptions.correlationWarning(webReq, response); warning != "" {
+					budget.Add(warning)
+					applyWarnings(webReq, budget, response)
+				}
+				populateStatusDetails(webReq, response)
+				logDenial(webReq, response)
+				recordDecision(validatingWebhook, webReq, start, response, nil)
 				sendResponse(responseWriter, review, response)
 				return
 			}
 		}
 		// if we have reached this point, all admits approved
+		keepWouldDeny(response, audited)
+		recordDecision(validatingWebhook, webReq, start, response, nil)
 		sendResponse(responseWriter, review, response)
 	}
 }
@@ -227,6 +312,7 @@ func NewValidatingHandlerFunc(handler ValidatingAdmissionHandler) http.HandlerFu
 // NewMutatingHandlerFunc returns a new HandlerFunc that will call the function returned by the MutatingAdmissionHandler's AdmitFunc() call.
 func NewMutatingHandlerFunc(handler MutatingAdmissionHandler) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, req *http.Request) {
+		start := time.Now()
 		review, webReq, err := getReviewAndRequestForHandler(req, handler)
 		if err != nil {
 			// review could not be valid, so initialize some safe defaults
@@ -235,22 +321,54 @@ func NewMutatingHandlerFunc(handler MutatingAdmissionHandler) http.HandlerFunc {
 		}
 
 		if bypassValidation(review.Request) {
+			audit.record(mutatingWebhook, webReq, start, auditBypassed, nil, nil)
 			sendResponse(responseWriter, review, ResponseAllowed())
 			logrus.Debugf("admit bypassed: %s %s %s", webReq.Operation, webReq.Kind.String(), resourceString(webReq.Namespace, webReq.Name))
 			return
 		}
+		// shed requests aren't logged as denials, which would add to the load of an overloaded webhook
+		if response := loadShedding.admit(req.Context(), mutatingWebhook, handler, webReq); response != nil {
+			recordDecision(mutatingWebhook, webReq, start, response, nil)
+			sendResponse(responseWriter, review, response)
+			return
+		}
+		if response := earlyDenial(webReq); response != nil {
+			populateStatusDetails(webReq, response)
+			logDenial(webReq, response)
+			recordDecision(mutatingWebhook, webReq, start, response, nil)
+			sendResponse(responseWriter, review, response)
+			return
+		}
+		normalization := normalizeMutatedRequest(webReq)
 
 		response, err := handler.Admit(webReq)
 		if response == nil {
 			response = &admissionv1.AdmissionResponse{}
 		}
 		logrus.Debugf("admit result: %s %s %s user=%s allowed=%v err=%v", webReq.Operation, webReq.Kind.String(), resourceString(webReq.Namespace, webReq.Name), webReq.UserInfo.Username, response.Allowed, err)
+		if err == nil && !response.Allowed {
+			if allowed := auditMode.allow(webReq, response); allowed != nil {
+				response = allowed
+			}
+		}
 
+		if err == nil {
+			err = addNormalizationPatch(response, normalization)
+		}
+		if err == nil && response.Allowed {
+			err = addPreconditions(webReq, response)
+		}
+		applyWarnings(webReq, &WarningBudget{}, response)
+		recordDecision(mutatingWebhook, webReq, start, response, err)
 		if err != nil {
 			review.Response = response
 			sendError(responseWriter, review, err)
 			return
 		}
+		if !response.Allowed {
+			populateStatusDetails(webReq, response)
+			logDenial(webReq, response)
+		}
 		sendResponse(responseWriter, review, response)
 	}
 }
@@ -271,11 +389,15 @@ func getReviewAndRequestForHandler(req *http.Request, handler WebhookHandler) (*
 		AdmissionRequest: *review.Request,
 		Context:          req.Context(),
 	}
+	if webReq.IsDryRun() {
+		webReq.Context = WithDryRun(webReq.Context)
+	}
 
 	// validate that this handler can handle the provided operation
 	if !canHandleOperation(handler, review.Request.Operation) {
 		return &review, nil, fmt.Errorf("can not handle '%s' for '%s': %w", review.Request.Operation, SubPath(handler.GVR()), ErrUnsupportedOperation)
 	}
+	relaxOldObject(webReq)
 	return &review, webReq, nil
 }
 
@@ -336,6 +458,34 @@ func resourceString(ns, name string) string {
 	return fmt.Sprintf("%s/%s", ns, name)
 }
 
+// logDenial logs the denied request, and its object if the resource is selected for denied object logging.
+func logDenial(request *Request, response *admissionv1.AdmissionResponse) {
+	message := ""
+	if response.Result != nil {
+		message = response.Result.Message
+	}
+	denials.record(request, message)
+	deniedObjects.log(request, message)
+}
+
+// recordDecision records the decision of the webhook on the request, started at the given time, in the exported
+// decisions, the audit log and the admission metrics.
+func recordDecision(webhook string, request *Request, start time.Time, response *admissionv1.AdmissionResponse, err error) {
+	decisions.record(webhook, request, response, err)
+	decision := decisionOf(response, err)
+	audit.record(webhook, request, start, decision, response, err)
+	observed := metrics.Admission{
+		Webhook:   webhook,
+		Resource:  schema.GroupVersionResource(request.Resource),
+		Operation: string(request.Operation),
+		Decision:  decision,
+	}
+	if decision == metrics.DecisionDenied && response.Result != nil {
+		observed.Reason = string(response.Result.Reason)
+	}
+	metrics.ObserveAdmission(observed, time.Since(start))
+}
+
 // ResponseAllowed returns a minimal AdmissionResponse in which Allowed is true
 func ResponseAllowed() *admissionv1.AdmissionResponse {
 	return &admissionv1.AdmissionResponse{
@@ -346,28 +496,12 @@ func ResponseAllowed() *admissionv1.AdmissionResponse {
 // ResponseBadRequest returns an AdmissionResponse for BadRequest(err code 400)
 // the message is used as the message in the response
 func ResponseBadRequest(message string) *admissionv1.AdmissionResponse {
-	return &admissionv1.AdmissionResponse{
-		Result: &metav1.Status{
-			Status:  "Failure",
-			Message: message,
-			Reason:  metav1.StatusReasonBadRequest,
-			Code:    http.StatusBadRequest,
-		},
-		Allowed: false,
-	}
+	return ResponseDenied(DenialBadRequest, message)
 }
 
 // ResponseFailedEscalation returns an AdmissionResponse a failed escalation check.
 func ResponseFailedEscalation(message string) *admissionv1.AdmissionResponse {
-	return &admissionv1.AdmissionResponse{
-		Result: &metav1.Status{
-			Status:  "Failure",
-			Message: message,
-			Reason:  metav1.StatusReasonForbidden,
-			Code:    http.StatusForbidden,
-		},
-		Allowed: false,
-	}
+	return ResponseDenied(DenialForbidden, message)
 }
 
 // CreateWebhookName returns a new name for the given webhook handler with the given suffix.
@@ -381,13 +515,6 @@ func CreateWebhookName(handler WebhookHandler, suffix string) string {
 
 // bypassValidation users can bypass the webhook if they are the sudo account and system:masters group
 func bypassValidation(request *admissionv1.AdmissionRequest) bool {
-	if request.UserInfo.Username != bypassServiceAccount {
-		return false
-	}
-	for _, group := range request.UserInfo.Groups {
-		if group == systemMasters {
-			return true
-		}
-	}
-	return false
+	id := identity.FromUserInfo(request.UserInfo)
+	return id.Name == bypassServiceAccount && id.InGroup(systemMasters)
 }
@@ -0,0 +1,365 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AuditLogEnvKey is the environment variable listing the sinks of the audit log, separated by commas: stdout,
+	// file:<path> or the http or https URL of a webhook. Admission requests aren't audited if it's empty.
+	AuditLogEnvKey = "CATTLE_WEBHOOK_AUDIT_LOG"
+	// AuditLogMaxSizeEnvKey is the environment variable holding the size in megabytes after which audit log files are
+	// rotated.
+	AuditLogMaxSizeEnvKey = "CATTLE_WEBHOOK_AUDIT_LOG_MAX_SIZE"
+	// AuditLogMaxBackupsEnvKey is the environment variable holding the number of rotated audit log files kept.
+	AuditLogMaxBackupsEnvKey = "CATTLE_WEBHOOK_AUDIT_LOG_MAX_BACKUPS"
+
+	// DefaultAuditLogMaxSize and DefaultAuditLogMaxBackups are used when their environment variable isn't set.
+	DefaultAuditLogMaxSize    = 100
+	DefaultAuditLogMaxBackups = 5
+
+	// auditBypassed is the decision of the requests bypassing the webhook.
+	auditBypassed = "bypassed"
+	// auditQueueSize is the number of records waiting to be written after which new records are dropped, so that a
+	// slow sink never slows admission down.
+	auditQueueSize      = 10000
+	auditBatchSize      = 500
+	auditFlushInterval  = time.Second
+	auditWebhookTimeout = 10 * time.Second
+)
+
+var auditRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rancher_webhook",
+	Name:      "audit_records_total",
+	Help:      "Number of audit records handled by each sink, by result: written, dropped because the queue was full, or failed to be written.",
+}, []string{"sink", "result"})
+
+func init() {
+	prometheus.MustRegister(auditRecords)
+}
+
+// AuditLog is the configuration of the audit log of admission requests.
+type AuditLog struct {
+	// Sinks are the destinations of the records: stdout, file:<path> or a webhook URL.
+	Sinks []string
+	// MaxSize is the size in megabytes after which files are rotated.
+	MaxSize int
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups int
+}
+
+// AuditLogFromEnv returns the audit log configured by the audit log environment variables.
+func AuditLogFromEnv() (AuditLog, error) {
+	return ParseAuditLog(os.Getenv(AuditLogEnvKey), os.Getenv(AuditLogMaxSizeEnvKey), os.Getenv(AuditLogMaxBackupsEnvKey))
+}
+
+// ParseAuditLog parses the sinks, max size and max backups of the audit log. Empty values get the defaults.
+func ParseAuditLog(sinks, maxSize, maxBackups string) (AuditLog, error) {
+	config := AuditLog{MaxSize: DefaultAuditLogMaxSize, MaxBackups: DefaultAuditLogMaxBackups}
+	var err error
+	for _, sink := range strings.Split(sinks, ",") {
+		sink = strings.TrimSpace(sink)
+		if sink == "" {
+			continue
+		}
+		if err := checkAuditSink(sink); err != nil {
+			return config, err
+		}
+		config.Sinks = append(config.Sinks, sink)
+	}
+	if maxSize != "" {
+		if config.MaxSize, err = strconv.Atoi(maxSize); err != nil || config.MaxSize < 1 {
+			return config, fmt.Errorf("max size %q must be a positive number of megabytes", maxSize)
+		}
+	}
+	if maxBackups != "" {
+		if config.MaxBackups, err = strconv.Atoi(maxBackups); err != nil || config.MaxBackups < 0 {
+			return config, fmt.Errorf("max backups %q must be a non-negative number", maxBackups)
+		}
+	}
+	return config, nil
+}
+
+// checkAuditSink checks that the sink is stdout, a file with a path, or an http or https URL.
+func checkAuditSink(sink string) error {
+	switch {
+	case sink == "stdout":
+		return nil
+	case strings.HasPrefix(sink, "file:"):
+		if strings.TrimPrefix(sink, "file:") == "" {
+			return fmt.Errorf("sink %q has no file path", sink)
+		}
+		return nil
+	}
+	endpoint, err := url.Parse(sink)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("sink %q must be stdout, file:<path> or an http or https URL", sink)
+	}
+	return nil
+}
+
+// AuditRecord is the record of an admission request written to the audit log.
+type AuditRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	UID       string    `json:"uid"`
+	User      string    `json:"user"`
+	Groups    []string  `json:"groups,omitempty"`
+	// Webhook is the type of the webhook which handled the request, validating or mutating.
+	Webhook   string                      `json:"webhook"`
+	Resource  metav1.GroupVersionResource `json:"resource"`
+	Operation string                      `json:"operation"`
+	Namespace string                      `json:"namespace,omitempty"`
+	Name      string                      `json:"name,omitempty"`
+	DryRun    bool                        `json:"dryRun,omitempty"`
+	// Decision is allowed, denied, would-deny if audit mode allowed a denial, error if the request couldn't be
+	// evaluated, or bypassed.
+	Decision string `json:"decision"`
+	Patched  bool   `json:"patched,omitempty"`
+	Code     int32  `json:"code,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	// Rule is the ID of the rule which denied the request, if the denial links to it.
+	Rule     string   `json:"rule,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Error is the error the webhook failed with, if any.
+	Error string `json:"error,omitempty"`
+	// DurationSeconds is the time taken to evaluate the request.
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// auditSink is a destination of the audit log.
+type auditSink interface {
+	// name returns the kind of the sink, used to label its metrics.
+	name() string
+	// write writes the newline delimited JSON records.
+	write(ctx context.Context, records []byte) error
+}
+
+// writerSink writes the records to stdout or a rotated file.
+type writerSink struct {
+	kind   string
+	writer io.Writer
+}
+
+func (w *writerSink) name() string { return w.kind }
+
+func (w *writerSink) write(_ context.Context, records []byte) error {
+	_, err := w.writer.Write(records)
+	return err
+}
+
+// webhookSink posts the records to a webhook.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhookSink) name() string { return "webhook" }
+
+func (w *webhookSink) write(ctx context.Context, records []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(records))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// newAuditSinks returns the sinks of the audit log.
+func newAuditSinks(config AuditLog) []auditSink {
+	sinks := make([]auditSink, 0, len(config.Sinks))
+	for _, sink := range config.Sinks {
+		switch {
+		case sink == "stdout":
+			sinks = append(sinks, &writerSink{kind: "stdout", writer: os.Stdout})
+		case strings.HasPrefix(sink, "file:"):
+			sinks = append(sinks, &writerSink{kind: "file", writer: &lumberjack.Logger{
+				Filename:   strings.TrimPrefix(sink, "file:"),
+				MaxSize:    config.MaxSize,
+				MaxBackups: config.MaxBackups,
+			}})
+		default:
+			sinks = append(sinks, &webhookSink{url: sink, client: &http.Client{Timeout: auditWebhookTimeout}})
+		}
+	}
+	return sinks
+}
+
+// auditLogger queues the records of admission requests and writes them to the sinks.
+type auditLogger struct {
+	mutex sync.Mutex
+	sinks []auditSink
+	queue chan AuditRecord
+	now   func() time.Time
+}
+
+var audit = newAuditLogger()
+
+func newAuditLogger() *auditLogger {
+	return &auditLogger{
+		queue: make(chan AuditRecord, auditQueueSize),
+		now:   time.Now,
+	}
+}
+
+// StartAuditLog writes the records of admission requests to the sinks of the audit log until the context is done.
+// Nothing is recorded if the audit log has no sinks.
+func StartAuditLog(ctx context.Context, config AuditLog) {
+	if len(config.Sinks) == 0 {
+		return
+	}
+	audit.configure(newAuditSinks(config))
+	logrus.Infof("[audit-log] writing the records of admission requests to %s", strings.Join(config.Sinks, ", "))
+	go audit.run(ctx)
+}
+
+// configure replaces the sinks, no sinks disables the audit log.
+func (a *auditLogger) configure(sinks []auditSink) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.sinks = sinks
+}
+
+// current returns the sinks of the audit log.
+func (a *auditLogger) current() []auditSink {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.sinks
+}
+
+// record queues the record of the decision of the webhook on the request, started at the given time. The record is
+// dropped if the queue is full.
+func (a *auditLogger) record(webhook string, request *Request, start time.Time, decision string, response *admissionv1.AdmissionResponse, err error) {
+	sinks := a.current()
+	if len(sinks) == 0 {
+		return
+	}
+	now := a.now()
+	record := AuditRecord{
+		Timestamp:       now.UTC(),
+		UID:             string(request.UID),
+		User:            request.UserInfo.Username,
+		Groups:          request.UserInfo.Groups,
+		Webhook:         webhook,
+		Resource:        request.Resource,
+		Operation:       string(request.Operation),
+		Namespace:       request.Namespace,
+		Name:            request.Name,
+		DryRun:          request.IsDryRun(),
+		Decision:        decision,
+		DurationSeconds: now.Sub(start).Seconds(),
+	}
+	if response != nil {
+		record.Patched = len(response.Patch) != 0
+		record.Warnings = response.Warnings
+		if response.Result != nil {
+			record.Code = response.Result.Code
+			record.Reason = string(response.Result.Reason)
+			record.Message = response.Result.Message
+			if match := ruleIDPattern.FindStringSubmatch(record.Message); match != nil {
+				record.Rule = match[1]
+			}
+		}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	select {
+	case a.queue <- record:
+	default:
+		for _, sink := range sinks {
+			auditRecords.WithLabelValues(sink.name(), "dropped").Inc()
+		}
+	}
+}
+
+// run writes the queued records in batches until the context is done.
+func (a *auditLogger) run(ctx context.Context) {
+	for {
+		batch := a.collect(ctx)
+		if len(batch) != 0 {
+			a.write(ctx, batch)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// collect returns the records queued until the batch is full or the flush interval elapsed.
+func (a *auditLogger) collect(ctx context.Context) []AuditRecord {
+	timer := time.NewTimer(auditFlushInterval)
+	defer timer.Stop()
+	var batch []AuditRecord
+	for len(batch) < auditBatchSize {
+		select {
+		case <-ctx.Done():
+			return batch
+		case <-timer.C:
+			return batch
+		case record := <-a.queue:
+			batch = append(batch, record)
+		}
+	}
+	return batch
+}
+
+// write writes the batch to each sink. A sink failing doesn't keep the others from getting the records.
+func (a *auditLogger) write(ctx context.Context, batch []AuditRecord) {
+	var records bytes.Buffer
+	encoder := json.NewEncoder(&records)
+	for _, record := range batch {
+		if err := encoder.Encode(record); err != nil {
+			logrus.Errorf("[audit-log] failed to encode the record of request %s: %v", record.UID, err)
+		}
+	}
+	for _, sink := range a.current() {
+		if err := sink.write(ctx, records.Bytes()); err != nil {
+			logrus.Warnf("[audit-log] failed to write %d records to %s: %v", len(batch), sink.name(), err)
+			auditRecords.WithLabelValues(sink.name(), "failed").Add(float64(len(batch)))
+			continue
+		}
+		auditRecords.WithLabelValues(sink.name(), "written").Add(float64(len(batch)))
+	}
+}
+
+// decisionOf returns the decision of the response: allowed, denied, would-deny if audit mode allowed a denial, or error
+// if the request couldn't be evaluated.
+func decisionOf(response *admissionv1.AdmissionResponse, err error) string {
+	switch {
+	case err != nil:
+		return metrics.DecisionError
+	case response != nil && !response.Allowed:
+		return metrics.DecisionDenied
+	case wouldDeny(response):
+		return metrics.DecisionWouldDeny
+	}
+	return metrics.DecisionAllowed
+}
@@ -0,0 +1,187 @@
+package admission
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseAuditLog(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                       string
+		sinks, maxSize, maxBackups string
+		want                       AuditLog
+		wantErr                    bool
+	}{
+		{
+			name: "defaults",
+			want: AuditLog{MaxSize: DefaultAuditLogMaxSize, MaxBackups: DefaultAuditLogMaxBackups},
+		},
+		{
+			name:       "all sinks",
+			sinks:      "stdout, file:/var/log/webhook/audit.log,https://audit.example.com/records",
+			maxSize:    "50",
+			maxBackups: "0",
+			want: AuditLog{
+				Sinks:   []string{"stdout", "file:/var/log/webhook/audit.log", "https://audit.example.com/records"},
+				MaxSize: 50,
+			},
+		},
+		{
+			name:    "unknown sink",
+			sinks:   "stderr",
+			wantErr: true,
+		},
+		{
+			name:    "file without path",
+			sinks:   "file:",
+			wantErr: true,
+		},
+		{
+			name:    "webhook without host",
+			sinks:   "https://",
+			wantErr: true,
+		},
+		{
+			name:    "invalid max size",
+			maxSize: "0",
+			wantErr: true,
+		},
+		{
+			name:       "invalid max backups",
+			maxBackups: "-1",
+			wantErr:    true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseAuditLog(test.sinks, test.maxSize, test.maxBackups)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+// auditReceiver is a webhook receiving audit records, failing with the queued statuses first.
+type auditReceiver struct {
+	mutex    sync.Mutex
+	statuses []int
+	records  []AuditRecord
+}
+
+func (a *auditReceiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if len(a.statuses) != 0 {
+		status := a.statuses[0]
+		a.statuses = a.statuses[1:]
+		rw.WriteHeader(status)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	a.records = append(a.records, decodeAuditRecords(body)...)
+}
+
+func decodeAuditRecords(data []byte) []AuditRecord {
+	var records []AuditRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestAuditLogger(t *testing.T) {
+	t.Parallel()
+	receiver := &auditReceiver{statuses: []int{http.StatusServiceUnavailable}}
+	endpoint := httptest.NewServer(receiver)
+	defer endpoint.Close()
+	file := filepath.Join(t.TempDir(), "audit.log")
+
+	logger := newAuditLogger()
+	now := time.Date(2025, 3, 1, 0, 0, 1, 0, time.UTC)
+	logger.now = func() time.Time { return now }
+	logger.record(validatingWebhook, exportRequest("p-ignored"), now, decisionOf(ResponseAllowed(), nil), ResponseAllowed(), nil)
+	assert.Empty(t, logger.queue, "records aren't queued before the audit log is configured")
+
+	logger.configure(newAuditSinks(AuditLog{Sinks: []string{"file:" + file, endpoint.URL}, MaxSize: 1, MaxBackups: 1}))
+	denied := ResponseBadRequest("quota exceeded (see /rules/project-used-quota)")
+	denied.Warnings = []string{"namespace default quota set"}
+	logger.record(validatingWebhook, exportRequest("p-denied"), now.Add(-250*time.Millisecond), decisionOf(denied, nil), denied, nil)
+	logger.record(mutatingWebhook, exportRequest("p-failed"), now, decisionOf(nil, assert.AnError), nil, assert.AnError)
+	logger.record(mutatingWebhook, exportRequest("p-bypassed"), now, auditBypassed, nil, nil)
+
+	batch := logger.collect(context.Background())
+	require.Len(t, batch, 3)
+	logger.write(context.Background(), batch)
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	records := decodeAuditRecords(data)
+	require.Len(t, records, 3)
+	assert.Equal(t, AuditRecord{
+		Timestamp:       now,
+		UID:             "uid-p-denied",
+		User:            "u-a",
+		Webhook:         validatingWebhook,
+		Resource:        metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"},
+		Operation:       "CREATE",
+		Namespace:       "local",
+		Name:            "p-denied",
+		Decision:        "denied",
+		Code:            http.StatusBadRequest,
+		Reason:          string(metav1.StatusReasonBadRequest),
+		Message:         "quota exceeded (see /rules/project-used-quota)",
+		Rule:            "project-used-quota",
+		Warnings:        []string{"namespace default quota set"},
+		DurationSeconds: 0.25,
+	}, records[0])
+	assert.Equal(t, "error", records[1].Decision)
+	assert.Equal(t, assert.AnError.Error(), records[1].Error)
+	assert.Equal(t, auditBypassed, records[2].Decision)
+
+	// a failing sink doesn't keep the others from getting the records
+	receiver.mutex.Lock()
+	assert.Empty(t, receiver.records)
+	receiver.mutex.Unlock()
+	logger.write(context.Background(), batch)
+	receiver.mutex.Lock()
+	assert.Len(t, receiver.records, 3)
+	receiver.mutex.Unlock()
+	data, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Len(t, decodeAuditRecords(data), 6)
+}
+
+func TestAuditLoggerQueueFull(t *testing.T) {
+	t.Parallel()
+	logger := &auditLogger{queue: make(chan AuditRecord, 1), now: time.Now}
+	logger.configure([]auditSink{&writerSink{kind: "stdout", writer: io.Discard}})
+	logger.record(validatingWebhook, exportRequest("p-1"), time.Now(), "allowed", ResponseAllowed(), nil)
+	logger.record(validatingWebhook, exportRequest("p-2"), time.Now(), "allowed", ResponseAllowed(), nil)
+	require.Len(t, logger.queue, 1)
+	assert.Equal(t, "p-1", (<-logger.queue).Name)
+}
@@ -0,0 +1,121 @@
+package admission
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// AuditModeEnvKey is the environment variable listing the resources in audit mode, in the resource.group form or
+	// the resource name alone for core resources, separated by commas. "*" puts all the resources in audit mode. The
+	// denials of the resources in audit mode are turned into warnings, and the requests allowed.
+	AuditModeEnvKey = "CATTLE_WEBHOOK_AUDIT_MODE"
+
+	// AllResources puts all the resources in audit mode.
+	AllResources = "*"
+	// wouldDenyAnnotation is the audit annotation holding the message of the denial audit mode turned into a warning.
+	wouldDenyAnnotation = "would-deny"
+)
+
+// AuditModeFromEnv returns the resources in audit mode configured by AuditModeEnvKey.
+func AuditModeFromEnv() ([]string, error) {
+	resources, err := ParseAuditMode(os.Getenv(AuditModeEnvKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", AuditModeEnvKey, err)
+	}
+	return resources, nil
+}
+
+// ParseAuditMode parses the comma separated resources in audit mode. An empty value puts no resource in audit mode.
+func ParseAuditMode(value string) ([]string, error) {
+	var resources []string
+	for _, resource := range strings.Split(value, ",") {
+		resource = strings.TrimSpace(resource)
+		if resource == "" {
+			continue
+		}
+		if resource != AllResources && (strings.ContainsAny(resource, "*/ ") || strings.HasPrefix(resource, ".")) {
+			return nil, fmt.Errorf("resource %q must be %s or in the resource.group form", resource, AllResources)
+		}
+		if !slices.Contains(resources, resource) {
+			resources = append(resources, resource)
+		}
+	}
+	return resources, nil
+}
+
+// auditModeResources holds the resources in audit mode.
+type auditModeResources struct {
+	mutex     sync.RWMutex
+	resources []string
+}
+
+var auditMode = &auditModeResources{}
+
+// ConfigureAuditMode puts the resources in audit mode, replacing the previous ones. Without resources, denials are
+// enforced.
+func ConfigureAuditMode(resources []string) {
+	auditMode.mutex.Lock()
+	defer auditMode.mutex.Unlock()
+	auditMode.resources = resources
+}
+
+// audits returns whether the resource of the request is in audit mode.
+func (a *auditModeResources) audits(request *Request) bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	resource := schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String()
+	return slices.Contains(a.resources, AllResources) || slices.Contains(a.resources, resource)
+}
+
+// allow returns a copy of the denial allowing the request with a warning, if its resource is in audit mode. The denial
+// is logged, and its message kept in the would-deny audit annotation, which makes its decision would-deny. It returns nil if
+// the resource isn't in audit mode.
+func (a *auditModeResources) allow(request *Request, response *admissionv1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	if !a.audits(request) {
+		return nil
+	}
+	message := ""
+	if response.Result != nil {
+		message = response.Result.Message
+	}
+	logrus.Infof("[audit-mode] would deny %s %s %s user=%s: %s", request.Operation, request.Kind.String(),
+		resourceString(request.Namespace, request.Name), request.UserInfo.Username, message)
+
+	allowed := response.DeepCopy()
+	allowed.Allowed = true
+	allowed.Warnings = append(allowed.Warnings, "audit mode: this request would have been denied: "+message)
+	if allowed.AuditAnnotations == nil {
+		allowed.AuditAnnotations = map[string]string{}
+	}
+	allowed.AuditAnnotations[wouldDenyAnnotation] = message
+	return allowed
+}
+
+// wouldDeny returns whether audit mode allowed the response which would have been denied.
+func wouldDeny(response *admissionv1.AdmissionResponse) bool {
+	if response == nil || !response.Allowed {
+		return false
+	}
+	_, ok := response.AuditAnnotations[wouldDenyAnnotation]
+	return ok
+}
+
+// keepWouldDeny records the would-deny of the denial audit mode allowed on the final response of the request, if a
+// later admitter replaced it.
+func keepWouldDeny(response, audited *admissionv1.AdmissionResponse) {
+	if audited == nil || response == nil || wouldDeny(response) {
+		return
+	}
+	if response.AuditAnnotations == nil {
+		response.AuditAnnotations = map[string]string{}
+	}
+	response.AuditAnnotations[wouldDenyAnnotation] = audited.AuditAnnotations[wouldDenyAnnotation]
+}
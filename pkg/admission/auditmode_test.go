@@ -0,0 +1,138 @@
+package admission
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseAuditMode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "all resources",
+			value: "*",
+			want:  []string{"*"},
+		},
+		{
+			name:  "resources",
+			value: " clusters.management.cattle.io, namespaces,,clusters.management.cattle.io",
+			want:  []string{"clusters.management.cattle.io", "namespaces"},
+		},
+		{
+			name:    "subresource",
+			value:   "clusters.management.cattle.io/status",
+			wantErr: true,
+		},
+		{
+			name:    "wildcard resource",
+			value:   "*.management.cattle.io",
+			wantErr: true,
+		},
+		{
+			name:    "group alone",
+			value:   ".management.cattle.io",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseAuditMode(test.value)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestAuditModeAllow(t *testing.T) {
+	t.Parallel()
+	request := &Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "clusters"},
+			Name:      "c-m-abc12",
+			UserInfo:  authenticationv1.UserInfo{Username: "u-12345"},
+		},
+	}
+	denial := ResponseDenied(DenialInvalid, "version management annotation is invalid")
+	denial.Warnings = []string{"field.cattle.io/description is deprecated"}
+	tests := []struct {
+		name      string
+		resources []string
+		wantAudit bool
+	}{
+		{
+			name: "no resources",
+		},
+		{
+			name:      "other resource",
+			resources: []string{"projects.management.cattle.io", "clusters"},
+		},
+		{
+			name:      "resource",
+			resources: []string{"clusters.management.cattle.io"},
+			wantAudit: true,
+		},
+		{
+			name:      "all resources",
+			resources: []string{AllResources},
+			wantAudit: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			mode := &auditModeResources{resources: test.resources}
+			allowed := mode.allow(request, denial)
+			if !test.wantAudit {
+				assert.Nil(t, allowed)
+				return
+			}
+			require.NotNil(t, allowed)
+			assert.True(t, allowed.Allowed)
+			assert.Equal(t, []string{
+				"field.cattle.io/description is deprecated",
+				"audit mode: this request would have been denied: version management annotation is invalid",
+			}, allowed.Warnings)
+			assert.Equal(t, map[string]string{"would-deny": "version management annotation is invalid"}, allowed.AuditAnnotations)
+			assert.True(t, wouldDeny(allowed))
+			assert.Equal(t, metrics.DecisionWouldDeny, decisionOf(allowed, nil))
+			assert.False(t, denial.Allowed, "the denial is left unchanged")
+			assert.Len(t, denial.Warnings, 1)
+		})
+	}
+}
+
+func TestKeepWouldDeny(t *testing.T) {
+	t.Parallel()
+	mode := &auditModeResources{resources: []string{AllResources}}
+	audited := mode.allow(&Request{}, ResponseDenied(DenialInvalid, "denied"))
+
+	response := ResponseAllowed()
+	keepWouldDeny(response, nil)
+	assert.Equal(t, metrics.DecisionAllowed, decisionOf(response, nil))
+
+	keepWouldDeny(response, audited)
+	assert.Equal(t, map[string]string{"would-deny": "denied"}, response.AuditAnnotations)
+	assert.Equal(t, metrics.DecisionWouldDeny, decisionOf(response, nil))
+}
@@ -0,0 +1,200 @@
+package admission
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DenialCatalogPath is the path of the endpoint returning the catalog of denial codes and the rules using them.
+	DenialCatalogPath = "/denial-codes"
+	// DenialCatalogSchemaPath is the path of the endpoint returning the JSON schema of the catalog.
+	DenialCatalogSchemaPath = DenialCatalogPath + "/schema"
+)
+
+// DenialCatalog is the machine-readable catalog of the denial codes, and of the rules denying requests with them, so
+// that clients can handle denials by their code and rule instead of by their message.
+type DenialCatalog struct {
+	Codes []DenialCodeEntry `json:"codes"`
+	Rules []DenialRuleEntry `json:"rules"`
+}
+
+// DenialCodeEntry documents a denial code.
+type DenialCodeEntry struct {
+	Code      DenialCode          `json:"code"`
+	Reason    metav1.StatusReason `json:"reason"`
+	HTTPCode  int32               `json:"httpCode"`
+	Retryable bool                `json:"retryable"`
+	// RetryAfterSeconds is the default delay clients are told to wait before retrying, in the retryAfterSeconds of
+	// the details of the status. It's omitted for codes not telling clients to wait.
+	RetryAfterSeconds int32  `json:"retryAfterSeconds,omitempty"`
+	Description       string `json:"description"`
+}
+
+// DenialRuleEntry documents the denials of a rule.
+type DenialRuleEntry struct {
+	ID             string              `json:"id"`
+	Group          string              `json:"group"`
+	Version        string              `json:"version"`
+	Resource       string              `json:"resource"`
+	Severity       rules.Severity      `json:"severity"`
+	Code           DenialCode          `json:"code"`
+	Reason         metav1.StatusReason `json:"reason"`
+	HTTPCode       int32               `json:"httpCode"`
+	URL            string              `json:"url"`
+	ExampleMessage string              `json:"exampleMessage,omitempty"`
+}
+
+// NewDenialCatalog returns the catalog of the denial codes and of the registered rules, sorted by ID. Since rules are
+// registered when their packages are initialized, the catalog describes the rules built into the binary.
+func NewDenialCatalog() DenialCatalog {
+	catalog := DenialCatalog{Codes: make([]DenialCodeEntry, 0, len(denialCodes))}
+	for _, code := range denialCodes {
+		status := denialStatuses[code]
+		entry := DenialCodeEntry{
+			Code:        code,
+			Reason:      status.reason,
+			HTTPCode:    status.code,
+			Retryable:   status.retryable,
+			Description: status.description,
+		}
+		if status.retryAfter > 0 {
+			entry.RetryAfterSeconds = retryAfterSeconds(status.retryAfter)
+		}
+		catalog.Codes = append(catalog.Codes, entry)
+	}
+	allRules := rules.List()
+	catalog.Rules = make([]DenialRuleEntry, 0, len(allRules))
+	for _, rule := range allRules {
+		code := RuleDenialCode(rule)
+		status := code.Status("")
+		entry := DenialRuleEntry{
+			ID:       rule.ID,
+			Group:    rule.GVR.Group,
+			Version:  rule.GVR.Version,
+			Resource: rule.GVR.Resource,
+			Severity: rule.Severity,
+			Code:     code,
+			Reason:   status.Reason,
+			HTTPCode: status.Code,
+			URL:      rule.URL(),
+		}
+		if rule.ExampleDenial != "" {
+			entry.ExampleMessage = rule.Message("%s", rule.ExampleDenial)
+		}
+		catalog.Rules = append(catalog.Rules, entry)
+	}
+	return catalog
+}
+
+// RuleDenialCode returns the denial code of the requests denied by the rule, Invalid unless the rule sets another one.
+func RuleDenialCode(rule rules.Rule) DenialCode {
+	if rule.DenialCode == "" {
+		return DenialInvalid
+	}
+	return DenialCode(rule.DenialCode)
+}
+
+// ResponseForRule returns the response to a request breaking the rule with the given message, and records the
+// violation. The request is denied with the denial code of the rule if the rule denies it, allowed with the message as
+// a warning if the rule only warns, and allowed if the rule is off.
+func ResponseForRule(rule rules.Rule, overrides rules.Overrides, message string) *admissionv1.AdmissionResponse {
+	switch rules.EnforceWith(rule, overrides) {
+	case rules.StageDeny:
+		return ResponseDenied(RuleDenialCode(rule), message)
+	case rules.StageWarn:
+		response := ResponseAllowed()
+		response.Warnings = []string{message}
+		return response
+	default:
+		return ResponseAllowed()
+	}
+}
+
+// DenialCatalogSchema returns the JSON schema of the catalog. The codes and rule IDs are enumerated, so that clients
+// generating types from the schema get constants for them.
+func DenialCatalogSchema() map[string]any {
+	codes := make([]string, 0, len(denialCodes))
+	reasons := make([]string, 0, len(denialCodes))
+	for _, code := range denialCodes {
+		codes = append(codes, string(code))
+		reasons = append(reasons, string(denialStatuses[code].reason))
+	}
+	allRules := rules.List()
+	ids := make([]string, 0, len(allRules))
+	for _, rule := range allRules {
+		ids = append(ids, rule.ID)
+	}
+	str := map[string]any{"type": "string"}
+	httpCode := map[string]any{"type": "integer", "minimum": 400, "maximum": 599}
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         DenialCatalogSchemaPath,
+		"title":       "DenialCatalog",
+		"description": "Denial codes of the Rancher webhook and the rules denying requests with them.",
+		"type":        "object",
+		"required":    []string{"codes", "rules"},
+		"properties": map[string]any{
+			"codes": map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/codeEntry"}},
+			"rules": map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/ruleEntry"}},
+		},
+		"$defs": map[string]any{
+			"code":     map[string]any{"type": "string", "enum": codes},
+			"reason":   map[string]any{"type": "string", "enum": reasons},
+			"ruleId":   map[string]any{"type": "string", "enum": ids},
+			"severity": map[string]any{"type": "string", "enum": []string{string(rules.SeverityDeny), string(rules.SeverityWarn)}},
+			"codeEntry": map[string]any{
+				"type":     "object",
+				"required": []string{"code", "reason", "httpCode", "retryable", "description"},
+				"properties": map[string]any{
+					"code":              map[string]any{"$ref": "#/$defs/code"},
+					"reason":            map[string]any{"$ref": "#/$defs/reason"},
+					"httpCode":          httpCode,
+					"retryable":         map[string]any{"type": "boolean"},
+					"retryAfterSeconds": map[string]any{"type": "integer", "minimum": 1},
+					"description":       str,
+				},
+			},
+			"ruleEntry": map[string]any{
+				"type":     "object",
+				"required": []string{"id", "group", "version", "resource", "severity", "code", "reason", "httpCode", "url"},
+				"properties": map[string]any{
+					"id":             map[string]any{"$ref": "#/$defs/ruleId"},
+					"group":          str,
+					"version":        str,
+					"resource":       str,
+					"severity":       map[string]any{"$ref": "#/$defs/severity"},
+					"code":           map[string]any{"$ref": "#/$defs/code"},
+					"reason":         map[string]any{"$ref": "#/$defs/reason"},
+					"httpCode":       httpCode,
+					"url":            str,
+					"exampleMessage": str,
+				},
+			},
+		},
+	}
+}
+
+// RegisterDenialCatalogHandlers adds the endpoints returning the catalog of denial codes and its JSON schema to the
+// router.
+func RegisterDenialCatalogHandlers(router *mux.Router) {
+	router.HandleFunc(DenialCatalogPath, func(rw http.ResponseWriter, _ *http.Request) {
+		writeCatalogJSON(rw, "application/json", NewDenialCatalog())
+	}).Methods(http.MethodGet)
+	router.HandleFunc(DenialCatalogSchemaPath, func(rw http.ResponseWriter, _ *http.Request) {
+		writeCatalogJSON(rw, "application/schema+json", DenialCatalogSchema())
+	}).Methods(http.MethodGet)
+}
+
+func writeCatalogJSON(rw http.ResponseWriter, contentType string, obj any) {
+	rw.Header().Set("Content-Type", contentType)
+	if err := json.NewEncoder(rw).Encode(obj); err != nil {
+		logrus.Errorf("failed to write denial catalog response: %v", err)
+	}
+}
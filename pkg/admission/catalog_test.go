@@ -0,0 +1,105 @@
+package admission
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var catalogTestRule = rules.Register(rules.Rule{
+	ID:            "denial-catalog-test",
+	GVR:           schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"},
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "spec.displayName: Required value",
+	DenialCode:    string(DenialConflict),
+})
+
+func TestDenialCatalog(t *testing.T) {
+	t.Parallel()
+	catalog := NewDenialCatalog()
+	require.Len(t, catalog.Codes, len(denialStatuses), "every code must be documented")
+	for _, code := range catalog.Codes {
+		status := code.Code.Status("")
+		assert.Equal(t, status.Reason, code.Reason)
+		assert.Equal(t, status.Code, code.HTTPCode)
+		assert.NotEmpty(t, code.Description)
+		if code.RetryAfterSeconds != 0 {
+			require.NotNil(t, status.Details)
+			assert.Equal(t, status.Details.RetryAfterSeconds, code.RetryAfterSeconds)
+			assert.True(t, code.Retryable, "clients are only told to wait before retrying retryable denials")
+		}
+	}
+
+	var entry *DenialRuleEntry
+	for i := range catalog.Rules {
+		if catalog.Rules[i].ID == catalogTestRule.ID {
+			entry = &catalog.Rules[i]
+		}
+	}
+	require.NotNil(t, entry)
+	assert.Equal(t, DenialRuleEntry{
+		ID:             "denial-catalog-test",
+		Group:          "management.cattle.io",
+		Version:        "v3",
+		Resource:       "projects",
+		Severity:       rules.SeverityDeny,
+		Code:           DenialConflict,
+		Reason:         metav1.StatusReasonConflict,
+		HTTPCode:       http.StatusConflict,
+		URL:            "/rules/denial-catalog-test",
+		ExampleMessage: "spec.displayName: Required value (see /rules/denial-catalog-test)",
+	}, *entry)
+	assert.Equal(t, DenialInvalid, RuleDenialCode(rules.Rule{ID: "no-code"}))
+}
+
+func TestDenialCatalogHandlers(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	RegisterDenialCatalogHandlers(router)
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, DenialCatalogPath, nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var catalog DenialCatalog
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &catalog))
+	assert.NotEmpty(t, catalog.Codes)
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, DenialCatalogSchemaPath, nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/schema+json", rw.Header().Get("Content-Type"))
+	var schema struct {
+		Defs map[string]struct {
+			Enum []string `json:"enum"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &schema))
+	assert.Equal(t, []string{"Invalid", "Forbidden", "Conflict", "BadRequest", "TooManyRequests", "Unavailable"},
+		schema.Defs["code"].Enum)
+	assert.Contains(t, schema.Defs["ruleId"].Enum, catalogTestRule.ID)
+}
+
+func TestResponseForRule(t *testing.T) {
+	t.Parallel()
+	rule := rules.Rule{ID: "response-for-rule-test", Severity: rules.SeverityWarn, DenialCode: string(DenialConflict)}
+
+	warned := ResponseForRule(rule, nil, "warned")
+	assert.True(t, warned.Allowed)
+	assert.Equal(t, []string{"warned"}, warned.Warnings)
+
+	denied := ResponseForRule(rule, rules.Overrides{rule.ID: rules.StageDeny}, "denied")
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, DenialConflict.Status("denied"), denied.Result, "the denial code of the rule is used")
+
+	off := ResponseForRule(rule, rules.Overrides{rule.ID: rules.StageOff}, "off")
+	assert.True(t, off.Allowed)
+	assert.Empty(t, off.Warnings)
+}
@@ -0,0 +1,132 @@
+package admission
+
+import (
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DenialCode classifies why a request is denied. Each code maps to one status reason and HTTP code, so that the same
+// kind of failure is reported the same way by every handler, and clients can tell whether retrying can succeed.
+type DenialCode string
+
+const (
+	// DenialInvalid denies an object failing validation: resubmitting it unchanged fails again. Reported as 422 Invalid.
+	DenialInvalid DenialCode = "Invalid"
+	// DenialForbidden denies a change the user isn't allowed to make, though another user may be. Reported as
+	// 403 Forbidden.
+	DenialForbidden DenialCode = "Forbidden"
+	// DenialConflict denies an object conflicting with the current state of other objects: the same request may succeed
+	// once that state changes. Reported as 409 Conflict.
+	DenialConflict DenialCode = "Conflict"
+	// DenialBadRequest denies a malformed request, e.g. one whose object can't be decoded. Reported as 400 BadRequest.
+	DenialBadRequest DenialCode = "BadRequest"
+	// DenialTooManyRequests denies a request the webhook is throttling: the same request succeeds once the client backs
+	// off. Reported as 429 TooManyRequests, with a retry-after hint.
+	DenialTooManyRequests DenialCode = "TooManyRequests"
+	// DenialUnavailable denies a request the webhook can't review yet, e.g. while its caches are warming up: the same
+	// request succeeds once it's ready. Reported as 503 ServiceUnavailable, with a retry-after hint.
+	DenialUnavailable DenialCode = "Unavailable"
+)
+
+// denialCodes lists the codes in the order they are documented.
+var denialCodes = []DenialCode{
+	DenialInvalid, DenialForbidden, DenialConflict, DenialBadRequest, DenialTooManyRequests, DenialUnavailable,
+}
+
+var denialStatuses = map[DenialCode]struct {
+	reason    metav1.StatusReason
+	code      int32
+	retryable bool
+	// retryAfter is the default delay clients are told to wait before retrying, zero if they aren't told to wait.
+	retryAfter  time.Duration
+	description string
+}{
+	DenialInvalid: {
+		reason:      metav1.StatusReasonInvalid,
+		code:        http.StatusUnprocessableEntity,
+		description: "The object fails validation, resubmitting it unchanged fails again.",
+	},
+	DenialForbidden: {
+		reason:      metav1.StatusReasonForbidden,
+		code:        http.StatusForbidden,
+		description: "The user isn't allowed to make the change, though another user may be.",
+	},
+	DenialConflict: {
+		reason:      metav1.StatusReasonConflict,
+		code:        http.StatusConflict,
+		retryable:   true,
+		description: "The object conflicts with the current state of other objects, retrying may succeed once it changes.",
+	},
+	DenialBadRequest: {
+		reason:      metav1.StatusReasonBadRequest,
+		code:        http.StatusBadRequest,
+		description: "The request itself is malformed, e.g. its object can't be decoded.",
+	},
+	DenialTooManyRequests: {
+		reason:      metav1.StatusReasonTooManyRequests,
+		code:        http.StatusTooManyRequests,
+		retryable:   true,
+		retryAfter:  time.Second,
+		description: "The webhook is throttling the request, retrying succeeds once the client backs off for the hinted delay.",
+	},
+	DenialUnavailable: {
+		reason:      metav1.StatusReasonServiceUnavailable,
+		code:        http.StatusServiceUnavailable,
+		retryable:   true,
+		retryAfter:  5 * time.Second,
+		description: "The webhook can't review the request yet, e.g. while its caches are warming up, retrying succeeds once it's ready.",
+	},
+}
+
+// Status returns the failure status of a denial with the code. Unknown codes are reported as BadRequest. Codes telling
+// clients to wait before retrying set the retry-after seconds of the details, which client-go honors when backing off.
+func (c DenialCode) Status(message string) *metav1.Status {
+	status, ok := denialStatuses[c]
+	if !ok {
+		status = denialStatuses[DenialBadRequest]
+	}
+	result := &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Message: message,
+		Reason:  status.reason,
+		Code:    status.code,
+	}
+	if status.retryAfter > 0 {
+		result.Details = &metav1.StatusDetails{RetryAfterSeconds: retryAfterSeconds(status.retryAfter)}
+	}
+	return result
+}
+
+// RetryAfter returns the default delay clients are told to wait before retrying a denial with the code, zero if they
+// aren't told to wait.
+func (c DenialCode) RetryAfter() time.Duration {
+	return denialStatuses[c].retryAfter
+}
+
+// ResponseDenied returns an AdmissionResponse denying the request with the status of the code.
+func ResponseDenied(code DenialCode, message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Result:  code.Status(message),
+		Allowed: false,
+	}
+}
+
+// ResponseRetryAfter returns an AdmissionResponse denying the request with the status of the code, telling clients to
+// wait for the delay before retrying instead of the default delay of the code.
+func ResponseRetryAfter(code DenialCode, message string, retryAfter time.Duration) *admissionv1.AdmissionResponse {
+	response := ResponseDenied(code, message)
+	if response.Result.Details == nil {
+		response.Result.Details = &metav1.StatusDetails{}
+	}
+	response.Result.Details.RetryAfterSeconds = retryAfterSeconds(retryAfter)
+	return response
+}
+
+// retryAfterSeconds rounds the delay up to whole seconds, at least one, since a zero hint tells clients not to wait.
+func retryAfterSeconds(retryAfter time.Duration) int32 {
+	seconds := int32((retryAfter + time.Second - 1) / time.Second)
+	return max(seconds, 1)
+}
@@ -0,0 +1,72 @@
+package admission
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResponseDenied(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		code       DenialCode
+		wantReason metav1.StatusReason
+		wantCode   int32
+		wantRetry  int32
+	}{
+		{code: DenialInvalid, wantReason: metav1.StatusReasonInvalid, wantCode: http.StatusUnprocessableEntity},
+		{code: DenialForbidden, wantReason: metav1.StatusReasonForbidden, wantCode: http.StatusForbidden},
+		{code: DenialConflict, wantReason: metav1.StatusReasonConflict, wantCode: http.StatusConflict},
+		{code: DenialBadRequest, wantReason: metav1.StatusReasonBadRequest, wantCode: http.StatusBadRequest},
+		{code: DenialTooManyRequests, wantReason: metav1.StatusReasonTooManyRequests, wantCode: http.StatusTooManyRequests, wantRetry: 1},
+		{code: DenialUnavailable, wantReason: metav1.StatusReasonServiceUnavailable, wantCode: http.StatusServiceUnavailable, wantRetry: 5},
+		{code: "Unknown", wantReason: metav1.StatusReasonBadRequest, wantCode: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(string(test.code), func(t *testing.T) {
+			t.Parallel()
+			response := ResponseDenied(test.code, "denied")
+			assert.False(t, response.Allowed)
+			want := &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "denied",
+				Reason:  test.wantReason,
+				Code:    test.wantCode,
+			}
+			if test.wantRetry != 0 {
+				want.Details = &metav1.StatusDetails{RetryAfterSeconds: test.wantRetry}
+			}
+			assert.Equal(t, want, response.Result)
+		})
+	}
+}
+
+func TestResponseRetryAfter(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		retryAfter time.Duration
+		want       int32
+	}{
+		{name: "whole seconds", retryAfter: 30 * time.Second, want: 30},
+		{name: "rounded up", retryAfter: 1500 * time.Millisecond, want: 2},
+		{name: "at least a second", retryAfter: 0, want: 1},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			response := ResponseRetryAfter(DenialTooManyRequests, "throttled", test.retryAfter)
+			assert.False(t, response.Allowed)
+			assert.Equal(t, metav1.StatusReasonTooManyRequests, response.Result.Reason)
+			assert.Equal(t, test.want, response.Result.Details.RetryAfterSeconds)
+		})
+	}
+	// codes without a default delay are told to wait too
+	response := ResponseRetryAfter(DenialConflict, "conflict", 3*time.Second)
+	assert.Equal(t, int32(3), response.Result.Details.RetryAfterSeconds)
+}
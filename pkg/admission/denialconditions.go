@@ -0,0 +1,149 @@
+package admission
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// DeniedCondition is the type of the condition set on the objects whose requests are repeatedly denied.
+	DeniedCondition = "WebhookDenied"
+	// deniedConditionReason is the reason of the DeniedCondition. Rule IDs aren't valid condition reasons, so the rule
+	// is named in the message instead.
+	deniedConditionReason = "RepeatedlyDenied"
+)
+
+// deniedFight is a fight over an object, as seen by the denial condition writer.
+type deniedFight struct {
+	key     denialKey
+	object  fightObject
+	since   time.Time
+	message string
+}
+
+// deniedFights returns the records currently fighting, with their objects.
+func (d *denialAggregator) deniedFights() []deniedFight {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var fights []deniedFight
+	for key, rec := range d.records {
+		if rec.fightingSince.IsZero() {
+			continue
+		}
+		fights = append(fights, deniedFight{key: key, object: rec.object, since: rec.fightingSince, message: rec.message})
+	}
+	return fights
+}
+
+// denialConditionWriter sets the DeniedCondition on the status of the objects whose requests are repeatedly denied, so
+// that users see why the updates of their controllers don't go through in the object itself.
+type denialConditionWriter struct {
+	client     dynamic.Interface
+	aggregator *denialAggregator
+	now        func() time.Time
+	// written holds the start of the fights whose condition was written, so that each fight is only written once.
+	written map[denialKey]time.Time
+}
+
+// WriteDenialConditions sets the WebhookDenied condition on the status of the objects whose requests are repeatedly
+// denied, once per fight, until the context is done. The condition names the rule, the user and the denial message.
+func WriteDenialConditions(ctx context.Context, client dynamic.Interface) {
+	writer := &denialConditionWriter{
+		client:     client,
+		aggregator: denials,
+		now:        time.Now,
+		written:    map[denialKey]time.Time{},
+	}
+	ticker := time.NewTicker(denials.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			writer.sync(ctx)
+		}
+	}
+}
+
+// sync writes the condition of the fights that started since the last sync, and forgets the fights that ended.
+func (w *denialConditionWriter) sync(ctx context.Context) {
+	fighting := map[denialKey]bool{}
+	for _, fight := range w.aggregator.deniedFights() {
+		fighting[fight.key] = true
+		if since, ok := w.written[fight.key]; ok && since.Equal(fight.since) {
+			continue
+		}
+		if err := w.write(ctx, fight); err != nil {
+			logrus.Warnf("[denials] failed to set the %s condition of %s %s: %v", DeniedCondition, fight.key.Resource, fight.key.Object, err)
+			continue
+		}
+		w.written[fight.key] = fight.since
+	}
+	for key := range w.written {
+		if !fighting[key] {
+			delete(w.written, key)
+		}
+	}
+}
+
+// write sets the condition of the fight on the status of its object. Objects that don't exist, e.g. because they were
+// denied on creation, or have no status subresource are skipped.
+func (w *denialConditionWriter) write(ctx context.Context, fight deniedFight) error {
+	client := w.client.Resource(fight.object.gvr).Namespace(fight.object.namespace)
+	obj, err := client.Get(ctx, fight.object.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := setDeniedCondition(obj, fight, w.now()); err != nil {
+		return err
+	}
+	_, err = client.UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// setDeniedCondition sets the DeniedCondition of the fight in the status conditions of the object, replacing the
+// previous one.
+func setDeniedCondition(obj *unstructured.Unstructured, fight deniedFight, now time.Time) error {
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return fmt.Errorf("invalid status conditions: %w", err)
+	}
+	message := fmt.Sprintf("denied repeatedly for user %s since %s: %s", fight.key.User, fight.since.UTC().Format(time.RFC3339), fight.message)
+	if fight.key.Rule != "" {
+		message = fmt.Sprintf("rule %s %s", fight.key.Rule, message)
+	}
+	timestamp := now.UTC().Format(time.RFC3339)
+	condition := map[string]any{
+		"type":               DeniedCondition,
+		"status":             string(metav1.ConditionTrue),
+		"reason":             deniedConditionReason,
+		"message":            message,
+		"lastTransitionTime": timestamp,
+		"lastUpdateTime":     timestamp,
+	}
+	replaced := false
+	for i, c := range conditions {
+		if existing, ok := c.(map[string]any); ok && existing["type"] == DeniedCondition {
+			conditions[i] = condition
+			replaced = true
+		}
+	}
+	if !replaced {
+		conditions = append(conditions, condition)
+	}
+	return unstructured.SetNestedSlice(obj.Object, conditions, "status", "conditions")
+}
@@ -0,0 +1,108 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestDenialConditionWriter(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	d := newDenialAggregator(time.Minute, 3)
+	d.now = func() time.Time { return now }
+	const message = "quota exceeded (see /rules/project-used-quota)"
+
+	gvr := schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"}
+	project := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "management.cattle.io/v3",
+		"kind":       "Project",
+		"metadata":   map[string]any{"name": "p-1", "namespace": "local"},
+		"status": map[string]any{"conditions": []any{
+			map[string]any{"type": "Ready", "status": "True"},
+		}},
+	}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "ProjectList"}, project)
+	writer := &denialConditionWriter{
+		client:     client,
+		aggregator: d,
+		now:        func() time.Time { return now },
+		written:    map[denialKey]time.Time{},
+	}
+	conditions := func() []any {
+		obj, err := client.Resource(gvr).Namespace("local").Get(context.Background(), "p-1", metav1.GetOptions{})
+		require.NoError(t, err)
+		conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		require.NoError(t, err)
+		return conditions
+	}
+
+	// denials under the threshold don't set the condition
+	d.record(denialRequest("u-a", "p-1"), message)
+	d.record(denialRequest("u-a", "p-1"), message)
+	writer.sync(context.Background())
+	assert.Len(t, conditions(), 1)
+
+	// a fight sets the condition, keeping the other conditions
+	d.record(denialRequest("u-a", "p-1"), message)
+	// fights over objects that don't exist are skipped
+	for i := 0; i < 3; i++ {
+		d.record(denialRequest("u-a", "p-2"), message)
+	}
+	writer.sync(context.Background())
+	got := conditions()
+	require.Len(t, got, 2)
+	assert.Equal(t, map[string]any{
+		"type":               DeniedCondition,
+		"status":             "True",
+		"reason":             "RepeatedlyDenied",
+		"message":            "rule project-used-quota denied repeatedly for user u-a since 2025-03-01T00:00:00Z: " + message,
+		"lastTransitionTime": "2025-03-01T00:00:00Z",
+		"lastUpdateTime":     "2025-03-01T00:00:00Z",
+	}, got[1])
+
+	// the condition is only written once per fight
+	now = now.Add(10 * time.Second)
+	d.record(denialRequest("u-a", "p-1"), message)
+	writer.sync(context.Background())
+	assert.Equal(t, "2025-03-01T00:00:00Z", conditions()[1].(map[string]any)["lastUpdateTime"])
+	assert.Len(t, writer.written, 2)
+
+	// fights that ended are forgotten
+	now = now.Add(time.Minute)
+	d.flush()
+	now = now.Add(time.Minute)
+	d.flush()
+	writer.sync(context.Background())
+	assert.Empty(t, writer.written)
+}
+
+func TestSetDeniedConditionReplacesCondition(t *testing.T) {
+	t.Parallel()
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"status": map[string]any{"conditions": []any{
+			map[string]any{"type": DeniedCondition, "status": "True", "message": "old"},
+		}},
+	}}
+	fight := deniedFight{
+		key:     denialKey{User: "u-a", Rule: "project-used-quota"},
+		since:   time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		message: "quota exceeded",
+	}
+	require.NoError(t, setDeniedCondition(obj, fight, fight.since.Add(time.Minute)))
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	require.NoError(t, err)
+	require.Len(t, conditions, 1)
+	assert.Equal(t, "rule project-used-quota denied repeatedly for user u-a since 2025-03-01T00:00:00Z: quota exceeded",
+		conditions[0].(map[string]any)["message"])
+	assert.Equal(t, "2025-03-01T00:01:00Z", conditions[0].(map[string]any)["lastUpdateTime"])
+}
@@ -0,0 +1,240 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// DenialsPath is the path of the debug endpoint listing the objects whose requests are repeatedly denied.
+	DenialsPath = "/debug/denials"
+
+	// denialSummaryInterval is how often repeated denials are summarized.
+	denialSummaryInterval = time.Minute
+	// fightThreshold is the number of identical denials within an interval after which further ones are only
+	// summarized, since they most likely come from controllers fighting over the object.
+	fightThreshold = 5
+)
+
+// ruleIDPattern matches the link to the documentation of a rule in denial messages, see rules.Rule.Message.
+var ruleIDPattern = regexp.MustCompile(`\(see /rules/([a-z0-9-]+)\)`)
+
+var (
+	repeatedDenials = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rancher_webhook",
+		Name:      "repeated_denials_total",
+		Help:      "Number of denials that were only summarized in the logs because the same user was repeatedly denied the same request on an object.",
+	}, []string{"resource", "rule"})
+	fightingObjects = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "rancher_webhook",
+		Name:      "fighting_objects",
+		Help:      "Number of objects whose requests are currently repeatedly denied.",
+	}, func() float64 { return float64(len(denials.fights())) })
+)
+
+func init() {
+	prometheus.MustRegister(repeatedDenials, fightingObjects)
+}
+
+// denialKey identifies identical denials.
+type denialKey struct {
+	User     string
+	Resource string
+	Rule     string
+	Object   string
+}
+
+// denialRecord counts the identical denials of the current interval.
+type denialRecord struct {
+	windowStart time.Time
+	count       int
+	suppressed  int
+	// fightingSince is when the denial started repeating, zero if it isn't.
+	fightingSince time.Time
+	lastSeen      time.Time
+	message       string
+	// object is the denied object.
+	object fightObject
+}
+
+// fightObject is an object whose requests are repeatedly denied.
+type fightObject struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// Fight is an object whose requests are repeatedly denied.
+type Fight struct {
+	User     string    `json:"user"`
+	Resource string    `json:"resource"`
+	Rule     string    `json:"rule,omitempty"`
+	Object   string    `json:"object"`
+	Since    time.Time `json:"since"`
+	LastSeen time.Time `json:"lastSeen"`
+	// Count is the number of denials in the current interval.
+	Count   int    `json:"count"`
+	Message string `json:"message"`
+}
+
+// denialAggregator logs denials, collapsing identical denials repeating within an interval into summaries.
+type denialAggregator struct {
+	mutex     sync.Mutex
+	interval  time.Duration
+	threshold int
+	now       func() time.Time
+	records   map[denialKey]*denialRecord
+}
+
+var denials = newDenialAggregator(denialSummaryInterval, fightThreshold)
+
+func newDenialAggregator(interval time.Duration, threshold int) *denialAggregator {
+	return &denialAggregator{
+		interval:  interval,
+		threshold: threshold,
+		now:       time.Now,
+		records:   map[denialKey]*denialRecord{},
+	}
+}
+
+// record logs the denial of the request, unless the same denial is repeating within the interval.
+func (d *denialAggregator) record(request *Request, message string) {
+	key := denialKey{
+		User:     request.UserInfo.Username,
+		Resource: schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String(),
+		Object:   resourceString(request.Namespace, request.Name),
+	}
+	if match := ruleIDPattern.FindStringSubmatch(message); match != nil {
+		key.Rule = match[1]
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	now := d.now()
+	rec, ok := d.records[key]
+	if !ok {
+		rec = &denialRecord{windowStart: now}
+		d.records[key] = rec
+	} else if now.Sub(rec.windowStart) >= d.interval {
+		d.rotate(key, rec, now)
+	}
+	rec.count++
+	rec.lastSeen = now
+	rec.message = message
+	rec.object = fightObject{
+		gvr:       schema.GroupVersionResource(request.Resource),
+		namespace: request.Namespace,
+		name:      request.Name,
+	}
+
+	switch {
+	case !rec.fightingSince.IsZero():
+		rec.suppressed++
+		repeatedDenials.WithLabelValues(key.Resource, key.Rule).Inc()
+	case rec.count >= d.threshold:
+		rec.fightingSince = now
+		logrus.Warnf("[denials] %s %s denied %d times for user %s within %s, repeats are summarized every %s: %s",
+			key.Resource, key.Object, rec.count, key.User, d.interval, d.interval, message)
+	default:
+		logrus.Infof("[denials] %s %s %s denied for user %s: %s", request.Operation, key.Resource, key.Object, key.User, message)
+	}
+}
+
+// rotate starts a new interval for the record, logging a summary of the denials suppressed in the last one. Records
+// stop fighting once they fall under the threshold.
+func (d *denialAggregator) rotate(key denialKey, rec *denialRecord, now time.Time) {
+	if rec.suppressed > 0 {
+		logrus.Warnf("[denials] %s %s denied %d more times for user %s in the last %s: %s",
+			key.Resource, key.Object, rec.suppressed, key.User, d.interval, rec.message)
+	}
+	if rec.count < d.threshold {
+		rec.fightingSince = time.Time{}
+	}
+	rec.windowStart = now
+	rec.count = 0
+	rec.suppressed = 0
+}
+
+// flush rotates the records whose interval ended, and forgets the ones without denials in their last interval.
+func (d *denialAggregator) flush() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	now := d.now()
+	for key, rec := range d.records {
+		if now.Sub(rec.windowStart) < d.interval {
+			continue
+		}
+		if rec.count == 0 {
+			delete(d.records, key)
+			continue
+		}
+		d.rotate(key, rec, now)
+	}
+}
+
+// fights returns the records currently fighting, sorted by resource, object and user.
+func (d *denialAggregator) fights() []Fight {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	fights := []Fight{}
+	for key, rec := range d.records {
+		if rec.fightingSince.IsZero() {
+			continue
+		}
+		fights = append(fights, Fight{
+			User:     key.User,
+			Resource: key.Resource,
+			Rule:     key.Rule,
+			Object:   key.Object,
+			Since:    rec.fightingSince,
+			LastSeen: rec.lastSeen,
+			Count:    rec.count,
+			Message:  rec.message,
+		})
+	}
+	sort.Slice(fights, func(i, j int) bool {
+		if fights[i].Resource != fights[j].Resource {
+			return fights[i].Resource < fights[j].Resource
+		}
+		if fights[i].Object != fights[j].Object {
+			return fights[i].Object < fights[j].Object
+		}
+		return fights[i].User < fights[j].User
+	})
+	return fights
+}
+
+// SummarizeDenials logs the summaries of repeated denials at the end of each interval until the context is done.
+func SummarizeDenials(ctx context.Context) {
+	ticker := time.NewTicker(denials.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			denials.flush()
+		}
+	}
+}
+
+// RegisterDenialHandlers adds the debug endpoint listing the objects whose requests are repeatedly denied to the
+// router.
+func RegisterDenialHandlers(router *mux.Router) {
+	router.HandleFunc(DenialsPath, func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(denials.fights()); err != nil {
+			logrus.Errorf("failed to write denials response: %v", err)
+		}
+	}).Methods(http.MethodGet)
+}
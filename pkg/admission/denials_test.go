@@ -0,0 +1,97 @@
+package admission
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func denialRequest(user, name string) *Request {
+	return &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"},
+		Namespace: "local",
+		Name:      name,
+		UserInfo:  authenticationv1.UserInfo{Username: user},
+	}}
+}
+
+// TestDenialAggregator isn't parallel since it captures the standard logger.
+func TestDenialAggregator(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	d := newDenialAggregator(time.Minute, 3)
+	d.now = func() time.Time { return now }
+	const message = "quota exceeded (see /rules/project-used-quota)"
+
+	// denials under the threshold are all logged
+	d.record(denialRequest("u-a", "p-1"), message)
+	d.record(denialRequest("u-a", "p-1"), message)
+	d.record(denialRequest("u-b", "p-1"), message)
+	assert.Len(t, hook.AllEntries(), 3)
+	assert.Equal(t, logrus.InfoLevel, hook.LastEntry().Level)
+	assert.Empty(t, d.fights())
+
+	// the denial reaching the threshold starts a fight, and later ones are suppressed
+	d.record(denialRequest("u-a", "p-1"), message)
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	assert.Contains(t, hook.LastEntry().Message, "projects.management.cattle.io local/p-1 denied 3 times for user u-a")
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Second)
+		d.record(denialRequest("u-a", "p-1"), message)
+	}
+	assert.Len(t, hook.AllEntries(), 4)
+	fights := d.fights()
+	require.Len(t, fights, 1)
+	assert.Equal(t, Fight{
+		User:     "u-a",
+		Resource: "projects.management.cattle.io",
+		Rule:     "project-used-quota",
+		Object:   "local/p-1",
+		Since:    time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		LastSeen: now,
+		Count:    13,
+		Message:  message,
+	}, fights[0])
+
+	// the end of the interval logs a summary, and the fight goes on while the denials repeat
+	now = now.Add(time.Minute)
+	d.flush()
+	assert.Len(t, hook.AllEntries(), 5)
+	assert.Contains(t, hook.LastEntry().Message, "denied 10 more times for user u-a in the last 1m0s")
+	d.record(denialRequest("u-a", "p-1"), message)
+	assert.Len(t, hook.AllEntries(), 5)
+	assert.Len(t, d.fights(), 1)
+
+	// the fight ends after an interval under the threshold, and the records are forgotten after an idle interval
+	now = now.Add(time.Minute)
+	d.flush()
+	assert.Empty(t, d.fights())
+	assert.Len(t, hook.AllEntries(), 6)
+	now = now.Add(time.Minute)
+	d.flush()
+	assert.Empty(t, d.records)
+}
+
+func TestDenialHandlers(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	RegisterDenialHandlers(router)
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, DenialsPath, nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	var fights []Fight
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &fights))
+}
@@ -0,0 +1,126 @@
+package admission
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// deniedObjectPrefix is the prefix of the generated names of denied objects.
+	deniedObjectPrefix = "denied-"
+	// deniedObjectQueueSize is the number of denied objects waiting to be written, above which denied objects are
+	// dropped rather than slowing down admission.
+	deniedObjectQueueSize = 100
+	// deniedObjectExpiryInterval is how often expired denied objects are deleted.
+	deniedObjectExpiryInterval = time.Minute
+)
+
+// DeniedObjectClient creates, lists and deletes DeniedObjects, e.g. the generated DeniedObject client.
+type DeniedObjectClient interface {
+	Create(*webhookv1.DeniedObject) (*webhookv1.DeniedObject, error)
+	List(opts metav1.ListOptions) (*webhookv1.DeniedObjectList, error)
+	Delete(name string, opts *metav1.DeleteOptions) error
+}
+
+// deniedObjectRetainer writes the denied objects selected for retention and deletes them once they expire. Objects are
+// written in the background, since admission must not wait for the API server.
+type deniedObjectRetainer struct {
+	mutex  sync.Mutex
+	client DeniedObjectClient
+	queue  chan *webhookv1.DeniedObject
+	now    func() time.Time
+}
+
+var retainedObjects = &deniedObjectRetainer{queue: make(chan *webhookv1.DeniedObject, deniedObjectQueueSize), now: time.Now}
+
+// ConfigureDeniedObjectRetention makes the denied creates selected by the denied object logging setting be retained
+// with the given client. Without a client, no objects are retained.
+func ConfigureDeniedObjectRetention(client DeniedObjectClient) {
+	retainedObjects.mutex.Lock()
+	defer retainedObjects.mutex.Unlock()
+	retainedObjects.client = client
+}
+
+// currentClient returns the client denied objects are written with, nil if retention isn't configured.
+func (r *deniedObjectRetainer) currentClient() DeniedObjectClient {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.client
+}
+
+// retain queues the redacted object of the denied create to be written, retained for the ttl or DefaultDeniedObjectTTL
+// if zero. The object is dropped if the queue is full.
+func (r *deniedObjectRetainer) retain(request *Request, resource, message string, ttl time.Duration) {
+	if r.currentClient() == nil {
+		return
+	}
+	if ttl == 0 {
+		ttl = DefaultDeniedObjectTTL
+	}
+	object := &webhookv1.DeniedObject{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: deniedObjectPrefix},
+		Spec: webhookv1.DeniedObjectSpec{
+			Resource:  resource,
+			Namespace: request.Namespace,
+			Name:      request.Name,
+			User:      request.UserInfo.Username,
+			Message:   message,
+			Object:    deniedObject(request, MaxDeniedObjectMaxBytes),
+			Expires:   metav1.NewTime(r.now().Add(ttl)),
+		},
+	}
+	select {
+	case r.queue <- object:
+	default:
+		logrus.Warnf("[denied-objects] too many denied objects waiting to be retained, dropped the object denied for user %s: %s %s",
+			request.UserInfo.Username, resource, resourceString(request.Namespace, request.Name))
+	}
+}
+
+// RetainDeniedObjects writes the queued denied objects and deletes the expired ones until the context is canceled. It
+// returns immediately if retention isn't configured.
+func RetainDeniedObjects(ctx context.Context) {
+	client := retainedObjects.currentClient()
+	if client == nil {
+		return
+	}
+	ticker := time.NewTicker(deniedObjectExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case object := <-retainedObjects.queue:
+			if _, err := client.Create(object); err != nil {
+				logrus.Warnf("[denied-objects] failed to retain the object denied for user %s: %v", object.Spec.User, err)
+			}
+		case <-ticker.C:
+			retainedObjects.deleteExpired(client)
+		}
+	}
+}
+
+// deleteExpired deletes the denied objects which expired.
+func (r *deniedObjectRetainer) deleteExpired(client DeniedObjectClient) {
+	list, err := client.List(metav1.ListOptions{})
+	if err != nil {
+		logrus.Warnf("[denied-objects] failed to list denied objects: %v", err)
+		return
+	}
+	now := metav1.NewTime(r.now())
+	for i := range list.Items {
+		object := &list.Items[i]
+		if now.Before(&object.Spec.Expires) {
+			continue
+		}
+		if err := client.Delete(object.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			logrus.Warnf("[denied-objects] failed to delete expired denied object %s: %v", object.Name, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package admission
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fakeDeniedObjects stores denied objects in memory.
+type fakeDeniedObjects struct {
+	mutex   sync.Mutex
+	objects map[string]webhookv1.DeniedObject
+}
+
+func (f *fakeDeniedObjects) Create(object *webhookv1.DeniedObject) (*webhookv1.DeniedObject, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	created := *object.DeepCopy()
+	created.Name = created.GenerateName + created.Spec.Name
+	f.objects[created.Name] = created
+	return &created, nil
+}
+
+func (f *fakeDeniedObjects) List(metav1.ListOptions) (*webhookv1.DeniedObjectList, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	list := &webhookv1.DeniedObjectList{}
+	for _, object := range f.objects {
+		list.Items = append(list.Items, object)
+	}
+	return list, nil
+}
+
+func (f *fakeDeniedObjects) Delete(name string, _ *metav1.DeleteOptions) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.objects, name)
+	return nil
+}
+
+func (f *fakeDeniedObjects) names() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	names := []string{}
+	for name := range f.objects {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestDeniedObjectRetainer(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1700000000, 0)
+	client := &fakeDeniedObjects{objects: map[string]webhookv1.DeniedObject{}}
+	retainer := &deniedObjectRetainer{
+		client: client,
+		queue:  make(chan *webhookv1.DeniedObject, 1),
+		now:    func() time.Time { return now },
+	}
+	request := func(name string) *Request {
+		return &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      name,
+			Namespace: "c-abc12",
+			Operation: admissionv1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: "u-12345"},
+			Object:    runtime.RawExtension{Raw: []byte(`{"kind": "Project", "spec": {"token": "t"}}`)},
+		}}
+	}
+
+	retainer.retain(request("p-1"), "projects.management.cattle.io", "denied", 0)
+	retainer.retain(request("p-2"), "projects.management.cattle.io", "denied", time.Minute)
+	require.Len(t, retainer.queue, 1, "objects are dropped once the queue is full")
+	object := <-retainer.queue
+	assert.Equal(t, webhookv1.DeniedObjectSpec{
+		Resource:  "projects.management.cattle.io",
+		Namespace: "c-abc12",
+		Name:      "p-1",
+		User:      "u-12345",
+		Message:   "denied",
+		Object:    `{"kind":"Project","spec":{"token":"[REDACTED]"}}`,
+		Expires:   metav1.NewTime(now.Add(DefaultDeniedObjectTTL)),
+	}, object.Spec)
+	assert.Equal(t, deniedObjectPrefix, object.GenerateName)
+
+	_, err := client.Create(object)
+	require.NoError(t, err)
+	expired := object.DeepCopy()
+	expired.Spec.Name = "p-0"
+	expired.Spec.Expires = metav1.NewTime(now.Add(-time.Second))
+	_, err = client.Create(expired)
+	require.NoError(t, err)
+	retainer.deleteExpired(client)
+	assert.Equal(t, []string{"denied-p-1"}, client.names())
+}
+
+func TestDeniedObjectRetainerWithoutClient(t *testing.T) {
+	t.Parallel()
+	retainer := &deniedObjectRetainer{queue: make(chan *webhookv1.DeniedObject, 1), now: time.Now}
+	retainer.retain(&Request{}, "namespaces", "denied", 0)
+	assert.Empty(t, retainer.queue)
+}
+
+func TestRetainDeniedObjectsWithoutClient(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		RetainDeniedObjects(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RetainDeniedObjects didn't return without a client")
+	}
+}
@@ -0,0 +1,222 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// DeniedObjectLoggingSetting is the name of the debug setting selecting the resources whose denied objects are logged.
+	DeniedObjectLoggingSetting = "webhook-log-denied-objects"
+
+	// DefaultDeniedObjectMaxBytes is the size denied objects are truncated to when the setting has no maxBytes.
+	DefaultDeniedObjectMaxBytes = 4096
+	// MaxDeniedObjectMaxBytes is the largest size denied objects can be truncated to.
+	MaxDeniedObjectMaxBytes = 65536
+
+	// DefaultDeniedObjectTTL is how long denied objects are retained when the setting has no ttl.
+	DefaultDeniedObjectTTL = time.Hour
+	// MaxDeniedObjectTTL is the longest denied objects can be retained.
+	MaxDeniedObjectTTL = 24 * time.Hour
+
+	redacted = "[REDACTED]"
+)
+
+// sensitiveKeys are the parts of field names whose values are redacted from logged objects, in lower case.
+var sensitiveKeys = []string{"password", "secret", "token", "credential", "privatekey", "last-applied-configuration"}
+
+// sensitiveTopLevelKeys are the top level fields whose values are redacted from logged objects, e.g. the data of
+// Secrets and ConfigMaps.
+var sensitiveTopLevelKeys = []string{"data", "stringData", "binaryData"}
+
+// DeniedObjectLogging is the value of the denied object logging setting.
+type DeniedObjectLogging struct {
+	// Resources are the resources whose denied objects are logged, in the resource.group form of the webhook paths,
+	// e.g. projects.management.cattle.io, or namespaces for core resources.
+	Resources []string `json:"resources"`
+	// MaxBytes is the size logged objects are truncated to, DefaultDeniedObjectMaxBytes if zero.
+	MaxBytes int `json:"maxBytes,omitempty"`
+	// Retain selects the resources whose denied creates are retained as DeniedObjects, none if nil.
+	Retain *DeniedObjectRetention `json:"retain,omitempty"`
+}
+
+// DeniedObjectRetention selects the resources whose denied creates are retained as DeniedObjects.
+type DeniedObjectRetention struct {
+	// Resources are the resources whose denied creates are retained, in the same form as the logged resources.
+	Resources []string `json:"resources"`
+	// TTL is how long denied objects are retained, DefaultDeniedObjectTTL if zero.
+	TTL metav1.Duration `json:"ttl,omitempty"`
+}
+
+// ParseDeniedObjectLogging parses the value of the denied object logging setting, a JSON object such as
+// {"resources": ["projects.management.cattle.io"], "maxBytes": 2048, "retain": {"resources": ["projects.management.cattle.io"], "ttl": "2h"}}.
+// An empty value logs and retains no objects.
+func ParseDeniedObjectLogging(value string) (DeniedObjectLogging, error) {
+	var logging DeniedObjectLogging
+	if strings.TrimSpace(value) == "" {
+		return logging, nil
+	}
+	if err := json.Unmarshal([]byte(value), &logging); err != nil {
+		return logging, fmt.Errorf("invalid denied object logging: %w", err)
+	}
+	if logging.MaxBytes < 0 || logging.MaxBytes > MaxDeniedObjectMaxBytes {
+		return logging, fmt.Errorf("maxBytes must be between 0 and %d", MaxDeniedObjectMaxBytes)
+	}
+	resources := logging.Resources
+	if logging.Retain != nil {
+		if logging.Retain.TTL.Duration < 0 || logging.Retain.TTL.Duration > MaxDeniedObjectTTL {
+			return logging, fmt.Errorf("retain.ttl must be between 0 and %s", MaxDeniedObjectTTL)
+		}
+		resources = append(slices.Clone(resources), logging.Retain.Resources...)
+	}
+	for _, resource := range resources {
+		if strings.TrimSpace(resource) == "" || strings.Contains(resource, "/") {
+			return logging, fmt.Errorf("invalid resource %q, resources must be in the resource.group form", resource)
+		}
+	}
+	return logging, nil
+}
+
+// SettingGetter gets management.cattle.io settings by name, e.g. a Setting cache.
+type SettingGetter interface {
+	Get(name string) (*v3.Setting, error)
+}
+
+// deniedObjectLogger logs the redacted objects of denied requests for the resources selected by the setting.
+type deniedObjectLogger struct {
+	mutex    sync.Mutex
+	settings SettingGetter
+	// value and logging cache the last parsed setting value.
+	value   string
+	logging DeniedObjectLogging
+}
+
+var deniedObjects = &deniedObjectLogger{}
+
+// ConfigureDeniedObjectLogging makes denied objects be logged according to the setting read from the given settings.
+// Without settings, no objects are logged.
+func ConfigureDeniedObjectLogging(settings SettingGetter) {
+	deniedObjects.mutex.Lock()
+	defer deniedObjects.mutex.Unlock()
+	deniedObjects.settings = settings
+	deniedObjects.value = ""
+	deniedObjects.logging = DeniedObjectLogging{}
+}
+
+// log logs the object of the denied request if the setting selects its resource, and retains it if the request is a
+// create of a resource selected for retention.
+func (d *deniedObjectLogger) log(request *Request, message string) {
+	logging := d.current()
+	resource := schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String()
+	if logging.Retain != nil && request.Operation == admissionv1.Create && slices.Contains(logging.Retain.Resources, resource) {
+		retainedObjects.retain(request, resource, message, logging.Retain.TTL.Duration)
+	}
+	if !slices.Contains(logging.Resources, resource) {
+		return
+	}
+	logrus.Infof("[denied-objects] %s %s %s user=%s denied: %s object=%s", request.Operation, request.Kind.String(),
+		resourceString(request.Namespace, request.Name), request.UserInfo.Username, message, deniedObject(request, logging.MaxBytes))
+}
+
+// current returns the logging of the setting, which logs no objects if the setting can't be read.
+func (d *deniedObjectLogger) current() DeniedObjectLogging {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.settings == nil {
+		return DeniedObjectLogging{}
+	}
+	setting, err := d.settings.Get(DeniedObjectLoggingSetting)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logrus.Warnf("[denied-objects] failed to get setting %s: %v", DeniedObjectLoggingSetting, err)
+		}
+		return DeniedObjectLogging{}
+	}
+	value := setting.Value
+	if value == "" {
+		value = setting.Default
+	}
+	if value == d.value {
+		return d.logging
+	}
+	logging, err := ParseDeniedObjectLogging(value)
+	if err != nil {
+		logrus.Warnf("[denied-objects] failed to parse setting %s: %v", DeniedObjectLoggingSetting, err)
+		return DeniedObjectLogging{}
+	}
+	d.value = value
+	d.logging = logging
+	return logging
+}
+
+// deniedObject returns the redacted object of the request, or its old object for deletions, truncated to maxBytes.
+func deniedObject(request *Request, maxBytes int) string {
+	raw := request.Object.Raw
+	if len(raw) == 0 {
+		raw = request.OldObject.Raw
+	}
+	var object map[string]any
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return "<unreadable>"
+	}
+	for _, key := range sensitiveTopLevelKeys {
+		if _, ok := object[key]; ok {
+			object[key] = redacted
+		}
+	}
+	if metadata, ok := object["metadata"].(map[string]any); ok {
+		delete(metadata, "managedFields")
+	}
+	data, err := json.Marshal(redact(object))
+	if err != nil {
+		return "<unreadable>"
+	}
+	if maxBytes == 0 {
+		maxBytes = DefaultDeniedObjectMaxBytes
+	}
+	if len(data) <= maxBytes {
+		return string(data)
+	}
+	return strings.ToValidUTF8(string(data[:maxBytes]), "") + fmt.Sprintf("...(%d bytes truncated)", len(data)-maxBytes)
+}
+
+// redact replaces the values of the sensitive fields of the value with a placeholder.
+func redact(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if isSensitive(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redact(field)
+		}
+	case []any:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	}
+	return value
+}
+
+// isSensitive returns true if the field name contains one of the sensitive keys.
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package admission
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fakeSettings returns the setting with the given value, or the given error.
+type fakeSettings struct {
+	value string
+	err   error
+}
+
+func (f *fakeSettings) Get(name string) (*v3.Setting, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &v3.Setting{ObjectMeta: metav1.ObjectMeta{Name: name}, Value: f.value}, nil
+}
+
+func TestParseDeniedObjectLogging(t *testing.T) {
+	t.Parallel()
+	logging, err := ParseDeniedObjectLogging(`{"resources": ["projects.management.cattle.io"], "maxBytes": 2048}`)
+	require.NoError(t, err)
+	assert.Equal(t, DeniedObjectLogging{Resources: []string{"projects.management.cattle.io"}, MaxBytes: 2048}, logging)
+
+	logging, err = ParseDeniedObjectLogging(" ")
+	require.NoError(t, err)
+	assert.Empty(t, logging.Resources)
+
+	logging, err = ParseDeniedObjectLogging(`{"retain": {"resources": ["projects.management.cattle.io"], "ttl": "2h"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, &DeniedObjectRetention{Resources: []string{"projects.management.cattle.io"}, TTL: metav1.Duration{Duration: 2 * time.Hour}}, logging.Retain)
+
+	for _, value := range []string{"foo", `{"maxBytes": 70000}`, `{"resources": ["v1/namespaces"]}`, `{"resources": [""]}`,
+		`{"retain": {"resources": ["v1/namespaces"]}}`, `{"retain": {"resources": ["namespaces"], "ttl": "48h"}}`} {
+		_, err = ParseDeniedObjectLogging(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestDeniedObjectLoggerCurrent(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		settings SettingGetter
+		want     []string
+	}{
+		{name: "no settings"},
+		{name: "setting not readable", settings: &fakeSettings{err: errors.New("unavailable")}},
+		{name: "invalid setting", settings: &fakeSettings{value: "foo"}},
+		{
+			name:     "selected resources",
+			settings: &fakeSettings{value: `{"resources": ["projects.management.cattle.io", "namespaces"]}`},
+			want:     []string{"projects.management.cattle.io", "namespaces"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			logger := &deniedObjectLogger{settings: test.settings}
+			assert.Equal(t, test.want, logger.current().Resources)
+		})
+	}
+}
+
+func TestDeniedObject(t *testing.T) {
+	t.Parallel()
+	secret := `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "s", "managedFields": [{"manager": "kubectl"}],` +
+		`"annotations": {"kubectl.kubernetes.io/last-applied-configuration": "{}"}}, "data": {"key": "dmFsdWU="}}`
+	cluster := `{"kind": "Cluster", "metadata": {"name": "c"}, "spec": {"registries": [{"url": "r", "password": "p", "authConfigSecretName": "a"}]}}`
+
+	tests := []struct {
+		name     string
+		request  *Request
+		maxBytes int
+		want     string
+	}{
+		{
+			name:    "data and annotations redacted",
+			request: &Request{AdmissionRequest: admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: []byte(secret)}}},
+			want:    `{"apiVersion":"v1","data":"[REDACTED]","kind":"Secret","metadata":{"annotations":{"kubectl.kubernetes.io/last-applied-configuration":"[REDACTED]"},"name":"s"}}`,
+		},
+		{
+			name:    "nested fields redacted",
+			request: &Request{AdmissionRequest: admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: []byte(cluster)}}},
+			want:    `{"kind":"Cluster","metadata":{"name":"c"},"spec":{"registries":[{"authConfigSecretName":"[REDACTED]","password":"[REDACTED]","url":"r"}]}}`,
+		},
+		{
+			name:     "truncated",
+			request:  &Request{AdmissionRequest: admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: []byte(cluster)}}},
+			maxBytes: 20,
+			want:     `{"kind":"Cluster","m...(118 bytes truncated)`,
+		},
+		{
+			name:    "old object of a deletion",
+			request: &Request{AdmissionRequest: admissionv1.AdmissionRequest{OldObject: runtime.RawExtension{Raw: []byte(`{"kind": "Project"}`)}}},
+			want:    `{"kind":"Project"}`,
+		},
+		{
+			name:    "no object",
+			request: &Request{},
+			want:    "<unreadable>",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, deniedObject(test.request, test.maxBytes))
+		})
+	}
+
+	large := `{"kind": "Project", "description": "` + strings.Repeat("a", 2*DefaultDeniedObjectMaxBytes) + `"}`
+	got := deniedObject(&Request{AdmissionRequest: admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: []byte(large)}}}, 0)
+	assert.True(t, strings.HasSuffix(got, "bytes truncated)"))
+	assert.Less(t, len(got), DefaultDeniedObjectMaxBytes+50)
+}
@@ -0,0 +1,18 @@
+package admission
+
+import "context"
+
+// dryRunKey is the key marking the contexts of dry run requests.
+type dryRunKey struct{}
+
+// WithDryRun returns a copy of the context marked as the context of a dry run request.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRunContext returns true if the context is the one of a dry run request. Code only given the context of the
+// request, such as the clients used by admitters, uses it to skip side effects and lookups which dry runs don't need.
+func IsDryRunContext(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
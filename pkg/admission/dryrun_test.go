@@ -0,0 +1,38 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type createHandler struct{}
+
+func (createHandler) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"}
+}
+
+func (createHandler) Operations() []v1.OperationType { return []v1.OperationType{v1.Create} }
+
+func TestDryRunContext(t *testing.T) {
+	t.Parallel()
+	for _, dryRun := range []*bool{nil, Ptr(false), Ptr(true)} {
+		body, err := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+			UID:       "1",
+			Operation: admissionv1.Create,
+			DryRun:    dryRun,
+		}})
+		require.NoError(t, err)
+		_, request, err := getReviewAndRequestForHandler(httptest.NewRequest("POST", "/", bytes.NewReader(body)), createHandler{})
+		require.NoError(t, err)
+		assert.Equal(t, request.IsDryRun(), IsDryRunContext(request.Context), "dryRun %v", dryRun)
+	}
+	assert.True(t, IsDryRunContext(WithDryRun(httptest.NewRequest("GET", "/", nil).Context())))
+}
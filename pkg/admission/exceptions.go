@@ -0,0 +1,109 @@
+package admission
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// PolicyExceptionLister lists webhook.cattle.io policy exception requests, e.g. a PolicyExceptionRequest cache.
+type PolicyExceptionLister interface {
+	List(selector labels.Selector) ([]*webhookv1.PolicyExceptionRequest, error)
+}
+
+// exceptionHonorer allows the denied requests excepted from the rules denying them by approved policy exception
+// requests.
+type exceptionHonorer struct {
+	mutex      sync.Mutex
+	exceptions PolicyExceptionLister
+	now        func() time.Time
+}
+
+var policyExceptions = &exceptionHonorer{now: time.Now}
+
+// ConfigurePolicyExceptions makes validating handlers honor the approved, unexpired policy exception requests of the
+// lister. Without a lister, no exceptions are honored.
+func ConfigurePolicyExceptions(exceptions PolicyExceptionLister) {
+	policyExceptions.mutex.Lock()
+	defer policyExceptions.mutex.Unlock()
+	policyExceptions.exceptions = exceptions
+}
+
+// currentExceptions returns the lister of the exceptions, nil if exceptions aren't configured.
+func (e *exceptionHonorer) currentExceptions() PolicyExceptionLister {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.exceptions
+}
+
+// except returns a response allowing the denied request, with a warning for each exception, if exceptions of its user
+// except it from every rule its denial links to. It returns nil if the request isn't excepted, or if the denial
+// doesn't link to a rule.
+func (e *exceptionHonorer) except(request *Request, response *admissionv1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	exceptions := e.currentExceptions()
+	ruleIDs := deniedRules(response)
+	if exceptions == nil || len(ruleIDs) == 0 {
+		return nil
+	}
+	list, err := exceptions.List(labels.Everything())
+	if err != nil {
+		logrus.Warnf("[policy-exceptions] failed to list policy exception requests, keeping the denial: %v", err)
+		return nil
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	resource := schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String()
+	now := metav1.NewTime(e.now())
+	excepted := ResponseAllowed()
+	for _, rule := range ruleIDs {
+		exception := findException(list, rule, resource, request, now)
+		if exception == nil {
+			return nil
+		}
+		excepted.Warnings = append(excepted.Warnings, fmt.Sprintf("policy exception request %s excepts this request from rule %s until %s",
+			exception.Name, rule, exception.Spec.Expires.UTC().Format(time.RFC3339)))
+	}
+	return excepted
+}
+
+// findException returns the first exception excepting the user's request from the rule, nil if there is none.
+func findException(exceptions []*webhookv1.PolicyExceptionRequest, rule, resource string, request *Request, now metav1.Time) *webhookv1.PolicyExceptionRequest {
+	for _, exception := range exceptions {
+		if exception.Excepts(rule, resource, request.Namespace, request.Name, request.UserInfo.Username, now) {
+			return exception
+		}
+	}
+	return nil
+}
+
+// correlationWarning returns the warning giving the correlation ID to request an exception to the denial with, empty
+// if exceptions aren't configured or the denial doesn't link to a rule.
+func (e *exceptionHonorer) correlationWarning(request *Request, response *admissionv1.AdmissionResponse) string {
+	if e.currentExceptions() == nil || len(deniedRules(response)) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("the correlation ID of this denial is %s, give it to a PolicyExceptionRequest to request an exception", request.UID)
+}
+
+// deniedRules returns the IDs of the rules the denial links to, each once.
+func deniedRules(response *admissionv1.AdmissionResponse) []string {
+	if response.Result == nil {
+		return nil
+	}
+	var ids []string
+	for _, match := range ruleIDPattern.FindAllStringSubmatch(response.Result.Message, -1) {
+		if !slices.Contains(ids, match[1]) {
+			ids = append(ids, match[1])
+		}
+	}
+	return ids
+}
@@ -0,0 +1,129 @@
+package admission
+
+import (
+	"testing"
+	"time"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// fakeExceptions lists the policy exception requests it holds.
+type fakeExceptions []*webhookv1.PolicyExceptionRequest
+
+func (f fakeExceptions) List(_ labels.Selector) ([]*webhookv1.PolicyExceptionRequest, error) {
+	return append([]*webhookv1.PolicyExceptionRequest(nil), f...), nil
+}
+
+func TestExceptionHonorer(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	exception := func(name, rule, approver string, expires time.Time) *webhookv1.PolicyExceptionRequest {
+		return &webhookv1.PolicyExceptionRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: webhookv1.PolicyExceptionRequestSpec{
+				CorrelationID: "uid-0",
+				Rule:          rule,
+				Resource:      "clusters.management.cattle.io",
+				Name:          "c-m-abc12",
+				Requester:     "u-requester",
+				Reason:        "hotfix",
+				Expires:       metav1.NewTime(expires),
+				Approver:      approver,
+			},
+		}
+	}
+	tagDenial := ResponseDenied(DenialForbidden, "agent image tag isn't allowed (see /rules/cluster-agent-image-tag)")
+	tests := []struct {
+		name           string
+		exceptions     PolicyExceptionLister
+		user           string
+		response       *admissionv1.AdmissionResponse
+		wantWarnings   []string
+		wantCorrelated bool
+	}{
+		{
+			name:       "approved exception",
+			exceptions: fakeExceptions{exception("agent-tag", "cluster-agent-image-tag", "u-approver", now.Add(time.Hour))},
+			user:       "u-requester",
+			response:   tagDenial,
+			wantWarnings: []string{
+				"policy exception request agent-tag excepts this request from rule cluster-agent-image-tag until 2025-03-01T13:00:00Z",
+			},
+		},
+		{
+			name:           "pending exception",
+			exceptions:     fakeExceptions{exception("agent-tag", "cluster-agent-image-tag", "", now.Add(time.Hour))},
+			user:           "u-requester",
+			response:       tagDenial,
+			wantCorrelated: true,
+		},
+		{
+			name:           "expired exception",
+			exceptions:     fakeExceptions{exception("agent-tag", "cluster-agent-image-tag", "u-approver", now)},
+			user:           "u-requester",
+			response:       tagDenial,
+			wantCorrelated: true,
+		},
+		{
+			name:           "exception of another user",
+			exceptions:     fakeExceptions{exception("agent-tag", "cluster-agent-image-tag", "u-approver", now.Add(time.Hour))},
+			user:           "u-other",
+			response:       tagDenial,
+			wantCorrelated: true,
+		},
+		{
+			name:       "denial of a rule without exception",
+			exceptions: fakeExceptions{exception("agent-tag", "cluster-agent-image-tag", "u-approver", now.Add(time.Hour))},
+			user:       "u-requester",
+			response: ResponseDenied(DenialForbidden, "agent image tag isn't allowed (see /rules/cluster-agent-image-tag), "+
+				"agent image registry isn't allowed (see /rules/cluster-agent-image-registry)"),
+			wantCorrelated: true,
+		},
+		{
+			name:       "denial without a rule",
+			exceptions: fakeExceptions{exception("agent-tag", "cluster-agent-image-tag", "u-approver", now.Add(time.Hour))},
+			user:       "u-requester",
+			response:   ResponseDenied(DenialBadRequest, "spec.displayName: Required value"),
+		},
+		{
+			name:     "exceptions not configured",
+			user:     "u-requester",
+			response: tagDenial,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			honorer := &exceptionHonorer{exceptions: test.exceptions, now: func() time.Time { return now }}
+			request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UID:      "uid-1",
+				Resource: metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "clusters"},
+				Name:     "c-m-abc12",
+				UserInfo: authenticationv1.UserInfo{Username: test.user},
+			}}
+
+			excepted := honorer.except(request, test.response)
+			if test.wantWarnings == nil {
+				assert.Nil(t, excepted)
+			} else {
+				require.NotNil(t, excepted)
+				assert.True(t, excepted.Allowed)
+				assert.Equal(t, test.wantWarnings, excepted.Warnings)
+			}
+
+			warning := honorer.correlationWarning(request, test.response)
+			if test.wantCorrelated {
+				assert.Equal(t, "the correlation ID of this denial is uid-1, give it to a PolicyExceptionRequest to request an exception", warning)
+			} else if test.wantWarnings == nil {
+				assert.Empty(t, warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// DecisionExportSecretNamespace and DecisionExportSecretName identify the secret configuring the export of
+	// admission decisions. Decisions are only exported while the secret exists.
+	DecisionExportSecretNamespace = "cattle-system"
+	DecisionExportSecretName      = "rancher-webhook-decision-export"
+
+	// DefaultDecisionIndex is the index decisions are exported to when the secret has no index.
+	DefaultDecisionIndex = "rancher-webhook-decisions"
+	// DefaultDecisionBatchSize is the number of decisions exported in one bulk request when the secret has no batchSize.
+	DefaultDecisionBatchSize = 500
+	// DefaultDecisionFlushInterval is how often decisions are exported when the secret has no flushInterval.
+	DefaultDecisionFlushInterval = 5 * time.Second
+
+	// decisionQueueSize is the number of decisions waiting to be exported after which new decisions are dropped, so
+	// that a slow or unavailable endpoint never slows admission down.
+	decisionQueueSize = 10000
+	// decisionExportAttempts is the number of times a batch is sent before it is dropped.
+	decisionExportAttempts = 3
+	decisionExportTimeout  = 10 * time.Second
+)
+
+// errPartialBulk is returned when some documents of a bulk request weren't indexed. The request isn't retried, since
+// that would duplicate the indexed ones.
+var errPartialBulk = errors.New("some decisions weren't indexed")
+
+var exportedDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rancher_webhook",
+	Name:      "exported_decisions_total",
+	Help:      "Number of admission decisions handled by the exporter, by result: sent, dropped because the queue was full, or failed to be sent.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(exportedDecisions)
+}
+
+// DecisionExport is the configuration of the export of admission decisions to an OpenSearch or Elasticsearch
+// endpoint, read from the keys of the decision export secret.
+type DecisionExport struct {
+	// URL is the base URL of the endpoint, decisions are sent to its _bulk API. Required.
+	URL string
+	// Index is the index decisions are written to.
+	Index string
+	// Username and Password are the basic auth credentials of the endpoint, if any.
+	Username string
+	Password string
+	// CABundle are the PEM encoded certificates trusted for the endpoint, in addition to the system ones.
+	CABundle []byte
+	// DenialsOnly skips the export of allowed requests.
+	DenialsOnly bool
+	// BatchSize is the largest number of decisions sent in one request.
+	BatchSize int
+	// FlushInterval is the longest time a decision waits before being sent.
+	FlushInterval time.Duration
+}
+
+// ParseDecisionExport parses the data of the decision export secret. Its keys are url, index, username, password,
+// caBundle, denialsOnly, batchSize and flushInterval, only url is required.
+func ParseDecisionExport(data map[string][]byte) (DecisionExport, error) {
+	export := DecisionExport{
+		URL:           strings.TrimSpace(string(data["url"])),
+		Index:         strings.TrimSpace(string(data["index"])),
+		Username:      string(data["username"]),
+		Password:      string(data["password"]),
+		CABundle:      data["caBundle"],
+		BatchSize:     DefaultDecisionBatchSize,
+		FlushInterval: DefaultDecisionFlushInterval,
+	}
+	endpoint, err := url.Parse(export.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return export, fmt.Errorf("url %q must be an http or https URL", export.URL)
+	}
+	if export.Index == "" {
+		export.Index = DefaultDecisionIndex
+	}
+	if value := strings.TrimSpace(string(data["denialsOnly"])); value != "" {
+		if export.DenialsOnly, err = strconv.ParseBool(value); err != nil {
+			return export, fmt.Errorf("invalid denialsOnly %q: %w", value, err)
+		}
+	}
+	if value := strings.TrimSpace(string(data["batchSize"])); value != "" {
+		if export.BatchSize, err = strconv.Atoi(value); err != nil || export.BatchSize < 1 || export.BatchSize > decisionQueueSize {
+			return export, fmt.Errorf("batchSize %q must be between 1 and %d", value, decisionQueueSize)
+		}
+	}
+	if value := strings.TrimSpace(string(data["flushInterval"])); value != "" {
+		if export.FlushInterval, err = time.ParseDuration(value); err != nil || export.FlushInterval < time.Second {
+			return export, fmt.Errorf("flushInterval %q must be a duration of at least 1s", value)
+		}
+	}
+	if len(export.CABundle) != 0 && !x509.NewCertPool().AppendCertsFromPEM(export.CABundle) {
+		return export, fmt.Errorf("caBundle has no valid PEM encoded certificates")
+	}
+	return export, nil
+}
+
+// Decision is the record of an admission decision exported as a document.
+type Decision struct {
+	Timestamp time.Time `json:"@timestamp"`
+	UID       string    `json:"uid"`
+	// Webhook is the type of the webhook which made the decision, validating or mutating.
+	Webhook   string   `json:"webhook"`
+	Operation string   `json:"operation"`
+	Resource  string   `json:"resource"`
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	User      string   `json:"user"`
+	Groups    []string `json:"groups,omitempty"`
+	DryRun    bool     `json:"dryRun,omitempty"`
+	Allowed   bool     `json:"allowed"`
+	Patched   bool     `json:"patched,omitempty"`
+	Code      int32    `json:"code,omitempty"`
+	Reason    string   `json:"reason,omitempty"`
+	Message   string   `json:"message,omitempty"`
+	// Rule is the ID of the rule which denied the request, if the denial links to it.
+	Rule     string   `json:"rule,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Error is the error the webhook failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// decisionExporter batches admission decisions and sends them to the endpoint of the decision export secret.
+type decisionExporter struct {
+	mutex  sync.Mutex
+	export *DecisionExport
+	client *http.Client
+	queue  chan Decision
+	now    func() time.Time
+	// retryDelay is the delay before the first retry of a batch, doubled for each further retry.
+	retryDelay time.Duration
+}
+
+var decisions = newDecisionExporter()
+
+func newDecisionExporter() *decisionExporter {
+	return &decisionExporter{
+		queue:      make(chan Decision, decisionQueueSize),
+		now:        time.Now,
+		retryDelay: time.Second,
+	}
+}
+
+// SyncDecisionExport is a secret handler configuring the export of admission decisions from the decision export
+// secret. Decisions stop being exported when the secret is removed or invalid.
+func SyncDecisionExport(key string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if key != DecisionExportSecretNamespace+"/"+DecisionExportSecretName {
+		return secret, nil
+	}
+	if secret == nil || secret.DeletionTimestamp != nil {
+		decisions.configure(nil)
+		return secret, nil
+	}
+	export, err := ParseDecisionExport(secret.Data)
+	if err != nil {
+		logrus.Errorf("[decision-export] invalid secret %s, decisions aren't exported: %v", key, err)
+		decisions.configure(nil)
+		return secret, nil
+	}
+	decisions.configure(&export)
+	return secret, nil
+}
+
+// ExportDecisions sends the admission decisions to the configured endpoint until the context is done.
+func ExportDecisions(ctx context.Context) {
+	decisions.run(ctx)
+}
+
+// configure replaces the export configuration, nil disables the export.
+func (e *decisionExporter) configure(export *DecisionExport) {
+	var client *http.Client
+	if export != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if len(export.CABundle) != 0 {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			pool.AppendCertsFromPEM(export.CABundle)
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		}
+		client = &http.Client{Transport: transport, Timeout: decisionExportTimeout}
+		logrus.Infof("[decision-export] exporting decisions to index %s of %s", export.Index, export.URL)
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if export == nil && e.export != nil {
+		logrus.Info("[decision-export] stopped exporting decisions")
+	}
+	e.export = export
+	e.client = client
+}
+
+// current returns the export configuration and its client, a nil configuration if decisions aren't exported.
+func (e *decisionExporter) current() (*DecisionExport, *http.Client) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.export, e.client
+}
+
+// record queues the decision of the webhook on the request, unless decisions aren't exported. The decision is dropped
+// if the queue is full.
+func (e *decisionExporter) record(webhook string, request *Request, response *admissionv1.AdmissionResponse, err error) {
+	export, _ := e.current()
+	if export == nil || response == nil || (export.DenialsOnly && response.Allowed && err == nil && !wouldDeny(response)) {
+		return
+	}
+	decision := Decision{
+		Timestamp: e.now().UTC(),
+		UID:       string(request.UID),
+		Webhook:   webhook,
+		Operation: string(request.Operation),
+		Resource:  schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String(),
+		Namespace: request.Namespace,
+		Name:      request.Name,
+		User:      request.UserInfo.Username,
+		Groups:    request.UserInfo.Groups,
+		DryRun:    request.IsDryRun(),
+		Allowed:   response.Allowed && err == nil,
+		Patched:   len(response.Patch) != 0,
+		Warnings:  response.Warnings,
+	}
+	if response.Result != nil {
+		decision.Code = response.Result.Code
+		decision.Reason = string(response.Result.Reason)
+		decision.Message = response.Result.Message
+		if match := ruleIDPattern.FindStringSubmatch(decision.Message); match != nil {
+			decision.Rule = match[1]
+		}
+	}
+	if err != nil {
+		decision.Error = err.Error()
+	}
+	select {
+	case e.queue <- decision:
+	default:
+		exportedDecisions.WithLabelValues("dropped").Inc()
+	}
+}
+
+// run sends the queued decisions in batches until the context is done.
+func (e *decisionExporter) run(ctx context.Context) {
+	for {
+		batch := e.collect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if len(batch) != 0 {
+			e.send(ctx, batch)
+		}
+	}
+}
+
+// collect returns the decisions queued until the batch is full or the flush interval elapsed.
+func (e *decisionExporter) collect(ctx context.Context) []Decision {
+	batchSize, interval := DefaultDecisionBatchSize, DefaultDecisionFlushInterval
+	if export, _ := e.current(); export != nil {
+		batchSize, interval = export.BatchSize, export.FlushInterval
+	}
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	var batch []Decision
+	for len(batch) < batchSize {
+		select {
+		case <-ctx.Done():
+			return batch
+		case <-timer.C:
+			return batch
+		case decision := <-e.queue:
+			batch = append(batch, decision)
+		}
+	}
+	return batch
+}
+
+// send exports the batch, retrying with a backoff before dropping it. Batches are dropped if the export was disabled
+// since they were queued.
+func (e *decisionExporter) send(ctx context.Context, batch []Decision) {
+	export, client := e.current()
+	if export == nil {
+		exportedDecisions.WithLabelValues("dropped").Add(float64(len(batch)))
+		return
+	}
+	body, err := bulkBody(export.Index, batch)
+	if err != nil {
+		logrus.Errorf("[decision-export] failed to encode %d decisions: %v", len(batch), err)
+		exportedDecisions.WithLabelValues("failed").Add(float64(len(batch)))
+		return
+	}
+	delay := e.retryDelay
+	for attempt := 1; ; attempt++ {
+		err = postBulk(ctx, client, export, body)
+		if err == nil {
+			exportedDecisions.WithLabelValues("sent").Add(float64(len(batch)))
+			return
+		}
+		if attempt == decisionExportAttempts || errors.Is(err, errPartialBulk) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	logrus.Warnf("[decision-export] failed to export %d decisions: %v", len(batch), err)
+	exportedDecisions.WithLabelValues("failed").Add(float64(len(batch)))
+}
+
+// bulkBody returns the newline delimited JSON body of a bulk request indexing the decisions.
+func bulkBody(index string, batch []Decision) ([]byte, error) {
+	action, err := json.Marshal(map[string]any{"index": map[string]string{"_index": index}})
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	for _, decision := range batch {
+		document, err := json.Marshal(decision)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(document)
+		body.WriteByte('\n')
+	}
+	return body.Bytes(), nil
+}
+
+// postBulk sends the body to the _bulk API of the endpoint. It fails if any document wasn't indexed.
+func postBulk(ctx context.Context, client *http.Client, export *DecisionExport, body []byte) error {
+	endpoint, err := url.JoinPath(export.URL, "_bulk")
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if export.Username != "" || export.Password != "" {
+		req.SetBasicAuth(export.Username, export.Password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %w", err)
+	}
+	if result.Errors {
+		return errPartialBulk
+	}
+	return nil
+}
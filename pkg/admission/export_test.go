@@ -0,0 +1,240 @@
+package admission
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParseDecisionExport(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    DecisionExport
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			data: map[string]string{"url": "https://opensearch.example.com:9200"},
+			want: DecisionExport{
+				URL:           "https://opensearch.example.com:9200",
+				Index:         DefaultDecisionIndex,
+				BatchSize:     DefaultDecisionBatchSize,
+				FlushInterval: DefaultDecisionFlushInterval,
+			},
+		},
+		{
+			name: "all keys",
+			data: map[string]string{
+				"url":           "http://elasticsearch:9200/",
+				"index":         "decisions",
+				"username":      "webhook",
+				"password":      "secret",
+				"denialsOnly":   "true",
+				"batchSize":     "100",
+				"flushInterval": "30s",
+			},
+			want: DecisionExport{
+				URL:           "http://elasticsearch:9200/",
+				Index:         "decisions",
+				Username:      "webhook",
+				Password:      "secret",
+				DenialsOnly:   true,
+				BatchSize:     100,
+				FlushInterval: 30 * time.Second,
+			},
+		},
+		{name: "missing url", data: map[string]string{}, wantErr: true},
+		{name: "url without a scheme", data: map[string]string{"url": "opensearch:9200"}, wantErr: true},
+		{name: "invalid denialsOnly", data: map[string]string{"url": "https://opensearch", "denialsOnly": "maybe"}, wantErr: true},
+		{name: "zero batchSize", data: map[string]string{"url": "https://opensearch", "batchSize": "0"}, wantErr: true},
+		{name: "short flushInterval", data: map[string]string{"url": "https://opensearch", "flushInterval": "10ms"}, wantErr: true},
+		{name: "invalid caBundle", data: map[string]string{"url": "https://opensearch", "caBundle": "not a certificate"}, wantErr: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			data := map[string][]byte{}
+			for key, value := range test.data {
+				data[key] = []byte(value)
+			}
+			export, err := ParseDecisionExport(data)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, export)
+		})
+	}
+}
+
+// bulkServer is an OpenSearch _bulk API answering with the given statuses, then with 200.
+type bulkServer struct {
+	mutex     sync.Mutex
+	statuses  []int
+	requests  int
+	documents []Decision
+}
+
+func (b *bulkServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.requests++
+	if user, password, _ := req.BasicAuth(); req.URL.Path != "/_bulk" || user != "webhook" || password != "secret" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if len(b.statuses) != 0 {
+		status := b.statuses[0]
+		b.statuses = b.statuses[1:]
+		rw.WriteHeader(status)
+		if status == http.StatusOK {
+			_, _ = rw.Write([]byte(`{"errors":true}`))
+		}
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for line := 0; scanner.Scan(); line++ {
+		if line%2 == 0 {
+			continue
+		}
+		var decision Decision
+		if err := json.Unmarshal(scanner.Bytes(), &decision); err == nil {
+			b.documents = append(b.documents, decision)
+		}
+	}
+	_, _ = rw.Write([]byte(`{"errors":false}`))
+}
+
+// received returns the number of requests and the indexed documents.
+func (b *bulkServer) received() (int, []Decision) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.requests, b.documents
+}
+
+func exportRequest(name string) *Request {
+	return &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:       types.UID("uid-" + name),
+		Operation: admissionv1.Create,
+		Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"},
+		Namespace: "local",
+		Name:      name,
+		UserInfo:  authenticationv1.UserInfo{Username: "u-a"},
+	}}
+}
+
+func TestDecisionExporter(t *testing.T) {
+	t.Parallel()
+	server := &bulkServer{statuses: []int{http.StatusServiceUnavailable}}
+	endpoint := httptest.NewServer(server)
+	defer endpoint.Close()
+
+	exporter := newDecisionExporter()
+	exporter.retryDelay = time.Millisecond
+	exporter.now = func() time.Time { return time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC) }
+	exporter.record(validatingWebhook, exportRequest("p-ignored"), ResponseAllowed(), nil)
+	assert.Empty(t, exporter.queue, "decisions aren't queued before the export is configured")
+
+	exporter.configure(&DecisionExport{
+		URL:           endpoint.URL,
+		Index:         DefaultDecisionIndex,
+		Username:      "webhook",
+		Password:      "secret",
+		DenialsOnly:   true,
+		BatchSize:     10,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	exporter.record(validatingWebhook, exportRequest("p-allowed"), ResponseAllowed(), nil)
+	exporter.record(validatingWebhook, exportRequest("p-denied"), ResponseBadRequest("quota exceeded (see /rules/project-used-quota)"), nil)
+	exporter.record(mutatingWebhook, exportRequest("p-failed"), &admissionv1.AdmissionResponse{}, assert.AnError)
+
+	// the batch is sent again after the endpoint failed
+	batch := exporter.collect(context.Background())
+	require.Len(t, batch, 2)
+	exporter.send(context.Background(), batch)
+	requests, documents := server.received()
+	assert.Equal(t, 2, requests)
+	require.Len(t, documents, 2)
+	assert.Equal(t, Decision{
+		Timestamp: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		UID:       "uid-p-denied",
+		Webhook:   validatingWebhook,
+		Operation: "CREATE",
+		Resource:  "projects.management.cattle.io",
+		Namespace: "local",
+		Name:      "p-denied",
+		User:      "u-a",
+		Code:      http.StatusBadRequest,
+		Reason:    string(metav1.StatusReasonBadRequest),
+		Message:   "quota exceeded (see /rules/project-used-quota)",
+		Rule:      "project-used-quota",
+	}, documents[0])
+	assert.Equal(t, "p-failed", documents[1].Name)
+	assert.Equal(t, assert.AnError.Error(), documents[1].Error)
+
+	// partially indexed batches aren't sent again
+	server.mutex.Lock()
+	server.statuses = []int{http.StatusOK}
+	server.mutex.Unlock()
+	exporter.send(context.Background(), batch)
+	requests, documents = server.received()
+	assert.Equal(t, 3, requests)
+	assert.Len(t, documents, 2)
+}
+
+func TestDecisionExporterQueueFull(t *testing.T) {
+	t.Parallel()
+	exporter := &decisionExporter{queue: make(chan Decision, 1), now: time.Now}
+	exporter.configure(&DecisionExport{URL: "https://opensearch", BatchSize: 1, FlushInterval: time.Second})
+	exporter.record(validatingWebhook, exportRequest("p-1"), ResponseAllowed(), nil)
+	exporter.record(validatingWebhook, exportRequest("p-2"), ResponseAllowed(), nil)
+	require.Len(t, exporter.queue, 1)
+	assert.Equal(t, "p-1", (<-exporter.queue).Name)
+}
+
+// TestSyncDecisionExport isn't parallel since it configures the exporter of the package.
+func TestSyncDecisionExport(t *testing.T) {
+	defer decisions.configure(nil)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: DecisionExportSecretNamespace, Name: DecisionExportSecretName},
+		Data:       map[string][]byte{"url": []byte("https://opensearch")},
+	}
+	key := DecisionExportSecretNamespace + "/" + DecisionExportSecretName
+
+	_, err := SyncDecisionExport("cattle-system/other", secret)
+	require.NoError(t, err)
+	export, _ := decisions.current()
+	assert.Nil(t, export)
+
+	_, err = SyncDecisionExport(key, secret)
+	require.NoError(t, err)
+	export, client := decisions.current()
+	require.NotNil(t, export)
+	assert.Equal(t, "https://opensearch", export.URL)
+	assert.NotNil(t, client)
+
+	_, err = SyncDecisionExport(key, nil)
+	require.NoError(t, err)
+	export, _ = decisions.current()
+	assert.Nil(t, export)
+}
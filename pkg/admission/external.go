@@ -0,0 +1,260 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// ExternalPoliciesEnvKey is the environment variable holding the JSON list of the external policies admission
+	// requests are forwarded to, see ExternalPolicy. Requests aren't forwarded if it's empty.
+	ExternalPoliciesEnvKey = "CATTLE_WEBHOOK_EXTERNAL_POLICIES"
+
+	// DefaultExternalPolicyTimeout bounds the requests to an external policy without a timeout.
+	DefaultExternalPolicyTimeout = 3 * time.Second
+	// maxExternalPolicyTimeout is the longest timeout of an external policy, the longest timeout of a webhook.
+	maxExternalPolicyTimeout = 30 * time.Second
+	// maxExternalPolicyResponseSize bounds the responses read from external policies.
+	maxExternalPolicyResponseSize = 3 << 20
+)
+
+var externalPolicyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rancher_webhook",
+	Name:      "external_policy_requests_total",
+	Help:      "Number of admission requests forwarded to each external policy, by result: allowed, denied, or error if the policy failed to answer.",
+}, []string{"policy", "result"})
+
+func init() {
+	prometheus.MustRegister(externalPolicyRequests)
+}
+
+// ExternalPolicy is a downstream admission webhook, e.g. OPA Gatekeeper, Kyverno or a custom HTTP service, which the
+// requests allowed by the built-in admitters of validating handlers are forwarded to.
+type ExternalPolicy struct {
+	// Name identifies the policy in denials, logs and metrics.
+	Name string `json:"name"`
+	// URL is the http or https URL the AdmissionReview of each request is posted to.
+	URL string `json:"url"`
+	// CABundle is the PEM encoded CA bundle verifying the certificate of an https URL. Empty uses the system roots.
+	CABundle string `json:"caBundle,omitempty"`
+	// Resources are the resources forwarded to the policy, in the resource.group form or the resource name alone for
+	// core resources. Empty forwards all the resources validated by the webhook.
+	Resources []string `json:"resources,omitempty"`
+	// Timeout bounds each request to the policy. Zero uses DefaultExternalPolicyTimeout.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// FailurePolicy is Fail to deny the requests the policy fails to answer, or Ignore to allow them. Empty is Fail.
+	FailurePolicy v1.FailurePolicyType `json:"failurePolicy,omitempty"`
+}
+
+// ExternalPoliciesFromEnv returns the external policies configured by ExternalPoliciesEnvKey.
+func ExternalPoliciesFromEnv() ([]ExternalPolicy, error) {
+	policies, err := ParseExternalPolicies(os.Getenv(ExternalPoliciesEnvKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", ExternalPoliciesEnvKey, err)
+	}
+	return policies, nil
+}
+
+// ParseExternalPolicies parses the JSON list of external policies, and sets the defaults of their unset fields.
+func ParseExternalPolicies(value string) ([]ExternalPolicy, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var policies []ExternalPolicy
+	if err := json.Unmarshal([]byte(value), &policies); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for i := range policies {
+		policy := &policies[i]
+		if policy.Name == "" {
+			return nil, fmt.Errorf("external policy %d has no name", i)
+		}
+		if names[policy.Name] {
+			return nil, fmt.Errorf("external policy %s is listed twice", policy.Name)
+		}
+		names[policy.Name] = true
+		endpoint, err := url.Parse(policy.URL)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return nil, fmt.Errorf("URL %q of external policy %s must be an http or https URL", policy.URL, policy.Name)
+		}
+		if policy.Timeout.Duration == 0 {
+			policy.Timeout.Duration = DefaultExternalPolicyTimeout
+		}
+		if policy.Timeout.Duration < 0 || policy.Timeout.Duration > maxExternalPolicyTimeout {
+			return nil, fmt.Errorf("timeout %s of external policy %s must be between 0 and %s", policy.Timeout.Duration, policy.Name, maxExternalPolicyTimeout)
+		}
+		switch policy.FailurePolicy {
+		case "":
+			policy.FailurePolicy = v1.Fail
+		case v1.Fail, v1.Ignore:
+		default:
+			return nil, fmt.Errorf("failure policy %q of external policy %s must be %s or %s", policy.FailurePolicy, policy.Name, v1.Fail, v1.Ignore)
+		}
+	}
+	return policies, nil
+}
+
+// externalPolicyChain forwards admission requests to the external policies, in order.
+type externalPolicyChain struct {
+	mutex     sync.RWMutex
+	endpoints []*externalEndpoint
+}
+
+// externalEndpoint is an external policy with its client.
+type externalEndpoint struct {
+	ExternalPolicy
+	client *http.Client
+}
+
+var externalPolicies = &externalPolicyChain{}
+
+// ConfigureExternalPolicies makes validating handlers forward the requests their admitters allow to the policies, in
+// order. Without policies, requests aren't forwarded.
+func ConfigureExternalPolicies(policies []ExternalPolicy) error {
+	endpoints := make([]*externalEndpoint, 0, len(policies))
+	for _, policy := range policies {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if policy.CABundle != "" {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(policy.CABundle)) {
+				return fmt.Errorf("CA bundle of external policy %s has no PEM certificate", policy.Name)
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		}
+		endpoints = append(endpoints, &externalEndpoint{
+			ExternalPolicy: policy,
+			client:         &http.Client{Transport: transport, Timeout: policy.Timeout.Duration},
+		})
+	}
+	externalPolicies.mutex.Lock()
+	defer externalPolicies.mutex.Unlock()
+	externalPolicies.endpoints = endpoints
+	return nil
+}
+
+// admitterFor returns the admitter forwarding the request to the policies of its resource, nil if there is none.
+func (c *externalPolicyChain) admitterFor(request *Request) Admitter {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	resource := schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String()
+	var endpoints []*externalEndpoint
+	for _, endpoint := range c.endpoints {
+		if len(endpoint.Resources) == 0 || slices.Contains(endpoint.Resources, resource) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+	return externalAdmitter(endpoints)
+}
+
+// externalAdmitter forwards requests to external policies.
+type externalAdmitter []*externalEndpoint
+
+// Admit forwards the request to each policy in order, and returns the first denial, or allows the request with the
+// warnings of all the policies. Requests a policy fails to answer are denied as retryable conflicts if its failure
+// policy is Fail, and skip it if it's Ignore. Patches of the policies are ignored, since the handlers only validate.
+func (a externalAdmitter) Admit(request *Request) (*admissionv1.AdmissionResponse, error) {
+	var warnings []string
+	for _, endpoint := range a {
+		response, err := endpoint.review(request)
+		if err != nil {
+			externalPolicyRequests.WithLabelValues(endpoint.Name, "error").Inc()
+			if endpoint.FailurePolicy == v1.Ignore {
+				logrus.Warnf("[external-policies] ignoring external policy %s for %s %s %s: %v", endpoint.Name, request.Operation, request.Kind.String(), resourceString(request.Namespace, request.Name), err)
+				continue
+			}
+			return ResponseDenied(DenialConflict, fmt.Sprintf("external policy %s failed to review the request: %v", endpoint.Name, err)), nil
+		}
+		warnings = append(warnings, response.Warnings...)
+		if !response.Allowed {
+			externalPolicyRequests.WithLabelValues(endpoint.Name, "denied").Inc()
+			denied := externalDenial(endpoint.Name, response.Result)
+			denied.Warnings = warnings
+			return denied, nil
+		}
+		externalPolicyRequests.WithLabelValues(endpoint.Name, "allowed").Inc()
+	}
+	allowed := ResponseAllowed()
+	allowed.Warnings = warnings
+	return allowed, nil
+}
+
+// review posts the AdmissionReview of the request to the policy, and returns its response.
+func (e *externalEndpoint) review(request *Request) (*admissionv1.AdmissionResponse, error) {
+	ctx := request.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout.Duration)
+	defer cancel()
+
+	body, err := json.Marshal(&admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Request:  &request.AdmissionRequest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the admission review: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("replied %s", resp.Status)
+	}
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxExternalPolicyResponseSize)).Decode(&review); err != nil {
+		return nil, fmt.Errorf("failed to decode the admission review: %w", err)
+	}
+	if review.Response == nil {
+		return nil, fmt.Errorf("admission review has no response")
+	}
+	if review.Response.UID != request.UID {
+		return nil, fmt.Errorf("admission review answers request %s instead of %s", review.Response.UID, request.UID)
+	}
+	return review.Response, nil
+}
+
+// externalDenial returns the denial of a policy, keeping the status reason and code of its result, and prefixing its
+// message with the name of the policy. Results without a code are reported as Forbidden.
+func externalDenial(name string, result *metav1.Status) *admissionv1.AdmissionResponse {
+	message := "denied the request"
+	if result != nil && result.Message != "" {
+		message = result.Message
+	}
+	message = fmt.Sprintf("external policy %s: %s", name, message)
+	if result == nil || result.Code == 0 {
+		return ResponseDenied(DenialForbidden, message)
+	}
+	status := result.DeepCopy()
+	status.Status = metav1.StatusFailure
+	status.Message = message
+	return &admissionv1.AdmissionResponse{Allowed: false, Result: status}
+}
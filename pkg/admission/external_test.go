@@ -0,0 +1,254 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseExternalPolicies(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		value   string
+		want    []ExternalPolicy
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "defaults",
+			value: `[{"name": "gatekeeper", "url": "https://gatekeeper-webhook-service.gatekeeper-system.svc/v1/admit"}]`,
+			want: []ExternalPolicy{{
+				Name:          "gatekeeper",
+				URL:           "https://gatekeeper-webhook-service.gatekeeper-system.svc/v1/admit",
+				Timeout:       metav1.Duration{Duration: DefaultExternalPolicyTimeout},
+				FailurePolicy: v1.Fail,
+			}},
+		},
+		{
+			name:  "all fields",
+			value: `[{"name": "kyverno", "url": "http://kyverno:8080/validate", "resources": ["clusters.management.cattle.io"], "timeout": "5s", "failurePolicy": "Ignore"}]`,
+			want: []ExternalPolicy{{
+				Name:          "kyverno",
+				URL:           "http://kyverno:8080/validate",
+				Resources:     []string{"clusters.management.cattle.io"},
+				Timeout:       metav1.Duration{Duration: 5 * time.Second},
+				FailurePolicy: v1.Ignore,
+			}},
+		},
+		{
+			name:    "invalid JSON",
+			value:   `{"name": "gatekeeper"}`,
+			wantErr: "cannot unmarshal",
+		},
+		{
+			name:    "missing name",
+			value:   `[{"url": "https://policy"}]`,
+			wantErr: "external policy 0 has no name",
+		},
+		{
+			name:    "duplicate name",
+			value:   `[{"name": "opa", "url": "https://opa"}, {"name": "opa", "url": "https://opa-2"}]`,
+			wantErr: "external policy opa is listed twice",
+		},
+		{
+			name:    "invalid URL",
+			value:   `[{"name": "opa", "url": "opa.svc:8443"}]`,
+			wantErr: "must be an http or https URL",
+		},
+		{
+			name:    "timeout too long",
+			value:   `[{"name": "opa", "url": "https://opa", "timeout": "1m"}]`,
+			wantErr: "timeout 1m0s of external policy opa must be between 0 and 30s",
+		},
+		{
+			name:    "invalid failure policy",
+			value:   `[{"name": "opa", "url": "https://opa", "failurePolicy": "Retry"}]`,
+			wantErr: `failure policy "Retry" of external policy opa must be Fail or Ignore`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			policies, err := ParseExternalPolicies(test.value)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, policies)
+		})
+	}
+}
+
+// externalPolicyServer returns a server answering admission reviews with the response returned by respond.
+func externalPolicyServer(t *testing.T, respond func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var review admissionv1.AdmissionReview
+		if err := json.NewDecoder(req.Body).Decode(&review); err != nil || review.Request == nil {
+			http.Error(rw, "invalid review", http.StatusBadRequest)
+			return
+		}
+		response := respond(review.Request)
+		if response == nil {
+			http.Error(rw, "policy engine unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		review.Response = response
+		_ = json.NewEncoder(rw).Encode(&review)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExternalAdmitter(t *testing.T) {
+	t.Parallel()
+	allow := func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		return &admissionv1.AdmissionResponse{UID: request.UID, Allowed: true, Warnings: []string{"allowed by policy"}}
+	}
+	deny := func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		return &admissionv1.AdmissionResponse{UID: request.UID, Result: &metav1.Status{
+			Message: "clusters must have a cost-center label",
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+		}}
+	}
+	denyWithoutStatus := func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		return &admissionv1.AdmissionResponse{UID: request.UID}
+	}
+	fail := func(_ *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse { return nil }
+	wrongUID := func(_ *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		return &admissionv1.AdmissionResponse{UID: "other", Allowed: true}
+	}
+	slow := func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		time.Sleep(200 * time.Millisecond)
+		return allow(request)
+	}
+	type policy struct {
+		respond       func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+		failurePolicy v1.FailurePolicyType
+	}
+
+	tests := []struct {
+		name         string
+		policies     []policy
+		wantAllowed  bool
+		wantMessage  string
+		wantCode     int32
+		wantWarnings []string
+	}{
+		{
+			name:         "allowed by all policies",
+			policies:     []policy{{respond: allow}, {respond: allow}},
+			wantAllowed:  true,
+			wantWarnings: []string{"allowed by policy", "allowed by policy"},
+		},
+		{
+			name:         "denied by the second policy",
+			policies:     []policy{{respond: allow}, {respond: deny}, {respond: fail}},
+			wantMessage:  "external policy policy-1: clusters must have a cost-center label",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantWarnings: []string{"allowed by policy"},
+		},
+		{
+			name:        "denied without a status",
+			policies:    []policy{{respond: denyWithoutStatus}},
+			wantMessage: "external policy policy-0: denied the request",
+			wantCode:    http.StatusForbidden,
+		},
+		{
+			name:        "failing policy with the Fail failure policy",
+			policies:    []policy{{respond: fail, failurePolicy: v1.Fail}},
+			wantMessage: "external policy policy-0 failed to review the request: replied 503 Service Unavailable",
+			wantCode:    http.StatusConflict,
+		},
+		{
+			name:         "failing policy with the Ignore failure policy",
+			policies:     []policy{{respond: fail, failurePolicy: v1.Ignore}, {respond: allow}},
+			wantAllowed:  true,
+			wantWarnings: []string{"allowed by policy"},
+		},
+		{
+			name:        "response to another request",
+			policies:    []policy{{respond: wrongUID, failurePolicy: v1.Fail}},
+			wantMessage: "admission review answers request other instead of uid-1",
+		},
+		{
+			name:        "timeout",
+			policies:    []policy{{respond: slow, failurePolicy: v1.Fail}},
+			wantMessage: "external policy policy-0 failed to review the request",
+			wantCode:    http.StatusConflict,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var admitter externalAdmitter
+			for i, policy := range test.policies {
+				server := externalPolicyServer(t, policy.respond)
+				failurePolicy := policy.failurePolicy
+				if failurePolicy == "" {
+					failurePolicy = v1.Fail
+				}
+				admitter = append(admitter, &externalEndpoint{
+					ExternalPolicy: ExternalPolicy{
+						Name:          "policy-" + strconv.Itoa(i),
+						URL:           server.URL,
+						Timeout:       metav1.Duration{Duration: 100 * time.Millisecond},
+						FailurePolicy: failurePolicy,
+					},
+					client: server.Client(),
+				})
+			}
+			request := &Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{UID: "uid-1", Operation: admissionv1.Create, Name: "c-m-abc12"},
+				Context:          context.Background(),
+			}
+
+			response, err := admitter.Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+			assert.Equal(t, test.wantWarnings, response.Warnings)
+			if test.wantAllowed {
+				return
+			}
+			require.NotNil(t, response.Result)
+			assert.Contains(t, response.Result.Message, test.wantMessage)
+			if test.wantCode != 0 {
+				assert.Equal(t, test.wantCode, response.Result.Code)
+			}
+		})
+	}
+}
+
+func TestExternalPolicyChainAdmitterFor(t *testing.T) {
+	t.Parallel()
+	all := &externalEndpoint{ExternalPolicy: ExternalPolicy{Name: "all"}}
+	clusters := &externalEndpoint{ExternalPolicy: ExternalPolicy{Name: "clusters", Resources: []string{"clusters.management.cattle.io"}}}
+	secrets := &externalEndpoint{ExternalPolicy: ExternalPolicy{Name: "secrets", Resources: []string{"secrets"}}}
+	chain := &externalPolicyChain{endpoints: []*externalEndpoint{clusters, all, secrets}}
+
+	request := func(group, resource string) *Request {
+		return &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Resource: metav1.GroupVersionResource{Group: group, Resource: resource},
+		}}
+	}
+	assert.Equal(t, externalAdmitter{clusters, all}, chain.admitterFor(request("management.cattle.io", "clusters")))
+	assert.Equal(t, externalAdmitter{all, secrets}, chain.admitterFor(request("", "secrets")))
+	assert.Equal(t, externalAdmitter{all}, chain.admitterFor(request("management.cattle.io", "projects")))
+	assert.Nil(t, (&externalPolicyChain{}).admitterFor(request("", "secrets")))
+}
@@ -0,0 +1,59 @@
+package admission
+
+import (
+	"reflect"
+
+	"github.com/rancher/webhook/pkg/faults"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// lookupKey identifies an object looked up while admitting a request.
+type lookupKey struct {
+	Type      reflect.Type
+	Namespace string
+	Name      string
+}
+
+// lookupResult is the memoized result of a lookup.
+type lookupResult struct {
+	obj any
+	err error
+}
+
+// Lookup returns the object with the given name from the cache. The result, including errors such as the object not
+// being found, is memoized for the rest of the request, so that admitters following the same references (e.g. project
+// to cluster to setting) hit the cache once per object.
+func Lookup[T runtime.Object](request *Request, cache generic.NonNamespacedCacheInterface[T], name string) (T, error) {
+	return lookup(request, "", name, func() (T, error) { return cache.Get(name) })
+}
+
+// LookupNamespaced returns the object with the given namespace and name from the cache, memoizing the result for the
+// rest of the request like Lookup.
+func LookupNamespaced[T runtime.Object](request *Request, cache generic.CacheInterface[T], namespace, name string) (T, error) {
+	return lookup(request, namespace, name, func() (T, error) { return cache.Get(namespace, name) })
+}
+
+// lookup returns the memoized result of get for the object, calling get on the first lookup. Lookups aren't safe for
+// concurrent use, the admitters of a request run sequentially. The result isn't memoized without a request.
+func lookup[T runtime.Object](request *Request, namespace, name string, get func() (T, error)) (T, error) {
+	if request == nil {
+		return get()
+	}
+	if err := faults.CacheError(schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String()); err != nil {
+		var zero T
+		return zero, err
+	}
+	key := lookupKey{Type: reflect.TypeFor[T](), Namespace: namespace, Name: name}
+	if result, ok := request.lookups[key]; ok {
+		obj, _ := result.obj.(T)
+		return obj, result.err
+	}
+	obj, err := get()
+	if request.lookups == nil {
+		request.lookups = map[lookupKey]lookupResult{}
+	}
+	request.lookups[key] = lookupResult{obj: obj, err: err}
+	return obj, err
+}
@@ -0,0 +1,51 @@
+package admission
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+	settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl)
+	projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+	notFound := apierrors.NewNotFound(v3.Resource("clusters"), "c-missing")
+
+	// each object is only looked up once per request, including the ones that aren't found
+	clusterCache.EXPECT().Get("c-1").Return(&v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-1"}}, nil).Times(2)
+	clusterCache.EXPECT().Get("c-missing").Return(nil, notFound)
+	settingCache.EXPECT().Get("c-1").Return(&v3.Setting{ObjectMeta: metav1.ObjectMeta{Name: "c-1"}}, nil)
+	projectCache.EXPECT().Get("c-1", "p-1").Return(&v3.Project{ObjectMeta: metav1.ObjectMeta{Name: "p-1", Namespace: "c-1"}}, nil)
+
+	request := &Request{}
+	for i := 0; i < 2; i++ {
+		cluster, err := Lookup(request, clusterCache, "c-1")
+		require.NoError(t, err)
+		assert.Equal(t, "c-1", cluster.Name)
+
+		_, err = Lookup(request, clusterCache, "c-missing")
+		assert.True(t, apierrors.IsNotFound(err))
+
+		// objects of different types with the same name are distinct
+		setting, err := Lookup(request, settingCache, "c-1")
+		require.NoError(t, err)
+		assert.Equal(t, "c-1", setting.Name)
+
+		project, err := LookupNamespaced(request, projectCache, "c-1", "p-1")
+		require.NoError(t, err)
+		assert.Equal(t, "p-1", project.Name)
+	}
+
+	// lookups aren't shared between requests
+	_, err := Lookup(&Request{}, clusterCache, "c-1")
+	require.NoError(t, err)
+}
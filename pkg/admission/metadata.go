@@ -0,0 +1,47 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// requestMetadata is the memoized metadata of the objects of a request.
+type requestMetadata struct {
+	oldObj, newObj *metav1.PartialObjectMetadata
+}
+
+// MetadataFromRequest returns the metadata of the old and new objects of the request, decoding only their type and
+// object metadata instead of the full typed objects. Rules that only inspect metadata, like the immutability of
+// annotations and labels, should use it, since most updates made by controllers are to the spec or status of large
+// objects. The result is memoized for the rest of the request, so the admitters shared by all validators decode the
+// metadata once. Objects missing from the request, like the old object on create, have empty metadata.
+func MetadataFromRequest(request *Request) (oldObj, newObj *metav1.PartialObjectMetadata, err error) {
+	if request.metadata != nil {
+		return request.metadata.oldObj, request.metadata.newObj, nil
+	}
+	oldObj, err = decodeMetadata(request.OldObject.Raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode the metadata of the old object: %w", err)
+	}
+	newObj, err = decodeMetadata(request.Object.Raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode the metadata of the object: %w", err)
+	}
+	request.metadata = &requestMetadata{oldObj: oldObj, newObj: newObj}
+	return oldObj, newObj, nil
+}
+
+// decodeMetadata decodes the type and object metadata of a raw object. The other fields are skipped by the decoder
+// without being allocated.
+func decodeMetadata(raw []byte) (*metav1.PartialObjectMetadata, error) {
+	obj := &metav1.PartialObjectMetadata{}
+	if len(raw) == 0 {
+		return obj, nil
+	}
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,48 @@
+package admission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMetadataFromRequest(t *testing.T) {
+	t.Parallel()
+	request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		OldObject: runtime.RawExtension{Raw: []byte(`{"kind":"Cluster","metadata":{"name":"c-1","annotations":{"field.cattle.io/creatorId":"u-1"}},"spec":{"displayName":"old"}}`)},
+		// fields that don't decode into the typed object don't matter, only the metadata is decoded
+		Object: runtime.RawExtension{Raw: []byte(`{"kind":"Cluster","metadata":{"name":"c-1","labels":{"env":"prod"}},"spec":{"displayName":1},"status":[]}`)},
+	}}
+
+	oldObj, newObj, err := MetadataFromRequest(request)
+	require.NoError(t, err)
+	assert.Equal(t, "Cluster", oldObj.Kind)
+	assert.Equal(t, map[string]string{"field.cattle.io/creatorId": "u-1"}, oldObj.Annotations)
+	assert.Equal(t, "c-1", newObj.Name)
+	assert.Equal(t, map[string]string{"env": "prod"}, newObj.Labels)
+
+	// the metadata is memoized for the rest of the request
+	request.Object.Raw = []byte(`not json`)
+	_, memoized, err := MetadataFromRequest(request)
+	require.NoError(t, err)
+	assert.Same(t, newObj, memoized)
+
+	created := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"c-2"}}`)},
+	}}
+	oldObj, newObj, err = MetadataFromRequest(created)
+	require.NoError(t, err)
+	assert.Empty(t, oldObj.Name)
+	assert.Equal(t, "c-2", newObj.Name)
+
+	invalid := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Object: runtime.RawExtension{Raw: []byte(`{"metadata":{"name":1}}`)},
+	}}
+	_, _, err = MetadataFromRequest(invalid)
+	assert.Error(t, err)
+}
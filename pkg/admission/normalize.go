@@ -0,0 +1,417 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// NormalizationOff leaves objects as they are sent. This is the default.
+	NormalizationOff = "off"
+	// NormalizationValidate rewrites the fields of the old objects the validating admitters review to the values of the
+	// objects when they only differ in how they are written, so that immutability checks compare them normalized. The
+	// objects the admitters review are the ones stored, and aren't changed.
+	NormalizationValidate = "validate"
+	// NormalizationPersist also normalizes the objects the mutating admitters review, and adds the normalization to
+	// their patch so that the normalized objects are stored.
+	NormalizationPersist = "persist"
+)
+
+const (
+	normalizationOff int32 = iota
+	normalizationValidate
+	normalizationPersist
+)
+
+// normalizationMode is how objects are normalized, see SetNormalization.
+var normalizationMode atomic.Int32
+
+// SetNormalization sets how the objects of requests are normalized before they are admitted: NormalizationOff, the
+// default if mode is empty, NormalizationValidate or NormalizationPersist.
+func SetNormalization(mode string) error {
+	switch mode {
+	case "", NormalizationOff:
+		normalizationMode.Store(normalizationOff)
+	case NormalizationValidate:
+		normalizationMode.Store(normalizationValidate)
+	case NormalizationPersist:
+		normalizationMode.Store(normalizationPersist)
+	default:
+		return fmt.Errorf("unknown normalization %q, must be %q, %q or %q", mode, NormalizationOff, NormalizationValidate, NormalizationPersist)
+	}
+	return nil
+}
+
+// Normalization declares the fields of a resource whose semantically identical values can be written differently.
+// Fields are given by their JSON path, e.g. spec.displayName, with map entries in brackets, e.g.
+// metadata.annotations[field.cattle.io/creatorId]. Lists along a path are normalized in all their elements.
+type Normalization struct {
+	// Trimmed are the string fields whose leading and trailing whitespace is removed.
+	Trimmed []string
+	// Hostnames are the string fields holding hostnames, which are trimmed and lowercased.
+	Hostnames []string
+	// Unordered are the list fields whose order doesn't matter, which are sorted.
+	Unordered []string
+}
+
+// normalizations are the normalizations of the resources, by resource.
+var normalizations = map[schema.GroupVersionResource]Normalization{
+	{Group: "management.cattle.io", Version: "v3", Resource: "clusters"}: {
+		Trimmed:   []string{"spec.displayName", "metadata.annotations[field.cattle.io/creatorId]"},
+		Hostnames: []string{"spec.localClusterAuthEndpoint.fqdn"},
+	},
+	{Group: "management.cattle.io", Version: "v3", Resource: "projects"}: {
+		Trimmed: []string{"spec.displayName", "spec.clusterName", "metadata.annotations[field.cattle.io/creatorId]"},
+	},
+	{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"}: {
+		Trimmed:   []string{"displayName"},
+		Unordered: []string{"inheritedClusterRoles"},
+	},
+	{Group: "management.cattle.io", Version: "v3", Resource: "globalrolebindings"}: {
+		Trimmed: []string{"userName", "groupPrincipalName", "globalRoleName"},
+	},
+	{Group: "management.cattle.io", Version: "v3", Resource: "roletemplates"}: {
+		Trimmed:   []string{"displayName"},
+		Unordered: []string{"roleTemplateNames"},
+	},
+	{Group: "management.cattle.io", Version: "v3", Resource: "clusterroletemplatebindings"}: {
+		Trimmed: []string{"userName", "groupPrincipalName", "roleTemplateName", "clusterName"},
+	},
+	{Group: "management.cattle.io", Version: "v3", Resource: "projectroletemplatebindings"}: {
+		Trimmed: []string{"userName", "groupPrincipalName", "roleTemplateName", "projectName"},
+	},
+	{Group: "provisioning.cattle.io", Version: "v1", Resource: "clusters"}: {
+		Trimmed:   []string{"metadata.annotations[field.cattle.io/creatorId]"},
+		Hostnames: []string{"spec.localClusterAuthEndpoint.fqdn"},
+	},
+}
+
+// normalizeValidatedRequest aligns the old object of a request reviewed by validating admitters with its object,
+// unless normalization is off. Only the old object is changed: the validators must review the object that is stored,
+// which is only normalized when a mutating admitter persisted its normalization.
+func normalizeValidatedRequest(request *Request) {
+	if normalizationMode.Load() == normalizationOff || request.SubResource != "" || len(request.OldObject.Raw) == 0 {
+		return
+	}
+	normalization, ok := normalizations[schema.GroupVersionResource(request.Resource)]
+	if !ok {
+		return
+	}
+	raw, fields := normalization.align(request.OldObject.Raw, request.Object.Raw)
+	if len(fields) == 0 {
+		return
+	}
+	request.OldObject.Raw = raw
+	request.OldObject.Object = nil
+	logrus.Debugf("[normalization] aligned fields %s of the old object of %s %s", strings.Join(fields, ", "), request.Kind.Kind, resourceString(request.Namespace, request.Name))
+}
+
+// normalizeMutatedRequest normalizes the objects of a request reviewed by a mutating admitter when normalization is
+// persisted, and returns the JSON patch normalizing the object as it was sent, nil if there is nothing to persist.
+// The objects aren't normalized otherwise, since the patch of the admitter must apply to the object as it was sent.
+func normalizeMutatedRequest(request *Request) []byte {
+	if normalizationMode.Load() != normalizationPersist {
+		return nil
+	}
+	original := request.Object.Raw
+	if !normalizeRequest(request) {
+		return nil
+	}
+	operations, err := jsonpatch.CreatePatch(original, request.Object.Raw)
+	if err != nil || len(operations) == 0 {
+		if err != nil {
+			logrus.Debugf("[normalization] failed to create the patch normalizing %s %s: %v", request.Kind.Kind, resourceString(request.Namespace, request.Name), err)
+		}
+		return nil
+	}
+	patch, err := json.Marshal(operations)
+	if err != nil {
+		return nil
+	}
+	return patch
+}
+
+// normalizeRequest replaces the object and old object of the request with their normalization, if the resource of the
+// request has one. Objects that don't decode are left as they are for the admitters to fail on. It returns true if the
+// object was changed.
+func normalizeRequest(request *Request) bool {
+	if request.SubResource != "" {
+		return false
+	}
+	normalization, ok := normalizations[schema.GroupVersionResource(request.Resource)]
+	if !ok {
+		return false
+	}
+	if raw, fields := normalization.normalize(request.OldObject.Raw); len(fields) != 0 {
+		request.OldObject.Raw = raw
+		request.OldObject.Object = nil
+	}
+	raw, fields := normalization.normalize(request.Object.Raw)
+	if len(fields) == 0 {
+		return false
+	}
+	request.Object.Raw = raw
+	request.Object.Object = nil
+	logrus.Debugf("[normalization] normalized fields %s of %s %s", strings.Join(fields, ", "), request.Kind.Kind, resourceString(request.Namespace, request.Name))
+	return true
+}
+
+// normalize returns the normalization of the raw JSON object, and the paths of the fields it changed. The object is
+// returned as it is if nothing changed or if it doesn't decode.
+func (n Normalization) normalize(raw []byte) ([]byte, []string) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	var content map[string]any
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return raw, nil
+	}
+	var fields []string
+	apply := func(paths []string, normalize func(any) (any, bool)) {
+		for _, path := range paths {
+			if updated, changed := normalizeField(content, splitNormalizationPath(path), normalize); changed {
+				content = updated.(map[string]any)
+				fields = append(fields, path)
+			}
+		}
+	}
+	apply(n.Trimmed, trimString)
+	apply(n.Hostnames, lowerHostname)
+	apply(n.Unordered, sortList)
+	if len(fields) == 0 {
+		return raw, nil
+	}
+	normalized, err := json.Marshal(content)
+	if err != nil {
+		return raw, nil
+	}
+	return normalized, fields
+}
+
+// align returns the raw JSON old object with the fields whose normalization is the same as in the raw JSON object set
+// to their value in the object, and the paths of the fields it changed. The old object is returned as it is if nothing
+// changed or if either object doesn't decode.
+func (n Normalization) align(oldRaw, raw []byte) ([]byte, []string) {
+	var oldContent, content map[string]any
+	if err := json.Unmarshal(oldRaw, &oldContent); err != nil {
+		return oldRaw, nil
+	}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return oldRaw, nil
+	}
+	var fields []string
+	apply := func(paths []string, normalize func(any) (any, bool)) {
+		for _, path := range paths {
+			if updated, changed := alignField(oldContent, content, splitNormalizationPath(path), normalize); changed {
+				oldContent = updated.(map[string]any)
+				fields = append(fields, path)
+			}
+		}
+	}
+	apply(n.Trimmed, trimString)
+	apply(n.Hostnames, lowerHostname)
+	apply(n.Unordered, sortList)
+	if len(fields) == 0 {
+		return oldRaw, nil
+	}
+	aligned, err := json.Marshal(oldContent)
+	if err != nil {
+		return oldRaw, nil
+	}
+	return aligned, fields
+}
+
+// alignField replaces the values at the path of the decoded JSON old value by the ones of the decoded JSON value when
+// they only differ before normalize, and returns the updated old value and whether anything changed. Lists along the
+// path are aligned element by element when they have the same length.
+func alignField(oldValue, value any, path []string, normalize func(any) (any, bool)) (any, bool) {
+	if len(path) == 0 {
+		if reflect.DeepEqual(oldValue, value) {
+			return oldValue, false
+		}
+		normalizedOld, _ := normalize(oldValue)
+		normalized, _ := normalize(value)
+		if !reflect.DeepEqual(normalizedOld, normalized) {
+			return oldValue, false
+		}
+		return value, true
+	}
+	switch old := oldValue.(type) {
+	case map[string]any:
+		current, ok := value.(map[string]any)
+		if !ok {
+			return oldValue, false
+		}
+		oldChild, oldOK := old[path[0]]
+		child, ok := current[path[0]]
+		if !oldOK || !ok {
+			return oldValue, false
+		}
+		updated, changed := alignField(oldChild, child, path[1:], normalize)
+		if changed {
+			old[path[0]] = updated
+		}
+		return old, changed
+	case []any:
+		current, ok := value.([]any)
+		if !ok || len(current) != len(old) {
+			return oldValue, false
+		}
+		changed := false
+		for i := range old {
+			updated, elementChanged := alignField(old[i], current[i], path, normalize)
+			if elementChanged {
+				old[i] = updated
+				changed = true
+			}
+		}
+		return old, changed
+	}
+	return oldValue, false
+}
+
+// normalizeField applies normalize to the values at the path of the decoded JSON value, and returns the updated
+// value and whether anything changed.
+func normalizeField(value any, path []string, normalize func(any) (any, bool)) (any, bool) {
+	if len(path) == 0 {
+		return normalize(value)
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		child, ok := v[path[0]]
+		if !ok {
+			return value, false
+		}
+		updated, changed := normalizeField(child, path[1:], normalize)
+		if changed {
+			v[path[0]] = updated
+		}
+		return v, changed
+	case []any:
+		changed := false
+		for i, element := range v {
+			updated, elementChanged := normalizeField(element, path, normalize)
+			if elementChanged {
+				v[i] = updated
+				changed = true
+			}
+		}
+		return v, changed
+	}
+	return value, false
+}
+
+// splitNormalizationPath splits a field path into its keys, e.g. metadata.annotations[cattle.io/name] into metadata,
+// annotations and cattle.io/name.
+func splitNormalizationPath(path string) []string {
+	var keys []string
+	for path != "" {
+		switch {
+		case path[0] == '.':
+			path = path[1:]
+		case path[0] == '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return append(keys, path[1:])
+			}
+			keys = append(keys, path[1:end])
+			path = path[end+1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			keys = append(keys, path[:end])
+			path = path[end:]
+		}
+	}
+	return keys
+}
+
+// trimString removes the leading and trailing whitespace of string values.
+func trimString(value any) (any, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return value, false
+	}
+	trimmed := strings.TrimSpace(s)
+	return trimmed, trimmed != s
+}
+
+// lowerHostname trims and lowercases hostname values.
+func lowerHostname(value any) (any, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return value, false
+	}
+	lowered := strings.ToLower(strings.TrimSpace(s))
+	return lowered, lowered != s
+}
+
+// sortList sorts list values, by the elements for lists of strings, by the JSON encoding of the elements otherwise.
+func sortList(value any) (any, bool) {
+	list, ok := value.([]any)
+	if !ok || len(list) < 2 {
+		return value, false
+	}
+	keys := make([]string, len(list))
+	for i, element := range list {
+		if s, ok := element.(string); ok {
+			keys[i] = s
+			continue
+		}
+		encoded, err := json.Marshal(element)
+		if err != nil {
+			return value, false
+		}
+		keys[i] = string(encoded)
+	}
+	if sort.StringsAreSorted(keys) {
+		return value, false
+	}
+	indexes := make([]int, len(list))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool { return keys[indexes[i]] < keys[indexes[j]] })
+	sorted := make([]any, len(list))
+	for i, index := range indexes {
+		sorted[i] = list[index]
+	}
+	return sorted, true
+}
+
+// addNormalizationPatch prepends the patch normalizing the object of the request to the patch of the response of a
+// mutating admitter, which was computed from the normalized object. Denied responses are left as they are.
+func addNormalizationPatch(response *admissionv1.AdmissionResponse, normalization []byte) error {
+	if len(normalization) == 0 || !response.Allowed {
+		return nil
+	}
+	if len(response.Patch) == 0 {
+		response.Patch = normalization
+		response.PatchType = Ptr(admissionv1.PatchTypeJSONPatch)
+		return nil
+	}
+	var operations, normalizationOperations []json.RawMessage
+	if err := json.Unmarshal(response.Patch, &operations); err != nil {
+		return fmt.Errorf("failed to decode patch: %w", err)
+	}
+	if err := json.Unmarshal(normalization, &normalizationOperations); err != nil {
+		return fmt.Errorf("failed to decode normalization patch: %w", err)
+	}
+	patch, err := json.Marshal(append(normalizationOperations, operations...))
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch with normalization to JSON: %w", err)
+	}
+	response.Patch = patch
+	return nil
+}
@@ -0,0 +1,243 @@
+package admission
+
+import (
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNormalizationNormalize(t *testing.T) {
+	t.Parallel()
+	normalization := Normalization{
+		Trimmed:   []string{"spec.displayName", "metadata.annotations[field.cattle.io/creatorId]", "spec.members.name"},
+		Hostnames: []string{"spec.fqdn"},
+		Unordered: []string{"roles", "rules"},
+	}
+	tests := []struct {
+		name       string
+		object     string
+		wantObject string
+		wantFields []string
+	}{
+		{
+			name:   "normalized object",
+			object: `{"metadata":{"annotations":{"field.cattle.io/creatorId":"u-1"}},"spec":{"displayName":"one","fqdn":"rancher.example.com"},"roles":["a","b"]}`,
+		},
+		{
+			name:       "trimmed fields",
+			object:     `{"metadata":{"annotations":{"field.cattle.io/creatorId":" u-1\n","other":" x "}},"spec":{"displayName":"one\t"}}`,
+			wantObject: `{"metadata":{"annotations":{"field.cattle.io/creatorId":"u-1","other":" x "}},"spec":{"displayName":"one"}}`,
+			wantFields: []string{"spec.displayName", "metadata.annotations[field.cattle.io/creatorId]"},
+		},
+		{
+			name:       "fields in lists",
+			object:     `{"spec":{"members":[{"name":" a"},{"name":"b"},{"other":" c "}]}}`,
+			wantObject: `{"spec":{"members":[{"name":"a"},{"name":"b"},{"other":" c "}]}}`,
+			wantFields: []string{"spec.members.name"},
+		},
+		{
+			name:       "hostnames",
+			object:     `{"spec":{"fqdn":" Rancher.Example.COM"}}`,
+			wantObject: `{"spec":{"fqdn":"rancher.example.com"}}`,
+			wantFields: []string{"spec.fqdn"},
+		},
+		{
+			name:       "unordered lists",
+			object:     `{"roles":["b","c","a"],"rules":[{"verbs":["get"]},{"apiGroups":[""]}]}`,
+			wantObject: `{"roles":["a","b","c"],"rules":[{"apiGroups":[""]},{"verbs":["get"]}]}`,
+			wantFields: []string{"roles", "rules"},
+		},
+		{
+			name:   "fields of another type",
+			object: `{"spec":{"displayName":1,"fqdn":["a"]},"roles":"b,a"}`,
+		},
+		{
+			name:   "object that doesn't decode",
+			object: `{"spec":`,
+		},
+		{
+			name: "no object",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			raw, fields := normalization.normalize([]byte(test.object))
+			assert.Equal(t, test.wantFields, fields)
+			if test.wantObject == "" {
+				assert.Equal(t, test.object, string(raw))
+				return
+			}
+			assert.JSONEq(t, test.wantObject, string(raw))
+		})
+	}
+}
+
+func TestNormalizationAlign(t *testing.T) {
+	t.Parallel()
+	normalization := Normalization{
+		Trimmed:   []string{"spec.displayName", "spec.members.name"},
+		Hostnames: []string{"spec.fqdn"},
+		Unordered: []string{"roles"},
+	}
+	tests := []struct {
+		name          string
+		oldObject     string
+		object        string
+		wantOldObject string
+		wantFields    []string
+	}{
+		{
+			name:      "same objects",
+			oldObject: `{"spec":{"displayName":"one"},"roles":["a","b"]}`,
+			object:    `{"spec":{"displayName":"one"},"roles":["a","b"]}`,
+		},
+		{
+			name:          "differently written values",
+			oldObject:     `{"spec":{"displayName":" one","fqdn":"Rancher.example.com"},"roles":["b","a"]}`,
+			object:        `{"spec":{"displayName":"one ","fqdn":"rancher.example.com"},"roles":["a","b"]}`,
+			wantOldObject: `{"spec":{"displayName":"one ","fqdn":"rancher.example.com"},"roles":["a","b"]}`,
+			wantFields:    []string{"spec.displayName", "spec.fqdn", "roles"},
+		},
+		{
+			name:      "changed values",
+			oldObject: `{"spec":{"displayName":" one","fqdn":"rancher.example.com"},"roles":["b","a"]}`,
+			object:    `{"spec":{"displayName":"two","fqdn":"other.example.com"},"roles":["a","c"]}`,
+		},
+		{
+			name:          "fields in lists",
+			oldObject:     `{"spec":{"members":[{"name":" a"},{"name":"b"}]}}`,
+			object:        `{"spec":{"members":[{"name":"a"},{"name":"c"}]}}`,
+			wantOldObject: `{"spec":{"members":[{"name":"a"},{"name":"b"}]}}`,
+			wantFields:    []string{"spec.members.name"},
+		},
+		{
+			name:      "lists of another length",
+			oldObject: `{"spec":{"members":[{"name":" a"}]}}`,
+			object:    `{"spec":{"members":[{"name":"a"},{"name":"b"}]}}`,
+		},
+		{
+			name:      "removed fields",
+			oldObject: `{"spec":{"displayName":" one"}}`,
+			object:    `{"spec":{}}`,
+		},
+		{
+			name:      "object that doesn't decode",
+			oldObject: `{"spec":{"displayName":" one"}}`,
+			object:    `{"spec":`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			raw, fields := normalization.align([]byte(test.oldObject), []byte(test.object))
+			assert.Equal(t, test.wantFields, fields)
+			if test.wantOldObject == "" {
+				assert.Equal(t, test.oldObject, string(raw))
+				return
+			}
+			assert.JSONEq(t, test.wantOldObject, string(raw))
+		})
+	}
+}
+
+func TestSplitNormalizationPath(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{"spec", "displayName"}, splitNormalizationPath("spec.displayName"))
+	assert.Equal(t, []string{"metadata", "annotations", "field.cattle.io/creatorId"}, splitNormalizationPath("metadata.annotations[field.cattle.io/creatorId]"))
+	assert.Equal(t, []string{"data", "a.b", "c"}, splitNormalizationPath("data[a.b].c"))
+	assert.Equal(t, []string{"data", "a.b"}, splitNormalizationPath("data[a.b"))
+	assert.Empty(t, splitNormalizationPath(""))
+}
+
+// TestNormalizeRequest isn't parallel since it changes how objects are normalized for the package.
+func TestNormalizeRequest(t *testing.T) {
+	defer func() { _ = SetNormalization(NormalizationOff) }()
+	bindings := metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalrolebindings"}
+	const (
+		object     = `{"metadata":{"name":"grb-1","resourceVersion":"2"},"userName":"u-1 ","globalRoleName":"admin"}`
+		oldObject  = `{"metadata":{"name":"grb-1","resourceVersion":"1"},"userName":" u-1","globalRoleName":"admin"}`
+		normalized = `{"metadata":{"name":"grb-1","resourceVersion":"2"},"userName":"u-1","globalRoleName":"admin"}`
+	)
+	newRequest := func(resource metav1.GroupVersionResource, subResource string) *Request {
+		return &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation:   admissionv1.Update,
+			Resource:    resource,
+			SubResource: subResource,
+			Object:      runtime.RawExtension{Raw: []byte(object)},
+			OldObject:   runtime.RawExtension{Raw: []byte(oldObject)},
+		}}
+	}
+
+	require.NoError(t, SetNormalization(NormalizationOff))
+	request := newRequest(bindings, "")
+	normalizeValidatedRequest(request)
+	assert.Equal(t, object, string(request.Object.Raw))
+	assert.Nil(t, normalizeMutatedRequest(request))
+
+	require.NoError(t, SetNormalization(NormalizationValidate))
+	request = newRequest(bindings, "")
+	normalizeValidatedRequest(request)
+	assert.Equal(t, object, string(request.Object.Raw), "validators review the object that is stored")
+	assert.JSONEq(t, `{"metadata":{"name":"grb-1","resourceVersion":"1"},"userName":"u-1 ","globalRoleName":"admin"}`, string(request.OldObject.Raw),
+		"the old object is aligned with the object")
+	request = newRequest(bindings, "")
+	request.Object.Raw = []byte(`{"metadata":{"name":"grb-1","resourceVersion":"2"},"userName":"u-2","globalRoleName":"admin"}`)
+	normalizeValidatedRequest(request)
+	assert.Equal(t, oldObject, string(request.OldObject.Raw), "changed values aren't aligned")
+	request = newRequest(bindings, "")
+	assert.Nil(t, normalizeMutatedRequest(request))
+	assert.Equal(t, object, string(request.Object.Raw))
+
+	require.NoError(t, SetNormalization(NormalizationPersist))
+	request = newRequest(bindings, "")
+	patch := normalizeMutatedRequest(request)
+	require.NotNil(t, patch)
+	assert.JSONEq(t, normalized, string(request.Object.Raw))
+	decoded, err := jsonpatch.DecodePatch(patch)
+	require.NoError(t, err)
+	patched, err := decoded.Apply([]byte(object))
+	require.NoError(t, err)
+	assert.JSONEq(t, normalized, string(patched))
+
+	request = newRequest(bindings, "status")
+	assert.Nil(t, normalizeMutatedRequest(request))
+	assert.Equal(t, object, string(request.Object.Raw))
+	request = newRequest(metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "tokens"}, "")
+	assert.Nil(t, normalizeMutatedRequest(request))
+	assert.Equal(t, object, string(request.Object.Raw))
+
+	assert.Error(t, SetNormalization("always"))
+}
+
+func TestAddNormalizationPatch(t *testing.T) {
+	t.Parallel()
+	normalization := []byte(`[{"op":"replace","path":"/userName","value":"u-1"}]`)
+
+	response := ResponseAllowed()
+	require.NoError(t, addNormalizationPatch(response, normalization))
+	assert.JSONEq(t, string(normalization), string(response.Patch))
+	assert.Equal(t, admissionv1.PatchTypeJSONPatch, *response.PatchType)
+
+	response = ResponseAllowedWithPatch([]byte(`[{"op":"add","path":"/metadata/labels","value":{"a":"b"}}]`))
+	require.NoError(t, addNormalizationPatch(response, normalization))
+	assert.JSONEq(t, `[{"op":"replace","path":"/userName","value":"u-1"},{"op":"add","path":"/metadata/labels","value":{"a":"b"}}]`, string(response.Patch))
+
+	response = ResponseDenied(DenialForbidden, "denied")
+	require.NoError(t, addNormalizationPatch(response, normalization))
+	assert.Empty(t, response.Patch)
+
+	response = ResponseAllowed()
+	require.NoError(t, addNormalizationPatch(response, nil))
+	assert.Empty(t, response.Patch)
+
+	response = ResponseAllowedWithPatch([]byte(`{"op":"add"}`))
+	assert.Error(t, addNormalizationPatch(response, normalization))
+}
@@ -0,0 +1,143 @@
+package admission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rancher/wrangler/v3/pkg/schemes"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// StrictOldObjects fails requests whose old object doesn't decode into the current types. This is the default.
+	StrictOldObjects = "strict"
+	// RelaxedOldObjects drops the fields of old objects that don't decode into the current types, with a warning, so
+	// that objects stored before an API change can still be fixed.
+	RelaxedOldObjects = "relaxed"
+
+	// maxDroppedFields is the number of fields dropped from an old object before giving up on decoding it.
+	maxDroppedFields = 20
+)
+
+// relaxedOldObjects is true when old objects are decoded leniently, see SetOldObjectDecoding.
+var relaxedOldObjects atomic.Bool
+
+// SetOldObjectDecoding sets how old objects that don't decode into the current types are handled: StrictOldObjects,
+// the default if mode is empty, or RelaxedOldObjects.
+func SetOldObjectDecoding(mode string) error {
+	switch mode {
+	case "", StrictOldObjects:
+		relaxedOldObjects.Store(false)
+	case RelaxedOldObjects:
+		relaxedOldObjects.Store(true)
+	default:
+		return fmt.Errorf("unknown old object decoding %q, must be %q or %q", mode, StrictOldObjects, RelaxedOldObjects)
+	}
+	return nil
+}
+
+// relaxOldObject replaces the old object of the request with one that decodes into the current type of its kind, by
+// dropping the fields that don't, and adds a warning listing them to the request. Old objects that already decode, or
+// that can't be fixed by dropping fields, are left as they are, so that admitters fail on them as in strict mode.
+func relaxOldObject(request *Request) {
+	if !relaxedOldObjects.Load() || len(request.OldObject.Raw) == 0 {
+		return
+	}
+	gvk := schema.GroupVersionKind(request.Kind)
+	if !schemes.All.Recognizes(gvk) {
+		return
+	}
+	raw := request.OldObject.Raw
+	var content map[string]any
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return
+	}
+	var dropped []string
+	for {
+		obj, err := schemes.All.New(gvk)
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(raw, obj)
+		if err == nil {
+			break
+		}
+		if len(dropped) == maxDroppedFields {
+			return
+		}
+		field := undecodableField(content, err)
+		if field == "" {
+			logrus.Debugf("[relaxOldObject] can't locate the field of %s %s failing to decode: %v", gvk.Kind, resourceString(request.Namespace, request.Name), err)
+			return
+		}
+		dropped = append(dropped, field)
+		if raw, err = json.Marshal(content); err != nil {
+			return
+		}
+	}
+	if len(dropped) == 0 {
+		return
+	}
+	request.OldObject.Raw = raw
+	request.OldObject.Object = nil
+	request.warnings = append(request.warnings, fmt.Sprintf("the stored %s doesn't match its current schema, these fields of the old object were ignored: %s",
+		gvk.Kind, strings.Join(dropped, ", ")))
+	logrus.Warnf("[relaxOldObject] ignored fields %s of the old %s %s", strings.Join(dropped, ", "), gvk.Kind, resourceString(request.Namespace, request.Name))
+}
+
+// undecodableField removes the field the decoding error is about from the content, and returns its path. Errors that
+// don't name a field are blamed on the status, which controllers rewrite anyway. It returns an empty path if nothing
+// could be removed.
+func undecodableField(content map[string]any, err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		if removeField(content, strings.Split(typeErr.Field, ".")) {
+			return typeErr.Field
+		}
+		return ""
+	}
+	if _, ok := content["status"]; ok {
+		delete(content, "status")
+		return "status"
+	}
+	return ""
+}
+
+// removeField removes the field at the given path from the content. Lists along the path are either indexed by the
+// path, or the field is removed from all their elements. It returns true if the field was found.
+func removeField(content any, path []string) bool {
+	switch value := content.(type) {
+	case map[string]any:
+		child, ok := value[path[0]]
+		if !ok {
+			return false
+		}
+		if len(path) == 1 {
+			delete(value, path[0])
+			return true
+		}
+		return removeField(child, path[1:])
+	case []any:
+		if index, err := strconv.Atoi(path[0]); err == nil {
+			if index < 0 || index >= len(value) {
+				return false
+			}
+			if len(path) == 1 {
+				return false
+			}
+			return removeField(value[index], path[1:])
+		}
+		found := false
+		for _, element := range value {
+			found = removeField(element, path) || found
+		}
+		return found
+	default:
+		return false
+	}
+}
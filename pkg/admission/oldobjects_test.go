@@ -0,0 +1,93 @@
+package admission
+
+import (
+	"encoding/json"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/schemes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TestRelaxOldObject isn't parallel since it changes how old objects are decoded for the package.
+func TestRelaxOldObject(t *testing.T) {
+	require.NoError(t, schemes.Register(v3.AddToScheme))
+	defer func() { _ = SetOldObjectDecoding(StrictOldObjects) }()
+	projectKind := metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"}
+
+	tests := []struct {
+		name         string
+		kind         metav1.GroupVersionKind
+		oldObject    string
+		wantWarnings []string
+		wantOld      *v3.Project
+	}{
+		{
+			name:      "old object that decodes",
+			kind:      projectKind,
+			oldObject: `{"metadata":{"name":"p-1"},"spec":{"displayName":"one"}}`,
+		},
+		{
+			name:         "fields with the wrong type",
+			kind:         projectKind,
+			oldObject:    `{"metadata":{"name":"p-1"},"spec":{"displayName":1,"clusterName":"c-1","containerDefaultResourceLimit":{"limitsCpu":["1"]}}}`,
+			wantWarnings: []string{"the stored Project doesn't match its current schema, these fields of the old object were ignored: spec.displayName, spec.containerDefaultResourceLimit.limitsCpu"},
+			wantOld: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: "p-1"},
+				Spec:       v3.ProjectSpec{ClusterName: "c-1", ContainerDefaultResourceLimit: &v3.ContainerResourceLimit{}},
+			},
+		},
+		{
+			name:         "status that doesn't decode",
+			kind:         projectKind,
+			oldObject:    `{"metadata":{"name":"p-1"},"spec":{"displayName":"one"},"status":{"conditions":[{"lastUpdateTime":1}]}}`,
+			wantWarnings: []string{"the stored Project doesn't match its current schema, these fields of the old object were ignored: status.conditions.0.lastUpdateTime"},
+			wantOld: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: "p-1"},
+				Spec:       v3.ProjectSpec{DisplayName: "one"},
+				Status:     v3.ProjectStatus{Conditions: []v3.ProjectCondition{{}}},
+			},
+		},
+		{
+			name:      "error that can't be located",
+			kind:      projectKind,
+			oldObject: `{"metadata":{"name":"p-1","creationTimestamp":"yesterday"}}`,
+		},
+		{
+			name:      "unknown kind",
+			kind:      metav1.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Unknown"},
+			oldObject: `{"spec":{"displayName":1}}`,
+		},
+	}
+	for _, mode := range []string{StrictOldObjects, RelaxedOldObjects} {
+		require.NoError(t, SetOldObjectDecoding(mode))
+		for _, test := range tests {
+			request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Kind:      test.kind,
+				Operation: admissionv1.Update,
+				OldObject: runtime.RawExtension{Raw: []byte(test.oldObject)},
+			}}
+			relaxOldObject(request)
+			if mode == StrictOldObjects || test.wantOld == nil {
+				assert.Equal(t, test.oldObject, string(request.OldObject.Raw), "%s: %s", mode, test.name)
+				assert.Empty(t, request.warnings, "%s: %s", mode, test.name)
+				continue
+			}
+			assert.Equal(t, test.wantWarnings, request.warnings, test.name)
+			var oldObject v3.Project
+			require.NoError(t, json.Unmarshal(request.OldObject.Raw, &oldObject), test.name)
+			assert.Equal(t, test.wantOld, &oldObject, test.name)
+
+			response := ResponseAllowed()
+			budget := &WarningBudget{}
+			applyWarnings(request, budget, response)
+			applyWarnings(request, budget, response)
+			assert.Equal(t, test.wantWarnings, response.Warnings, test.name)
+		}
+	}
+	assert.Error(t, SetOldObjectDecoding("lenient"))
+}
@@ -0,0 +1,181 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// ProjectIDAnnotation is the annotation of namespaces holding the ID of their project, in the cluster:project form.
+	ProjectIDAnnotation = "field.cattle.io/projectId"
+	// NamespacesByProjectIndex is the index of namespaces by the ID of their project, see IndexNamespacesByProject.
+	NamespacesByProjectIndex = "webhook.cattle.io/namespaces-by-project"
+)
+
+var (
+	clustersResource   = schema.GroupResource{Group: "management.cattle.io", Resource: "clusters"}
+	projectsResource   = schema.GroupResource{Group: "management.cattle.io", Resource: "projects"}
+	prtbsResource      = schema.GroupResource{Group: "management.cattle.io", Resource: "projectroletemplatebindings"}
+	crtbsResource      = schema.GroupResource{Group: "management.cattle.io", Resource: "clusterroletemplatebindings"}
+	namespacesResource = schema.GroupResource{Resource: "namespaces"}
+)
+
+// Owner is the management cluster and project owning the object of a request.
+type Owner struct {
+	// Cluster is the name of the management cluster, empty if the cluster can't be told.
+	Cluster string
+	// Project is the name of the project in the namespace of its cluster, empty if the object doesn't belong to a
+	// project.
+	Project string
+}
+
+// ProjectID returns the ID of the project in the cluster:project form, empty if the owner has no project.
+func (o Owner) ProjectID() string {
+	if o.Cluster == "" || o.Project == "" {
+		return ""
+	}
+	return o.Cluster + ":" + o.Project
+}
+
+// ParseProjectID returns the owner of the project with the given ID in the cluster:project form, as used by the
+// field.cattle.io/projectId annotation of namespaces and the projectName of project role template bindings. It returns
+// false if the ID doesn't have exactly two non-empty parts.
+func ParseProjectID(id string) (Owner, bool) {
+	clusterName, projectName, ok := strings.Cut(id, ":")
+	if !ok || clusterName == "" || projectName == "" || strings.Contains(projectName, ":") {
+		return Owner{}, false
+	}
+	return Owner{Cluster: clusterName, Project: projectName}, true
+}
+
+// owners holds the namespace cache used to find the owners of namespaced objects.
+var owners = struct {
+	mutex          sync.RWMutex
+	namespaceCache generic.NonNamespacedCacheInterface[*corev1.Namespace]
+}{}
+
+// ConfigureOwners sets the cache of the namespaces whose project owns the objects in them. Without a cache, the owner
+// of namespaced objects other than projects and role template bindings isn't resolved.
+func ConfigureOwners(namespaceCache generic.NonNamespacedCacheInterface[*corev1.Namespace]) {
+	owners.mutex.Lock()
+	defer owners.mutex.Unlock()
+	owners.namespaceCache = namespaceCache
+}
+
+// OwnerFromRequest returns the cluster and project owning the object of the request, the old object on delete. The
+// owner is resolved once per request, so that all rules agree on it:
+//   - clusters own themselves,
+//   - projects are owned by the cluster of their namespace,
+//   - project role template bindings by the project of their projectName, and cluster role template bindings by the
+//     cluster of their clusterName,
+//   - namespaces by the project of their field.cattle.io/projectId annotation,
+//   - other namespaced objects by the project of their namespace.
+//
+// Objects with an invalid reference, or whose owner can't be told, have an empty owner.
+func OwnerFromRequest(request *Request) (Owner, error) {
+	if request.owner != nil {
+		return *request.owner, nil
+	}
+	owner, err := resolveOwner(request)
+	if err != nil {
+		return Owner{}, err
+	}
+	request.owner = &owner
+	return owner, nil
+}
+
+// resolveOwner returns the owner of the object of the request.
+func resolveOwner(request *Request) (Owner, error) {
+	oldObj, newObj, err := MetadataFromRequest(request)
+	if err != nil {
+		return Owner{}, err
+	}
+	obj, raw := newObj, request.Object.Raw
+	if len(raw) == 0 {
+		obj, raw = oldObj, request.OldObject.Raw
+	}
+	name, namespace := obj.Name, obj.Namespace
+	if name == "" {
+		name = request.Name
+	}
+	if namespace == "" {
+		namespace = request.Namespace
+	}
+
+	switch (schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}) {
+	case clustersResource:
+		return Owner{Cluster: name}, nil
+	case projectsResource:
+		return Owner{Cluster: namespace, Project: name}, nil
+	case prtbsResource, crtbsResource:
+		var binding struct {
+			ProjectName string `json:"projectName"`
+			ClusterName string `json:"clusterName"`
+		}
+		if len(raw) != 0 {
+			if err := json.Unmarshal(raw, &binding); err != nil {
+				return Owner{}, fmt.Errorf("failed to decode the role template binding: %w", err)
+			}
+		}
+		if request.Resource.Resource == crtbsResource.Resource {
+			return Owner{Cluster: binding.ClusterName}, nil
+		}
+		owner, _ := ParseProjectID(binding.ProjectName)
+		return owner, nil
+	case namespacesResource:
+		owner, _ := ParseProjectID(obj.Annotations[ProjectIDAnnotation])
+		return owner, nil
+	}
+	if namespace == "" {
+		return Owner{}, nil
+	}
+	return namespaceOwner(request, namespace)
+}
+
+// namespaceOwner returns the owner of the namespace, empty if it has no project or namespaces aren't configured.
+func namespaceOwner(request *Request, name string) (Owner, error) {
+	owners.mutex.RLock()
+	namespaceCache := owners.namespaceCache
+	owners.mutex.RUnlock()
+	if namespaceCache == nil {
+		return Owner{}, nil
+	}
+	namespace, err := Lookup(request, namespaceCache, name)
+	if apierrors.IsNotFound(err) {
+		return Owner{}, nil
+	}
+	if err != nil {
+		return Owner{}, fmt.Errorf("failed to get namespace %s: %w", name, err)
+	}
+	owner, _ := ParseProjectID(namespace.Annotations[ProjectIDAnnotation])
+	return owner, nil
+}
+
+// indexedNamespaceCaches are the namespace caches with the NamespacesByProjectIndex index.
+var indexedNamespaceCaches sync.Map
+
+// IndexNamespacesByProject adds the NamespacesByProjectIndex index to the namespace cache, unless it was already
+// added. The handlers sharing the cache of the shared controller factory each call it, since adding an index twice
+// panics.
+func IndexNamespacesByProject(namespaceCache generic.NonNamespacedCacheInterface[*corev1.Namespace]) {
+	if _, indexed := indexedNamespaceCaches.LoadOrStore(namespaceCache, true); indexed {
+		return
+	}
+	namespaceCache.AddIndexer(NamespacesByProjectIndex, namespacesByProject)
+}
+
+// namespacesByProject indexes namespaces by the ID of their project, in the cluster:project form.
+func namespacesByProject(namespace *corev1.Namespace) ([]string, error) {
+	owner, ok := ParseProjectID(namespace.Annotations[ProjectIDAnnotation])
+	if !ok {
+		return nil, nil
+	}
+	return []string{owner.ProjectID()}, nil
+}
@@ -0,0 +1,178 @@
+package admission
+
+import (
+	"testing"
+
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestParseProjectID(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		id     string
+		want   Owner
+		wantOK bool
+	}{
+		{id: "c-abc12:p-xyz34", want: Owner{Cluster: "c-abc12", Project: "p-xyz34"}, wantOK: true},
+		{id: "p-xyz34"},
+		{id: ":p-xyz34"},
+		{id: "c-abc12:"},
+		{id: "c-abc12:p-xyz34:extra"},
+		{id: ""},
+	}
+	for _, test := range tests {
+		owner, ok := ParseProjectID(test.id)
+		assert.Equal(t, test.wantOK, ok, test.id)
+		assert.Equal(t, test.want, owner, test.id)
+		if ok {
+			assert.Equal(t, test.id, owner.ProjectID())
+		}
+	}
+}
+
+func TestOwnerFromRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](ctrl)
+	namespaceCache.EXPECT().Get("team-a").Return(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-a",
+		Annotations: map[string]string{ProjectIDAnnotation: "c-abc12:p-xyz34"},
+	}}, nil)
+	namespaceCache.EXPECT().Get("missing").Return(nil, apierrors.NewNotFound(corev1.Resource("namespaces"), "missing"))
+	ConfigureOwners(namespaceCache)
+	t.Cleanup(func() { ConfigureOwners(nil) })
+
+	tests := []struct {
+		name      string
+		group     string
+		resource  string
+		namespace string
+		object    string
+		oldObject string
+		want      Owner
+	}{
+		{
+			name:     "cluster",
+			group:    "management.cattle.io",
+			resource: "clusters",
+			object:   `{"metadata":{"name":"c-abc12"}}`,
+			want:     Owner{Cluster: "c-abc12"},
+		},
+		{
+			name:      "project",
+			group:     "management.cattle.io",
+			resource:  "projects",
+			namespace: "c-abc12",
+			object:    `{"metadata":{"name":"p-xyz34","namespace":"c-abc12"},"spec":{"clusterName":"c-abc12"}}`,
+			want:      Owner{Cluster: "c-abc12", Project: "p-xyz34"},
+		},
+		{
+			name:      "project role template binding",
+			group:     "management.cattle.io",
+			resource:  "projectroletemplatebindings",
+			namespace: "p-xyz34",
+			object:    `{"metadata":{"name":"prtb-1","namespace":"p-xyz34"},"projectName":"c-abc12:p-xyz34"}`,
+			want:      Owner{Cluster: "c-abc12", Project: "p-xyz34"},
+		},
+		{
+			name:      "project role template binding with an invalid project",
+			group:     "management.cattle.io",
+			resource:  "projectroletemplatebindings",
+			namespace: "p-xyz34",
+			object:    `{"metadata":{"name":"prtb-1","namespace":"p-xyz34"},"projectName":"c-abc12:p-xyz34:extra"}`,
+		},
+		{
+			name:      "cluster role template binding",
+			group:     "management.cattle.io",
+			resource:  "clusterroletemplatebindings",
+			namespace: "c-abc12",
+			object:    `{"metadata":{"name":"crtb-1","namespace":"c-abc12"},"clusterName":"c-abc12"}`,
+			want:      Owner{Cluster: "c-abc12"},
+		},
+		{
+			name:     "namespace",
+			resource: "namespaces",
+			object:   `{"metadata":{"name":"team-b","annotations":{"field.cattle.io/projectId":"c-abc12:p-xyz34"}}}`,
+			want:     Owner{Cluster: "c-abc12", Project: "p-xyz34"},
+		},
+		{
+			name:      "deleted namespace",
+			resource:  "namespaces",
+			oldObject: `{"metadata":{"name":"team-b","annotations":{"field.cattle.io/projectId":"c-abc12:p-xyz34"}}}`,
+			want:      Owner{Cluster: "c-abc12", Project: "p-xyz34"},
+		},
+		{
+			name:     "namespace without project",
+			resource: "namespaces",
+			object:   `{"metadata":{"name":"team-b"}}`,
+		},
+		{
+			name:      "object in a project namespace",
+			resource:  "secrets",
+			namespace: "team-a",
+			object:    `{"metadata":{"name":"secret-1","namespace":"team-a"}}`,
+			want:      Owner{Cluster: "c-abc12", Project: "p-xyz34"},
+		},
+		{
+			name:      "object in a missing namespace",
+			resource:  "secrets",
+			namespace: "missing",
+			object:    `{"metadata":{"name":"secret-1","namespace":"missing"}}`,
+		},
+		{
+			name:     "cluster scoped object",
+			group:    "rbac.authorization.k8s.io",
+			resource: "clusterroles",
+			object:   `{"metadata":{"name":"admin"}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Resource:  metav1.GroupVersionResource{Group: test.group, Version: "v1", Resource: test.resource},
+				Namespace: test.namespace,
+				Object:    runtime.RawExtension{Raw: []byte(test.object)},
+				OldObject: runtime.RawExtension{Raw: []byte(test.oldObject)},
+			}}
+			owner, err := OwnerFromRequest(request)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, owner)
+
+			// the owner is resolved once per request
+			request.Object.Raw = []byte(`not json`)
+			memoized, err := OwnerFromRequest(request)
+			require.NoError(t, err)
+			assert.Equal(t, owner, memoized)
+		})
+	}
+}
+
+func TestIndexNamespacesByProject(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](ctrl)
+	var indexer func(*corev1.Namespace) ([]string, error)
+	namespaceCache.EXPECT().AddIndexer(NamespacesByProjectIndex, gomock.Any()).Do(func(_ string, fn generic.Indexer[*corev1.Namespace]) {
+		indexer = fn
+	})
+
+	// the index is only added once to the same cache
+	IndexNamespacesByProject(namespaceCache)
+	IndexNamespacesByProject(namespaceCache)
+	require.NotNil(t, indexer)
+
+	keys, err := indexer(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{ProjectIDAnnotation: "c-abc12:p-xyz34"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c-abc12:p-xyz34"}, keys)
+	keys, err = indexer(&corev1.Namespace{})
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
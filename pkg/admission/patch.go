@@ -0,0 +1,48 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// PatchFromObjects computes an RFC6902 JSON patch containing the differences between original and mutated.
+// Both objects are expected to be decoded from the same request, with mutated being modified in place after
+// a copy of original was taken. Diffing the decoded objects rather than the raw request keeps fields unknown to
+// the Go type out of the patch. A nil patch is returned when the objects are equal.
+func PatchFromObjects(original, mutated any) ([]byte, error) {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal original object to JSON: %w", err)
+	}
+	mutatedJSON, err := json.Marshal(mutated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mutated object to JSON: %w", err)
+	}
+	operations, err := jsonpatch.CreatePatch(originalJSON, mutatedJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create patch: %w", err)
+	}
+	if len(operations) == 0 {
+		return nil, nil
+	}
+	patchJSON, err := json.Marshal(operations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal generated patch to JSON: %w", err)
+	}
+	return patchJSON, nil
+}
+
+// ResponseAllowedWithPatch returns an AdmissionResponse in which Allowed is true and the given JSON patch is set.
+// If the patch is empty the response carries no patch.
+func ResponseAllowedWithPatch(patch []byte) *admissionv1.AdmissionResponse {
+	response := ResponseAllowed()
+	if len(patch) == 0 {
+		return response
+	}
+	response.Patch = patch
+	response.PatchType = Ptr(admissionv1.PatchTypeJSONPatch)
+	return response
+}
@@ -0,0 +1,113 @@
+package admission_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPatchFromObjects(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		mutate    func(pod *corev1.Pod)
+		wantPatch []map[string]any
+	}{
+		{
+			name:   "no changes",
+			mutate: func(_ *corev1.Pod) {},
+		},
+		{
+			name: "add annotation",
+			mutate: func(pod *corev1.Pod) {
+				pod.Annotations["new"] = "value"
+			},
+			wantPatch: []map[string]any{
+				{"op": "add", "path": "/metadata/annotations/new", "value": "value"},
+			},
+		},
+		{
+			name: "replace annotation with an escaped key",
+			mutate: func(pod *corev1.Pod) {
+				pod.Annotations["field.cattle.io/creatorId"] = "u-new"
+			},
+			wantPatch: []map[string]any{
+				{"op": "replace", "path": "/metadata/annotations/field.cattle.io~1creatorId", "value": "u-new"},
+			},
+		},
+		{
+			name: "remove env var from the middle of a list",
+			mutate: func(pod *corev1.Pod) {
+				container := &pod.Spec.Containers[0]
+				container.Env = append(container.Env[:1], container.Env[2:]...)
+			},
+			wantPatch: []map[string]any{
+				{"op": "replace", "path": "/spec/containers/0/env/1/name", "value": "THIRD"},
+				{"op": "remove", "path": "/spec/containers/0/env/2"},
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			original := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+					Annotations: map[string]string{
+						"field.cattle.io/creatorId": "u-old",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+							Env:  []corev1.EnvVar{{Name: "FIRST"}, {Name: "SECOND"}, {Name: "THIRD"}},
+						},
+					},
+				},
+			}
+			mutated := original.DeepCopy()
+			test.mutate(mutated)
+
+			patch, err := admission.PatchFromObjects(original, mutated)
+			require.NoError(t, err)
+			if test.wantPatch == nil {
+				assert.Nil(t, patch)
+				return
+			}
+			var got []map[string]any
+			require.NoError(t, json.Unmarshal(patch, &got))
+			assert.ElementsMatch(t, test.wantPatch, got)
+		})
+	}
+}
+
+func TestPatchFromObjectsMarshalError(t *testing.T) {
+	t.Parallel()
+	_, err := admission.PatchFromObjects(make(chan int), map[string]string{})
+	assert.Error(t, err)
+	_, err = admission.PatchFromObjects(map[string]string{}, make(chan int))
+	assert.Error(t, err)
+}
+
+func TestResponseAllowedWithPatch(t *testing.T) {
+	t.Parallel()
+	response := admission.ResponseAllowedWithPatch(nil)
+	assert.True(t, response.Allowed)
+	assert.Nil(t, response.Patch)
+	assert.Nil(t, response.PatchType)
+
+	patch := []byte(`[{"op":"add","path":"/metadata/labels","value":{}}]`)
+	response = admission.ResponseAllowedWithPatch(patch)
+	assert.True(t, response.Allowed)
+	assert.Equal(t, patch, response.Patch)
+	require.NotNil(t, response.PatchType)
+	assert.Equal(t, admissionv1.PatchTypeJSONPatch, *response.PatchType)
+}
@@ -0,0 +1,80 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// objectIdentity is the metadata identifying the instance and version of an object.
+type objectIdentity struct {
+	Metadata struct {
+		UID             types.UID `json:"uid,omitempty"`
+		ResourceVersion string    `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+}
+
+// identityOf returns the identity of the raw object, empty if the object isn't set or doesn't decode.
+func identityOf(raw []byte) objectIdentity {
+	var identity objectIdentity
+	if len(raw) != 0 {
+		_ = json.Unmarshal(raw, &identity)
+	}
+	return identity
+}
+
+// identityConflict returns a response denying an update whose object isn't the same instance as its old object, which
+// happens when the object is deleted and recreated with the same name while the request is in flight. Mutations and
+// validations computed from one instance must not be applied to the other. It returns nil if the request has no such
+// conflict.
+func identityConflict(request *Request) *admissionv1.AdmissionResponse {
+	if request.Operation != admissionv1.Update {
+		return nil
+	}
+	uid := identityOf(request.Object.Raw).Metadata.UID
+	oldUID := identityOf(request.OldObject.Raw).Metadata.UID
+	if uid == "" || oldUID == "" || uid == oldUID {
+		return nil
+	}
+	return ResponseDenied(DenialConflict, fmt.Sprintf("the object has uid %s but the stored object has uid %s, it was recreated while the request was in flight", uid, oldUID))
+}
+
+// addPreconditions prepends to the JSON patch of the response test operations on the uid and resourceVersion of the
+// object the patch was computed from, so that the API server rejects the patch rather than applying it to a different
+// instance or version of the object. Responses without a patch, and objects without uid or resourceVersion, such as
+// objects being created, are left as they are.
+func addPreconditions(request *Request, response *admissionv1.AdmissionResponse) error {
+	if len(response.Patch) == 0 {
+		return nil
+	}
+	identity := identityOf(request.Object.Raw).Metadata
+	var preconditions []map[string]any
+	if identity.UID != "" {
+		preconditions = append(preconditions, map[string]any{"op": "test", "path": "/metadata/uid", "value": identity.UID})
+	}
+	if identity.ResourceVersion != "" {
+		preconditions = append(preconditions, map[string]any{"op": "test", "path": "/metadata/resourceVersion", "value": identity.ResourceVersion})
+	}
+	if len(preconditions) == 0 {
+		return nil
+	}
+	var operations []json.RawMessage
+	if err := json.Unmarshal(response.Patch, &operations); err != nil {
+		return fmt.Errorf("failed to decode patch: %w", err)
+	}
+	patch := make([]any, 0, len(preconditions)+len(operations))
+	for _, precondition := range preconditions {
+		patch = append(patch, precondition)
+	}
+	for _, operation := range operations {
+		patch = append(patch, operation)
+	}
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch with preconditions to JSON: %w", err)
+	}
+	response.Patch = patchJSON
+	return nil
+}
@@ -0,0 +1,108 @@
+package admission
+
+import (
+	"net/http"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestIdentityConflict(t *testing.T) {
+	t.Parallel()
+	object := func(uid string) runtime.RawExtension {
+		return runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"test","uid":"` + uid + `"}}`)}
+	}
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		object    runtime.RawExtension
+		oldObject runtime.RawExtension
+		wantDeny  bool
+	}{
+		{
+			name:      "same uid",
+			operation: admissionv1.Update,
+			object:    object("a"),
+			oldObject: object("a"),
+		},
+		{
+			name:      "different uid",
+			operation: admissionv1.Update,
+			object:    object("b"),
+			oldObject: object("a"),
+			wantDeny:  true,
+		},
+		{
+			name:      "no uid in the object",
+			operation: admissionv1.Update,
+			object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"test"}}`)},
+			oldObject: object("a"),
+		},
+		{
+			name:      "create",
+			operation: admissionv1.Create,
+			object:    object("b"),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: test.operation,
+				Object:    test.object,
+				OldObject: test.oldObject,
+			}}
+			response := identityConflict(request)
+			if !test.wantDeny {
+				assert.Nil(t, response)
+				return
+			}
+			require.NotNil(t, response)
+			assert.False(t, response.Allowed)
+			assert.Equal(t, int32(http.StatusConflict), response.Result.Code)
+		})
+	}
+}
+
+func TestAddPreconditions(t *testing.T) {
+	t.Parallel()
+	object := []byte(`{"metadata":{"name":"test","uid":"a","resourceVersion":"1"}}`)
+	request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		Object:    runtime.RawExtension{Raw: object},
+	}}
+	response := ResponseAllowedWithPatch([]byte(`[{"op":"add","path":"/metadata/labels","value":{"a":"b"}}]`))
+	require.NoError(t, addPreconditions(request, response))
+	assert.JSONEq(t, `[
+		{"op":"test","path":"/metadata/uid","value":"a"},
+		{"op":"test","path":"/metadata/resourceVersion","value":"1"},
+		{"op":"add","path":"/metadata/labels","value":{"a":"b"}}
+	]`, string(response.Patch))
+
+	patch, err := jsonpatch.DecodePatch(response.Patch)
+	require.NoError(t, err)
+	patched, err := patch.Apply(object)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"name":"test","uid":"a","resourceVersion":"1","labels":{"a":"b"}}}`, string(patched))
+	_, err = patch.Apply([]byte(`{"metadata":{"name":"test","uid":"b","resourceVersion":"1"}}`))
+	assert.Error(t, err, "the patch must not apply to a recreated object")
+	_, err = patch.Apply([]byte(`{"metadata":{"name":"test","uid":"a","resourceVersion":"2"}}`))
+	assert.Error(t, err, "the patch must not apply to another version of the object")
+
+	response = ResponseAllowed()
+	require.NoError(t, addPreconditions(request, response))
+	assert.Nil(t, response.Patch, "responses without a patch must be left unchanged")
+
+	create := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"test"}}`)},
+	}}
+	response = ResponseAllowedWithPatch([]byte(`[{"op":"add","path":"/metadata/labels","value":{}}]`))
+	require.NoError(t, addPreconditions(create, response))
+	assert.JSONEq(t, `[{"op":"add","path":"/metadata/labels","value":{}}]`, string(response.Patch))
+}
@@ -0,0 +1,61 @@
+package admission
+
+import (
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// ReviewPrefetcher is optionally implemented by validators whose SubjectAccessReviews can be told from the request
+// alone, like the escalate and bind checks of RBAC resources. The reviews of a request waiting for its turn under the
+// rate limits are prefetched, see ConfigurePrefetch, so that binding storms don't pay for them one request at a time.
+type ReviewPrefetcher interface {
+	// PrefetchReviews returns the resource attributes of the reviews the admitters will create for the requester.
+	PrefetchReviews(request *Request) []authorizationv1.ResourceAttributes
+}
+
+// reviewPrefetch holds the function prefetching the reviews of the queued requests.
+var reviewPrefetch = struct {
+	mutex    sync.RWMutex
+	prefetch func(*Request, []authorizationv1.ResourceAttributes)
+}{}
+
+// ConfigurePrefetch sets the function prefetching the reviews of the requests queued under the rate limits. It must
+// not block, the reviews are created in the background. Without it, reviews aren't prefetched.
+func ConfigurePrefetch(prefetch func(*Request, []authorizationv1.ResourceAttributes)) {
+	reviewPrefetch.mutex.Lock()
+	defer reviewPrefetch.mutex.Unlock()
+	reviewPrefetch.prefetch = prefetch
+}
+
+// prefetchReviews starts prefetching the reviews of the request if the handler, or a handler it wraps, can tell them.
+func prefetchReviews(handler WebhookHandler, request *Request) {
+	prefetcher, ok := unwrapPrefetcher(handler)
+	if !ok {
+		return
+	}
+	reviewPrefetch.mutex.RLock()
+	prefetch := reviewPrefetch.prefetch
+	reviewPrefetch.mutex.RUnlock()
+	if prefetch == nil {
+		return
+	}
+	if attributes := prefetcher.PrefetchReviews(request); len(attributes) != 0 {
+		prefetch(request, attributes)
+	}
+}
+
+// unwrapPrefetcher returns the first ReviewPrefetcher among the handler and the handlers it wraps, see Wrapper.
+func unwrapPrefetcher(handler WebhookHandler) (ReviewPrefetcher, bool) {
+	for handler != nil {
+		if prefetcher, ok := handler.(ReviewPrefetcher); ok {
+			return prefetcher, true
+		}
+		wrapper, ok := handler.(Wrapper)
+		if !ok {
+			break
+		}
+		handler = wrapper.Unwrap()
+	}
+	return nil, false
+}
@@ -0,0 +1,72 @@
+package admission
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// prefetchingHandler is a handler whose requests need a bind review on global roles.
+type prefetchingHandler struct{}
+
+func (p *prefetchingHandler) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalrolebindings"}
+}
+
+func (p *prefetchingHandler) Operations() []v1.OperationType {
+	return []v1.OperationType{v1.Create}
+}
+
+func (p *prefetchingHandler) PrefetchReviews(request *Request) []authorizationv1.ResourceAttributes {
+	return []authorizationv1.ResourceAttributes{{Verb: "bind", Group: "management.cattle.io", Version: "v3", Resource: "globalroles", Name: request.Name}}
+}
+
+// wrappingHandler decorates a handler without forwarding its optional interfaces.
+type wrappingHandler struct {
+	WebhookHandler
+}
+
+func (w *wrappingHandler) Unwrap() WebhookHandler {
+	return w.WebhookHandler
+}
+
+func TestPrefetchReviewsOfQueuedRequests(t *testing.T) {
+	var prefetched []authorizationv1.ResourceAttributes
+	ConfigurePrefetch(func(_ *Request, attributes []authorizationv1.ResourceAttributes) {
+		prefetched = append(prefetched, attributes...)
+	})
+	t.Cleanup(func() { ConfigurePrefetch(nil) })
+
+	limits, err := ParseRateLimits(`{"qps":20,"burst":1,"maxWait":"1s"}`)
+	require.NoError(t, err)
+	shedder := &loadShedder{}
+	shedder.configure(limits)
+	request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalrolebindings"},
+		Name:      "admin",
+	}}
+	ctx := context.Background()
+
+	assert.Nil(t, shedder.admit(ctx, validatingWebhook, &prefetchingHandler{}, request))
+	assert.Empty(t, prefetched, "requests admitted right away aren't prefetched")
+
+	assert.Nil(t, shedder.admit(ctx, validatingWebhook, &prefetchingHandler{}, request))
+	assert.Equal(t, []authorizationv1.ResourceAttributes{{Verb: "bind", Group: "management.cattle.io", Version: "v3", Resource: "globalroles", Name: "admin"}}, prefetched,
+		"the reviews of queued requests are prefetched")
+
+	prefetched = nil
+	assert.Nil(t, shedder.admit(ctx, validatingWebhook, &wrappingHandler{&wrappingHandler{&prefetchingHandler{}}}, request))
+	assert.Len(t, prefetched, 1, "the reviews of wrapped handlers are prefetched")
+
+	prefetched = nil
+	assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request))
+	assert.Empty(t, prefetched, "handlers which can't tell their reviews aren't prefetched")
+}
@@ -0,0 +1,269 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// RateLimitsEnvKey is the environment variable holding the JSON rate limits of the admission requests, see
+	// RateLimits. Requests aren't limited if it's empty.
+	RateLimitsEnvKey = "CATTLE_WEBHOOK_RATE_LIMITS"
+
+	// OverloadDeny denies the requests shed under overload as TooManyRequests, telling clients when to retry.
+	OverloadDeny = "Deny"
+	// OverloadAllow allows the validating requests shed under overload with a warning, without validating them.
+	OverloadAllow = "Allow"
+
+	// DefaultRateLimitQueueSize is the number of requests waiting for a limit without a queue size.
+	DefaultRateLimitQueueSize = 50
+	// DefaultRateLimitMaxWait bounds the wait of the requests without a max wait.
+	DefaultRateLimitMaxWait = time.Second
+	// maxRateLimitMaxWait is the longest max wait, well under the default timeout of a webhook, 10s.
+	maxRateLimitMaxWait = 5 * time.Second
+)
+
+var shedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rancher_webhook",
+	Name:      "admission_shed_requests_total",
+	Help:      "Number of admission requests shed under overload by the rate limits, by resource and by action: denied or allowed.",
+}, []string{"resource", "action"})
+
+func init() {
+	prometheus.MustRegister(shedRequests)
+}
+
+// RateLimit is the sustained rate and the burst of the requests a limit admits.
+type RateLimit struct {
+	// QPS is the number of requests admitted per second. Zero doesn't limit the requests.
+	QPS float64 `json:"qps,omitempty"`
+	// Burst is the number of requests admitted at once. Zero uses the QPS rounded up.
+	Burst int `json:"burst,omitempty"`
+}
+
+// RateLimits limit the rate of the admission requests reviewed by the handlers, so that the webhook sheds the requests
+// it can't review in time under overload, instead of letting their latency climb past the webhook timeout of the API
+// server. Requests over a limit wait in a bounded queue for their turn, and are shed if the queue is full or if they
+// would wait longer than the max wait.
+type RateLimits struct {
+	// RateLimit is the global limit of the requests of all the resources.
+	RateLimit `json:",inline"`
+	// Resources are the limits of single resources, in the resource.group form or the resource name alone for core
+	// resources. Requests are admitted by both the global limit and the limit of their resource.
+	Resources map[string]RateLimit `json:"resources,omitempty"`
+	// QueueSize is the number of requests waiting for each limit. Zero uses DefaultRateLimitQueueSize.
+	QueueSize int `json:"queueSize,omitempty"`
+	// MaxWait is the longest time a request waits for its turn. Zero uses DefaultRateLimitMaxWait.
+	MaxWait metav1.Duration `json:"maxWait,omitempty"`
+	// Overload is Deny to deny the shed requests, or Allow to allow the shed validating requests with a warning. Shed
+	// mutating requests are always denied, since allowing them would skip their mutation. Empty is Deny.
+	Overload string `json:"overload,omitempty"`
+}
+
+// RateLimitsFromEnv returns the rate limits configured by RateLimitsEnvKey.
+func RateLimitsFromEnv() (RateLimits, error) {
+	limits, err := ParseRateLimits(os.Getenv(RateLimitsEnvKey))
+	if err != nil {
+		return limits, fmt.Errorf("failed to decode %s: %w", RateLimitsEnvKey, err)
+	}
+	return limits, nil
+}
+
+// ParseRateLimits parses the JSON rate limits, and sets the defaults of their unset fields.
+func ParseRateLimits(value string) (RateLimits, error) {
+	var limits RateLimits
+	if value == "" {
+		return limits, nil
+	}
+	if err := json.Unmarshal([]byte(value), &limits); err != nil {
+		return limits, err
+	}
+	if err := limits.RateLimit.check("global rate limit"); err != nil {
+		return limits, err
+	}
+	for resource, limit := range limits.Resources {
+		if resource == "" {
+			return limits, fmt.Errorf("rate limit without a resource")
+		}
+		if limit.QPS == 0 {
+			return limits, fmt.Errorf("rate limit of %s has no qps", resource)
+		}
+		if err := limit.check("rate limit of " + resource); err != nil {
+			return limits, err
+		}
+	}
+	if limits.QueueSize < 0 {
+		return limits, fmt.Errorf("queue size %d must not be negative", limits.QueueSize)
+	}
+	if limits.QueueSize == 0 {
+		limits.QueueSize = DefaultRateLimitQueueSize
+	}
+	if limits.MaxWait.Duration < 0 || limits.MaxWait.Duration > maxRateLimitMaxWait {
+		return limits, fmt.Errorf("max wait %s must be between 0 and %s", limits.MaxWait.Duration, maxRateLimitMaxWait)
+	}
+	if limits.MaxWait.Duration == 0 {
+		limits.MaxWait.Duration = DefaultRateLimitMaxWait
+	}
+	switch limits.Overload {
+	case "":
+		limits.Overload = OverloadDeny
+	case OverloadDeny, OverloadAllow:
+	default:
+		return limits, fmt.Errorf("overload %q must be %s or %s", limits.Overload, OverloadDeny, OverloadAllow)
+	}
+	return limits, nil
+}
+
+// check returns an error if the rate or the burst of the limit is negative.
+func (l RateLimit) check(name string) error {
+	if l.QPS < 0 || math.IsNaN(l.QPS) || math.IsInf(l.QPS, 0) {
+		return fmt.Errorf("qps %v of the %s must be a non-negative number", l.QPS, name)
+	}
+	if l.Burst < 0 {
+		return fmt.Errorf("burst %d of the %s must not be negative", l.Burst, name)
+	}
+	return nil
+}
+
+// queuedLimiter is a rate limiter with the number of requests waiting for it.
+type queuedLimiter struct {
+	limiter *rate.Limiter
+	queued  atomic.Int32
+}
+
+// newQueuedLimiter returns the limiter of the rate limit, nil if it doesn't limit the requests.
+func newQueuedLimiter(limit RateLimit) *queuedLimiter {
+	if limit.QPS == 0 {
+		return nil
+	}
+	burst := limit.Burst
+	if burst == 0 {
+		burst = max(int(math.Ceil(limit.QPS)), 1)
+	}
+	return &queuedLimiter{limiter: rate.NewLimiter(rate.Limit(limit.QPS), burst)}
+}
+
+// loadShedder admits the requests within the rate limits, and sheds the others.
+type loadShedder struct {
+	mutex     sync.RWMutex
+	limits    RateLimits
+	global    *queuedLimiter
+	resources map[string]*queuedLimiter
+}
+
+var loadShedding = &loadShedder{}
+
+// ConfigureRateLimits makes handlers admit requests within the limits, see RateLimits. Without limits, requests aren't
+// limited.
+func ConfigureRateLimits(limits RateLimits) {
+	loadShedding.configure(limits)
+}
+
+// configure replaces the limits, and the limiters with new ones admitting a full burst.
+func (s *loadShedder) configure(limits RateLimits) {
+	resources := make(map[string]*queuedLimiter, len(limits.Resources))
+	for resource, limit := range limits.Resources {
+		resources[resource] = newQueuedLimiter(limit)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.limits = limits
+	s.global = newQueuedLimiter(limits.RateLimit)
+	s.resources = resources
+}
+
+// admit waits for the turn of the request under the global limit and the limit of its resource, and returns nil once
+// the handler may review it. It returns the response of the request if it's shed, because the queue of a limit is full
+// or because it would wait longer than the max wait. The reviews the handler will create for a queued request are
+// prefetched while it waits, see ReviewPrefetcher.
+func (s *loadShedder) admit(ctx context.Context, webhook string, handler WebhookHandler, request *Request) *admissionv1.AdmissionResponse {
+	s.mutex.RLock()
+	limits := s.limits
+	resource := schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String()
+	limiters := make([]*queuedLimiter, 0, 2)
+	for _, limiter := range []*queuedLimiter{s.global, s.resources[resource]} {
+		if limiter != nil {
+			limiters = append(limiters, limiter)
+		}
+	}
+	s.mutex.RUnlock()
+	if len(limiters) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	reservations := make([]*rate.Reservation, 0, len(limiters))
+	var wait time.Duration
+	shed := false
+	for _, limiter := range limiters {
+		reservation := limiter.limiter.ReserveN(now, 1)
+		reservations = append(reservations, reservation)
+		if !reservation.OK() {
+			shed = true
+			continue
+		}
+		wait = max(wait, reservation.DelayFrom(now))
+	}
+	if wait > limits.MaxWait.Duration {
+		shed = true
+	}
+	if !shed && wait > 0 {
+		queued := make([]*queuedLimiter, 0, len(limiters))
+		for _, limiter := range limiters {
+			if limiter.queued.Add(1) > int32(limits.QueueSize) {
+				shed = true
+			}
+			queued = append(queued, limiter)
+		}
+		if !shed {
+			prefetchReviews(handler, request)
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				shed = true
+			}
+		}
+		for _, limiter := range queued {
+			limiter.queued.Add(-1)
+		}
+	}
+	if !shed {
+		return nil
+	}
+	for _, reservation := range reservations {
+		reservation.CancelAt(now)
+	}
+	return shedResponse(limits, webhook, request, resource, wait)
+}
+
+// shedResponse returns the response of the shed request, which waited or would have waited for its turn.
+func shedResponse(limits RateLimits, webhook string, request *Request, resource string, wait time.Duration) *admissionv1.AdmissionResponse {
+	if limits.Overload == OverloadAllow && webhook == validatingWebhook {
+		shedRequests.WithLabelValues(resource, "allowed").Inc()
+		logrus.Debugf("[rate-limits] allowing %s %s %s without validating it under overload", request.Operation,
+			request.Kind.String(), resourceString(request.Namespace, request.Name))
+		response := ResponseAllowed()
+		response.Warnings = []string{"the webhook is overloaded, the request was allowed without being validated"}
+		return response
+	}
+	shedRequests.WithLabelValues(resource, "denied").Inc()
+	logrus.Debugf("[rate-limits] denying %s %s %s under overload", request.Operation, request.Kind.String(),
+		resourceString(request.Namespace, request.Name))
+	return ResponseRetryAfter(DenialTooManyRequests,
+		fmt.Sprintf("the webhook is overloaded, retry %s %s later", request.Operation, resource), max(wait, time.Second))
+}
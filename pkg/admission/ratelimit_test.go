@@ -0,0 +1,185 @@
+package admission
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		value   string
+		want    RateLimits
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "defaults",
+			value: `{"qps":100}`,
+			want: RateLimits{
+				RateLimit: RateLimit{QPS: 100},
+				QueueSize: DefaultRateLimitQueueSize,
+				MaxWait:   metav1.Duration{Duration: DefaultRateLimitMaxWait},
+				Overload:  OverloadDeny,
+			},
+		},
+		{
+			name:  "all fields",
+			value: `{"qps":100,"burst":200,"resources":{"projects.management.cattle.io":{"qps":5}},"queueSize":10,"maxWait":"500ms","overload":"Allow"}`,
+			want: RateLimits{
+				RateLimit: RateLimit{QPS: 100, Burst: 200},
+				Resources: map[string]RateLimit{"projects.management.cattle.io": {QPS: 5}},
+				QueueSize: 10,
+				MaxWait:   metav1.Duration{Duration: 500 * time.Millisecond},
+				Overload:  OverloadAllow,
+			},
+		},
+		{
+			name:    "invalid JSON",
+			value:   `{"qps":`,
+			wantErr: "unexpected end of JSON input",
+		},
+		{
+			name:    "negative qps",
+			value:   `{"qps":-1}`,
+			wantErr: "qps -1 of the global rate limit must be a non-negative number",
+		},
+		{
+			name:    "negative burst",
+			value:   `{"resources":{"secrets":{"qps":1,"burst":-1}}}`,
+			wantErr: "burst -1 of the rate limit of secrets must not be negative",
+		},
+		{
+			name:    "resource without qps",
+			value:   `{"resources":{"secrets":{"burst":10}}}`,
+			wantErr: "rate limit of secrets has no qps",
+		},
+		{
+			name:    "negative queue size",
+			value:   `{"qps":1,"queueSize":-1}`,
+			wantErr: "queue size -1 must not be negative",
+		},
+		{
+			name:    "max wait too long",
+			value:   `{"qps":1,"maxWait":"10s"}`,
+			wantErr: "max wait 10s must be between 0 and 5s",
+		},
+		{
+			name:    "unknown overload",
+			value:   `{"qps":1,"overload":"Ignore"}`,
+			wantErr: `overload "Ignore" must be Deny or Allow`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseRateLimits(test.value)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestLoadShedder(t *testing.T) {
+	t.Parallel()
+	request := func(resource string) *Request {
+		return &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: resource},
+			Name:      "test",
+		}}
+	}
+	parse := func(value string) RateLimits {
+		limits, err := ParseRateLimits(value)
+		require.NoError(t, err)
+		return limits
+	}
+	ctx := context.Background()
+
+	t.Run("without limits", func(t *testing.T) {
+		t.Parallel()
+		shedder := &loadShedder{}
+		for i := 0; i < 10; i++ {
+			assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request("projects")))
+		}
+	})
+	t.Run("denied over the global limit", func(t *testing.T) {
+		t.Parallel()
+		shedder := &loadShedder{}
+		shedder.configure(parse(`{"qps":0.1,"burst":2}`))
+		assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request("projects")))
+		assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request("clusters")))
+		response := shedder.admit(ctx, validatingWebhook, nil, request("projects"))
+		require.NotNil(t, response)
+		assert.False(t, response.Allowed)
+		assert.Equal(t, int32(http.StatusTooManyRequests), response.Result.Code)
+		assert.Equal(t, metav1.StatusReasonTooManyRequests, response.Result.Reason)
+		assert.Greater(t, response.Result.Details.RetryAfterSeconds, int32(1), "clients retry once a token is available")
+		assert.Equal(t, "the webhook is overloaded, retry UPDATE projects.management.cattle.io later", response.Result.Message)
+	})
+	t.Run("denied over the limit of the resource", func(t *testing.T) {
+		t.Parallel()
+		shedder := &loadShedder{}
+		shedder.configure(parse(`{"resources":{"projects.management.cattle.io":{"qps":0.1}}}`))
+		assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request("projects")))
+		assert.NotNil(t, shedder.admit(ctx, validatingWebhook, nil, request("projects")))
+		assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request("clusters")), "other resources aren't limited")
+	})
+	t.Run("allowed with a warning", func(t *testing.T) {
+		t.Parallel()
+		shedder := &loadShedder{}
+		shedder.configure(parse(`{"qps":0.1,"overload":"Allow"}`))
+		assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request("projects")))
+		response := shedder.admit(ctx, validatingWebhook, nil, request("projects"))
+		require.NotNil(t, response)
+		assert.True(t, response.Allowed)
+		assert.Equal(t, []string{"the webhook is overloaded, the request was allowed without being validated"}, response.Warnings)
+
+		response = shedder.admit(ctx, mutatingWebhook, nil, request("projects"))
+		require.NotNil(t, response)
+		assert.False(t, response.Allowed, "mutating requests are denied, allowing them would skip their mutation")
+	})
+	t.Run("waits within the max wait", func(t *testing.T) {
+		t.Parallel()
+		shedder := &loadShedder{}
+		shedder.configure(parse(`{"qps":20,"burst":1,"maxWait":"1s"}`))
+		assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request("projects")))
+		start := time.Now()
+		assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request("projects")))
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond, "the request waited for its turn")
+	})
+	t.Run("shed when the queue is full", func(t *testing.T) {
+		t.Parallel()
+		shedder := &loadShedder{}
+		shedder.configure(parse(`{"qps":1,"burst":1,"queueSize":1,"maxWait":"5s"}`))
+		assert.Nil(t, shedder.admit(ctx, validatingWebhook, nil, request("projects")))
+		waitCtx, cancel := context.WithCancel(ctx)
+		waited := make(chan *admissionv1.AdmissionResponse)
+		go func() {
+			waited <- shedder.admit(waitCtx, validatingWebhook, nil, request("projects"))
+		}()
+		require.Eventually(t, func() bool { return shedder.global.queued.Load() == 1 }, time.Second, time.Millisecond)
+		response := shedder.admit(ctx, validatingWebhook, nil, request("projects"))
+		require.NotNil(t, response, "the queue is full")
+		assert.False(t, response.Allowed)
+
+		cancel()
+		assert.NotNil(t, <-waited, "requests whose context is done while waiting are shed")
+		assert.Zero(t, shedder.global.queued.Load())
+	})
+}
@@ -0,0 +1,136 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/wrangler/v3/pkg/schemes"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// maxRemovedFields is the number of fields listed by the warning about the fields missing from the compiled types.
+const maxRemovedFields = 10
+
+// removedFieldsGroupVersion is the group version whose objects are checked for fields missing from the compiled types.
+var removedFieldsGroupVersion = schema.GroupVersion{Group: "management.cattle.io", Version: "v3"}
+
+// warnRemovedFields adds a warning to the request listing the fields its object sets or changes that aren't part of
+// the compiled type of its kind, e.g. fields removed from the API that manifests still reference. Admitters decode
+// objects into the compiled types, so they silently ignore these fields. Fields are found by comparing the object with
+// its re-encoding from the compiled type. Zero values are skipped, since the re-encoding omits empty fields.
+func warnRemovedFields(request *Request) {
+	if (request.Operation != admissionv1.Create && request.Operation != admissionv1.Update) || len(request.Object.Raw) == 0 {
+		return
+	}
+	gvk := schema.GroupVersionKind(request.Kind)
+	if gvk.GroupVersion() != removedFieldsGroupVersion || !schemes.All.Recognizes(gvk) {
+		return
+	}
+	var content map[string]any
+	if err := json.Unmarshal(request.Object.Raw, &content); err != nil {
+		return
+	}
+	obj, err := schemes.All.New(gvk)
+	if err != nil {
+		return
+	}
+	// objects that don't decode are denied by their admitters
+	if err := json.Unmarshal(request.Object.Raw, obj); err != nil {
+		return
+	}
+	encoded, err := json.Marshal(obj)
+	if err != nil {
+		return
+	}
+	var typed map[string]any
+	if err := json.Unmarshal(encoded, &typed); err != nil {
+		return
+	}
+	var oldContent map[string]any
+	if request.Operation == admissionv1.Update {
+		_ = json.Unmarshal(request.OldObject.Raw, &oldContent)
+	}
+
+	fields := removedFields(content, typed, oldContent, "")
+	if len(fields) == 0 {
+		return
+	}
+	if len(fields) > maxRemovedFields {
+		fields = append(fields[:maxRemovedFields], fmt.Sprintf("and %d more", len(fields)-maxRemovedFields))
+	}
+	request.warnings = append(request.warnings, fmt.Sprintf("these fields aren't part of the %s %s schema and are ignored, they may have been removed from the API: %s",
+		gvk.GroupVersion().String(), gvk.Kind, strings.Join(fields, ", ")))
+}
+
+// removedFields returns the paths of the fields of the content missing from the typed re-encoding of the content,
+// except those with zero values or unchanged from the old content.
+func removedFields(content, typed, oldContent any, path string) []string {
+	var fields []string
+	switch value := content.(type) {
+	case map[string]any:
+		typedMap, _ := typed.(map[string]any)
+		oldMap, _ := oldContent.(map[string]any)
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child, oldChild := value[key], oldMap[key]
+			typedChild, ok := typedMap[key]
+			if !ok {
+				if !isZeroJSON(child) && !reflect.DeepEqual(child, oldChild) {
+					fields = append(fields, joinFieldPath(path, key))
+				}
+				continue
+			}
+			fields = append(fields, removedFields(child, typedChild, oldChild, joinFieldPath(path, key))...)
+		}
+	case []any:
+		typedList, _ := typed.([]any)
+		oldList, _ := oldContent.([]any)
+		for i, element := range value {
+			if i >= len(typedList) {
+				break
+			}
+			var oldElement any
+			if i < len(oldList) {
+				oldElement = oldList[i]
+			}
+			fields = append(fields, removedFields(element, typedList[i], oldElement, joinFieldPath(path, strconv.Itoa(i)))...)
+		}
+	}
+	return fields
+}
+
+// joinFieldPath appends the key to the dot separated path.
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// isZeroJSON returns true if the decoded JSON value is null, false, 0, an empty string, or an empty object or list.
+func isZeroJSON(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
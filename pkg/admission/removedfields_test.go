@@ -0,0 +1,109 @@
+package admission
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/schemes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TestWarnRemovedFields isn't parallel since it registers the management types in the shared scheme.
+func TestWarnRemovedFields(t *testing.T) {
+	require.NoError(t, schemes.Register(v3.AddToScheme))
+	projectKind := metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"}
+	manyFields := make([]string, 0, 12)
+	for i := 0; i < 12; i++ {
+		manyFields = append(manyFields, fmt.Sprintf(`"removed%02d":"x"`, i))
+	}
+
+	tests := []struct {
+		name         string
+		operation    admissionv1.Operation
+		kind         metav1.GroupVersionKind
+		object       string
+		oldObject    string
+		wantWarnings []string
+	}{
+		{
+			name:      "known fields",
+			operation: admissionv1.Create,
+			kind:      projectKind,
+			object:    `{"metadata":{"name":"p-1","creationTimestamp":null},"spec":{"displayName":"one","clusterName":"c-1"},"status":{}}`,
+		},
+		{
+			name:      "removed fields",
+			operation: admissionv1.Create,
+			kind:      projectKind,
+			object:    `{"metadata":{"name":"p-1"},"spec":{"displayName":"one","enableProjectMonitoring":true,"containerDefaultResourceLimit":{"limitsCpu":"1","limitsGpu":"1"}}}`,
+			wantWarnings: []string{"these fields aren't part of the management.cattle.io/v3 Project schema and are ignored, they may have been removed from the API: " +
+				"spec.containerDefaultResourceLimit.limitsGpu, spec.enableProjectMonitoring"},
+		},
+		{
+			name:      "removed fields in lists",
+			operation: admissionv1.Create,
+			kind:      projectKind,
+			object:    `{"metadata":{"name":"p-1"},"status":{"conditions":[{"type":"Ready","severity":"Info"}]}}`,
+			wantWarnings: []string{"these fields aren't part of the management.cattle.io/v3 Project schema and are ignored, they may have been removed from the API: " +
+				"status.conditions.0.severity"},
+		},
+		{
+			name:      "removed fields with zero values",
+			operation: admissionv1.Create,
+			kind:      projectKind,
+			object:    `{"metadata":{"name":"p-1"},"spec":{"enableProjectMonitoring":false,"podSecurityPolicyTemplateId":""}}`,
+		},
+		{
+			name:      "removed fields unchanged by an update",
+			operation: admissionv1.Update,
+			kind:      projectKind,
+			object:    `{"metadata":{"name":"p-1"},"spec":{"displayName":"two","enableProjectMonitoring":true}}`,
+			oldObject: `{"metadata":{"name":"p-1"},"spec":{"displayName":"one","enableProjectMonitoring":true}}`,
+		},
+		{
+			name:      "many removed fields",
+			operation: admissionv1.Create,
+			kind:      projectKind,
+			object:    `{"metadata":{"name":"p-1"},"spec":{` + strings.Join(manyFields, ",") + `}}`,
+			wantWarnings: []string{"these fields aren't part of the management.cattle.io/v3 Project schema and are ignored, they may have been removed from the API: " +
+				"spec.removed00, spec.removed01, spec.removed02, spec.removed03, spec.removed04, spec.removed05, spec.removed06, spec.removed07, spec.removed08, spec.removed09, and 2 more"},
+		},
+		{
+			name:      "delete",
+			operation: admissionv1.Delete,
+			kind:      projectKind,
+			oldObject: `{"metadata":{"name":"p-1"},"spec":{"enableProjectMonitoring":true}}`,
+		},
+		{
+			name:      "object that doesn't decode",
+			operation: admissionv1.Create,
+			kind:      projectKind,
+			object:    `{"metadata":{"name":"p-1"},"spec":{"displayName":1,"enableProjectMonitoring":true}}`,
+		},
+		{
+			name:      "other group version",
+			operation: admissionv1.Create,
+			kind:      metav1.GroupVersionKind{Group: "", Version: "v1", Kind: "Namespace"},
+			object:    `{"metadata":{"name":"ns-1"},"spec":{"removed":true}}`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: test.operation,
+				Kind:      test.kind,
+				Object:    runtime.RawExtension{Raw: []byte(test.object)},
+				OldObject: runtime.RawExtension{Raw: []byte(test.oldObject)},
+			}}
+			warnRemovedFields(request)
+			assert.Equal(t, test.wantWarnings, request.warnings)
+		})
+	}
+}
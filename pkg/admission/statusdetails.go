@@ -0,0 +1,127 @@
+package admission
+
+import (
+	"regexp"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// fieldErrorTypes are the types of field errors, by the string they are printed as.
+var fieldErrorTypes = map[string]field.ErrorType{}
+
+// fieldErrorStart matches the start of each field error in a message printed from a field.ErrorList, e.g.
+// "spec.displayName: Required value" or ", metadata.labels[a]: Forbidden: ...".
+var fieldErrorStart *regexp.Regexp
+
+func init() {
+	types := []field.ErrorType{
+		field.ErrorTypeNotFound, field.ErrorTypeRequired, field.ErrorTypeDuplicate, field.ErrorTypeInvalid,
+		field.ErrorTypeNotSupported, field.ErrorTypeForbidden, field.ErrorTypeTooLong, field.ErrorTypeTooMany,
+		field.ErrorTypeInternal, field.ErrorTypeTypeInvalid,
+	}
+	names := make([]string, 0, len(types))
+	for _, errorType := range types {
+		if _, ok := fieldErrorTypes[errorType.String()]; ok {
+			continue // FieldValueTypeInvalid is printed like FieldValueInvalid
+		}
+		fieldErrorTypes[errorType.String()] = errorType
+		names = append(names, regexp.QuoteMeta(errorType.String()))
+	}
+	fieldErrorStart = regexp.MustCompile(`(?:^|, )(\S+): (` + strings.Join(names, "|") + `)\b`)
+}
+
+// ResponseDeniedErrors returns an AdmissionResponse denying the request with the status of the code, with the field
+// errors as its message and as the causes of its details.
+func ResponseDeniedErrors(code DenialCode, errs field.ErrorList) *admissionv1.AdmissionResponse {
+	response := ResponseDenied(code, errs.ToAggregate().Error())
+	if response.Result.Details == nil {
+		response.Result.Details = &metav1.StatusDetails{}
+	}
+	response.Result.Details.Causes = StatusCauses(errs)
+	return response
+}
+
+// StatusCauses returns the causes of a status for the field errors, as the API server reports them for invalid
+// objects.
+func StatusCauses(errs field.ErrorList) []metav1.StatusCause {
+	causes := make([]metav1.StatusCause, 0, len(errs))
+	for _, err := range errs {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseType(err.Type),
+			Message: err.ErrorBody(),
+			Field:   err.Field,
+		})
+	}
+	return causes
+}
+
+// populateStatusDetails fills the details of the status of a denial with the identity of the object, and with its
+// causes if the handler didn't set them, so that clients like kubectl can tell which object and fields were denied.
+func populateStatusDetails(request *Request, response *admissionv1.AdmissionResponse) {
+	status := response.Result
+	if status == nil {
+		return
+	}
+	if status.Details == nil {
+		status.Details = &metav1.StatusDetails{}
+	}
+	details := status.Details
+	if details.Group == "" && details.Kind == "" {
+		details.Group = request.Kind.Group
+		details.Kind = request.Kind.Kind
+	}
+	if details.Name == "" {
+		details.Name = request.Name
+	}
+	if details.UID == "" {
+		details.UID = objectUID(request)
+	}
+	if len(details.Causes) == 0 {
+		details.Causes = causesFromMessage(status.Message, status.Reason)
+	}
+}
+
+// objectUID returns the UID of the object of the request, empty if it has none yet or its metadata can't be decoded.
+func objectUID(request *Request) types.UID {
+	oldObj, newObj, err := MetadataFromRequest(request)
+	if err != nil {
+		return ""
+	}
+	if newObj.UID != "" {
+		return newObj.UID
+	}
+	return oldObj.UID
+}
+
+// causesFromMessage returns the causes of a denial with the message, one for each field error if it was printed from
+// a field.ErrorList, or else a single cause with the reason of the status.
+func causesFromMessage(message string, reason metav1.StatusReason) []metav1.StatusCause {
+	if message == "" {
+		return nil
+	}
+	trimmed := message
+	if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+		trimmed = trimmed[1 : len(trimmed)-1]
+	}
+	matches := fieldErrorStart.FindAllStringSubmatchIndex(trimmed, -1)
+	if len(matches) == 0 || matches[0][0] != 0 {
+		return []metav1.StatusCause{{Type: metav1.CauseType(reason), Message: message}}
+	}
+	causes := make([]metav1.StatusCause, 0, len(matches))
+	for i, match := range matches {
+		end := len(trimmed)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseType(fieldErrorTypes[trimmed[match[4]:match[5]]]),
+			Message: trimmed[match[4]:end],
+			Field:   trimmed[match[2]:match[3]],
+		})
+	}
+	return causes
+}
@@ -0,0 +1,108 @@
+package admission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestResponseDeniedErrors(t *testing.T) {
+	t.Parallel()
+	errs := field.ErrorList{
+		field.Required(field.NewPath("spec", "displayName"), "must be set"),
+		field.Invalid(field.NewPath("spec", "replicas"), -1, "must not be negative"),
+	}
+	response := ResponseDeniedErrors(DenialInvalid, errs)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, errs.ToAggregate().Error(), response.Result.Message)
+	assert.Equal(t, metav1.StatusReasonInvalid, response.Result.Reason)
+	assert.Equal(t, []metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldValueRequired, Message: "Required value: must be set", Field: "spec.displayName"},
+		{Type: metav1.CauseTypeFieldValueInvalid, Message: "Invalid value: -1: must not be negative", Field: "spec.replicas"},
+	}, response.Result.Details.Causes)
+}
+
+func TestPopulateStatusDetails(t *testing.T) {
+	t.Parallel()
+	request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"},
+		Namespace: "c-abc12",
+		Name:      "p-xyz34",
+		Operation: admissionv1.Update,
+		Object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"p-xyz34","uid":"1234"}}`)},
+		OldObject: runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"p-xyz34","uid":"1234"}}`)},
+	}}
+
+	response := ResponseDenied(DenialForbidden, "metadata.labels[cattle.io/a]: Forbidden: key is reserved (see /rules/reserved-metadata)")
+	populateStatusDetails(request, response)
+	assert.Equal(t, &metav1.StatusDetails{
+		Group: "management.cattle.io",
+		Kind:  "Project",
+		Name:  "p-xyz34",
+		UID:   "1234",
+		Causes: []metav1.StatusCause{{
+			Type:    metav1.CauseType(field.ErrorTypeForbidden),
+			Message: "Forbidden: key is reserved (see /rules/reserved-metadata)",
+			Field:   "metadata.labels[cattle.io/a]",
+		}},
+	}, response.Result.Details)
+
+	response = ResponseDeniedErrors(DenialInvalid, field.ErrorList{field.Required(field.NewPath("spec"), "")})
+	populateStatusDetails(request, response)
+	assert.Equal(t, "Project", response.Result.Details.Kind)
+	assert.Equal(t, []metav1.StatusCause{{Type: metav1.CauseTypeFieldValueRequired, Message: "Required value", Field: "spec"}},
+		response.Result.Details.Causes, "causes set by the handler are kept")
+
+	response = &admissionv1.AdmissionResponse{}
+	populateStatusDetails(request, response)
+	assert.Nil(t, response.Result, "denials without a status are left alone")
+}
+
+func TestCausesFromMessage(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		message string
+		want    []metav1.StatusCause
+	}{
+		{
+			name:    "single field error",
+			message: `spec.displayName: Invalid value: "": must not be empty`,
+			want: []metav1.StatusCause{
+				{Type: metav1.CauseTypeFieldValueInvalid, Message: `Invalid value: "": must not be empty`, Field: "spec.displayName"},
+			},
+		},
+		{
+			name:    "aggregated field errors",
+			message: "[spec.a: Required value, spec.b: Forbidden: can't be set]",
+			want: []metav1.StatusCause{
+				{Type: metav1.CauseTypeFieldValueRequired, Message: "Required value", Field: "spec.a"},
+				{Type: metav1.CauseType(field.ErrorTypeForbidden), Message: "Forbidden: can't be set", Field: "spec.b"},
+			},
+		},
+		{
+			name:    "free text",
+			message: "namespace is protected",
+			want:    []metav1.StatusCause{{Type: "Forbidden", Message: "namespace is protected"}},
+		},
+		{
+			name:    "free text mentioning a field error",
+			message: "denied because spec.a: Required value",
+			want:    []metav1.StatusCause{{Type: "Forbidden", Message: "denied because spec.a: Required value"}},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			causes := causesFromMessage(test.message, metav1.StatusReasonForbidden)
+			require.Len(t, causes, len(test.want))
+			assert.Equal(t, test.want, causes)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package admission
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// warmUpGate denies requests while the webhook warms up, since the handlers would review them against caches that
+// don't hold every object yet, and deny requests referring to objects they can't find.
+type warmUpGate struct {
+	mutex sync.Mutex
+	check func() error
+	// ready is set once the check succeeds, since the caches don't go back to warming up.
+	ready bool
+}
+
+var warmUp = &warmUpGate{}
+
+// ConfigureWarmUp makes handlers deny requests as Unavailable, telling clients to retry after its delay, until the
+// check succeeds, e.g. until the caches the handlers look objects up in synced. Without a check, requests are reviewed
+// right away.
+func ConfigureWarmUp(check func() error) {
+	warmUp.mutex.Lock()
+	defer warmUp.mutex.Unlock()
+	warmUp.check = check
+	warmUp.ready = false
+}
+
+// deny returns the response denying the request while the webhook warms up, nil once it's ready.
+func (g *warmUpGate) deny(request *Request) *admissionv1.AdmissionResponse {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.ready || g.check == nil {
+		return nil
+	}
+	err := g.check()
+	if err == nil {
+		g.ready = true
+		logrus.Info("[warm-up] the webhook is ready, reviewing requests")
+		return nil
+	}
+	logrus.Debugf("[warm-up] denying %s %s %s while warming up: %v", request.Operation, request.Kind.String(),
+		resourceString(request.Namespace, request.Name), err)
+	return ResponseDenied(DenialUnavailable, fmt.Sprintf("the webhook is warming up, retry after %s: %v", DenialUnavailable.RetryAfter(), err))
+}
+
+// earlyDenial returns the response denying the request before its handler reviews it, because the webhook is warming
+// up or the request conflicts with the identity of its object, nil if the handler must review it.
+func earlyDenial(request *Request) *admissionv1.AdmissionResponse {
+	if response := warmUp.deny(request); response != nil {
+		return response
+	}
+	return identityConflict(request)
+}
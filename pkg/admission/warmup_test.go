@@ -0,0 +1,38 @@
+package admission
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+func TestWarmUpGate(t *testing.T) {
+	t.Parallel()
+	request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Create, Name: "test"}}
+	assert.Nil(t, (&warmUpGate{}).deny(request), "requests are reviewed without a check")
+
+	checkErr := errors.New("the caches of Project haven't synced")
+	checks := 0
+	gate := &warmUpGate{check: func() error {
+		checks++
+		return checkErr
+	}}
+	response := gate.deny(request)
+	require.NotNil(t, response)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, int32(http.StatusServiceUnavailable), response.Result.Code)
+	assert.Equal(t, int32(5), response.Result.Details.RetryAfterSeconds)
+	assert.Contains(t, response.Result.Message, "the webhook is warming up, retry after 5s")
+	assert.Contains(t, response.Result.Message, checkErr.Error())
+
+	// once the check succeeds, it isn't run again
+	checkErr = nil
+	assert.Nil(t, gate.deny(request))
+	checkErr = errors.New("not synced")
+	assert.Nil(t, gate.deny(request))
+	assert.Equal(t, 2, checks)
+}
@@ -0,0 +1,102 @@
+package admission
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const (
+	// maxWarnings is the largest number of warnings returned in a response.
+	maxWarnings = 10
+	// maxWarningsLength is the largest total length of the warnings of a response. The API server ignores warnings
+	// after 4096 characters, from all sources.
+	maxWarningsLength = 2048
+)
+
+// WarningBudget collects the warnings of the admitters contributing to a response, and fits them in the budget of
+// the response: warnings are deduplicated, the ones linking to a rule come first, and the ones that don't fit are
+// suppressed. It is safe for concurrent use.
+type WarningBudget struct {
+	mutex    sync.Mutex
+	warnings []string
+	seen     map[string]bool
+}
+
+// Add adds warnings to the budget, ignoring empty and duplicate warnings.
+func (b *WarningBudget) Add(warnings ...string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, warning := range warnings {
+		if warning == "" || b.seen[warning] {
+			continue
+		}
+		if b.seen == nil {
+			b.seen = map[string]bool{}
+		}
+		b.seen[warning] = true
+		b.warnings = append(b.warnings, warning)
+	}
+}
+
+// All returns all the warnings, by priority. Warnings linking to a rule come first, in the order they were added.
+func (b *WarningBudget) All() []string {
+	b.mutex.Lock()
+	warnings := append([]string(nil), b.warnings...)
+	b.mutex.Unlock()
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return ruleIDPattern.MatchString(warnings[i]) && !ruleIDPattern.MatchString(warnings[j])
+	})
+	return warnings
+}
+
+// Warnings returns the warnings fitting in the budget of a response, by priority. If some were suppressed, the last
+// warning tells how many, and suppressed is true.
+func (b *WarningBudget) Warnings() (warnings []string, suppressed bool) {
+	all := b.All()
+	if len(all) <= maxWarnings && totalLength(all) <= maxWarningsLength {
+		return all, false
+	}
+	// room is kept for the count, whose length is bounded by the one suppressing all the warnings
+	length := len(suppressedCount(len(all)))
+	for _, warning := range all {
+		if len(warnings) == maxWarnings-1 || length+len(warning) > maxWarningsLength {
+			break
+		}
+		warnings = append(warnings, warning)
+		length += len(warning)
+	}
+	return append(warnings, suppressedCount(len(all)-len(warnings))), true
+}
+
+// suppressedCount returns the last warning of a response whose warnings were suppressed.
+func suppressedCount(suppressed int) string {
+	return fmt.Sprintf("%d more warnings suppressed", suppressed)
+}
+
+func totalLength(warnings []string) int {
+	length := 0
+	for _, warning := range warnings {
+		length += len(warning)
+	}
+	return length
+}
+
+// applyWarnings adds the warnings of the response and of the request to the budget, and sets the warnings of the
+// response to the ones fitting in the budget. All the warnings are logged at debug level if some were suppressed.
+func applyWarnings(request *Request, budget *WarningBudget, response *admissionv1.AdmissionResponse) {
+	budget.Add(response.Warnings...)
+	budget.Add(request.warnings...)
+	warnings, suppressed := budget.Warnings()
+	if len(warnings) == 0 {
+		return
+	}
+	response.Warnings = warnings
+	if suppressed {
+		logrus.Debugf("suppressed warnings of %s %s %s: %s", request.Operation, request.Kind.Kind, resourceString(request.Namespace, request.Name), strings.Join(budget.All(), "; "))
+	}
+}
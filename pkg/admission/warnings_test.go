@@ -0,0 +1,65 @@
+package admission
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestWarningBudget(t *testing.T) {
+	t.Parallel()
+	budget := &WarningBudget{}
+	budget.Add("first", "", "spec.field: deprecated (see /rules/example-rule)", "first")
+	warnings, suppressed := budget.Warnings()
+	assert.False(t, suppressed)
+	assert.Equal(t, []string{"spec.field: deprecated (see /rules/example-rule)", "first"}, warnings,
+		"rule warnings must come first and duplicates must be dropped")
+
+	budget = &WarningBudget{}
+	var wg sync.WaitGroup
+	for i := 0; i < 3*maxWarnings; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			budget.Add(fmt.Sprintf("warning %02d", i))
+		}(i)
+	}
+	wg.Wait()
+	warnings, suppressed = budget.Warnings()
+	assert.True(t, suppressed)
+	require.Len(t, warnings, maxWarnings)
+	assert.Equal(t, fmt.Sprintf("%d more warnings suppressed", 2*maxWarnings+1), warnings[maxWarnings-1])
+	assert.Len(t, budget.All(), 3*maxWarnings)
+
+	budget = &WarningBudget{}
+	long := strings.Repeat("x", maxWarningsLength/2)
+	budget.Add(long+"1", long+"2", "short")
+	warnings, suppressed = budget.Warnings()
+	assert.True(t, suppressed)
+	assert.Equal(t, []string{long + "1", "2 more warnings suppressed"}, warnings)
+}
+
+func TestApplyWarnings(t *testing.T) {
+	t.Parallel()
+	request := &Request{AdmissionRequest: admissionv1.AdmissionRequest{UID: types.UID("apply-warnings-uid")}, warnings: []string{"old object relaxed"}}
+	budget := &WarningBudget{}
+	first := ResponseAllowed()
+	first.Warnings = []string{"from the first admitter"}
+	applyWarnings(request, budget, first)
+	last := ResponseAllowed()
+	applyWarnings(request, budget, last)
+	assert.Equal(t, []string{"from the first admitter", "old object relaxed"}, last.Warnings)
+
+	for i := 0; i < maxWarnings; i++ {
+		last.Warnings = append(last.Warnings, fmt.Sprintf("warning %d", i))
+	}
+	applyWarnings(request, budget, last)
+	require.Len(t, last.Warnings, maxWarnings)
+	assert.Equal(t, "3 more warnings suppressed", last.Warnings[maxWarnings-1])
+}
@@ -0,0 +1,246 @@
+package v1
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MaintenanceWindow relaxes a list of the webhook's rules cluster-wide for a limited time, e.g. to let changes through
+// an image allow-list during an incident. The rules only warn about requests breaking them while the window is
+// active, and the window expires on its own at its end.
+type MaintenanceWindow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MaintenanceWindowSpec `json:"spec"`
+}
+
+// MaintenanceWindowSpec is the description of a maintenance window.
+type MaintenanceWindowSpec struct {
+	// Rules are the IDs of the rules relaxed during the window, as listed on the webhook's /rules endpoint.
+	Rules []string `json:"rules"`
+	// Start is when the window starts.
+	Start metav1.Time `json:"start"`
+	// End is when the window ends. The window can't be longer than the maximum duration configured for the webhook.
+	End metav1.Time `json:"end"`
+	// Reason explains why the rules are relaxed.
+	Reason string `json:"reason,omitempty"`
+	// Approver is the user who approved the window.
+	Approver string `json:"approver"`
+}
+
+// ActiveAt returns whether the window is active at the given time.
+func (w *MaintenanceWindow) ActiveAt(now metav1.Time) bool {
+	return !now.Before(&w.Spec.Start) && now.Before(&w.Spec.End)
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TaintPolicy declares the taints the agents of downstream clusters may tolerate, so that the tolerations appended to
+// the cluster and fleet agents can't schedule them on quarantined nodes. The tolerations allowed by all the policies
+// are combined. Agents can tolerate any taint while no policy exists.
+type TaintPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TaintPolicySpec `json:"spec"`
+}
+
+// TaintPolicySpec is the description of a taint policy.
+type TaintPolicySpec struct {
+	// AllowedTolerations are the tolerations agents may declare. An agent's toleration is allowed if every taint it
+	// tolerates is tolerated by one of them: an allowed toleration with the Exists operator allows any value of its key,
+	// and one without an effect allows all effects.
+	AllowedTolerations []corev1.Toleration `json:"allowedTolerations"`
+}
+
+// Allows returns whether every taint tolerated by the toleration is tolerated by one of the allowed tolerations.
+func (p *TaintPolicySpec) Allows(toleration corev1.Toleration) bool {
+	for _, allowed := range p.AllowedTolerations {
+		if covers(allowed, toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers returns whether every taint tolerated by toleration is tolerated by allowed.
+func covers(allowed, toleration corev1.Toleration) bool {
+	// an empty key only tolerates all taints with the Exists operator, which must then be allowed for all keys
+	if allowed.Key != "" && allowed.Key != toleration.Key {
+		return false
+	}
+	// an empty effect tolerates all effects
+	if allowed.Effect != "" && allowed.Effect != toleration.Effect {
+		return false
+	}
+	if allowed.Operator == corev1.TolerationOpExists {
+		return true
+	}
+	// the operator defaults to Equal
+	return toleration.Operator != corev1.TolerationOpExists && allowed.Value == toleration.Value
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DeniedObject retains the redacted object of a denied create for a short time, so that the user who submitted it
+// can recover the manifest, e.g. the input of a UI form, instead of losing it. Denied objects are only written for the
+// resources selected by the webhook-log-denied-objects setting, and deleted once they expire.
+type DeniedObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DeniedObjectSpec `json:"spec"`
+}
+
+// DeniedObjectSpec is the description of a denied object.
+type DeniedObjectSpec struct {
+	// Resource is the resource of the object in the resource.group form, e.g. projects.management.cattle.io.
+	Resource string `json:"resource"`
+	// Namespace is the namespace of the object, empty for cluster scoped objects.
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the object, empty if the server was to generate it.
+	Name string `json:"name,omitempty"`
+	// User is the name of the user whose create was denied.
+	User string `json:"user"`
+	// Message is why the create was denied.
+	Message string `json:"message"`
+	// Object is the submitted object as JSON, with its sensitive fields redacted, truncated if it's too large.
+	Object string `json:"object"`
+	// Expires is when the denied object is deleted.
+	Expires metav1.Time `json:"expires"`
+}
+
+// ClusterTierLabel is the label of management clusters naming their tier, e.g. free, which selects the
+// ClusterTierPolicy objects restricting the quotas of their projects. The label is reserved to Rancher, so cluster
+// owners can't change the tier of their cluster.
+const ClusterTierLabel = "management.cattle.io/cluster-tier"
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterTierPolicy restricts the resources the projects of the clusters of a tier may set in their quotas, e.g. to
+// keep load balancers off a free tier. The resources allowed by all the policies of a tier are combined. Projects of
+// clusters without a tier, or of a tier without policies, may set any resource.
+type ClusterTierPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClusterTierPolicySpec `json:"spec"`
+}
+
+// ClusterTierPolicySpec is the description of a cluster tier policy.
+type ClusterTierPolicySpec struct {
+	// Tier is the value of the management.cattle.io/cluster-tier label of the clusters the policy applies to.
+	Tier string `json:"tier"`
+	// AllowedQuotaResources are the resources the projects of the tier's clusters may set in spec.resourceQuota and
+	// spec.namespaceDefaultResourceQuota, named like the fields of their limit, e.g. limitsCpu or servicesLoadBalancers.
+	AllowedQuotaResources []string `json:"allowedQuotaResources"`
+}
+
+// AllowsQuotaResource returns whether the policy allows projects to set the resource in their quotas.
+func (p *ClusterTierPolicySpec) AllowsQuotaResource(resource string) bool {
+	for _, allowed := range p.AllowedQuotaResources {
+		if allowed == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CustomPolicy is an additional validation rule written by admins as a CEL expression, e.g. to require a cost-center
+// annotation on all clusters, without forking the webhook. Policies are evaluated after the built-in checks of the
+// resources the webhook validates, and deny the requests for which their expression is false.
+type CustomPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CustomPolicySpec `json:"spec"`
+}
+
+// CustomPolicySpec is the description of a custom policy.
+type CustomPolicySpec struct {
+	// Resources are the resources the policy applies to in the resource.group form, e.g. clusters.management.cattle.io,
+	// or the resource name alone for core resources, e.g. namespaces.
+	Resources []string `json:"resources"`
+	// Operations are the operations the policy applies to: CREATE, UPDATE or DELETE. Empty applies to CREATE and
+	// UPDATE.
+	Operations []string `json:"operations,omitempty"`
+	// Expression is the CEL expression requests must evaluate to true for, given the object, the oldObject, null on
+	// create, and the request with its operation, namespace, name and userInfo.
+	Expression string `json:"expression"`
+	// Message is the message of the denial of the requests the expression is false for.
+	Message string `json:"message"`
+}
+
+// AppliesTo returns whether the policy applies to the operation on the resource, in the resource.group form.
+func (p *CustomPolicySpec) AppliesTo(resource, operation string) bool {
+	if !slices.Contains(p.Resources, resource) {
+		return false
+	}
+	if len(p.Operations) == 0 {
+		return operation == "CREATE" || operation == "UPDATE"
+	}
+	return slices.Contains(p.Operations, operation)
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PolicyExceptionRequest asks for an exception to a rule which denied a request of its requester on an object. The
+// requester creates it from the correlation ID of the denial, and once another user allowed to approve exceptions
+// approves it, the rule only warns about the requester's requests on the object until the exception expires.
+type PolicyExceptionRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PolicyExceptionRequestSpec `json:"spec"`
+}
+
+// PolicyExceptionRequestSpec is the description of a policy exception request.
+type PolicyExceptionRequestSpec struct {
+	// CorrelationID is the UID of the denied request, as given in the warning of its denial and recorded by the
+	// audit log.
+	CorrelationID string `json:"correlationID"`
+	// Rule is the ID of the rule which denied the request, as listed on the webhook's /rules endpoint.
+	Rule string `json:"rule"`
+	// Resource is the resource of the object in the resource.group form, e.g. clusters.management.cattle.io, or the
+	// resource name alone for core resources, e.g. namespaces.
+	Resource string `json:"resource"`
+	// Namespace is the namespace of the object, empty for cluster scoped objects.
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the object.
+	Name string `json:"name"`
+	// Requester is the user who requested the exception, whose requests are excepted from the rule.
+	Requester string `json:"requester"`
+	// Reason explains why the exception is needed.
+	Reason string `json:"reason"`
+	// Expires is when the exception expires.
+	Expires metav1.Time `json:"expires"`
+	// Approver is the user who approved the exception, empty while it's pending.
+	Approver string `json:"approver,omitempty"`
+}
+
+// Excepts returns whether the exception is approved and unexpired at the given time, and applies to the rule for the
+// user's requests on the object of the resource, in the resource.group form.
+func (r *PolicyExceptionRequest) Excepts(rule, resource, namespace, name, user string, now metav1.Time) bool {
+	spec := &r.Spec
+	return spec.Approver != "" && now.Before(&spec.Expires) && spec.Rule == rule && spec.Resource == resource &&
+		spec.Namespace == namespace && spec.Name == name && spec.Requester == user
+}
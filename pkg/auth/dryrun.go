@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/kubernetes/pkg/registry/rbac/validation"
+	"k8s.io/kubernetes/plugin/pkg/auth/authorizer/rbac"
+)
+
+var dryRunReviews = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "rancher_webhook",
+	Name:      "dry_run_local_reviews_total",
+	Help:      "Number of SubjectAccessReviews of dry run requests answered from the RBAC rules in the webhook's caches rather than created.",
+})
+
+func init() {
+	prometheus.MustRegister(dryRunReviews)
+}
+
+// dryRunSubjectAccessReviews answers the SubjectAccessReviews of dry run requests that the RBAC rules allow without
+// creating them.
+type dryRunSubjectAccessReviews struct {
+	authorizationv1.SubjectAccessReviewInterface
+	resolver validation.AuthorizationRuleResolver
+}
+
+// NewDryRunSubjectAccessReviews returns a SubjectAccessReview client which doesn't create the reviews of dry run
+// requests the RBAC rules found by the resolver allow, so that dry runs don't add load on the API server or entries to
+// its audit log. Other reviews are still created, since the API server may allow them through other authorizers, so
+// that dry runs always get the same decision.
+func NewDryRunSubjectAccessReviews(client authorizationv1.SubjectAccessReviewInterface, resolver validation.AuthorizationRuleResolver) authorizationv1.SubjectAccessReviewInterface {
+	if resolver == nil {
+		return client
+	}
+	return &dryRunSubjectAccessReviews{SubjectAccessReviewInterface: client, resolver: resolver}
+}
+
+// Create returns the review allowed by the RBAC rules for dry run requests, and creates it otherwise.
+func (d *dryRunSubjectAccessReviews) Create(ctx context.Context, review *v1.SubjectAccessReview, opts metav1.CreateOptions) (*v1.SubjectAccessReview, error) {
+	if admission.IsDryRunContext(ctx) && allowedByRules(ctx, d.resolver, &review.Spec) {
+		dryRunReviews.Inc()
+		result := review.DeepCopy()
+		result.Status = v1.SubjectAccessReviewStatus{Allowed: true, Reason: "allowed by the RBAC rules of a dry run request"}
+		return result, nil
+	}
+	return d.SubjectAccessReviewInterface.Create(ctx, review, opts)
+}
+
+// allowedByRules returns true if the RBAC rules found by the resolver for the user of the review allow its resource
+// attributes.
+func allowedByRules(ctx context.Context, resolver validation.AuthorizationRuleResolver, spec *v1.SubjectAccessReviewSpec) bool {
+	resource := spec.ResourceAttributes
+	if resource == nil {
+		return false
+	}
+	extra := make(map[string][]string, len(spec.Extra))
+	for k, v := range spec.Extra {
+		extra[k] = v
+	}
+	userInfo := &user.DefaultInfo{Name: spec.User, UID: spec.UID, Groups: spec.Groups, Extra: extra}
+	// rules which couldn't be resolved are left out, so they don't allow the review
+	rules, err := resolver.RulesFor(ctx, userInfo, resource.Namespace)
+	if err != nil {
+		logrus.Debugf("[dry-run] failed to resolve some rules of user %s: %v", spec.User, err)
+	}
+	return rbac.RulesAllow(authorizer.AttributesRecord{
+		User:            userInfo,
+		Verb:            resource.Verb,
+		Namespace:       resource.Namespace,
+		APIGroup:        resource.Group,
+		APIVersion:      resource.Version,
+		Resource:        resource.Resource,
+		Subresource:     resource.Subresource,
+		Name:            resource.Name,
+		ResourceRequest: true,
+	}, rules...)
+}
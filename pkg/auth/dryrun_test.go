@@ -0,0 +1,111 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+	"k8s.io/kubernetes/pkg/registry/rbac/validation"
+)
+
+func TestDryRunSubjectAccessReviews(t *testing.T) {
+	t.Parallel()
+	clusterRoles := []*rbacv1.ClusterRole{{
+		ObjectMeta: metav1.ObjectMeta{Name: "project-manager"},
+		Rules: []rbacv1.PolicyRule{{
+			Verbs:     []string{"manage-namespaces"},
+			APIGroups: []string{"management.cattle.io"},
+			Resources: []string{"projects"},
+		}},
+	}}
+	clusterRoleBindings := []*rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-manager"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "u-manager"}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "project-manager"},
+	}}
+	resolver, _ := validation.NewTestRuleResolver(nil, nil, clusterRoles, clusterRoleBindings)
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		user        string
+		verb        string
+		wantAllowed bool
+		wantCreated bool
+	}{
+		{
+			name:        "dry run allowed by the rules isn't created",
+			dryRun:      true,
+			user:        "u-manager",
+			verb:        "manage-namespaces",
+			wantAllowed: true,
+		},
+		{
+			name:        "dry run not allowed by the rules is created",
+			dryRun:      true,
+			user:        "u-manager",
+			verb:        "updatepsa",
+			wantAllowed: true,
+			wantCreated: true,
+		},
+		{
+			name:        "dry run of another user is created",
+			dryRun:      true,
+			user:        "u-other",
+			verb:        "manage-namespaces",
+			wantCreated: true,
+		},
+		{
+			name:        "request allowed by the rules is created",
+			user:        "u-manager",
+			verb:        "manage-namespaces",
+			wantAllowed: true,
+			wantCreated: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			created := false
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				created = true
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				// the API server allows the other verbs through another authorizer
+				review.Status.Allowed = review.Spec.User == "u-manager"
+				return true, review, nil
+			})
+			sar := auth.NewDryRunSubjectAccessReviews((&k8fake.FakeAuthorizationV1{Fake: k8Fake}).SubjectAccessReviews(), resolver)
+
+			ctx := context.Background()
+			if test.dryRun {
+				ctx = admission.WithDryRun(ctx)
+			}
+			review, err := sar.Create(ctx, &authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Verb:     test.verb,
+						Group:    "management.cattle.io",
+						Version:  "v3",
+						Resource: "projects",
+						Name:     "p-abc12",
+					},
+					User: test.user,
+				},
+			}, metav1.CreateOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, review.Status.Allowed)
+			assert.Equal(t, test.wantCreated, created)
+		})
+	}
+}
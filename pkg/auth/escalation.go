@@ -7,13 +7,13 @@ import (
 	"net/http"
 
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
 	admissionv1 "k8s.io/api/admission/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
 	v1 "k8s.io/api/authorization/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apiserver/pkg/authentication/user"
 	k8srequest "k8s.io/apiserver/pkg/endpoints/request"
 	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/kubernetes/pkg/registry/rbac/validation"
@@ -21,44 +21,42 @@ import (
 
 // RequestUserHasVerb checks if the user associated with the context has a given verb on a given gvr for a specified name/namespace
 func RequestUserHasVerb(request *admission.Request, gvr schema.GroupVersionResource, sar authorizationv1.SubjectAccessReviewInterface, verb, name, namespace string) (bool, error) {
+	resp, err := sar.Create(request.Context, requesterReview(request, v1.ResourceAttributes{
+		Verb:      verb,
+		Namespace: namespace,
+		Version:   gvr.Version,
+		Resource:  gvr.Resource,
+		Group:     gvr.Group,
+		Name:      name,
+	}), metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to checkout create sar request: %w", err)
+	}
+
+	return resp.Status.Allowed, nil
+}
+
+// requesterReview returns the review of the user of the request on the given resource attributes.
+func requesterReview(request *admission.Request, attributes v1.ResourceAttributes) *v1.SubjectAccessReview {
 	extras := map[string]v1.ExtraValue{}
 	for k, v := range request.UserInfo.Extra {
 		extras[k] = v1.ExtraValue(v)
 	}
-
-	resp, err := sar.Create(request.Context, &v1.SubjectAccessReview{
+	return &v1.SubjectAccessReview{
 		Spec: v1.SubjectAccessReviewSpec{
-			ResourceAttributes: &v1.ResourceAttributes{
-				Verb:      verb,
-				Namespace: namespace,
-				Version:   gvr.Version,
-				Resource:  gvr.Resource,
-				Group:     gvr.Group,
-				Name:      name,
-			},
-			User:   request.UserInfo.Username,
-			Groups: request.UserInfo.Groups,
-			Extra:  extras,
-			UID:    request.UserInfo.UID,
+			ResourceAttributes: &attributes,
+			User:               request.UserInfo.Username,
+			Groups:             request.UserInfo.Groups,
+			Extra:              extras,
+			UID:                request.UserInfo.UID,
 		},
-	}, metav1.CreateOptions{})
-	if err != nil {
-		return false, fmt.Errorf("failed to checkout create sar request: %w", err)
 	}
-
-	return resp.Status.Allowed, nil
 }
 
 // ConfirmNoEscalation checks that the user attempting to create a binding/role has all the permissions they are attempting
 // to grant.
 func ConfirmNoEscalation(request *admission.Request, rules []rbacv1.PolicyRule, namespace string, ruleResolver validation.AuthorizationRuleResolver) error {
-	userInfo := &user.DefaultInfo{
-		Name:   request.UserInfo.Username,
-		UID:    request.UserInfo.UID,
-		Groups: request.UserInfo.Groups,
-		Extra:  ToExtraString(request.UserInfo.Extra),
-	}
-
+	userInfo := identity.FromUserInfo(request.UserInfo).Info()
 	globalCtx := k8srequest.WithNamespace(k8srequest.WithUser(context.Background(), userInfo), namespace)
 
 	return validation.ConfirmNoEscalation(globalCtx, ruleResolver, rules)
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"context"
+
+	v1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/kubernetes/pkg/registry/rbac/validation"
+)
+
+// rbacSubjectAccessReviews answers SubjectAccessReviews from RBAC rules without creating them.
+type rbacSubjectAccessReviews struct {
+	resolver validation.AuthorizationRuleResolver
+}
+
+// NewRBACSubjectAccessReviews returns a SubjectAccessReview client which answers the reviews from the RBAC rules found
+// by the resolver, without an API server. Reviews the rules don't allow are denied, since no other authorizer is asked,
+// so it's meant for running the handlers without a cluster.
+func NewRBACSubjectAccessReviews(resolver validation.AuthorizationRuleResolver) authorizationv1.SubjectAccessReviewInterface {
+	return &rbacSubjectAccessReviews{resolver: resolver}
+}
+
+// Create returns the review, allowed if the RBAC rules allow it.
+func (r *rbacSubjectAccessReviews) Create(ctx context.Context, review *v1.SubjectAccessReview, _ metav1.CreateOptions) (*v1.SubjectAccessReview, error) {
+	result := review.DeepCopy()
+	if allowedByRules(ctx, r.resolver, &review.Spec) {
+		result.Status = v1.SubjectAccessReviewStatus{Allowed: true, Reason: "allowed by the RBAC rules"}
+	} else {
+		result.Status = v1.SubjectAccessReviewStatus{Reason: "not allowed by the RBAC rules"}
+	}
+	return result, nil
+}
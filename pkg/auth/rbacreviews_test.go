@@ -0,0 +1,63 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/registry/rbac/validation"
+)
+
+func TestRBACSubjectAccessReviews(t *testing.T) {
+	t.Parallel()
+	clusterRoles := []*rbacv1.ClusterRole{{
+		ObjectMeta: metav1.ObjectMeta{Name: "project-manager"},
+		Rules: []rbacv1.PolicyRule{{
+			Verbs:     []string{"manage-namespaces"},
+			APIGroups: []string{"management.cattle.io"},
+			Resources: []string{"projects"},
+		}},
+	}}
+	clusterRoleBindings := []*rbacv1.ClusterRoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-manager"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "u-manager"}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "project-manager"},
+	}}
+	resolver, _ := validation.NewTestRuleResolver(nil, nil, clusterRoles, clusterRoleBindings)
+	reviews := auth.NewRBACSubjectAccessReviews(resolver)
+
+	tests := []struct {
+		name        string
+		user        string
+		verb        string
+		wantAllowed bool
+	}{
+		{name: "allowed by the rules", user: "u-manager", verb: "manage-namespaces", wantAllowed: true},
+		{name: "other verb", user: "u-manager", verb: "updatepsa"},
+		{name: "other user", user: "u-other", verb: "manage-namespaces"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			review, err := reviews.Create(context.Background(), &authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					User: test.user,
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Verb:     test.verb,
+						Group:    "management.cattle.io",
+						Resource: "projects",
+						Name:     "p-abc",
+					},
+				},
+			}, metav1.CreateOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, review.Status.Allowed)
+		})
+	}
+}
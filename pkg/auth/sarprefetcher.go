@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rancher/webhook/pkg/admission"
+	v1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+const (
+	// SARPrefetchConcurrencyEnvKey is the environment variable holding the number of SubjectAccessReviews the webhook
+	// prefetches at once, see SubjectAccessReviewPrefetcher.
+	SARPrefetchConcurrencyEnvKey = "CATTLE_WEBHOOK_SAR_PREFETCH_CONCURRENCY"
+	// DefaultSARPrefetchConcurrency is the number of SubjectAccessReviews prefetched at once without
+	// SARPrefetchConcurrencyEnvKey.
+	DefaultSARPrefetchConcurrency = 10
+	// prefetchedReviewTTL bounds how long a prefetched review is kept for its request: the longest wait under the rate
+	// limits, 5s, with time left for the admitters to reach the review.
+	prefetchedReviewTTL = 10 * time.Second
+)
+
+var prefetchedReviews = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rancher_webhook",
+	Name:      "sar_prefetches_total",
+	Help:      "Number of SubjectAccessReviews of queued requests prefetched or skipped, by result: used, unused, or skipped when the prefetch concurrency limit was reached.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(prefetchedReviews)
+}
+
+// SARPrefetchConcurrencyFromEnv returns the number of SubjectAccessReviews prefetched at once configured by
+// SARPrefetchConcurrencyEnvKey.
+func SARPrefetchConcurrencyFromEnv() (int, error) {
+	concurrency, err := ParseSARPrefetchConcurrency(os.Getenv(SARPrefetchConcurrencyEnvKey))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode %s: %w", SARPrefetchConcurrencyEnvKey, err)
+	}
+	return concurrency, nil
+}
+
+// ParseSARPrefetchConcurrency parses the number of SubjectAccessReviews prefetched at once,
+// DefaultSARPrefetchConcurrency if it's empty.
+func ParseSARPrefetchConcurrency(value string) (int, error) {
+	if value == "" {
+		return DefaultSARPrefetchConcurrency, nil
+	}
+	concurrency, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if concurrency < 1 {
+		return 0, fmt.Errorf("concurrency %d must be positive", concurrency)
+	}
+	return concurrency, nil
+}
+
+// SubjectAccessReviewPrefetcher creates the SubjectAccessReviews of the requests queued under the rate limits before
+// their turn, and answers the reviews of the admitted requests with them. Only the prefetches are bounded, so that
+// binding storms don't flood the API server with them; the reviews of admitted requests are created right away.
+type SubjectAccessReviewPrefetcher struct {
+	authorizationv1.SubjectAccessReviewInterface
+	slots chan struct{}
+
+	mutex      sync.Mutex
+	prefetched map[string]*prefetchedReview
+}
+
+// prefetchedReview is the result of a review created before the request needing it was admitted.
+type prefetchedReview struct {
+	done    chan struct{}
+	review  *v1.SubjectAccessReview
+	err     error
+	expires time.Time
+}
+
+// NewSubjectAccessReviewPrefetcher returns a SubjectAccessReview client prefetching at most concurrency reviews at once
+// through the given client.
+func NewSubjectAccessReviewPrefetcher(client authorizationv1.SubjectAccessReviewInterface, concurrency int) *SubjectAccessReviewPrefetcher {
+	return &SubjectAccessReviewPrefetcher{
+		SubjectAccessReviewInterface: client,
+		slots:                        make(chan struct{}, max(concurrency, 1)),
+		prefetched:                   map[string]*prefetchedReview{},
+	}
+}
+
+// Create returns the prefetched result of the review if there is one, and creates the review otherwise.
+func (l *SubjectAccessReviewPrefetcher) Create(ctx context.Context, review *v1.SubjectAccessReview, opts metav1.CreateOptions) (*v1.SubjectAccessReview, error) {
+	if result, ok := l.takePrefetched(ctx, review); ok {
+		return result, nil
+	}
+	return l.SubjectAccessReviewInterface.Create(ctx, review, opts)
+}
+
+// PrefetchRequest creates the reviews of the requester with the given resource attributes in the background, so that
+// the admitters of the request get their results without waiting for the API server. It's configured with
+// admission.ConfigurePrefetch to run while the request waits for its turn under the rate limits. Reviews of dry run
+// requests aren't prefetched, since they may be answered without creating them, see NewDryRunSubjectAccessReviews.
+func (l *SubjectAccessReviewPrefetcher) PrefetchRequest(request *admission.Request, attributes []v1.ResourceAttributes) {
+	if request.IsDryRun() {
+		return
+	}
+	for i := range attributes {
+		l.prefetch(request.Context, requesterReview(request, attributes[i]))
+	}
+}
+
+// prefetch creates the review in the background, unless it's already being prefetched. It's skipped when the
+// concurrency limit of the prefetches is reached.
+func (l *SubjectAccessReviewPrefetcher) prefetch(ctx context.Context, review *v1.SubjectAccessReview) {
+	key, err := reviewKey(review)
+	if err != nil {
+		return
+	}
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		prefetchedReviews.WithLabelValues("skipped").Inc()
+		return
+	}
+
+	now := time.Now()
+	l.mutex.Lock()
+	l.expire(now)
+	if _, ok := l.prefetched[key]; ok {
+		l.mutex.Unlock()
+		<-l.slots
+		return
+	}
+	entry := &prefetchedReview{done: make(chan struct{}), expires: now.Add(prefetchedReviewTTL)}
+	l.prefetched[key] = entry
+	l.mutex.Unlock()
+
+	go func() {
+		defer func() { <-l.slots }()
+		entry.review, entry.err = l.SubjectAccessReviewInterface.Create(ctx, review, metav1.CreateOptions{})
+		close(entry.done)
+	}()
+}
+
+// takePrefetched returns the result of the prefetched review, waiting for it if it's still being created. False is
+// returned if the review wasn't prefetched, expired, or failed, so that the caller creates it.
+func (l *SubjectAccessReviewPrefetcher) takePrefetched(ctx context.Context, review *v1.SubjectAccessReview) (*v1.SubjectAccessReview, bool) {
+	key, err := reviewKey(review)
+	if err != nil {
+		return nil, false
+	}
+	l.mutex.Lock()
+	entry, ok := l.prefetched[key]
+	delete(l.prefetched, key)
+	l.mutex.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return nil, false
+	}
+	if entry.err != nil {
+		return nil, false
+	}
+	prefetchedReviews.WithLabelValues("used").Inc()
+	return entry.review.DeepCopy(), true
+}
+
+// expire drops the prefetched reviews no request took in time. The mutex must be held.
+func (l *SubjectAccessReviewPrefetcher) expire(now time.Time) {
+	for key, entry := range l.prefetched {
+		if now.After(entry.expires) {
+			delete(l.prefetched, key)
+			prefetchedReviews.WithLabelValues("unused").Inc()
+		}
+	}
+}
+
+// reviewKey identifies the reviews with the same spec, which get the same result.
+func reviewKey(review *v1.SubjectAccessReview) (string, error) {
+	key, err := json.Marshal(&review.Spec)
+	return string(key), err
+}
@@ -0,0 +1,172 @@
+package auth_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+func TestParseSARPrefetchConcurrency(t *testing.T) {
+	t.Parallel()
+	concurrency, err := auth.ParseSARPrefetchConcurrency("")
+	require.NoError(t, err)
+	assert.Equal(t, auth.DefaultSARPrefetchConcurrency, concurrency)
+	concurrency, err = auth.ParseSARPrefetchConcurrency("5")
+	require.NoError(t, err)
+	assert.Equal(t, 5, concurrency)
+	for _, value := range []string{"0", "-1", "many"} {
+		_, err := auth.ParseSARPrefetchConcurrency(value)
+		assert.Error(t, err, value)
+	}
+}
+
+// countingReviews is a SubjectAccessReview client allowing the reviews once release is closed, counting the reviews
+// created and the most created at once.
+type countingReviews struct {
+	authorizationclient.SubjectAccessReviewInterface
+	release                      chan struct{}
+	created, running, maxRunning atomic.Int32
+}
+
+func newCountingReviews() *countingReviews {
+	return &countingReviews{release: make(chan struct{})}
+}
+
+func (c *countingReviews) Create(_ context.Context, review *authorizationv1.SubjectAccessReview, _ metav1.CreateOptions) (*authorizationv1.SubjectAccessReview, error) {
+	c.created.Add(1)
+	current := c.running.Add(1)
+	defer c.running.Add(-1)
+	for previous := c.maxRunning.Load(); current > previous && !c.maxRunning.CompareAndSwap(previous, current); previous = c.maxRunning.Load() {
+	}
+	<-c.release
+	result := review.DeepCopy()
+	result.Status.Allowed = true
+	return result, nil
+}
+
+func TestSubjectAccessReviewPrefetcherDoesNotBoundReviews(t *testing.T) {
+	t.Parallel()
+	client := newCountingReviews()
+	prefetcher := auth.NewSubjectAccessReviewPrefetcher(client, 2)
+	request := &admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{UserInfo: authenticationv1.UserInfo{Username: "u-abc12"}},
+		Context:          context.Background(),
+	}
+	gvr := schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"}
+
+	done := make(chan bool)
+	for i := 0; i < 5; i++ {
+		go func() {
+			allowed, err := auth.RequestUserHasVerb(request, gvr, prefetcher, "bind", "admin", "")
+			assert.NoError(t, err)
+			done <- allowed
+		}()
+	}
+	require.Eventually(t, func() bool { return client.created.Load() == 5 }, time.Second, time.Millisecond, "reviews of admitted requests aren't throttled")
+	close(client.release)
+	for i := 0; i < 5; i++ {
+		assert.True(t, <-done)
+	}
+	assert.Equal(t, int32(5), client.maxRunning.Load())
+}
+
+func TestSubjectAccessReviewPrefetcherPrefetch(t *testing.T) {
+	t.Parallel()
+	gvr := schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"}
+	attributes := []authorizationv1.ResourceAttributes{{Verb: "bind", Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource, Name: "admin"}}
+	newRequest := func(dryRun bool) *admission.Request {
+		return &admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: "u-abc12", Groups: []string{"system:authenticated"}},
+				DryRun:   &dryRun,
+			},
+			Context: context.Background(),
+		}
+	}
+
+	t.Run("prefetched review is used once", func(t *testing.T) {
+		t.Parallel()
+		client := newCountingReviews()
+		close(client.release)
+		prefetcher := auth.NewSubjectAccessReviewPrefetcher(client, 4)
+		request := newRequest(false)
+
+		prefetcher.PrefetchRequest(request, attributes)
+		require.Eventually(t, func() bool { return client.created.Load() == 1 }, time.Second, time.Millisecond)
+		allowed, err := auth.RequestUserHasVerb(request, gvr, prefetcher, "bind", "admin", "")
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, int32(1), client.created.Load(), "the prefetched review is used")
+
+		allowed, err = auth.RequestUserHasVerb(request, gvr, prefetcher, "bind", "admin", "")
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, int32(2), client.created.Load(), "a prefetched review is only used once")
+	})
+	t.Run("review waits for its prefetch", func(t *testing.T) {
+		t.Parallel()
+		client := newCountingReviews()
+		prefetcher := auth.NewSubjectAccessReviewPrefetcher(client, 4)
+		request := newRequest(false)
+
+		prefetcher.PrefetchRequest(request, attributes)
+		require.Eventually(t, func() bool { return client.created.Load() == 1 }, time.Second, time.Millisecond)
+		done := make(chan bool)
+		go func() {
+			allowed, err := auth.RequestUserHasVerb(request, gvr, prefetcher, "bind", "admin", "")
+			assert.NoError(t, err)
+			done <- allowed
+		}()
+		close(client.release)
+		assert.True(t, <-done)
+		assert.Equal(t, int32(1), client.created.Load())
+	})
+	t.Run("other reviews aren't answered by the prefetch", func(t *testing.T) {
+		t.Parallel()
+		client := newCountingReviews()
+		close(client.release)
+		prefetcher := auth.NewSubjectAccessReviewPrefetcher(client, 4)
+		request := newRequest(false)
+
+		prefetcher.PrefetchRequest(request, attributes)
+		require.Eventually(t, func() bool { return client.created.Load() == 1 }, time.Second, time.Millisecond)
+		_, err := auth.RequestUserHasVerb(request, gvr, prefetcher, "escalate", "admin", "")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), client.created.Load())
+	})
+	t.Run("dry run isn't prefetched", func(t *testing.T) {
+		t.Parallel()
+		client := newCountingReviews()
+		close(client.release)
+		prefetcher := auth.NewSubjectAccessReviewPrefetcher(client, 4)
+
+		prefetcher.PrefetchRequest(newRequest(true), attributes)
+		time.Sleep(10 * time.Millisecond)
+		assert.Zero(t, client.created.Load())
+	})
+	t.Run("prefetch skipped at the concurrency limit", func(t *testing.T) {
+		t.Parallel()
+		client := newCountingReviews()
+		prefetcher := auth.NewSubjectAccessReviewPrefetcher(client, 1)
+		request := newRequest(false)
+
+		prefetcher.PrefetchRequest(request, attributes)
+		require.Eventually(t, func() bool { return client.created.Load() == 1 }, time.Second, time.Millisecond)
+		prefetcher.PrefetchRequest(request, []authorizationv1.ResourceAttributes{{Verb: "escalate", Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource, Name: "admin"}})
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, int32(1), client.created.Load(), "only one review is prefetched at once")
+		close(client.release)
+	})
+}
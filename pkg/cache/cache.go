@@ -0,0 +1,227 @@
+// Package cache provides the bounded in-memory caches of the webhook. Caches evict their least recently used entries
+// past their size, and their entries past their TTL, report their size and evictions as metrics, and can be flushed
+// with a debug endpoint.
+package cache
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// EvictedSize is the reason of evictions making room for new entries.
+	EvictedSize = "size"
+	// EvictedExpired is the reason of evictions of entries past their TTL.
+	EvictedExpired = "expired"
+	// EvictedFlush is the reason of evictions by a flush.
+	EvictedFlush = "flush"
+)
+
+var (
+	evictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rancher_webhook",
+		Name:      "cache_evictions_total",
+		Help:      "Number of entries evicted from the in-memory caches, by the reason of the eviction: size, expired or flush.",
+	}, []string{"cache", "reason"})
+	lookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rancher_webhook",
+		Name:      "cache_lookups_total",
+		Help:      "Number of lookups of the in-memory caches, by result: hit or miss.",
+	}, []string{"cache", "result"})
+	entriesDesc = prometheus.NewDesc("rancher_webhook_cache_entries", "Number of entries in the in-memory caches, expired or not.", []string{"cache"}, nil)
+)
+
+func init() {
+	prometheus.MustRegister(evictions, lookups, &entriesCollector{})
+}
+
+// Flusher is a cache registered for the debug endpoint.
+type Flusher interface {
+	// Info returns the name, size and limits of the cache.
+	Info() Info
+	// Flush evicts all the entries of the cache.
+	Flush()
+}
+
+// Info describes a cache.
+type Info struct {
+	Name    string          `json:"name"`
+	Len     int             `json:"len"`
+	MaxSize int             `json:"maxSize"`
+	TTL     metav1.Duration `json:"ttl"`
+}
+
+// registry holds the caches by name.
+var registry = struct {
+	mutex  sync.Mutex
+	caches map[string]Flusher
+}{caches: map[string]Flusher{}}
+
+// register adds the cache to the registry, replacing the previous cache of the same name.
+func register(cache Flusher) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.caches[cache.Info().Name] = cache
+}
+
+// lookup returns the registered cache of the name.
+func lookup(name string) (Flusher, bool) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	cache, ok := registry.caches[name]
+	return cache, ok
+}
+
+// registered returns the registered caches, by name.
+func registered() []Flusher {
+	registry.mutex.Lock()
+	caches := make([]Flusher, 0, len(registry.caches))
+	for _, cache := range registry.caches {
+		caches = append(caches, cache)
+	}
+	registry.mutex.Unlock()
+	sort.Slice(caches, func(i, j int) bool { return caches[i].Info().Name < caches[j].Info().Name })
+	return caches
+}
+
+// Cache is a bounded cache of the values of keys, safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	name    string
+	maxSize int
+	ttl     time.Duration
+	now     func() time.Time
+
+	mutex   sync.Mutex
+	entries map[K]*list.Element
+	// order holds the entries from the most to the least recently used.
+	order *list.List
+
+	hits, misses                                    prometheus.Counter
+	sizeEvictions, expiredEvictions, flushEvictions prometheus.Counter
+}
+
+// entry is an entry of a cache.
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// New returns a cache of up to maxSize entries, which expire ttl after they were added, or never if ttl is zero. The
+// cache is registered under its name for the metrics and the debug endpoint, replacing any cache of the same name.
+func New[K comparable, V any](name string, maxSize int, ttl time.Duration) *Cache[K, V] {
+	c := &Cache[K, V]{
+		name:             name,
+		maxSize:          maxSize,
+		ttl:              ttl,
+		now:              time.Now,
+		entries:          map[K]*list.Element{},
+		order:            list.New(),
+		hits:             lookups.WithLabelValues(name, "hit"),
+		misses:           lookups.WithLabelValues(name, "miss"),
+		sizeEvictions:    evictions.WithLabelValues(name, EvictedSize),
+		expiredEvictions: evictions.WithLabelValues(name, EvictedExpired),
+		flushEvictions:   evictions.WithLabelValues(name, EvictedFlush),
+	}
+	register(c)
+	return c
+}
+
+// Get returns the value of the key, false if it isn't cached or expired.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses.Inc()
+		var zero V
+		return zero, false
+	}
+	e := element.Value.(*entry[K, V])
+	if !e.expires.IsZero() && !c.now().Before(e.expires) {
+		c.removeElement(element)
+		c.expiredEvictions.Inc()
+		c.misses.Inc()
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	c.hits.Inc()
+	return e.value, true
+}
+
+// Add caches the value of the key, evicting the least recently used entry if the cache is full.
+func (c *Cache[K, V]) Add(key K, value V) {
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		e := element.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	for c.maxSize > 0 && c.order.Len() > c.maxSize {
+		c.removeElement(c.order.Back())
+		c.sizeEvictions.Inc()
+	}
+}
+
+// Remove removes the key from the cache.
+func (c *Cache[K, V]) Remove(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+// Len returns the number of entries of the cache, including the expired ones not evicted yet.
+func (c *Cache[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+// Flush evicts all the entries of the cache.
+func (c *Cache[K, V]) Flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.flushEvictions.Add(float64(c.order.Len()))
+	c.entries = map[K]*list.Element{}
+	c.order.Init()
+}
+
+// Info returns the name, size and limits of the cache.
+func (c *Cache[K, V]) Info() Info {
+	return Info{Name: c.name, Len: c.Len(), MaxSize: c.maxSize, TTL: metav1.Duration{Duration: c.ttl}}
+}
+
+// removeElement removes the entry of the element. The mutex must be held.
+func (c *Cache[K, V]) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry[K, V]).key)
+}
+
+// entriesCollector reports the number of entries of the registered caches.
+type entriesCollector struct{}
+
+func (c *entriesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- entriesDesc
+}
+
+func (c *entriesCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, cache := range registered() {
+		info := cache.Info()
+		ch <- prometheus.MustNewConstMetric(entriesDesc, prometheus.GaugeValue, float64(info.Len), info.Name)
+	}
+}
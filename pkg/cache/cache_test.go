@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheSize(t *testing.T) {
+	t.Parallel()
+	c := New[string, int]("test-size", 2, 0)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	_, ok := c.Get("a")
+	require.True(t, ok)
+
+	// b is the least recently used entry
+	c.Add("c", 3)
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	// updating an entry doesn't evict another
+	c.Add("c", 4)
+	value, _ = c.Get("c")
+	assert.Equal(t, 4, value)
+	assert.Equal(t, 2, c.Len())
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(evictions.WithLabelValues("test-size", EvictedSize)))
+	assert.Equal(t, float64(3), testutil.ToFloat64(lookups.WithLabelValues("test-size", "hit")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(lookups.WithLabelValues("test-size", "miss")))
+}
+
+func TestCacheTTL(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := New[string, int]("test-ttl", 10, time.Minute)
+	c.now = func() time.Time { return now }
+	c.Add("a", 1)
+
+	now = now.Add(59 * time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, float64(1), testutil.ToFloat64(evictions.WithLabelValues("test-ttl", EvictedExpired)))
+}
+
+func TestCacheRemoveAndFlush(t *testing.T) {
+	t.Parallel()
+	c := New[int, string]("test-flush", 10, 0)
+	for i := 0; i < 3; i++ {
+		c.Add(i, strconv.Itoa(i))
+	}
+	c.Remove(0)
+	assert.Equal(t, 2, c.Len())
+	c.Flush()
+	assert.Equal(t, 0, c.Len())
+	_, ok := c.Get(1)
+	assert.False(t, ok)
+	assert.Equal(t, float64(2), testutil.ToFloat64(evictions.WithLabelValues("test-flush", EvictedFlush)))
+}
+
+func TestHandlers(t *testing.T) {
+	t.Parallel()
+	c := New[string, int]("test-handlers", 10, time.Minute)
+	c.Add("a", 1)
+	router := mux.NewRouter()
+	RegisterHandlers(router)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, Path, nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var infos []Info
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &infos))
+	assert.Contains(t, infos, Info{Name: "test-handlers", Len: 1, MaxSize: 10, TTL: c.Info().TTL})
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, Path+"/test-handlers", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 0, c.Len())
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, Path+"/no-such-cache", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func BenchmarkCacheGet(b *testing.B) {
+	c := New[int, int]("benchmark-get", 1000, time.Minute)
+	for i := 0; i < 1000; i++ {
+		c.Add(i, i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(i % 1000)
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheAdd(b *testing.B) {
+	c := New[int, int]("benchmark-add", 1000, time.Minute)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			// half of the keys don't fit, so that entries are evicted
+			c.Add(i%2000, i)
+			i++
+		}
+	})
+}
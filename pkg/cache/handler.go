@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Path is the path of the debug endpoint listing (GET) and flushing (DELETE) the caches. A single cache is flushed
+// with DELETE on Path/<name>.
+const Path = "/debug/caches"
+
+// RegisterHandlers registers the debug endpoint of the caches.
+func RegisterHandlers(router *mux.Router) {
+	router.HandleFunc(Path, func(rw http.ResponseWriter, _ *http.Request) {
+		writeInfos(rw, registered())
+	}).Methods(http.MethodGet)
+	router.HandleFunc(Path, func(rw http.ResponseWriter, _ *http.Request) {
+		caches := registered()
+		for _, cache := range caches {
+			cache.Flush()
+		}
+		logrus.Info("[caches] flushed all caches")
+		writeInfos(rw, caches)
+	}).Methods(http.MethodDelete)
+	router.HandleFunc(Path+"/{name}", func(rw http.ResponseWriter, req *http.Request) {
+		name := mux.Vars(req)["name"]
+		cache, ok := lookup(name)
+		if !ok {
+			http.Error(rw, fmt.Sprintf("no cache %s", name), http.StatusNotFound)
+			return
+		}
+		cache.Flush()
+		logrus.Infof("[caches] flushed cache %s", name)
+		writeInfos(rw, []Flusher{cache})
+	}).Methods(http.MethodDelete)
+}
+
+func writeInfos(rw http.ResponseWriter, caches []Flusher) {
+	infos := make([]Info, 0, len(caches))
+	for _, cache := range caches {
+		infos = append(infos, cache.Info())
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(infos); err != nil {
+		logrus.Errorf("failed to write caches response: %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+// Package certs provides the serving certificate of the webhook and the CA bundle the webhook configurations trust,
+// when they aren't stored in the webhook's secrets.
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+)
+
+// Mode is how the webhook gets its serving certificate.
+type Mode string
+
+const (
+	// ModeSecret stores a CA and a serving certificate generated by dynamiclistener in the cattle-webhook-ca and
+	// cattle-webhook-tls secrets. It's the default.
+	ModeSecret Mode = "secret"
+	// ModeSelfSigned generates a CA and a serving certificate in memory, and rotates them before they expire.
+	ModeSelfSigned Mode = "self-signed"
+	// ModeFile reads the serving certificate, its key and the CA from files, e.g. mounted from a secret issued by an
+	// external provisioner, and reloads them when they change.
+	ModeFile Mode = "file"
+	// ModeCertManager reads the serving certificate, its key and the CA from a kubernetes.io/tls secret issued by
+	// cert-manager, and serves the new certificates as soon as the secret changes.
+	ModeCertManager Mode = "cert-manager"
+
+	// DefaultValidity is how long the self-signed serving certificates are valid.
+	DefaultValidity = 90 * 24 * time.Hour
+	// DefaultCertFile, DefaultKeyFile and DefaultCAFile are where a kubernetes.io/tls secret with a ca.crt key, like
+	// the ones issued by cert-manager, is mounted in file mode.
+	DefaultCertFile = "/etc/rancher-webhook/tls/tls.crt"
+	DefaultKeyFile  = "/etc/rancher-webhook/tls/tls.key"
+	DefaultCAFile   = "/etc/rancher-webhook/tls/ca.crt"
+)
+
+// ParseMode returns the mode with the given name, or ModeSecret if it's empty.
+func ParseMode(value string) (Mode, error) {
+	switch mode := Mode(value); mode {
+	case "":
+		return ModeSecret, nil
+	case ModeSecret, ModeSelfSigned, ModeFile, ModeCertManager:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown certificate mode '%s', must be %s, %s, %s or %s", value, ModeSecret, ModeSelfSigned, ModeFile, ModeCertManager)
+	}
+}
+
+// Options configure the certificate source of the webhook.
+type Options struct {
+	Mode Mode
+	// Validity is how long the serving certificates are valid in self-signed mode.
+	Validity time.Duration
+	// CertFile, KeyFile and CAFile are the PEM encoded files read in file mode.
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// SecretNamespace and SecretName are the kubernetes.io/tls secret read in cert-manager mode.
+	SecretNamespace string
+	SecretName      string
+}
+
+// Source provides the serving certificate and the CA bundle trusting it.
+type Source interface {
+	// GetCertificate returns the current serving certificate, for tls.Config.GetCertificate.
+	GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	// CABundle returns the PEM encoded CA bundle verifying the current serving certificate.
+	CABundle() []byte
+	// Certificates returns the current serving certificate and the CA that signed it.
+	Certificates() (serving, ca *x509.Certificate)
+	// OnChange registers a function called with the new CA bundle whenever the certificates change. It's called
+	// before the new serving certificate is served, so that the CA bundle can be registered first.
+	OnChange(func(caBundle []byte))
+	// OnServe registers a function called with the serving certificate and its CA whenever a new serving certificate
+	// is served.
+	OnServe(func(serving, ca *x509.Certificate))
+	// Run rotates or reloads the certificates until the context is done.
+	Run(ctx context.Context)
+}
+
+// New returns the certificate source of the given options for a serving certificate valid for the given DNS names,
+// or nil in secret mode, where dynamiclistener manages the certificates. The secrets are only watched in cert-manager
+// mode, until the context is done.
+func New(ctx context.Context, opts Options, dnsNames []string, secrets corecontrollers.SecretController) (Source, error) {
+	switch opts.Mode {
+	case "", ModeSecret:
+		return nil, nil
+	case ModeSelfSigned:
+		validity := opts.Validity
+		if validity == 0 {
+			validity = DefaultValidity
+		}
+		return NewSelfSigned(dnsNames, validity)
+	case ModeFile:
+		return NewFiles(opts.CertFile, opts.KeyFile, opts.CAFile)
+	case ModeCertManager:
+		return NewSecret(ctx, secrets, opts.SecretNamespace, opts.SecretName)
+	default:
+		return nil, fmt.Errorf("unknown certificate mode '%s'", opts.Mode)
+	}
+}
+
+// store holds the certificates of a source and notifies their changes.
+type store struct {
+	mutex    sync.RWMutex
+	serving  *tls.Certificate
+	ca       *x509.Certificate
+	caBundle []byte
+	onChange []func(caBundle []byte)
+	onServe  []func(serving, ca *x509.Certificate)
+}
+
+func (s *store) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.serving == nil {
+		return nil, errors.New("no serving certificate")
+	}
+	return s.serving, nil
+}
+
+func (s *store) CABundle() []byte {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return bytes.Clone(s.caBundle)
+}
+
+func (s *store) Certificates() (serving, ca *x509.Certificate) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.serving != nil {
+		serving = s.serving.Leaf
+	}
+	return serving, s.ca
+}
+
+func (s *store) OnChange(handler func(caBundle []byte)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onChange = append(s.onChange, handler)
+}
+
+func (s *store) OnServe(handler func(serving, ca *x509.Certificate)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onServe = append(s.onServe, handler)
+}
+
+// set notifies the OnChange handlers of the CA bundle if it changed, then serves the new certificate and notifies the
+// OnServe handlers.
+func (s *store) set(serving *tls.Certificate, ca *x509.Certificate, caBundle []byte) {
+	s.mutex.RLock()
+	onChange := s.onChange
+	changed := !bytes.Equal(s.caBundle, caBundle)
+	s.mutex.RUnlock()
+	if changed {
+		for _, handler := range onChange {
+			handler(bytes.Clone(caBundle))
+		}
+	}
+	s.mutex.Lock()
+	s.serving = serving
+	s.ca = ca
+	s.caBundle = caBundle
+	onServe := s.onServe
+	s.mutex.Unlock()
+	for _, handler := range onServe {
+		handler(serving.Leaf, ca)
+	}
+}
+
+// keyPair parses the PEM encoded serving certificate and key, and returns them with the CA of the PEM encoded bundle
+// verifying the certificate.
+func keyPair(certPEM, keyPEM, caBundle []byte) (*tls.Certificate, *x509.Certificate, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the key pair: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse the certificate: %w", err)
+		}
+	}
+	ca, err := verifyingCA(cert.Leaf, caBundle)
+	if err != nil {
+		return nil, nil, fmt.Errorf("the CA bundle doesn't verify the certificate: %w", err)
+	}
+	return &cert, ca, nil
+}
+
+// verifyingCA returns the CA of the PEM encoded bundle which verifies the serving certificate.
+func verifyingCA(serving *x509.Certificate, caBundle []byte) (*x509.Certificate, error) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBundle) {
+		return nil, errors.New("no certificates in the CA bundle")
+	}
+	chains, err := serving.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return nil, err
+	}
+	chain := chains[0]
+	return chain[len(chain)-1], nil
+}
+
+// encodeCertificates returns the PEM encoding of the given certificates.
+func encodeCertificates(certs ...*x509.Certificate) []byte {
+	var encoded []byte
+	for _, cert := range certs {
+		encoded = append(encoded, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return encoded
+}
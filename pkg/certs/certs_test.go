@@ -0,0 +1,56 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value   string
+		want    Mode
+		wantErr bool
+	}{
+		{value: "", want: ModeSecret},
+		{value: "secret", want: ModeSecret},
+		{value: "self-signed", want: ModeSelfSigned},
+		{value: "file", want: ModeFile},
+		{value: "cert-manager", want: ModeCertManager},
+		{value: "acme", wantErr: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.value, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseMode(test.value)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	source, err := New(context.Background(), Options{Mode: ModeSecret}, []string{"rancher-webhook.cattle-system.svc"}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, source, "dynamiclistener manages the certificates in secret mode")
+
+	source, err = New(context.Background(), Options{Mode: ModeSelfSigned}, []string{"rancher-webhook.cattle-system.svc"}, nil)
+	require.NoError(t, err)
+	serving, _ := source.Certificates()
+	assert.Equal(t, DefaultValidity, serving.NotAfter.Sub(serving.NotBefore)-clockSkew)
+
+	_, err = New(context.Background(), Options{Mode: ModeFile, CertFile: "missing.crt", KeyFile: "missing.key", CAFile: "missing-ca.crt"}, nil, nil)
+	assert.Error(t, err)
+
+	_, err = New(context.Background(), Options{Mode: ModeCertManager, SecretNamespace: "cattle-system"}, nil, nil)
+	assert.Error(t, err, "the secret name is required in cert-manager mode")
+}
@@ -0,0 +1,81 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// filePollInterval is how often the certificate files are checked for changes. Mounted secrets are updated through a
+// symlink swap, which file notifications don't reliably report.
+const filePollInterval = 10 * time.Second
+
+// Files is a Source reading the serving certificate, its key and the CA bundle from PEM encoded files, e.g. mounted
+// from a secret issued by cert-manager, and reloading them when they change.
+type Files struct {
+	store
+	certFile string
+	keyFile  string
+	caFile   string
+
+	// contents are the contents of the files last loaded.
+	contents [][]byte
+}
+
+// NewFiles returns a Files source reading the given files, which must hold a serving certificate verified by the CA
+// bundle.
+func NewFiles(certFile, keyFile, caFile string) (*Files, error) {
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, errors.New("the certificate, key and CA files are required")
+	}
+	f := &Files{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Run reloads the files every filePollInterval until the context is done. Files that fail to load are logged, and the
+// last certificates loaded keep being served.
+func (f *Files) Run(ctx context.Context) {
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.load(); err != nil {
+				logrus.Errorf("[certs] failed to reload the certificates, still serving the previous ones: %v", err)
+			}
+		}
+	}
+}
+
+// load reads the files and serves their certificates if they changed.
+func (f *Files) load() error {
+	contents := make([][]byte, 0, 3)
+	for _, file := range []string{f.certFile, f.keyFile, f.caFile} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		contents = append(contents, data)
+	}
+	if f.contents != nil && bytes.Equal(contents[0], f.contents[0]) && bytes.Equal(contents[1], f.contents[1]) && bytes.Equal(contents[2], f.contents[2]) {
+		return nil
+	}
+	cert, ca, err := keyPair(contents[0], contents[1], contents[2])
+	if err != nil {
+		return fmt.Errorf("failed to load %s, %s and %s: %w", f.certFile, f.keyFile, f.caFile, err)
+	}
+	f.set(cert, ca, contents[2])
+	f.contents = contents
+	logrus.Infof("[certs] loaded the serving certificate of %s, valid until %s", f.certFile, cert.Leaf.NotAfter.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,69 @@
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	caFile := filepath.Join(dir, "ca.crt")
+	write := func(certs *SelfSigned) {
+		serving, ca := certs.Certificates()
+		cert, err := certs.GetCertificate(nil)
+		require.NoError(t, err)
+		key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(certFile, encodeCertificates(serving), 0o600))
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+		require.NoError(t, os.WriteFile(caFile, encodeCertificates(ca), 0o600))
+	}
+	issuer, err := NewSelfSigned([]string{testDNSName}, time.Hour)
+	require.NoError(t, err)
+	write(issuer)
+
+	source, err := NewFiles(certFile, keyFile, caFile)
+	require.NoError(t, err)
+	wantServing, wantCA := issuer.Certificates()
+	serving, ca := source.Certificates()
+	assert.True(t, serving.Equal(wantServing))
+	assert.True(t, ca.Equal(wantCA))
+	assert.Equal(t, issuer.CABundle(), source.CABundle())
+	var caBundles [][]byte
+	source.OnChange(func(caBundle []byte) { caBundles = append(caBundles, caBundle) })
+
+	// unchanged files aren't reloaded
+	require.NoError(t, source.load())
+	assert.Empty(t, caBundles)
+
+	// a certificate that the CA doesn't verify isn't served
+	other, err := NewSelfSigned([]string{testDNSName}, time.Hour)
+	require.NoError(t, err)
+	otherServing, _ := other.Certificates()
+	require.NoError(t, os.WriteFile(certFile, encodeCertificates(otherServing), 0o600))
+	assert.Error(t, source.load())
+	serving, _ = source.Certificates()
+	assert.True(t, serving.Equal(wantServing), "the previous certificate is still served")
+
+	write(other)
+	require.NoError(t, source.load())
+	serving, _ = source.Certificates()
+	assert.True(t, serving.Equal(otherServing))
+	assert.Equal(t, [][]byte{other.CABundle()}, caBundles)
+}
+
+func TestNewFiles(t *testing.T) {
+	t.Parallel()
+	_, err := NewFiles("tls.crt", "", "ca.crt")
+	assert.Error(t, err)
+}
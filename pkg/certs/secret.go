@@ -0,0 +1,86 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// caCertKey is the key of the CA in the kubernetes.io/tls secrets issued by cert-manager.
+const caCertKey = "ca.crt"
+
+// Secret is a Source reading the serving certificate, its key and the CA bundle from a kubernetes.io/tls secret with a
+// ca.crt key, like the ones cert-manager issues, and serving the new certificates as soon as the secret changes.
+type Secret struct {
+	store
+	namespace string
+	name      string
+
+	// data is the data of the secret last loaded.
+	data map[string][]byte
+}
+
+// NewSecret returns a Secret source reading the given secret, which must exist and hold a serving certificate verified
+// by its CA, and watches the secret until the context is done.
+func NewSecret(ctx context.Context, secrets corecontrollers.SecretController, namespace, name string) (*Secret, error) {
+	if name == "" {
+		return nil, errors.New("the name of the certificate secret is required")
+	}
+	s := &Secret{namespace: namespace, name: name}
+	// the caches aren't started yet, so the secret is read from the API server
+	secret, err := secrets.Get(namespace, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the certificate secret %s/%s: %w", namespace, name, err)
+	}
+	if err := s.load(secret); err != nil {
+		return nil, err
+	}
+	secrets.OnChange(ctx, "certificate-secret", s.sync)
+	return s, nil
+}
+
+// Run does nothing, the secret is watched from NewSecret.
+func (s *Secret) Run(_ context.Context) {}
+
+// sync serves the certificates of the secret when it changes. Secrets that fail to load are logged, and the last
+// certificates loaded keep being served, since retrying them won't succeed until the secret changes again.
+func (s *Secret) sync(_ string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil || secret.Namespace != s.namespace || secret.Name != s.name {
+		return secret, nil
+	}
+	if secret.DeletionTimestamp != nil {
+		logrus.Warnf("[certs] certificate secret %s/%s is being deleted, still serving the previous certificates", s.namespace, s.name)
+		return secret, nil
+	}
+	if err := s.load(secret); err != nil {
+		logrus.Errorf("[certs] failed to reload the certificates, still serving the previous ones: %v", err)
+	}
+	return secret, nil
+}
+
+// load serves the certificates of the secret if they changed.
+func (s *Secret) load(secret *corev1.Secret) error {
+	if s.data != nil && bytes.Equal(secret.Data[corev1.TLSCertKey], s.data[corev1.TLSCertKey]) &&
+		bytes.Equal(secret.Data[corev1.TLSPrivateKeyKey], s.data[corev1.TLSPrivateKeyKey]) &&
+		bytes.Equal(secret.Data[caCertKey], s.data[caCertKey]) {
+		return nil
+	}
+	if len(secret.Data[caCertKey]) == 0 {
+		return fmt.Errorf("certificate secret %s/%s has no %s key, its issuer must provide the CA", s.namespace, s.name, caCertKey)
+	}
+	cert, ca, err := keyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey], secret.Data[caCertKey])
+	if err != nil {
+		return fmt.Errorf("failed to load certificate secret %s/%s: %w", s.namespace, s.name, err)
+	}
+	s.set(cert, ca, secret.Data[caCertKey])
+	s.data = secret.Data
+	logrus.Infof("[certs] loaded the serving certificate of secret %s/%s, valid until %s", s.namespace, s.name, cert.Leaf.NotAfter.Format(time.RFC3339))
+	return nil
+}
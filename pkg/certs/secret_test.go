@@ -0,0 +1,106 @@
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// issuedSecret returns the kubernetes.io/tls secret cert-manager would issue with the certificates of the issuer.
+func issuedSecret(t *testing.T, issuer *SelfSigned) *corev1.Secret {
+	t.Helper()
+	serving, ca := issuer.Certificates()
+	cert, err := issuer.GetCertificate(nil)
+	require.NoError(t, err)
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "rancher-webhook-tls", Namespace: "cattle-system"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       encodeCertificates(serving),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}),
+			caCertKey:               encodeCertificates(ca),
+		},
+	}
+}
+
+func TestSecret(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	issuer, err := NewSelfSigned([]string{testDNSName}, time.Hour)
+	require.NoError(t, err)
+	secret := issuedSecret(t, issuer)
+	secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+	secrets.EXPECT().Get("cattle-system", "rancher-webhook-tls", metav1.GetOptions{}).Return(secret, nil)
+	secrets.EXPECT().OnChange(gomock.Any(), "certificate-secret", gomock.Any())
+
+	source, err := NewSecret(context.Background(), secrets, "cattle-system", "rancher-webhook-tls")
+	require.NoError(t, err)
+	wantServing, wantCA := issuer.Certificates()
+	serving, ca := source.Certificates()
+	assert.True(t, serving.Equal(wantServing))
+	assert.True(t, ca.Equal(wantCA))
+	assert.Equal(t, issuer.CABundle(), source.CABundle())
+	var caBundles [][]byte
+	source.OnChange(func(caBundle []byte) { caBundles = append(caBundles, caBundle) })
+
+	// other secrets and unchanged certificates are ignored
+	other, err := NewSelfSigned([]string{testDNSName}, time.Hour)
+	require.NoError(t, err)
+	otherSecret := issuedSecret(t, other)
+	otherSecret.Name = "other"
+	_, err = source.sync("cattle-system/other", otherSecret)
+	require.NoError(t, err)
+	_, err = source.sync("cattle-system/rancher-webhook-tls", secret.DeepCopy())
+	require.NoError(t, err)
+	assert.Empty(t, caBundles)
+
+	// a secret without a CA, or whose CA doesn't verify its certificate, isn't served
+	invalid := issuedSecret(t, other)
+	delete(invalid.Data, caCertKey)
+	_, err = source.sync("cattle-system/rancher-webhook-tls", invalid)
+	require.NoError(t, err)
+	invalid = issuedSecret(t, other)
+	invalid.Data[caCertKey] = secret.Data[caCertKey]
+	_, err = source.sync("cattle-system/rancher-webhook-tls", invalid)
+	require.NoError(t, err)
+	serving, _ = source.Certificates()
+	assert.True(t, serving.Equal(wantServing), "the previous certificate is still served")
+
+	// a deleted secret keeps the previous certificate
+	_, err = source.sync("cattle-system/rancher-webhook-tls", nil)
+	require.NoError(t, err)
+	serving, _ = source.Certificates()
+	assert.True(t, serving.Equal(wantServing))
+
+	// a reissued certificate is served, after its CA is registered
+	otherServing, _ := other.Certificates()
+	_, err = source.sync("cattle-system/rancher-webhook-tls", issuedSecret(t, other))
+	require.NoError(t, err)
+	serving, _ = source.Certificates()
+	assert.True(t, serving.Equal(otherServing))
+	assert.Equal(t, [][]byte{other.CABundle()}, caBundles)
+}
+
+func TestNewSecret(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+	secrets.EXPECT().Get("cattle-system", "rancher-webhook-tls", metav1.GetOptions{}).
+		Return(nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "rancher-webhook-tls"))
+
+	_, err := NewSecret(context.Background(), secrets, "cattle-system", "rancher-webhook-tls")
+	assert.ErrorContains(t, err, "cattle-system/rancher-webhook-tls")
+}
@@ -0,0 +1,200 @@
+package certs
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// caValidityFactor is how many times longer than the serving certificates the CA is valid.
+	caValidityFactor = 4
+	// clockSkew backdates the certificates, so that clients whose clock is a little behind accept them.
+	clockSkew = time.Hour
+	// retryInterval is how long a failed rotation waits before it's retried.
+	retryInterval = time.Minute
+)
+
+// SelfSigned is a Source generating its own CA and serving certificate in memory. The serving certificate is
+// rotated once two thirds of its validity passed, and the CA once it has less than a serving certificate's validity
+// left, so that it outlives every certificate it signs. The CA bundle keeps the previous CA until it expires, so that
+// the certificates it signed stay trusted while the new CA bundle propagates.
+//
+// Each instance generates its own CA, so the CA bundle registered by the last instance to start only trusts the
+// certificates of that instance.
+type SelfSigned struct {
+	store
+	dnsNames []string
+	validity time.Duration
+	now      func() time.Time
+
+	caKey      crypto.Signer
+	previousCA *x509.Certificate
+}
+
+// NewSelfSigned returns a SelfSigned source whose serving certificates are valid for the given DNS names and duration.
+func NewSelfSigned(dnsNames []string, validity time.Duration) (*SelfSigned, error) {
+	if len(dnsNames) == 0 {
+		return nil, errors.New("the serving certificate needs a DNS name")
+	}
+	if validity <= 0 {
+		return nil, fmt.Errorf("certificate validity %s must be positive", validity)
+	}
+	s := &SelfSigned{dnsNames: dnsNames, validity: validity, now: time.Now}
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run rotates the certificates when they're due until the context is done.
+func (s *SelfSigned) Run(ctx context.Context) {
+	for {
+		wait := time.Until(s.nextRotation())
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := s.rotate(); err != nil {
+			logrus.Errorf("[certs] failed to rotate the self-signed certificates, retrying in %s: %v", retryInterval, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+		}
+	}
+}
+
+// nextRotation returns when the serving certificate or the CA is due for rotation.
+func (s *SelfSigned) nextRotation() time.Time {
+	serving, ca := s.Certificates()
+	next := serving.NotAfter.Add(-s.validity / 3)
+	if caRotation := ca.NotAfter.Add(-s.validity); caRotation.Before(next) {
+		next = caRotation
+	}
+	return next
+}
+
+// rotate generates a new CA if it's due, and a new serving certificate if it's due or the CA changed.
+func (s *SelfSigned) rotate() error {
+	now := s.now()
+	serving, ca := s.Certificates()
+	rotateCA := ca == nil || ca.NotAfter.Sub(now) < s.validity
+	if !rotateCA && serving != nil && serving.NotAfter.Sub(now) >= s.validity/3 {
+		return nil
+	}
+	caKey := s.caKey
+	previousCA := s.previousCA
+	if rotateCA {
+		var err error
+		previousCA = ca
+		if ca, caKey, err = newCA(now, caValidityFactor*s.validity); err != nil {
+			return err
+		}
+		logrus.Infof("[certs] generated a self-signed CA valid until %s", ca.NotAfter.Format(time.RFC3339))
+	}
+	trusted := []*x509.Certificate{ca}
+	if previousCA != nil && previousCA.NotAfter.After(now) {
+		trusted = append(trusted, previousCA)
+	} else {
+		previousCA = nil
+	}
+	cert, err := newServingCertificate(now, s.validity, s.dnsNames, ca, caKey)
+	if err != nil {
+		return err
+	}
+	s.caKey = caKey
+	s.previousCA = previousCA
+	s.set(cert, ca, encodeCertificates(trusted...))
+	logrus.Infof("[certs] generated a self-signed serving certificate valid until %s", cert.Leaf.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// newCA returns a self-signed CA valid for the given duration.
+func newCA(now time.Time, validity time.Duration) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate the CA key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("rancher-webhook-ca@%d", now.Unix())},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create the CA certificate: %w", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the CA certificate: %w", err)
+	}
+	return ca, key, nil
+}
+
+// newServingCertificate returns a serving certificate for the DNS names, signed by the CA and valid for the given
+// duration.
+func newServingCertificate(now time.Time, validity time.Duration, dnsNames []string, ca *x509.Certificate, caKey crypto.Signer) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the serving certificate key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-clockSkew),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the serving certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the serving certificate: %w", err)
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// serialNumber returns a random 128 bit serial number.
+func serialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a serial number: %w", err)
+	}
+	return serial, nil
+}
@@ -0,0 +1,86 @@
+package certs
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDNSName = "rancher-webhook.cattle-system.svc"
+
+func TestSelfSignedRotation(t *testing.T) {
+	t.Parallel()
+	validity := 30 * time.Hour
+	source, err := NewSelfSigned([]string{testDNSName}, validity)
+	require.NoError(t, err)
+	now := time.Now()
+	source.now = func() time.Time { return now }
+
+	var caBundles [][]byte
+	source.OnChange(func(caBundle []byte) {
+		// the CA bundle is registered before the new certificate is served
+		serving, _ := source.Certificates()
+		assert.NoError(t, verify(caBundle, serving), "the new CA bundle must trust the certificate still served")
+		caBundles = append(caBundles, caBundle)
+	})
+	var served []*x509.Certificate
+	source.OnServe(func(serving, _ *x509.Certificate) { served = append(served, serving) })
+
+	firstServing, firstCA := source.Certificates()
+	require.NoError(t, verify(source.CABundle(), firstServing))
+	assert.Equal(t, []string{testDNSName}, firstServing.DNSNames)
+	assert.Equal(t, firstServing.NotAfter.Add(-validity/3), source.nextRotation())
+
+	// nothing is due before two thirds of the validity passed
+	require.NoError(t, source.rotate())
+	assert.Empty(t, served)
+
+	now = source.nextRotation().Add(time.Second)
+	require.NoError(t, source.rotate())
+	serving, ca := source.Certificates()
+	assert.False(t, serving.Equal(firstServing))
+	assert.True(t, ca.Equal(firstCA), "the CA is kept while it outlives the serving certificates")
+	assert.Equal(t, []*x509.Certificate{serving}, served)
+	assert.Empty(t, caBundles, "the CA bundle didn't change")
+
+	// the CA is rotated once it has less than a serving certificate's validity left
+	now = firstCA.NotAfter.Add(-validity + time.Second)
+	require.NoError(t, source.rotate())
+	serving, ca = source.Certificates()
+	assert.False(t, ca.Equal(firstCA))
+	require.Len(t, caBundles, 1)
+	assert.NoError(t, verify(caBundles[0], serving))
+	assert.NoError(t, verify(caBundles[0], firstServing), "the previous CA is trusted until it expires")
+	assert.True(t, serving.NotAfter.Before(ca.NotAfter))
+
+	// the previous CA is dropped from the CA bundle once it expired
+	now = firstCA.NotAfter.Add(time.Second)
+	require.NoError(t, source.rotate())
+	require.Len(t, caBundles, 2)
+	assert.Error(t, verify(caBundles[1], firstServing))
+}
+
+func TestNewSelfSigned(t *testing.T) {
+	t.Parallel()
+	_, err := NewSelfSigned(nil, time.Hour)
+	assert.Error(t, err)
+	_, err = NewSelfSigned([]string{testDNSName}, 0)
+	assert.Error(t, err)
+}
+
+// verify returns an error if the CA bundle doesn't verify the serving certificate for testDNSName at the time it was
+// issued.
+func verify(caBundle []byte, serving *x509.Certificate) error {
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caBundle)
+	_, err := serving.Verify(x509.VerifyOptions{
+		DNSName:     testDNSName,
+		Roots:       roots,
+		CurrentTime: serving.NotBefore.Add(clockSkew),
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return err
+}
@@ -2,32 +2,91 @@ package clients
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/rancher/webhook/pkg/auth"
 	"github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io"
 	managementv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/generated/controllers/provisioning.cattle.io"
 	provv1 "github.com/rancher/webhook/pkg/generated/controllers/provisioning.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/generated/controllers/webhook.cattle.io"
+	webhookv1 "github.com/rancher/webhook/pkg/generated/controllers/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/identity"
 	"github.com/rancher/wrangler/v3/pkg/clients"
+	"github.com/rancher/wrangler/v3/pkg/generic"
 	"github.com/rancher/wrangler/v3/pkg/schemes"
+	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/kubernetes/pkg/registry/rbac/validation"
 )
 
+// resyncEnvKey overrides the resync period of the informers. Validators only read from the caches, so resyncs are
+// disabled by default; they replay the cache to handlers and don't query the API server.
+const resyncEnvKey = "CATTLE_WEBHOOK_RESYNC_PERIOD"
+
 type Clients struct {
 	clients.Clients
 
 	MultiClusterManagement bool
 	Management             managementv3.Interface
 	Provisioning           provv1.Interface
-	RoleTemplateResolver   *auth.RoleTemplateResolver
-	GlobalRoleResolver     *auth.GlobalRoleResolver
-	DefaultResolver        validation.AuthorizationRuleResolver
+	// Webhook holds the controllers of the resources owned by the webhook. It's nil on downstream clusters.
+	Webhook              webhookv1.Interface
+	RoleTemplateResolver *auth.RoleTemplateResolver
+	GlobalRoleResolver   *auth.GlobalRoleResolver
+	DefaultResolver      validation.AuthorizationRuleResolver
+	// Identities resolves Rancher users and verifies their principals for both validators and mutators. It's nil
+	// without multi-cluster management.
+	Identities *identity.Resolver
+	// SARPrefetcher prefetches the SubjectAccessReviews of queued requests. It's nil for offline clients.
+	SARPrefetcher *auth.SubjectAccessReviewPrefetcher
+
+	// offline is true for the clients created by NewOffline.
+	offline        bool
+	logWatchesOnce sync.Once
+	// synced holds whether each informer synced, once the first Start waited for them.
+	syncedMutex sync.RWMutex
+	synced      map[schema.GroupVersionKind]bool
 }
 
+// New creates the clients used by all handlers. The wrangler, management and provisioning factories share a single
+// controller factory, so each resource is watched by one informer no matter how many handlers use its cache.
 func New(ctx context.Context, rest *rest.Config, mcmEnabled bool) (*Clients, error) {
-	clients, err := clients.NewFromConfig(rest, nil)
+	opts, err := factoryOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	sarPrefetchConcurrency, err := auth.SARPrefetchConcurrencyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if mcmEnabled {
+		if err := ensureCRDs(ctx, rest); err != nil {
+			return nil, err
+		}
+	}
+	result, err := newClients(rest, opts, mcmEnabled)
+	if err != nil {
+		return nil, err
+	}
+	result.SARPrefetcher = auth.NewSubjectAccessReviewPrefetcher(result.K8s.AuthorizationV1().SubjectAccessReviews(), sarPrefetchConcurrency)
+	return result, nil
+}
+
+// newClients creates the clients of the given config, without reaching the API server.
+func newClients(rest *rest.Config, opts *generic.FactoryOptions, mcmEnabled bool) (*Clients, error) {
+	clients, err := clients.NewFromConfig(rest, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -46,10 +105,6 @@ func New(ctx context.Context, rest *rest.Config, mcmEnabled bool) (*Clients, err
 		return nil, err
 	}
 
-	if err = mgmt.Start(ctx, 5); err != nil {
-		return nil, err
-	}
-
 	rbacRestGetter := auth.RBACRestGetter{
 		Roles:               clients.RBAC.Role().Cache(),
 		RoleBindings:        clients.RBAC.RoleBinding().Cache(),
@@ -66,9 +121,111 @@ func New(ctx context.Context, rest *rest.Config, mcmEnabled bool) (*Clients, err
 	}
 
 	if mcmEnabled {
+		hook, err := webhook.NewFactoryFromConfigWithOptions(rest, clients.FactoryOptions)
+		if err != nil {
+			return nil, err
+		}
+		result.Webhook = hook.Webhook().V1()
 		result.RoleTemplateResolver = auth.NewRoleTemplateResolver(mgmt.Management().V3().RoleTemplate().Cache(), clients.RBAC.ClusterRole().Cache())
 		result.GlobalRoleResolver = auth.NewGlobalRoleResolver(result.RoleTemplateResolver, mgmt.Management().V3().GlobalRole().Cache())
+		verifier, err := identity.PrincipalVerifierFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		result.Identities = identity.NewResolver(mgmt.Management().V3().User().Cache()).WithPrincipalVerifier(verifier)
 	}
 
 	return result, nil
 }
+
+// SubjectAccessReviews returns the client admitters create SubjectAccessReviews with. Reviews of dry run requests
+// allowed by the RBAC rules in the caches aren't created, see auth.NewDryRunSubjectAccessReviews, and the others are
+// created through the SARPrefetcher. Offline clients answer all reviews from the RBAC rules in their caches.
+func (c *Clients) SubjectAccessReviews() authorizationv1.SubjectAccessReviewInterface {
+	if c.offline {
+		return auth.NewRBACSubjectAccessReviews(c.DefaultResolver)
+	}
+	var client authorizationv1.SubjectAccessReviewInterface = c.K8s.AuthorizationV1().SubjectAccessReviews()
+	if c.SARPrefetcher != nil {
+		client = c.SARPrefetcher
+	}
+	return auth.NewDryRunSubjectAccessReviews(client, c.DefaultResolver)
+}
+
+// ResourceQuotaCache returns the cache of the resource quotas, which the core controllers of wrangler don't include.
+func (c *Clients) ResourceQuotaCache() generic.CacheInterface[*corev1.ResourceQuota] {
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ResourceQuota"}
+	return generic.NewController[*corev1.ResourceQuota, *corev1.ResourceQuotaList](gvk, "resourcequotas", true, c.SharedControllerFactory).Cache()
+}
+
+// Start starts the informers of all the caches used so far and the controllers of the registered handlers. The
+// first time it's called, it waits for the caches to sync and logs the resources being watched.
+func (c *Clients) Start(ctx context.Context) error {
+	if err := c.Clients.Start(ctx); err != nil {
+		return err
+	}
+	c.logWatchesOnce.Do(func() {
+		synced := c.SharedControllerFactory.SharedCacheFactory().WaitForCacheSync(ctx)
+		logrus.Infof("Watching %d resources: %v", len(synced), watchedKinds(synced))
+		c.syncedMutex.Lock()
+		c.synced = synced
+		c.syncedMutex.Unlock()
+	})
+	return nil
+}
+
+// CheckCachesSynced returns an error until the caches the first Start waited for synced. Until then, validators don't
+// find the objects their caches don't hold yet, and deny requests that refer to them.
+func (c *Clients) CheckCachesSynced(_ *http.Request) error {
+	c.syncedMutex.RLock()
+	defer c.syncedMutex.RUnlock()
+	if c.synced == nil {
+		return errors.New("the caches haven't synced yet")
+	}
+	var unsynced []string
+	for gvk, ok := range c.synced {
+		if !ok {
+			unsynced = append(unsynced, kindName(gvk))
+		}
+	}
+	if len(unsynced) != 0 {
+		sort.Strings(unsynced)
+		return fmt.Errorf("the caches of %s haven't synced", strings.Join(unsynced, ", "))
+	}
+	return nil
+}
+
+// factoryOptionsFromEnv returns the options of the shared controller factory.
+func factoryOptionsFromEnv() (*generic.FactoryOptions, error) {
+	opts := &generic.FactoryOptions{}
+	if value := os.Getenv(resyncEnvKey); value != "" {
+		resync, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s value '%s': %w", resyncEnvKey, value, err)
+		}
+		opts.Resync = resync
+	}
+	return opts, nil
+}
+
+// watchedKinds returns the sorted kinds of the informers, marking those that haven't synced.
+func watchedKinds(synced map[schema.GroupVersionKind]bool) []string {
+	kinds := make([]string, 0, len(synced))
+	for gvk, ok := range synced {
+		kind := kindName(gvk)
+		if !ok {
+			kind += " (not synced)"
+		}
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// kindName returns the kind of the informer, qualified with its group.
+func kindName(gvk schema.GroupVersionKind) string {
+	if gvk.Group == "" {
+		return gvk.Kind
+	}
+	return gvk.Kind + "." + gvk.Group
+}
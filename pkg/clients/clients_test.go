@@ -0,0 +1,51 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFactoryOptionsFromEnv(t *testing.T) {
+	t.Setenv(resyncEnvKey, "")
+	opts, err := factoryOptionsFromEnv()
+	require.NoError(t, err)
+	assert.Zero(t, opts.Resync)
+
+	t.Setenv(resyncEnvKey, "10h")
+	opts, err = factoryOptionsFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Hour, opts.Resync)
+
+	t.Setenv(resyncEnvKey, "often")
+	_, err = factoryOptionsFromEnv()
+	assert.Error(t, err)
+}
+
+func TestWatchedKinds(t *testing.T) {
+	t.Parallel()
+	kinds := watchedKinds(map[schema.GroupVersionKind]bool{
+		{Group: "management.cattle.io", Version: "v3", Kind: "Setting"}:   true,
+		{Version: "v1", Kind: "Secret"}:                                   true,
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"}: false,
+	})
+	assert.Equal(t, []string{"Role.rbac.authorization.k8s.io (not synced)", "Secret", "Setting.management.cattle.io"}, kinds)
+}
+
+func TestCheckCachesSynced(t *testing.T) {
+	t.Parallel()
+	c := &Clients{}
+	assert.Error(t, c.CheckCachesSynced(nil), "the caches aren't synced before Start waited for them")
+
+	c.synced = map[schema.GroupVersionKind]bool{
+		{Version: "v1", Kind: "Secret"}:                                   true,
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"}: false,
+	}
+	assert.EqualError(t, c.CheckCachesSynced(nil), "the caches of Role.rbac.authorization.k8s.io haven't synced")
+
+	c.synced[schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"}] = true
+	assert.NoError(t, c.CheckCachesSynced(nil))
+}
@@ -0,0 +1,54 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/crd"
+	"k8s.io/client-go/rest"
+)
+
+// crds returns the CRDs of the resources owned by the webhook.
+func crds() []crd.CRD {
+	return []crd.CRD{
+		crd.NonNamespacedType("MaintenanceWindow.webhook.cattle.io/v1").
+			WithSchemaFromStruct(webhookv1.MaintenanceWindow{}).
+			WithColumn("Start", ".spec.start").
+			WithColumn("End", ".spec.end").
+			WithColumn("Approver", ".spec.approver"),
+		crd.NonNamespacedType("TaintPolicy.webhook.cattle.io/v1").
+			WithSchemaFromStruct(webhookv1.TaintPolicy{}),
+		crd.NonNamespacedType("DeniedObject.webhook.cattle.io/v1").
+			WithSchemaFromStruct(webhookv1.DeniedObject{}).
+			WithColumn("Resource", ".spec.resource").
+			WithColumn("User", ".spec.user").
+			WithColumn("Expires", ".spec.expires"),
+		crd.NonNamespacedType("ClusterTierPolicy.webhook.cattle.io/v1").
+			WithSchemaFromStruct(webhookv1.ClusterTierPolicy{}).
+			WithColumn("Tier", ".spec.tier"),
+		crd.NonNamespacedType("CustomPolicy.webhook.cattle.io/v1").
+			WithSchemaFromStruct(webhookv1.CustomPolicy{}).
+			WithColumn("Resources", ".spec.resources").
+			WithColumn("Message", ".spec.message"),
+		crd.NonNamespacedType("PolicyExceptionRequest.webhook.cattle.io/v1").
+			WithSchemaFromStruct(webhookv1.PolicyExceptionRequest{}).
+			WithColumn("Rule", ".spec.rule").
+			WithColumn("Requester", ".spec.requester").
+			WithColumn("Approver", ".spec.approver").
+			WithColumn("Expires", ".spec.expires"),
+	}
+}
+
+// ensureCRDs creates or updates the CRDs of the resources owned by the webhook and waits for them to be established,
+// since the caches of their resources can't sync before.
+func ensureCRDs(ctx context.Context, rest *rest.Config) error {
+	factory, err := crd.NewFactoryFromClient(rest)
+	if err != nil {
+		return fmt.Errorf("failed to create CRD factory: %w", err)
+	}
+	if err := factory.BatchCreateCRDs(ctx, crds()...).BatchWait(); err != nil {
+		return fmt.Errorf("failed to create CRDs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/rancher/wrangler/v3/pkg/schemes"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+)
+
+// NewOffline creates clients that never reach an API server, for running the handlers without a cluster, e.g. to
+// validate manifests in CI. Their caches are never started: they only hold the objects added with AddObjects. Requests
+// to the API server are answered as if it held no objects, with a NotFound, and SubjectAccessReviews are answered from
+// the RBAC rules in the caches.
+func NewOffline(mcmEnabled bool) (*Clients, error) {
+	cfg := &rest.Config{
+		// the reserved .invalid domain never resolves, the transport answers all requests anyway
+		Host:      "https://offline.invalid",
+		Transport: notFoundTransport{},
+	}
+	clients, err := newClients(cfg, &generic.FactoryOptions{}, mcmEnabled)
+	if err != nil {
+		return nil, err
+	}
+	clients.offline = true
+	return clients, nil
+}
+
+// notFoundTransport answers every request with a NotFound status, like an API server without objects.
+type notFoundTransport struct{}
+
+func (notFoundTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	status := &metav1.Status{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+		Status:   metav1.StatusFailure,
+		Message:  fmt.Sprintf("offline clients have no objects, %s %s not found", request.Method, request.URL.Path),
+		Reason:   metav1.StatusReasonNotFound,
+		Code:     http.StatusNotFound,
+	}
+	body, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    request,
+	}, nil
+}
+
+// AddObjects adds the objects to the caches of offline clients. Unstructured objects are converted to their type, which
+// must be known to the webhook. Objects of kinds no handler reads from a cache are ignored by the handlers.
+func (c *Clients) AddObjects(objs ...runtime.Object) error {
+	if !c.offline {
+		return errors.New("objects can only be added to the caches of offline clients")
+	}
+	for _, obj := range objs {
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			typed, err := schemes.All.New(gvk)
+			if err != nil {
+				return fmt.Errorf("failed to add %s %s: %w", gvk.Kind, u.GetName(), err)
+			}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+				return fmt.Errorf("failed to convert %s %s: %w", gvk.Kind, u.GetName(), err)
+			}
+			obj = typed
+		} else if gvk.Empty() {
+			gvks, _, err := schemes.All.ObjectKinds(obj)
+			if err != nil {
+				return err
+			}
+			gvk = gvks[0]
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		// the caches of the handlers already exist and are keyed by kind, so the resource is only used for the caches
+		// of kinds no handler reads
+		gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+		informer, err := c.SharedControllerFactory.SharedCacheFactory().ForResourceKind(gvr, gvk.Kind, accessor.GetNamespace() != metav1.NamespaceNone)
+		if err != nil {
+			return fmt.Errorf("failed to get the cache of %s: %w", gvk.Kind, err)
+		}
+		if err := informer.GetIndexer().Add(obj); err != nil {
+			return fmt.Errorf("failed to add %s %s: %w", gvk.Kind, accessor.GetName(), err)
+		}
+	}
+	return nil
+}
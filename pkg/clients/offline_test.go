@@ -0,0 +1,46 @@
+package clients
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNewOffline(t *testing.T) {
+	t.Parallel()
+	clients, err := NewOffline(true)
+	require.NoError(t, err)
+	projects := clients.Management.Project().Cache()
+	clusters := clients.Management.Cluster().Cache()
+
+	require.NoError(t, clients.AddObjects(
+		&v3.Project{ObjectMeta: metav1.ObjectMeta{Name: "p-abc", Namespace: "c-abc"}},
+		&unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "management.cattle.io/v3",
+			"kind":       "Cluster",
+			"metadata":   map[string]any{"name": "c-abc"},
+		}},
+	))
+	project, err := projects.Get("c-abc", "p-abc")
+	require.NoError(t, err)
+	assert.Equal(t, "p-abc", project.Name)
+	cluster, err := clusters.Get("c-abc")
+	require.NoError(t, err)
+	assert.Equal(t, "c-abc", cluster.Name)
+
+	// the API server of offline clients holds no objects
+	_, err = clients.Core.Secret().Get("cattle-system", "tls", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "unexpected error %v", err)
+	err = clients.Core.Secret().Delete("cattle-system", "tls", &metav1.DeleteOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "unexpected error %v", err)
+}
+
+func TestAddObjectsOnline(t *testing.T) {
+	t.Parallel()
+	assert.Error(t, (&Clients{}).AddObjects(&v3.Project{}), "objects can't be added to the caches of a cluster")
+}
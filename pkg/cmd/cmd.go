@@ -0,0 +1,235 @@
+// Package cmd implements the webhook's command line interface.
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/certs"
+	"github.com/rancher/webhook/pkg/clients"
+	"github.com/rancher/webhook/pkg/server"
+	"github.com/rancher/wrangler/v3/pkg/kubeconfig"
+	"github.com/rancher/wrangler/v3/pkg/ratelimit"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	handlersEnvKey      = "CATTLE_WEBHOOK_HANDLERS"
+	mcmEnvKey           = "ENABLE_MCM"
+	shardNameEnvKey     = "CATTLE_WEBHOOK_SHARD_NAME"
+	shardSelectorEnvKey = "CATTLE_WEBHOOK_SHARD_SELECTOR"
+	oldObjectsEnvKey    = "CATTLE_WEBHOOK_OLD_OBJECTS"
+	normalizationEnvKey = "CATTLE_WEBHOOK_NORMALIZATION"
+	certModeEnvKey      = "CATTLE_WEBHOOK_CERT_MODE"
+	certValidityEnvKey  = "CATTLE_WEBHOOK_CERT_VALIDITY"
+	certFileEnvKey      = "CATTLE_WEBHOOK_CERT_FILE"
+	keyFileEnvKey       = "CATTLE_WEBHOOK_KEY_FILE"
+	caFileEnvKey        = "CATTLE_WEBHOOK_CA_FILE"
+	certSecretEnvKey    = "CATTLE_WEBHOOK_CERT_SECRET"
+)
+
+// options are the flags shared by all commands.
+type options struct {
+	kubeconfig    string
+	mcm           bool
+	handlers      string
+	shardName     string
+	shardSelector string
+	oldObjects    string
+	normalization string
+
+	// the certificate flags are only used to serve the webhook
+	certMode     string
+	certValidity string
+	certFile     string
+	keyFile      string
+	caFile       string
+	certSecret   string
+}
+
+// New returns the root webhook command. Running it without a subcommand serves the webhook.
+func New(version, gitCommit string) *cobra.Command {
+	opts := &options{}
+	serve := newServeCommand(opts, version, gitCommit)
+	root := &cobra.Command{
+		Use:           "webhook",
+		Short:         "Rancher's admission webhook",
+		Version:       fmt.Sprintf("%s (%s)", version, gitCommit),
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			setLogLevel()
+			if err := admission.SetOldObjectDecoding(opts.oldObjects); err != nil {
+				return err
+			}
+			return admission.SetNormalization(opts.normalization)
+		},
+		RunE: serve.RunE,
+	}
+	opts.addCertFlags(root.Flags())
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "Path to the kubeconfig of the cluster Rancher runs in. Defaults to $KUBECONFIG or the in-cluster config.")
+	flags.BoolVar(&opts.mcm, "mcm", os.Getenv(mcmEnvKey) != "false", "Enable the handlers of multi-cluster management resources. Defaults to false if $"+mcmEnvKey+" is false.")
+	flags.StringVar(&opts.handlers, "handlers", os.Getenv(handlersEnvKey),
+		"Comma separated list of the handlers to enable, e.g. clusters.management.cattle.io,projects.management.cattle.io. All handlers are enabled if empty. Defaults to $"+handlersEnvKey+".")
+	flags.StringVar(&opts.shardName, "shard-name", os.Getenv(shardNameEnvKey),
+		"Name of the shard served by this instance, appended to the names of its service and webhook configurations. Required with --shard-selector. Defaults to $"+shardNameEnvKey+".")
+	flags.StringVar(&opts.shardSelector, "shard-selector", os.Getenv(shardSelectorEnvKey),
+		"Label selector of the namespaces and cluster scoped objects this instance handles, e.g. shard=a. All objects are handled if empty. Defaults to $"+shardSelectorEnvKey+".")
+	flags.StringVar(&opts.oldObjects, "old-objects", os.Getenv(oldObjectsEnvKey),
+		"How old objects that don't decode into the current types are handled: strict fails the request, relaxed ignores the fields that don't decode with a warning. Defaults to $"+oldObjectsEnvKey+" or strict.")
+	flags.StringVar(&opts.normalization, "normalization", os.Getenv(normalizationEnvKey),
+		"How objects are normalized before they are admitted: off leaves them as they are, validate compares the old and new objects validators review normalized, persist also stores the normalized objects of mutated requests. Defaults to $"+normalizationEnvKey+" or off.")
+
+	root.AddCommand(
+		serve,
+		newGenConfigCommand(opts),
+		newTestCommand(opts),
+		newReplayCommand(opts),
+		newSimulateCommand(opts),
+		newValidateConfigCommand(opts),
+		newCoverageCommand(opts),
+		newDenialCodesCommand(),
+		newScaffoldCommand(),
+	)
+	return root
+}
+
+// setLogLevel sets the log level from the debug and trace environment variables.
+func setLogLevel() {
+	if os.Getenv("CATTLE_DEBUG") == "true" || os.Getenv("RANCHER_DEBUG") == "true" {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+	if os.Getenv("CATTLE_TRACE") == "true" {
+		logrus.SetLevel(logrus.TraceLevel)
+	}
+}
+
+// restConfig returns the config of the cluster Rancher runs in.
+func (o *options) restConfig() (*rest.Config, error) {
+	cfg, err := kubeconfig.GetNonInteractiveClientConfig(o.kubeconfig).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	cfg.RateLimiter = ratelimit.None
+	return cfg, nil
+}
+
+// addCertFlags adds the flags configuring the serving certificate of the webhook.
+func (o *options) addCertFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.certMode, "cert-mode", os.Getenv(certModeEnvKey),
+		"How the serving certificate is provided: secret stores a generated CA and certificate in the cattle-webhook-ca and cattle-webhook-tls secrets, "+
+			"self-signed generates them in memory and rotates them before they expire, file reads them from --cert-file, --key-file and --ca-file and reloads them when they change, "+
+			"cert-manager reads them from the secret named by --cert-secret and serves them as soon as it changes. "+
+			"Defaults to $"+certModeEnvKey+" or secret.")
+	flags.StringVar(&o.certValidity, "cert-validity", os.Getenv(certValidityEnvKey),
+		"How long the self-signed serving certificates are valid, e.g. 720h. They're rotated once two thirds of it passed. Defaults to $"+certValidityEnvKey+" or 2160h.")
+	flags.StringVar(&o.certFile, "cert-file", envOrDefault(certFileEnvKey, certs.DefaultCertFile),
+		"Path to the PEM encoded serving certificate in file mode. Defaults to $"+certFileEnvKey+".")
+	flags.StringVar(&o.keyFile, "key-file", envOrDefault(keyFileEnvKey, certs.DefaultKeyFile),
+		"Path to the PEM encoded key of the serving certificate in file mode. Defaults to $"+keyFileEnvKey+".")
+	flags.StringVar(&o.caFile, "ca-file", envOrDefault(caFileEnvKey, certs.DefaultCAFile),
+		"Path to the PEM encoded CA bundle registered in the webhook configurations in file mode. Defaults to $"+caFileEnvKey+".")
+	flags.StringVar(&o.certSecret, "cert-secret", os.Getenv(certSecretEnvKey),
+		"Name of the kubernetes.io/tls secret with a ca.crt key in the cattle-system namespace, e.g. issued by cert-manager, read in cert-manager mode. Defaults to $"+certSecretEnvKey+".")
+}
+
+// certOptions returns the certificate options configured by the certificate flags.
+func (o *options) certOptions() (certs.Options, error) {
+	mode, err := certs.ParseMode(o.certMode)
+	if err != nil {
+		return certs.Options{}, err
+	}
+	certOpts := certs.Options{Mode: mode, CertFile: o.certFile, KeyFile: o.keyFile, CAFile: o.caFile, SecretName: o.certSecret}
+	if mode == certs.ModeCertManager && o.certSecret == "" {
+		return certs.Options{}, errors.New("the certificate secret is required in cert-manager mode")
+	}
+	if o.certValidity != "" {
+		if certOpts.Validity, err = time.ParseDuration(o.certValidity); err != nil {
+			return certs.Options{}, fmt.Errorf("failed to decode certificate validity '%s': %w", o.certValidity, err)
+		}
+		if certOpts.Validity <= 0 {
+			return certs.Options{}, fmt.Errorf("certificate validity '%s' must be positive", o.certValidity)
+		}
+	}
+	return certOpts, nil
+}
+
+// envOrDefault returns the value of the environment variable, or the default if it's empty.
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// enabledHandlers returns the names of the handlers enabled by the handlers flag.
+func (o *options) enabledHandlers() []string {
+	return splitHandlers(o.handlers)
+}
+
+// shard returns the shard configured by the shard flags.
+func (o *options) shard() (server.Shard, error) {
+	return server.NewShard(o.shardName, o.shardSelector)
+}
+
+// handlerSet holds the enabled handlers and the clients backing them.
+type handlerSet struct {
+	clients    *clients.Clients
+	validators []admission.ValidatingAdmissionHandler
+	mutators   []admission.MutatingAdmissionHandler
+}
+
+// loadHandlers creates the clients and the enabled handlers.
+func (o *options) loadHandlers(ctx context.Context) (*handlerSet, error) {
+	cfg, err := o.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	clients, err := clients.New(ctx, cfg, o.mcm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new client: %w", err)
+	}
+	validators, mutators, err := server.Handlers(clients, o.enabledHandlers())
+	if err != nil {
+		return nil, err
+	}
+	return &handlerSet{
+		clients:    clients,
+		validators: validators,
+		mutators:   mutators,
+	}, nil
+}
+
+// startHandlers loads the enabled handlers and waits for the caches they use to sync, so that they can review requests.
+func (o *options) startHandlers(ctx context.Context) (*handlerSet, error) {
+	handlers, err := o.loadHandlers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := handlers.clients.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start client: %w", err)
+	}
+	return handlers, nil
+}
+
+// splitHandlers splits a comma separated list of handler names, ignoring empty entries.
+func splitHandlers(handlers string) []string {
+	var names []string
+	for _, name := range strings.Split(handlers, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"errors"
+	"slices"
+	"sort"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/fixtures"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/spf13/cobra"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// resourceCoverage is the coverage of the validation of a resource.
+type resourceCoverage struct {
+	// Resource is the resource validated by the handler, as used by the handlers flag.
+	Resource string `json:"resource"`
+	// Operations are the operations the handler validates.
+	Operations []string `json:"operations"`
+	// Rules are the IDs of the rules registered for the resource.
+	Rules []string `json:"rules"`
+	// Fixtures is the number of fixtures of the resource for each operation.
+	Fixtures map[string]int `json:"fixtures"`
+	// DeniedFixtures is the number of fixtures of the resource the webhook denies.
+	DeniedFixtures int `json:"deniedFixtures"`
+	// Gaps are the missing coverage of the resource.
+	Gaps []string `json:"gaps,omitempty"`
+}
+
+// coverageReport is the output of the coverage command.
+type coverageReport struct {
+	Resources []resourceCoverage `json:"resources"`
+	// Gaps is the number of resources with gaps.
+	Gaps int `json:"gaps"`
+}
+
+// errCoverageGaps is returned by the coverage command with --fail-on-gaps when a resource has gaps, after printing the
+// report, so that the command exits with a non-zero status.
+var errCoverageGaps = errors.New("validated resources have coverage gaps")
+
+func newCoverageCommand(opts *options) *cobra.Command {
+	var output string
+	var failOnGaps bool
+	cmd := &cobra.Command{
+		Use:   "coverage",
+		Short: "Report the validated resources lacking rules or fixtures",
+		Long: "Cross-reference the resources and operations of the enabled validators with the registered rules and the " +
+			"fixtures, and print, for each validated resource, the operations validated without UPDATE or DELETE fixtures, " +
+			"and whether it has no denied fixtures or no registered rules.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			format, err := parseOutputFormat(output)
+			if err != nil {
+				return err
+			}
+			handlers, err := opts.loadHandlers(cmd.Context())
+			if err != nil {
+				return err
+			}
+			report := coverage(handlers.validators, rules.List(), fixtures.All())
+			if err := printObjects(cmd.OutOrStdout(), format, report); err != nil {
+				return err
+			}
+			if failOnGaps && report.Gaps != 0 {
+				return errCoverageGaps
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&output, "output", "o", string(outputYAML), "Output format, one of yaml or json.")
+	flags.BoolVar(&failOnGaps, "fail-on-gaps", false, "Exit with a non-zero status if any validated resource has gaps.")
+	return cmd
+}
+
+// coverage returns the coverage of the resources of the validators by the rules and fixtures, sorted by resource.
+func coverage(validators []admission.ValidatingAdmissionHandler, allRules []rules.Rule, allFixtures []fixtures.Fixture) coverageReport {
+	report := coverageReport{Resources: []resourceCoverage{}}
+	for _, validator := range validators {
+		gvr := validator.GVR()
+		resource := resourceCoverage{
+			Resource:   admission.SubPath(gvr),
+			Operations: validatedOperations(validator.Operations()),
+			Rules:      []string{},
+			Fixtures:   map[string]int{},
+		}
+		for _, rule := range allRules {
+			if coversResource(gvr, rule.GVR) {
+				resource.Rules = append(resource.Rules, rule.ID)
+			}
+		}
+		for _, fixture := range allFixtures {
+			if !coversResource(gvr, fixture.Resource) {
+				continue
+			}
+			resource.Fixtures[string(fixture.Operation)]++
+			if !fixture.Allowed {
+				resource.DeniedFixtures++
+			}
+		}
+
+		for _, operation := range []admissionv1.Operation{admissionv1.Update, admissionv1.Delete} {
+			if slices.Contains(resource.Operations, string(operation)) && resource.Fixtures[string(operation)] == 0 {
+				resource.Gaps = append(resource.Gaps, "no "+string(operation)+" fixtures")
+			}
+		}
+		if resource.DeniedFixtures == 0 {
+			resource.Gaps = append(resource.Gaps, "no denied fixtures")
+		}
+		if len(resource.Rules) == 0 {
+			resource.Gaps = append(resource.Gaps, "no rules")
+		}
+		if len(resource.Gaps) != 0 {
+			report.Gaps++
+		}
+		report.Resources = append(report.Resources, resource)
+	}
+	sort.Slice(report.Resources, func(i, j int) bool { return report.Resources[i].Resource < report.Resources[j].Resource })
+	return report
+}
+
+// validatedOperations returns the admission operations matching the webhook operations, expanding the wildcard.
+func validatedOperations(operations []v1.OperationType) []string {
+	var validated []string
+	for _, operation := range []v1.OperationType{v1.Create, v1.Update, v1.Delete, v1.Connect} {
+		for _, op := range operations {
+			if op == operation || op == v1.OperationAll {
+				validated = append(validated, string(operation))
+				break
+			}
+		}
+	}
+	return validated
+}
+
+// coversResource returns true if the resource of a rule or fixture is handled by a validator of gvr, which may be a
+// group wide handler.
+func coversResource(gvr, resource schema.GroupVersionResource) bool {
+	if gvr.Resource == "*" {
+		return gvr.Group == resource.Group
+	}
+	return gvr == resource
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/fixtures"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCoverage(t *testing.T) {
+	t.Parallel()
+	projectsGVR := schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"}
+	machineConfigsGVR := schema.GroupVersionResource{Group: "rke-machine-config.cattle.io", Version: "v1", Resource: "*"}
+	validators := []admission.ValidatingAdmissionHandler{
+		&fakeValidator{gvr: projectsGVR},
+		&fakeValidator{gvr: clustersGVR},
+		&fakeValidator{gvr: machineConfigsGVR},
+	}
+	allRules := []rules.Rule{
+		{ID: "cluster-rule", GVR: clustersGVR},
+		{ID: "machine-config-rule", GVR: machineConfigsGVR.GroupVersion().WithResource("amazonec2configs")},
+	}
+	allFixtures := []fixtures.Fixture{
+		{Resource: clustersGVR, Operation: admissionv1.Create, Allowed: true},
+		{Resource: clustersGVR, Operation: admissionv1.Update},
+		{Resource: machineConfigsGVR.GroupVersion().WithResource("amazonec2configs"), Operation: admissionv1.Create},
+		{Resource: projectsGVR, Operation: admissionv1.Create, Allowed: true},
+	}
+
+	report := coverage(validators, allRules, allFixtures)
+	assert.Equal(t, coverageReport{
+		Resources: []resourceCoverage{
+			{
+				Resource:       "clusters.management.cattle.io",
+				Operations:     []string{"CREATE", "UPDATE"},
+				Rules:          []string{"cluster-rule"},
+				Fixtures:       map[string]int{"CREATE": 1, "UPDATE": 1},
+				DeniedFixtures: 1,
+			},
+			{
+				Resource:       "projects.management.cattle.io",
+				Operations:     []string{"CREATE", "UPDATE"},
+				Rules:          []string{},
+				Fixtures:       map[string]int{"CREATE": 1},
+				DeniedFixtures: 0,
+				Gaps:           []string{"no UPDATE fixtures", "no denied fixtures", "no rules"},
+			},
+			{
+				Resource:       "rke-machine-config.cattle.io",
+				Operations:     []string{"CREATE", "UPDATE"},
+				Rules:          []string{"machine-config-rule"},
+				Fixtures:       map[string]int{"CREATE": 1},
+				DeniedFixtures: 1,
+				Gaps:           []string{"no UPDATE fixtures"},
+			},
+		},
+		Gaps: 2,
+	}, report)
+}
+
+func TestValidatedOperations(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{"CREATE", "DELETE"}, validatedOperations([]admissionregistrationv1.OperationType{admissionregistrationv1.Delete, admissionregistrationv1.Create}))
+	assert.Equal(t, []string{"CREATE", "UPDATE", "DELETE", "CONNECT"}, validatedOperations([]admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll}))
+}
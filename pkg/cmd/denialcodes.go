@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// denialCodesMarkdown prints the catalog as the markdown page generated into denialcodes.md.
+	denialCodesMarkdown = "markdown"
+	// denialCodesSchema prints the JSON schema of the catalog.
+	denialCodesSchema = "schema"
+)
+
+func newDenialCodesCommand() *cobra.Command {
+	var output, file string
+	cmd := &cobra.Command{
+		Use:   "denial-codes",
+		Short: "Print the catalog of denial codes and the rules using them",
+		Long: "Print the codes the webhook denies requests with, their status reasons and HTTP codes, and the code, " +
+			"severity and example message of every rule built into the webhook. The catalog is also served at " +
+			admission.DenialCatalogPath + " and its JSON schema at " + admission.DenialCatalogSchemaPath + ".",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			w := cmd.OutOrStdout()
+			if file != "" {
+				f, err := os.Create(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			switch output {
+			case denialCodesMarkdown:
+				return writeDenialCatalogMarkdown(w, admission.NewDenialCatalog())
+			case denialCodesSchema:
+				return printObjects(w, outputJSON, admission.DenialCatalogSchema())
+			}
+			format, err := parseOutputFormat(output)
+			if err != nil {
+				return fmt.Errorf("unknown output format %q, must be one of %s, %s, %s or %s", output, outputYAML, outputJSON,
+					denialCodesMarkdown, denialCodesSchema)
+			}
+			return printObjects(w, format, admission.NewDenialCatalog())
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&output, "output", "o", string(outputYAML), "Output format, one of yaml, json, markdown or schema.")
+	flags.StringVarP(&file, "file", "f", "", "Write the output to the file instead of stdout.")
+	return cmd
+}
+
+// writeDenialCatalogMarkdown writes the catalog as a markdown page.
+func writeDenialCatalogMarkdown(w io.Writer, catalog admission.DenialCatalog) error {
+	var builder strings.Builder
+	builder.WriteString("<!-- Generated by `webhook denial-codes -o markdown`, DO NOT EDIT. -->\n\n")
+	builder.WriteString("# Denial codes\n\n")
+	builder.WriteString("The webhook denies requests with one of the following codes, reported as the reason and HTTP code of the " +
+		"status of the response. Codes with a retry-after delay set it as the `retryAfterSeconds` of the details of the " +
+		"status, so that clients back off before retrying. The catalog is served at `" + admission.DenialCatalogPath +
+		"` and its JSON schema at `" + admission.DenialCatalogSchemaPath + "`.\n\n")
+	builder.WriteString("| Code | Reason | HTTP code | Retryable | Retry after | Description |\n")
+	builder.WriteString("|------|--------|-----------|-----------|-------------|-------------|\n")
+	for _, code := range catalog.Codes {
+		retryAfter := "-"
+		if code.RetryAfterSeconds > 0 {
+			retryAfter = fmt.Sprintf("%ds", code.RetryAfterSeconds)
+		}
+		fmt.Fprintf(&builder, "| `%s` | `%s` | %d | %t | %s | %s |\n", code.Code, code.Reason, code.HTTPCode, code.Retryable, retryAfter,
+			code.Description)
+	}
+	builder.WriteString("\n## Rules\n\n")
+	builder.WriteString("Denial messages of rules end with the path of the rule's documentation, e.g. `(see /rules/<id>)`.\n\n")
+	builder.WriteString("| Rule | Resource | Severity | Code | Example message |\n")
+	builder.WriteString("|------|----------|----------|------|-----------------|\n")
+	for _, rule := range catalog.Rules {
+		resource := rule.Resource
+		if rule.Group != "" {
+			resource += "." + rule.Group
+		}
+		fmt.Fprintf(&builder, "| `%s` | `%s/%s` | %s | `%s` | %s |\n", rule.ID, resource, rule.Version, rule.Severity, rule.Code,
+			markdownCell(rule.ExampleMessage))
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// markdownCell escapes the text for a markdown table cell.
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(text)
+}
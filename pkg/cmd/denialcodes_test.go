@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenialCodesMarkdownUpToDate(t *testing.T) {
+	t.Parallel()
+	var generated bytes.Buffer
+	require.NoError(t, writeDenialCatalogMarkdown(&generated, admission.NewDenialCatalog()))
+	checkedIn, err := os.ReadFile("../../denialcodes.md")
+	require.NoError(t, err)
+	assert.Equal(t, string(checkedIn), generated.String(), "denialcodes.md is out of date, run go generate")
+}
+
+func TestDenialCodesCommand(t *testing.T) {
+	t.Parallel()
+	for _, output := range []string{"yaml", "json", "markdown", "schema"} {
+		var out bytes.Buffer
+		cmd := newDenialCodesCommand()
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{"-o", output})
+		require.NoError(t, cmd.Execute(), output)
+		assert.Contains(t, out.String(), "reserved-metadata", output)
+	}
+	cmd := newDenialCodesCommand()
+	cmd.SetArgs([]string{"-o", "xml"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	assert.ErrorContains(t, cmd.Execute(), "unknown output format")
+}
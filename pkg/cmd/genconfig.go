@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rancher/webhook/pkg/config"
+	"github.com/rancher/webhook/pkg/server"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/admissionregistration/v1"
+)
+
+func newGenConfigCommand(opts *options) *cobra.Command {
+	var caBundleFile, configFile string
+	cmd := &cobra.Command{
+		Use:   "gen-config",
+		Short: "Print the webhook configurations registering the enabled handlers",
+		Long: "Print the ValidatingWebhookConfiguration and MutatingWebhookConfiguration the webhook applies to the cluster " +
+			"for the enabled handlers, without applying them.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var caBundle []byte
+			if caBundleFile != "" {
+				var err error
+				if caBundle, err = os.ReadFile(caBundleFile); err != nil {
+					return fmt.Errorf("failed to read CA bundle: %w", err)
+				}
+			}
+			var webhookConfig *config.Config
+			if configFile != "" {
+				data, err := os.ReadFile(configFile)
+				if err != nil {
+					return fmt.Errorf("failed to read webhook configuration: %w", err)
+				}
+				if webhookConfig, err = config.Parse(data); err != nil {
+					return err
+				}
+			}
+			shard, err := opts.shard()
+			if err != nil {
+				return err
+			}
+			handlers, err := opts.loadHandlers(cmd.Context())
+			if err != nil {
+				return err
+			}
+			validatingConfig, mutatingConfig := server.WebhookConfigurations(handlers.validators, handlers.mutators, caBundle, shard, webhookConfig)
+			validatingConfig.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("ValidatingWebhookConfiguration"))
+			mutatingConfig.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("MutatingWebhookConfiguration"))
+			return printObjects(cmd.OutOrStdout(), outputYAML, validatingConfig, mutatingConfig)
+		},
+	}
+	cmd.Flags().StringVar(&caBundleFile, "ca-bundle", "", "Path to the PEM encoded CA bundle the webhooks trust.")
+	cmd.Flags().StringVar(&configFile, "config", os.Getenv(config.FileEnvKey),
+		"Path to the webhook configuration applied to the webhooks. Defaults to $"+config.FileEnvKey+".")
+	return cmd
+}
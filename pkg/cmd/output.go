@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/yaml"
+)
+
+// outputFormat is the format objects are printed in.
+type outputFormat string
+
+const (
+	outputYAML outputFormat = "yaml"
+	outputJSON outputFormat = "json"
+)
+
+// parseOutputFormat returns the output format with the given name.
+func parseOutputFormat(name string) (outputFormat, error) {
+	switch format := outputFormat(name); format {
+	case outputYAML, outputJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q, must be one of %s or %s", name, outputYAML, outputJSON)
+	}
+}
+
+// printObjects prints the objects as YAML documents or as indented JSON objects.
+func printObjects(w io.Writer, format outputFormat, objs ...any) error {
+	for i, obj := range objs {
+		var data []byte
+		var err error
+		if format == outputJSON {
+			data, err = json.MarshalIndent(obj, "", "  ")
+			data = append(data, '\n')
+		} else {
+			data, err = yaml.Marshal(obj)
+			if i > 0 {
+				data = append([]byte("---\n"), data...)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to marshal output: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+func newReplayCommand(opts *options) *cobra.Command {
+	var file, output string
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Run recorded admission reviews through the handlers",
+		Long: "Run the requests of recorded AdmissionReviews through the enabled handlers, against the current state of the " +
+			"cluster, and print the reviews with the new responses.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			format, err := parseReviewFormat(output)
+			if err != nil {
+				return err
+			}
+			reviews, err := readReviews(file, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			handlers, err := opts.startHandlers(cmd.Context())
+			if err != nil {
+				return err
+			}
+			reviewer := &reviewer{validators: handlers.validators, mutators: handlers.mutators}
+
+			results := make([]*admissionv1.AdmissionReview, 0, len(reviews))
+			for _, review := range reviews {
+				response, err := reviewer.review(cmd.Context(), review.Request)
+				if err != nil {
+					return fmt.Errorf("failed to review %s: %w", describeRequest(review.Request), err)
+				}
+				results = append(results, reviewFor(review.Request, response))
+			}
+			return printReviews(cmd.OutOrStdout(), format, results)
+		},
+	}
+	cmd.Flags().StringVarP(&file, "filename", "f", "-", "File holding the AdmissionReviews to replay as YAML documents or JSON objects, - for stdin.")
+	cmd.Flags().StringVarP(&output, "output", "o", string(outputYAML), "Output format, one of yaml, json, policyreport or sarif.")
+	return cmd
+}
+
+func newTestCommand(opts *options) *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Check that the handlers still give recorded admission reviews the same verdict",
+		Long: "Run the requests of AdmissionReviews through the enabled handlers and compare the new responses to the " +
+			"recorded ones. The command fails if any review is now allowed while it was denied or the other way around.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			reviews, err := readReviews(file, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			for i, review := range reviews {
+				if review.Response == nil {
+					return fmt.Errorf("admission review %d in %s has no response to compare to", i, file)
+				}
+			}
+			handlers, err := opts.startHandlers(cmd.Context())
+			if err != nil {
+				return err
+			}
+			reviewer := &reviewer{validators: handlers.validators, mutators: handlers.mutators}
+
+			failed := 0
+			for _, review := range reviews {
+				response, err := reviewer.review(cmd.Context(), review.Request)
+				if err != nil {
+					return fmt.Errorf("failed to review %s: %w", describeRequest(review.Request), err)
+				}
+				if response.Allowed == review.Response.Allowed {
+					fmt.Fprintf(cmd.OutOrStdout(), "PASS %s: %s\n", describeRequest(review.Request), verdict(response))
+					continue
+				}
+				failed++
+				fmt.Fprintf(cmd.OutOrStdout(), "FAIL %s: expected %s, got %s\n", describeRequest(review.Request), verdict(review.Response), verdict(response))
+			}
+			if failed != 0 {
+				return fmt.Errorf("%d of %d admission reviews failed", failed, len(reviews))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "filename", "f", "-", "File holding the AdmissionReviews to test as YAML documents or JSON objects, - for stdin.")
+	return cmd
+}
+
+// describeRequest returns a short description of the request for messages.
+func describeRequest(request *admissionv1.AdmissionRequest) string {
+	name := request.Name
+	if request.Namespace != "" {
+		name = request.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s %s.%s %s", request.Operation, request.Resource.Resource, request.Resource.Group, name)
+}
+
+// verdict returns a short description of the response for messages.
+func verdict(response *admissionv1.AdmissionResponse) string {
+	if response.Allowed {
+		return "allowed"
+	}
+	if response.Result != nil && response.Result.Message != "" {
+		return "denied (" + response.Result.Message + ")"
+	}
+	return "denied"
+}
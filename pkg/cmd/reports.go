@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// outputPolicyReport prints the reviews as wgpolicyk8s.io PolicyReports and ClusterPolicyReports.
+	outputPolicyReport outputFormat = "policyreport"
+	// outputSARIF prints the denials and warnings of the reviews as a SARIF log.
+	outputSARIF outputFormat = "sarif"
+
+	// reportSource is the source and tool name of the reports.
+	reportSource = "rancher-webhook"
+	// reportInformationURI is the documentation of the tool in SARIF logs.
+	reportInformationURI = "https://github.com/rancher/webhook"
+	// policyReportAPIVersion is the API version of the PolicyReports.
+	policyReportAPIVersion = "wgpolicyk8s.io/v1alpha2"
+	// sarifVersion and sarifSchema identify the SARIF format of the logs.
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// ruleReference matches the link to the rule's documentation at the end of the denial messages of registered rules.
+var ruleReference = regexp.MustCompile(`\(see ` + regexp.QuoteMeta(rules.Path) + `/([a-z0-9-]+)\)`)
+
+// parseReviewFormat returns the format reviews are printed in: yaml, json, policyreport or sarif.
+func parseReviewFormat(name string) (outputFormat, error) {
+	switch format := outputFormat(name); format {
+	case outputPolicyReport, outputSARIF:
+		return format, nil
+	}
+	if format, err := parseOutputFormat(name); err == nil {
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown output format %q, must be one of %s, %s, %s or %s", name, outputYAML, outputJSON, outputPolicyReport, outputSARIF)
+}
+
+// printReviews prints the reviews in the format.
+func printReviews(w io.Writer, format outputFormat, reviews []*admissionv1.AdmissionReview) error {
+	switch format {
+	case outputPolicyReport:
+		reports := policyReports(reviews, time.Now())
+		objs := make([]any, 0, len(reports))
+		for _, report := range reports {
+			objs = append(objs, report)
+		}
+		return printObjects(w, outputYAML, objs...)
+	case outputSARIF:
+		return printObjects(w, outputJSON, sarifLog(reviews))
+	}
+	objs := make([]any, 0, len(reviews))
+	for _, review := range reviews {
+		objs = append(objs, review)
+	}
+	return printObjects(w, format, objs...)
+}
+
+// reviewFinding is the outcome of a review, as reported in policy reports and SARIF logs.
+type reviewFinding struct {
+	// ruleID is the ID of the registered rule the denial or warning links to, if any.
+	ruleID string
+	// result is pass, fail or warn.
+	result  string
+	message string
+}
+
+// findings returns the outcomes of a review: a failure for a denial, a warning for each warning of an allowed request,
+// or a pass for an allowed request without warnings.
+func findings(review *admissionv1.AdmissionReview) []reviewFinding {
+	response := review.Response
+	if !response.Allowed {
+		message := "denied"
+		if response.Result != nil && response.Result.Message != "" {
+			message = response.Result.Message
+		}
+		return []reviewFinding{{ruleID: referencedRule(message), result: "fail", message: message}}
+	}
+	if len(response.Warnings) == 0 {
+		return []reviewFinding{{result: "pass", message: "allowed"}}
+	}
+	found := make([]reviewFinding, 0, len(response.Warnings))
+	for _, warning := range response.Warnings {
+		found = append(found, reviewFinding{ruleID: referencedRule(warning), result: "warn", message: warning})
+	}
+	return found
+}
+
+// referencedRule returns the ID of the rule the message links to, empty if it doesn't link to one.
+func referencedRule(message string) string {
+	if match := ruleReference.FindStringSubmatch(message); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// policyName returns the policy of the results of a request, the resource it was validated as.
+func policyName(request *admissionv1.AdmissionRequest) string {
+	if request.Resource.Group == "" {
+		return request.Resource.Resource
+	}
+	return request.Resource.Resource + "." + request.Resource.Group
+}
+
+// policyReport is a wgpolicyk8s.io PolicyReport, or a ClusterPolicyReport for cluster scoped objects.
+type policyReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Summary           policyReportSummary  `json:"summary"`
+	Results           []policyReportResult `json:"results"`
+}
+
+type policyReportSummary struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Skip  int `json:"skip"`
+}
+
+type policyReportResult struct {
+	Source    string                 `json:"source"`
+	Policy    string                 `json:"policy"`
+	Rule      string                 `json:"rule,omitempty"`
+	Result    string                 `json:"result"`
+	Message   string                 `json:"message"`
+	Severity  string                 `json:"severity,omitempty"`
+	Timestamp metav1.Timestamp       `json:"timestamp"`
+	Resources []policyReportResource `json:"resources"`
+}
+
+type policyReportResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// policyReports returns a PolicyReport for each namespace of the reviewed objects and a ClusterPolicyReport for the
+// cluster scoped objects, sorted by namespace.
+func policyReports(reviews []*admissionv1.AdmissionReview, now time.Time) []*policyReport {
+	byNamespace := map[string]*policyReport{}
+	for _, review := range reviews {
+		request := review.Request
+		report, ok := byNamespace[request.Namespace]
+		if !ok {
+			report = &policyReport{
+				TypeMeta:   metav1.TypeMeta{APIVersion: policyReportAPIVersion, Kind: "PolicyReport"},
+				ObjectMeta: metav1.ObjectMeta{Name: reportSource, Namespace: request.Namespace},
+				Results:    []policyReportResult{},
+			}
+			if request.Namespace == "" {
+				report.Kind = "ClusterPolicyReport"
+			}
+			byNamespace[request.Namespace] = report
+		}
+		gvk := request.Kind
+		resource := policyReportResource{
+			APIVersion: metav1.GroupVersion{Group: gvk.Group, Version: gvk.Version}.String(),
+			Kind:       gvk.Kind,
+			Namespace:  request.Namespace,
+			Name:       request.Name,
+		}
+		for _, finding := range findings(review) {
+			result := policyReportResult{
+				Source:    reportSource,
+				Policy:    policyName(request),
+				Rule:      finding.ruleID,
+				Result:    finding.result,
+				Message:   finding.message,
+				Timestamp: metav1.Timestamp{Seconds: now.Unix()},
+				Resources: []policyReportResource{resource},
+			}
+			switch finding.result {
+			case "fail":
+				result.Severity = "high"
+				report.Summary.Fail++
+			case "warn":
+				result.Severity = "medium"
+				report.Summary.Warn++
+			default:
+				report.Summary.Pass++
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	reports := make([]*policyReport, 0, len(byNamespace))
+	for _, report := range byNamespace {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Namespace < reports[j].Namespace })
+	return reports
+}
+
+// sarif is a SARIF log with a single run.
+type sarif struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	HelpURI          string       `json:"helpUri,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLog returns a SARIF log of the denials, as errors, and warnings of the reviews. Results are reported under the
+// rule they link to, or under the resource the request was validated as for the checks that aren't registered rules.
+func sarifLog(reviews []*admissionv1.AdmissionReview) sarif {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: reportSource, InformationURI: reportInformationURI, Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+	seen := map[string]bool{}
+	for _, review := range reviews {
+		request := review.Request
+		name := request.Name
+		if request.Namespace != "" {
+			name = request.Namespace + "/" + name
+		}
+		location := sarifLocation{LogicalLocations: []sarifLogicalLocation{{
+			Name:               name,
+			FullyQualifiedName: policyName(request) + "/" + name,
+			Kind:               "resource",
+		}}}
+		for _, finding := range findings(review) {
+			if finding.result == "pass" {
+				continue
+			}
+			ruleID := finding.ruleID
+			if ruleID == "" {
+				ruleID = policyName(request)
+			}
+			if !seen[ruleID] {
+				seen[ruleID] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRuleFor(ruleID))
+			}
+			level := "error"
+			if finding.result == "warn" {
+				level = "warning"
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    ruleID,
+				Level:     level,
+				Message:   sarifMessage{Text: finding.message},
+				Locations: []sarifLocation{location},
+			})
+		}
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool { return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID })
+	return sarif{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}
+}
+
+// sarifRuleFor describes the rule with the ID, or the validation of the resource with that name if it isn't a
+// registered rule.
+func sarifRuleFor(id string) sarifRule {
+	if rule, ok := rules.Get(id); ok {
+		return sarifRule{ID: id, ShortDescription: sarifMessage{Text: rule.Description}, HelpURI: rule.URL()}
+	}
+	return sarifRule{ID: id, ShortDescription: sarifMessage{Text: "Validation of " + id}}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testReviews() []*admissionv1.AdmissionReview {
+	project := func(name string) *admissionv1.AdmissionRequest {
+		return &admissionv1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"},
+			Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"},
+			Namespace: "c-abc12",
+			Name:      name,
+			Operation: admissionv1.Update,
+		}
+	}
+	namespace := &admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+		Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+		Name:      "team-a",
+		Operation: admissionv1.Delete,
+	}
+	return []*admissionv1.AdmissionReview{
+		reviewFor(project("p-allowed"), &admissionv1.AdmissionResponse{Allowed: true}),
+		reviewFor(project("p-denied"), &admissionv1.AdmissionResponse{Result: &metav1.Status{
+			Message: "project.spec.resourceQuota.usedLimit: Forbidden: the used limit is managed by Rancher (see /rules/project-used-limit)",
+		}}),
+		reviewFor(project("p-warned"), &admissionv1.AdmissionResponse{Allowed: true, Warnings: []string{"quota is almost used"}}),
+		reviewFor(namespace, &admissionv1.AdmissionResponse{Result: &metav1.Status{Message: "namespace is protected"}}),
+	}
+}
+
+func TestPolicyReports(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1700000000, 0)
+	reports := policyReports(testReviews(), now)
+	require.Len(t, reports, 2)
+
+	cluster := reports[0]
+	assert.Equal(t, "ClusterPolicyReport", cluster.Kind)
+	assert.Equal(t, policyReportSummary{Fail: 1}, cluster.Summary)
+	assert.Equal(t, policyReportResult{
+		Source:    "rancher-webhook",
+		Policy:    "namespaces",
+		Result:    "fail",
+		Message:   "namespace is protected",
+		Severity:  "high",
+		Timestamp: metav1.Timestamp{Seconds: now.Unix()},
+		Resources: []policyReportResource{{APIVersion: "v1", Kind: "Namespace", Name: "team-a"}},
+	}, cluster.Results[0])
+
+	namespaced := reports[1]
+	assert.Equal(t, "PolicyReport", namespaced.Kind)
+	assert.Equal(t, "wgpolicyk8s.io/v1alpha2", namespaced.APIVersion)
+	assert.Equal(t, "c-abc12", namespaced.Namespace)
+	assert.Equal(t, policyReportSummary{Pass: 1, Fail: 1, Warn: 1}, namespaced.Summary)
+	require.Len(t, namespaced.Results, 3)
+	assert.Equal(t, "pass", namespaced.Results[0].Result)
+	assert.Equal(t, "project-used-limit", namespaced.Results[1].Rule)
+	assert.Equal(t, "projects.management.cattle.io", namespaced.Results[1].Policy)
+	assert.Equal(t, policyReportResource{APIVersion: "management.cattle.io/v3", Kind: "Project", Namespace: "c-abc12", Name: "p-denied"},
+		namespaced.Results[1].Resources[0])
+	assert.Equal(t, "warn", namespaced.Results[2].Result)
+}
+
+func TestSARIFLog(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	require.NoError(t, printReviews(&out, outputSARIF, testReviews()))
+	var log sarif
+	require.NoError(t, json.Unmarshal(out.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+
+	ids := []string{}
+	for _, rule := range run.Tool.Driver.Rules {
+		ids = append(ids, rule.ID)
+	}
+	assert.Equal(t, []string{"namespaces", "project-used-limit", "projects.management.cattle.io"}, ids)
+	assert.Equal(t, "/rules/project-used-limit", run.Tool.Driver.Rules[1].HelpURI)
+
+	require.Len(t, run.Results, 3, "allowed requests without warnings aren't reported")
+	assert.Equal(t, "project-used-limit", run.Results[0].RuleID)
+	assert.Equal(t, "error", run.Results[0].Level)
+	assert.Equal(t, "projects.management.cattle.io/c-abc12/p-denied", run.Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Equal(t, "warning", run.Results[1].Level)
+	assert.Equal(t, "projects.management.cattle.io", run.Results[1].RuleID)
+	assert.Equal(t, "namespaces", run.Results[2].RuleID)
+}
+
+func TestParseReviewFormat(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"yaml", "json", "policyreport", "sarif"} {
+		format, err := parseReviewFormat(name)
+		require.NoError(t, err)
+		assert.Equal(t, outputFormat(name), format)
+	}
+	_, err := parseReviewFormat("junit")
+	assert.ErrorContains(t, err, "must be one of yaml, json, policyreport or sarif")
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/offline"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// reviewer runs admission requests through the handlers in-process, the way the API server calls the webhooks.
+type reviewer struct {
+	validators []admission.ValidatingAdmissionHandler
+	mutators   []admission.MutatingAdmissionHandler
+}
+
+// review runs the request through the handlers, see offline.Review.
+func (r *reviewer) review(ctx context.Context, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	return offline.Review(ctx, r.validators, r.mutators, request)
+}
+
+// readReviews reads the AdmissionReviews in the YAML or JSON file. A file of "-" reads from stdin.
+func readReviews(file string, stdin io.Reader) ([]*admissionv1.AdmissionReview, error) {
+	var reviews []*admissionv1.AdmissionReview
+	err := decodeFile(file, stdin, func() any {
+		review := &admissionv1.AdmissionReview{}
+		reviews = append(reviews, review)
+		return review
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, review := range reviews {
+		if review.Request == nil {
+			return nil, fmt.Errorf("admission review %d in %s has no request", i, file)
+		}
+	}
+	return reviews, nil
+}
+
+// decodeFile decodes each YAML document or JSON object of the file into a new object returned by next.
+// A file of "-" reads from stdin.
+func decodeFile(file string, stdin io.Reader, next func() any) error {
+	reader := stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		reader = f
+	}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(reader, 4096)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+		if err := json.Unmarshal(raw, next()); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+	}
+}
+
+// reviewFor returns an AdmissionReview holding the request and the response.
+func reviewFor(request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse) *admissionv1.AdmissionReview {
+	return &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Request:  request,
+		Response: response,
+	}
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var clustersGVR = schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "clusters"}
+
+type fakeValidator struct {
+	gvr   schema.GroupVersionResource
+	admit func(*admission.Request) (*admissionv1.AdmissionResponse, error)
+}
+
+func (f *fakeValidator) GVR() schema.GroupVersionResource { return f.gvr }
+
+func (f *fakeValidator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+func (f *fakeValidator) ValidatingWebhook(_ admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return nil
+}
+
+func (f *fakeValidator) Admitters() []admission.Admitter { return []admission.Admitter{f} }
+
+func (f *fakeValidator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	return f.admit(request)
+}
+
+type fakeMutator struct {
+	fakeValidator
+}
+
+func (f *fakeMutator) MutatingWebhook(_ admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.MutatingWebhook {
+	return nil
+}
+
+// labelMutator adds the label to the object.
+func labelMutator(key, value string) *fakeMutator {
+	return &fakeMutator{fakeValidator{gvr: clustersGVR, admit: func(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+		mutated := map[string]any{}
+		if err := json.Unmarshal(request.Object.Raw, &mutated); err != nil {
+			return nil, err
+		}
+		metadata, _ := mutated["metadata"].(map[string]any)
+		labels, _ := metadata["labels"].(map[string]any)
+		if labels == nil {
+			labels = map[string]any{}
+		}
+		labels[key] = value
+		metadata["labels"] = labels
+		patch, err := admission.PatchFromObjects(json.RawMessage(request.Object.Raw), mutated)
+		if err != nil {
+			return nil, err
+		}
+		response := admission.ResponseAllowedWithPatch(patch)
+		response.Warnings = []string{"added label " + key}
+		return response, nil
+	}}}
+}
+
+// labelValidator denies objects without the label.
+func labelValidator(key string) *fakeValidator {
+	return &fakeValidator{gvr: clustersGVR, admit: func(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+		if strings.Contains(string(request.Object.Raw), `"`+key+`"`) {
+			return admission.ResponseAllowed(), nil
+		}
+		return admission.ResponseBadRequest("missing label " + key), nil
+	}}
+}
+
+func clusterRequest(operation admissionv1.Operation) *admissionv1.AdmissionRequest {
+	return &admissionv1.AdmissionRequest{
+		UID:       "1",
+		Resource:  metav1.GroupVersionResource(clustersGVR),
+		Name:      "c-1",
+		Operation: operation,
+		Object:    runtime.RawExtension{Raw: []byte(`{"apiVersion":"management.cattle.io/v3","kind":"Cluster","metadata":{"name":"c-1"}}`)},
+	}
+}
+
+func TestReview(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		reviewer     *reviewer
+		request      *admissionv1.AdmissionRequest
+		wantAllowed  bool
+		wantMessage  string
+		wantPatch    string
+		wantWarnings []string
+		wantErr      bool
+	}{
+		{
+			name:        "no handlers",
+			reviewer:    &reviewer{},
+			request:     clusterRequest(admissionv1.Create),
+			wantAllowed: true,
+		},
+		{
+			name:        "validator denies",
+			reviewer:    &reviewer{validators: []admission.ValidatingAdmissionHandler{labelValidator("team")}},
+			request:     clusterRequest(admissionv1.Create),
+			wantMessage: "missing label team",
+		},
+		{
+			name: "validators see the mutated object",
+			reviewer: &reviewer{
+				validators: []admission.ValidatingAdmissionHandler{labelValidator("team")},
+				mutators:   []admission.MutatingAdmissionHandler{labelMutator("team", "a")},
+			},
+			request:      clusterRequest(admissionv1.Create),
+			wantAllowed:  true,
+			wantPatch:    `[{"op":"add","path":"/metadata/labels","value":{"team":"a"}}]`,
+			wantWarnings: []string{"added label team"},
+		},
+		{
+			name: "patches of all mutators are combined",
+			reviewer: &reviewer{
+				mutators: []admission.MutatingAdmissionHandler{labelMutator("team", "a"), labelMutator("env", "prod")},
+			},
+			request:      clusterRequest(admissionv1.Create),
+			wantAllowed:  true,
+			wantPatch:    `[{"op":"add","path":"/metadata/labels","value":{"env":"prod","team":"a"}}]`,
+			wantWarnings: []string{"added label team", "added label env"},
+		},
+		{
+			name: "handlers of other resources are skipped",
+			reviewer: &reviewer{validators: []admission.ValidatingAdmissionHandler{&fakeValidator{
+				gvr: schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"},
+				admit: func(_ *admission.Request) (*admissionv1.AdmissionResponse, error) {
+					return admission.ResponseBadRequest("denied"), nil
+				},
+			}}},
+			request:     clusterRequest(admissionv1.Create),
+			wantAllowed: true,
+		},
+		{
+			name:        "handlers of other operations are skipped",
+			reviewer:    &reviewer{validators: []admission.ValidatingAdmissionHandler{labelValidator("team")}},
+			request:     clusterRequest(admissionv1.Delete),
+			wantAllowed: true,
+		},
+		{
+			name: "handler error",
+			reviewer: &reviewer{validators: []admission.ValidatingAdmissionHandler{&fakeValidator{
+				gvr: clustersGVR,
+				admit: func(_ *admission.Request) (*admissionv1.AdmissionResponse, error) {
+					return nil, errors.New("cache not synced")
+				},
+			}}},
+			request: clusterRequest(admissionv1.Create),
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			original := test.request.DeepCopy()
+			response, err := test.reviewer.review(context.Background(), test.request)
+			assert.Equal(t, original, test.request, "the request must not be modified")
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.request.UID, response.UID)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+			if test.wantMessage != "" {
+				require.NotNil(t, response.Result)
+				assert.Equal(t, test.wantMessage, response.Result.Message)
+			}
+			if test.wantPatch != "" {
+				assert.JSONEq(t, test.wantPatch, string(response.Patch))
+			} else {
+				assert.Empty(t, response.Patch)
+			}
+			assert.Equal(t, test.wantWarnings, response.Warnings)
+		})
+	}
+}
+
+func TestReadReviews(t *testing.T) {
+	t.Parallel()
+	input := `apiVersion: admission.k8s.io/v1
+kind: AdmissionReview
+request:
+  uid: "1"
+  operation: CREATE
+---
+{"apiVersion": "admission.k8s.io/v1", "kind": "AdmissionReview", "request": {"uid": "2", "operation": "DELETE"}, "response": {"allowed": true}}
+`
+	reviews, err := readReviews("-", strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, reviews, 2)
+	assert.Equal(t, admissionv1.Create, reviews[0].Request.Operation)
+	assert.Nil(t, reviews[0].Response)
+	assert.Equal(t, admissionv1.Delete, reviews[1].Request.Operation)
+	assert.True(t, reviews[1].Response.Allowed)
+
+	_, err = readReviews("-", strings.NewReader("apiVersion: admission.k8s.io/v1\nkind: AdmissionReview\n"))
+	assert.Error(t, err, "reviews without a request must be rejected")
+}
@@ -0,0 +1,632 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	scaffoldModule       = "github.com/rancher/webhook"
+	scaffoldCodegenFile  = "pkg/codegen/main.go"
+	scaffoldHandlersFile = "pkg/server/handlers.go"
+	// scaffoldMCMGroup is the group whose handlers are only registered when multi-cluster management is enabled.
+	scaffoldMCMGroup = "management.cattle.io"
+)
+
+// scaffoldOptions are the flags of the scaffold command.
+type scaffoldOptions struct {
+	root       string
+	group      string
+	version    string
+	kind       string
+	resource   string
+	namespaced bool
+	mutator    bool
+}
+
+// scaffold holds the names used by the templates of a new resource.
+type scaffold struct {
+	Group      string
+	APIGroup   string
+	Version    string
+	Kind       string
+	Resource   string
+	Package    string
+	LowerKind  string
+	Scope      string
+	APIAlias   string
+	APIImport  string
+	Objects    string
+	Mutator    bool
+	Namespaced bool
+}
+
+func newScaffoldCommand() *cobra.Command {
+	opts := &scaffoldOptions{}
+	cmd := &cobra.Command{
+		Use:   "scaffold",
+		Short: "Generate the skeleton of the handlers of a new resource",
+		Long: `Generates the validator, optionally the mutator, the documentation and a table-driven test of a new resource in
+pkg/resources/<group>/<version>/<kind>, adds the resource to the types whose decode helpers are generated in
+pkg/codegen/main.go, and registers the handlers in pkg/server/handlers.go. Run go generate afterwards to generate the
+decode helpers and the documentation.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runScaffold(cmd.OutOrStdout(), opts)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&opts.root, "root", ".", "Root of the webhook repository.")
+	flags.StringVar(&opts.group, "group", "", "API group of the resource, core for the core group, e.g. management.cattle.io.")
+	flags.StringVar(&opts.version, "version", "", "API version of the resource, e.g. v3.")
+	flags.StringVar(&opts.kind, "kind", "", "Kind of the resource, e.g. Foo.")
+	flags.StringVar(&opts.resource, "resource", "", "Plural name of the resource. Defaults to the lowercase kind followed by s.")
+	flags.BoolVar(&opts.namespaced, "namespaced", false, "Whether the resource is namespaced.")
+	flags.BoolVar(&opts.mutator, "mutator", false, "Also generate a mutator.")
+	_ = cmd.MarkFlagRequired("group")
+	_ = cmd.MarkFlagRequired("version")
+	_ = cmd.MarkFlagRequired("kind")
+	return cmd
+}
+
+// runScaffold generates the files of the new resource and wires it into the code generator and the handlers.
+func runScaffold(out io.Writer, opts *scaffoldOptions) error {
+	s, err := newScaffold(opts)
+	if err != nil {
+		return err
+	}
+	codegenPath := filepath.Join(opts.root, scaffoldCodegenFile)
+	codegen, err := os.ReadFile(codegenPath)
+	if err != nil {
+		return err
+	}
+	codegen, err = s.addObjectType(codegen)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to %s: %w", s.Kind, scaffoldCodegenFile, err)
+	}
+	handlersPath := filepath.Join(opts.root, scaffoldHandlersFile)
+	handlers, err := os.ReadFile(handlersPath)
+	if err != nil {
+		return err
+	}
+	handlers, err = s.registerHandlers(handlers)
+	if err != nil {
+		return fmt.Errorf("failed to register the handlers in %s: %w", scaffoldHandlersFile, err)
+	}
+
+	dir := filepath.Join(opts.root, "pkg", "resources", s.Group, s.Version, s.Package)
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists", dir)
+	}
+	files := map[string]string{
+		"validator.go":      validatorTemplate,
+		"validator_test.go": validatorTestTemplate,
+		s.Kind + ".md":      docTemplate,
+	}
+	if s.Mutator {
+		files["mutator.go"] = mutatorTemplate
+	}
+	generated := map[string][]byte{}
+	for name, text := range files {
+		content, err := s.render(name, text)
+		if err != nil {
+			return err
+		}
+		generated[filepath.Join(dir, name)] = content
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	generated[codegenPath] = codegen
+	generated[handlersPath] = handlers
+	paths := make([]string, 0, len(generated))
+	for path := range generated {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := os.WriteFile(path, generated[path], 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "wrote %s\n", path)
+	}
+	fmt.Fprintln(out, "run go generate to generate the decode helpers and the documentation")
+	return nil
+}
+
+func newScaffold(opts *scaffoldOptions) (*scaffold, error) {
+	if opts.group == "" || opts.version == "" || opts.kind == "" {
+		return nil, errors.New("group, version and kind are required")
+	}
+	if !token.IsIdentifier(opts.kind) || !token.IsExported(opts.kind) {
+		return nil, fmt.Errorf("kind %q must be an exported Go identifier", opts.kind)
+	}
+	lowerKind := strings.ToLower(opts.kind[:1]) + opts.kind[1:]
+	s := &scaffold{
+		Group:      opts.group,
+		APIGroup:   opts.group,
+		Version:    opts.version,
+		Kind:       opts.kind,
+		Resource:   opts.resource,
+		Package:    strings.ToLower(opts.kind),
+		LowerKind:  lowerKind,
+		Scope:      "ClusterScope",
+		Objects:    "objects" + opts.version,
+		Mutator:    opts.mutator,
+		Namespaced: opts.namespaced,
+	}
+	if s.Resource == "" {
+		s.Resource = strings.ToLower(opts.kind) + "s"
+	}
+	if opts.group == "core" {
+		s.APIGroup = ""
+	}
+	if opts.namespaced {
+		s.Scope = "NamespacedScope"
+	}
+	return s, nil
+}
+
+func (s *scaffold) render(name, text string) ([]byte, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, s); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	if !strings.HasSuffix(name, ".go") {
+		return buf.Bytes(), nil
+	}
+	content, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format %s: %w", name, err)
+	}
+	return content, nil
+}
+
+// insertion is text inserted at an offset of a file.
+type insertion struct {
+	offset int
+	text   string
+}
+
+// applyInsertions inserts the texts, and formats the result.
+func applyInsertions(src []byte, insertions []insertion) ([]byte, error) {
+	sort.Slice(insertions, func(i, j int) bool { return insertions[i].offset > insertions[j].offset })
+	for _, ins := range insertions {
+		src = append(src[:ins.offset], append([]byte(ins.text), src[ins.offset:]...)...)
+	}
+	return format.Source(src)
+}
+
+// addObjectType adds the kind to the types of its group whose decode helpers are generated, and sets the alias and
+// import path of the API package of the group, which the group's existing types use.
+func (s *scaffold) addObjectType(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, scaffoldCodegenFile, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var types *ast.CompositeLit
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || types != nil {
+			return types == nil
+		}
+		if ident, ok := call.Fun.(*ast.Ident); !ok || ident.Name != "generateObjectsFromRequest" || len(call.Args) != 2 {
+			return true
+		}
+		groups, ok := call.Args[1].(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		for _, elt := range groups.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			if key, ok := kv.Key.(*ast.BasicLit); ok && key.Value == strconv.Quote(s.Group) {
+				types = groupTypes(kv.Value)
+			}
+		}
+		return false
+	})
+	if types == nil || len(types.Elts) == 0 {
+		return nil, fmt.Errorf("group %s has no generated types, add it to generateObjectsFromRequest first", s.Group)
+	}
+	for _, elt := range types.Elts {
+		alias, kind := typeName(elt)
+		if alias == "" {
+			continue
+		}
+		s.APIAlias = alias
+		if kind == s.Kind {
+			return nil, fmt.Errorf("%s is already generated", s.Kind)
+		}
+	}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == s.APIAlias {
+			s.APIImport = path
+		}
+	}
+	if s.APIImport == "" {
+		return nil, fmt.Errorf("import of %s not found", s.APIAlias)
+	}
+	return applyInsertions(src, []insertion{{
+		offset: fset.Position(types.Rbrace).Offset,
+		text:   fmt.Sprintf("&%s.%s{},\n", s.APIAlias, s.Kind),
+	}})
+}
+
+// groupTypes returns the Types field of an args.Group literal.
+func groupTypes(group ast.Expr) *ast.CompositeLit {
+	lit, ok := group.(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		if key, ok := kv.Key.(*ast.Ident); ok && key.Name == "Types" {
+			types, _ := kv.Value.(*ast.CompositeLit)
+			return types
+		}
+	}
+	return nil
+}
+
+// typeName returns the package alias and name of a &alias.Kind{} expression.
+func typeName(expr ast.Expr) (string, string) {
+	unary, ok := expr.(*ast.UnaryExpr)
+	if !ok {
+		return "", ""
+	}
+	lit, ok := unary.X.(*ast.CompositeLit)
+	if !ok {
+		return "", ""
+	}
+	sel, ok := lit.Type.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", ""
+	}
+	return ident.Name, sel.Sel.Name
+}
+
+// registerHandlers imports the package of the new resource and adds its handlers to the Validation and Mutation
+// functions. Handlers of the management group are only added when multi-cluster management is enabled.
+func (s *scaffold) registerHandlers(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, scaffoldHandlersFile, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	importPath := fmt.Sprintf("%s/pkg/resources/%s/%s/%s", scaffoldModule, s.Group, s.Version, s.Package)
+	var lastImport *ast.ImportSpec
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		if path == importPath {
+			return nil, fmt.Errorf("%s is already imported", importPath)
+		}
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == s.Package {
+			return nil, fmt.Errorf("package name %s is already used by %s", s.Package, path)
+		}
+		lastImport = spec
+	}
+	if lastImport == nil {
+		return nil, errors.New("no imports found")
+	}
+	insertions := []insertion{{
+		offset: fset.Position(lastImport.End()).Offset,
+		text:   fmt.Sprintf("\n%q", importPath),
+	}}
+
+	register := func(function, list string, constructor string) error {
+		offset, comma, err := handlerListEnd(src, fset, file, function, list, s.Group == scaffoldMCMGroup)
+		if err != nil {
+			return err
+		}
+		if comma {
+			constructor = ",\n" + constructor
+		}
+		insertions = append(insertions, insertion{offset: offset, text: constructor + ",\n"})
+		return nil
+	}
+	if err := register("Validation", "handlers", s.Package+".NewValidator()"); err != nil {
+		return nil, err
+	}
+	if s.Mutator {
+		if err := register("Mutation", "mutators", s.Package+".NewMutator()"); err != nil {
+			return nil, err
+		}
+	}
+	return applyInsertions(src, insertions)
+}
+
+// handlerListEnd returns the offset at which a handler is added to the function: the end of the list literal
+// initializing the variable, or the end of the arguments of the call appending to it when multi-cluster management is
+// enabled. It also returns whether a comma must separate the handler from the previous element.
+func handlerListEnd(src []byte, fset *token.FileSet, file *ast.File, function, variable string, mcm bool) (int, bool, error) {
+	var fn *ast.FuncDecl
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.FuncDecl); ok && d.Name.Name == function {
+			fn = d
+		}
+	}
+	if fn == nil {
+		return 0, false, fmt.Errorf("function %s not found", function)
+	}
+	offset, comma := -1, false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || offset >= 0 || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+			return offset < 0
+		}
+		if ident, ok := assign.Lhs[0].(*ast.Ident); !ok || ident.Name != variable {
+			return true
+		}
+		var elements []ast.Expr
+		switch rhs := assign.Rhs[0].(type) {
+		case *ast.CompositeLit:
+			if mcm || assign.Tok != token.DEFINE {
+				return true
+			}
+			offset, elements = fset.Position(rhs.Rbrace).Offset, rhs.Elts
+		case *ast.CallExpr:
+			if ident, ok := rhs.Fun.(*ast.Ident); !mcm || !ok || ident.Name != "append" || len(rhs.Args) < 2 {
+				return true
+			}
+			offset, elements = fset.Position(rhs.Rparen).Offset, rhs.Args
+		default:
+			return true
+		}
+		if len(elements) > 0 {
+			last := fset.Position(elements[len(elements)-1].End()).Offset
+			comma = !bytes.Contains(src[last:offset], []byte(","))
+		}
+		return false
+	})
+	if offset < 0 {
+		return 0, false, fmt.Errorf("list of %s not found in %s", variable, function)
+	}
+	return offset, comma, nil
+}
+
+const validatorTemplate = `package {{.Package}}
+
+import (
+	"fmt"
+
+	{{.APIAlias}} "{{.APIImport}}"
+	"github.com/rancher/webhook/pkg/admission"
+	{{.Objects}} "github.com/rancher/webhook/pkg/generated/objects/{{.Group}}/{{.Version}}"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+var gvr = schema.GroupVersionResource{
+	Group:    "{{.APIGroup}}",
+	Version:  "{{.Version}}",
+	Resource: "{{.Resource}}",
+}
+
+// Validator validates {{.Resource}}.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new validator for {{.Resource}}.
+func NewValidator() *Validator {
+	return &Validator{
+		admitter: admitter{},
+	}
+}
+
+// GVR returns the GroupVersionResource for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{
+		*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.{{.Scope}}, v.Operations()),
+	}
+}
+
+// Admitters returns the admitter objects used to validate {{.Resource}}.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct{}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("{{.LowerKind}}Validator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	old{{.Kind}}, new{{.Kind}}, err := {{.Objects}}.{{.Kind}}OldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old and new {{.Resource}} from request: %w", err)
+	}
+	if fieldErr := validate{{.Kind}}(old{{.Kind}}, new{{.Kind}}); fieldErr != nil {
+		return admission.ResponseDenied(admission.DenialInvalid, fieldErr.Error()), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// validate{{.Kind}} checks the new {{.LowerKind}}. The old {{.LowerKind}} is empty on create.
+func validate{{.Kind}}(_, _ *{{.APIAlias}}.{{.Kind}}) *field.Error {
+	return nil
+}
+`
+
+const mutatorTemplate = `package {{.Package}}
+
+import (
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	{{.Objects}} "github.com/rancher/webhook/pkg/generated/objects/{{.Group}}/{{.Version}}"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+// Mutator implements admission.MutatingAdmissionHandler for {{.Resource}}.
+type Mutator struct{}
+
+// NewMutator returns a new mutator for {{.Resource}}.
+func NewMutator() *Mutator {
+	return &Mutator{}
+}
+
+// GVR returns the GroupVersionResource for this CRD.
+func (m *Mutator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this mutator.
+func (m *Mutator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+}
+
+// MutatingWebhook returns the MutatingWebhook used for this CRD.
+func (m *Mutator) MutatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.MutatingWebhook {
+	return []admissionregistrationv1.MutatingWebhook{
+		*admission.NewDefaultMutatingWebhook(m, clientConfig, admissionregistrationv1.{{.Scope}}, m.Operations()),
+	}
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (m *Mutator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("{{.LowerKind}}Mutator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	{{.LowerKind}}, err := {{.Objects}}.{{.Kind}}FromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get {{.LowerKind}} from request: %w", err)
+	}
+	new{{.Kind}} := {{.LowerKind}}.DeepCopy()
+
+	patch, err := admission.PatchFromObjects({{.LowerKind}}, new{{.Kind}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create patch: %w", err)
+	}
+	return admission.ResponseAllowedWithPatch(patch), nil
+}
+`
+
+const validatorTestTemplate = `package {{.Package}}
+
+import (
+	"encoding/json"
+	"testing"
+
+	{{.APIAlias}} "{{.APIImport}}"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		old{{.Kind}}   *{{.APIAlias}}.{{.Kind}}
+		new{{.Kind}}   *{{.APIAlias}}.{{.Kind}}
+		wantAllowed bool
+	}{
+		{
+			name:        "create",
+			operation:   admissionv1.Create,
+			new{{.Kind}}:   &{{.APIAlias}}.{{.Kind}}{ObjectMeta: metav1.ObjectMeta{Name: "test"}},
+			wantAllowed: true,
+		},
+		{
+			name:        "update",
+			operation:   admissionv1.Update,
+			old{{.Kind}}:   &{{.APIAlias}}.{{.Kind}}{ObjectMeta: metav1.ObjectMeta{Name: "test"}},
+			new{{.Kind}}:   &{{.APIAlias}}.{{.Kind}}{ObjectMeta: metav1.ObjectMeta{Name: "test"}},
+			wantAllowed: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UID:       "1",
+				Kind:      metav1.GroupVersionKind{Group: gvr.Group, Version: gvr.Version, Kind: "{{.Kind}}"},
+				Resource:  metav1.GroupVersionResource(gvr),
+				Operation: test.operation,
+				UserInfo:  authenticationv1.UserInfo{Username: "u-test"},
+			}}
+			var err error
+			request.Object.Raw, err = json.Marshal(test.new{{.Kind}})
+			require.NoError(t, err)
+			if test.old{{.Kind}} != nil {
+				request.OldObject.Raw, err = json.Marshal(test.old{{.Kind}})
+				require.NoError(t, err)
+			}
+
+			validator := NewValidator()
+			response, err := validator.Admitters()[0].Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+		})
+	}
+}
+`
+
+const docTemplate = `## Validation Checks
+
+### {{.Kind}} validation
+
+Describe the checks of the {{.Kind}} validator here.
+`
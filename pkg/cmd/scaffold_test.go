@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scaffoldTestCodegen = `package main
+
+import (
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func main() {
+	generateObjectsFromRequest("pkg/generated/objects", map[string]args.Group{
+		"management.cattle.io": {
+			Types: []interface{}{
+				&v3.Cluster{},
+			},
+		},
+		"core": {
+			Types: []interface{}{
+				&corev1.Secret{},
+			},
+		},
+	})
+}
+`
+
+const scaffoldTestHandlers = `package server
+
+import (
+	"github.com/rancher/webhook/pkg/resources/core/v1/namespace"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/project"
+)
+
+func Validation(clients *clients.Clients) ([]admission.ValidatingAdmissionHandler, error) {
+	handlers := []admission.ValidatingAdmissionHandler{
+		namespace.NewValidator(),
+	}
+	if clients.MultiClusterManagement {
+		handlers = append(
+			handlers,
+			project.NewValidator(),
+		)
+	}
+	return handlers, nil
+}
+
+func Mutation(clients *clients.Clients) ([]admission.MutatingAdmissionHandler, error) {
+	mutators := []admission.MutatingAdmissionHandler{}
+	if clients.MultiClusterManagement {
+		mutators = append(mutators, project.NewMutator())
+	}
+	return mutators, nil
+}
+`
+
+func TestScaffold(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		opts         scaffoldOptions
+		wantFiles    []string
+		wantCodegen  []string
+		wantHandlers []string
+		wantErr      string
+	}{
+		{
+			name:      "management validator and mutator",
+			opts:      scaffoldOptions{group: "management.cattle.io", version: "v3", kind: "Foo", mutator: true},
+			wantFiles: []string{"validator.go", "validator_test.go", "mutator.go", "Foo.md"},
+			wantCodegen: []string{
+				"&v3.Cluster{},\n\t\t\t\t&v3.Foo{},\n",
+			},
+			wantHandlers: []string{
+				`"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/foo"`,
+				"project.NewValidator(),\n\t\t\tfoo.NewValidator(),\n\t\t)",
+				"mutators = append(mutators, project.NewMutator(),\n\t\t\tfoo.NewMutator(),\n\t\t)",
+			},
+		},
+		{
+			name:      "core validator",
+			opts:      scaffoldOptions{group: "core", version: "v1", kind: "ConfigMap", namespaced: true},
+			wantFiles: []string{"validator.go", "validator_test.go", "ConfigMap.md"},
+			wantCodegen: []string{
+				"&corev1.Secret{},\n\t\t\t\t&corev1.ConfigMap{},\n",
+			},
+			wantHandlers: []string{
+				`"github.com/rancher/webhook/pkg/resources/core/v1/configmap"`,
+				"namespace.NewValidator(),\n\t\tconfigmap.NewValidator(),\n\t}",
+			},
+		},
+		{
+			name:    "group without generated types",
+			opts:    scaffoldOptions{group: "catalog.cattle.io", version: "v1", kind: "App"},
+			wantErr: "group catalog.cattle.io has no generated types",
+		},
+		{
+			name:    "kind already generated",
+			opts:    scaffoldOptions{group: "management.cattle.io", version: "v3", kind: "Cluster"},
+			wantErr: "Cluster is already generated",
+		},
+		{
+			name:    "package name already used",
+			opts:    scaffoldOptions{group: "management.cattle.io", version: "v3", kind: "Namespace"},
+			wantErr: "package name namespace is already used",
+		},
+		{
+			name:    "unexported kind",
+			opts:    scaffoldOptions{group: "management.cattle.io", version: "v3", kind: "foo"},
+			wantErr: "must be an exported Go identifier",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			root := t.TempDir()
+			writeScaffoldFile(t, filepath.Join(root, scaffoldCodegenFile), scaffoldTestCodegen)
+			writeScaffoldFile(t, filepath.Join(root, scaffoldHandlersFile), scaffoldTestHandlers)
+			opts := test.opts
+			opts.root = root
+
+			var out bytes.Buffer
+			err := runScaffold(&out, &opts)
+			if test.wantErr != "" {
+				require.ErrorContains(t, err, test.wantErr)
+				handlers, err := os.ReadFile(filepath.Join(root, scaffoldHandlersFile))
+				require.NoError(t, err)
+				assert.Equal(t, scaffoldTestHandlers, string(handlers), "files must be left unchanged on errors")
+				return
+			}
+			require.NoError(t, err)
+
+			s, err := newScaffold(&opts)
+			require.NoError(t, err)
+			dir := filepath.Join(root, "pkg", "resources", opts.group, opts.version, s.Package)
+			for _, name := range test.wantFiles {
+				assert.FileExists(t, filepath.Join(dir, name))
+			}
+			codegen, err := os.ReadFile(filepath.Join(root, scaffoldCodegenFile))
+			require.NoError(t, err)
+			for _, want := range test.wantCodegen {
+				assert.Contains(t, string(codegen), want)
+			}
+			handlers, err := os.ReadFile(filepath.Join(root, scaffoldHandlersFile))
+			require.NoError(t, err)
+			for _, want := range test.wantHandlers {
+				assert.Contains(t, string(handlers), want)
+			}
+
+			validator, err := os.ReadFile(filepath.Join(dir, "validator.go"))
+			require.NoError(t, err)
+			assert.Contains(t, string(validator), "Resource: \""+s.Resource+"\"")
+			assert.Contains(t, string(validator), s.Objects+"."+opts.kind+"OldAndNewFromRequest")
+		})
+	}
+}
+
+func writeScaffoldFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"github.com/rancher/webhook/pkg/server"
+	"github.com/rancher/wrangler/v3/pkg/k8scheck"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newServeCommand(opts *options, version, gitCommit string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the webhook and register it with the cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			logrus.Infof("Rancher-webhook version %s (%s) is starting", version, gitCommit)
+			server.SetVersion(version, gitCommit)
+
+			shard, err := opts.shard()
+			if err != nil {
+				return err
+			}
+			certOpts, err := opts.certOptions()
+			if err != nil {
+				return err
+			}
+			cfg, err := opts.restConfig()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			if err := k8scheck.Wait(ctx, *cfg); err != nil {
+				return err
+			}
+			if err := server.ListenAndServe(ctx, cfg, opts.mcm, opts.enabledHandlers(), shard, certOpts); err != nil {
+				return err
+			}
+
+			<-ctx.Done()
+			logrus.Info("Rancher-webhook is shutting down")
+			server.WaitForShutdown()
+			return nil
+		},
+	}
+	opts.addCertFlags(cmd.Flags())
+	return cmd
+}
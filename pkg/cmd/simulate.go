@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/spf13/cobra"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// simulateOptions are the flags of the simulate command.
+type simulateOptions struct {
+	file      string
+	oldFile   string
+	operation string
+	user      string
+	groups    []string
+	output    string
+}
+
+func newSimulateCommand(opts *options) *cobra.Command {
+	simulateOpts := &simulateOptions{}
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Run objects from manifests through the handlers as if they were applied",
+		Long: "Build an admission request for each object of a manifest and run it through the enabled handlers, " +
+			"against the current state of the cluster, as the given user. The objects are not applied. Prints the reviews " +
+			"with the responses.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			format, err := parseReviewFormat(simulateOpts.output)
+			if err != nil {
+				return err
+			}
+			objects, err := readObjects(simulateOpts.file, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			var oldObjects []*unstructured.Unstructured
+			if simulateOpts.oldFile != "" {
+				if oldObjects, err = readObjects(simulateOpts.oldFile, cmd.InOrStdin()); err != nil {
+					return err
+				}
+			}
+			handlers, err := opts.startHandlers(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			requests, err := simulateOpts.requests(objects, oldObjects, func(obj *unstructured.Unstructured) (metav1.GroupVersionResource, error) {
+				gvk := obj.GroupVersionKind()
+				mapping, err := handlers.clients.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+				if err != nil {
+					return metav1.GroupVersionResource{}, fmt.Errorf("failed to find the resource of %s: %w", gvk, err)
+				}
+				return metav1.GroupVersionResource(mapping.Resource), nil
+			})
+			if err != nil {
+				return err
+			}
+
+			reviewer := &reviewer{validators: handlers.validators, mutators: handlers.mutators}
+			results := make([]*admissionv1.AdmissionReview, 0, len(requests))
+			for _, request := range requests {
+				response, err := reviewer.review(cmd.Context(), request)
+				if err != nil {
+					return fmt.Errorf("failed to review %s: %w", describeRequest(request), err)
+				}
+				results = append(results, reviewFor(request, response))
+			}
+			return printReviews(cmd.OutOrStdout(), format, results)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&simulateOpts.file, "filename", "f", "-", "Manifest holding the objects to simulate as YAML documents or JSON objects, - for stdin. On DELETE these are the deleted objects.")
+	flags.StringVar(&simulateOpts.oldFile, "old-filename", "", "Manifest holding the current version of the objects, in the same order, required on UPDATE.")
+	flags.StringVar(&simulateOpts.operation, "operation", string(admissionv1.Create), "Operation to simulate, one of CREATE, UPDATE or DELETE.")
+	flags.StringVar(&simulateOpts.user, "as", "admin", "Username of the user to simulate the requests as.")
+	flags.StringSliceVar(&simulateOpts.groups, "as-group", []string{"system:authenticated"}, "Groups of the user to simulate the requests as.")
+	flags.StringVarP(&simulateOpts.output, "output", "o", string(outputYAML), "Output format, one of yaml, json, policyreport or sarif.")
+	return cmd
+}
+
+// requests builds a dry-run admission request for each object. resourceFor returns the resource of an object.
+func (o *simulateOptions) requests(objects, oldObjects []*unstructured.Unstructured, resourceFor func(*unstructured.Unstructured) (metav1.GroupVersionResource, error)) ([]*admissionv1.AdmissionRequest, error) {
+	operation := admissionv1.Operation(strings.ToUpper(o.operation))
+	switch operation {
+	case admissionv1.Create, admissionv1.Delete:
+		if len(oldObjects) != 0 {
+			return nil, fmt.Errorf("old objects can only be given on %s", admissionv1.Update)
+		}
+	case admissionv1.Update:
+		if len(oldObjects) != len(objects) {
+			return nil, fmt.Errorf("%s requires an old object for each of the %d objects, got %d", admissionv1.Update, len(objects), len(oldObjects))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported operation %q, must be one of %s, %s or %s", o.operation, admissionv1.Create, admissionv1.Update, admissionv1.Delete)
+	}
+
+	requests := make([]*admissionv1.AdmissionRequest, 0, len(objects))
+	for i, obj := range objects {
+		resource, err := resourceFor(obj)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", obj.GetName(), err)
+		}
+		gvk := obj.GroupVersionKind()
+		request := &admissionv1.AdmissionRequest{
+			UID:       uuid.NewUUID(),
+			Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+			Resource:  resource,
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Operation: operation,
+			UserInfo: authenticationv1.UserInfo{
+				Username: o.user,
+				Groups:   o.groups,
+			},
+			DryRun: admission.Ptr(true),
+		}
+		switch operation {
+		case admissionv1.Create:
+			request.Object = runtime.RawExtension{Raw: raw}
+		case admissionv1.Update:
+			oldRaw, err := json.Marshal(oldObjects[i])
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal old %s: %w", obj.GetName(), err)
+			}
+			request.Object = runtime.RawExtension{Raw: raw}
+			request.OldObject = runtime.RawExtension{Raw: oldRaw}
+		case admissionv1.Delete:
+			request.OldObject = runtime.RawExtension{Raw: raw}
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// readObjects reads the objects of the manifest. A file of "-" reads from stdin.
+func readObjects(file string, stdin io.Reader) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	err := decodeFile(file, stdin, func() any {
+		obj := &unstructured.Unstructured{}
+		objects = append(objects, obj)
+		return obj
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, obj := range objects {
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("object %d in %s has no apiVersion or kind", i, file)
+		}
+	}
+	return objects, nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const clusterManifest = `apiVersion: management.cattle.io/v3
+kind: Cluster
+metadata:
+  name: c-1
+---
+apiVersion: management.cattle.io/v3
+kind: Project
+metadata:
+  name: p-1
+  namespace: c-1
+`
+
+func clusterResourceFor(obj *unstructured.Unstructured) (metav1.GroupVersionResource, error) {
+	switch obj.GetKind() {
+	case "Cluster":
+		return metav1.GroupVersionResource(clustersGVR), nil
+	case "Project":
+		return metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"}, nil
+	}
+	return metav1.GroupVersionResource{}, errors.New("unknown kind")
+}
+
+func TestSimulateRequests(t *testing.T) {
+	t.Parallel()
+	objects, err := readObjects("-", strings.NewReader(clusterManifest))
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+
+	tests := []struct {
+		name       string
+		operation  string
+		oldObjects []*unstructured.Unstructured
+		wantErr    bool
+	}{
+		{
+			name:      "create",
+			operation: "create",
+		},
+		{
+			name:       "update",
+			operation:  "UPDATE",
+			oldObjects: objects,
+		},
+		{
+			name:      "delete",
+			operation: "DELETE",
+		},
+		{
+			name:      "update without old objects",
+			operation: "UPDATE",
+			wantErr:   true,
+		},
+		{
+			name:       "create with old objects",
+			operation:  "CREATE",
+			oldObjects: objects,
+			wantErr:    true,
+		},
+		{
+			name:      "unsupported operation",
+			operation: "CONNECT",
+			wantErr:   true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			opts := &simulateOptions{operation: test.operation, user: "u-1", groups: []string{"admins"}}
+			requests, err := opts.requests(objects, test.oldObjects, clusterResourceFor)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, requests, 2)
+
+			cluster, project := requests[0], requests[1]
+			assert.Equal(t, admissionv1.Operation(strings.ToUpper(test.operation)), cluster.Operation)
+			assert.Equal(t, metav1.GroupVersionResource(clustersGVR), cluster.Resource)
+			assert.Equal(t, metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Cluster"}, cluster.Kind)
+			assert.Equal(t, "c-1", cluster.Name)
+			assert.Equal(t, "projects", project.Resource.Resource)
+			assert.Equal(t, "c-1", project.Namespace)
+			assert.Equal(t, "u-1", cluster.UserInfo.Username)
+			assert.Equal(t, []string{"admins"}, cluster.UserInfo.Groups)
+			assert.True(t, *cluster.DryRun)
+			assert.NotEqual(t, cluster.UID, project.UID)
+
+			switch cluster.Operation {
+			case admissionv1.Create:
+				assert.Contains(t, string(cluster.Object.Raw), `"name":"c-1"`)
+				assert.Empty(t, cluster.OldObject.Raw)
+			case admissionv1.Update:
+				assert.Contains(t, string(cluster.Object.Raw), `"name":"c-1"`)
+				assert.Contains(t, string(cluster.OldObject.Raw), `"name":"c-1"`)
+			case admissionv1.Delete:
+				assert.Empty(t, cluster.Object.Raw)
+				assert.Contains(t, string(cluster.OldObject.Raw), `"name":"c-1"`)
+			}
+		})
+	}
+}
+
+func TestReadObjectsRequiresKind(t *testing.T) {
+	t.Parallel()
+	_, err := readObjects("-", strings.NewReader("metadata:\n  name: c-1\n"))
+	assert.Error(t, err)
+}
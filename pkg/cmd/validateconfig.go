@@ -0,0 +1,433 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/rancher/webhook/pkg/certs"
+	"github.com/rancher/webhook/pkg/config"
+	"github.com/rancher/webhook/pkg/identity"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/maintenancewindow"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/rancher/webhook/pkg/server"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+
+	maintenanceWindowMaxDurationEnvKey = "CATTLE_WEBHOOK_MAINTENANCE_WINDOW_MAX_DURATION"
+)
+
+// policyFile is the configuration an operator supplies to the webhook, checked by the validate-config command.
+type policyFile struct {
+	// Enforcement are the rule enforcement schedules, as set in the webhook-rule-enforcement setting.
+	Enforcement map[string]rules.Schedule `json:"enforcement,omitempty"`
+	// MaintenanceWindows are maintenance windows to create.
+	MaintenanceWindows []webhookv1.MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// Env are the environment variables of the webhook's deployment.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// diagnostic is a problem found in a policy file.
+type diagnostic struct {
+	// Path is the path of the offending field in the file, empty if the file itself can't be read.
+	Path string `json:"path"`
+	// Severity is error for problems the webhook rejects or fails on, and warning for suspicious values.
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// configReport is the output of the validate-config command.
+type configReport struct {
+	File        string       `json:"file"`
+	Valid       bool         `json:"valid"`
+	Diagnostics []diagnostic `json:"diagnostics"`
+}
+
+// errInvalidConfig is returned by the validate-config command when the file has errors, after printing the report,
+// so that the command exits with a non-zero status.
+var errInvalidConfig = errors.New("configuration is invalid")
+
+func newValidateConfigCommand(_ *options) *cobra.Command {
+	var file, output string
+	cmd := &cobra.Command{
+		Use:   "validate-config",
+		Short: "Check a policy file for errors before deploying it",
+		Long: "Statically check the configuration an operator supplies to the webhook: the rule enforcement schedules, " +
+			"maintenance windows and environment variables of a policy file. Doesn't need access to a cluster. Prints the " +
+			"diagnostics and exits with a non-zero status if any of them is an error, so that it can gate deployments in CI.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			format, err := parseOutputFormat(output)
+			if err != nil {
+				return err
+			}
+			report := validateConfig(file, cmd.InOrStdin())
+			if err := printObjects(cmd.OutOrStdout(), format, report); err != nil {
+				return err
+			}
+			if !report.Valid {
+				return errInvalidConfig
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&file, "filename", "f", "-", "Policy file to check, as YAML or JSON, - for stdin.")
+	flags.StringVarP(&output, "output", "o", string(outputJSON), "Output format, one of yaml or json.")
+	return cmd
+}
+
+// validateConfig reads the policy file and returns the diagnostics of its configuration.
+func validateConfig(file string, stdin io.Reader) configReport {
+	report := configReport{File: file, Diagnostics: []diagnostic{}}
+	policy, err := readPolicyFile(file, stdin)
+	if err != nil {
+		report.Diagnostics = append(report.Diagnostics, diagnostic{Severity: severityError, Message: err.Error()})
+	} else {
+		report.Diagnostics = policy.diagnostics()
+	}
+	report.Valid = true
+	for _, d := range report.Diagnostics {
+		if d.Severity == severityError {
+			report.Valid = false
+		}
+	}
+	return report
+}
+
+// readPolicyFile decodes the single document of the policy file. Unknown fields are errors, so that misspelled or
+// unsupported configuration isn't silently ignored.
+func readPolicyFile(file string, stdin io.Reader) (*policyFile, error) {
+	var docs []*json.RawMessage
+	err := decodeFile(file, stdin, func() any {
+		doc := &json.RawMessage{}
+		docs = append(docs, doc)
+		return doc
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) != 1 {
+		return nil, fmt.Errorf("%s must hold exactly one document, found %d", file, len(docs))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(*docs[0]))
+	decoder.DisallowUnknownFields()
+	policy := &policyFile{}
+	if err := decoder.Decode(policy); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	return policy, nil
+}
+
+// diagnostics returns the problems of the policy, sorted by path.
+func (p *policyFile) diagnostics() []diagnostic {
+	diagnostics := []diagnostic{}
+	report := func(severity string, fldPath *field.Path, format string, args ...any) {
+		diagnostics = append(diagnostics, diagnostic{Path: fldPath.String(), Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	enforcementPath := field.NewPath("enforcement")
+	for id, schedule := range p.Enforcement {
+		fldPath := enforcementPath.Key(id)
+		if _, ok := rules.Get(id); !ok {
+			report(severityError, fldPath, "unknown rule %s", id)
+		}
+		if schedule.Warn == nil && schedule.Deny == nil {
+			report(severityWarning, fldPath, "schedule has no dates, the rule is off")
+		}
+		if schedule.Warn != nil && schedule.Deny != nil && schedule.Deny.Before(*schedule.Warn) {
+			report(severityError, fldPath.Child("deny"), "deny date is before the warn date")
+		}
+	}
+
+	envPath := field.NewPath("env")
+	for name, value := range p.Env {
+		check, known := envChecks[name]
+		if !known {
+			if strings.HasPrefix(name, "CATTLE_") {
+				report(severityWarning, envPath.Key(name), "not read by the webhook")
+			}
+			continue
+		}
+		if check == nil || value == "" {
+			continue
+		}
+		if err := check(value); err != nil {
+			report(severityError, envPath.Key(name), "invalid value %q: %v", value, err)
+		}
+	}
+	if _, err := server.NewShard(p.Env[shardNameEnvKey], p.Env[shardSelectorEnvKey]); err != nil {
+		report(severityError, envPath, "%v", err)
+	}
+	if p.Env[config.FileEnvKey] != "" && p.Env[config.ConfigMapEnvKey] != "" {
+		report(severityError, envPath, "only one of %s and %s can be set", config.FileEnvKey, config.ConfigMapEnvKey)
+	}
+
+	maxDuration := maintenancewindow.DefaultMaxDuration
+	if value, err := time.ParseDuration(p.Env[maintenanceWindowMaxDurationEnvKey]); err == nil && value > 0 {
+		maxDuration = value
+	}
+	windowsPath := field.NewPath("maintenanceWindows")
+	names := map[string]bool{}
+	for i, window := range p.MaintenanceWindows {
+		fldPath := windowsPath.Index(i)
+		switch {
+		case window.Name == "":
+			report(severityError, fldPath.Child("metadata", "name"), "name is required")
+		case names[window.Name]:
+			report(severityError, fldPath.Child("metadata", "name"), "duplicate maintenance window %s", window.Name)
+		}
+		names[window.Name] = true
+		for _, fieldErr := range maintenancewindow.ValidateSpec(&window.Spec, maxDuration) {
+			diagnostics = append(diagnostics, diagnostic{
+				Path:     fldPath.String() + "." + fieldErr.Field,
+				Severity: severityError,
+				Message:  fieldErr.ErrorBody(),
+			})
+		}
+		if !window.Spec.End.IsZero() && window.Spec.End.Before(&metav1.Time{Time: time.Now()}) {
+			report(severityWarning, fldPath.Child("spec", "end"), "maintenance window already ended")
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Path < diagnostics[j].Path
+	})
+	return diagnostics
+}
+
+// envChecks validate the values of the environment variables read by the webhook and its handlers. Variables with a
+// nil check accept any value.
+var envChecks = map[string]func(value string) error{
+	mcmEnvKey:                   checkTrueOrFalse,
+	"CATTLE_DEBUG":              checkTrueOrFalse,
+	"RANCHER_DEBUG":             checkTrueOrFalse,
+	"CATTLE_TRACE":              checkTrueOrFalse,
+	"CATTLE_PORT":               checkPort,
+	"CATTLE_WEBHOOK_DEBUG_PORT": checkPort,
+	"CATTLE_NEW_SIGNED_CERT_EXPIRATION_DAYS": func(value string) error {
+		_, err := strconv.Atoi(value)
+		return err
+	},
+	"CATTLE_WEBHOOK_CERT_EXPIRY_WARNING":      checkDuration,
+	"CATTLE_WEBHOOK_CA_BUNDLE_CHECK_INTERVAL": checkDuration,
+	"CATTLE_WEBHOOK_RESYNC_PERIOD":            checkDuration,
+	"CATTLE_WEBHOOK_URL":                      server.CheckWebhookURL,
+	"CATTLE_WEBHOOK_BIND_ADDRESSES": func(value string) error {
+		_, err := server.ParseBindAddresses(value)
+		return err
+	},
+	"ALLOWED_CNS":                          nil,
+	handlersEnvKey:                         nil,
+	shardNameEnvKey:                        nil,
+	shardSelectorEnvKey:                    nil,
+	oldObjectsEnvKey:                       checkOldObjects,
+	normalizationEnvKey:                    checkNormalization,
+	"CATTLE_WEBHOOK_GZIP":                  checkBool,
+	"CATTLE_WEBHOOK_DENIAL_CONDITIONS":     checkBool,
+	"CATTLE_WEBHOOK_IMPORT_PRECHECKS":      checkBool,
+	"CATTLE_WEBHOOK_KEEP_ALIVES":           checkBool,
+	"CATTLE_WEBHOOK_IDLE_TIMEOUT":          checkDuration,
+	"CATTLE_WEBHOOK_TCP_KEEP_ALIVE_PERIOD": checkDuration,
+	"CATTLE_WEBHOOK_HTTP2_MAX_CONCURRENT_STREAMS": func(value string) error {
+		_, err := strconv.ParseUint(value, 10, 32)
+		return err
+	},
+	"CATTLE_AGENT_IMAGE_ALLOWED_REGISTRIES":       nil,
+	"CATTLE_AGENT_IMAGE_ALLOWED_TAGS":             checkTagPatterns,
+	"CATTLE_PROJECT_USED_LIMIT_WRITERS":           nil,
+	"CATTLE_PROJECT_QUOTA_DECREASE_APPROVER_ROLE": nil,
+	"CATTLE_PROJECT_QUOTA_DECREASE_MARGIN": func(value string) error {
+		margin, err := strconv.Atoi(value)
+		if err == nil && margin < 0 {
+			err = errors.New("must not be negative")
+		}
+		return err
+	},
+	"CATTLE_PROJECT_QUOTA_RECOMMENDED_PAIRS": func(value string) error {
+		_, err := quota.ParseRecommendedPairs(value)
+		return err
+	},
+	"CATTLE_PROJECT_QUOTA_USAGE": func(value string) error {
+		if value != "used-limit" && value != "live" {
+			return errors.New("must be used-limit or live")
+		}
+		return nil
+	},
+	maintenanceWindowMaxDurationEnvKey: func(value string) error {
+		duration, err := time.ParseDuration(value)
+		if err == nil && duration <= 0 {
+			err = errors.New("must be positive")
+		}
+		return err
+	},
+	common.NormanShimsUntilEnv: func(value string) error {
+		_, err := time.Parse(common.NormanShimsDateLayout, value)
+		return err
+	},
+	identity.PrincipalVerifierURLEnv: func(value string) error {
+		_, err := identity.NewHTTPPrincipalVerifier(value, nil)
+		return err
+	},
+	identity.PrincipalVerifierCacheTTLEnv: checkDuration,
+	"CATTLE_WEBHOOK_WATCHDOG_CEILING": func(value string) error {
+		duration, err := time.ParseDuration(value)
+		if err == nil && duration < 0 {
+			err = errors.New("must not be negative")
+		}
+		return err
+	},
+	"CATTLE_WEBHOOK_WATCHDOG_LEAK_THRESHOLD": func(value string) error {
+		threshold, err := strconv.Atoi(value)
+		if err == nil && threshold <= 0 {
+			err = errors.New("must be positive")
+		}
+		return err
+	},
+	server.RulesetHashEnvKey: server.CheckPinnedRulesetHash,
+	certModeEnvKey: func(value string) error {
+		_, err := certs.ParseMode(value)
+		return err
+	},
+	certValidityEnvKey: checkPositiveDuration,
+	certFileEnvKey:     nil,
+	keyFileEnvKey:      nil,
+	caFileEnvKey:       nil,
+	certSecretEnvKey: func(value string) error {
+		if errs := validation.IsDNS1123Subdomain(value); len(errs) != 0 {
+			return errors.New(strings.Join(errs, ", "))
+		}
+		return nil
+	},
+	admission.AuditLogEnvKey: func(value string) error {
+		_, err := admission.ParseAuditLog(value, "", "")
+		return err
+	},
+	admission.AuditLogMaxSizeEnvKey: func(value string) error {
+		_, err := admission.ParseAuditLog("", value, "")
+		return err
+	},
+	admission.AuditLogMaxBackupsEnvKey: func(value string) error {
+		_, err := admission.ParseAuditLog("", "", value)
+		return err
+	},
+	admission.ExternalPoliciesEnvKey: func(value string) error {
+		_, err := admission.ParseExternalPolicies(value)
+		return err
+	},
+	admission.AuditModeEnvKey: func(value string) error {
+		_, err := admission.ParseAuditMode(value)
+		return err
+	},
+	admission.RateLimitsEnvKey: func(value string) error {
+		_, err := admission.ParseRateLimits(value)
+		return err
+	},
+	auth.SARPrefetchConcurrencyEnvKey: func(value string) error {
+		_, err := auth.ParseSARPrefetchConcurrency(value)
+		return err
+	},
+	config.FileEnvKey: nil,
+	config.ConfigMapEnvKey: func(value string) error {
+		if errs := validation.IsDNS1123Subdomain(value); len(errs) != 0 {
+			return errors.New(strings.Join(errs, ", "))
+		}
+		return nil
+	},
+	"CATTLE_WEBHOOK_SHUTDOWN_DELAY":   checkNonNegativeDuration,
+	"CATTLE_WEBHOOK_SHUTDOWN_TIMEOUT": checkNonNegativeDuration,
+}
+
+func checkBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+// checkTrueOrFalse checks flags compared to the exact strings true or false, unlike those parsed by strconv.ParseBool.
+func checkTrueOrFalse(value string) error {
+	if value != "true" && value != "false" {
+		return errors.New("must be true or false")
+	}
+	return nil
+}
+
+func checkDuration(value string) error {
+	_, err := time.ParseDuration(value)
+	return err
+}
+
+func checkNonNegativeDuration(value string) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if duration < 0 {
+		return errors.New("must not be negative")
+	}
+	return nil
+}
+
+func checkPositiveDuration(value string) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return errors.New("must be positive")
+	}
+	return nil
+}
+
+func checkPort(value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	if errs := validation.IsValidPortNum(port); len(errs) != 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func checkOldObjects(value string) error {
+	if value != admission.StrictOldObjects && value != admission.RelaxedOldObjects {
+		return fmt.Errorf("must be %s or %s", admission.StrictOldObjects, admission.RelaxedOldObjects)
+	}
+	return nil
+}
+
+func checkNormalization(value string) error {
+	if value != admission.NormalizationOff && value != admission.NormalizationValidate && value != admission.NormalizationPersist {
+		return fmt.Errorf("must be %s, %s or %s", admission.NormalizationOff, admission.NormalizationValidate, admission.NormalizationPersist)
+	}
+	return nil
+}
+
+// checkTagPatterns checks that each entry of the list is a valid path.Match pattern.
+func checkTagPatterns(value string) error {
+	for _, pattern := range common.SplitList(value) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
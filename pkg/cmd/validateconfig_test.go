@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		policy    string
+		wantValid bool
+		wantPaths []string
+	}{
+		{
+			name: "valid policy",
+			policy: `enforcement:
+  cluster-agent-image-tag:
+    warn: "2025-03-01T00:00:00Z"
+    deny: "2025-06-01T00:00:00Z"
+env:
+  CATTLE_AGENT_IMAGE_ALLOWED_TAGS: v2.11.*
+  CATTLE_WEBHOOK_MAINTENANCE_WINDOW_MAX_DURATION: 72h
+maintenanceWindows:
+- metadata:
+    name: incident-1234
+  spec:
+    rules: [cluster-agent-image-tag]
+    start: "2100-01-01T00:00:00Z"
+    end: "2100-01-03T00:00:00Z"
+    approver: u-approver
+`,
+			wantValid: true,
+		},
+		{
+			name:      "syntax error",
+			policy:    "enforcement: [",
+			wantPaths: []string{""},
+		},
+		{
+			name:      "unsupported configuration",
+			policy:    "celRules: []",
+			wantPaths: []string{""},
+		},
+		{
+			name: "invalid enforcement schedules",
+			policy: `enforcement:
+  unknown-rule:
+    warn: "2025-03-01T00:00:00Z"
+  cluster-agent-image-tag:
+    warn: "2025-06-01T00:00:00Z"
+    deny: "2025-03-01T00:00:00Z"
+`,
+			wantPaths: []string{"enforcement[cluster-agent-image-tag].deny", "enforcement[unknown-rule]"},
+		},
+		{
+			name: "invalid environment variables",
+			policy: `env:
+  CATTLE_PORT: "99999"
+  CATTLE_AGENT_IMAGE_ALLOWED_TAGS: "v2.11.*,[a"
+  CATTLE_WEBHOOK_OLD_OBJECTS: lenient
+  CATTLE_WEBHOOK_SHARD_NAME: a
+  CATTLE_WEBHOOK_URL: https://fd00::10:9443
+  CATTLE_WEBHOOK_BIND_ADDRESSES: 0.0.0.0,localhost
+  CATTLE_WEBHOOK_CERT_MODE: acme
+  CATTLE_WEBHOOK_CERT_VALIDITY: -24h
+  CATTLE_WEBHOOK_AUDIT_MODE: clusters.management.cattle.io/status
+`,
+			wantPaths: []string{"env", "env[CATTLE_AGENT_IMAGE_ALLOWED_TAGS]", "env[CATTLE_PORT]", "env[CATTLE_WEBHOOK_AUDIT_MODE]", "env[CATTLE_WEBHOOK_BIND_ADDRESSES]", "env[CATTLE_WEBHOOK_CERT_MODE]", "env[CATTLE_WEBHOOK_CERT_VALIDITY]", "env[CATTLE_WEBHOOK_OLD_OBJECTS]", "env[CATTLE_WEBHOOK_URL]"},
+		},
+		{
+			name: "webhook configuration from both a file and a ConfigMap",
+			policy: `env:
+  CATTLE_WEBHOOK_CONFIG_FILE: /etc/webhook/config.yaml
+  CATTLE_WEBHOOK_CONFIG_CONFIGMAP: Webhook_Config
+`,
+			wantPaths: []string{"env", "env[CATTLE_WEBHOOK_CONFIG_CONFIGMAP]"},
+		},
+		{
+			name: "unknown environment variables are warnings",
+			policy: `env:
+  CATTLE_WEBHOOK_TYPO: "true"
+  HOME: /root
+`,
+			wantValid: true,
+			wantPaths: []string{"env[CATTLE_WEBHOOK_TYPO]"},
+		},
+		{
+			name: "invalid maintenance windows",
+			policy: `maintenanceWindows:
+- metadata:
+    name: w
+  spec:
+    rules: [cluster-agent-image-tag]
+    start: "2100-01-01T00:00:00Z"
+    end: "2100-01-03T00:00:00Z"
+- metadata:
+    name: w
+  spec:
+    rules: [cluster-agent-image-tag]
+    start: "2100-01-01T00:00:00Z"
+    end: "2100-01-01T01:00:00Z"
+    approver: u-approver
+`,
+			wantPaths: []string{
+				"maintenanceWindows[0].spec.approver",
+				"maintenanceWindows[0].spec.end",
+				"maintenanceWindows[1].metadata.name",
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			report := validateConfig("-", strings.NewReader(test.policy))
+			assert.Equal(t, test.wantValid, report.Valid, "diagnostics: %v", report.Diagnostics)
+			paths := []string{}
+			for _, d := range report.Diagnostics {
+				paths = append(paths, d.Path)
+			}
+			if test.wantPaths == nil {
+				test.wantPaths = []string{}
+			}
+			assert.Equal(t, test.wantPaths, paths)
+		})
+	}
+}
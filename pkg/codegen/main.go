@@ -9,9 +9,11 @@ import (
 	"strings"
 	"text/template"
 
+	fleetv1alpha1 "github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
 	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
 	controllergen "github.com/rancher/wrangler/v3/pkg/controller-gen"
 	"github.com/rancher/wrangler/v3/pkg/controller-gen/args"
 	"golang.org/x/tools/imports"
@@ -61,6 +63,17 @@ func main() {
 					&catalogv1.ClusterRepo{},
 				},
 			},
+			"webhook.cattle.io": {
+				Types: []interface{}{
+					webhookv1.MaintenanceWindow{},
+					webhookv1.TaintPolicy{},
+					webhookv1.DeniedObject{},
+					webhookv1.ClusterTierPolicy{},
+					webhookv1.CustomPolicy{},
+					webhookv1.PolicyExceptionRequest{},
+				},
+				GenerateTypes: true,
+			},
 		},
 	})
 
@@ -71,6 +84,11 @@ func main() {
 				&catalogv1.ClusterRepo{},
 			},
 		},
+		"fleet.cattle.io": {
+			Types: []interface{}{
+				&fleetv1alpha1.Bundle{},
+			},
+		},
 		"management.cattle.io": {
 			Types: []interface{}{
 				&v3.Cluster{},
@@ -106,6 +124,15 @@ func main() {
 				&rbacv1.ClusterRole{},
 				&rbacv1.ClusterRoleBinding{},
 			},
+		},
+		"webhook.cattle.io": {
+			Types: []interface{}{
+				&webhookv1.MaintenanceWindow{},
+				&webhookv1.TaintPolicy{},
+				&webhookv1.ClusterTierPolicy{},
+				&webhookv1.CustomPolicy{},
+				&webhookv1.PolicyExceptionRequest{},
+			},
 		}}); err != nil {
 		fmt.Printf("ERROR: %v\n", err)
 	}
@@ -0,0 +1,166 @@
+// Package config holds the webhook configuration, which enables or disables the webhooks of each handler and overrides
+// their failure policy, timeout and namespace selector, and watches it for changes so that the webhook configurations
+// are registered again without restarting the webhook.
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// maxTimeoutSeconds is the longest timeout the API server accepts for a webhook.
+const maxTimeoutSeconds = 30
+
+// Config is the webhook configuration. The zero Config, like a nil one, keeps the settings of all handlers.
+type Config struct {
+	// Webhooks configure the webhooks of the handlers.
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+}
+
+// Webhook configures the webhooks of a handler. Unset fields keep the settings of the handler.
+type Webhook struct {
+	// Handler is the name of the handler, the sub path of its webhook, e.g. projects.management.cattle.io.
+	Handler string `json:"handler"`
+	// Validating enables or disables the validating webhooks of the handler.
+	Validating *bool `json:"validating,omitempty"`
+	// Mutating enables or disables the mutating webhooks of the handler.
+	Mutating *bool `json:"mutating,omitempty"`
+	// FailurePolicy is the failure policy of the webhooks of the handler, Fail or Ignore.
+	FailurePolicy *v1.FailurePolicyType `json:"failurePolicy,omitempty"`
+	// TimeoutSeconds is the timeout of the webhooks of the handler, from 1 to 30 seconds.
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// NamespaceSelector restricts the namespaces of the objects the webhooks of the handler are called for. It is
+	// combined with the namespace selector of the handler, which it can't widen.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// Parse decodes and validates a YAML or JSON webhook configuration.
+func Parse(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook configuration: %w", err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// validate returns the problems of the configuration, joined.
+func (c *Config) validate() error {
+	var errs []error
+	handlers := map[string]bool{}
+	for i, webhook := range c.Webhooks {
+		switch {
+		case webhook.Handler == "":
+			errs = append(errs, fmt.Errorf("webhooks[%d]: handler is required", i))
+		case handlers[webhook.Handler]:
+			errs = append(errs, fmt.Errorf("webhooks[%d]: duplicate handler %s", i, webhook.Handler))
+		}
+		handlers[webhook.Handler] = true
+		if policy := webhook.FailurePolicy; policy != nil && *policy != v1.Fail && *policy != v1.Ignore {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: failurePolicy must be %s or %s", i, v1.Fail, v1.Ignore))
+		}
+		if timeout := webhook.TimeoutSeconds; timeout != nil && (*timeout < 1 || *timeout > maxTimeoutSeconds) {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: timeoutSeconds must be between 1 and %d", i, maxTimeoutSeconds))
+		}
+		if webhook.NamespaceSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(webhook.NamespaceSelector); err != nil {
+				errs = append(errs, fmt.Errorf("webhooks[%d]: invalid namespaceSelector: %w", i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Handlers returns the names of the handlers the configuration configures.
+func (c *Config) Handlers() []string {
+	if c == nil {
+		return nil
+	}
+	handlers := make([]string, 0, len(c.Webhooks))
+	for _, webhook := range c.Webhooks {
+		handlers = append(handlers, webhook.Handler)
+	}
+	return handlers
+}
+
+// webhook returns the configuration of the webhooks of the handler, nil if there is none.
+func (c *Config) webhook(handler string) *Webhook {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Webhooks {
+		if c.Webhooks[i].Handler == handler {
+			return &c.Webhooks[i]
+		}
+	}
+	return nil
+}
+
+// ValidatingEnabled returns false if the validating webhooks of the handler are disabled.
+func (c *Config) ValidatingEnabled(handler string) bool {
+	webhook := c.webhook(handler)
+	return webhook == nil || webhook.Validating == nil || *webhook.Validating
+}
+
+// MutatingEnabled returns false if the mutating webhooks of the handler are disabled.
+func (c *Config) MutatingEnabled(handler string) bool {
+	webhook := c.webhook(handler)
+	return webhook == nil || webhook.Mutating == nil || *webhook.Mutating
+}
+
+// ApplyValidating overrides the settings of a validating webhook of the handler with the configured ones.
+func (c *Config) ApplyValidating(handler string, webhook *v1.ValidatingWebhook) {
+	config := c.webhook(handler)
+	if config == nil {
+		return
+	}
+	if config.FailurePolicy != nil {
+		webhook.FailurePolicy = config.FailurePolicy
+	}
+	if config.TimeoutSeconds != nil {
+		webhook.TimeoutSeconds = config.TimeoutSeconds
+	}
+	if config.NamespaceSelector != nil {
+		webhook.NamespaceSelector = restrictSelector(webhook.NamespaceSelector, config.NamespaceSelector)
+	}
+}
+
+// ApplyMutating overrides the settings of a mutating webhook of the handler with the configured ones.
+func (c *Config) ApplyMutating(handler string, webhook *v1.MutatingWebhook) {
+	config := c.webhook(handler)
+	if config == nil {
+		return
+	}
+	if config.FailurePolicy != nil {
+		webhook.FailurePolicy = config.FailurePolicy
+	}
+	if config.TimeoutSeconds != nil {
+		webhook.TimeoutSeconds = config.TimeoutSeconds
+	}
+	if config.NamespaceSelector != nil {
+		webhook.NamespaceSelector = restrictSelector(webhook.NamespaceSelector, config.NamespaceSelector)
+	}
+}
+
+// restrictSelector returns a selector matching the objects matched by both selectors. The labels of the restriction
+// are added as requirements, so that they can't replace the labels of the selector.
+func restrictSelector(selector, restriction *metav1.LabelSelector) *metav1.LabelSelector {
+	restricted := &metav1.LabelSelector{}
+	if selector != nil {
+		restricted = selector.DeepCopy()
+	}
+	for key, value := range restriction.MatchLabels {
+		restricted.MatchExpressions = append(restricted.MatchExpressions,
+			metav1.LabelSelectorRequirement{Key: key, Operator: metav1.LabelSelectorOpIn, Values: []string{value}})
+	}
+	for _, requirement := range restriction.MatchExpressions {
+		restricted.MatchExpressions = append(restricted.MatchExpressions, *requirement.DeepCopy())
+	}
+	return restricted
+}
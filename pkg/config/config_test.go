@@ -0,0 +1,134 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "valid configuration",
+			data: `webhooks:
+- handler: projects.management.cattle.io
+  mutating: false
+  failurePolicy: Ignore
+  timeoutSeconds: 5
+  namespaceSelector:
+    matchLabels:
+      team: a
+- handler: namespaces
+  validating: true
+`,
+		},
+		{
+			name: "empty configuration",
+		},
+		{
+			name:    "unknown field",
+			data:    "webhooks:\n- handler: namespaces\n  enabled: false\n",
+			wantErr: "failed to decode",
+		},
+		{
+			name:    "missing handler",
+			data:    "webhooks:\n- timeoutSeconds: 5\n",
+			wantErr: "webhooks[0]: handler is required",
+		},
+		{
+			name:    "duplicate handler",
+			data:    "webhooks:\n- handler: namespaces\n- handler: namespaces\n",
+			wantErr: "webhooks[1]: duplicate handler namespaces",
+		},
+		{
+			name:    "invalid failure policy",
+			data:    "webhooks:\n- handler: namespaces\n  failurePolicy: Retry\n",
+			wantErr: "failurePolicy must be Fail or Ignore",
+		},
+		{
+			name:    "timeout too long",
+			data:    "webhooks:\n- handler: namespaces\n  timeoutSeconds: 31\n",
+			wantErr: "timeoutSeconds must be between 1 and 30",
+		},
+		{
+			name:    "invalid namespace selector",
+			data:    "webhooks:\n- handler: namespaces\n  namespaceSelector:\n    matchExpressions:\n    - key: team\n      operator: Near\n",
+			wantErr: "invalid namespaceSelector",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			config, err := Parse([]byte(test.data))
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, config)
+		})
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	t.Parallel()
+	config, err := Parse([]byte(`webhooks:
+- handler: projects.management.cattle.io
+  validating: false
+  failurePolicy: Ignore
+  timeoutSeconds: 5
+  namespaceSelector:
+    matchLabels:
+      team: a
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"projects.management.cattle.io"}, config.Handlers())
+	assert.False(t, config.ValidatingEnabled("projects.management.cattle.io"))
+	assert.True(t, config.MutatingEnabled("projects.management.cattle.io"))
+	assert.True(t, config.ValidatingEnabled("namespaces"))
+
+	webhook := &v1.MutatingWebhook{
+		FailurePolicy:  failurePolicy(v1.Fail),
+		TimeoutSeconds: timeoutSeconds(10),
+		NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "kubernetes.io/metadata.name", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+		}},
+	}
+	handlerSelector := webhook.NamespaceSelector
+	config.ApplyMutating("projects.management.cattle.io", webhook)
+	assert.Equal(t, v1.Ignore, *webhook.FailurePolicy)
+	assert.Equal(t, int32(5), *webhook.TimeoutSeconds)
+	selector, err := metav1.LabelSelectorAsSelector(webhook.NamespaceSelector)
+	require.NoError(t, err)
+	assert.True(t, selector.Matches(labels.Set{"team": "a"}))
+	assert.False(t, selector.Matches(labels.Set{"team": "b"}))
+	assert.False(t, selector.Matches(labels.Set{"team": "a", "kubernetes.io/metadata.name": "kube-system"}), "the selector of the handler still applies")
+	assert.Len(t, handlerSelector.MatchExpressions, 1, "the selector of the handler isn't modified")
+
+	// webhooks of other handlers, and all webhooks with a nil configuration, are left as they are
+	other := &v1.ValidatingWebhook{FailurePolicy: failurePolicy(v1.Fail)}
+	config.ApplyValidating("namespaces", other)
+	var none *Config
+	none.ApplyValidating("projects.management.cattle.io", other)
+	assert.Equal(t, &v1.ValidatingWebhook{FailurePolicy: failurePolicy(v1.Fail)}, other)
+	assert.True(t, none.ValidatingEnabled("projects.management.cattle.io"))
+	assert.Empty(t, none.Handlers())
+}
+
+func failurePolicy(policy v1.FailurePolicyType) *v1.FailurePolicyType {
+	return &policy
+}
+
+func timeoutSeconds(seconds int32) *int32 {
+	return &seconds
+}
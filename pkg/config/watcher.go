@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// FileEnvKey is the environment variable holding the path of the configuration file, which is checked for changes
+	// every filePollInterval.
+	FileEnvKey = "CATTLE_WEBHOOK_CONFIG_FILE"
+	// ConfigMapEnvKey is the environment variable holding the name of the ConfigMap of the cattle-system namespace
+	// holding the configuration under ConfigMapKey, which is watched for changes.
+	ConfigMapEnvKey = "CATTLE_WEBHOOK_CONFIG_CONFIGMAP"
+	// ConfigMapKey is the key of the configuration in its ConfigMap.
+	ConfigMapKey = "config.yaml"
+
+	configMapNamespace = "cattle-system"
+	filePollInterval   = 10 * time.Second
+)
+
+// Watcher holds the current webhook configuration, and calls its handler whenever the configuration changes. A
+// changed configuration that doesn't parse is logged and ignored, keeping the previous one.
+type Watcher struct {
+	mutex    sync.Mutex
+	current  *Config
+	raw      []byte
+	onChange func(*Config)
+}
+
+// NewWatcher returns a watcher holding the empty configuration.
+func NewWatcher() *Watcher {
+	return &Watcher{current: &Config{}}
+}
+
+// Current returns the current configuration.
+func (w *Watcher) Current() *Config {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.current
+}
+
+// OnChange sets the function called with the new configuration whenever it changes.
+func (w *Watcher) OnChange(onChange func(*Config)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.onChange = onChange
+}
+
+// WatchFromEnv loads the configuration from the file of FileEnvKey or the ConfigMap of ConfigMapEnvKey, and watches it
+// for changes until the context is done. At most one of them can be set. An invalid configuration file fails, so that
+// the webhook doesn't start with settings the operator didn't intend.
+func (w *Watcher) WatchFromEnv(ctx context.Context, configMaps corecontrollers.ConfigMapController) error {
+	path, name := os.Getenv(FileEnvKey), os.Getenv(ConfigMapEnvKey)
+	switch {
+	case path != "" && name != "":
+		return fmt.Errorf("only one of %s and %s can be set", FileEnvKey, ConfigMapEnvKey)
+	case path != "":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read webhook configuration: %w", err)
+		}
+		if err := w.update(data); err != nil {
+			return fmt.Errorf("invalid webhook configuration file %s: %w", path, err)
+		}
+		go w.pollFile(ctx, path, filePollInterval)
+	case name != "":
+		configMaps.OnChange(ctx, "webhook-config", w.SyncConfigMap(name))
+	}
+	return nil
+}
+
+// pollFile reloads the configuration file every interval until the context is done.
+func (w *Watcher) pollFile(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			data, err := os.ReadFile(path)
+			if err != nil {
+				logrus.Errorf("[webhookConfig] failed to read webhook configuration file %s: %v", path, err)
+				continue
+			}
+			if err := w.update(data); err != nil {
+				logrus.Errorf("[webhookConfig] ignoring invalid webhook configuration file %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// SyncConfigMap returns the handler updating the configuration from the ConfigMap with the given name. Deleting the
+// ConfigMap, or its ConfigMapKey key, resets the configuration.
+func (w *Watcher) SyncConfigMap(name string) func(string, *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	return func(key string, configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+		if key != configMapNamespace+"/"+name {
+			return configMap, nil
+		}
+		var data []byte
+		if configMap != nil && configMap.DeletionTimestamp == nil {
+			data = []byte(configMap.Data[ConfigMapKey])
+		}
+		if err := w.update(data); err != nil {
+			// the ConfigMap isn't retried, it only parses once it's fixed
+			logrus.Errorf("[webhookConfig] ignoring invalid webhook configuration in ConfigMap %s: %v", key, err)
+		}
+		return configMap, nil
+	}
+}
+
+// update parses the configuration and, if it changed, makes it current and calls the change handler.
+func (w *Watcher) update(data []byte) error {
+	w.mutex.Lock()
+	if w.raw != nil && bytes.Equal(w.raw, data) {
+		w.mutex.Unlock()
+		return nil
+	}
+	config, err := Parse(data)
+	if err != nil {
+		w.mutex.Unlock()
+		return err
+	}
+	w.current, w.raw = config, append([]byte{}, data...)
+	onChange := w.onChange
+	w.mutex.Unlock()
+
+	logrus.Infof("[webhookConfig] loaded webhook configuration of %d handlers", len(config.Webhooks))
+	if onChange != nil {
+		onChange(config)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWatcherSyncConfigMap(t *testing.T) {
+	t.Parallel()
+	watcher := NewWatcher()
+	var changes []*Config
+	watcher.OnChange(func(config *Config) { changes = append(changes, config) })
+	sync := watcher.SyncConfigMap("webhook-config")
+	configMap := func(data string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "cattle-system", Name: "webhook-config"},
+			Data:       map[string]string{ConfigMapKey: data},
+		}
+	}
+
+	_, err := sync("cattle-system/webhook-config", configMap("webhooks:\n- handler: namespaces\n  validating: false\n"))
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.False(t, watcher.Current().ValidatingEnabled("namespaces"))
+
+	// unchanged configurations and other ConfigMaps don't change anything
+	_, err = sync("cattle-system/webhook-config", configMap("webhooks:\n- handler: namespaces\n  validating: false\n"))
+	require.NoError(t, err)
+	_, err = sync("cattle-system/other", configMap("webhooks: []"))
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+
+	// invalid configurations are ignored
+	_, err = sync("cattle-system/webhook-config", configMap("webhooks:\n- handler: namespaces\n  timeoutSeconds: 60\n"))
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.False(t, watcher.Current().ValidatingEnabled("namespaces"))
+
+	// deleting the ConfigMap resets the configuration
+	_, err = sync("cattle-system/webhook-config", nil)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.True(t, watcher.Current().ValidatingEnabled("namespaces"))
+}
+
+func TestWatcherPollFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("webhooks:\n- handler: namespaces\n  mutating: false\n"), 0o600))
+	watcher := NewWatcher()
+	changes := make(chan *Config, 2)
+	watcher.OnChange(func(config *Config) { changes <- config })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.pollFile(ctx, path, 10*time.Millisecond)
+	select {
+	case config := <-changes:
+		assert.False(t, config.MutatingEnabled("namespaces"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("the configuration file wasn't loaded")
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("webhooks: []\n"), 0o600))
+	select {
+	case config := <-changes:
+		assert.True(t, config.MutatingEnabled("namespaces"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("the changed configuration file wasn't loaded")
+	}
+}
+
+// TestWatchFromEnv isn't parallel since it sets the environment variables of the configuration.
+func TestWatchFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("webhooks:\n- handler: namespaces\n  timeoutSeconds: 0\n"), 0o600))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Setenv(FileEnvKey, path)
+	t.Setenv(ConfigMapEnvKey, "webhook-config")
+	assert.ErrorContains(t, NewWatcher().WatchFromEnv(ctx, nil), "only one of")
+
+	t.Setenv(ConfigMapEnvKey, "")
+	assert.ErrorContains(t, NewWatcher().WatchFromEnv(ctx, nil), "timeoutSeconds must be between 1 and 30")
+
+	require.NoError(t, os.WriteFile(path, []byte("webhooks:\n- handler: namespaces\n  timeoutSeconds: 3\n"), 0o600))
+	watcher := NewWatcher()
+	require.NoError(t, watcher.WatchFromEnv(ctx, nil))
+	assert.Equal(t, []string{"namespaces"}, watcher.Current().Handlers())
+}
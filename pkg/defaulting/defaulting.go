@@ -0,0 +1,163 @@
+// Package defaulting holds declarative helpers used by mutators to default the fields of objects, and to build the
+// patch setting the defaults.
+package defaulting
+
+import (
+	"fmt"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Defaulter sets a default on an object. It returns an error if the default can't be computed.
+type Defaulter[T any] func(obj T) error
+
+// Copier is an object that can be deep copied, like the generated Kubernetes types.
+type Copier[T any] interface {
+	DeepCopy() T
+}
+
+// SettingGetter gets management.cattle.io settings by name, e.g. a Setting cache.
+type SettingGetter interface {
+	Get(name string) (*v3.Setting, error)
+}
+
+// Default applies the defaulters to the object in order, and stops at the first error.
+func Default[T any](obj T, defaulters ...Defaulter[T]) error {
+	for _, defaulter := range defaulters {
+		if err := defaulter(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Response applies the defaulters to a copy of the object, and returns an allowed response with the patch setting the
+// defaults on the object. The object must be decoded from the request, so that fields unknown to its type are kept.
+func Response[T Copier[T]](obj T, defaulters ...Defaulter[T]) (*admissionv1.AdmissionResponse, error) {
+	defaulted := obj.DeepCopy()
+	if err := Default(defaulted, defaulters...); err != nil {
+		return nil, err
+	}
+	patch, err := admission.PatchFromObjects(obj, defaulted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create patch: %w", err)
+	}
+	return admission.ResponseAllowedWithPatch(patch), nil
+}
+
+// When applies the defaulters only to the objects matching the condition.
+func When[T any](condition func(obj T) bool, defaulters ...Defaulter[T]) Defaulter[T] {
+	return func(obj T) error {
+		if !condition(obj) {
+			return nil
+		}
+		return Default(obj, defaulters...)
+	}
+}
+
+// IfEmpty sets the field returned by field to the value if it has its zero value.
+func IfEmpty[T any, V comparable](field func(obj T) *V, value V) Defaulter[T] {
+	return IfEmptyFunc(field, func(T) (V, error) { return value, nil })
+}
+
+// IfEmptyFunc sets the field returned by field to the value computed from the object if it has its zero value. The
+// value is only computed for empty fields.
+func IfEmptyFunc[T any, V comparable](field func(obj T) *V, value func(obj T) (V, error)) Defaulter[T] {
+	return func(obj T) error {
+		target := field(obj)
+		var zero V
+		if *target != zero {
+			return nil
+		}
+		v, err := value(obj)
+		if err != nil {
+			return err
+		}
+		*target = v
+		return nil
+	}
+}
+
+// Annotation sets the annotation to the value if it is missing or empty.
+func Annotation[T metav1.Object](key, value string) Defaulter[T] {
+	return func(obj T) error {
+		annotations := obj.GetAnnotations()
+		if annotations[key] != "" {
+			return nil
+		}
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[key] = value
+		obj.SetAnnotations(annotations)
+		return nil
+	}
+}
+
+// FromSetting sets the field returned by field to the value of the setting, or its default if the setting has no
+// value, parsed with parse, if the field has its zero value. The field is left empty if the setting doesn't exist or
+// is empty.
+func FromSetting[T any, V comparable](field func(obj T) *V, settings SettingGetter, name string, parse func(string) (V, error)) Defaulter[T] {
+	return func(obj T) error {
+		target := field(obj)
+		var zero V
+		if *target != zero {
+			return nil
+		}
+		setting, err := settings.Get(name)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get setting %s: %w", name, err)
+		}
+		value := setting.Value
+		if value == "" {
+			value = setting.Default
+		}
+		if value == "" {
+			return nil
+		}
+		v, err := parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid value of setting %s: %w", name, err)
+		}
+		*target = v
+		return nil
+	}
+}
+
+// FromOwner sets the field returned by field to the value taken from the owner of the object, e.g. the project of a
+// namespace, if the field has its zero value. The field is left empty if the owner doesn't exist, which owner reports
+// with a nil owner or a not found error.
+func FromOwner[T any, O comparable, V comparable](field func(obj T) *V, owner func(obj T) (O, error), value func(owner O) V) Defaulter[T] {
+	return func(obj T) error {
+		target := field(obj)
+		var zero V
+		if *target != zero {
+			return nil
+		}
+		o, err := owner(obj)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get owner: %w", err)
+		}
+		var noOwner O
+		if o == noOwner {
+			return nil
+		}
+		*target = value(o)
+		return nil
+	}
+}
+
+// String returns the value unchanged, as a parse function for FromSetting.
+func String(value string) (string, error) {
+	return value, nil
+}
@@ -0,0 +1,173 @@
+package defaulting
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeSettings returns the settings by name, not found for the others.
+type fakeSettings map[string]*v3.Setting
+
+func (f fakeSettings) Get(name string) (*v3.Setting, error) {
+	if setting, ok := f[name]; ok {
+		return setting, nil
+	}
+	return nil, apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "settings"}, name)
+}
+
+func displayName(project *v3.Project) *string { return &project.Spec.DisplayName }
+
+func TestIfEmpty(t *testing.T) {
+	t.Parallel()
+	project := &v3.Project{}
+	require.NoError(t, Default(project, IfEmpty(displayName, "Default")))
+	assert.Equal(t, "Default", project.Spec.DisplayName)
+	require.NoError(t, Default(project, IfEmpty(displayName, "Other")))
+	assert.Equal(t, "Default", project.Spec.DisplayName, "set fields must be kept")
+
+	project = &v3.Project{ObjectMeta: metav1.ObjectMeta{Name: "p-abc12"}}
+	require.NoError(t, Default(project, IfEmptyFunc(displayName, func(project *v3.Project) (string, error) {
+		return project.Name, nil
+	})))
+	assert.Equal(t, "p-abc12", project.Spec.DisplayName)
+	err := Default(&v3.Project{}, IfEmptyFunc(displayName, func(*v3.Project) (string, error) {
+		return "", errors.New("unavailable")
+	}))
+	assert.ErrorContains(t, err, "unavailable")
+}
+
+func TestAnnotation(t *testing.T) {
+	t.Parallel()
+	defaulter := Annotation[*corev1.Namespace]("example.cattle.io/mode", "default")
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+	}{
+		{name: "no annotations", want: "default"},
+		{name: "empty annotation", annotations: map[string]string{"example.cattle.io/mode": ""}, want: "default"},
+		{name: "set annotation", annotations: map[string]string{"example.cattle.io/mode": "custom"}, want: "custom"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations}}
+			require.NoError(t, defaulter(namespace))
+			assert.Equal(t, test.want, namespace.Annotations["example.cattle.io/mode"])
+		})
+	}
+}
+
+func TestFromSetting(t *testing.T) {
+	t.Parallel()
+	settings := fakeSettings{
+		"value":   {Value: "42", Default: "1"},
+		"default": {Default: "7"},
+		"empty":   {},
+		"invalid": {Value: "many"},
+	}
+	type object struct{ replicas int }
+	replicas := func(obj *object) *int { return &obj.replicas }
+	tests := []struct {
+		name    string
+		setting string
+		obj     object
+		want    int
+		wantErr string
+	}{
+		{name: "setting value", setting: "value", want: 42},
+		{name: "setting default", setting: "default", want: 7},
+		{name: "empty setting", setting: "empty", want: 0},
+		{name: "missing setting", setting: "missing", want: 0},
+		{name: "set field", setting: "value", obj: object{replicas: 3}, want: 3},
+		{name: "invalid setting", setting: "invalid", wantErr: "invalid value of setting invalid"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			obj := test.obj
+			err := FromSetting(replicas, settings, test.setting, strconv.Atoi)(&obj)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, obj.replicas)
+		})
+	}
+}
+
+func TestFromOwner(t *testing.T) {
+	t.Parallel()
+	clusters := map[string]*v3.Cluster{"c-abc12": {Spec: v3.ClusterSpec{DisplayName: "production"}}}
+	owner := func(project *v3.Project) (*v3.Cluster, error) {
+		if project.Namespace == "c-error" {
+			return nil, errors.New("unavailable")
+		}
+		cluster, ok := clusters[project.Namespace]
+		if !ok {
+			return nil, apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "clusters"}, project.Namespace)
+		}
+		return cluster, nil
+	}
+	defaulter := FromOwner(displayName, owner, func(cluster *v3.Cluster) string { return cluster.Spec.DisplayName + " project" })
+
+	project := &v3.Project{ObjectMeta: metav1.ObjectMeta{Namespace: "c-abc12"}}
+	require.NoError(t, defaulter(project))
+	assert.Equal(t, "production project", project.Spec.DisplayName)
+
+	project = &v3.Project{ObjectMeta: metav1.ObjectMeta{Namespace: "c-gone"}}
+	require.NoError(t, defaulter(project))
+	assert.Empty(t, project.Spec.DisplayName, "projects of missing owners must be left empty")
+
+	project = &v3.Project{ObjectMeta: metav1.ObjectMeta{Namespace: "c-error"}}
+	assert.ErrorContains(t, defaulter(project), "failed to get owner: unavailable")
+}
+
+func TestWhen(t *testing.T) {
+	t.Parallel()
+	isNamed := func(project *v3.Project) bool { return project.Name != "" }
+	defaulter := When(isNamed, IfEmpty(displayName, "Default"))
+
+	unnamed := &v3.Project{}
+	require.NoError(t, defaulter(unnamed))
+	assert.Empty(t, unnamed.Spec.DisplayName)
+	named := &v3.Project{ObjectMeta: metav1.ObjectMeta{Name: "p-abc12"}}
+	require.NoError(t, defaulter(named))
+	assert.Equal(t, "Default", named.Spec.DisplayName)
+}
+
+func TestResponse(t *testing.T) {
+	t.Parallel()
+	project := &v3.Project{ObjectMeta: metav1.ObjectMeta{Name: "p-abc12"}}
+	response, err := Response(project, IfEmpty(displayName, "Default"), Annotation[*v3.Project]("example.cattle.io/mode", "default"))
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Empty(t, project.Spec.DisplayName, "the object must not be changed")
+	var patch []map[string]any
+	require.NoError(t, json.Unmarshal(response.Patch, &patch))
+	assert.ElementsMatch(t, []map[string]any{
+		{"op": "replace", "path": "/spec/displayName", "value": "Default"},
+		{"op": "add", "path": "/metadata/annotations", "value": map[string]any{"example.cattle.io/mode": "default"}},
+	}, patch)
+
+	response, err = Response(project)
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Nil(t, response.Patch, "objects without defaults must not be patched")
+
+	_, err = Response(project, IfEmptyFunc(displayName, func(*v3.Project) (string, error) { return "", errors.New("unavailable") }))
+	assert.Error(t, err)
+}
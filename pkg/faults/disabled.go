@@ -0,0 +1,30 @@
+//go:build !faults
+
+package faults
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Enabled is true in builds injecting faults.
+const Enabled = false
+
+// Handler returns the handler of the webhook unchanged, faults aren't injected in this build.
+func Handler(_ string, next http.Handler) http.Handler {
+	return next
+}
+
+// CacheError returns nil, faults aren't injected in this build.
+func CacheError(string) error {
+	return nil
+}
+
+// WrapTransport returns the transport unchanged, faults aren't injected in this build.
+func WrapTransport(next http.RoundTripper) http.RoundTripper {
+	return next
+}
+
+// RegisterHandlers doesn't add the debug endpoint, faults aren't injected in this build.
+func RegisterHandlers(*mux.Router) {}
@@ -0,0 +1,158 @@
+//go:build faults
+
+package faults
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Enabled is true in builds injecting faults.
+const Enabled = true
+
+// sarPath is the path of the SubjectAccessReview API.
+const sarPath = "/apis/authorization.k8s.io/v1/subjectaccessreviews"
+
+// current holds the faults currently injected.
+var current = struct {
+	mutex  sync.RWMutex
+	config Config
+}{}
+
+// get returns the faults currently injected.
+func get() Config {
+	current.mutex.RLock()
+	defer current.mutex.RUnlock()
+	return current.config
+}
+
+// set replaces the faults currently injected.
+func set(config Config) {
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	current.config = config
+}
+
+// affects returns true if the faults apply to the webhook of the resource.
+func (c *Config) affects(resource string) bool {
+	if len(c.Webhooks) == 0 {
+		return true
+	}
+	for _, webhook := range c.Webhooks {
+		if strings.EqualFold(webhook, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// happens returns true with the probability of the rate.
+func happens(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// Handler returns the handler of the webhook of the resource, delaying requests and resetting their connections as
+// configured.
+func Handler(resource string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		config := get()
+		if !config.affects(resource) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		if config.Delay.Duration > 0 {
+			select {
+			case <-time.After(config.Delay.Duration):
+			case <-req.Context().Done():
+				return
+			}
+		}
+		if happens(config.ConnectionResetRate) {
+			resetConnection(rw)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// resetConnection closes the connection of the request without a response. HTTP/2 connections, which can't be
+// hijacked, have their stream reset instead.
+func resetConnection(rw http.ResponseWriter) {
+	if hijacker, ok := rw.(http.Hijacker); ok {
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			_ = conn.Close()
+			return
+		}
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// CacheError returns an error for the cache lookups failing in the webhook of the resource, and nil otherwise.
+func CacheError(resource string) error {
+	config := get()
+	if !config.affects(resource) || !happens(config.CacheErrorRate) {
+		return nil
+	}
+	return fmt.Errorf("cache lookup failed: %w", ErrInjected)
+}
+
+// sarTransport fails SubjectAccessReviews as configured.
+type sarTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip fails SubjectAccessReview creations as configured, and sends the other requests.
+func (t *sarTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, sarPath) && happens(get().SARErrorRate) {
+		return nil, fmt.Errorf("SubjectAccessReview failed: %w", ErrInjected)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// WrapTransport wraps the transport of the Kubernetes clients, failing SubjectAccessReviews as configured.
+func WrapTransport(next http.RoundTripper) http.RoundTripper {
+	return &sarTransport{next: next}
+}
+
+// RegisterHandlers adds the debug endpoint configuring the faults to the router.
+func RegisterHandlers(router *mux.Router) {
+	router.HandleFunc(Path, func(rw http.ResponseWriter, _ *http.Request) {
+		writeConfig(rw, get())
+	}).Methods(http.MethodGet)
+	router.HandleFunc(Path, func(rw http.ResponseWriter, req *http.Request) {
+		var config Config
+		decoder := json.NewDecoder(req.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			http.Error(rw, fmt.Sprintf("invalid faults: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := config.Validate(); err != nil {
+			http.Error(rw, fmt.Sprintf("invalid faults: %v", err), http.StatusBadRequest)
+			return
+		}
+		set(config)
+		logrus.Warnf("[faults] injecting faults: %+v", config)
+		writeConfig(rw, config)
+	}).Methods(http.MethodPut)
+	router.HandleFunc(Path, func(rw http.ResponseWriter, _ *http.Request) {
+		set(Config{})
+		logrus.Warn("[faults] stopped injecting faults")
+		writeConfig(rw, Config{})
+	}).Methods(http.MethodDelete)
+}
+
+func writeConfig(rw http.ResponseWriter, config Config) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(config); err != nil {
+		logrus.Errorf("failed to write faults response: %v", err)
+	}
+}
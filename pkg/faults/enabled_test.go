@@ -0,0 +1,98 @@
+//go:build faults
+
+package faults
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// roundTripperFunc sends requests with a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// The tests share the configured faults, so they don't run in parallel.
+
+func TestHandler(t *testing.T) {
+	t.Cleanup(func() { set(Config{}) })
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) { rw.WriteHeader(http.StatusOK) })
+	server := httptest.NewServer(Handler("projects.management.cattle.io", ok))
+	t.Cleanup(server.Close)
+
+	set(Config{Webhooks: []string{"clusters.management.cattle.io"}, ConnectionResetRate: 1})
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err, "other webhooks must not be affected")
+	resp.Body.Close()
+
+	set(Config{Delay: metav1.Duration{Duration: 100 * time.Millisecond}})
+	start := time.Now()
+	resp, err = server.Client().Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	set(Config{Webhooks: []string{"projects.management.cattle.io"}, ConnectionResetRate: 1})
+	_, err = server.Client().Get(server.URL)
+	assert.Error(t, err, "the connection must be reset")
+}
+
+func TestCacheError(t *testing.T) {
+	t.Cleanup(func() { set(Config{}) })
+	assert.NoError(t, CacheError("projects.management.cattle.io"))
+	set(Config{CacheErrorRate: 1})
+	assert.ErrorIs(t, CacheError("projects.management.cattle.io"), ErrInjected)
+}
+
+func TestWrapTransport(t *testing.T) {
+	t.Cleanup(func() { set(Config{}) })
+	transport := WrapTransport(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusCreated}, nil
+	}))
+	set(Config{SARErrorRate: 1})
+	sar := httptest.NewRequest(http.MethodPost, "https://kubernetes"+sarPath, nil)
+	_, err := transport.RoundTrip(sar)
+	assert.True(t, errors.Is(err, ErrInjected))
+	other := httptest.NewRequest(http.MethodPost, "https://kubernetes/api/v1/namespaces", nil)
+	resp, err := transport.RoundTrip(other)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestRegisterHandlers(t *testing.T) {
+	t.Cleanup(func() { set(Config{}) })
+	router := mux.NewRouter()
+	RegisterHandlers(router)
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, Path, strings.NewReader(`{"delay":"2s","sarErrorRate":0.5}`)))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Equal(t, 2*time.Second, get().Delay.Duration)
+	assert.Equal(t, 0.5, get().SARErrorRate)
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, Path, strings.NewReader(`{"sarErrorRate":2}`)))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, 0.5, get().SARErrorRate, "invalid faults must not be applied")
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, Path, nil))
+	var config Config
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &config))
+	assert.Equal(t, get(), config)
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodDelete, Path, nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, Config{}, get())
+}
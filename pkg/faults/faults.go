@@ -0,0 +1,54 @@
+// Package faults injects faults into the webhook, so that the behavior of the API server when the webhook degrades,
+// and the failure policy of each webhook, can be rehearsed. Faults are only injected by binaries built with the faults
+// build tag, the functions of this package do nothing in other builds.
+package faults
+
+import (
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Path is the path of the debug endpoint getting (GET), setting (PUT) and clearing (DELETE) the injected faults.
+const Path = "/debug/faults"
+
+// ErrInjected is the error of the injected failures.
+var ErrInjected = errors.New("injected fault")
+
+// Config are the faults to inject. Rates are the probability of a fault, between 0 and 1.
+type Config struct {
+	// Webhooks restricts the delays, connection resets and cache errors to the webhooks whose resource, e.g.
+	// projects.management.cattle.io, is in the list. All webhooks are affected if it's empty.
+	Webhooks []string `json:"webhooks,omitempty"`
+	// Delay is added before handling each admission request.
+	Delay metav1.Duration `json:"delay,omitempty"`
+	// ConnectionResetRate is the rate of admission requests whose connection is reset instead of answered.
+	ConnectionResetRate float64 `json:"connectionResetRate,omitempty"`
+	// CacheErrorRate is the rate of cache lookups of admitters failing.
+	CacheErrorRate float64 `json:"cacheErrorRate,omitempty"`
+	// SARErrorRate is the rate of SubjectAccessReviews failing, for all webhooks.
+	SARErrorRate float64 `json:"sarErrorRate,omitempty"`
+}
+
+// Validate returns an error if the rates aren't between 0 and 1 or the delay is negative.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Delay.Duration < 0 {
+		errs = append(errs, fmt.Errorf("delay %s must not be negative", c.Delay.Duration))
+	}
+	rates := []struct {
+		name  string
+		value float64
+	}{
+		{"connectionResetRate", c.ConnectionResetRate},
+		{"cacheErrorRate", c.CacheErrorRate},
+		{"sarErrorRate", c.SARErrorRate},
+	}
+	for _, rate := range rates {
+		if rate.value < 0 || rate.value > 1 {
+			errs = append(errs, fmt.Errorf("%s %v must be between 0 and 1", rate.name, rate.value))
+		}
+	}
+	return errors.Join(errs...)
+}
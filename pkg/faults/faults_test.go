@@ -0,0 +1,20 @@
+package faults
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+	valid := Config{Delay: metav1.Duration{Duration: time.Second}, ConnectionResetRate: 1, CacheErrorRate: 0.5}
+	assert.NoError(t, valid.Validate())
+	invalid := Config{Delay: metav1.Duration{Duration: -time.Second}, CacheErrorRate: 1.5, SARErrorRate: -0.1}
+	err := invalid.Validate()
+	assert.ErrorContains(t, err, "delay -1s must not be negative")
+	assert.ErrorContains(t, err, "cacheErrorRate 1.5 must be between 0 and 1")
+	assert.ErrorContains(t, err, "sarErrorRate -0.1 must be between 0 and 1")
+}
@@ -0,0 +1,136 @@
+// Package fixtures provides canonical objects the webhook allows or denies, for every resource it validates.
+// The webhook's own tests run the fixtures through its validators, so Rancher's integration suites and downstream
+// forks can test against the same corpus.
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// Username is the user making the requests of the fixtures, e.g. the value of the creator annotations.
+	Username = "u-fixtures"
+	// ClusterName is the management cluster the fixtures refer to. It must exist for fixtures referring to it to be allowed.
+	ClusterName = "local"
+)
+
+// Fixture is a request the webhook is expected to allow or deny.
+type Fixture struct {
+	// Name describes the fixture.
+	Name string
+	// Resource is the resource the webhook validates the object as.
+	Resource schema.GroupVersionResource
+	// Operation is the operation of the request.
+	Operation admissionv1.Operation
+	// Object is the object of the request. It's nil for deletes.
+	Object runtime.Object
+	// OldObject is the current object for updates and deletes.
+	OldObject runtime.Object
+	// Allowed is true if the webhook allows the request.
+	Allowed bool
+	// Denial is part of the message returned when the webhook denies the request.
+	Denial string
+	// Stateless is true if the webhook's decision depends only on the request. Other fixtures assume the objects
+	// they refer to, like the ClusterName cluster, exist and that Username is allowed to make the request.
+	Stateless bool
+}
+
+// Request returns the admission request of the fixture, made by Username.
+func (f Fixture) Request() (*admissionv1.AdmissionRequest, error) {
+	request := &admissionv1.AdmissionRequest{
+		UID:       "fixture",
+		Resource:  metav1.GroupVersionResource{Group: f.Resource.Group, Version: f.Resource.Version, Resource: f.Resource.Resource},
+		Operation: f.Operation,
+		UserInfo:  authenticationv1.UserInfo{Username: Username},
+	}
+	for _, obj := range []runtime.Object{f.Object, f.OldObject} {
+		if obj == nil {
+			continue
+		}
+		accessor, err := meta(obj)
+		if err != nil {
+			return nil, err
+		}
+		request.Name = accessor.GetName()
+		request.Namespace = accessor.GetNamespace()
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		request.Kind = metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}
+		break
+	}
+	var err error
+	if f.Object != nil {
+		if request.Object.Raw, err = json.Marshal(f.Object); err != nil {
+			return nil, fmt.Errorf("failed to marshal object of fixture %q: %w", f.Name, err)
+		}
+	}
+	if f.OldObject != nil {
+		if request.OldObject.Raw, err = json.Marshal(f.OldObject); err != nil {
+			return nil, fmt.Errorf("failed to marshal old object of fixture %q: %w", f.Name, err)
+		}
+	}
+	return request, nil
+}
+
+// meta returns the object metadata of obj.
+func meta(obj runtime.Object) (metav1.Object, error) {
+	accessor, ok := obj.(metav1.Object)
+	if !ok {
+		return nil, fmt.Errorf("object of kind %s has no metadata", obj.GetObjectKind().GroupVersionKind())
+	}
+	return accessor, nil
+}
+
+// All returns the fixtures of all resources. The objects are new on each call and can be modified.
+func All() []Fixture {
+	var fixtures []Fixture
+	for _, forResource := range []func() []Fixture{
+		clusterRepos,
+		clusterAuthTokens,
+		namespaces,
+		managementClusters,
+		clusterRoleTemplateBindings,
+		features,
+		globalRoles,
+		globalRoleBindings,
+		podSecurityAdmissionConfigurationTemplates,
+		projects,
+		projectRoleTemplateBindings,
+		roleTemplates,
+		settings,
+		tokens,
+		userAttributes,
+		provisioningClusters,
+		clusterRoles,
+		clusterRoleBindings,
+		roles,
+		roleBindings,
+		machineConfigs,
+	} {
+		fixtures = append(fixtures, forResource()...)
+	}
+	return fixtures
+}
+
+// For returns the fixtures of a resource.
+func For(gvr schema.GroupVersionResource) []Fixture {
+	var fixtures []Fixture
+	for _, fixture := range All() {
+		if fixture.Resource == gvr {
+			fixtures = append(fixtures, fixture)
+		}
+	}
+	return fixtures
+}
+
+// withGVK sets the type of obj to gvk and returns it.
+func withGVK[T runtime.Object](obj T, gvk schema.GroupVersionKind) T {
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return obj
+}
@@ -0,0 +1,135 @@
+package fixtures_test
+
+import (
+	"context"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/fixtures"
+	"github.com/rancher/webhook/pkg/resources/catalog.cattle.io/v1/clusterrepo"
+	"github.com/rancher/webhook/pkg/resources/cluster.cattle.io/v3/clusterauthtoken"
+	"github.com/rancher/webhook/pkg/resources/core/v1/namespace"
+	managementCluster "github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/cluster"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/feature"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/podsecurityadmissionconfigurationtemplate"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/project"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/setting"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/token"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/userattribute"
+	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/clusterrole"
+	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/clusterrolebinding"
+	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/role"
+	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/rolebinding"
+	"github.com/rancher/webhook/pkg/resources/rke-machine-config.cattle.io/v1/machineconfig"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+)
+
+// statelessValidators are the validators of the stateless fixtures. Their dependencies are nil, or mocks without
+// expectations, since stateless fixtures must not need them.
+func statelessValidators(t *testing.T) []admission.ValidatingAdmissionHandler {
+	ctrl := gomock.NewController(t)
+	managementClusters := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+	managementClusters.EXPECT().AddIndexer(gomock.Any(), gomock.Any()).AnyTimes()
+	provisioningClusters := fake.NewMockCacheInterface[*provv1.Cluster](ctrl)
+	provisioningClusters.EXPECT().AddIndexer(gomock.Any(), gomock.Any()).AnyTimes()
+	return []admission.ValidatingAdmissionHandler{
+		clusterrepo.NewValidator(),
+		clusterauthtoken.NewValidator(),
+		namespace.NewValidator(nil, nil, nil),
+		managementCluster.NewValidator(nil, nil, nil, nil, nil, nil),
+		feature.NewValidator(),
+		podsecurityadmissionconfigurationtemplate.NewValidator(managementClusters, provisioningClusters),
+		project.NewValidator(nil, nil, nil, nil, nil, nil, nil, nil, nil),
+		setting.NewValidator(nil, nil),
+		token.NewValidator(),
+		userattribute.NewValidator(),
+		clusterrole.NewValidator(),
+		clusterrolebinding.NewValidator(),
+		role.NewValidator(),
+		rolebinding.NewValidator(),
+		machineconfig.NewValidator(),
+	}
+}
+
+func TestStatelessFixtures(t *testing.T) {
+	t.Parallel()
+	validators := statelessValidators(t)
+	for _, fixture := range fixtures.All() {
+		if !fixture.Stateless {
+			continue
+		}
+		fixture := fixture
+		t.Run(fixture.Resource.Resource+"/"+fixture.Name, func(t *testing.T) {
+			t.Parallel()
+			request, err := fixture.Request()
+			require.NoError(t, err)
+			validator := validatorFor(validators, request)
+			require.NotNil(t, validator, "no validator for the fixture")
+
+			response := admit(t, validator, request)
+			assert.Equal(t, fixture.Allowed, response.Allowed)
+			if !fixture.Allowed {
+				require.NotNil(t, response.Result)
+				assert.Contains(t, response.Result.Message, fixture.Denial)
+			}
+		})
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	t.Parallel()
+	names := map[string]bool{}
+	for _, fixture := range fixtures.All() {
+		key := fixture.Resource.String() + "/" + fixture.Name
+		assert.False(t, names[key], "duplicate fixture %s", key)
+		names[key] = true
+		assert.NotEqual(t, fixture.Allowed, fixture.Denial != "", "fixture %s must be allowed or have a denial", key)
+
+		request, err := fixture.Request()
+		require.NoError(t, err)
+		assert.NotEmpty(t, request.Kind.Kind, "fixture %s has no kind", key)
+		assert.NotEmpty(t, request.Name, "fixture %s has no name", key)
+	}
+	assert.NotEmpty(t, fixtures.For(fixtures.All()[0].Resource))
+}
+
+// validatorFor returns the validator handling the request, like the webhook's routes.
+func validatorFor(validators []admission.ValidatingAdmissionHandler, request *admissionv1.AdmissionRequest) admission.ValidatingAdmissionHandler {
+	for _, validator := range validators {
+		gvr := validator.GVR()
+		if gvr.Group != request.Resource.Group || gvr.Version != request.Resource.Version {
+			continue
+		}
+		if gvr.Resource != "*" && gvr.Resource != request.Resource.Resource {
+			continue
+		}
+		for _, op := range validator.Operations() {
+			if string(op) == string(request.Operation) || op == admissionregistrationv1.OperationAll {
+				return validator
+			}
+		}
+	}
+	return nil
+}
+
+// admit runs the request through the admitters of the validator, stopping at the first denial.
+func admit(t *testing.T, validator admission.ValidatingAdmissionHandler, request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	t.Helper()
+	var response *admissionv1.AdmissionResponse
+	for _, admitter := range validator.Admitters() {
+		var err error
+		response, err = admitter.Admit(&admission.Request{AdmissionRequest: *request, Context: context.Background()})
+		require.NoError(t, err)
+		if !response.Allowed {
+			break
+		}
+	}
+	return response
+}
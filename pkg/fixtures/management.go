@@ -0,0 +1,429 @@
+package fixtures
+
+import (
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	rketypes "github.com/rancher/rke/types"
+	admissionv1 "k8s.io/api/admission/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	creatorIDAnn     = "field.cattle.io/creatorId"
+	noCreatorRBACAnn = "field.cattle.io/no-creator-rbac"
+	ruleOverridesAnn = "webhook.cattle.io/rule-overrides"
+)
+
+var managementGV = schema.GroupVersion{Group: "management.cattle.io", Version: "v3"}
+
+// managementClusters returns fixtures of management.cattle.io/v3 clusters.
+func managementClusters() []Fixture {
+	gvr := managementGV.WithResource("clusters")
+	cluster := func(annotations map[string]string) *v3.Cluster {
+		return withGVK(&v3.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "c-fixture", Annotations: annotations},
+		}, managementGV.WithKind("Cluster"))
+	}
+	rkeCluster := func(clusterCIDR, serviceCIDR string, annotations map[string]string) *v3.Cluster {
+		rke := cluster(annotations)
+		rke.Spec.RancherKubernetesEngineConfig = &rketypes.RancherKubernetesEngineConfig{
+			Services: rketypes.RKEConfigServices{
+				KubeController: rketypes.KubeControllerService{ClusterCIDR: clusterCIDR},
+				KubeAPI:        rketypes.KubeAPIService{ServiceClusterIPRange: serviceCIDR},
+			},
+		}
+		return rke
+	}
+	return []Fixture{
+		{
+			Name:      "cluster created by its creator",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    cluster(map[string]string{creatorIDAnn: Username}),
+			Allowed:   true,
+		},
+		{
+			Name:      "cluster created with creator annotations that conflict",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    cluster(map[string]string{creatorIDAnn: Username, noCreatorRBACAnn: "true"}),
+			Denial:    "cannot have both field.cattle.io/no-creator-rbac and field.cattle.io/creatorId annotation set",
+		},
+		{
+			Name:      "RKE cluster created with the default networking",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    rkeCluster("", "", nil),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "RKE cluster enforcing the networking rule created with overlapping cluster and service CIDRs",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    rkeCluster("10.42.0.0/16", "10.42.0.0/16", map[string]string{ruleOverridesAnn: `{"cluster-networking": "deny"}`}),
+			Denial:    "service CIDR 10.42.0.0/16 overlaps with cluster CIDR 10.42.0.0/16",
+			Stateless: true,
+		},
+	}
+}
+
+// clusterRoleTemplateBindings returns fixtures of clusterroletemplatebindings.
+func clusterRoleTemplateBindings() []Fixture {
+	gvr := managementGV.WithResource("clusterroletemplatebindings")
+	crtb := func(userName, groupName string) *v3.ClusterRoleTemplateBinding {
+		return withGVK(&v3.ClusterRoleTemplateBinding{
+			ObjectMeta:       metav1.ObjectMeta{Name: "crtb-fixture", Namespace: ClusterName},
+			ClusterName:      ClusterName,
+			RoleTemplateName: "cluster-member",
+			UserName:         userName,
+			GroupName:        groupName,
+		}, managementGV.WithKind("ClusterRoleTemplateBinding"))
+	}
+	return []Fixture{
+		{
+			Name:      "binding of a user to a cluster role",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    crtb(Username, ""),
+			Allowed:   true,
+		},
+		{
+			Name:      "binding of both a user and a group",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    crtb(Username, "g-fixtures"),
+			Denial:    "binding must target either a user [userName]/[userPrincipalName] OR a group [groupName]/[groupPrincipalName]",
+		},
+	}
+}
+
+// features returns fixtures of features.
+func features() []Fixture {
+	gvr := managementGV.WithResource("features")
+	feature := func(value bool, lockedValue *bool) *v3.Feature {
+		return withGVK(&v3.Feature{
+			ObjectMeta: metav1.ObjectMeta{Name: "fixture-feature"},
+			Spec:       v3.FeatureSpec{Value: &value},
+			Status:     v3.FeatureStatus{LockedValue: lockedValue},
+		}, managementGV.WithKind("Feature"))
+	}
+	locked := false
+	return []Fixture{
+		{
+			Name:      "feature enabled",
+			Resource:  gvr,
+			Operation: admissionv1.Update,
+			OldObject: feature(false, nil),
+			Object:    feature(true, nil),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "locked feature changed",
+			Resource:  gvr,
+			Operation: admissionv1.Update,
+			OldObject: feature(false, &locked),
+			Object:    feature(true, &locked),
+			Denial:    "feature flag cannot be changed from current value: false",
+			Stateless: true,
+		},
+	}
+}
+
+// globalRoles returns fixtures of globalroles.
+func globalRoles() []Fixture {
+	gvr := managementGV.WithResource("globalroles")
+	globalRole := func(rule rbacv1.PolicyRule) *v3.GlobalRole {
+		return withGVK(&v3.GlobalRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "gr-fixture"},
+			Rules:      []rbacv1.PolicyRule{rule},
+		}, managementGV.WithKind("GlobalRole"))
+	}
+	return []Fixture{
+		{
+			Name:      "global role reading settings",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    globalRole(rbacv1.PolicyRule{APIGroups: []string{managementGV.Group}, Resources: []string{"settings"}, Verbs: []string{"get", "list", "watch"}}),
+			Allowed:   true,
+		},
+		{
+			Name:      "global role with a rule without verbs",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    globalRole(rbacv1.PolicyRule{APIGroups: []string{managementGV.Group}, Resources: []string{"settings"}}),
+			Denial:    "verbs: Required value",
+		},
+	}
+}
+
+// globalRoleBindings returns fixtures of globalrolebindings.
+func globalRoleBindings() []Fixture {
+	gvr := managementGV.WithResource("globalrolebindings")
+	grb := func(userName, groupPrincipalName string) *v3.GlobalRoleBinding {
+		return withGVK(&v3.GlobalRoleBinding{
+			ObjectMeta:         metav1.ObjectMeta{Name: "grb-fixture"},
+			GlobalRoleName:     "user-base",
+			UserName:           userName,
+			GroupPrincipalName: groupPrincipalName,
+		}, managementGV.WithKind("GlobalRoleBinding"))
+	}
+	return []Fixture{
+		{
+			Name:      "binding of a user to a global role",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    grb(Username, ""),
+			Allowed:   true,
+		},
+		{
+			Name:      "binding of both a user and a group",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    grb(Username, "local://g-fixtures"),
+			Denial:    "bindings can not set both userName and groupPrincipalName",
+		},
+	}
+}
+
+// podSecurityAdmissionConfigurationTemplates returns fixtures of podsecurityadmissionconfigurationtemplates.
+func podSecurityAdmissionConfigurationTemplates() []Fixture {
+	gvr := managementGV.WithResource("podsecurityadmissionconfigurationtemplates")
+	template := func(enforce string) *v3.PodSecurityAdmissionConfigurationTemplate {
+		return withGVK(&v3.PodSecurityAdmissionConfigurationTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "psact-fixture"},
+			Configuration: v3.PodSecurityAdmissionConfigurationTemplateSpec{
+				Defaults: v3.PodSecurityAdmissionConfigurationTemplateDefaults{
+					Enforce: enforce,
+					Audit:   "restricted",
+					Warn:    "restricted",
+				},
+			},
+		}, managementGV.WithKind("PodSecurityAdmissionConfigurationTemplate"))
+	}
+	return []Fixture{
+		{
+			Name:      "template enforcing the baseline level",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    template("baseline"),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "template enforcing an unknown level",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    template("strict"),
+			Denial:    "defaults.enforce",
+			Stateless: true,
+		},
+	}
+}
+
+// projects returns fixtures of projects.
+func projects() []Fixture {
+	gvr := managementGV.WithResource("projects")
+	project := func(projectLimit, namespaceLimit string) *v3.Project {
+		return withGVK(&v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: "p-fixture", Namespace: ClusterName},
+			Spec: v3.ProjectSpec{
+				DisplayName: "fixture",
+				ClusterName: ClusterName,
+				ResourceQuota: &v3.ProjectResourceQuota{
+					Limit: v3.ResourceQuotaLimit{ConfigMaps: projectLimit},
+				},
+				NamespaceDefaultResourceQuota: &v3.NamespaceResourceQuota{
+					Limit: v3.ResourceQuotaLimit{ConfigMaps: namespaceLimit},
+				},
+			},
+		}, managementGV.WithKind("Project"))
+	}
+	systemProject := project("20", "10")
+	systemProject.Labels = map[string]string{"authz.management.cattle.io/system-project": "true"}
+	return []Fixture{
+		{
+			Name:      "project with a namespace quota within the project quota",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    project("20", "10"),
+			Allowed:   true,
+		},
+		{
+			Name:      "project with a namespace quota exceeding the project quota",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    project("20", "100"),
+			Denial:    "namespace default quota limit exceeds project limit on fields: configMaps=100",
+		},
+		{
+			Name:      "project deleted",
+			Resource:  gvr,
+			Operation: admissionv1.Delete,
+			OldObject: project("20", "10"),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "system project deleted",
+			Resource:  gvr,
+			Operation: admissionv1.Delete,
+			OldObject: systemProject,
+			Denial:    "System Project cannot be deleted",
+			Stateless: true,
+		},
+	}
+}
+
+// projectRoleTemplateBindings returns fixtures of projectroletemplatebindings.
+func projectRoleTemplateBindings() []Fixture {
+	gvr := managementGV.WithResource("projectroletemplatebindings")
+	prtb := func(projectName string) *v3.ProjectRoleTemplateBinding {
+		return withGVK(&v3.ProjectRoleTemplateBinding{
+			ObjectMeta:       metav1.ObjectMeta{Name: "prtb-fixture", Namespace: "p-fixture"},
+			ProjectName:      projectName,
+			RoleTemplateName: "project-member",
+			UserName:         Username,
+		}, managementGV.WithKind("ProjectRoleTemplateBinding"))
+	}
+	return []Fixture{
+		{
+			Name:      "binding of a user to a project role",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    prtb(ClusterName + ":p-fixture"),
+			Allowed:   true,
+		},
+		{
+			Name:      "binding with a project name without the cluster",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    prtb("p-fixture"),
+			Denial:    "projectName must be of the form cluster.metadata.name:project.metadata.name",
+		},
+	}
+}
+
+// roleTemplates returns fixtures of roletemplates.
+func roleTemplates() []Fixture {
+	gvr := managementGV.WithResource("roletemplates")
+	roleTemplate := func(context string) *v3.RoleTemplate {
+		return withGVK(&v3.RoleTemplate{
+			ObjectMeta:            metav1.ObjectMeta{Name: "rt-fixture"},
+			Context:               context,
+			ProjectCreatorDefault: true,
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "watch"}},
+			},
+		}, managementGV.WithKind("RoleTemplate"))
+	}
+	return []Fixture{
+		{
+			Name:      "project creator default role template",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    roleTemplate("project"),
+			Allowed:   true,
+		},
+		{
+			Name:      "project creator default role template of the cluster context",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    roleTemplate("cluster"),
+			Denial:    "RoleTemplate context must be project when projectCreatorDefault=true",
+		},
+	}
+}
+
+// settings returns fixtures of settings.
+func settings() []Fixture {
+	gvr := managementGV.WithResource("settings")
+	setting := func(value string) *v3.Setting {
+		return withGVK(&v3.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: "user-retention-cron"},
+			Value:      value,
+		}, managementGV.WithKind("Setting"))
+	}
+	return []Fixture{
+		{
+			Name:      "user retention cron set to a cron expression",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    setting("0 0 * * 0"),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "user retention cron set to an invalid cron expression",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    setting("every sunday"),
+			Denial:    "value: Invalid value",
+			Stateless: true,
+		},
+	}
+}
+
+// tokens returns fixtures of tokens. The objects are unstructured since lastUsedAt can't hold invalid dates in the
+// typed object.
+func tokens() []Fixture {
+	gvr := managementGV.WithResource("tokens")
+	return []Fixture{
+		{
+			Name:      "token last used at a date time",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    lastUsedAtObject(managementGV.WithKind("Token"), "token-fixture", "2023-11-29T00:00:00Z"),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "token last used at an invalid date time",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    lastUsedAtObject(managementGV.WithKind("Token"), "token-fixture", "yesterday"),
+			Denial:    "lastUsedAt: Invalid value",
+			Stateless: true,
+		},
+	}
+}
+
+// userAttributes returns fixtures of userattributes.
+func userAttributes() []Fixture {
+	gvr := managementGV.WithResource("userattributes")
+	userAttribute := func(disableAfter string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]any{"disableAfter": disableAfter}}
+		obj.SetGroupVersionKind(managementGV.WithKind("UserAttribute"))
+		obj.SetName(Username)
+		return obj
+	}
+	return []Fixture{
+		{
+			Name:      "user disabled after a duration",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    userAttribute("240h"),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "user disabled after a negative duration",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    userAttribute("-240h"),
+			Denial:    "negative duration",
+			Stateless: true,
+		},
+	}
+}
+
+// lastUsedAtObject returns an object of the given kind with lastUsedAt set to the given value.
+func lastUsedAtObject(gvk schema.GroupVersionKind, name, lastUsedAt string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{"lastUsedAt": lastUsedAt}}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name)
+	return obj
+}
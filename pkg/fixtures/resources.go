@@ -0,0 +1,240 @@
+package fixtures
+
+import (
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	grOwnerLabel  = "authz.management.cattle.io/gr-owner"
+	grbOwnerLabel = "authz.management.cattle.io/grb-owner"
+)
+
+// clusterRepos returns fixtures of catalog.cattle.io/v1 clusterrepos.
+func clusterRepos() []Fixture {
+	gvr := catalogv1.SchemeGroupVersion.WithResource("clusterrepos")
+	clusterRepo := func(url, gitRepo string) *catalogv1.ClusterRepo {
+		return withGVK(&catalogv1.ClusterRepo{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-fixture"},
+			Spec:       catalogv1.RepoSpec{URL: url, GitRepo: gitRepo},
+		}, catalogv1.SchemeGroupVersion.WithKind("ClusterRepo"))
+	}
+	return []Fixture{
+		{
+			Name:      "repository served over http",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    clusterRepo("https://charts.rancher.io", ""),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "repository with both a URL and a git repository",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    clusterRepo("https://charts.rancher.io", "https://github.com/rancher/charts"),
+			Denial:    "both fields spec.URL and spec.GitRepo cannot be specified simultaneously",
+			Stateless: true,
+		},
+	}
+}
+
+// clusterAuthTokens returns fixtures of cluster.cattle.io/v3 clusterauthtokens.
+func clusterAuthTokens() []Fixture {
+	gv := schema.GroupVersion{Group: "cluster.cattle.io", Version: "v3"}
+	gvr := gv.WithResource("clusterauthtokens")
+	return []Fixture{
+		{
+			Name:      "cluster token last used at a date time",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    lastUsedAtObject(gv.WithKind("ClusterAuthToken"), "token-fixture", "2023-11-29T00:00:00Z"),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "cluster token last used at an invalid date time",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    lastUsedAtObject(gv.WithKind("ClusterAuthToken"), "token-fixture", "yesterday"),
+			Denial:    "lastUsedAt: Invalid value",
+			Stateless: true,
+		},
+	}
+}
+
+// namespaces returns fixtures of namespaces.
+func namespaces() []Fixture {
+	gvr := corev1.SchemeGroupVersion.WithResource("namespaces")
+	namespace := func(resourceLimit string) *corev1.Namespace {
+		return withGVK(&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "ns-fixture",
+				Annotations: map[string]string{"field.cattle.io/containerDefaultResourceLimit": resourceLimit},
+			},
+		}, corev1.SchemeGroupVersion.WithKind("Namespace"))
+	}
+	return []Fixture{
+		{
+			Name:      "namespace with container limits above the requests",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    namespace(`{"requestsCpu": "100m", "limitsCpu": "200m"}`),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "namespace with container limits below the requests",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    namespace(`{"requestsCpu": "200m", "limitsCpu": "100m"}`),
+			Denial:    "requestsCpu (200m) cannot be greater than limitsCpu (100m)",
+			Stateless: true,
+		},
+	}
+}
+
+// provisioningClusters returns fixtures of provisioning.cattle.io/v1 clusters.
+func provisioningClusters() []Fixture {
+	gvr := provv1.SchemeGroupVersion.WithResource("clusters")
+	cluster := func(systemAgentDir string) *provv1.Cluster {
+		return withGVK(&provv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "fixture",
+				Namespace:   "fleet-default",
+				Annotations: map[string]string{creatorIDAnn: Username},
+			},
+			Spec: provv1.ClusterSpec{
+				KubernetesVersion: "v1.31.4+rke2r1",
+				RKEConfig: &provv1.RKEConfig{
+					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+						DataDirectories: rkev1.DataDirectories{SystemAgent: systemAgentDir},
+					},
+				},
+			},
+		}, provv1.SchemeGroupVersion.WithKind("Cluster"))
+	}
+	return []Fixture{
+		{
+			Name:      "RKE2 cluster with an absolute system agent data directory",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    cluster("/var/lib/fixture/agent"),
+			Allowed:   true,
+		},
+		{
+			Name:      "RKE2 cluster with a relative system agent data directory",
+			Resource:  gvr,
+			Operation: admissionv1.Create,
+			Object:    cluster("fixture/agent"),
+			Denial:    "data directory must be an absolute path",
+		},
+	}
+}
+
+// clusterRoles returns fixtures of clusterroles.
+func clusterRoles() []Fixture {
+	return ownerLabelFixtures(rbacv1.SchemeGroupVersion.WithResource("clusterroles"), grOwnerLabel, func(labels map[string]string) runtime.Object {
+		return withGVK(&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "cr-fixture", Labels: labels},
+		}, rbacv1.SchemeGroupVersion.WithKind("ClusterRole"))
+	})
+}
+
+// clusterRoleBindings returns fixtures of clusterrolebindings.
+func clusterRoleBindings() []Fixture {
+	return ownerLabelFixtures(rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings"), grbOwnerLabel, func(labels map[string]string) runtime.Object {
+		return withGVK(&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "crb-fixture", Labels: labels},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "cr-fixture"},
+		}, rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding"))
+	})
+}
+
+// roles returns fixtures of roles.
+func roles() []Fixture {
+	return ownerLabelFixtures(rbacv1.SchemeGroupVersion.WithResource("roles"), grOwnerLabel, func(labels map[string]string) runtime.Object {
+		return withGVK(&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "role-fixture", Namespace: "ns-fixture", Labels: labels},
+		}, rbacv1.SchemeGroupVersion.WithKind("Role"))
+	})
+}
+
+// roleBindings returns fixtures of rolebindings.
+func roleBindings() []Fixture {
+	return ownerLabelFixtures(rbacv1.SchemeGroupVersion.WithResource("rolebindings"), grbOwnerLabel, func(labels map[string]string) runtime.Object {
+		return withGVK(&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "rb-fixture", Namespace: "ns-fixture", Labels: labels},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "role-fixture"},
+		}, rbacv1.SchemeGroupVersion.WithKind("RoleBinding"))
+	})
+}
+
+// ownerLabelFixtures returns fixtures of RBAC objects owned by a global role or global role binding through the
+// given label, which can't be changed or removed. The label is reserved to Rancher, so changing it is denied by the
+// reserved metadata check before the validators of the objects see it, and only its removal is checked here.
+func ownerLabelFixtures(gvr schema.GroupVersionResource, ownerLabel string, object func(labels map[string]string) runtime.Object) []Fixture {
+	return []Fixture{
+		{
+			Name:      "owned object labeled",
+			Resource:  gvr,
+			Operation: admissionv1.Update,
+			OldObject: object(map[string]string{ownerLabel: "fixture-owner"}),
+			Object:    object(map[string]string{ownerLabel: "fixture-owner", "fixture": "true"}),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "owner label removed",
+			Resource:  gvr,
+			Operation: admissionv1.Update,
+			OldObject: object(map[string]string{ownerLabel: "fixture-owner"}),
+			Object:    object(map[string]string{"fixture": "true"}),
+			Denial:    "cannot modify or remove label " + ownerLabel,
+			Stateless: true,
+		},
+	}
+}
+
+// machineConfigs returns fixtures of rke-machine-config.cattle.io/v1 machine configs. Machine config kinds are
+// defined by node drivers, so the objects are unstructured.
+func machineConfigs() []Fixture {
+	gv := schema.GroupVersion{Group: "rke-machine-config.cattle.io", Version: "v1"}
+	gvr := gv.WithResource("amazonec2configs")
+	machineConfig := func(annotations map[string]string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]any{"region": "us-west-2"}}
+		obj.SetGroupVersionKind(gv.WithKind("Amazonec2Config"))
+		obj.SetName("nc-fixture")
+		obj.SetNamespace("fleet-default")
+		obj.SetAnnotations(annotations)
+		return obj
+	}
+	return []Fixture{
+		{
+			Name:      "machine config updated",
+			Resource:  gvr,
+			Operation: admissionv1.Update,
+			OldObject: machineConfig(map[string]string{creatorIDAnn: Username}),
+			Object:    machineConfig(map[string]string{creatorIDAnn: Username, "fixture": "true"}),
+			Allowed:   true,
+			Stateless: true,
+		},
+		{
+			Name:      "machine config given to another creator",
+			Resource:  gvr,
+			Operation: admissionv1.Update,
+			OldObject: machineConfig(map[string]string{creatorIDAnn: Username}),
+			Object:    machineConfig(map[string]string{creatorIDAnn: "u-other"}),
+			Denial:    "creatorID annotation cannot be changed",
+			Stateless: true,
+		},
+	}
+}
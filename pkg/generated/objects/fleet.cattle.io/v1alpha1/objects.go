@@ -0,0 +1,62 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// BundleOldAndNewFromRequest gets the old and new Bundle objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for Bundle.
+// Similarly, if the request is a Create operation, then the old object is the zero value for Bundle.
+func BundleOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1alpha1.Bundle, *v1alpha1.Bundle, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1alpha1.Bundle{}
+	oldObject := &v1alpha1.Bundle{}
+
+	if request.Operation != admissionv1.Delete {
+		err := json.Unmarshal(request.Object.Raw, object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// BundleFromRequest returns a Bundle object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func BundleFromRequest(request *admissionv1.AdmissionRequest) (*v1alpha1.Bundle, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1alpha1.Bundle{}
+	raw := request.Object.Raw
+
+	if request.Operation == admissionv1.Delete {
+		raw = request.OldObject.Raw
+	}
+
+	err := json.Unmarshal(raw, object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
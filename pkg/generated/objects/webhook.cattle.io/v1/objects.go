@@ -0,0 +1,274 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// MaintenanceWindowOldAndNewFromRequest gets the old and new MaintenanceWindow objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for MaintenanceWindow.
+// Similarly, if the request is a Create operation, then the old object is the zero value for MaintenanceWindow.
+func MaintenanceWindowOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.MaintenanceWindow, *v1.MaintenanceWindow, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.MaintenanceWindow{}
+	oldObject := &v1.MaintenanceWindow{}
+
+	if request.Operation != admissionv1.Delete {
+		err := json.Unmarshal(request.Object.Raw, object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// MaintenanceWindowFromRequest returns a MaintenanceWindow object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func MaintenanceWindowFromRequest(request *admissionv1.AdmissionRequest) (*v1.MaintenanceWindow, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.MaintenanceWindow{}
+	raw := request.Object.Raw
+
+	if request.Operation == admissionv1.Delete {
+		raw = request.OldObject.Raw
+	}
+
+	err := json.Unmarshal(raw, object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// TaintPolicyOldAndNewFromRequest gets the old and new TaintPolicy objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for TaintPolicy.
+// Similarly, if the request is a Create operation, then the old object is the zero value for TaintPolicy.
+func TaintPolicyOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.TaintPolicy, *v1.TaintPolicy, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.TaintPolicy{}
+	oldObject := &v1.TaintPolicy{}
+
+	if request.Operation != admissionv1.Delete {
+		err := json.Unmarshal(request.Object.Raw, object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// TaintPolicyFromRequest returns a TaintPolicy object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func TaintPolicyFromRequest(request *admissionv1.AdmissionRequest) (*v1.TaintPolicy, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.TaintPolicy{}
+	raw := request.Object.Raw
+
+	if request.Operation == admissionv1.Delete {
+		raw = request.OldObject.Raw
+	}
+
+	err := json.Unmarshal(raw, object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// ClusterTierPolicyOldAndNewFromRequest gets the old and new ClusterTierPolicy objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for ClusterTierPolicy.
+// Similarly, if the request is a Create operation, then the old object is the zero value for ClusterTierPolicy.
+func ClusterTierPolicyOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.ClusterTierPolicy, *v1.ClusterTierPolicy, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.ClusterTierPolicy{}
+	oldObject := &v1.ClusterTierPolicy{}
+
+	if request.Operation != admissionv1.Delete {
+		err := json.Unmarshal(request.Object.Raw, object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// ClusterTierPolicyFromRequest returns a ClusterTierPolicy object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func ClusterTierPolicyFromRequest(request *admissionv1.AdmissionRequest) (*v1.ClusterTierPolicy, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.ClusterTierPolicy{}
+	raw := request.Object.Raw
+
+	if request.Operation == admissionv1.Delete {
+		raw = request.OldObject.Raw
+	}
+
+	err := json.Unmarshal(raw, object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// CustomPolicyOldAndNewFromRequest gets the old and new CustomPolicy objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for CustomPolicy.
+// Similarly, if the request is a Create operation, then the old object is the zero value for CustomPolicy.
+func CustomPolicyOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.CustomPolicy, *v1.CustomPolicy, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.CustomPolicy{}
+	oldObject := &v1.CustomPolicy{}
+
+	if request.Operation != admissionv1.Delete {
+		err := json.Unmarshal(request.Object.Raw, object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// CustomPolicyFromRequest returns a CustomPolicy object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func CustomPolicyFromRequest(request *admissionv1.AdmissionRequest) (*v1.CustomPolicy, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.CustomPolicy{}
+	raw := request.Object.Raw
+
+	if request.Operation == admissionv1.Delete {
+		raw = request.OldObject.Raw
+	}
+
+	err := json.Unmarshal(raw, object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// PolicyExceptionRequestOldAndNewFromRequest gets the old and new PolicyExceptionRequest objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for PolicyExceptionRequest.
+// Similarly, if the request is a Create operation, then the old object is the zero value for PolicyExceptionRequest.
+func PolicyExceptionRequestOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.PolicyExceptionRequest, *v1.PolicyExceptionRequest, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.PolicyExceptionRequest{}
+	oldObject := &v1.PolicyExceptionRequest{}
+
+	if request.Operation != admissionv1.Delete {
+		err := json.Unmarshal(request.Object.Raw, object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// PolicyExceptionRequestFromRequest returns a PolicyExceptionRequest object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func PolicyExceptionRequestFromRequest(request *admissionv1.AdmissionRequest) (*v1.PolicyExceptionRequest, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.PolicyExceptionRequest{}
+	raw := request.Object.Raw
+
+	if request.Operation == admissionv1.Delete {
+		raw = request.OldObject.Raw
+	}
+
+	err := json.Unmarshal(raw, object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
@@ -17,6 +17,12 @@ func RegisterHealthCheckers(router *mux.Router, checkers ...healthz.HealthChecke
 	healthz.InstallHandler(&muxWrapper{router}, checkers...)
 }
 
+// RegisterReadinessCheckers adds the readyz endpoint to the webhook. Unlike the healthz endpoint, which tells whether
+// the webhook is alive, it tells whether the webhook can admit requests.
+func RegisterReadinessCheckers(router *mux.Router, checkers ...healthz.HealthChecker) {
+	healthz.InstallReadyzHandler(&muxWrapper{router}, checkers...)
+}
+
 // NewErrorChecker returns a new error checker initialized with a "not ready" error
 func NewErrorChecker(name string) *ErrorChecker {
 	return &ErrorChecker{
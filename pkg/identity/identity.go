@@ -0,0 +1,192 @@
+// Package identity resolves the users making requests, and the users named by objects, to their Rancher users,
+// principals and groups, so that every validator handles missing users and principals the same way.
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// principalIDExtra is the extra key Rancher sets to the principal of the users it impersonates.
+const principalIDExtra = "principalid"
+
+// ErrUserNotFound is returned, wrapped, when a Rancher user doesn't exist.
+var ErrUserNotFound = errors.New("user doesn't exist")
+
+// Identity is a user as seen by the webhook: the Kubernetes user info of a request and, when the user is a Rancher
+// user, the Rancher user.
+type Identity struct {
+	// Name is the name of the user.
+	Name string
+	// UID is the UID of the user, empty for users named by objects.
+	UID string
+	// Groups are the groups the user is a member of, empty for users named by objects.
+	Groups []string
+	// Extra is the extra information of the user, empty for users named by objects.
+	Extra map[string][]string
+	// User is the Rancher user, nil if the user isn't a Rancher user, e.g. a service account.
+	User *v3.User
+}
+
+// FromUserInfo returns the identity of the user info of a request, without its Rancher user.
+func FromUserInfo(info authenticationv1.UserInfo) *Identity {
+	extra := make(map[string][]string, len(info.Extra))
+	for k, v := range info.Extra {
+		extra[k] = v
+	}
+	return &Identity{
+		Name:   info.Username,
+		UID:    info.UID,
+		Groups: info.Groups,
+		Extra:  extra,
+	}
+}
+
+// PrincipalIDs returns the principals of the user: the principals of its Rancher user followed by the principal
+// Rancher impersonated the request as, if it isn't one of them.
+func (i *Identity) PrincipalIDs() []string {
+	var principals []string
+	if i.User != nil {
+		principals = append(principals, i.User.PrincipalIDs...)
+	}
+	for _, principal := range i.Extra[principalIDExtra] {
+		if principal != "" && !slices.Contains(principals, principal) {
+			principals = append(principals, principal)
+		}
+	}
+	return principals
+}
+
+// PrimaryPrincipal returns the first principal of the user, or an empty string if it has none.
+func (i *Identity) PrimaryPrincipal() string {
+	if principals := i.PrincipalIDs(); len(principals) > 0 {
+		return principals[0]
+	}
+	return ""
+}
+
+// HasPrincipal returns whether the principal is one of the principals of the user.
+func (i *Identity) HasPrincipal(principal string) bool {
+	return principal != "" && slices.Contains(i.PrincipalIDs(), principal)
+}
+
+// InGroup returns whether the user is a member of the group.
+func (i *Identity) InGroup(group string) bool {
+	return slices.Contains(i.Groups, group)
+}
+
+// Info returns the user as the user info used by the Kubernetes authorizers, e.g. to check for privilege escalation.
+func (i *Identity) Info() user.Info {
+	return &user.DefaultInfo{
+		Name:   i.Name,
+		UID:    i.UID,
+		Groups: i.Groups,
+		Extra:  i.Extra,
+	}
+}
+
+// Resolver resolves users to their Rancher users. Rancher users are read from the informer cache of the users, so
+// resolving them doesn't reach the API server.
+type Resolver struct {
+	userCache controllerv3.UserCache
+	verifier  PrincipalVerifier
+}
+
+// NewResolver returns a new Resolver reading Rancher users from the given cache. It returns nil if the cache is nil,
+// as it is on downstream clusters, which have no Rancher users.
+func NewResolver(userCache controllerv3.UserCache) *Resolver {
+	if userCache == nil {
+		return nil
+	}
+	return &Resolver{userCache: userCache}
+}
+
+// WithPrincipalVerifier sets the verifier of the principals which aren't stored on the Rancher users, see
+// VerifyPrincipal. It returns the resolver, nil if it's nil.
+func (r *Resolver) WithPrincipalVerifier(verifier PrincipalVerifier) *Resolver {
+	if r != nil {
+		r.verifier = verifier
+	}
+	return r
+}
+
+// User returns the identity of the Rancher user with the given name. The error wraps ErrUserNotFound if the user
+// doesn't exist.
+func (r *Resolver) User(name string) (*Identity, error) {
+	rancherUser, err := r.getUser(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{Name: name, User: rancherUser}, nil
+}
+
+// Resolve returns the identity of the user info of a request, along with its Rancher user if the request is made by
+// one. Users which aren't Rancher users, e.g. service accounts, are resolved without a Rancher user.
+func (r *Resolver) Resolve(info authenticationv1.UserInfo) (*Identity, error) {
+	id := FromUserInfo(info)
+	// Kubernetes users, e.g. system:admin or service accounts, are namespaced by colons, which Rancher user names never
+	// contain
+	if info.Username == "" || strings.Contains(info.Username, ":") {
+		return id, nil
+	}
+	rancherUser, err := r.getUser(info.Username)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+	id.User = rancherUser
+	return id, nil
+}
+
+// VerifyPrincipal returns whether the principal belongs to the Rancher user with the given name: whether it's one of the
+// principals of the user or, failing that, the principal verifier confirms it. The verifier is also asked when the user
+// doesn't exist, since Rancher creates the users of SSO principals when they first log in. The error wraps
+// ErrUserNotFound if the user doesn't exist and the principal isn't verified. Errors of the verifier are logged and
+// the principal is treated as unverified, so that an unavailable verifier doesn't fail requests.
+func (r *Resolver) VerifyPrincipal(ctx context.Context, username, principal string) (bool, error) {
+	id, err := r.User(username)
+	if err == nil && id.HasPrincipal(principal) {
+		return true, nil
+	}
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return false, err
+	}
+	if r.verifier == nil || principal == "" {
+		return false, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	verified, verifyErr := r.verifier.VerifyPrincipal(ctx, username, principal)
+	if verifyErr != nil {
+		logrus.Warnf("[identity] failed to verify principal %s of user %s: %v", principal, username, verifyErr)
+		return false, err
+	}
+	if verified {
+		return true, nil
+	}
+	return false, err
+}
+
+func (r *Resolver) getUser(name string) (*v3.User, error) {
+	if name == "" {
+		return nil, fmt.Errorf("user %q: %w", name, ErrUserNotFound)
+	}
+	rancherUser, err := r.userCache.Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("user %s: %w", name, ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("failed to get user %s: %w", name, err)
+	}
+	return rancherUser, nil
+}
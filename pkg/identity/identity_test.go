@@ -0,0 +1,96 @@
+package identity
+
+import (
+	"errors"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newUserCache(t *testing.T) *fake.MockNonNamespacedCacheInterface[*v3.User] {
+	ctrl := gomock.NewController(t)
+	userCache := fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl)
+	userCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.User, error) {
+		switch name {
+		case "u-12345":
+			return &v3.User{
+				ObjectMeta:   metav1.ObjectMeta{Name: name},
+				PrincipalIDs: []string{"local://u-12345", "keycloak_user://12345"},
+			}, nil
+		case "u-broken":
+			return nil, errors.New("some error")
+		}
+		return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+	}).AnyTimes()
+	return userCache
+}
+
+func TestResolverUser(t *testing.T) {
+	t.Parallel()
+	resolver := NewResolver(newUserCache(t))
+
+	id, err := resolver.User("u-12345")
+	require.NoError(t, err)
+	assert.Equal(t, "u-12345", id.Name)
+	assert.Equal(t, "local://u-12345", id.PrimaryPrincipal())
+	assert.True(t, id.HasPrincipal("keycloak_user://12345"))
+	assert.False(t, id.HasPrincipal(""))
+
+	_, err = resolver.User("u-missing")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = resolver.User("")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = resolver.User("u-broken")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestResolverResolve(t *testing.T) {
+	t.Parallel()
+	resolver := NewResolver(newUserCache(t))
+
+	id, err := resolver.Resolve(authenticationv1.UserInfo{
+		Username: "u-12345",
+		Groups:   []string{"system:authenticated"},
+		Extra:    map[string]authenticationv1.ExtraValue{"principalid": {"keycloak_user://12345"}},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, id.User)
+	assert.Equal(t, []string{"local://u-12345", "keycloak_user://12345"}, id.PrincipalIDs())
+	assert.True(t, id.InGroup("system:authenticated"))
+	assert.Equal(t, []string{"system:authenticated"}, id.Info().GetGroups())
+
+	id, err = resolver.Resolve(authenticationv1.UserInfo{
+		Username: "system:serviceaccount:cattle-system:rancher",
+		Groups:   []string{"system:serviceaccounts:cattle-system"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, id.User)
+	assert.Empty(t, id.PrincipalIDs())
+	assert.True(t, id.InGroup("system:serviceaccounts:cattle-system"))
+
+	id, err = resolver.Resolve(authenticationv1.UserInfo{
+		Username: "u-missing",
+		Extra:    map[string]authenticationv1.ExtraValue{"principalid": {"local://u-missing"}},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, id.User)
+	assert.Equal(t, "local://u-missing", id.PrimaryPrincipal())
+
+	_, err = resolver.Resolve(authenticationv1.UserInfo{Username: "u-broken"})
+	assert.Error(t, err)
+}
+
+func TestNewResolverWithoutUsers(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, NewResolver(nil))
+}
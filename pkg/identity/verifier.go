@@ -0,0 +1,135 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/rancher/webhook/pkg/cache"
+)
+
+const (
+	// PrincipalVerifierURLEnv is the URL of the HTTP endpoint verifying principals, see NewHTTPPrincipalVerifier.
+	PrincipalVerifierURLEnv = "CATTLE_WEBHOOK_PRINCIPAL_VERIFIER_URL"
+	// PrincipalVerifierCacheTTLEnv overrides how long the results of the principal verifier are cached.
+	PrincipalVerifierCacheTTLEnv = "CATTLE_WEBHOOK_PRINCIPAL_VERIFIER_CACHE_TTL"
+
+	// DefaultPrincipalVerifierCacheTTL is how long the results of the principal verifier are cached by default.
+	DefaultPrincipalVerifierCacheTTL = time.Minute
+	// principalVerifierCacheSize is the number of user and principal pairs whose verification is cached.
+	principalVerifierCacheSize = 1000
+	// principalVerifierTimeout bounds the requests to the principal verifier, which are made while admitting requests.
+	principalVerifierTimeout = 5 * time.Second
+)
+
+// PrincipalVerifier verifies the principals of users which aren't stored on their Rancher users, e.g. the principal of
+// an SSO user who just logged in, which Rancher adds to the user, or creates the user with, after the fact.
+type PrincipalVerifier interface {
+	// VerifyPrincipal returns whether the principal belongs to the Rancher user with the given name, which may not
+	// exist yet.
+	VerifyPrincipal(ctx context.Context, username, principal string) (bool, error)
+}
+
+// PrincipalVerifierFromEnv returns the HTTP principal verifier configured by PrincipalVerifierURLEnv, with its results
+// cached for PrincipalVerifierCacheTTLEnv. It returns nil if no URL is configured.
+func PrincipalVerifierFromEnv() (PrincipalVerifier, error) {
+	endpoint := os.Getenv(PrincipalVerifierURLEnv)
+	if endpoint == "" {
+		return nil, nil
+	}
+	verifier, err := NewHTTPPrincipalVerifier(endpoint, &http.Client{Timeout: principalVerifierTimeout})
+	if err != nil {
+		return nil, err
+	}
+	ttl := DefaultPrincipalVerifierCacheTTL
+	if value := os.Getenv(PrincipalVerifierCacheTTLEnv); value != "" {
+		if ttl, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("failed to decode %s value '%s': %w", PrincipalVerifierCacheTTLEnv, value, err)
+		}
+	}
+	return NewCachedPrincipalVerifier(verifier, ttl), nil
+}
+
+// httpPrincipalVerifier verifies principals with an HTTP endpoint.
+type httpPrincipalVerifier struct {
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewHTTPPrincipalVerifier returns a PrincipalVerifier calling an HTTP endpoint, e.g. a service in front of Rancher's
+// auth provider API. The endpoint is called with GET and the user and principal query parameters, and must reply 200
+// if the principal belongs to the user, and 404 if it doesn't.
+func NewHTTPPrincipalVerifier(endpoint string, client *http.Client) (PrincipalVerifier, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid principal verifier URL %q: %w", endpoint, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid principal verifier URL %q: scheme must be http or https", endpoint)
+	}
+	return &httpPrincipalVerifier{endpoint: parsed, client: client}, nil
+}
+
+// VerifyPrincipal asks the endpoint whether the principal belongs to the user.
+func (v *httpPrincipalVerifier) VerifyPrincipal(ctx context.Context, username, principal string) (bool, error) {
+	endpoint := *v.endpoint
+	query := endpoint.Query()
+	query.Set("user", username)
+	query.Set("principal", principal)
+	endpoint.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to call principal verifier: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("principal verifier replied %s", resp.Status)
+	}
+}
+
+// cachedPrincipalVerifier caches the results of a verifier.
+type cachedPrincipalVerifier struct {
+	verifier PrincipalVerifier
+	cache    *cache.Cache[principalKey, bool]
+}
+
+// principalKey is the cache key of the verification of a principal.
+type principalKey struct {
+	username, principal string
+}
+
+// NewCachedPrincipalVerifier returns a PrincipalVerifier caching the results of the verifier for ttl, so that
+// repeated checks of the same principal, e.g. by the validators of the objects a user creates in a row, call the
+// verifier once. Errors aren't cached.
+func NewCachedPrincipalVerifier(verifier PrincipalVerifier, ttl time.Duration) PrincipalVerifier {
+	return &cachedPrincipalVerifier{
+		verifier: verifier,
+		cache:    cache.New[principalKey, bool]("principal-verifier", principalVerifierCacheSize, ttl),
+	}
+}
+
+// VerifyPrincipal returns the cached result of the verifier, calling it on a cache miss.
+func (v *cachedPrincipalVerifier) VerifyPrincipal(ctx context.Context, username, principal string) (bool, error) {
+	key := principalKey{username: username, principal: principal}
+	if verified, ok := v.cache.Get(key); ok {
+		return verified, nil
+	}
+	verified, err := v.verifier.VerifyPrincipal(ctx, username, principal)
+	if err != nil {
+		return false, err
+	}
+	v.cache.Add(key, verified)
+	return verified, nil
+}
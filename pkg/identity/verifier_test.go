@@ -0,0 +1,76 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPPrincipalVerifier(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, "rancher", req.URL.Query().Get("cluster"), "the query of the URL must be kept")
+		switch req.URL.Query().Get("principal") {
+		case "github_user://1234":
+			if req.URL.Query().Get("user") == "u-new" {
+				rw.WriteHeader(http.StatusOK)
+				return
+			}
+			rw.WriteHeader(http.StatusNotFound)
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	verifier, err := NewHTTPPrincipalVerifier(server.URL+"?cluster=rancher", server.Client())
+	require.NoError(t, err)
+	verified, err := verifier.VerifyPrincipal(context.Background(), "u-new", "github_user://1234")
+	require.NoError(t, err)
+	assert.True(t, verified)
+	verified, err = verifier.VerifyPrincipal(context.Background(), "u-other", "github_user://1234")
+	require.NoError(t, err)
+	assert.False(t, verified)
+	_, err = verifier.VerifyPrincipal(context.Background(), "u-new", "github_user://error")
+	assert.Error(t, err)
+
+	cached := NewCachedPrincipalVerifier(verifier, time.Minute)
+	calls.Store(0)
+	for i := 0; i < 3; i++ {
+		verified, err = cached.VerifyPrincipal(context.Background(), "u-new", "github_user://1234")
+		require.NoError(t, err)
+		assert.True(t, verified)
+		_, err = cached.VerifyPrincipal(context.Background(), "u-new", "github_user://error")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(4), calls.Load(), "results must be cached, but not errors")
+
+	_, err = NewHTTPPrincipalVerifier("ftp://rancher", nil)
+	assert.Error(t, err)
+}
+
+func TestPrincipalVerifierFromEnv(t *testing.T) {
+	t.Setenv(PrincipalVerifierURLEnv, "")
+	verifier, err := PrincipalVerifierFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, verifier)
+
+	t.Setenv(PrincipalVerifierURLEnv, "https://rancher/verify")
+	t.Setenv(PrincipalVerifierCacheTTLEnv, "10s")
+	verifier, err = PrincipalVerifierFromEnv()
+	require.NoError(t, err)
+	require.IsType(t, &cachedPrincipalVerifier{}, verifier)
+	assert.Equal(t, 10*time.Second, verifier.(*cachedPrincipalVerifier).cache.Info().TTL.Duration)
+
+	t.Setenv(PrincipalVerifierCacheTTLEnv, "soon")
+	_, err = PrincipalVerifierFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+// Package metrics holds the prometheus metrics of the admission requests handled by the webhook.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const metricsNamespace = "rancher_webhook"
+
+// Decisions of admission requests.
+const (
+	DecisionAllowed = "allowed"
+	DecisionDenied  = "denied"
+	DecisionError   = "error"
+	// DecisionWouldDeny is the decision of the denials audit mode turned into warnings.
+	DecisionWouldDeny = "would-deny"
+)
+
+var (
+	admissionLabels = []string{"webhook", "group", "version", "resource", "operation"}
+
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "admission_requests_total",
+		Help:      "Number of admission requests handled by the validators and mutators.",
+	}, admissionLabels)
+	decisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "admission_decisions_total",
+		Help:      "Number of admission requests by decision: allowed, denied, would-deny if audit mode allowed a denial, or error if the request couldn't be evaluated.",
+	}, append(admissionLabels, "decision"))
+	denials = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "admission_denials_total",
+		Help:      "Number of denied admission requests by the reason of the denial, e.g. Forbidden or BadRequest.",
+	}, append(admissionLabels, "reason"))
+	latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "admission_duration_seconds",
+		Help:      "Time taken by the validators and mutators to evaluate admission requests.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, admissionLabels)
+)
+
+func init() {
+	prometheus.MustRegister(requests, decisions, denials, latency)
+}
+
+// Admission is the outcome of an admission request.
+type Admission struct {
+	// Webhook is the kind of webhook which handled the request, validating or mutating.
+	Webhook string
+	// Resource is the resource of the request.
+	Resource schema.GroupVersionResource
+	// Operation is the operation of the request, e.g. CREATE.
+	Operation string
+	// Decision is one of DecisionAllowed, DecisionDenied, DecisionWouldDeny or DecisionError.
+	Decision string
+	// Reason is the reason of a denial, e.g. Forbidden. Denials without a reason are counted as Unknown.
+	Reason string
+}
+
+// ObserveAdmission records the outcome of an admission request which took the given duration to evaluate.
+func ObserveAdmission(admission Admission, duration time.Duration) {
+	labels := []string{admission.Webhook, admission.Resource.Group, admission.Resource.Version,
+		admission.Resource.Resource, admission.Operation}
+	requests.WithLabelValues(labels...).Inc()
+	decisions.WithLabelValues(append(labels, admission.Decision)...).Inc()
+	if admission.Decision == DecisionDenied {
+		reason := admission.Reason
+		if reason == "" {
+			reason = "Unknown"
+		}
+		denials.WithLabelValues(append(labels, reason)...).Inc()
+	}
+	latency.WithLabelValues(labels...).Observe(duration.Seconds())
+}
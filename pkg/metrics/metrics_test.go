@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestObserveAdmission(t *testing.T) {
+	t.Parallel()
+	gvr := schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "metricstests"}
+	labels := []string{"validating", gvr.Group, gvr.Version, gvr.Resource, "CREATE"}
+	with := func(value string) []string {
+		return append(append([]string{}, labels...), value)
+	}
+
+	ObserveAdmission(Admission{Webhook: "validating", Resource: gvr, Operation: "CREATE", Decision: DecisionAllowed}, 10*time.Millisecond)
+	ObserveAdmission(Admission{Webhook: "validating", Resource: gvr, Operation: "CREATE", Decision: DecisionDenied, Reason: "Forbidden"}, 20*time.Millisecond)
+	ObserveAdmission(Admission{Webhook: "validating", Resource: gvr, Operation: "CREATE", Decision: DecisionDenied}, 30*time.Millisecond)
+	ObserveAdmission(Admission{Webhook: "validating", Resource: gvr, Operation: "CREATE", Decision: DecisionError}, time.Second)
+
+	assert.Equal(t, float64(4), testutil.ToFloat64(requests.WithLabelValues(labels...)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(decisions.WithLabelValues(with(DecisionAllowed)...)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(decisions.WithLabelValues(with(DecisionDenied)...)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(decisions.WithLabelValues(with(DecisionError)...)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(denials.WithLabelValues(with("Forbidden")...)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(denials.WithLabelValues(with("Unknown")...)), "denials without a reason are counted as Unknown")
+
+	// the latency of each request is observed, errors included
+	assert.Equal(t, 1, testutil.CollectAndCount(latency, "rancher_webhook_admission_duration_seconds"))
+}
@@ -0,0 +1,124 @@
+package offline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/rancher/webhook/pkg/admission"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Review runs the request through the mutating handlers of its resource, applying their patches to the object, and
+// then through the validating handlers, the way the API server calls the webhooks. The returned response combines the responses of all handlers: it is denied
+// by the first denying handler, collects the warnings, and holds the patch of all mutations.
+func Review(ctx context.Context, validators []admission.ValidatingAdmissionHandler, mutators []admission.MutatingAdmissionHandler, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	request = request.DeepCopy()
+	original := request.Object.Raw
+	result := &admissionv1.AdmissionResponse{UID: request.UID, Allowed: true}
+
+	for _, mutator := range mutators {
+		if !handles(mutator, request) {
+			continue
+		}
+		response, err := serveReview(ctx, admission.NewMutatingHandlerFunc(mutator), request)
+		if err != nil {
+			return nil, fmt.Errorf("mutating handler %s failed: %w", admission.SubPath(mutator.GVR()), err)
+		}
+		result.Warnings = append(result.Warnings, response.Warnings...)
+		if !response.Allowed {
+			result.Allowed = false
+			result.Result = response.Result
+			return result, nil
+		}
+		if len(response.Patch) != 0 {
+			if request.Object.Raw, err = applyPatch(request.Object.Raw, response.Patch); err != nil {
+				return nil, fmt.Errorf("failed to apply patch of mutating handler %s: %w", admission.SubPath(mutator.GVR()), err)
+			}
+		}
+	}
+
+	for _, validator := range validators {
+		if !handles(validator, request) {
+			continue
+		}
+		response, err := serveReview(ctx, admission.NewValidatingHandlerFunc(validator), request)
+		if err != nil {
+			return nil, fmt.Errorf("validating handler %s failed: %w", admission.SubPath(validator.GVR()), err)
+		}
+		result.Warnings = append(result.Warnings, response.Warnings...)
+		if !response.Allowed {
+			result.Allowed = false
+			result.Result = response.Result
+			return result, nil
+		}
+	}
+
+	if !bytes.Equal(original, request.Object.Raw) {
+		patch, err := admission.PatchFromObjects(json.RawMessage(original), json.RawMessage(request.Object.Raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create patch: %w", err)
+		}
+		result.Patch = patch
+		result.PatchType = admission.Ptr(admissionv1.PatchTypeJSONPatch)
+	}
+	return result, nil
+}
+
+// handles returns true if the handler reviews requests for the resource and operation of the request.
+func handles(handler admission.WebhookHandler, request *admissionv1.AdmissionRequest) bool {
+	gvr := handler.GVR()
+	if gvr.Group != request.Resource.Group || gvr.Version != request.Resource.Version {
+		return false
+	}
+	if gvr.Resource != "*" && gvr.Resource != request.Resource.Resource {
+		return false
+	}
+	for _, op := range handler.Operations() {
+		if string(op) == string(request.Operation) || op == admissionregistrationv1.OperationAll {
+			return true
+		}
+	}
+	return false
+}
+
+// serveReview sends the request to the handler function as an AdmissionReview and returns the response.
+func serveReview(ctx context.Context, handlerFunc http.HandlerFunc, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	body, err := json.Marshal(&admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Request:  request,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal admission review: %w", err)
+	}
+	recorder := httptest.NewRecorder()
+	handlerFunc(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)).WithContext(ctx))
+
+	review := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil || review.Response == nil {
+		return nil, fmt.Errorf("unexpected response with status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder.Code != http.StatusOK {
+		if review.Response.Result != nil {
+			return nil, errors.New(review.Response.Result.Message)
+		}
+		return nil, fmt.Errorf("unexpected response status %d", recorder.Code)
+	}
+	return review.Response, nil
+}
+
+// applyPatch applies a JSON patch to the JSON document.
+func applyPatch(doc, patch []byte) ([]byte, error) {
+	decoded, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	return decoded.Apply(doc)
+}
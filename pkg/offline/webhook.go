@@ -0,0 +1,120 @@
+// Package offline runs the webhook's handlers in-process without a Kubernetes cluster, so that CLIs and CI linters can
+// validate Rancher manifests with the same checks as the webhook.
+package offline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/clients"
+	"github.com/rancher/webhook/pkg/server"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// Options configure an offline webhook.
+type Options struct {
+	// MultiClusterManagement enables the handlers of the local cluster Rancher runs in, like ENABLE_MCM. Otherwise only
+	// the handlers of downstream clusters are enabled.
+	MultiClusterManagement bool
+	// Handlers are the names of the enabled handlers, e.g. projects.management.cattle.io, see server.FilterHandlers.
+	// All handlers are enabled if it's empty.
+	Handlers []string
+	// Objects are the existing objects the handlers look up, e.g. the cluster of a project or the RBAC rules allowing a
+	// user to make a request.
+	Objects []runtime.Object
+}
+
+// Webhook reviews admission requests with the webhook's handlers against the objects it holds in memory, instead of
+// the caches of a cluster. Requests to the API server are answered as if it held no objects, so handlers creating
+// objects fail the requests they handle. The handlers share global configuration, like the enforcement of the rules,
+// which the last Webhook created configures.
+type Webhook struct {
+	clients    *clients.Clients
+	validators []admission.ValidatingAdmissionHandler
+	mutators   []admission.MutatingAdmissionHandler
+}
+
+// NewOfflineWebhook returns a webhook running the handlers of the options without a cluster.
+func NewOfflineWebhook(opts Options) (*Webhook, error) {
+	offlineClients, err := clients.NewOffline(opts.MultiClusterManagement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the offline clients: %w", err)
+	}
+	validators, mutators, err := server.Handlers(offlineClients, opts.Handlers)
+	if err != nil {
+		return nil, err
+	}
+	w := &Webhook{clients: offlineClients, validators: validators, mutators: mutators}
+	if err := w.AddObjects(opts.Objects...); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// AddObjects adds existing objects the handlers look up. Unstructured objects, e.g. read from manifests, are converted
+// to their type.
+func (w *Webhook) AddObjects(objs ...runtime.Object) error {
+	return w.clients.AddObjects(objs...)
+}
+
+// Review runs the request through the mutating and then the validating handlers of its resource, and returns their
+// combined response, see Review.
+func (w *Webhook) Review(ctx context.Context, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	return Review(ctx, w.validators, w.mutators, request)
+}
+
+// ReviewObject reviews the dry run request of the operation on the object made by the user. The old object is the
+// current object on UPDATE, and the deleted object on DELETE, where obj is nil. The resource of the object is guessed
+// from its kind, like kubectl does for kinds unknown to discovery.
+func (w *Webhook) ReviewObject(ctx context.Context, operation admissionv1.Operation, obj, oldObj *unstructured.Unstructured, user authenticationv1.UserInfo) (*admissionv1.AdmissionResponse, error) {
+	request, err := NewRequest(operation, obj, oldObj, user)
+	if err != nil {
+		return nil, err
+	}
+	return w.Review(ctx, request)
+}
+
+// NewRequest returns the dry run request of the operation on the object made by the user, see Webhook.ReviewObject.
+func NewRequest(operation admissionv1.Operation, obj, oldObj *unstructured.Unstructured, user authenticationv1.UserInfo) (*admissionv1.AdmissionRequest, error) {
+	subject := obj
+	if subject == nil {
+		subject = oldObj
+	}
+	if subject == nil {
+		return nil, fmt.Errorf("%s request without an object", operation)
+	}
+	gvk := subject.GroupVersionKind()
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+	request := &admissionv1.AdmissionRequest{
+		UID:       uuid.NewUUID(),
+		Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		Resource:  metav1.GroupVersionResource{Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource},
+		Name:      subject.GetName(),
+		Namespace: subject.GetNamespace(),
+		Operation: operation,
+		UserInfo:  user,
+		DryRun:    admission.Ptr(true),
+	}
+	for _, raw := range []struct {
+		obj *unstructured.Unstructured
+		ext *runtime.RawExtension
+	}{{obj, &request.Object}, {oldObj, &request.OldObject}} {
+		if raw.obj == nil {
+			continue
+		}
+		data, err := json.Marshal(raw.obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", raw.obj.GetName(), err)
+		}
+		raw.ext.Raw = data
+	}
+	return request, nil
+}
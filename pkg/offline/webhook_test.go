@@ -0,0 +1,100 @@
+package offline_test
+
+import (
+	"context"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/fixtures"
+	"github.com/rancher/webhook/pkg/offline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fixtureObjects are the objects the fixtures refer to, with the RBAC rules allowing fixtures.Username everything.
+func fixtureObjects() []runtime.Object {
+	return []runtime.Object{
+		&v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: fixtures.ClusterName}},
+		&v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: "p-fixture", Namespace: fixtures.ClusterName},
+			Spec:       v3.ProjectSpec{ClusterName: fixtures.ClusterName},
+		},
+		&v3.RoleTemplate{ObjectMeta: metav1.ObjectMeta{Name: "cluster-member"}, Context: "cluster"},
+		&v3.RoleTemplate{ObjectMeta: metav1.ObjectMeta{Name: "project-member"}, Context: "project"},
+		&v3.GlobalRole{ObjectMeta: metav1.ObjectMeta{Name: "user-base"}},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+			Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+				{Verbs: []string{"*"}, NonResourceURLs: []string{"*"}},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "fixtures"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: fixtures.Username}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "cluster-admin"},
+		},
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	local, err := offline.NewOfflineWebhook(offline.Options{MultiClusterManagement: true, Objects: fixtureObjects()})
+	require.NoError(t, err)
+	downstream, err := offline.NewOfflineWebhook(offline.Options{})
+	require.NoError(t, err)
+	for _, fixture := range fixtures.All() {
+		fixture := fixture
+		t.Run(fixture.Resource.Resource+"/"+fixture.Name, func(t *testing.T) {
+			webhook := local
+			if fixture.Resource.Group == "cluster.cattle.io" {
+				// cluster auth tokens are only validated on downstream clusters
+				webhook = downstream
+			}
+			request, err := fixture.Request()
+			require.NoError(t, err)
+			response, err := webhook.Review(context.Background(), request)
+			require.NoError(t, err)
+			assert.Equal(t, fixture.Allowed, response.Allowed, "%v", response.Result)
+			if !fixture.Allowed {
+				require.NotNil(t, response.Result)
+				assert.Contains(t, response.Result.Message, fixture.Denial)
+			}
+		})
+	}
+}
+
+func TestReviewObject(t *testing.T) {
+	webhook, err := offline.NewOfflineWebhook(offline.Options{
+		MultiClusterManagement: true,
+		Handlers:               []string{"projects.management.cattle.io"},
+	})
+	require.NoError(t, err)
+	project := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "management.cattle.io/v3",
+		"kind":       "Project",
+		"metadata":   map[string]any{"name": "p-abc", "namespace": "c-abc"},
+		"spec":       map[string]any{"clusterName": "c-abc", "displayName": "abc"},
+	}}
+	user := authenticationv1.UserInfo{Username: "u-abc"}
+
+	// the cluster of the project doesn't exist yet
+	response, err := webhook.ReviewObject(context.Background(), admissionv1.Create, project, nil, user)
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+
+	cluster := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "management.cattle.io/v3",
+		"kind":       "Cluster",
+		"metadata":   map[string]any{"name": "c-abc"},
+	}}
+	require.NoError(t, webhook.AddObjects(cluster))
+	response, err = webhook.ReviewObject(context.Background(), admissionv1.Create, project, nil, user)
+	require.NoError(t, err)
+	assert.True(t, response.Allowed, "%v", response.Result)
+}
@@ -13,12 +13,14 @@ import (
 	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
 	psav1 "k8s.io/pod-security-admission/admission/api/v1"
 	psav1beta1 "k8s.io/pod-security-admission/admission/api/v1beta1"
+	psaapi "k8s.io/pod-security-admission/api"
 	"sigs.k8s.io/yaml"
 )
 
 var (
 	parsedRangeAtLeast125 = semver.MustParseRange(">= 1.25.0-rancher0")
 	parsedRange123to124   = semver.MustParseRange(">=1.23.0-rancher0 <=1.24.99-rancher-0")
+	parsedRangeAtLeast123 = semver.MustParseRange(">= 1.23.0-rancher0")
 )
 
 // GetAdmissionConfigFromCluster generates an AdmissionConfiguration from a Cluster,
@@ -147,3 +149,35 @@ func GenerateAdmissionConfigFile(configurationTemplate *apisv3.PodSecurityAdmiss
 	}
 	return parsed, err
 }
+
+// CheckTemplateCompatibility returns an error if the template can't be used by a cluster running the given k8s version.
+// PodSecurity admission requires k8s 1.23 or above, and the policy versions pinned in the defaults of the template
+// can't be newer than the k8s version of the cluster, since the cluster doesn't know these policies.
+func CheckTemplateCompatibility(template *apisv3.PodSecurityAdmissionConfigurationTemplate, k8sVersion string) error {
+	parsedVersion, err := GetClusterVersion(k8sVersion)
+	if err != nil {
+		return err
+	}
+	if !parsedRangeAtLeast123(parsedVersion) {
+		return fmt.Errorf("PodSecurityAdmissionConfigurationTemplate(PSACT) is only supported in k8s version 1.23 and above, got %s", k8sVersion)
+	}
+	clusterVersion := psaapi.MajorMinorVersion(int(parsedVersion.Major), int(parsedVersion.Minor))
+	defaults := template.Configuration.Defaults
+	for _, policy := range []struct{ mode, version string }{
+		{"enforce-version", defaults.EnforceVersion},
+		{"audit-version", defaults.AuditVersion},
+		{"warn-version", defaults.WarnVersion},
+	} {
+		if policy.version == "" {
+			continue
+		}
+		policyVersion, err := psaapi.ParseVersion(policy.version)
+		if err != nil {
+			return fmt.Errorf("%s %q of PodSecurityAdmissionConfigurationTemplate(PSACT) %s is invalid: %w", policy.mode, policy.version, template.Name, err)
+		}
+		if !policyVersion.Latest() && clusterVersion.Older(policyVersion) {
+			return fmt.Errorf("%s %s of PodSecurityAdmissionConfigurationTemplate(PSACT) %s is newer than the k8s version %s of the cluster", policy.mode, policy.version, template.Name, k8sVersion)
+		}
+	}
+	return nil
+}
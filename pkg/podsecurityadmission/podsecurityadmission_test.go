@@ -420,3 +420,72 @@ plugins:
 		})
 	}
 }
+
+func TestCheckTemplateCompatibility(t *testing.T) {
+	pinned := getPsactRestricted()
+	pinned.Configuration.Defaults.EnforceVersion = "v1.25"
+	pinned.Configuration.Defaults.WarnVersion = "v1.24"
+	invalid := getPsactRestricted()
+	invalid.Configuration.Defaults.AuditVersion = "1.25"
+
+	tests := []struct {
+		testName   string
+		psact      *v3.PodSecurityAdmissionConfigurationTemplate
+		k8sVersion string
+		expectErr  string
+	}{
+		{
+			testName:   "latest on k8s 1.23",
+			psact:      getPsactRestricted(),
+			k8sVersion: "v1.23.5+k3s1",
+		},
+		{
+			testName:   "latest on k8s 1.22",
+			psact:      getPsactRestricted(),
+			k8sVersion: "v1.22.17+rke2r1",
+			expectErr:  "only supported in k8s version 1.23 and above",
+		},
+		{
+			testName:   "invalid k8s version",
+			psact:      getPsactRestricted(),
+			k8sVersion: "1.25.5",
+			expectErr:  "is not valid k8s version",
+		},
+		{
+			testName:   "pinned versions on the same k8s version",
+			psact:      pinned,
+			k8sVersion: "v1.25.5+rke2r2",
+		},
+		{
+			testName:   "pinned versions on a newer k8s version",
+			psact:      pinned,
+			k8sVersion: "v1.30.2+k3s1",
+		},
+		{
+			testName:   "pinned version newer than the k8s version",
+			psact:      pinned,
+			k8sVersion: "v1.24.9+k3s1",
+			expectErr:  "enforce-version v1.25",
+		},
+		{
+			testName:   "invalid pinned version",
+			psact:      invalid,
+			k8sVersion: "v1.25.5+rke2r2",
+			expectErr:  `audit-version "1.25"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			err := CheckTemplateCompatibility(tt.psact, tt.k8sVersion)
+			if tt.expectErr == "" {
+				if err != nil {
+					t.Errorf("failed in the test case: [%v]; unexpected error: [%v]", tt.testName, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("failed in the test case: [%v]; get: [%v], expected an error containing: [%v]", tt.testName, err, tt.expectErr)
+			}
+		})
+	}
+}
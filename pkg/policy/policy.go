@@ -0,0 +1,221 @@
+// Package policy evaluates the CEL expressions of the webhook.cattle.io custom policies on admission requests.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/cache"
+	webhookcontrollers "github.com/rancher/webhook/pkg/generated/controllers/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// costLimit bounds the cost of evaluating an expression, so that a costly policy can't stall admission.
+const costLimit = 1_000_000
+
+var customPolicyRule = rules.Register(rules.Rule{
+	ID:            "custom-policy",
+	GVR:           schema.GroupVersionResource{Group: "*", Version: "*", Resource: "*"},
+	Description:   "Requests must satisfy the CEL expressions of the webhook.cattle.io custom policies of their resource and operation, which admins define to add their own checks, e.g. requiring a cost-center annotation on all clusters. Policies are evaluated after the built-in checks, and a policy whose expression fails to evaluate denies the request.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "custom policy cost-center: clusters must have a cost-center annotation",
+	DenialCode:    string(admission.DenialForbidden),
+})
+
+// env declares the variables of the expressions: the object and old object of the request, and the request itself.
+var env = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("oldObject", cel.DynType),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+	)
+})
+
+// compiled is the result of compiling an expression.
+type compiled struct {
+	program cel.Program
+	err     error
+}
+
+// programsCacheSize is the number of expressions whose compiled programs are cached.
+const programsCacheSize = 1000
+
+// programs caches the compiled programs by expression.
+var programs = cache.New[string, compiled]("custom-policy-programs", programsCacheSize, 0)
+
+// Compile compiles the expression of a policy, which must evaluate to a bool.
+func Compile(expression string) (cel.Program, error) {
+	if cached, ok := programs.Get(expression); ok {
+		return cached.program, cached.err
+	}
+	program, err := compile(expression)
+	programs.Add(expression, compiled{program: program, err: err})
+	return program, err
+}
+
+func compile(expression string) (cel.Program, error) {
+	celEnv, err := env()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the CEL environment: %w", err)
+	}
+	ast, issues := celEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	// fields of the object are dynamic, so expressions such as object.spec.enabled are only checked when evaluated
+	if output := ast.OutputType(); output != cel.BoolType && output != cel.DynType {
+		return nil, fmt.Errorf("expression must evaluate to a bool, not %s", output)
+	}
+	return celEnv.Program(ast, cel.CostLimit(costLimit), cel.InterruptCheckFrequency(100))
+}
+
+// WithCustomPolicies returns the validators, which also deny the requests the custom policies in the cache don't
+// allow, after their own admitters.
+func WithCustomPolicies(validators []admission.ValidatingAdmissionHandler, policies webhookcontrollers.CustomPolicyCache) []admission.ValidatingAdmissionHandler {
+	wrapped := make([]admission.ValidatingAdmissionHandler, 0, len(validators))
+	for _, validator := range validators {
+		wrapped = append(wrapped, &customPolicyValidator{ValidatingAdmissionHandler: validator, admitter: NewAdmitter(policies)})
+	}
+	return wrapped
+}
+
+// customPolicyValidator adds the custom policy Admitter to the admitters of a validator.
+type customPolicyValidator struct {
+	admission.ValidatingAdmissionHandler
+	admitter *Admitter
+}
+
+// Admitters returns the admitters of the validator followed by the custom policy Admitter.
+func (c *customPolicyValidator) Admitters() []admission.Admitter {
+	return append(slices.Clip(c.ValidatingAdmissionHandler.Admitters()), c.admitter)
+}
+
+// Unwrap returns the decorated validator.
+func (c *customPolicyValidator) Unwrap() admission.WebhookHandler {
+	return c.ValidatingAdmissionHandler
+}
+
+// Reset resets the shared resources of the validator, if it has any.
+func (c *customPolicyValidator) Reset() {
+	if resetter, ok := c.ValidatingAdmissionHandler.(admission.Resetter); ok {
+		resetter.Reset()
+	}
+}
+
+// Admitter denies the requests for which the expression of a custom policy of their resource and operation is false.
+type Admitter struct {
+	policies webhookcontrollers.CustomPolicyCache
+}
+
+// NewAdmitter returns an Admitter evaluating the custom policies in the cache.
+func NewAdmitter(policies webhookcontrollers.CustomPolicyCache) *Admitter {
+	return &Admitter{policies: policies}
+}
+
+// Admit evaluates the custom policies applying to the request.
+func (a *Admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	if a.policies == nil {
+		return admission.ResponseAllowed(), nil
+	}
+	policies, err := a.policies.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list custom policies: %w", err)
+	}
+	resource := schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String()
+	operation := string(request.Operation)
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+
+	var activation map[string]any
+	var failures []string
+	for _, policy := range policies {
+		if !policy.Spec.AppliesTo(resource, operation) {
+			continue
+		}
+		if activation == nil {
+			if activation, err = newActivation(request); err != nil {
+				return nil, err
+			}
+		}
+		if failure := evaluate(request, policy, activation); failure != "" {
+			failures = append(failures, failure)
+		}
+	}
+	if len(failures) == 0 {
+		return admission.ResponseAllowed(), nil
+	}
+	message := customPolicyRule.Message("%s", strings.Join(failures, "; "))
+	return admission.ResponseForRule(customPolicyRule, nil, message), nil
+}
+
+// evaluate returns why the request fails the policy, empty if it passes.
+func evaluate(request *admission.Request, policy *webhookv1.CustomPolicy, activation map[string]any) string {
+	program, err := Compile(policy.Spec.Expression)
+	if err != nil {
+		return fmt.Sprintf("custom policy %s has an invalid expression: %v", policy.Name, err)
+	}
+	ctx := request.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	out, _, err := program.ContextEval(ctx, activation)
+	if err != nil {
+		return fmt.Sprintf("custom policy %s failed to evaluate: %v", policy.Name, err)
+	}
+	passed, ok := out.Value().(bool)
+	if !ok {
+		return fmt.Sprintf("custom policy %s evaluated to %v instead of a bool", policy.Name, out.Value())
+	}
+	if passed {
+		return ""
+	}
+	return fmt.Sprintf("custom policy %s: %s", policy.Name, policy.Spec.Message)
+}
+
+// newActivation returns the variables of the expressions for the request.
+func newActivation(request *admission.Request) (map[string]any, error) {
+	object, err := decodeObject(request.Object.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+	oldObject, err := decodeObject(request.OldObject.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode old object: %w", err)
+	}
+	return map[string]any{
+		"object":    object,
+		"oldObject": oldObject,
+		"request": map[string]any{
+			"operation": string(request.Operation),
+			"namespace": request.Namespace,
+			"name":      request.Name,
+			"userInfo": map[string]any{
+				"username": request.UserInfo.Username,
+				"uid":      request.UserInfo.UID,
+				"groups":   request.UserInfo.Groups,
+			},
+		},
+	}, nil
+}
+
+// decodeObject returns the object as a map, nil if there is none.
+func decodeObject(raw []byte) (any, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var object map[string]any
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
@@ -0,0 +1,204 @@
+package policy_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/policy"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const costCenterAnnotation = "example.com/cost-center"
+
+var costCenterPolicy = &webhookv1.CustomPolicy{
+	ObjectMeta: metav1.ObjectMeta{Name: "cost-center"},
+	Spec: webhookv1.CustomPolicySpec{
+		Resources:  []string{"clusters.management.cattle.io"},
+		Expression: "has(object.metadata.annotations) && '" + costCenterAnnotation + "' in object.metadata.annotations",
+		Message:    "clusters must have a cost-center annotation",
+	},
+}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		policies    []*webhookv1.CustomPolicy
+		operation   admissionv1.Operation
+		resource    metav1.GroupVersionResource
+		annotations map[string]string
+		username    string
+		wantMessage string
+	}{
+		{
+			name:        "passes the policy",
+			policies:    []*webhookv1.CustomPolicy{costCenterPolicy},
+			operation:   admissionv1.Create,
+			annotations: map[string]string{costCenterAnnotation: "cc-42"},
+		},
+		{
+			name:        "fails the policy",
+			policies:    []*webhookv1.CustomPolicy{costCenterPolicy},
+			operation:   admissionv1.Create,
+			wantMessage: "custom policy cost-center: clusters must have a cost-center annotation (see /rules/custom-policy)",
+		},
+		{
+			name:      "no policies",
+			operation: admissionv1.Create,
+		},
+		{
+			name:     "policy of another resource",
+			policies: []*webhookv1.CustomPolicy{costCenterPolicy},
+			resource: metav1.GroupVersionResource{Group: "provisioning.cattle.io", Version: "v1", Resource: "clusters"},
+		},
+		{
+			name:      "deletes aren't checked by default",
+			policies:  []*webhookv1.CustomPolicy{costCenterPolicy},
+			operation: admissionv1.Delete,
+		},
+		{
+			name: "policy of the operation",
+			policies: []*webhookv1.CustomPolicy{{
+				ObjectMeta: metav1.ObjectMeta{Name: "local-cluster-deletes"},
+				Spec: webhookv1.CustomPolicySpec{
+					Resources:  []string{"clusters.management.cattle.io"},
+					Operations: []string{"DELETE"},
+					Expression: "oldObject.metadata.name != 'local' || request.userInfo.username == 'admin'",
+					Message:    "only admin can delete the local cluster",
+				},
+			}},
+			operation:   admissionv1.Delete,
+			username:    "u-abc12",
+			wantMessage: "custom policy local-cluster-deletes: only admin can delete the local cluster",
+		},
+		{
+			name: "failures of all policies are reported",
+			policies: []*webhookv1.CustomPolicy{{
+				ObjectMeta: metav1.ObjectMeta{Name: "z-display-name"},
+				Spec: webhookv1.CustomPolicySpec{
+					Resources:  []string{"clusters.management.cattle.io"},
+					Expression: "object.spec.displayName.startsWith('team-')",
+					Message:    "display names start with team-",
+				},
+			}, costCenterPolicy},
+			operation:   admissionv1.Update,
+			wantMessage: "custom policy cost-center: clusters must have a cost-center annotation; custom policy z-display-name: display names start with team-",
+		},
+		{
+			name: "policy failing to evaluate denies",
+			policies: []*webhookv1.CustomPolicy{{
+				ObjectMeta: metav1.ObjectMeta{Name: "cost-center-value"},
+				Spec: webhookv1.CustomPolicySpec{
+					Resources:  []string{"clusters.management.cattle.io"},
+					Expression: "object.metadata.annotations['" + costCenterAnnotation + "'].startsWith('cc-')",
+					Message:    "cost centers start with cc-",
+				},
+			}},
+			operation:   admissionv1.Create,
+			wantMessage: "custom policy cost-center-value failed to evaluate",
+		},
+		{
+			name: "policy evaluating to another type denies",
+			policies: []*webhookv1.CustomPolicy{{
+				ObjectMeta: metav1.ObjectMeta{Name: "display-name"},
+				Spec: webhookv1.CustomPolicySpec{
+					Resources:  []string{"clusters.management.cattle.io"},
+					Expression: "object.spec.displayName",
+					Message:    "unused",
+				},
+			}},
+			operation:   admissionv1.Create,
+			wantMessage: "custom policy display-name evaluated to local instead of a bool",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			cache := fake.NewMockNonNamespacedCacheInterface[*webhookv1.CustomPolicy](ctrl)
+			cache.EXPECT().List(gomock.Any()).Return(test.policies, nil).AnyTimes()
+
+			cluster := &v3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "local", Annotations: test.annotations},
+				Spec:       v3.ClusterSpec{DisplayName: "local"},
+			}
+			raw, err := json.Marshal(cluster)
+			require.NoError(t, err)
+			resource := test.resource
+			if resource.Resource == "" {
+				resource = metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "clusters"}
+			}
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: test.operation,
+				Resource:  resource,
+				Name:      cluster.Name,
+				UserInfo:  authenticationv1.UserInfo{Username: test.username},
+			}}
+			if test.operation != admissionv1.Delete {
+				request.Object = runtime.RawExtension{Raw: raw}
+			}
+			if test.operation != admissionv1.Create {
+				request.OldObject = runtime.RawExtension{Raw: raw}
+			}
+
+			response, err := policy.NewAdmitter(cache).Admit(request)
+			require.NoError(t, err)
+			if test.wantMessage == "" {
+				assert.True(t, response.Allowed)
+				return
+			}
+			require.False(t, response.Allowed)
+			assert.Equal(t, int32(403), response.Result.Code)
+			assert.Contains(t, response.Result.Message, test.wantMessage)
+		})
+	}
+}
+
+type fakeValidator struct {
+	admitters []admission.Admitter
+}
+
+func (f *fakeValidator) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "clusters"}
+}
+
+func (f *fakeValidator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+}
+
+func (f *fakeValidator) ValidatingWebhook(_ admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return nil
+}
+
+func (f *fakeValidator) Admitters() []admission.Admitter {
+	return f.admitters
+}
+
+func TestWithCustomPolicies(t *testing.T) {
+	t.Parallel()
+	builtIn := make([]admission.Admitter, 1, 4)
+	builtIn[0] = policy.NewAdmitter(nil)
+	validators := policy.WithCustomPolicies([]admission.ValidatingAdmissionHandler{&fakeValidator{admitters: builtIn}}, nil)
+	require.Len(t, validators, 1)
+	assert.Equal(t, schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "clusters"}, validators[0].GVR())
+
+	// the custom policies are evaluated after the built-in admitters, without writing to their slice
+	admitters := validators[0].Admitters()
+	require.Len(t, admitters, 2)
+	assert.Same(t, builtIn[0], admitters[0])
+	assert.IsType(t, &policy.Admitter{}, admitters[1])
+	assert.Nil(t, builtIn[:2][1])
+}
@@ -0,0 +1,156 @@
+// Package quota holds the resource quota math shared by the validators of projects and namespaces.
+package quota
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	mgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+)
+
+// MaxNamespacesAnnotation is the annotation of projects holding the largest number of namespaces they may own, e.g.
+// "10". The namespaces of a project aren't limited if it's empty or 0.
+const MaxNamespacesAnnotation = "management.cattle.io/max-namespaces"
+
+// Fits checks whether the quota in the second argument is sufficient for the requested quota in the first argument.
+// If it is not sufficient, a list of the resources that exceed the allotment is returned.
+// The ResourceList to be checked can be compiled by passing a
+// ResourceQuotaLimit to LimitToResourceList before calling this
+// function on the result.
+func Fits(resourceListA corev1.ResourceList, resourceListB corev1.ResourceList) (bool, corev1.ResourceList) {
+	_, exceeded := quotav1.LessThanOrEqual(resourceListA, resourceListB)
+	// Include resources with negative values among exceeded resources.
+	exceeded = append(exceeded, quotav1.IsNegative(resourceListA)...)
+	if len(exceeded) == 0 {
+		return true, nil
+	}
+	failedHard := quotav1.Mask(resourceListA, exceeded)
+	return false, failedHard
+}
+
+// LimitToResourceList converts a management.cattle.io/v3 ResourceQuotaLimit object to a core/v1 ResourceList,
+// which can then be used to compare quotas.
+func LimitToResourceList(limit *mgmtv3.ResourceQuotaLimit) (corev1.ResourceList, error) {
+	toReturn := corev1.ResourceList{}
+	converted, err := convert.EncodeToMap(limit)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range converted {
+		q, err := resource.ParseQuantity(convert.ToString(value))
+		if err != nil {
+			return nil, err
+		}
+		toReturn[corev1.ResourceName(key)] = q
+	}
+	return toReturn, nil
+}
+
+// LimitResourceNames returns the names of the resources of a ResourceQuotaLimit, as used by LimitToResourceList, e.g.
+// limitsCpu or servicesLoadBalancers.
+func LimitResourceNames() []string {
+	limitType := reflect.TypeOf(mgmtv3.ResourceQuotaLimit{})
+	names := make([]string, 0, limitType.NumField())
+	for i := 0; i < limitType.NumField(); i++ {
+		name, _, _ := strings.Cut(limitType.Field(i).Tag.Get("json"), ",")
+		names = append(names, name)
+	}
+	return names
+}
+
+// limitResourceNames maps the names Kubernetes resource quotas use for the resources a ResourceQuotaLimit can hold to
+// the names of the ResourceQuotaLimit fields.
+var limitResourceNames = map[corev1.ResourceName]corev1.ResourceName{
+	corev1.ResourcePods:                   "pods",
+	corev1.ResourceServices:               "services",
+	corev1.ResourceReplicationControllers: "replicationControllers",
+	corev1.ResourceSecrets:                "secrets",
+	corev1.ResourceConfigMaps:             "configMaps",
+	corev1.ResourcePersistentVolumeClaims: "persistentVolumeClaims",
+	corev1.ResourceServicesNodePorts:      "servicesNodePorts",
+	corev1.ResourceServicesLoadBalancers:  "servicesLoadBalancers",
+	corev1.ResourceRequestsCPU:            "requestsCpu",
+	corev1.ResourceRequestsMemory:         "requestsMemory",
+	corev1.ResourceRequestsStorage:        "requestsStorage",
+	corev1.ResourceLimitsCPU:              "limitsCpu",
+	corev1.ResourceLimitsMemory:           "limitsMemory",
+}
+
+// FromCoreResourceList converts a ResourceList using the names of Kubernetes resource quotas, e.g. the usage in the
+// status of a ResourceQuota, to the names used by LimitToResourceList, e.g. services.loadbalancers to
+// servicesLoadBalancers. Resources a ResourceQuotaLimit can't hold are dropped.
+func FromCoreResourceList(list corev1.ResourceList) corev1.ResourceList {
+	converted := corev1.ResourceList{}
+	for name, quantity := range list {
+		if limitName, ok := limitResourceNames[name]; ok {
+			converted[limitName] = quantity
+		}
+	}
+	return converted
+}
+
+// ParseMaxNamespaces parses the value of the MaxNamespacesAnnotation annotation, returning 0 if it's empty.
+func ParseMaxNamespaces(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("must be a non-negative number of namespaces")
+	}
+	return limit, nil
+}
+
+// RecommendedPair recommends setting the Recommended resource of a quota whenever its Resource is set, e.g. memory
+// requests along with CPU requests, since a quota leaving one of them unlimited strands the workloads that only set the
+// other, or don't set it at all.
+type RecommendedPair struct {
+	Resource    string
+	Recommended string
+}
+
+// DefaultRecommendedPairs pair CPU and memory, and limits with the requests of the same resource.
+var DefaultRecommendedPairs = []RecommendedPair{
+	{Resource: "requestsCpu", Recommended: "requestsMemory"},
+	{Resource: "requestsMemory", Recommended: "requestsCpu"},
+	{Resource: "limitsCpu", Recommended: "limitsMemory"},
+	{Resource: "limitsMemory", Recommended: "limitsCpu"},
+	{Resource: "limitsCpu", Recommended: "requestsCpu"},
+	{Resource: "limitsMemory", Recommended: "requestsMemory"},
+}
+
+// ParseRecommendedPairs parses a comma separated list of resource:recommended pairs of the names of LimitResourceNames,
+// e.g. "requestsCpu:requestsMemory,limitsCpu:requestsCpu". The default pairs are returned if it's empty.
+func ParseRecommendedPairs(value string) ([]RecommendedPair, error) {
+	if strings.TrimSpace(value) == "" {
+		return DefaultRecommendedPairs, nil
+	}
+	names := LimitResourceNames()
+	var pairs []RecommendedPair
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		resource, recommended, ok := strings.Cut(entry, ":")
+		resource, recommended = strings.TrimSpace(resource), strings.TrimSpace(recommended)
+		if !ok || resource == recommended {
+			return nil, fmt.Errorf("pair %q must be two different resources separated by a colon", entry)
+		}
+		for _, name := range []string{resource, recommended} {
+			if !slices.Contains(names, name) {
+				return nil, fmt.Errorf("resource %q of pair %q must be one of %s", name, entry, strings.Join(names, ", "))
+			}
+		}
+		pairs = append(pairs, RecommendedPair{Resource: resource, Recommended: recommended})
+	}
+	return pairs, nil
+}
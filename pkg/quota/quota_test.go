@@ -0,0 +1,295 @@
+package quota
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+
+	mgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+)
+
+func TestFits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		requested    corev1.ResourceList
+		quota        corev1.ResourceList
+		wantFits     bool
+		wantExceeded corev1.ResourceList
+	}{
+		{
+			name:      "empty lists fit",
+			requested: corev1.ResourceList{},
+			quota:     corev1.ResourceList{},
+			wantFits:  true,
+		},
+		{
+			name:      "equal quantities in different formats fit",
+			requested: corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("500m"), corev1.ResourceLimitsMemory: resource.MustParse("1024Mi")},
+			quota:     corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("0.5"), corev1.ResourceLimitsMemory: resource.MustParse("1Gi")},
+			wantFits:  true,
+		},
+		{
+			name:         "binary and decimal suffixes are compared by value",
+			requested:    corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("1Gi")},
+			quota:        corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("1G")},
+			wantFits:     false,
+			wantExceeded: corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("1Gi")},
+		},
+		{
+			name:      "resources missing from the quota are not limited",
+			requested: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1e18")},
+			quota:     corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("1")},
+			wantFits:  true,
+		},
+		{
+			name:         "negative requests never fit",
+			requested:    corev1.ResourceList{corev1.ResourcePods: resource.MustParse("-1")},
+			quota:        corev1.ResourceList{},
+			wantFits:     false,
+			wantExceeded: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("-1")},
+		},
+		{
+			name:      "very large quantities are compared exactly",
+			requested: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("9223372036854775807")},
+			quota:     corev1.ResourceList{corev1.ResourcePods: resource.MustParse("9223372036854775808")},
+			wantFits:  true,
+		},
+		{
+			name:         "very small quantities are compared exactly",
+			requested:    corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("2n")},
+			quota:        corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("1n")},
+			wantFits:     false,
+			wantExceeded: corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("2n")},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fits, exceeded := Fits(test.requested, test.quota)
+			assert.Equal(t, test.wantFits, fits)
+			assert.True(t, quotav1.Equals(test.wantExceeded, exceeded), "expected exceeded %v, got %v", test.wantExceeded, exceeded)
+		})
+	}
+}
+
+// TestFitsProperties checks properties of Fits that must hold for any resource lists, using randomly
+// generated lists with a fixed seed so that failures are reproducible.
+func TestFitsProperties(t *testing.T) {
+	t.Parallel()
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 1000; i++ {
+		a := randomResourceList(rnd)
+		b := randomResourceList(rnd)
+		c := randomResourceList(rnd)
+		msg := fmt.Sprintf("a=%v b=%v c=%v", a, b, c)
+
+		fitsAB, exceededAB := Fits(a, b)
+		require.Equal(t, fitsAB, len(exceededAB) == 0, msg)
+		for name, quantity := range exceededAB {
+			requested, ok := a[name]
+			require.True(t, ok, "exceeded resource %s is not requested: %s", name, msg)
+			require.Zero(t, requested.Cmp(quantity), "exceeded resource %s doesn't report the requested quantity: %s", name, msg)
+		}
+
+		// a list fits in itself unless it has negative values
+		fitsAA, _ := Fits(a, a)
+		require.Equal(t, len(quotav1.IsNegative(a)) == 0, fitsAA, msg)
+
+		// negative values never fit
+		if len(quotav1.IsNegative(a)) > 0 {
+			require.False(t, fitsAB, msg)
+		}
+
+		// if a fits in b and b fits in a, they hold the same quantities for the resources they share
+		fitsBA, _ := Fits(b, a)
+		if fitsAB && fitsBA {
+			for name := range a {
+				if quantity, ok := b[name]; ok {
+					require.Zero(t, quantity.Cmp(a[name]), msg)
+				}
+			}
+		}
+
+		// raising the quota keeps a fitting request fitting
+		if fitsAB {
+			fits, _ := Fits(a, quotav1.Add(b, quotav1.Mask(quotav1.RemoveZeros(abs(c)), quotav1.ResourceNames(b))))
+			require.True(t, fits, msg)
+		}
+
+		// fitting is transitive for resources limited by both quotas
+		if fitsAB {
+			sharedC := quotav1.Mask(c, quotav1.ResourceNames(b))
+			if fitsBC, _ := Fits(b, sharedC); fitsBC {
+				fitsAC, _ := Fits(a, sharedC)
+				require.True(t, fitsAC, msg)
+			}
+		}
+	}
+}
+
+// quantityFormats are the formats random quantities are generated in, covering suffixes and exponents.
+var quantityFormats = []string{"%d", "%dm", "%dn", "%dk", "%dKi", "%dMi", "%dGi", "%dE", "%dEi", "%de3", "%de-3"}
+
+// quotaResources are the resources random resource lists are generated with.
+var quotaResources = []corev1.ResourceName{
+	corev1.ResourcePods,
+	corev1.ResourceLimitsCPU,
+	corev1.ResourceLimitsMemory,
+	corev1.ResourceRequestsStorage,
+}
+
+// randomResourceList returns a resource list with a random subset of quotaResources set to random quantities.
+func randomResourceList(rnd *rand.Rand) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	for _, name := range quotaResources {
+		if rnd.IntN(4) == 0 {
+			continue
+		}
+		value := rnd.Int64N(2048)
+		if rnd.IntN(10) == 0 {
+			value = -value
+		}
+		list[name] = resource.MustParse(fmt.Sprintf(quantityFormats[rnd.IntN(len(quantityFormats))], value))
+	}
+	return list
+}
+
+// abs returns a copy of the resource list with all quantities made non-negative.
+func abs(list corev1.ResourceList) corev1.ResourceList {
+	result := corev1.ResourceList{}
+	for name, quantity := range list {
+		if quantity.Sign() < 0 {
+			quantity.Neg()
+		}
+		result[name] = quantity
+	}
+	return result
+}
+
+func TestLimitResourceNames(t *testing.T) {
+	t.Parallel()
+	names := LimitResourceNames()
+	assert.Contains(t, names, "limitsCpu")
+	assert.Contains(t, names, "servicesLoadBalancers")
+
+	// every resource of a limit converted by LimitToResourceList must be named
+	limit := mgmtv3.ResourceQuotaLimit{}
+	value := reflect.ValueOf(&limit).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		value.Field(i).SetString("1")
+	}
+	resources, err := LimitToResourceList(&limit)
+	require.NoError(t, err)
+	assert.Len(t, names, len(resources))
+	for name := range resources {
+		assert.Contains(t, names, string(name))
+	}
+}
+
+func TestFromCoreResourceList(t *testing.T) {
+	t.Parallel()
+	converted := FromCoreResourceList(corev1.ResourceList{
+		corev1.ResourceServicesLoadBalancers:          resource.MustParse("2"),
+		corev1.ResourceLimitsCPU:                      resource.MustParse("500m"),
+		corev1.ResourceName("count/deployments.apps"): resource.MustParse("3"),
+	})
+	assert.Equal(t, corev1.ResourceList{
+		"servicesLoadBalancers": resource.MustParse("2"),
+		"limitsCpu":             resource.MustParse("500m"),
+	}, converted)
+
+	// every resource of a limit must be converted from its Kubernetes name
+	names := LimitResourceNames()
+	assert.Len(t, limitResourceNames, len(names))
+	for _, name := range limitResourceNames {
+		assert.Contains(t, names, string(name))
+	}
+}
+
+func TestParseMaxNamespaces(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value     string
+		wantLimit int
+		wantErr   bool
+	}{
+		{value: "", wantLimit: 0},
+		{value: " 10 ", wantLimit: 10},
+		{value: "0", wantLimit: 0},
+		{value: "-1", wantErr: true},
+		{value: "ten", wantErr: true},
+		{value: "1.5", wantErr: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.value, func(t *testing.T) {
+			t.Parallel()
+			limit, err := ParseMaxNamespaces(test.value)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantLimit, limit)
+		})
+	}
+}
+
+func TestParseRecommendedPairs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		value   string
+		want    []RecommendedPair
+		wantErr string
+	}{
+		{
+			name: "empty uses the defaults",
+			want: DefaultRecommendedPairs,
+		},
+		{
+			name:  "pairs",
+			value: "requestsCpu:requestsMemory, limitsCpu : requestsCpu,",
+			want: []RecommendedPair{
+				{Resource: "requestsCpu", Recommended: "requestsMemory"},
+				{Resource: "limitsCpu", Recommended: "requestsCpu"},
+			},
+		},
+		{
+			name:    "missing colon",
+			value:   "requestsCpu",
+			wantErr: `pair "requestsCpu" must be two different resources separated by a colon`,
+		},
+		{
+			name:    "same resource",
+			value:   "requestsCpu:requestsCpu",
+			wantErr: `pair "requestsCpu:requestsCpu" must be two different resources separated by a colon`,
+		},
+		{
+			name:    "unknown resource",
+			value:   "requests.cpu:requestsMemory",
+			wantErr: `resource "requests.cpu" of pair "requests.cpu:requestsMemory" must be one of`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			pairs, err := ParseRecommendedPairs(test.value)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, pairs)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// AnnotationConflict declares two annotations that can't be set on the same object.
+type AnnotationConflict struct {
+	// Annotation and ConflictsWith are the keys of the conflicting annotations.
+	Annotation    string
+	ConflictsWith string
+	// Reason optionally explains why the annotations conflict.
+	Reason string
+}
+
+var (
+	// creatorAnnotationConflicts are the conflicts between the annotations identifying the creator of an object.
+	creatorAnnotationConflicts = []AnnotationConflict{
+		{
+			Annotation:    NoCreatorRBACAnn,
+			ConflictsWith: CreatorIDAnn,
+			Reason:        "no RBAC is created for the creator of the object",
+		},
+	}
+
+	// ClusterAnnotationConflicts are the annotations that can't be set together on management clusters.
+	ClusterAnnotationConflicts = creatorAnnotationConflicts
+	// ProjectAnnotationConflicts are the annotations that can't be set together on projects.
+	ProjectAnnotationConflicts = creatorAnnotationConflicts
+)
+
+// CheckAnnotationConflicts returns an error for each conflict whose annotations are both set on the object. It should
+// only be called for the create operation.
+func CheckAnnotationConflicts(obj metav1.Object, conflicts []AnnotationConflict) field.ErrorList {
+	var errList field.ErrorList
+	annotations := obj.GetAnnotations()
+	for _, conflict := range conflicts {
+		if !hasAnnotations(annotations, conflict.Annotation, conflict.ConflictsWith) {
+			continue
+		}
+		message := fmt.Sprintf("cannot have both %s and %s annotation set", conflict.Annotation, conflict.ConflictsWith)
+		if conflict.Reason != "" {
+			message += ": " + conflict.Reason
+		}
+		errList = append(errList, field.Forbidden(annotationsFieldPath, message))
+	}
+	return errList
+}
+
+// CheckCreatorIDAndNoCreatorRBAC checks that only one of no-creator-rbac or creatorID annotation is set
+func CheckCreatorIDAndNoCreatorRBAC(obj metav1.Object) *field.Error {
+	if errList := CheckAnnotationConflicts(obj, creatorAnnotationConflicts); len(errList) != 0 {
+		return errList[0]
+	}
+	return nil
+}
+
+// hasAnnotations returns true if all the keys are set in the annotations.
+func hasAnnotations(annotations map[string]string, keys ...string) bool {
+	for _, key := range keys {
+		if _, ok := annotations[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckAnnotationConflicts(t *testing.T) {
+	t.Parallel()
+	conflicts := []AnnotationConflict{
+		{Annotation: NoCreatorRBACAnn, ConflictsWith: CreatorIDAnn},
+		{Annotation: "example.cattle.io/a", ConflictsWith: "example.cattle.io/b", Reason: "a replaces b"},
+	}
+	clusterWith := func(annotations map[string]string) *v3.Cluster {
+		return &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name       string
+		obj        metav1.Object
+		wantErrors []string
+	}{
+		{
+			name: "no annotations",
+			obj:  &v3.Cluster{},
+		},
+		{
+			name: "just creatorID annotation",
+			obj:  clusterWith(map[string]string{CreatorIDAnn: "u-12345"}),
+		},
+		{
+			name: "just no-creator-rbac annotation",
+			obj:  clusterWith(map[string]string{NoCreatorRBACAnn: "true"}),
+		},
+		{
+			name:       "both creatorID and no-creator-rbac annotation",
+			obj:        clusterWith(map[string]string{CreatorIDAnn: "u-12345", NoCreatorRBACAnn: "true"}),
+			wantErrors: []string{"metadata.annotations: Forbidden: cannot have both field.cattle.io/no-creator-rbac and field.cattle.io/creatorId annotation set"},
+		},
+		{
+			name:       "conflicting annotations with empty values",
+			obj:        clusterWith(map[string]string{"example.cattle.io/a": "", "example.cattle.io/b": ""}),
+			wantErrors: []string{"metadata.annotations: Forbidden: cannot have both example.cattle.io/a and example.cattle.io/b annotation set: a replaces b"},
+		},
+		{
+			name: "all conflicts are reported",
+			obj: clusterWith(map[string]string{
+				CreatorIDAnn: "u-12345", NoCreatorRBACAnn: "true",
+				"example.cattle.io/a": "true", "example.cattle.io/b": "true",
+			}),
+			wantErrors: []string{
+				"metadata.annotations: Forbidden: cannot have both field.cattle.io/no-creator-rbac and field.cattle.io/creatorId annotation set",
+				"metadata.annotations: Forbidden: cannot have both example.cattle.io/a and example.cattle.io/b annotation set: a replaces b",
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			errList := CheckAnnotationConflicts(test.obj, conflicts)
+			var gotErrors []string
+			for _, fieldErr := range errList {
+				gotErrors = append(gotErrors, fieldErr.Error())
+			}
+			require.Equal(t, test.wantErrors, gotErrors)
+		})
+	}
+}
+
+func TestCheckCreatorIDAndNoCreatorRBAC(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		obj       *v3.Cluster
+		wantError bool
+	}{
+		{
+			name:      "no annotations",
+			obj:       &v3.Cluster{},
+			wantError: false,
+		},
+		{
+			name: "just creatorID annotation",
+			obj: &v3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn: "u-12345",
+					},
+				},
+			},
+			wantError: false,
+		},
+		{
+			name: "just no-creator-rbac annotation",
+			obj: &v3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						NoCreatorRBACAnn: "true",
+					},
+				},
+			},
+			wantError: false,
+		},
+		{
+			name: "both creatorID and no-creator-rbac annotation",
+			obj: &v3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:     "u-12345",
+						NoCreatorRBACAnn: "true",
+					},
+				},
+			},
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			fieldErr := CheckCreatorIDAndNoCreatorRBAC(test.obj)
+			require.Equal(t, test.wantError, fieldErr != nil)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// AnnotationMigration declares the rename of an annotation. Mutators move the legacy annotation to the new key on
+// write, and validators accept the legacy key until the end of the grace period, so that objects which haven't been
+// written since the rename keep working.
+type AnnotationMigration struct {
+	// From is the legacy key and To the new one.
+	From string
+	To   string
+	// Prefix makes From and To key prefixes, for annotations whose keys end with a name, e.g. the lifecycle
+	// annotations of a handler.
+	Prefix bool
+	// Until is the end of the grace period, after which the legacy key is denied. The grace period of a migration
+	// without an end never ends.
+	Until time.Time
+}
+
+var (
+	// ClusterAnnotationMigrations are the renamed annotations of management clusters.
+	ClusterAnnotationMigrations []AnnotationMigration
+	// ProjectAnnotationMigrations are the renamed annotations of projects.
+	ProjectAnnotationMigrations []AnnotationMigration
+)
+
+// migratedKey returns the new key of an annotation, and false if the key isn't renamed by the migration.
+func (m AnnotationMigration) migratedKey(key string) (string, bool) {
+	if !m.Prefix {
+		return m.To, key == m.From
+	}
+	if !strings.HasPrefix(key, m.From) {
+		return "", false
+	}
+	return m.To + strings.TrimPrefix(key, m.From), true
+}
+
+// inGracePeriod returns true if validators still accept the legacy key.
+func (m AnnotationMigration) inGracePeriod() bool {
+	return m.Until.IsZero() || time.Now().Before(m.Until)
+}
+
+// MigrateAnnotations moves the legacy annotations of the migrations on obj to their new keys. A new key that is
+// already set is kept, and the legacy annotation dropped. It returns true if obj was changed.
+func MigrateAnnotations(obj metav1.Object, migrations []AnnotationMigration) bool {
+	annotations := obj.GetAnnotations()
+	migrated := false
+	for _, key := range sortedAnnotationKeys(annotations) {
+		value := annotations[key]
+		for _, migration := range migrations {
+			newKey, ok := migration.migratedKey(key)
+			if !ok {
+				continue
+			}
+			if _, ok := annotations[newKey]; !ok {
+				annotations[newKey] = value
+			}
+			delete(annotations, key)
+			migrated = true
+			break
+		}
+	}
+	if migrated {
+		obj.SetAnnotations(annotations)
+	}
+	return migrated
+}
+
+// CheckAnnotationMigrations returns an error for each legacy annotation of the migrations on newObj whose grace period
+// ended, or whose new key is set to a different value.
+func CheckAnnotationMigrations(newObj metav1.Object, migrations []AnnotationMigration) field.ErrorList {
+	var errList field.ErrorList
+	annotations := newObj.GetAnnotations()
+	for _, key := range sortedAnnotationKeys(annotations) {
+		for _, migration := range migrations {
+			newKey, ok := migration.migratedKey(key)
+			if !ok {
+				continue
+			}
+			if !migration.inGracePeriod() {
+				errList = append(errList, field.Forbidden(annotationsFieldPath.Key(key),
+					fmt.Sprintf("annotation was renamed to %s", newKey)))
+			} else if value, ok := annotations[newKey]; ok && value != annotations[key] {
+				errList = append(errList, field.Invalid(annotationsFieldPath.Key(key), annotations[key],
+					fmt.Sprintf("conflicts with the value of %s, which it was renamed to", newKey)))
+			}
+			break
+		}
+	}
+	return errList
+}
+
+// GetMigratedAnnotation returns the value of the annotation of obj, falling back to the legacy key of the annotation
+// while its grace period lasts.
+func GetMigratedAnnotation(obj metav1.Object, key string, migrations []AnnotationMigration) (string, bool) {
+	annotations := obj.GetAnnotations()
+	if value, ok := annotations[key]; ok {
+		return value, true
+	}
+	for _, legacyKey := range sortedAnnotationKeys(annotations) {
+		value := annotations[legacyKey]
+		for _, migration := range migrations {
+			if newKey, ok := migration.migratedKey(legacyKey); ok && newKey == key && migration.inGracePeriod() {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// sortedAnnotationKeys returns the keys of the annotations in order, so that errors are reported in a stable order.
+func sortedAnnotationKeys(annotations map[string]string) []string {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var testAnnotationMigrations = []AnnotationMigration{
+	{From: "example.cattle.io/legacy", To: "example.cattle.io/current"},
+	{From: "lifecycle.cattle.io/", To: "lifecycle.management.cattle.io/", Prefix: true},
+	{From: "example.cattle.io/expired", To: "example.cattle.io/renamed", Until: time.Now().Add(-time.Hour)},
+}
+
+func projectWith(annotations map[string]string) *v3.Project {
+	return &v3.Project{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+}
+
+func TestMigrateAnnotations(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        map[string]string
+		wantChanged bool
+	}{
+		{
+			name: "no annotations",
+		},
+		{
+			name:        "new keys",
+			annotations: map[string]string{"example.cattle.io/current": "a"},
+			want:        map[string]string{"example.cattle.io/current": "a"},
+		},
+		{
+			name:        "legacy key",
+			annotations: map[string]string{"example.cattle.io/legacy": "a", "other": "b"},
+			want:        map[string]string{"example.cattle.io/current": "a", "other": "b"},
+			wantChanged: true,
+		},
+		{
+			name:        "legacy and new keys",
+			annotations: map[string]string{"example.cattle.io/legacy": "a", "example.cattle.io/current": "b"},
+			want:        map[string]string{"example.cattle.io/current": "b"},
+			wantChanged: true,
+		},
+		{
+			name:        "legacy prefix",
+			annotations: map[string]string{"lifecycle.cattle.io/create.project-rbac": "true"},
+			want:        map[string]string{"lifecycle.management.cattle.io/create.project-rbac": "true"},
+			wantChanged: true,
+		},
+		{
+			name:        "legacy key after the grace period",
+			annotations: map[string]string{"example.cattle.io/expired": "a"},
+			want:        map[string]string{"example.cattle.io/renamed": "a"},
+			wantChanged: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			project := projectWith(test.annotations)
+			assert.Equal(t, test.wantChanged, MigrateAnnotations(project, testAnnotationMigrations))
+			assert.Equal(t, test.want, project.Annotations)
+		})
+	}
+}
+
+func TestCheckAnnotationMigrations(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErrors  []string
+	}{
+		{
+			name:        "new key",
+			annotations: map[string]string{"example.cattle.io/current": "a", "example.cattle.io/renamed": "b"},
+		},
+		{
+			name:        "legacy key in the grace period",
+			annotations: map[string]string{"example.cattle.io/legacy": "a", "lifecycle.cattle.io/create.project-rbac": "true"},
+		},
+		{
+			name:        "legacy and new keys with the same value",
+			annotations: map[string]string{"example.cattle.io/legacy": "a", "example.cattle.io/current": "a"},
+		},
+		{
+			name:        "legacy and new keys with different values",
+			annotations: map[string]string{"example.cattle.io/legacy": "a", "example.cattle.io/current": "b"},
+			wantErrors:  []string{`metadata.annotations[example.cattle.io/legacy]: Invalid value: "a": conflicts with the value of example.cattle.io/current, which it was renamed to`},
+		},
+		{
+			name:        "legacy key after the grace period",
+			annotations: map[string]string{"example.cattle.io/expired": "a"},
+			wantErrors:  []string{"metadata.annotations[example.cattle.io/expired]: Forbidden: annotation was renamed to example.cattle.io/renamed"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			errList := CheckAnnotationMigrations(projectWith(test.annotations), testAnnotationMigrations)
+			var gotErrors []string
+			for _, fieldErr := range errList {
+				gotErrors = append(gotErrors, fieldErr.Error())
+			}
+			require.Equal(t, test.wantErrors, gotErrors)
+		})
+	}
+}
+
+func TestGetMigratedAnnotation(t *testing.T) {
+	t.Parallel()
+	value, ok := GetMigratedAnnotation(projectWith(map[string]string{"example.cattle.io/legacy": "a"}), "example.cattle.io/current", testAnnotationMigrations)
+	assert.True(t, ok)
+	assert.Equal(t, "a", value)
+
+	value, ok = GetMigratedAnnotation(projectWith(map[string]string{"example.cattle.io/legacy": "a", "example.cattle.io/current": "b"}), "example.cattle.io/current", testAnnotationMigrations)
+	assert.True(t, ok)
+	assert.Equal(t, "b", value)
+
+	_, ok = GetMigratedAnnotation(projectWith(map[string]string{"example.cattle.io/expired": "a"}), "example.cattle.io/renamed", testAnnotationMigrations)
+	assert.False(t, ok)
+}
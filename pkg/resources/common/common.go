@@ -1,6 +1,8 @@
 package common
 
 import (
+	"strings"
+
 	"github.com/rancher/webhook/pkg/admission"
 	"github.com/rancher/webhook/pkg/auth"
 	"github.com/sirupsen/logrus"
@@ -19,8 +21,13 @@ const (
 	CreatorPrincipalNameAnn = "field.cattle.io/creator-principal-name"
 	// NoCreatorRBACAnn is an annotation key to indicate that a cluster doesn't need
 	NoCreatorRBACAnn = "field.cattle.io/no-creator-rbac"
+	// OriginalCreatorAnnotationsAnn is an annotation key managed by the webhook that records the creator annotations an object was created with.
+	OriginalCreatorAnnotationsAnn = "webhook.cattle.io/original-creator-annotations"
 )
 
+// creatorAnnotations are the annotations that identify the creator of an object.
+var creatorAnnotations = []string{CreatorIDAnn, CreatorPrincipalNameAnn, NoCreatorRBACAnn}
+
 // ConvertAuthnExtras converts authnv1 type extras to authzv1 extras. Technically these are both
 // type alias to string, so the conversion is straightforward
 func ConvertAuthnExtras(extra map[string]authnv1.ExtraValue) map[string]authzv1.ExtraValue {
@@ -31,6 +38,17 @@ func ConvertAuthnExtras(extra map[string]authnv1.ExtraValue) map[string]authzv1.
 	return result
 }
 
+// SplitList splits a comma separated list, ignoring empty entries.
+func SplitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
 // ValidateLabel checks if a user is removing or modifying a label. If the label is newly added, return false.
 func IsModifyingLabel(oldLabels, newLabels map[string]string, label string) bool {
 	var oldValue, newValue string
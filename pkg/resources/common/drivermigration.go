@@ -0,0 +1,26 @@
+package common
+
+import (
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+)
+
+const (
+	// DriverMigrationAnn is an annotation key that allows Rancher to change the driver or provider of a cluster.
+	DriverMigrationAnn = "provisioning.cattle.io/allow-driver-migration"
+
+	// rancherNamespace is the namespace Rancher runs in.
+	rancherNamespace = "cattle-system"
+)
+
+// IsDriverMigrationAllowed returns true if the object has the DriverMigrationAnn annotation set to "true" and the
+// request is made by a service account of the namespace Rancher runs in. Other users can't migrate clusters between
+// drivers, even if the annotation is set.
+func IsDriverMigrationAllowed(request *admission.Request, obj metav1.Object) bool {
+	if obj.GetAnnotations()[DriverMigrationAnn] != "true" {
+		return false
+	}
+	return identity.FromUserInfo(request.UserInfo).InGroup(serviceaccount.MakeNamespaceGroupName(rancherNamespace))
+}
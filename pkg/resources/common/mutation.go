@@ -1,7 +1,11 @@
 package common
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -21,3 +25,100 @@ func SetCreatorIDAnnotation(request *admission.Request, obj metav1.Object) {
 	annotations[CreatorIDAnn] = request.UserInfo.Username
 	obj.SetAnnotations(annotations)
 }
+
+// SetCreatorAnnotations sets the creatorId annotation on the newObj to the user of the request if it's missing, and the
+// creator-principal-name annotation to the principal Rancher impersonated the request as if it's missing and the
+// principal belongs to the creator. Only Rancher users become creators, and nothing is set if the noCreatorRBAC
+// annotation is set.
+func SetCreatorAnnotations(request *admission.Request, identities *identity.Resolver, obj metav1.Object) error {
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[NoCreatorRBACAnn]; ok {
+		return nil
+	}
+	id, err := identities.Resolve(request.UserInfo)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the requester: %w", err)
+	}
+	if id.User == nil {
+		return nil
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if annotations[CreatorIDAnn] == "" {
+		annotations[CreatorIDAnn] = id.Name
+	}
+	principal := identity.FromUserInfo(request.UserInfo).PrimaryPrincipal()
+	if annotations[CreatorPrincipalNameAnn] == "" && annotations[CreatorIDAnn] == id.Name && principal != "" {
+		verified, err := identities.VerifyPrincipal(request.Context, id.Name, principal)
+		if err != nil {
+			return fmt.Errorf("failed to verify principal %s of user %s: %w", principal, id.Name, err)
+		}
+		if verified {
+			annotations[CreatorPrincipalNameAnn] = principal
+		}
+	}
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// SetOriginalCreatorAnnotations records the creator annotations currently set on the object in the
+// original-creator-annotations annotation, so that they can later be restored if they are accidentally removed.
+// The record is rebuilt from the creator annotations, replacing any record already set, and removed if the object has
+// no non-empty creator annotations.
+func SetOriginalCreatorAnnotations(obj metav1.Object) error {
+	annotations := obj.GetAnnotations()
+	record, err := originalCreatorAnnotationsRecord(obj)
+	if err != nil {
+		return err
+	}
+	if record == "" {
+		if _, ok := annotations[OriginalCreatorAnnotationsAnn]; ok {
+			delete(annotations, OriginalCreatorAnnotationsAnn)
+			obj.SetAnnotations(annotations)
+		}
+		return nil
+	}
+	annotations[OriginalCreatorAnnotationsAnn] = record
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// KeepOriginalCreatorAnnotations carries the original-creator-annotations record of the old object over to the new
+// one if the update drops it, e.g. when automation strips the annotations of the object, so that the creator
+// annotations stripped along with it can still be restored later.
+func KeepOriginalCreatorAnnotations(oldObj, newObj metav1.Object) {
+	record, ok := oldObj.GetAnnotations()[OriginalCreatorAnnotationsAnn]
+	if !ok {
+		return
+	}
+	annotations := newObj.GetAnnotations()
+	if _, ok := annotations[OriginalCreatorAnnotationsAnn]; ok {
+		return
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[OriginalCreatorAnnotationsAnn] = record
+	newObj.SetAnnotations(annotations)
+}
+
+// originalCreatorAnnotationsRecord returns the record of the non-empty creator annotations set on the object, or an
+// empty string if there are none.
+func originalCreatorAnnotationsRecord(obj metav1.Object) (string, error) {
+	annotations := obj.GetAnnotations()
+	original := map[string]string{}
+	for _, annotation := range creatorAnnotations {
+		if value := annotations[annotation]; value != "" {
+			original[annotation] = value
+		}
+	}
+	if len(original) == 0 {
+		return "", nil
+	}
+	record, err := json.Marshal(original)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal original creator annotations: %w", err)
+	}
+	return string(record), nil
+}
@@ -1,14 +1,22 @@
 package common
 
 import (
+	"fmt"
 	"testing"
 
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestSetCreatorIDAnnotation(t *testing.T) {
@@ -70,3 +78,277 @@ func TestSetCreatorIDAnnotation(t *testing.T) {
 		})
 	}
 }
+
+func TestSetCreatorAnnotations(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	userCache := fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl)
+	userCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.User, error) {
+		switch name {
+		case "u-12345":
+			return &v3.User{
+				ObjectMeta:   metav1.ObjectMeta{Name: "u-12345"},
+				PrincipalIDs: []string{"local://u-12345", "keycloak_user://12345"},
+			}, nil
+		case "u-error":
+			return nil, fmt.Errorf("some error")
+		default:
+			return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+		}
+	}).AnyTimes()
+	identities := identity.NewResolver(userCache)
+
+	tests := []struct {
+		name        string
+		username    string
+		principal   string
+		annotations map[string]string
+		want        map[string]string
+		wantErr     bool
+	}{
+		{
+			name:      "creator annotations are set",
+			username:  "u-12345",
+			principal: "keycloak_user://12345",
+			want: map[string]string{
+				CreatorIDAnn:            "u-12345",
+				CreatorPrincipalNameAnn: "keycloak_user://12345",
+			},
+		},
+		{
+			name:     "only creatorID is set without a principal",
+			username: "u-12345",
+			want: map[string]string{
+				CreatorIDAnn: "u-12345",
+			},
+		},
+		{
+			name:      "principal of another user is not set",
+			username:  "u-12345",
+			principal: "keycloak_user://12346",
+			want: map[string]string{
+				CreatorIDAnn: "u-12345",
+			},
+		},
+		{
+			name:      "existing creator annotations are kept",
+			username:  "u-12345",
+			principal: "keycloak_user://12345",
+			annotations: map[string]string{
+				CreatorIDAnn:            "u-12345",
+				CreatorPrincipalNameAnn: "local://u-12345",
+			},
+			want: map[string]string{
+				CreatorIDAnn:            "u-12345",
+				CreatorPrincipalNameAnn: "local://u-12345",
+			},
+		},
+		{
+			name:      "principal is not set for another creator",
+			username:  "u-12345",
+			principal: "keycloak_user://12345",
+			annotations: map[string]string{
+				CreatorIDAnn: "u-12346",
+			},
+			want: map[string]string{
+				CreatorIDAnn: "u-12346",
+			},
+		},
+		{
+			name:      "nothing is set if noCreatorRBAC is set",
+			username:  "u-12345",
+			principal: "keycloak_user://12345",
+			annotations: map[string]string{
+				NoCreatorRBACAnn: "true",
+			},
+			want: map[string]string{
+				NoCreatorRBACAnn: "true",
+			},
+		},
+		{
+			name:     "nothing is set for service accounts",
+			username: "system:serviceaccount:cattle-system:rancher",
+		},
+		{
+			name:     "nothing is set for users which don't exist",
+			username: "u-missing",
+		},
+		{
+			name:     "user lookup error",
+			username: "u-error",
+			wantErr:  true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					UserInfo: authenticationv1.UserInfo{
+						Username: test.username,
+					},
+				},
+			}
+			if test.principal != "" {
+				req.UserInfo.Extra = map[string]authenticationv1.ExtraValue{"principalid": {test.principal}}
+			}
+			cluster := v3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: test.annotations,
+				},
+			}
+			err := SetCreatorAnnotations(&req, identities, &cluster)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, cluster.GetAnnotations())
+		})
+	}
+}
+
+func TestSetOriginalCreatorAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        map[string]string
+	}{
+		{
+			name: "no annotations",
+		},
+		{
+			name: "no creator annotations",
+			annotations: map[string]string{
+				"existingAnno": "test",
+			},
+			want: map[string]string{
+				"existingAnno": "test",
+			},
+		},
+		{
+			name: "empty creatorID is not recorded",
+			annotations: map[string]string{
+				CreatorIDAnn: "",
+			},
+			want: map[string]string{
+				CreatorIDAnn: "",
+			},
+		},
+		{
+			name: "creator annotations are recorded",
+			annotations: map[string]string{
+				CreatorIDAnn:            "u-12345",
+				CreatorPrincipalNameAnn: "keycloak_user://12345",
+			},
+			want: map[string]string{
+				CreatorIDAnn:                  "u-12345",
+				CreatorPrincipalNameAnn:       "keycloak_user://12345",
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creator-principal-name":"keycloak_user://12345","field.cattle.io/creatorId":"u-12345"}`,
+			},
+		},
+		{
+			name: "no-creator-rbac is recorded",
+			annotations: map[string]string{
+				NoCreatorRBACAnn: "true",
+			},
+			want: map[string]string{
+				NoCreatorRBACAnn:              "true",
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/no-creator-rbac":"true"}`,
+			},
+		},
+		{
+			name: "existing record is replaced",
+			annotations: map[string]string{
+				CreatorIDAnn:                  "u-12345",
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12346"}`,
+			},
+			want: map[string]string{
+				CreatorIDAnn:                  "u-12345",
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345"}`,
+			},
+		},
+		{
+			name: "existing record is removed without creator annotations",
+			annotations: map[string]string{
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12346"}`,
+			},
+			want: map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: test.annotations,
+				},
+			}
+			err := SetOriginalCreatorAnnotations(&cluster)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, cluster.GetAnnotations())
+		})
+	}
+}
+
+func TestKeepOriginalCreatorAnnotations(t *testing.T) {
+	const record = `{"field.cattle.io/creatorId":"u-12345"}`
+	tests := []struct {
+		name           string
+		oldAnnotations map[string]string
+		newAnnotations map[string]string
+		want           map[string]string
+	}{
+		{
+			name: "no record",
+			oldAnnotations: map[string]string{
+				CreatorIDAnn: "u-12345",
+			},
+			want: nil,
+		},
+		{
+			name: "stripped record is kept",
+			oldAnnotations: map[string]string{
+				CreatorIDAnn:                  "u-12345",
+				OriginalCreatorAnnotationsAnn: record,
+			},
+			want: map[string]string{
+				OriginalCreatorAnnotationsAnn: record,
+			},
+		},
+		{
+			name: "record is kept along with other annotations",
+			oldAnnotations: map[string]string{
+				OriginalCreatorAnnotationsAnn: record,
+			},
+			newAnnotations: map[string]string{
+				CreatorIDAnn: "u-12345",
+			},
+			want: map[string]string{
+				CreatorIDAnn:                  "u-12345",
+				OriginalCreatorAnnotationsAnn: record,
+			},
+		},
+		{
+			name: "changed record is left to the validator",
+			oldAnnotations: map[string]string{
+				OriginalCreatorAnnotationsAnn: record,
+			},
+			newAnnotations: map[string]string{
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12346"}`,
+			},
+			want: map[string]string{
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12346"}`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldCluster := v1.Cluster{ObjectMeta: metav1.ObjectMeta{Annotations: test.oldAnnotations}}
+			newCluster := v1.Cluster{ObjectMeta: metav1.ObjectMeta{Annotations: test.newAnnotations}}
+			KeepOriginalCreatorAnnotations(&oldCluster, &newCluster)
+			assert.Equal(t, test.want, newCluster.GetAnnotations())
+		})
+	}
+}
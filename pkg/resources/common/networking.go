@@ -0,0 +1,266 @@
+package common
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	netutils "k8s.io/utils/net"
+)
+
+const (
+	// DefaultClusterCIDR is the pod CIDR used by RKE, RKE2 and K3s when none is configured.
+	DefaultClusterCIDR = "10.42.0.0/16"
+	// DefaultServiceCIDR is the service CIDR used by RKE, RKE2 and K3s when none is configured.
+	DefaultServiceCIDR = "10.43.0.0/16"
+	// DefaultClusterDNS is the cluster DNS IP used by RKE, RKE2 and K3s when neither the cluster DNS nor the service
+	// CIDR are configured.
+	DefaultClusterDNS = "10.43.0.10"
+	// clusterDNSOffset is the offset in the service CIDR of the cluster DNS IP RKE, RKE2 and K3s use when none is
+	// configured.
+	clusterDNSOffset = 10
+
+	// defaultNodeMaskSizeIPv4 and defaultNodeMaskSizeIPv6 are the default prefix sizes of the pod CIDR allocated to
+	// each node by the kube-controller-manager.
+	defaultNodeMaskSizeIPv4 = 24
+	defaultNodeMaskSizeIPv6 = 64
+	// maxServiceCIDRBitsIPv4 and maxServiceCIDRBitsIPv6 are the maximum number of host bits the kube-apiserver accepts
+	// for a service CIDR of each IP family, a /12 for IPv4 and a /64 for IPv6.
+	maxServiceCIDRBitsIPv4 = 20
+	maxServiceCIDRBitsIPv6 = 64
+)
+
+// nodeMaskSizeArgs are the kube-controller-manager arguments setting the prefix size of node pod CIDRs: the first for
+// both IP families, the others for a single family in dual-stack clusters.
+const (
+	nodeMaskSizeArg     = "node-cidr-mask-size"
+	nodeMaskSizeIPv4Arg = "node-cidr-mask-size-ipv4"
+	nodeMaskSizeIPv6Arg = "node-cidr-mask-size-ipv6"
+)
+
+// ClusterNetworking holds the networking configuration of a cluster along with the field paths they were read from.
+// The CIDR and DNS values may be comma separated lists to support dual-stack clusters.
+type ClusterNetworking struct {
+	ClusterCIDR     string
+	ClusterCIDRPath *field.Path
+	ServiceCIDR     string
+	ServiceCIDRPath *field.Path
+	ClusterDNS      string
+	ClusterDNSPath  *field.Path
+	// NodeCount is the number of nodes the cluster is expected to have. Zero skips the node capacity check.
+	NodeCount int
+	// NodeMaskSizeIPv4 and NodeMaskSizeIPv6 are the prefix sizes of the pod CIDR given to each node, zero for the
+	// kube-controller-manager defaults. See SetNodeMaskSizes.
+	NodeMaskSizeIPv4 int
+	NodeMaskSizeIPv6 int
+}
+
+// SetNodeMaskSizes sets the prefix sizes of node pod CIDRs from the kube-controller-manager arguments, given without
+// their leading dashes. Sizes that aren't numbers are ignored, the kube-controller-manager fails on them.
+func (n *ClusterNetworking) SetNodeMaskSizes(args map[string]string) {
+	parse := func(name string) int {
+		size, err := strconv.Atoi(strings.TrimSpace(args[name]))
+		if err != nil || size <= 0 {
+			return 0
+		}
+		return size
+	}
+	if size := parse(nodeMaskSizeArg); size != 0 {
+		n.NodeMaskSizeIPv4, n.NodeMaskSizeIPv6 = size, size
+	}
+	if size := parse(nodeMaskSizeIPv4Arg); size != 0 {
+		n.NodeMaskSizeIPv4 = size
+	}
+	if size := parse(nodeMaskSizeIPv6Arg); size != 0 {
+		n.NodeMaskSizeIPv6 = size
+	}
+}
+
+// ValidateClusterNetworking checks that the cluster and service CIDRs can be parsed, that they do not overlap, that
+// the service CIDR isn't larger than the kube-apiserver accepts, that the cluster DNS IP is within the service CIDR,
+// and that the cluster CIDR is large enough to give a pod CIDR to each of the expected nodes.
+func ValidateClusterNetworking(networking ClusterNetworking) field.ErrorList {
+	return validateClusterNetworking(nil, networking)
+}
+
+// ValidateClusterNetworkingUpdate checks the networking configuration of an updated cluster like
+// ValidateClusterNetworking, but only the values that changed: existing clusters aren't denied because of values
+// nobody touched. The node capacity of the cluster CIDR is only denied when it gets worse, e.g. when scaling up a
+// cluster which already has more nodes than its cluster CIDR fits.
+func ValidateClusterNetworkingUpdate(oldNetworking, networking ClusterNetworking) field.ErrorList {
+	return validateClusterNetworking(&oldNetworking, networking)
+}
+
+// validateClusterNetworking validates the networking configuration, only the values that changed from the old
+// networking configuration if there is one.
+func validateClusterNetworking(oldNetworking *ClusterNetworking, networking ClusterNetworking) field.ErrorList {
+	clusterCIDRChanged := oldNetworking == nil || oldNetworking.ClusterCIDR != networking.ClusterCIDR
+	serviceCIDRChanged := oldNetworking == nil || oldNetworking.ServiceCIDR != networking.ServiceCIDR
+	clusterDNSChanged := oldNetworking == nil || oldNetworking.ClusterDNS != networking.ClusterDNS
+
+	var errList field.ErrorList
+	clusterCIDRs, clusterCIDRErrs := parseCIDRs(networking.ClusterCIDR, networking.ClusterCIDRPath)
+	if clusterCIDRChanged {
+		errList = append(errList, clusterCIDRErrs...)
+	}
+	serviceCIDRs, serviceCIDRErrs := parseCIDRs(networking.ServiceCIDR, networking.ServiceCIDRPath)
+	if serviceCIDRChanged {
+		errList = append(errList, serviceCIDRErrs...)
+	}
+	if len(clusterCIDRErrs) != 0 || len(serviceCIDRErrs) != 0 {
+		return errList
+	}
+
+	if clusterCIDRChanged || serviceCIDRChanged {
+		for _, clusterCIDR := range clusterCIDRs {
+			for _, serviceCIDR := range serviceCIDRs {
+				if clusterCIDR.Contains(serviceCIDR.IP) || serviceCIDR.Contains(clusterCIDR.IP) {
+					errList = append(errList, field.Invalid(networking.ServiceCIDRPath, networking.ServiceCIDR,
+						fmt.Sprintf("service CIDR %s overlaps with cluster CIDR %s", serviceCIDR, clusterCIDR)))
+				}
+			}
+		}
+	}
+
+	if serviceCIDRChanged {
+		for _, serviceCIDR := range serviceCIDRs {
+			ones, bits := serviceCIDR.Mask.Size()
+			if maxBits := maxServiceCIDRBits(serviceCIDR); bits-ones > maxBits {
+				errList = append(errList, field.Invalid(networking.ServiceCIDRPath, networking.ServiceCIDR,
+					fmt.Sprintf("service CIDR %s is too large, the prefix size must be at least /%d", serviceCIDR, bits-maxBits)))
+			}
+		}
+	}
+
+	if networking.NodeCount > 0 {
+		var oldShortfalls map[bool]*big.Int
+		if oldNetworking != nil {
+			oldShortfalls = oldNetworking.nodeShortfalls()
+		}
+		for _, clusterCIDR := range clusterCIDRs {
+			capacity := networking.nodeCapacity(clusterCIDR)
+			shortfall := new(big.Int).Sub(big.NewInt(int64(networking.NodeCount)), capacity)
+			if shortfall.Sign() <= 0 {
+				continue
+			}
+			// an update only makes the capacity worse if more nodes are missing a pod CIDR than before
+			if oldShortfall, ok := oldShortfalls[netutils.IsIPv6CIDR(clusterCIDR)]; ok && shortfall.Cmp(oldShortfall) <= 0 {
+				continue
+			}
+			errList = append(errList, field.Invalid(networking.ClusterCIDRPath, networking.ClusterCIDR,
+				fmt.Sprintf("cluster CIDR %s only has room for %s node pod CIDRs of size /%d, but %d nodes are expected",
+					clusterCIDR, capacity, networking.nodeMaskSize(clusterCIDR), networking.NodeCount)))
+		}
+	}
+
+	if clusterDNSChanged || serviceCIDRChanged {
+		errList = append(errList, validateClusterDNS(networking, serviceCIDRs)...)
+	}
+	return errList
+}
+
+// DefaultClusterDNSFor returns the cluster DNS IP RKE, RKE2 and K3s derive from the service CIDR when none is
+// configured, the .10 address of the first service CIDR. An empty string is returned if the service CIDR is invalid.
+func DefaultClusterDNSFor(serviceCIDR string) string {
+	_, ipNet, err := netutils.ParseCIDRSloppy(strings.TrimSpace(strings.Split(serviceCIDR, ",")[0]))
+	if err != nil {
+		return ""
+	}
+	return netutils.AddIPOffset(netutils.BigForIP(ipNet.IP), clusterDNSOffset).String()
+}
+
+// validateClusterDNS checks that each cluster DNS IP is valid and belongs to a service CIDR of the same IP family.
+func validateClusterDNS(networking ClusterNetworking, serviceCIDRs []*net.IPNet) field.ErrorList {
+	if networking.ClusterDNS == "" {
+		return nil
+	}
+	var errList field.ErrorList
+	for _, dns := range strings.Split(networking.ClusterDNS, ",") {
+		ip := netutils.ParseIPSloppy(strings.TrimSpace(dns))
+		if ip == nil {
+			errList = append(errList, field.Invalid(networking.ClusterDNSPath, networking.ClusterDNS,
+				fmt.Sprintf("%q is not a valid IP address", dns)))
+			continue
+		}
+		inServiceCIDR := false
+		for _, serviceCIDR := range serviceCIDRs {
+			if serviceCIDR.Contains(ip) {
+				inServiceCIDR = true
+				break
+			}
+		}
+		if !inServiceCIDR {
+			errList = append(errList, field.Invalid(networking.ClusterDNSPath, networking.ClusterDNS,
+				fmt.Sprintf("cluster DNS IP %s is not within the service CIDR %s", ip, networking.ServiceCIDR)))
+		}
+	}
+	return errList
+}
+
+// parseCIDRs parses a comma separated list of CIDRs.
+func parseCIDRs(value string, path *field.Path) ([]*net.IPNet, field.ErrorList) {
+	var errList field.ErrorList
+	var cidrs []*net.IPNet
+	for _, cidr := range strings.Split(value, ",") {
+		_, ipNet, err := netutils.ParseCIDRSloppy(strings.TrimSpace(cidr))
+		if err != nil {
+			errList = append(errList, field.Invalid(path, value, fmt.Sprintf("%q is not a valid CIDR", cidr)))
+			continue
+		}
+		cidrs = append(cidrs, ipNet)
+	}
+	return cidrs, errList
+}
+
+// maxServiceCIDRBits returns the maximum number of host bits of a service CIDR of the IP family of the given CIDR.
+func maxServiceCIDRBits(cidr *net.IPNet) int {
+	if netutils.IsIPv6CIDR(cidr) {
+		return maxServiceCIDRBitsIPv6
+	}
+	return maxServiceCIDRBitsIPv4
+}
+
+// nodeMaskSize returns the prefix size of the pod CIDR given to each node for the IP family of the given CIDR.
+func (n *ClusterNetworking) nodeMaskSize(cidr *net.IPNet) int {
+	if netutils.IsIPv6CIDR(cidr) {
+		if n.NodeMaskSizeIPv6 != 0 {
+			return n.NodeMaskSizeIPv6
+		}
+		return defaultNodeMaskSizeIPv6
+	}
+	if n.NodeMaskSizeIPv4 != 0 {
+		return n.NodeMaskSizeIPv4
+	}
+	return defaultNodeMaskSizeIPv4
+}
+
+// nodeCapacity returns the number of node pod CIDRs that fit in the given cluster CIDR.
+func (n *ClusterNetworking) nodeCapacity(cidr *net.IPNet) *big.Int {
+	ones, _ := cidr.Mask.Size()
+	nodeMask := n.nodeMaskSize(cidr)
+	if ones > nodeMask {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(nodeMask-ones))
+}
+
+// nodeShortfalls returns the number of nodes without room for a pod CIDR in the cluster CIDR of each IP family, keyed
+// by whether the family is IPv6. Families whose cluster CIDR doesn't parse are left out.
+func (n *ClusterNetworking) nodeShortfalls() map[bool]*big.Int {
+	clusterCIDRs, errs := parseCIDRs(n.ClusterCIDR, n.ClusterCIDRPath)
+	if len(errs) != 0 {
+		return nil
+	}
+	shortfalls := map[bool]*big.Int{}
+	for _, clusterCIDR := range clusterCIDRs {
+		shortfall := new(big.Int).Sub(big.NewInt(int64(n.NodeCount)), n.nodeCapacity(clusterCIDR))
+		if shortfall.Sign() < 0 {
+			shortfall.SetInt64(0)
+		}
+		shortfalls[netutils.IsIPv6CIDR(clusterCIDR)] = shortfall
+	}
+	return shortfalls
+}
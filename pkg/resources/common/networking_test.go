@@ -0,0 +1,261 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateClusterNetworking(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		clusterCIDR string
+		serviceCIDR string
+		clusterDNS  string
+		nodeCount   int
+		args        map[string]string
+		wantFields  []string
+	}{
+		{
+			name:        "defaults",
+			clusterCIDR: DefaultClusterCIDR,
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  DefaultClusterDNS,
+			nodeCount:   3,
+		},
+		{
+			name:        "dual-stack",
+			clusterCIDR: "10.42.0.0/16,2001:cafe:42::/56",
+			serviceCIDR: "10.43.0.0/16,2001:cafe:43::/112",
+			clusterDNS:  "10.43.0.10,2001:cafe:43::a",
+			nodeCount:   200,
+		},
+		{
+			name:        "invalid cluster CIDR",
+			clusterCIDR: "10.42.0.0",
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  DefaultClusterDNS,
+			wantFields:  []string{"clusterCIDR"},
+		},
+		{
+			name:        "invalid cluster and service CIDRs",
+			clusterCIDR: "10.42.0.0/33",
+			serviceCIDR: "foo",
+			clusterDNS:  DefaultClusterDNS,
+			wantFields:  []string{"clusterCIDR", "serviceCIDR"},
+		},
+		{
+			name:        "service CIDR inside cluster CIDR",
+			clusterCIDR: "10.0.0.0/8",
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  DefaultClusterDNS,
+			wantFields:  []string{"serviceCIDR"},
+		},
+		{
+			name:        "cluster CIDR inside service CIDR",
+			clusterCIDR: DefaultClusterCIDR,
+			serviceCIDR: "10.32.0.0/12",
+			clusterDNS:  DefaultClusterDNS,
+			wantFields:  []string{"serviceCIDR"},
+		},
+		{
+			name:        "service CIDR too large",
+			clusterCIDR: DefaultClusterCIDR,
+			serviceCIDR: "172.16.0.0/11",
+			clusterDNS:  "172.16.0.10",
+			wantFields:  []string{"serviceCIDR"},
+		},
+		{
+			name:        "cluster DNS outside service CIDR",
+			clusterCIDR: DefaultClusterCIDR,
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  "10.44.0.10",
+			wantFields:  []string{"clusterDNS"},
+		},
+		{
+			name:        "invalid cluster DNS",
+			clusterCIDR: DefaultClusterCIDR,
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  "10.43.0",
+			wantFields:  []string{"clusterDNS"},
+		},
+		{
+			name:        "empty cluster DNS",
+			clusterCIDR: DefaultClusterCIDR,
+			serviceCIDR: DefaultServiceCIDR,
+		},
+		{
+			name:        "cluster CIDR too small for nodes",
+			clusterCIDR: "10.42.0.0/22",
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  DefaultClusterDNS,
+			nodeCount:   5,
+			wantFields:  []string{"clusterCIDR"},
+		},
+		{
+			name:        "cluster CIDR exactly fits nodes",
+			clusterCIDR: "10.42.0.0/22",
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  DefaultClusterDNS,
+			nodeCount:   4,
+		},
+		{
+			name:        "cluster CIDR smaller than a node CIDR",
+			clusterCIDR: "10.42.0.0/25",
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  DefaultClusterDNS,
+			nodeCount:   1,
+			wantFields:  []string{"clusterCIDR"},
+		},
+		{
+			name:        "large IPv6 service CIDR",
+			clusterCIDR: "2001:cafe:42::/56",
+			serviceCIDR: "2001:cafe:43::/96",
+			clusterDNS:  "2001:cafe:43::a",
+		},
+		{
+			name:        "IPv6 service CIDR too large",
+			clusterCIDR: "2001:cafe:42::/56",
+			serviceCIDR: "2001:cafe::/48",
+			clusterDNS:  "2001:cafe::a",
+			wantFields:  []string{"serviceCIDR"},
+		},
+		{
+			name:        "cluster CIDR fitting nodes with a custom node mask size",
+			clusterCIDR: "10.42.0.0/22",
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  DefaultClusterDNS,
+			nodeCount:   8,
+			args:        map[string]string{"node-cidr-mask-size": "25"},
+		},
+		{
+			name:        "cluster CIDR too small for nodes with a custom IPv4 node mask size",
+			clusterCIDR: "10.42.0.0/22,2001:cafe:42::/56",
+			serviceCIDR: "10.43.0.0/16,2001:cafe:43::/112",
+			clusterDNS:  DefaultClusterDNS,
+			nodeCount:   4,
+			args:        map[string]string{"node-cidr-mask-size-ipv4": "23"},
+			wantFields:  []string{"clusterCIDR"},
+		},
+		{
+			name:        "small cluster CIDR without nodes",
+			clusterCIDR: "10.42.0.0/25",
+			serviceCIDR: DefaultServiceCIDR,
+			clusterDNS:  DefaultClusterDNS,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			networking := testNetworking(test.clusterCIDR, test.serviceCIDR, test.clusterDNS, test.nodeCount)
+			networking.SetNodeMaskSizes(test.args)
+			errList := ValidateClusterNetworking(networking)
+			var gotFields []string
+			for _, err := range errList {
+				assert.Equal(t, field.ErrorTypeInvalid, err.Type)
+				gotFields = append(gotFields, err.Field)
+			}
+			assert.Equal(t, test.wantFields, gotFields)
+		})
+	}
+}
+
+func TestValidateClusterNetworkingUpdate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		oldNetworking ClusterNetworking
+		networking    ClusterNetworking
+		wantFields    []string
+	}{
+		{
+			name:          "scaling up with an unchanged invalid cluster DNS",
+			oldNetworking: testNetworking(DefaultClusterCIDR, DefaultServiceCIDR, "10.44.0.10", 3),
+			networking:    testNetworking(DefaultClusterCIDR, DefaultServiceCIDR, "10.44.0.10", 5),
+		},
+		{
+			name:          "scaling up with unchanged overlapping CIDRs",
+			oldNetworking: testNetworking("10.0.0.0/8", DefaultServiceCIDR, DefaultClusterDNS, 3),
+			networking:    testNetworking("10.0.0.0/8", DefaultServiceCIDR, DefaultClusterDNS, 5),
+		},
+		{
+			name:          "changing the cluster DNS to an invalid one",
+			oldNetworking: testNetworking(DefaultClusterCIDR, DefaultServiceCIDR, DefaultClusterDNS, 3),
+			networking:    testNetworking(DefaultClusterCIDR, DefaultServiceCIDR, "10.44.0.10", 3),
+			wantFields:    []string{"clusterDNS"},
+		},
+		{
+			name:          "changing the service CIDR away from the cluster DNS",
+			oldNetworking: testNetworking(DefaultClusterCIDR, DefaultServiceCIDR, DefaultClusterDNS, 3),
+			networking:    testNetworking(DefaultClusterCIDR, "172.17.0.0/16", DefaultClusterDNS, 3),
+			wantFields:    []string{"clusterDNS"},
+		},
+		{
+			name:          "scaling up beyond the cluster CIDR capacity",
+			oldNetworking: testNetworking("10.42.0.0/23", DefaultServiceCIDR, DefaultClusterDNS, 2),
+			networking:    testNetworking("10.42.0.0/23", DefaultServiceCIDR, DefaultClusterDNS, 3),
+			wantFields:    []string{"clusterCIDR"},
+		},
+		{
+			name:          "scaling down a cluster beyond its cluster CIDR capacity",
+			oldNetworking: testNetworking("10.42.0.0/23", DefaultServiceCIDR, DefaultClusterDNS, 5),
+			networking:    testNetworking("10.42.0.0/23", DefaultServiceCIDR, DefaultClusterDNS, 4),
+		},
+		{
+			name:          "scaling up a cluster already beyond its cluster CIDR capacity",
+			oldNetworking: testNetworking("10.42.0.0/23", DefaultServiceCIDR, DefaultClusterDNS, 4),
+			networking:    testNetworking("10.42.0.0/23", DefaultServiceCIDR, DefaultClusterDNS, 5),
+			wantFields:    []string{"clusterCIDR"},
+		},
+		{
+			name:          "shrinking the cluster CIDR below the capacity",
+			oldNetworking: testNetworking("10.42.0.0/22", DefaultServiceCIDR, DefaultClusterDNS, 3),
+			networking:    testNetworking("10.42.0.0/23", DefaultServiceCIDR, DefaultClusterDNS, 3),
+			wantFields:    []string{"clusterCIDR"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var gotFields []string
+			for _, err := range ValidateClusterNetworkingUpdate(test.oldNetworking, test.networking) {
+				gotFields = append(gotFields, err.Field)
+			}
+			assert.Equal(t, test.wantFields, gotFields)
+		})
+	}
+}
+
+// testNetworking returns the networking configuration with the CIDR and DNS values at the paths of their names.
+func testNetworking(clusterCIDR, serviceCIDR, clusterDNS string, nodeCount int) ClusterNetworking {
+	return ClusterNetworking{
+		ClusterCIDR:     clusterCIDR,
+		ClusterCIDRPath: field.NewPath("clusterCIDR"),
+		ServiceCIDR:     serviceCIDR,
+		ServiceCIDRPath: field.NewPath("serviceCIDR"),
+		ClusterDNS:      clusterDNS,
+		ClusterDNSPath:  field.NewPath("clusterDNS"),
+		NodeCount:       nodeCount,
+	}
+}
+
+func TestDefaultClusterDNSFor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		serviceCIDR string
+		want        string
+	}{
+		{serviceCIDR: DefaultServiceCIDR, want: DefaultClusterDNS},
+		{serviceCIDR: "172.17.0.0/16", want: "172.17.0.10"},
+		{serviceCIDR: "10.96.0.0/12,2001:cafe:43::/112", want: "10.96.0.10"},
+		{serviceCIDR: "2001:cafe:43::/112", want: "2001:cafe:43::a"},
+		{serviceCIDR: "172.17.0.0", want: ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, DefaultClusterDNSFor(test.serviceCIDR), test.serviceCIDR)
+	}
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NormanShimsUntilEnv overrides the end of the grace period of the Norman annotation shims, as a date like
+	// 2027-06-30.
+	NormanShimsUntilEnv = "CATTLE_WEBHOOK_NORMAN_ANNOTATIONS_UNTIL"
+	// NormanShimsDateLayout is the layout of the end of the grace period.
+	NormanShimsDateLayout = time.DateOnly
+	// normanDescriptionAnnotation is the annotation Norman clients set the description of objects with.
+	normanDescriptionAnnotation = "field.cattle.io/description"
+)
+
+// defaultNormanShimsUntil is the end of the grace period of the Norman annotation shims when NormanShimsUntilEnv isn't
+// set.
+var defaultNormanShimsUntil = time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+
+// NormanAnnotationShim declares a legacy annotation, still set by old Norman clients, which holds the value of a spec
+// field. Mutators move the annotation to the field until the end of the grace period, and warn about it.
+type NormanAnnotationShim[T metav1.Object] struct {
+	// Annotation is the legacy annotation.
+	Annotation string
+	// FieldPath is the path of the field, used in warnings.
+	FieldPath string
+	// Field returns the field of the object the annotation is translated to.
+	Field func(obj T) *string
+}
+
+var (
+	// ClusterNormanShims are the legacy Norman annotations of management clusters.
+	ClusterNormanShims = []NormanAnnotationShim[*v3.Cluster]{
+		{
+			Annotation: normanDescriptionAnnotation,
+			FieldPath:  "spec.description",
+			Field:      func(cluster *v3.Cluster) *string { return &cluster.Spec.Description },
+		},
+	}
+	// ProjectNormanShims are the legacy Norman annotations of projects.
+	ProjectNormanShims = []NormanAnnotationShim[*v3.Project]{
+		{
+			Annotation: normanDescriptionAnnotation,
+			FieldPath:  "spec.description",
+			Field:      func(project *v3.Project) *string { return &project.Spec.Description },
+		},
+	}
+)
+
+// NormanShimsUntil returns the end of the grace period of the Norman annotation shims. An invalid NormanShimsUntilEnv
+// is ignored in favor of the default.
+func NormanShimsUntil() time.Time {
+	value := os.Getenv(NormanShimsUntilEnv)
+	if value == "" {
+		return defaultNormanShimsUntil
+	}
+	until, err := time.Parse(NormanShimsDateLayout, value)
+	if err != nil {
+		logrus.Warnf("ignoring invalid %s value %q, using %s", NormanShimsUntilEnv, value, defaultNormanShimsUntil.Format(NormanShimsDateLayout))
+		return defaultNormanShimsUntil
+	}
+	return until
+}
+
+// ApplyNormanShims moves the legacy Norman annotations of the shims on obj to their fields, and returns a deprecation
+// warning for each legacy annotation found. A field that is already set is kept, and the annotation dropped. After
+// the end of the grace period, the annotations are left untouched and ignored.
+func ApplyNormanShims[T metav1.Object](obj T, shims []NormanAnnotationShim[T]) []string {
+	return applyNormanShims(obj, shims, time.Now(), NormanShimsUntil())
+}
+
+func applyNormanShims[T metav1.Object](obj T, shims []NormanAnnotationShim[T], now, until time.Time) []string {
+	annotations := obj.GetAnnotations()
+	var warnings []string
+	for _, shim := range shims {
+		value, ok := annotations[shim.Annotation]
+		if !ok {
+			continue
+		}
+		date := until.Format(NormanShimsDateLayout)
+		if !now.Before(until) {
+			warnings = append(warnings, fmt.Sprintf("annotation %s is no longer supported since %s and is ignored, set %s instead",
+				shim.Annotation, date, shim.FieldPath))
+			continue
+		}
+		if target := shim.Field(obj); *target == "" {
+			*target = value
+		}
+		delete(annotations, shim.Annotation)
+		daysLeft := int(math.Ceil(until.Sub(now).Hours() / 24))
+		warnings = append(warnings, fmt.Sprintf("annotation %s is deprecated and translated to %s until %s (%d days left), set %s instead",
+			shim.Annotation, shim.FieldPath, date, daysLeft, shim.FieldPath))
+	}
+	if len(warnings) != 0 {
+		obj.SetAnnotations(annotations)
+	}
+	return warnings
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyNormanShims(t *testing.T) {
+	t.Parallel()
+	until := time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		now             time.Time
+		annotations     map[string]string
+		description     string
+		wantDescription string
+		wantAnnotations map[string]string
+		wantWarning     string
+	}{
+		{
+			name:            "no legacy annotation",
+			now:             until.Add(-time.Hour),
+			annotations:     map[string]string{"test": "value"},
+			wantAnnotations: map[string]string{"test": "value"},
+		},
+		{
+			name:            "legacy annotation translated",
+			now:             until.Add(-10 * 24 * time.Hour),
+			annotations:     map[string]string{"field.cattle.io/description": "from norman", "test": "value"},
+			wantDescription: "from norman",
+			wantAnnotations: map[string]string{"test": "value"},
+			wantWarning:     "annotation field.cattle.io/description is deprecated and translated to spec.description until 2027-06-30 (10 days left), set spec.description instead",
+		},
+		{
+			name:            "field already set",
+			now:             until.Add(-36 * time.Hour),
+			annotations:     map[string]string{"field.cattle.io/description": "from norman"},
+			description:     "from spec",
+			wantDescription: "from spec",
+			wantAnnotations: map[string]string{},
+			wantWarning:     "(2 days left)",
+		},
+		{
+			name:            "grace period ended",
+			now:             until,
+			annotations:     map[string]string{"field.cattle.io/description": "from norman"},
+			wantAnnotations: map[string]string{"field.cattle.io/description": "from norman"},
+			wantWarning:     "annotation field.cattle.io/description is no longer supported since 2027-06-30 and is ignored, set spec.description instead",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			project := &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations},
+				Spec:       v3.ProjectSpec{Description: test.description},
+			}
+			warnings := applyNormanShims(project, ProjectNormanShims, test.now, until)
+			assert.Equal(t, test.wantDescription, project.Spec.Description)
+			assert.Equal(t, test.wantAnnotations, project.Annotations)
+			if test.wantWarning == "" {
+				assert.Empty(t, warnings)
+				return
+			}
+			require.Len(t, warnings, 1)
+			assert.Contains(t, warnings[0], test.wantWarning)
+		})
+	}
+}
+
+func TestNormanShimsUntil(t *testing.T) {
+	t.Setenv(NormanShimsUntilEnv, "")
+	assert.Equal(t, defaultNormanShimsUntil, NormanShimsUntil())
+	t.Setenv(NormanShimsUntilEnv, "2028-01-31")
+	assert.Equal(t, time.Date(2028, time.January, 31, 0, 0, 0, 0, time.UTC), NormanShimsUntil())
+	t.Setenv(NormanShimsUntilEnv, "soon")
+	assert.Equal(t, defaultNormanShimsUntil, NormanShimsUntil())
+}
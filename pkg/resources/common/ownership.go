@@ -0,0 +1,192 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
+	"github.com/sirupsen/logrus"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+const (
+	// TransferOwnerToAnn is an annotation key set by an admin to request the transfer of an object's ownership to the given user.
+	TransferOwnerToAnn = "field.cattle.io/transfer-owner-to"
+	// OwnershipTransferAnn is an annotation key managed by the webhook that records the last ownership transfer of an object.
+	OwnershipTransferAnn = "webhook.cattle.io/ownership-transfer"
+
+	transferOwnershipVerb = "transfer-ownership"
+)
+
+// OwnershipTransfer is the record of an ownership transfer stored in the OwnershipTransferAnn annotation.
+type OwnershipTransfer struct {
+	// From is the creatorId before the transfer.
+	From string `json:"from,omitempty"`
+	// To is the creatorId after the transfer.
+	To string `json:"to"`
+	// By is the user that requested the transfer.
+	By string `json:"by"`
+}
+
+// OwnershipTransferer processes the TransferOwnerToAnn annotation on objects of a given resource.
+type OwnershipTransferer struct {
+	gvr        schema.GroupVersionResource
+	identities *identity.Resolver
+	sar        authorizationv1.SubjectAccessReviewInterface
+}
+
+// NewOwnershipTransferer returns a new OwnershipTransferer for objects of the given resource.
+func NewOwnershipTransferer(gvr schema.GroupVersionResource, identities *identity.Resolver, sar authorizationv1.SubjectAccessReviewInterface) *OwnershipTransferer {
+	return &OwnershipTransferer{
+		gvr:        gvr,
+		identities: identities,
+		sar:        sar,
+	}
+}
+
+// TransferOwnership mutates newObj on update if it has the TransferOwnerToAnn annotation. The target user must exist and
+// the requester must be allowed the transfer-ownership verb on the object. The creator annotations are then rewritten
+// for the target user, the transfer is recorded in the OwnershipTransferAnn annotation and the request annotation is removed.
+// Without a transfer request any change to the OwnershipTransferAnn annotation is reverted, since only the webhook may write it.
+// A non-nil status is returned if the transfer is denied.
+func (t *OwnershipTransferer) TransferOwnership(request *admission.Request, oldObj, newObj metav1.Object) (*metav1.Status, error) {
+	annotations := newObj.GetAnnotations()
+	target, ok := annotations[TransferOwnerToAnn]
+	if !ok {
+		if oldRecord, ok := oldObj.GetAnnotations()[OwnershipTransferAnn]; ok {
+			if annotations == nil {
+				annotations = map[string]string{}
+				newObj.SetAnnotations(annotations)
+			}
+			annotations[OwnershipTransferAnn] = oldRecord
+		} else {
+			delete(annotations, OwnershipTransferAnn)
+		}
+		return nil, nil
+	}
+
+	if target == "" {
+		return &metav1.Status{
+			Status:  "Failure",
+			Message: fmt.Sprintf("annotation %s must be set to the id of the new owner", TransferOwnerToAnn),
+			Reason:  metav1.StatusReasonBadRequest,
+			Code:    http.StatusBadRequest,
+		}, nil
+	}
+
+	owner, err := t.identities.User(target)
+	if err != nil {
+		if errors.Is(err, identity.ErrUserNotFound) {
+			return &metav1.Status{
+				Status:  "Failure",
+				Message: fmt.Sprintf("can't transfer ownership to user %s: user doesn't exist", target),
+				Reason:  metav1.StatusReasonBadRequest,
+				Code:    http.StatusBadRequest,
+			}, nil
+		}
+		return nil, err
+	}
+
+	if status, err := t.authorize(request, newObj); status != nil || err != nil {
+		return status, err
+	}
+
+	record, err := json.Marshal(OwnershipTransfer{
+		From: oldObj.GetAnnotations()[CreatorIDAnn],
+		To:   target,
+		By:   request.UserInfo.Username,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ownership transfer record: %w", err)
+	}
+
+	delete(annotations, TransferOwnerToAnn)
+	delete(annotations, NoCreatorRBACAnn)
+	annotations[CreatorIDAnn] = target
+	if principal := owner.PrimaryPrincipal(); principal != "" {
+		annotations[CreatorPrincipalNameAnn] = principal
+	} else {
+		delete(annotations, CreatorPrincipalNameAnn)
+	}
+	annotations[OwnershipTransferAnn] = string(record)
+	// the new owner becomes the value creator annotations can be restored to
+	if err := SetOriginalCreatorAnnotations(newObj); err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("[ownership-transfer] user %s transferred ownership of %s %s from %q to %q", request.UserInfo.Username, t.gvr.Resource, objectName(newObj), oldObj.GetAnnotations()[CreatorIDAnn], target)
+	return nil, nil
+}
+
+// VerifyOwnershipTransfer returns true if the update records a new ownership transfer to the creatorId of the new
+// object which the requester made and is allowed to make. The record is written by the OwnershipTransferer of the
+// mutating webhook, but the validator can't rely on it having run, so the transfer is checked again.
+func (t *OwnershipTransferer) VerifyOwnershipTransfer(request *admission.Request, oldObj, newObj metav1.Object) (bool, error) {
+	transfer, ok := ownershipTransferOf(oldObj, newObj)
+	if !ok || transfer.By != request.UserInfo.Username {
+		return false, nil
+	}
+	if _, err := t.identities.User(transfer.To); err != nil {
+		if errors.Is(err, identity.ErrUserNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	status, err := t.authorize(request, newObj)
+	if err != nil {
+		return false, err
+	}
+	return status == nil, nil
+}
+
+// ownershipTransferOf returns the new ownership transfer the update records, if it transfers the ownership to the
+// creatorId of the new object.
+func ownershipTransferOf(oldObj, newObj metav1.Object) (OwnershipTransfer, bool) {
+	var transfer OwnershipTransfer
+	record, ok := newObj.GetAnnotations()[OwnershipTransferAnn]
+	if !ok || record == oldObj.GetAnnotations()[OwnershipTransferAnn] {
+		return transfer, false
+	}
+	if err := json.Unmarshal([]byte(record), &transfer); err != nil {
+		return transfer, false
+	}
+	return transfer, transfer.To != "" && transfer.To == newObj.GetAnnotations()[CreatorIDAnn]
+}
+
+// authorize returns a non-nil status if the requester isn't allowed the transfer-ownership verb on the object.
+func (t *OwnershipTransferer) authorize(request *admission.Request, obj metav1.Object) (*metav1.Status, error) {
+	resp, err := t.sar.Create(request.Context, &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authzv1.ResourceAttributes{
+				Verb:      transferOwnershipVerb,
+				Group:     t.gvr.Group,
+				Version:   t.gvr.Version,
+				Resource:  t.gvr.Resource,
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+			},
+			User:   request.UserInfo.Username,
+			Groups: request.UserInfo.Groups,
+			Extra:  ConvertAuthnExtras(request.UserInfo.Extra),
+			UID:    request.UserInfo.UID,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check SubjectAccessReview for %s %s: %w", t.gvr.Resource, objectName(obj), err)
+	}
+	if !resp.Status.Allowed {
+		return &metav1.Status{
+			Status:  "Failure",
+			Message: fmt.Sprintf("user %s is not allowed to %s of %s %s", request.UserInfo.Username, transferOwnershipVerb, t.gvr.Resource, objectName(obj)),
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,193 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+func TestTransferOwnership(t *testing.T) {
+	t.Parallel()
+	gvr := schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"}
+	tests := []struct {
+		name           string
+		oldAnnotations map[string]string
+		newAnnotations map[string]string
+		sarAllowed     bool
+		sarErr         error
+		wantCode       int32
+		wantErr        bool
+		want           map[string]string
+	}{
+		{
+			name:           "no transfer requested",
+			oldAnnotations: map[string]string{CreatorIDAnn: "u-old"},
+			newAnnotations: map[string]string{CreatorIDAnn: "u-old"},
+			want:           map[string]string{CreatorIDAnn: "u-old"},
+		},
+		{
+			name:           "no transfer requested and no annotations",
+			newAnnotations: nil,
+		},
+		{
+			name:           "forged transfer record is removed",
+			oldAnnotations: map[string]string{CreatorIDAnn: "u-old"},
+			newAnnotations: map[string]string{CreatorIDAnn: "u-new", OwnershipTransferAnn: `{"to":"u-new","by":"me"}`},
+			want:           map[string]string{CreatorIDAnn: "u-new"},
+		},
+		{
+			name:           "forged transfer record is reverted",
+			oldAnnotations: map[string]string{OwnershipTransferAnn: `{"to":"u-old","by":"admin"}`},
+			newAnnotations: nil,
+			want:           map[string]string{OwnershipTransferAnn: `{"to":"u-old","by":"admin"}`},
+		},
+		{
+			name:           "empty target",
+			oldAnnotations: map[string]string{CreatorIDAnn: "u-old"},
+			newAnnotations: map[string]string{CreatorIDAnn: "u-old", TransferOwnerToAnn: ""},
+			wantCode:       http.StatusBadRequest,
+		},
+		{
+			name:           "target user doesn't exist",
+			oldAnnotations: map[string]string{CreatorIDAnn: "u-old"},
+			newAnnotations: map[string]string{CreatorIDAnn: "u-old", TransferOwnerToAnn: "u-missing"},
+			wantCode:       http.StatusBadRequest,
+		},
+		{
+			name:           "user cache error",
+			oldAnnotations: map[string]string{CreatorIDAnn: "u-old"},
+			newAnnotations: map[string]string{CreatorIDAnn: "u-old", TransferOwnerToAnn: "u-error"},
+			wantErr:        true,
+		},
+		{
+			name:           "requester not allowed",
+			oldAnnotations: map[string]string{CreatorIDAnn: "u-old"},
+			newAnnotations: map[string]string{CreatorIDAnn: "u-old", TransferOwnerToAnn: "u-new"},
+			wantCode:       http.StatusForbidden,
+		},
+		{
+			name:           "sar error",
+			oldAnnotations: map[string]string{CreatorIDAnn: "u-old"},
+			newAnnotations: map[string]string{CreatorIDAnn: "u-old", TransferOwnerToAnn: "u-new"},
+			sarErr:         fmt.Errorf("sar error"),
+			wantErr:        true,
+		},
+		{
+			name: "ownership transferred",
+			oldAnnotations: map[string]string{
+				CreatorIDAnn:                  "u-old",
+				CreatorPrincipalNameAnn:       "local://u-old",
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-old"}`,
+			},
+			newAnnotations: map[string]string{
+				CreatorIDAnn:                  "u-old",
+				CreatorPrincipalNameAnn:       "local://u-old",
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-old"}`,
+				TransferOwnerToAnn:            "u-new",
+			},
+			sarAllowed: true,
+			want: map[string]string{
+				CreatorIDAnn:                  "u-new",
+				CreatorPrincipalNameAnn:       "local://u-new",
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creator-principal-name":"local://u-new","field.cattle.io/creatorId":"u-new"}`,
+				OwnershipTransferAnn:          `{"from":"u-old","to":"u-new","by":"admin"}`,
+			},
+		},
+		{
+			name:           "ownership transferred from an object without creator rbac to a user without principals",
+			oldAnnotations: map[string]string{NoCreatorRBACAnn: "true"},
+			newAnnotations: map[string]string{NoCreatorRBACAnn: "true", TransferOwnerToAnn: "u-noprincipal"},
+			sarAllowed:     true,
+			want: map[string]string{
+				CreatorIDAnn:                  "u-noprincipal",
+				OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-noprincipal"}`,
+				OwnershipTransferAnn:          `{"to":"u-noprincipal","by":"admin"}`,
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			userCache := fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl)
+			userCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.User, error) {
+				switch name {
+				case "u-new":
+					return &v3.User{ObjectMeta: metav1.ObjectMeta{Name: name}, PrincipalIDs: []string{"local://u-new"}}, nil
+				case "u-noprincipal":
+					return &v3.User{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+				case "u-error":
+					return nil, fmt.Errorf("cache error")
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+			}).AnyTimes()
+
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				attributes := review.Spec.ResourceAttributes
+				assert.Equal(t, "transfer-ownership", attributes.Verb)
+				assert.Equal(t, "projects", attributes.Resource)
+				assert.Equal(t, "p-abcde", attributes.Name)
+				assert.Equal(t, "c-abcde", attributes.Namespace)
+				assert.Equal(t, "admin", review.Spec.User)
+				review.Status.Allowed = test.sarAllowed
+				return true, review, test.sarErr
+			})
+			sar := (&k8fake.FakeAuthorizationV1{Fake: k8Fake}).SubjectAccessReviews()
+
+			oldObj := &v3.Project{ObjectMeta: metav1.ObjectMeta{Name: "p-abcde", Namespace: "c-abcde", Annotations: test.oldAnnotations}}
+			newObj := &v3.Project{ObjectMeta: metav1.ObjectMeta{Name: "p-abcde", Namespace: "c-abcde", Annotations: test.newAnnotations}}
+			request := &admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					UserInfo:  authenticationv1.UserInfo{Username: "admin"},
+				},
+			}
+
+			transferer := NewOwnershipTransferer(gvr, identity.NewResolver(userCache), sar)
+			status, err := transferer.TransferOwnership(request, oldObj, newObj)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if test.wantCode != 0 {
+				require.NotNil(t, status)
+				assert.Equal(t, test.wantCode, status.Code)
+				return
+			}
+			assert.Nil(t, status)
+			if test.want == nil {
+				assert.Empty(t, newObj.Annotations)
+				return
+			}
+			assert.Equal(t, test.want, newObj.Annotations)
+			if _, ok := test.newAnnotations[TransferOwnerToAnn]; ok {
+				fieldErr, err := CheckCreatorAnnotationsOnUpdate(request, transferer, oldObj, newObj)
+				require.NoError(t, err)
+				assert.Nil(t, fieldErr, "transfer should pass validation")
+			}
+		})
+	}
+}
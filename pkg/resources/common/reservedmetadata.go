@@ -0,0 +1,170 @@
+package common
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+)
+
+const (
+	// systemNamespace is the namespace of the service accounts Rancher and its agents run as.
+	systemNamespace = "cattle-system"
+	systemMasters   = "system:masters"
+)
+
+var (
+	// ReservedMetadataPrefixes are the prefixes of the label and annotation keys reserved to Rancher.
+	ReservedMetadataPrefixes = []string{"cattle.io/"}
+	// ReservedMetadataDomains are the domains whose label and annotation keys, including those of their subdomains like
+	// authz.management.cattle.io, are reserved to Rancher.
+	ReservedMetadataDomains = []string{"management.cattle.io"}
+)
+
+var reservedMetadataRule = rules.Register(rules.Rule{
+	ID:            "reserved-metadata",
+	GVR:           schema.GroupVersionResource{Group: "*", Version: "*", Resource: "*"},
+	Description:   "Labels and annotations with the cattle.io/ prefix, or in the management.cattle.io domain and its subdomains like authz.management.cattle.io, are reserved to Rancher, so that system metadata, like the labels marking system projects and namespaces, can't be spoofed. Only Rancher's service accounts, Kubernetes components and members of system:masters can add or change reserved keys, except the keys meant to be set by users, which their own rules validate. Removing a reserved key isn't checked.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "metadata.labels[authz.management.cattle.io/system-project]: Forbidden: key is reserved to Rancher and can't be set by user u-abc12",
+	DenialCode:    string(admission.DenialForbidden),
+})
+
+// userReservedMetadata holds the reserved keys users can set.
+var userReservedMetadata = struct {
+	mutex sync.RWMutex
+	keys  map[string]bool
+}{keys: map[string]bool{"cattle.io/force": true}}
+
+// RegisterReservedMetadataKeys allows users to set the given reserved label or annotation keys, which the handlers
+// registering them validate.
+func RegisterReservedMetadataKeys(keys ...string) {
+	userReservedMetadata.mutex.Lock()
+	defer userReservedMetadata.mutex.Unlock()
+	for _, key := range keys {
+		userReservedMetadata.keys[key] = true
+	}
+}
+
+// isReservedMetadataKey returns whether the key is reserved to Rancher and not registered for users.
+func isReservedMetadataKey(key string) bool {
+	userReservedMetadata.mutex.RLock()
+	registered := userReservedMetadata.keys[key]
+	userReservedMetadata.mutex.RUnlock()
+	if registered {
+		return false
+	}
+	for _, prefix := range ReservedMetadataPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	keyDomain, _, ok := strings.Cut(key, "/")
+	if !ok {
+		return false
+	}
+	for _, domain := range ReservedMetadataDomains {
+		if keyDomain == domain || strings.HasSuffix(keyDomain, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSystemUser returns whether the user is one of Rancher's service accounts, a Kubernetes component, or a member of
+// system:masters.
+func isSystemUser(info *identity.Identity) bool {
+	if info.InGroup(systemMasters) {
+		return true
+	}
+	if namespace, _, err := serviceaccount.SplitUsername(info.Name); err == nil {
+		return namespace == systemNamespace
+	}
+	return strings.HasPrefix(info.Name, "system:")
+}
+
+// ReservedMetadataAdmitter denies the labels and annotations with reserved prefixes added or changed by users. It
+// checks the metadata of any resource, so it is added to the admitters of all validators.
+type ReservedMetadataAdmitter struct{}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (r *ReservedMetadataAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return admission.ResponseAllowed(), nil
+	}
+	id := identity.FromUserInfo(request.UserInfo)
+	if isSystemUser(id) {
+		return admission.ResponseAllowed(), nil
+	}
+	oldObj, newObj, err := admission.MetadataFromRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if request.Operation == admissionv1.Create {
+		oldObj = &metav1.PartialObjectMetadata{}
+	}
+
+	metadataPath := field.NewPath("metadata")
+	errList := reservedKeysSet(metadataPath.Child("labels"), oldObj.Labels, newObj.Labels, id.Name)
+	errList = append(errList, reservedKeysSet(metadataPath.Child("annotations"), oldObj.Annotations, newObj.Annotations, id.Name)...)
+	if len(errList) == 0 {
+		return admission.ResponseAllowed(), nil
+	}
+	message := reservedMetadataRule.Message("%s", errList.ToAggregate().Error())
+	return admission.ResponseForRule(reservedMetadataRule, nil, message), nil
+}
+
+// reservedKeysSet returns an error for each reserved key added or changed.
+func reservedKeysSet(fldPath *field.Path, oldMetadata, newMetadata map[string]string, username string) field.ErrorList {
+	keys := make([]string, 0, len(newMetadata))
+	for key, value := range newMetadata {
+		if oldValue, ok := oldMetadata[key]; (!ok || oldValue != value) && isReservedMetadataKey(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var errList field.ErrorList
+	for _, key := range keys {
+		errList = append(errList, field.Forbidden(fldPath.Key(key), "key is reserved to Rancher and can't be set by user "+username))
+	}
+	return errList
+}
+
+// reservedMetadataValidator adds the ReservedMetadataAdmitter to the admitters of a validator.
+type reservedMetadataValidator struct {
+	admission.ValidatingAdmissionHandler
+}
+
+// Admitters returns the ReservedMetadataAdmitter followed by the admitters of the validator.
+func (r *reservedMetadataValidator) Admitters() []admission.Admitter {
+	return append([]admission.Admitter{&ReservedMetadataAdmitter{}}, r.ValidatingAdmissionHandler.Admitters()...)
+}
+
+// Unwrap returns the decorated validator.
+func (r *reservedMetadataValidator) Unwrap() admission.WebhookHandler {
+	return r.ValidatingAdmissionHandler
+}
+
+// Reset resets the shared resources of the validator, if it has any.
+func (r *reservedMetadataValidator) Reset() {
+	if resetter, ok := r.ValidatingAdmissionHandler.(admission.Resetter); ok {
+		resetter.Reset()
+	}
+}
+
+// WithReservedMetadataCheck returns the validators, which also deny reserved labels and annotations set by users.
+func WithReservedMetadataCheck(validators []admission.ValidatingAdmissionHandler) []admission.ValidatingAdmissionHandler {
+	wrapped := make([]admission.ValidatingAdmissionHandler, 0, len(validators))
+	for _, validator := range validators {
+		wrapped = append(wrapped, &reservedMetadataValidator{ValidatingAdmissionHandler: validator})
+	}
+	return wrapped
+}
@@ -0,0 +1,188 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestReservedMetadataAdmitter(t *testing.T) {
+	t.Parallel()
+	RegisterReservedMetadataKeys("management.cattle.io/reserved-test-key")
+	user := authenticationv1.UserInfo{Username: "u-abc12"}
+	tests := []struct {
+		name           string
+		operation      admissionv1.Operation
+		user           authenticationv1.UserInfo
+		oldLabels      map[string]string
+		newLabels      map[string]string
+		newAnnotations map[string]string
+		wantMessage    string
+	}{
+		{
+			name:        "user adds a reserved label",
+			operation:   admissionv1.Create,
+			user:        user,
+			newLabels:   map[string]string{"authz.management.cattle.io/system-project": "true"},
+			wantMessage: "metadata.labels[authz.management.cattle.io/system-project]: Forbidden: key is reserved to Rancher and can't be set by user u-abc12",
+		},
+		{
+			name:        "user adds a label of the reserved domain",
+			operation:   admissionv1.Create,
+			user:        user,
+			newLabels:   map[string]string{"management.cattle.io/cluster-tier": "gold"},
+			wantMessage: "metadata.labels[management.cattle.io/cluster-tier]",
+		},
+		{
+			name:           "user adds an annotation of a subdomain of the reserved domain",
+			operation:      admissionv1.Create,
+			user:           user,
+			newAnnotations: map[string]string{"lifecycle.management.cattle.io/create.project-rbac": "true"},
+			wantMessage:    "metadata.annotations[lifecycle.management.cattle.io/create.project-rbac]",
+		},
+		{
+			name:           "user adds a reserved annotation",
+			operation:      admissionv1.Create,
+			user:           user,
+			newAnnotations: map[string]string{"cattle.io/status": "{}"},
+			wantMessage:    "metadata.annotations[cattle.io/status]",
+		},
+		{
+			name:        "user changes a reserved label",
+			operation:   admissionv1.Update,
+			user:        user,
+			oldLabels:   map[string]string{"cattle.io/creator": "norman"},
+			newLabels:   map[string]string{"cattle.io/creator": "u-abc12"},
+			wantMessage: "metadata.labels[cattle.io/creator]",
+		},
+		{
+			name:      "user keeps a reserved label",
+			operation: admissionv1.Update,
+			user:      user,
+			oldLabels: map[string]string{"cattle.io/creator": "norman"},
+			newLabels: map[string]string{"cattle.io/creator": "norman", "app": "test"},
+		},
+		{
+			name:      "user removes a reserved label",
+			operation: admissionv1.Update,
+			user:      user,
+			oldLabels: map[string]string{"cattle.io/creator": "norman"},
+		},
+		{
+			name:      "user sets a registered key",
+			operation: admissionv1.Create,
+			user:      user,
+			newLabels: map[string]string{"management.cattle.io/reserved-test-key": "true"},
+		},
+		{
+			name:           "user sets other cattle.io keys",
+			operation:      admissionv1.Create,
+			user:           user,
+			newLabels:      map[string]string{"field.cattle.io/projectId": "p-abc12"},
+			newAnnotations: map[string]string{"lifecycle.cattle.io/create.test": "true"},
+		},
+		{
+			name:      "user sets keys of a domain only ending like the reserved domain",
+			operation: admissionv1.Create,
+			user:      user,
+			newLabels: map[string]string{"notmanagement.cattle.io/test": "true"},
+		},
+		{
+			name:      "rancher service account",
+			operation: admissionv1.Create,
+			user:      authenticationv1.UserInfo{Username: "system:serviceaccount:cattle-system:rancher"},
+			newLabels: map[string]string{"authz.management.cattle.io/system-project": "true"},
+		},
+		{
+			name:        "service account of another namespace",
+			operation:   admissionv1.Create,
+			user:        authenticationv1.UserInfo{Username: "system:serviceaccount:default:app"},
+			newLabels:   map[string]string{"authz.management.cattle.io/system-project": "true"},
+			wantMessage: "can't be set by user system:serviceaccount:default:app",
+		},
+		{
+			name:      "kubernetes component",
+			operation: admissionv1.Create,
+			user:      authenticationv1.UserInfo{Username: "system:kube-controller-manager"},
+			newLabels: map[string]string{"authz.management.cattle.io/system-project": "true"},
+		},
+		{
+			name:      "member of system:masters",
+			operation: admissionv1.Create,
+			user:      authenticationv1.UserInfo{Username: "admin", Groups: []string{"system:masters"}},
+			newLabels: map[string]string{"authz.management.cattle.io/system-project": "true"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			newNamespace := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: test.newLabels, Annotations: test.newAnnotations}}
+			oldNamespace := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: test.oldLabels}}
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: test.operation,
+				UserInfo:  test.user,
+			}}
+			var err error
+			request.Object.Raw, err = json.Marshal(&newNamespace)
+			require.NoError(t, err)
+			request.OldObject.Raw, err = json.Marshal(&oldNamespace)
+			require.NoError(t, err)
+
+			admitter := ReservedMetadataAdmitter{}
+			response, err := admitter.Admit(request)
+			require.NoError(t, err)
+			if test.wantMessage == "" {
+				assert.True(t, response.Allowed, response.Result)
+				return
+			}
+			assert.False(t, response.Allowed)
+			assert.Equal(t, int32(http.StatusForbidden), response.Result.Code)
+			assert.Contains(t, response.Result.Message, test.wantMessage)
+			assert.Contains(t, response.Result.Message, "/rules/reserved-metadata")
+		})
+	}
+}
+
+// testValidator is a validator with a single admitter.
+type testValidator struct {
+	admitter admission.Admitter
+}
+
+func (v *testValidator) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+}
+
+func (v *testValidator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+}
+
+func (v *testValidator) ValidatingWebhook(admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return nil
+}
+
+func (v *testValidator) Admitters() []admission.Admitter {
+	return []admission.Admitter{v.admitter}
+}
+
+func TestWithReservedMetadataCheck(t *testing.T) {
+	t.Parallel()
+	inner := &testValidator{admitter: &ReservedMetadataAdmitter{}}
+	validators := WithReservedMetadataCheck([]admission.ValidatingAdmissionHandler{inner})
+	require.Len(t, validators, 1)
+	assert.Equal(t, inner.GVR(), validators[0].GVR())
+	admitters := validators[0].Admitters()
+	require.Len(t, admitters, 2)
+	assert.IsType(t, &ReservedMetadataAdmitter{}, admitters[0])
+	assert.Same(t, inner.admitter, admitters[1])
+}
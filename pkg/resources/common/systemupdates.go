@@ -0,0 +1,65 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IsSystemUpdate returns whether the request is an update by one of Rancher's service accounts or a Kubernetes
+// component which doesn't change any of the guarded fields, given as dotted paths like "metadata.annotations".
+//
+// Controllers doing a read-modify-write of a stale object to update its status resubmit the rest of the object as they
+// read it, which can trip validations of fields they never meant to change. The guarded fields of the old and new
+// objects are compared directly: the managed fields are set by clients, so they can't tell what an update changed.
+func IsSystemUpdate(request *admission.Request, oldObj, newObj metav1.Object, guarded ...string) bool {
+	if request.Operation != admissionv1.Update || !isSystemUser(identity.FromUserInfo(request.UserInfo)) {
+		return false
+	}
+	oldContent, err := objectContent(oldObj)
+	if err != nil {
+		return false
+	}
+	newContent, err := objectContent(newObj)
+	if err != nil {
+		return false
+	}
+	for _, path := range guarded {
+		keys := strings.Split(path, ".")
+		if !reflect.DeepEqual(fieldAt(oldContent, keys), fieldAt(newContent, keys)) {
+			return false
+		}
+	}
+	return true
+}
+
+// objectContent returns the object decoded as a JSON object.
+func objectContent(obj metav1.Object) (map[string]any, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var content map[string]any
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// fieldAt returns the value at the path of the decoded JSON object, nil if it isn't set.
+func fieldAt(content map[string]any, path []string) any {
+	var value any = content
+	for _, key := range path {
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = fields[key]
+	}
+	return value
+}
@@ -0,0 +1,105 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsSystemUpdate(t *testing.T) {
+	t.Parallel()
+	const rancher = "system:serviceaccount:cattle-system:rancher"
+	configMap := func(creator string, data map[string]string) *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Data: data}
+		if creator != "" {
+			configMap.Annotations = map[string]string{"field.cattle.io/creatorId": creator}
+		}
+		return configMap
+	}
+	labeled := configMap("u-1", map[string]string{"a": "1"})
+	labeled.Labels = map[string]string{"b": "2"}
+	withManager := func(configMap *corev1.ConfigMap, manager string) *corev1.ConfigMap {
+		configMap.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: manager, Operation: metav1.ManagedFieldsOperationUpdate}}
+		return configMap
+	}
+	tests := []struct {
+		name      string
+		username  string
+		operation admissionv1.Operation
+		oldObj    *corev1.ConfigMap
+		newObj    *corev1.ConfigMap
+		want      bool
+	}{
+		{
+			name:     "update by rancher leaving the guarded fields",
+			username: rancher,
+			oldObj:   configMap("u-1", map[string]string{"a": "1"}),
+			newObj:   labeled,
+			want:     true,
+		},
+		{
+			name:     "update by a kubernetes component",
+			username: "system:kube-controller-manager",
+			oldObj:   configMap("u-1", nil),
+			newObj:   configMap("u-1", nil),
+			want:     true,
+		},
+		{
+			name:     "update changing a guarded annotation",
+			username: rancher,
+			oldObj:   configMap("u-1", nil),
+			newObj:   configMap("u-2", nil),
+		},
+		{
+			name:     "update changing a guarded annotation without changing the managed fields",
+			username: rancher,
+			oldObj:   withManager(configMap("u-1", nil), "kubectl"),
+			newObj:   withManager(configMap("u-2", nil), "kubectl"),
+		},
+		{
+			name:     "update adding a guarded field",
+			username: rancher,
+			oldObj:   configMap("u-1", nil),
+			newObj:   configMap("u-1", map[string]string{"a": "1"}),
+		},
+		{
+			name:     "update by a user",
+			username: "u-12345",
+			oldObj:   configMap("u-1", nil),
+			newObj:   configMap("u-1", nil),
+		},
+		{
+			name:     "service account of another namespace",
+			username: "system:serviceaccount:default:controller",
+			oldObj:   configMap("u-1", nil),
+			newObj:   configMap("u-1", nil),
+		},
+		{
+			name:      "create",
+			username:  rancher,
+			operation: admissionv1.Create,
+			oldObj:    &corev1.ConfigMap{},
+			newObj:    configMap("u-1", nil),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			operation := test.operation
+			if operation == "" {
+				operation = admissionv1.Update
+			}
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: operation,
+				UserInfo:  authenticationv1.UserInfo{Username: test.username},
+			}}
+			assert.Equal(t, test.want, IsSystemUpdate(request, test.oldObj, test.newObj, "metadata.annotations", "data"))
+		})
+	}
+}
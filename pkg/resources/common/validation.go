@@ -1,15 +1,17 @@
 package common
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/rancher/webhook/pkg/admission"
-	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/identity"
+	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/kubernetes/pkg/apis/rbac"
@@ -41,9 +43,19 @@ func CheckCreatorID(request *admission.Request, oldObj, newObj metav1.Object) *m
 			status.Message = "creatorID annotation does not match user"
 			return status
 		}
+		if fieldErr := CheckOriginalCreatorAnnotationsOnCreate(newObj); fieldErr != nil {
+			status.Message = fieldErr.Error()
+			return status
+		}
 		return nil
 	}
 
+	// The record of the original creator annotations is managed by the webhook and can only be removed.
+	if record, ok := newAnnotations[OriginalCreatorAnnotationsAnn]; ok && oldObj.GetAnnotations()[OriginalCreatorAnnotationsAnn] != record {
+		status.Message = "original creator annotations cannot be changed"
+		return status
+	}
+
 	// Check that the anno doesn't exist on the update object, the only allowed
 	// update to this field is deleting it.
 	if _, ok := newAnnotations[CreatorIDAnn]; !ok {
@@ -51,12 +63,15 @@ func CheckCreatorID(request *admission.Request, oldObj, newObj metav1.Object) *m
 	}
 
 	// Compare old vs new because they need to be the same, no updates are allowed for
-	// the CreatorIDAnn
+	// the CreatorIDAnn, except restoring it to the value the object was created with.
 	if oldObj.GetAnnotations()[CreatorIDAnn] != newAnnotations[CreatorIDAnn] {
-		status.Message = "creatorID annotation cannot be changed"
-		return status
+		if !isCreatorAnnotationRestore(oldObj, newObj, CreatorIDAnn) {
+			status.Message = "creatorID annotation cannot be changed"
+			return status
+		}
 	}
 
+	logCreatorAnnotationRestores(oldObj, newObj, CreatorIDAnn)
 	return nil
 }
 
@@ -75,8 +90,9 @@ func ValidateRules(rules []rbacv1.PolicyRule, isNamespaced bool, fldPath *field.
 var annotationsFieldPath = field.NewPath("metadata").Child("annotations")
 
 // CheckCreatorPrincipalName checks that if creator-principal-name annotation is set then creatorId annotation must be set as well.
-// The value of creator-principal-name annotation should match the creator's user principal id.
-func CheckCreatorPrincipalName(userCache controllerv3.UserCache, obj metav1.Object) (*field.Error, error) {
+// The value of creator-principal-name annotation should match the creator's user principal id, or be verified by the
+// principal verifier of the resolver.
+func CheckCreatorPrincipalName(ctx context.Context, identities *identity.Resolver, obj metav1.Object) (*field.Error, error) {
 	annotations := obj.GetAnnotations()
 	principalName := annotations[CreatorPrincipalNameAnn]
 	if principalName == "" { // Nothing to check.
@@ -88,51 +104,125 @@ func CheckCreatorPrincipalName(userCache controllerv3.UserCache, obj metav1.Obje
 		return field.Invalid(annotationsFieldPath, CreatorPrincipalNameAnn, fmt.Sprintf("annotation %s is required", CreatorIDAnn)), nil
 	}
 
-	user, err := userCache.Get(creatorID)
+	verified, err := identities.VerifyPrincipal(ctx, creatorID, principalName)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
+		if errors.Is(err, identity.ErrUserNotFound) {
 			return field.Invalid(annotationsFieldPath, CreatorPrincipalNameAnn, fmt.Sprintf("creator user %s doesn't exist", creatorID)), nil
 		}
 		return nil, fmt.Errorf("error getting creator user %s: %w", creatorID, err)
 	}
 
-	for _, principal := range user.PrincipalIDs {
-		if principal == principalName {
-			return nil, nil
-		}
+	if verified {
+		return nil, nil
 	}
 
 	return field.Invalid(annotationsFieldPath, CreatorPrincipalNameAnn, fmt.Sprintf("creator user %s doesn't have principal %s", creatorID, principalName)), nil
 }
 
 // CheckCreatorAnnotationsOnUpdate checks that the creatorId, creator-principal-name, and no-creator-rbac annotations are immutable.
-// The only allowed update is removing the annotations.
+// The only allowed updates are removing the annotations and restoring a removed annotation to the value
+// recorded in the webhook-managed original-creator-annotations annotation, as well as an ownership transfer
+// recorded by the OwnershipTransferer and verified again by the given one. Without an OwnershipTransferer, no
+// ownership transfer is allowed.
 // This function should only be called for the update operation.
-func CheckCreatorAnnotationsOnUpdate(oldObj, newObj metav1.Object) *field.Error {
+func CheckCreatorAnnotationsOnUpdate(request *admission.Request, ownership *OwnershipTransferer, oldObj, newObj metav1.Object) (*field.Error, error) {
 	oldAnnotations := oldObj.GetAnnotations()
 	newAnnotations := newObj.GetAnnotations()
 
-	for _, annotation := range []string{CreatorIDAnn, CreatorPrincipalNameAnn, NoCreatorRBACAnn} {
+	// The transfer request is consumed by the mutating webhook, so it must never reach the validator.
+	if _, ok := newAnnotations[TransferOwnerToAnn]; ok {
+		return field.Invalid(annotationsFieldPath, TransferOwnerToAnn, "ownership transfer was not processed by the webhook"), nil
+	}
+
+	// A sanctioned ownership transfer rewrites the creator annotations and their original values.
+	if ownership != nil {
+		transferred, err := ownership.VerifyOwnershipTransfer(request, oldObj, newObj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify ownership transfer: %w", err)
+		}
+		if transferred {
+			return nil, nil
+		}
+	}
+
+	// The record of the ownership transfers is managed by the webhook and only changed by verified transfers.
+	oldRecord, oldOk := oldAnnotations[OwnershipTransferAnn]
+	if newRecord, newOk := newAnnotations[OwnershipTransferAnn]; oldOk != newOk || oldRecord != newRecord {
+		return field.Invalid(annotationsFieldPath, OwnershipTransferAnn, "ownership transfer can't be verified"), nil
+	}
+
+	for _, annotation := range creatorAnnotations {
 		if _, ok := newAnnotations[annotation]; ok {
 			// If the annotation exists on the new object it must be the same as on the old object.
 			if oldAnnotations[annotation] != newAnnotations[annotation] {
-				return field.Invalid(annotationsFieldPath, annotation, "annotation is immutable")
+				if !isCreatorAnnotationRestore(oldObj, newObj, annotation) {
+					return field.Invalid(annotationsFieldPath, annotation, "annotation is immutable"), nil
+				}
 			}
 		}
 	}
 
-	return nil
+	// The record of the original values is managed by the webhook and can only be removed.
+	if _, ok := newAnnotations[OriginalCreatorAnnotationsAnn]; ok && oldAnnotations[OriginalCreatorAnnotationsAnn] != newAnnotations[OriginalCreatorAnnotationsAnn] {
+		return field.Invalid(annotationsFieldPath, OriginalCreatorAnnotationsAnn, "annotation is immutable"), nil
+	}
+
+	logCreatorAnnotationRestores(oldObj, newObj, creatorAnnotations...)
+	return nil, nil
 }
 
-// CheckCreatorIDAndNoCreatorRBAC checks that only one of no-creator-rbac or creatorID annotation is set
-func CheckCreatorIDAndNoCreatorRBAC(obj metav1.Object) *field.Error {
-	annotations := obj.GetAnnotations()
+// isCreatorAnnotationRestore returns true if the given annotation is missing on the old object and the new object sets it
+// back to the value recorded in the old object's original-creator-annotations annotation.
+func isCreatorAnnotationRestore(oldObj, newObj metav1.Object, annotation string) bool {
+	if _, ok := oldObj.GetAnnotations()[annotation]; ok {
+		return false
+	}
+	original, ok := OriginalCreatorAnnotations(oldObj)[annotation]
+	return ok && original == newObj.GetAnnotations()[annotation]
+}
 
-	if _, ok := annotations[NoCreatorRBACAnn]; ok {
-		// if the no-creator-rbac annotation exists, the creatorID annotation must not exist
-		if _, ok := annotations[CreatorIDAnn]; ok {
-			return field.Forbidden(annotationsFieldPath, fmt.Sprintf("cannot have both %s and %s annotation set", NoCreatorRBACAnn, CreatorIDAnn))
+// logCreatorAnnotationRestores logs which of the given annotations an allowed update restores to their original values.
+func logCreatorAnnotationRestores(oldObj, newObj metav1.Object, annotations ...string) {
+	for _, annotation := range annotations {
+		if isCreatorAnnotationRestore(oldObj, newObj, annotation) {
+			logrus.Infof("[creator-annotations] restoring annotation %s on %s to its original value %q", annotation, objectName(newObj), newObj.GetAnnotations()[annotation])
 		}
 	}
+}
+
+// CheckOriginalCreatorAnnotationsOnCreate checks that the original-creator-annotations annotation of a new object, if
+// set, records exactly the creator annotations of the object. The record is written by the mutating webhook on create,
+// so a record supplied by the client is denied.
+func CheckOriginalCreatorAnnotationsOnCreate(obj metav1.Object) *field.Error {
+	record, ok := obj.GetAnnotations()[OriginalCreatorAnnotationsAnn]
+	if !ok {
+		return nil
+	}
+	if want, err := originalCreatorAnnotationsRecord(obj); err != nil || record != want {
+		return field.Invalid(annotationsFieldPath, OriginalCreatorAnnotationsAnn, "annotation is managed by the webhook and cannot be set")
+	}
 	return nil
 }
+
+// OriginalCreatorAnnotations returns the creator annotations recorded on the object when it was created.
+// An empty map is returned if nothing was recorded or the record can't be parsed.
+func OriginalCreatorAnnotations(obj metav1.Object) map[string]string {
+	original := map[string]string{}
+	record, ok := obj.GetAnnotations()[OriginalCreatorAnnotationsAnn]
+	if !ok {
+		return original
+	}
+	if err := json.Unmarshal([]byte(record), &original); err != nil {
+		logrus.Warnf("[creator-annotations] ignoring malformed %s annotation on %s: %v", OriginalCreatorAnnotationsAnn, objectName(obj), err)
+		return map[string]string{}
+	}
+	return original
+}
+
+// objectName returns the name of the object, prefixed by its namespace if it has one.
+func objectName(obj metav1.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
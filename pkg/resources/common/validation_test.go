@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,18 +10,22 @@ import (
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
 )
 
 var (
@@ -73,6 +78,33 @@ func TestCheckCreatorID(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "create operation records the creatorID",
+			username:  "testUser",
+			operation: admissionv1.Create,
+			newCluster: v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "testUser",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"testUser"}`,
+					},
+				},
+			},
+		},
+		{
+			name:      "create operation has a forged original creator annotations record",
+			username:  "testUser",
+			operation: admissionv1.Create,
+			newCluster: v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "testUser",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"admin"}`,
+					},
+				},
+			},
+			errExpected: true,
+		},
 		{
 			name:      "create operation has noCreatorRBAC anno and creatorID anno",
 			username:  "testUser",
@@ -99,6 +131,61 @@ func TestCheckCreatorID(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "update operation restores creatorID to its original value",
+			username:  "testUser",
+			operation: admissionv1.Update,
+			oldCluster: v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"testUser2"}`,
+					},
+				},
+			},
+			newCluster: v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "testUser2",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"testUser2"}`,
+					},
+				},
+			},
+		},
+		{
+			name:      "update operation restores creatorID to a different value than the original",
+			username:  "testUser",
+			operation: admissionv1.Update,
+			oldCluster: v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"testUser2"}`,
+					},
+				},
+			},
+			newCluster: v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "testUser",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"testUser2"}`,
+					},
+				},
+			},
+			errExpected: true,
+		},
+		{
+			name:      "update operation adds original creator annotations",
+			username:  "testUser",
+			operation: admissionv1.Update,
+			newCluster: v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "testUser",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"testUser"}`,
+					},
+				},
+			},
+			errExpected: true,
+		},
 		{
 			name:      "update operation has noCreatorRBAC anno and creatorID anno",
 			username:  "testUser",
@@ -308,16 +395,59 @@ func TestCheckCreatorPrincipalName(t *testing.T) {
 		}
 	}).AnyTimes()
 
+	// the verifier knows the principals of the SSO user u-new, which Rancher hasn't created yet
+	verifier := principalVerifierFunc(func(_ context.Context, username, principal string) (bool, error) {
+		if principal == "github_user://unreachable" {
+			return false, fmt.Errorf("some error")
+		}
+		return username == "u-new" && principal == "github_user://new", nil
+	})
+
 	tests := []struct {
 		desc          string
 		creatorID     string
 		principalName string
+		verifier      identity.PrincipalVerifier
 		fieldErr      bool
 		err           bool
 	}{
 		{
 			desc: "no principal name annotation",
 		},
+		{
+			desc:          "principal of a new user verified",
+			creatorID:     "u-new",
+			principalName: "github_user://new",
+			verifier:      verifier,
+		},
+		{
+			desc:          "principal of a new user without verifier",
+			creatorID:     "u-new",
+			principalName: "github_user://new",
+			fieldErr:      true,
+		},
+		{
+			desc:          "principal not verified",
+			creatorID:     "u-12345",
+			principalName: "github_user://new",
+			verifier:      verifier,
+			fieldErr:      true,
+		},
+		{
+			desc:          "verifier failing",
+			creatorID:     "u-12345",
+			principalName: "github_user://unreachable",
+			verifier:      verifier,
+			fieldErr:      true,
+		},
+		{
+			desc:          "stored principal not sent to the verifier",
+			creatorID:     "u-12345",
+			principalName: "keycloak_user://12345",
+			verifier: principalVerifierFunc(func(context.Context, string, string) (bool, error) {
+				return false, fmt.Errorf("unexpected call")
+			}),
+		},
 		{
 			desc:          "creator id and principal name match",
 			creatorID:     "u-12345",
@@ -361,7 +491,8 @@ func TestCheckCreatorPrincipalName(t *testing.T) {
 				annotations[CreatorPrincipalNameAnn] = test.principalName
 			}
 
-			fieldErr, err := CheckCreatorPrincipalName(userCache, &v3.Project{
+			identities := identity.NewResolver(userCache).WithPrincipalVerifier(test.verifier)
+			fieldErr, err := CheckCreatorPrincipalName(context.Background(), identities, &v3.Project{
 				ObjectMeta: metav1.ObjectMeta{
 					Annotations: annotations,
 				},
@@ -372,11 +503,19 @@ func TestCheckCreatorPrincipalName(t *testing.T) {
 	}
 }
 
+// principalVerifierFunc verifies principals with a function.
+type principalVerifierFunc func(ctx context.Context, username, principal string) (bool, error)
+
+func (f principalVerifierFunc) VerifyPrincipal(ctx context.Context, username, principal string) (bool, error) {
+	return f(ctx, username, principal)
+}
+
 func TestCheckCreatorAnnotationsOnUpdate(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
 		desc     string
+		username string
 		oldObj   metav1.Object
 		newObj   metav1.Object
 		fieldErr bool
@@ -474,72 +613,334 @@ func TestCheckCreatorAnnotationsOnUpdate(t *testing.T) {
 			},
 			fieldErr: true,
 		},
-	}
-
-	for _, test := range tests {
-		test := test
-		t.Run(test.desc, func(t *testing.T) {
-			t.Parallel()
-
-			fieldErr := CheckCreatorAnnotationsOnUpdate(test.oldObj, test.newObj)
-			require.Equal(t, test.fieldErr, fieldErr != nil)
-		})
-	}
-}
-
-func TestCheckCreatorIDAndNoCreatorRBAC(t *testing.T) {
-	t.Parallel()
-	tests := []struct {
-		name      string
-		obj       *v3.Cluster
-		wantError bool
-	}{
 		{
-			name:      "no annotations",
-			obj:       &v3.Cluster{},
-			wantError: false,
+			desc: "annotations restored to original values",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345","field.cattle.io/creator-principal-name":"keycloak_user://12345"}`,
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "u-12345",
+						CreatorPrincipalNameAnn:       "keycloak_user://12345",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345","field.cattle.io/creator-principal-name":"keycloak_user://12345"}`,
+					},
+				},
+			},
+		},
+		{
+			desc: "annotation restored to a value other than the original",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345"}`,
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "u-12346",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345"}`,
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc: "annotation changed to original value while still set",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "u-12346",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345"}`,
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "u-12345",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345"}`,
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc:   "annotation restored using a record added in the same update",
+			oldObj: &v3.Project{},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "u-12345",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345"}`,
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc: "unprocessed ownership transfer request",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn: "u-12345",
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:       "u-12345",
+						TransferOwnerToAnn: "u-12346",
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc: "ownership transferred",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "u-12345",
+						CreatorPrincipalNameAnn:       "keycloak_user://12345",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345"}`,
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "u-12346",
+						CreatorPrincipalNameAnn:       "keycloak_user://12346",
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12346"}`,
+						OwnershipTransferAnn:          `{"from":"u-12345","to":"u-12346","by":"admin"}`,
+					},
+				},
+			},
 		},
 		{
-			name: "just creatorID annotation",
-			obj: &v3.Cluster{
+			desc:     "forged ownership transfer record",
+			username: "u-12345",
+			oldObj: &v3.Project{
 				ObjectMeta: metav1.ObjectMeta{
 					Annotations: map[string]string{
 						CreatorIDAnn: "u-12345",
 					},
 				},
 			},
-			wantError: false,
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:         "u-12346",
+						OwnershipTransferAnn: `{"from":"u-12345","to":"u-12346","by":"u-12345"}`,
+					},
+				},
+			},
+			fieldErr: true,
 		},
 		{
-			name: "just no-creator-rbac annotation",
-			obj: &v3.Cluster{
+			desc: "ownership transfer record made by another user",
+			oldObj: &v3.Project{
 				ObjectMeta: metav1.ObjectMeta{
 					Annotations: map[string]string{
-						NoCreatorRBACAnn: "true",
+						CreatorIDAnn: "u-12345",
 					},
 				},
 			},
-			wantError: false,
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:         "u-12346",
+						OwnershipTransferAnn: `{"from":"u-12345","to":"u-12346","by":"u-12345"}`,
+					},
+				},
+			},
+			fieldErr: true,
 		},
 		{
-			name: "both creatorID and no-creator-rbac annotation",
-			obj: &v3.Cluster{
+			desc: "ownership transfer to a user which doesn't exist",
+			oldObj: &v3.Project{
 				ObjectMeta: metav1.ObjectMeta{
 					Annotations: map[string]string{
-						CreatorIDAnn:     "u-12345",
-						NoCreatorRBACAnn: "true",
+						CreatorIDAnn: "u-12345",
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:         "u-missing",
+						OwnershipTransferAnn: `{"from":"u-12345","to":"u-missing","by":"admin"}`,
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc: "ownership transfer record removed",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:         "u-12345",
+						OwnershipTransferAnn: `{"to":"u-12345","by":"admin"}`,
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn: "u-12345",
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc: "ownership transfer record for another user",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn: "u-12345",
 					},
 				},
 			},
-			wantError: true,
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:         "u-12346",
+						OwnershipTransferAnn: `{"from":"u-12345","to":"u-12347","by":"admin"}`,
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc: "unchanged ownership transfer record",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:         "u-12345",
+						OwnershipTransferAnn: `{"to":"u-12345","by":"admin"}`,
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:         "u-12346",
+						OwnershipTransferAnn: `{"to":"u-12345","by":"admin"}`,
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc: "annotation restored with a malformed record",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						OriginalCreatorAnnotationsAnn: `not json`,
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						CreatorIDAnn:                  "u-12345",
+						OriginalCreatorAnnotationsAnn: `not json`,
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc: "original creator annotations changed",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345"}`,
+					},
+				},
+			},
+			newObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12346"}`,
+					},
+				},
+			},
+			fieldErr: true,
+		},
+		{
+			desc: "original creator annotations removed",
+			oldObj: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"u-12345"}`,
+					},
+				},
+			},
+			newObj: &v3.Project{},
 		},
 	}
+
+	ctrl := gomock.NewController(t)
+	userCache := fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl)
+	userCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.User, error) {
+		if name == "u-12346" {
+			return &v3.User{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+		}
+		return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+	}).AnyTimes()
+	k8Fake := &k8testing.Fake{}
+	k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+		review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+		review.Status.Allowed = review.Spec.User == "admin"
+		return true, review, nil
+	})
+	gvr := schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"}
+	transferer := NewOwnershipTransferer(gvr, identity.NewResolver(userCache), (&k8fake.FakeAuthorizationV1{Fake: k8Fake}).SubjectAccessReviews())
+
 	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			fieldErr := CheckCreatorIDAndNoCreatorRBAC(test.obj)
-			require.Equal(t, test.wantError, fieldErr != nil)
+			username := test.username
+			if username == "" {
+				username = "admin"
+			}
+			request := &admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					UserInfo:  authenticationv1.UserInfo{Username: username},
+				},
+			}
+			fieldErr, err := CheckCreatorAnnotationsOnUpdate(request, transferer, test.oldObj, test.newObj)
+			require.NoError(t, err)
+			require.Equal(t, test.fieldErr, fieldErr != nil)
 		})
 	}
+
+	t.Run("ownership transferred without a transferer", func(t *testing.T) {
+		t.Parallel()
+		fieldErr, err := CheckCreatorAnnotationsOnUpdate(&admission.Request{}, nil, &v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{CreatorIDAnn: "u-12345"}},
+		}, &v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				CreatorIDAnn:         "u-12346",
+				OwnershipTransferAnn: `{"from":"u-12345","to":"u-12346","by":"admin"}`,
+			}},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, fieldErr)
+	})
 }
@@ -3,7 +3,6 @@ package namespace
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/rancher/webhook/pkg/admission"
 	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
@@ -16,7 +15,7 @@ import (
 
 const (
 	manageNSVerb        = "manage-namespaces"
-	projectNSAnnotation = "field.cattle.io/projectId"
+	projectNSAnnotation = admission.ProjectIDAnnotation
 )
 
 type projectNamespaceAdmitter struct {
@@ -30,6 +29,10 @@ func (p *projectNamespaceAdmitter) Admit(request *admission.Request) (*admission
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
 	response := &admissionv1.AdmissionResponse{}
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		response.Allowed = true
+		return response, nil
+	}
 
 	oldNs, newNs, err := objectsv1.NamespaceOldAndNewFromRequest(&request.AdmissionRequest)
 	if err != nil {
@@ -51,11 +54,14 @@ func (p *projectNamespaceAdmitter) Admit(request *admission.Request) (*admission
 		}
 	}
 
-	values := strings.Split(projectAnnoValue, ":")
-	if len(values) < 2 {
-		return nil, fmt.Errorf("unable to retrieve project id from annotation, too few values")
+	owner, err := admission.OwnerFromRequest(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the project of the namespace: %w", err)
+	}
+	if owner.Project == "" {
+		return nil, fmt.Errorf("unable to retrieve project id from annotation %q, must be of the form cluster:project", projectAnnoValue)
 	}
-	projectName := values[1]
+	projectName := owner.Project
 	// convert from one type of extras to another. Necessary since these two packages re-define extras
 	extras := map[string]v1.ExtraValue{}
 	for k, v := range request.UserInfo.Extra {
@@ -239,7 +239,7 @@ func sarIsForProjectGVR(sarSpec authorizationv1.SubjectAccessReviewSpec) bool {
 
 func createAnnotationNamespaceRequest(newProjectAnnotation, oldProjectAnnotation string, includeProjectAnnotation bool, operation v1.Operation) (*admission.Request, error) {
 	gvk := metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"}
-	gvr := metav1.GroupVersionResource{Version: "v1", Resource: "namespace"}
+	gvr := metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"}
 	ns := corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "test-ns",
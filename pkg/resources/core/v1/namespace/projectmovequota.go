@@ -0,0 +1,135 @@
+package namespace
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+	"k8s.io/utils/trace"
+)
+
+// resourceQuotaAnnotation holds the resource quota of a namespace within its project, e.g.
+// {"limit":{"limitsCpu":"500m","pods":"10"}}.
+const resourceQuotaAnnotation = "field.cattle.io/resourceQuota"
+
+var projectMoveQuotaRule = rules.Register(rules.Rule{
+	ID:            "namespace-move-quota",
+	GVR:           schema.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+	Description:   "When a namespace is moved to a project with a resource quota, by changing its field.cattle.io/projectId annotation, the quota of the namespace, from its field.cattle.io/resourceQuota annotation or else the project's namespace default quota, must fit in the quota the project has left, its limit minus its used limit. Otherwise the moved namespace would exceed the project's quota. Resources left out of the project's quota aren't counted.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "metadata.annotations[field.cattle.io/projectId]: Forbidden: the quota of namespace team-a exceeds the quota left in project c-abc12/p-xyz34 on: pods=10 (4 left)",
+	DenialCode:    string(admission.DenialConflict),
+})
+
+type projectMoveQuotaAdmitter struct {
+	projectCache controllerv3.ProjectCache
+}
+
+// Admit denies moving a namespace to a project whose remaining quota can't absorb the quota of the namespace.
+func (p *projectMoveQuotaAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("Namespace Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	if request.Operation != admissionv1.Update || p.projectCache == nil {
+		return admission.ResponseAllowed(), nil
+	}
+	oldNs, newNs, err := objectsv1.NamespaceOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode namespace from request: %w", err)
+	}
+	projectID := newNs.Annotations[projectNSAnnotation]
+	if projectID == "" || projectID == oldNs.Annotations[projectNSAnnotation] {
+		return admission.ResponseAllowed(), nil
+	}
+	owner, err := admission.OwnerFromRequest(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the project of the namespace: %w", err)
+	}
+	clusterName, projectName := owner.Cluster, owner.Project
+	if projectName == "" {
+		return admission.ResponseAllowed(), nil
+	}
+	project, err := admission.LookupNamespaced(request, p.projectCache, clusterName, projectName)
+	if apierrors.IsNotFound(err) {
+		return admission.ResponseAllowed(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project %s/%s: %w", clusterName, projectName, err)
+	}
+
+	message, err := exceededProjectQuota(newNs, project)
+	if err != nil {
+		return admission.ResponseBadRequest(err.Error()), nil
+	}
+	if message == "" {
+		return admission.ResponseAllowed(), nil
+	}
+	message = projectMoveQuotaRule.Message("metadata.annotations[%s]: Forbidden: %s", projectNSAnnotation, message)
+	return admission.ResponseForRule(projectMoveQuotaRule, nil, message), nil
+}
+
+// exceededProjectQuota returns why the quota of the namespace doesn't fit in the quota left in the project, empty if
+// it fits or the project has no quota. It returns an error if the namespace quota annotation can't be parsed.
+func exceededProjectQuota(ns *corev1.Namespace, project *v3.Project) (string, error) {
+	if project.Spec.ResourceQuota == nil {
+		return "", nil
+	}
+	limit, err := quota.LimitToResourceList(&project.Spec.ResourceQuota.Limit)
+	if err != nil {
+		return "", nil // invalid project quotas are denied by the project validator
+	}
+	if len(limit) == 0 {
+		return "", nil
+	}
+	used, err := quota.LimitToResourceList(&project.Spec.ResourceQuota.UsedLimit)
+	if err != nil {
+		return "", nil
+	}
+
+	namespaceLimit := &v3.ResourceQuotaLimit{}
+	if value := ns.Annotations[resourceQuotaAnnotation]; value != "" {
+		var namespaceQuota v3.NamespaceResourceQuota
+		if err := json.Unmarshal([]byte(value), &namespaceQuota); err != nil {
+			return "", fmt.Errorf("invalid %s annotation: %w", resourceQuotaAnnotation, err)
+		}
+		namespaceLimit = &namespaceQuota.Limit
+	} else if project.Spec.NamespaceDefaultResourceQuota != nil {
+		namespaceLimit = &project.Spec.NamespaceDefaultResourceQuota.Limit
+	}
+	requested, err := quota.LimitToResourceList(namespaceLimit)
+	if err != nil {
+		return "", fmt.Errorf("invalid quota of namespace %s: %w", ns.Name, err)
+	}
+
+	// Only the resources limited by the project count, the namespace quota can't exceed the others.
+	requested = quotav1.Mask(requested, quotav1.ResourceNames(limit))
+	left := quotav1.Subtract(limit, quotav1.Mask(used, quotav1.ResourceNames(limit)))
+	if fits, exceeded := quota.Fits(requested, left); !fits {
+		return fmt.Sprintf("the quota of namespace %s exceeds the quota left in project %s/%s on: %s",
+			ns.Name, project.Namespace, project.Name, describeExceeded(exceeded, left)), nil
+	}
+	return "", nil
+}
+
+// describeExceeded lists the exceeded resources, sorted by name, with the quantity left of each.
+func describeExceeded(exceeded, left corev1.ResourceList) string {
+	parts := make([]string, 0, len(exceeded))
+	for name, quantity := range exceeded {
+		remaining := left[name]
+		parts = append(parts, fmt.Sprintf("%s=%s (%s left)", name, quantity.String(), remaining.String()))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,168 @@
+package namespace
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestProjectMoveQuota(t *testing.T) {
+	t.Parallel()
+	projects := map[string]*v3.Project{
+		"c-abc12/p-quota": {
+			ObjectMeta: metav1.ObjectMeta{Namespace: "c-abc12", Name: "p-quota"},
+			Spec: v3.ProjectSpec{
+				ResourceQuota: &v3.ProjectResourceQuota{
+					Limit:     v3.ResourceQuotaLimit{Pods: "10", LimitsCPU: "2"},
+					UsedLimit: v3.ResourceQuotaLimit{Pods: "6", LimitsCPU: "500m"},
+				},
+				NamespaceDefaultResourceQuota: &v3.NamespaceResourceQuota{
+					Limit: v3.ResourceQuotaLimit{Pods: "5", LimitsCPU: "500m"},
+				},
+			},
+		},
+		"c-abc12/p-free": {ObjectMeta: metav1.ObjectMeta{Namespace: "c-abc12", Name: "p-free"}},
+	}
+	namespace := func(projectID, resourceQuota string) corev1.Namespace {
+		ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: map[string]string{}}}
+		if projectID != "" {
+			ns.Annotations[projectNSAnnotation] = projectID
+		}
+		if resourceQuota != "" {
+			ns.Annotations[resourceQuotaAnnotation] = resourceQuota
+		}
+		return ns
+	}
+	tests := []struct {
+		name         string
+		oldNamespace corev1.Namespace
+		newNamespace corev1.Namespace
+		noCache      bool
+		wantAllowed  bool
+		wantCode     int32
+		wantMessage  string
+		wantError    bool
+	}{
+		{
+			name:         "quota fits in the project",
+			oldNamespace: namespace("c-abc12:p-free", `{"limit":{"pods":"4","limitsCpu":"1500m"}}`),
+			newNamespace: namespace("c-abc12:p-quota", `{"limit":{"pods":"4","limitsCpu":"1500m"}}`),
+			wantAllowed:  true,
+		},
+		{
+			name:         "quota exceeds what is left in the project",
+			oldNamespace: namespace("c-abc12:p-free", `{"limit":{"pods":"5","limitsCpu":"2"}}`),
+			newNamespace: namespace("c-abc12:p-quota", `{"limit":{"pods":"5","limitsCpu":"2"}}`),
+			wantCode:     http.StatusConflict,
+			wantMessage:  "metadata.annotations[field.cattle.io/projectId]: Forbidden: the quota of namespace team-a exceeds the quota left in project c-abc12/p-quota on: limitsCpu=2 (1500m left), pods=5 (4 left)",
+		},
+		{
+			name:         "namespace default quota exceeds what is left in the project",
+			oldNamespace: namespace("", ""),
+			newNamespace: namespace("c-abc12:p-quota", ""),
+			wantCode:     http.StatusConflict,
+			wantMessage:  "pods=5 (4 left)",
+		},
+		{
+			name:         "resources outside the project quota aren't counted",
+			oldNamespace: namespace("", ""),
+			newNamespace: namespace("c-abc12:p-quota", `{"limit":{"pods":"1","configMaps":"1000"}}`),
+			wantAllowed:  true,
+		},
+		{
+			name:         "project without a quota",
+			oldNamespace: namespace("c-abc12:p-quota", `{"limit":{"pods":"100"}}`),
+			newNamespace: namespace("c-abc12:p-free", `{"limit":{"pods":"100"}}`),
+			wantAllowed:  true,
+		},
+		{
+			name:         "project unchanged",
+			oldNamespace: namespace("c-abc12:p-quota", `{"limit":{"pods":"100"}}`),
+			newNamespace: namespace("c-abc12:p-quota", `{"limit":{"pods":"100"}}`),
+			wantAllowed:  true,
+		},
+		{
+			name:         "project doesn't exist",
+			oldNamespace: namespace("", ""),
+			newNamespace: namespace("c-abc12:p-gone", `{"limit":{"pods":"100"}}`),
+			wantAllowed:  true,
+		},
+		{
+			name:         "invalid namespace quota",
+			oldNamespace: namespace("", ""),
+			newNamespace: namespace("c-abc12:p-quota", `{"limit":`),
+			wantCode:     http.StatusBadRequest,
+			wantMessage:  "invalid field.cattle.io/resourceQuota annotation",
+		},
+		{
+			name:         "downstream cluster",
+			oldNamespace: namespace("", ""),
+			newNamespace: namespace("c-abc12:p-quota", `{"limit":{"pods":"100"}}`),
+			noCache:      true,
+			wantAllowed:  true,
+		},
+		{
+			name:         "project cache error",
+			oldNamespace: namespace("", ""),
+			newNamespace: namespace("c-abc12:p-error", ""),
+			wantError:    true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+			projectCache.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(namespace, name string) (*v3.Project, error) {
+				if name == "p-error" {
+					return nil, errors.New("cache error")
+				}
+				if project, ok := projects[namespace+"/"+name]; ok {
+					return project, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "projects"}, name)
+			}).AnyTimes()
+			admitter := projectMoveQuotaAdmitter{projectCache: projectCache}
+			if test.noCache {
+				admitter.projectCache = nil
+			}
+
+			oldRaw, err := json.Marshal(&test.oldNamespace)
+			require.NoError(t, err)
+			newRaw, err := json.Marshal(&test.newNamespace)
+			require.NoError(t, err)
+			response, err := admitter.Admit(&admission.Request{AdmissionRequest: v1.AdmissionRequest{
+				Operation: v1.Update,
+				Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+				Name:      test.newNamespace.Name,
+				Object:    runtime.RawExtension{Raw: newRaw},
+				OldObject: runtime.RawExtension{Raw: oldRaw},
+			}})
+			if test.wantError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+			if !test.wantAllowed {
+				assert.Equal(t, test.wantCode, response.Result.Code)
+				assert.Contains(t, response.Result.Message, test.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package namespace
+
+import (
+	"fmt"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+// systemNamespaceAnnotation marks the namespaces Rancher creates for the system project of a cluster.
+const systemNamespaceAnnotation = "management.cattle.io/system-namespace"
+
+var projectNamespaceDeletionRule = rules.Register(rules.Rule{
+	ID:            "project-namespace-deletion",
+	GVR:           schema.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+	Description:   "The backing namespace of a project, named after the project's cluster and name and annotated with the project's ID, and namespaces with the management.cattle.io/system-namespace annotation can only be deleted once their project is being deleted, so that deleting them first doesn't leave a half-deleted project behind.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "namespace c-abc12-p-xyz34 backs project c-abc12/p-xyz34 and can only be deleted once the project is being deleted",
+	DenialCode:    string(admission.DenialConflict),
+})
+
+type projectNamespaceDeletionAdmitter struct {
+	projectCache controllerv3.ProjectCache
+}
+
+// Admit denies the deletion of project backing namespaces and system namespaces while their project isn't deleting.
+func (p *projectNamespaceDeletionAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("Namespace Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	if request.Operation != admissionv1.Delete || p.projectCache == nil {
+		return admission.ResponseAllowed(), nil
+	}
+	ns, err := objectsv1.NamespaceFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode namespace from request: %w", err)
+	}
+
+	message, err := p.protectedBy(request, ns)
+	if err != nil {
+		return nil, err
+	}
+	if message == "" {
+		return admission.ResponseAllowed(), nil
+	}
+	message = projectNamespaceDeletionRule.Message("%s", message)
+	return admission.ResponseForRule(projectNamespaceDeletionRule, nil, message), nil
+}
+
+// protectedBy returns why the namespace can't be deleted yet, empty if it can.
+func (p *projectNamespaceDeletionAdmitter) protectedBy(request *admission.Request, ns *corev1.Namespace) (string, error) {
+	backing := isBackingNamespace(ns)
+	if !backing && ns.Annotations[systemNamespaceAnnotation] != "true" {
+		return "", nil
+	}
+	owner, err := admission.OwnerFromRequest(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the project of the namespace: %w", err)
+	}
+	clusterName, projectName := owner.Cluster, owner.Project
+	// a system namespace without a project has no project to leave half-deleted
+	if projectName == "" {
+		return "", nil
+	}
+	project, err := p.project(clusterName, projectName)
+	if err != nil {
+		return "", err
+	}
+	if project == nil || project.DeletionTimestamp != nil {
+		return "", nil
+	}
+	if backing {
+		return fmt.Sprintf("namespace %s backs project %s/%s and can only be deleted once the project is being deleted",
+			ns.Name, clusterName, projectName), nil
+	}
+	return fmt.Sprintf("namespace %s is a system namespace of project %s/%s and can only be deleted once the project is being deleted",
+		ns.Name, clusterName, projectName), nil
+}
+
+// project returns the project, nil if it doesn't exist.
+func (p *projectNamespaceDeletionAdmitter) project(clusterName, projectName string) (*v3.Project, error) {
+	project, err := p.projectCache.Get(clusterName, projectName)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project %s/%s: %w", clusterName, projectName, err)
+	}
+	return project, nil
+}
+
+// isBackingNamespace returns true if the namespace is the backing namespace of the project named in its
+// field.cattle.io/projectId annotation, which is named <cluster name>-<project name>. Names alone don't tell backing
+// namespaces apart, since user namespaces can be named the same way.
+func isBackingNamespace(ns *corev1.Namespace) bool {
+	owner, ok := admission.ParseProjectID(ns.Annotations[admission.ProjectIDAnnotation])
+	return ok && ns.Name == owner.Cluster+"-"+owner.Project
+}
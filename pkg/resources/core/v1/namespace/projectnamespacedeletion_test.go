@@ -0,0 +1,198 @@
+package namespace
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestProjectNamespaceDeletion(t *testing.T) {
+	t.Parallel()
+	projects := map[string]*v3.Project{
+		"c-abc12/p-active": {ObjectMeta: metav1.ObjectMeta{Namespace: "c-abc12", Name: "p-active"}},
+		"c-abc12/p-deleting": {ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "c-abc12",
+			Name:              "p-deleting",
+			DeletionTimestamp: &metav1.Time{},
+		}},
+	}
+	tests := []struct {
+		name        string
+		operation   v1.Operation
+		namespace   corev1.Namespace
+		noCache     bool
+		wantAllowed bool
+		wantError   bool
+	}{
+		{
+			name:      "backing namespace of an active project",
+			operation: v1.Delete,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "c-abc12-p-active",
+				Annotations: map[string]string{projectNSAnnotation: "c-abc12:p-active"},
+			}},
+		},
+		{
+			name:        "backing namespace of a deleting project",
+			operation:   v1.Delete,
+			namespace:   corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "c-abc12-p-deleting"}},
+			wantAllowed: true,
+		},
+		{
+			name:        "backing namespace of a deleted project",
+			operation:   v1.Delete,
+			namespace:   corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "c-abc12-p-gone"}},
+			wantAllowed: true,
+		},
+		{
+			name:      "system namespace of an active project",
+			operation: v1.Delete,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name: "cattle-monitoring-system",
+				Annotations: map[string]string{
+					systemNamespaceAnnotation: "true",
+					projectNSAnnotation:       "c-abc12:p-active",
+				},
+			}},
+		},
+		{
+			name:      "system namespace of a deleting project",
+			operation: v1.Delete,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name: "cattle-monitoring-system",
+				Annotations: map[string]string{
+					systemNamespaceAnnotation: "true",
+					projectNSAnnotation:       "c-abc12:p-deleting",
+				},
+			}},
+			wantAllowed: true,
+		},
+		{
+			name:      "system namespace without a project",
+			operation: v1.Delete,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "cattle-monitoring-system",
+				Annotations: map[string]string{systemNamespaceAnnotation: "true"},
+			}},
+			wantAllowed: true,
+		},
+		{
+			name:      "namespace of an active project",
+			operation: v1.Delete,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Annotations: map[string]string{projectNSAnnotation: "c-abc12:p-active"},
+			}},
+			wantAllowed: true,
+		},
+		{
+			name:        "namespace named like a backing namespace",
+			operation:   v1.Delete,
+			namespace:   corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "c-abc12-p-active"}},
+			wantAllowed: true,
+		},
+		{
+			name:      "namespace named like a backing namespace of another project",
+			operation: v1.Delete,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "c-abc12-p-active",
+				Annotations: map[string]string{projectNSAnnotation: "c-abc12:p-deleting"},
+			}},
+			wantAllowed: true,
+		},
+		{
+			name:        "update of a backing namespace",
+			operation:   v1.Update,
+			namespace:   corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "c-abc12-p-active"}},
+			wantAllowed: true,
+		},
+		{
+			name:      "downstream cluster",
+			operation: v1.Delete,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "c-abc12-p-active",
+				Annotations: map[string]string{projectNSAnnotation: "c-abc12:p-active"},
+			}},
+			noCache:     true,
+			wantAllowed: true,
+		},
+		{
+			name:      "project cache error",
+			operation: v1.Delete,
+			namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "c-abc12-p-error",
+				Annotations: map[string]string{projectNSAnnotation: "c-abc12:p-error"},
+			}},
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+			projectCache.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(namespace, name string) (*v3.Project, error) {
+				if name == "p-error" {
+					return nil, errors.New("cache error")
+				}
+				if project, ok := projects[namespace+"/"+name]; ok {
+					return project, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "projects"}, name)
+			}).AnyTimes()
+			admitter := projectNamespaceDeletionAdmitter{projectCache: projectCache}
+			if test.noCache {
+				admitter.projectCache = nil
+			}
+
+			raw, err := json.Marshal(&test.namespace)
+			require.NoError(t, err)
+			request := &admission.Request{AdmissionRequest: v1.AdmissionRequest{
+				Operation: test.operation,
+				Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+				Name:      test.namespace.Name,
+				OldObject: runtime.RawExtension{Raw: raw},
+			}}
+			if test.operation != v1.Delete {
+				request.Object = runtime.RawExtension{Raw: raw}
+			}
+			response, err := admitter.Admit(request)
+			if test.wantError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+			if !test.wantAllowed {
+				assert.Equal(t, int32(http.StatusConflict), response.Result.Code)
+				assert.Contains(t, response.Result.Message, "/rules/project-namespace-deletion")
+			}
+		})
+	}
+}
+
+func TestIsBackingNamespace(t *testing.T) {
+	t.Parallel()
+	namespace := func(name, projectID string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{projectNSAnnotation: projectID}}}
+	}
+	assert.True(t, isBackingNamespace(namespace("c-abc12-p-xyz34", "c-abc12:p-xyz34")))
+	assert.True(t, isBackingNamespace(namespace("local-p-xyz34", "local:p-xyz34")))
+	assert.False(t, isBackingNamespace(namespace("c-abc12-p-xyz34", "")), "names alone don't make backing namespaces")
+	assert.False(t, isBackingNamespace(namespace("team-p-app", "c-abc12:p-xyz34")))
+}
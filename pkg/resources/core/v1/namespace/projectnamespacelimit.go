@@ -0,0 +1,106 @@
+package namespace
+
+import (
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/rules"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+var projectNamespaceLimitRule = rules.Register(rules.Rule{
+	ID:            "namespace-project-limit",
+	GVR:           schema.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+	Description:   "When a project has the management.cattle.io/max-namespaces annotation, namespaces can't be created in the project, or moved to it by changing their field.cattle.io/projectId annotation, once it has that many namespaces. Namespaces being deleted aren't counted. Only the namespaces of the cluster the webhook runs in are counted.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "metadata.annotations[field.cattle.io/projectId]: Forbidden: project c-abc12/p-xyz34 already has 10 namespaces, the most allowed by its management.cattle.io/max-namespaces annotation",
+	DenialCode:    string(admission.DenialConflict),
+})
+
+type projectNamespaceLimitAdmitter struct {
+	projectCache   controllerv3.ProjectCache
+	namespaceCache corecontrollers.NamespaceCache
+}
+
+// newProjectNamespaceLimitAdmitter returns the admitter limiting the namespaces of projects, indexing the namespaces
+// by project if both caches are set.
+func newProjectNamespaceLimitAdmitter(projectCache controllerv3.ProjectCache, namespaceCache corecontrollers.NamespaceCache) projectNamespaceLimitAdmitter {
+	if projectCache == nil || namespaceCache == nil {
+		return projectNamespaceLimitAdmitter{}
+	}
+	admission.IndexNamespacesByProject(namespaceCache)
+	return projectNamespaceLimitAdmitter{
+		projectCache:   projectCache,
+		namespaceCache: namespaceCache,
+	}
+}
+
+// Admit denies creating a namespace in a project, or moving a namespace to it, once the project has as many
+// namespaces as its quota.MaxNamespacesAnnotation annotation allows.
+func (p *projectNamespaceLimitAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("Namespace Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	if p.projectCache == nil || p.namespaceCache == nil ||
+		(request.Operation != admissionv1.Create && request.Operation != admissionv1.Update) {
+		return admission.ResponseAllowed(), nil
+	}
+	oldNs, newNs, err := objectsv1.NamespaceOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode namespace from request: %w", err)
+	}
+	projectID := newNs.Annotations[projectNSAnnotation]
+	if projectID == "" || (request.Operation == admissionv1.Update && projectID == oldNs.Annotations[projectNSAnnotation]) {
+		return admission.ResponseAllowed(), nil
+	}
+	owner, err := admission.OwnerFromRequest(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the project of the namespace: %w", err)
+	}
+	if owner.Project == "" {
+		return admission.ResponseAllowed(), nil
+	}
+	project, err := admission.LookupNamespaced(request, p.projectCache, owner.Cluster, owner.Project)
+	if apierrors.IsNotFound(err) {
+		return admission.ResponseAllowed(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project %s/%s: %w", owner.Cluster, owner.Project, err)
+	}
+	limit, err := quota.ParseMaxNamespaces(project.Annotations[quota.MaxNamespacesAnnotation])
+	if err != nil {
+		// invalid limits are denied by the project validator
+		logrus.Warnf("[namespace-validation] ignoring invalid %s annotation of project %s/%s: %v", quota.MaxNamespacesAnnotation, project.Namespace, project.Name, err)
+		return admission.ResponseAllowed(), nil
+	}
+	if limit == 0 {
+		return admission.ResponseAllowed(), nil
+	}
+
+	namespaces, err := p.namespaceCache.GetByIndex(admission.NamespacesByProjectIndex, owner.ProjectID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the namespaces of project %s/%s: %w", owner.Cluster, owner.Project, err)
+	}
+	count := 0
+	for _, namespace := range namespaces {
+		if namespace.DeletionTimestamp != nil || namespace.Name == newNs.Name {
+			continue
+		}
+		count++
+	}
+	if count < limit {
+		return admission.ResponseAllowed(), nil
+	}
+
+	message := projectNamespaceLimitRule.Message("metadata.annotations[%s]: Forbidden: project %s/%s already has %d namespaces, the most allowed by its %s annotation",
+		projectNSAnnotation, owner.Cluster, owner.Project, count, quota.MaxNamespacesAnnotation)
+	return admission.ResponseForRule(projectNamespaceLimitRule, nil, message), nil
+}
@@ -0,0 +1,197 @@
+package namespace
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestProjectNamespaceLimit(t *testing.T) {
+	t.Parallel()
+	project := func(name, maxNamespaces string) *v3.Project {
+		return &v3.Project{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "c-abc12",
+			Name:        name,
+			Annotations: map[string]string{quota.MaxNamespacesAnnotation: maxNamespaces},
+		}}
+	}
+	projects := map[string]*v3.Project{
+		"p-full":      project("p-full", "2"),
+		"p-roomy":     project("p-roomy", "3"),
+		"p-unlimited": project("p-unlimited", ""),
+		"p-invalid":   project("p-invalid", "many"),
+	}
+	namespace := func(name, projectID string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{}}}
+		if projectID != "" {
+			ns.Annotations[projectNSAnnotation] = projectID
+		}
+		return ns
+	}
+	terminating := namespace("team-old", "c-abc12:p-roomy")
+	terminating.DeletionTimestamp = &metav1.Time{}
+	namespaces := map[string][]*corev1.Namespace{
+		"c-abc12:p-full":  {namespace("team-a", "c-abc12:p-full"), namespace("team-b", "c-abc12:p-full")},
+		"c-abc12:p-roomy": {namespace("team-c", "c-abc12:p-roomy"), terminating, namespace("team-d", "c-abc12:p-roomy")},
+	}
+
+	tests := []struct {
+		name         string
+		operation    v1.Operation
+		oldNamespace *corev1.Namespace
+		newNamespace *corev1.Namespace
+		noCache      bool
+		wantAllowed  bool
+		wantMessage  string
+		wantError    bool
+	}{
+		{
+			name:         "create in a full project",
+			operation:    v1.Create,
+			newNamespace: namespace("team-new", "c-abc12:p-full"),
+			wantMessage:  "metadata.annotations[field.cattle.io/projectId]: Forbidden: project c-abc12/p-full already has 2 namespaces, the most allowed by its management.cattle.io/max-namespaces annotation",
+		},
+		{
+			name:         "create in a project with room, not counting terminating namespaces",
+			operation:    v1.Create,
+			newNamespace: namespace("team-new", "c-abc12:p-roomy"),
+			wantAllowed:  true,
+		},
+		{
+			name:         "create in an unlimited project",
+			operation:    v1.Create,
+			newNamespace: namespace("team-new", "c-abc12:p-unlimited"),
+			wantAllowed:  true,
+		},
+		{
+			name:         "create in a project with an invalid limit",
+			operation:    v1.Create,
+			newNamespace: namespace("team-new", "c-abc12:p-invalid"),
+			wantAllowed:  true,
+		},
+		{
+			name:         "create without a project",
+			operation:    v1.Create,
+			newNamespace: namespace("team-new", ""),
+			wantAllowed:  true,
+		},
+		{
+			name:         "create in a project that doesn't exist",
+			operation:    v1.Create,
+			newNamespace: namespace("team-new", "c-abc12:p-gone"),
+			wantAllowed:  true,
+		},
+		{
+			name:         "move to a full project",
+			operation:    v1.Update,
+			oldNamespace: namespace("team-c", "c-abc12:p-roomy"),
+			newNamespace: namespace("team-c", "c-abc12:p-full"),
+			wantMessage:  "project c-abc12/p-full already has 2 namespaces",
+		},
+		{
+			name:         "update in a full project",
+			operation:    v1.Update,
+			oldNamespace: namespace("team-a", "c-abc12:p-full"),
+			newNamespace: namespace("team-a", "c-abc12:p-full"),
+			wantAllowed:  true,
+		},
+		{
+			name:         "downstream cluster",
+			operation:    v1.Create,
+			newNamespace: namespace("team-new", "c-abc12:p-full"),
+			noCache:      true,
+			wantAllowed:  true,
+		},
+		{
+			name:         "project cache error",
+			operation:    v1.Create,
+			newNamespace: namespace("team-new", "c-abc12:p-error"),
+			wantError:    true,
+		},
+		{
+			name:         "namespace cache error",
+			operation:    v1.Create,
+			newNamespace: namespace("team-new", "c-abc12:p-roomy"),
+			wantError:    true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+			projectCache.EXPECT().Get("c-abc12", gomock.Any()).DoAndReturn(func(_, name string) (*v3.Project, error) {
+				if name == "p-error" {
+					return nil, errors.New("cache error")
+				}
+				if project, ok := projects[name]; ok {
+					return project, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "projects"}, name)
+			}).AnyTimes()
+			namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](ctrl)
+			namespaceCache.EXPECT().GetByIndex(admission.NamespacesByProjectIndex, gomock.Any()).DoAndReturn(func(_, projectID string) ([]*corev1.Namespace, error) {
+				if test.name == "namespace cache error" {
+					return nil, errors.New("cache error")
+				}
+				return namespaces[projectID], nil
+			}).AnyTimes()
+			admitter := projectNamespaceLimitAdmitter{projectCache: projectCache, namespaceCache: namespaceCache}
+			if test.noCache {
+				admitter = projectNamespaceLimitAdmitter{}
+			}
+
+			request := &admission.Request{AdmissionRequest: v1.AdmissionRequest{
+				Operation: test.operation,
+				Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+				Name:      test.newNamespace.Name,
+			}}
+			newRaw, err := json.Marshal(test.newNamespace)
+			require.NoError(t, err)
+			request.Object = runtime.RawExtension{Raw: newRaw}
+			if test.oldNamespace != nil {
+				oldRaw, err := json.Marshal(test.oldNamespace)
+				require.NoError(t, err)
+				request.OldObject = runtime.RawExtension{Raw: oldRaw}
+			}
+			response, err := admitter.Admit(request)
+			if test.wantError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+			if !test.wantAllowed {
+				assert.Equal(t, int32(http.StatusConflict), response.Result.Code)
+				assert.Contains(t, response.Result.Message, test.wantMessage)
+			}
+		})
+	}
+}
+
+func TestNewProjectNamespaceLimitAdmitter(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+	namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](ctrl)
+	namespaceCache.EXPECT().AddIndexer(admission.NamespacesByProjectIndex, gomock.Any())
+
+	assert.Nil(t, newProjectNamespaceLimitAdmitter(nil, namespaceCache).namespaceCache)
+	assert.NotNil(t, newProjectNamespaceLimitAdmitter(projectCache, namespaceCache).namespaceCache)
+}
@@ -49,6 +49,9 @@ func (p *psaLabelAdmitter) Admit(request *admission.Request) (*admissionv1.Admis
 			response.Allowed = true
 			return response, nil
 		}
+	default:
+		response.Allowed = true
+		return response, nil
 	}
 
 	extras := map[string]v1.ExtraValue{}
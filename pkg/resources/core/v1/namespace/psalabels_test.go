@@ -380,7 +380,7 @@ func TestValidatePSALabels(t *testing.T) {
 func createNamespaceRequest(t *testing.T, s *test) *admission.Request {
 	t.Helper()
 	gvk := metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"}
-	gvr := metav1.GroupVersionResource{Version: "v1", Resource: "namespace"}
+	gvr := metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"}
 
 	req := &admission.Request{
 		AdmissionRequest: admissionv1.AdmissionRequest{
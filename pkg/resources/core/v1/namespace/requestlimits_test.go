@@ -125,7 +125,7 @@ func TestRequestLimitAdmitter(t *testing.T) {
 
 func createRequestLimitRequest(limitsAnnotation string, operation v1.Operation) (*admission.Request, error) {
 	gvk := metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"}
-	gvr := metav1.GroupVersionResource{Version: "v1", Resource: "namespace"}
+	gvr := metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"}
 
 	ns := corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
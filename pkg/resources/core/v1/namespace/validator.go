@@ -3,6 +3,8 @@ package namespace
 
 import (
 	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	admissionv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,15 +18,23 @@ var projectsGVR = schema.GroupVersionResource{
 	Resource: "projects",
 }
 
+// deleteExcludedNamespaces are the namespaces whose deletion isn't reviewed, since they never back a project.
+var deleteExcludedNamespaces = []string{"default", "kube-system", "kube-public", "kube-node-lease", "cattle-system"}
+
 // Validator validates the namespace admission request.
 type Validator struct {
 	psaAdmitter                psaLabelAdmitter
 	projectNamespaceAdmitter   projectNamespaceAdmitter
 	requestWithinLimitAdmitter requestLimitAdmitter
+	deletionAdmitter           projectNamespaceDeletionAdmitter
+	moveQuotaAdmitter          projectMoveQuotaAdmitter
+	namespaceLimitAdmitter     projectNamespaceLimitAdmitter
 }
 
-// NewValidator returns a new validator used for validation of namespace requests.
-func NewValidator(sar authorizationv1.SubjectAccessReviewInterface) *Validator {
+// NewValidator returns a new validator used for validation of namespace requests. Deletions, the quota of namespaces
+// moved between projects and the number of namespaces of projects are only validated when the project cache is set,
+// which it isn't on downstream clusters.
+func NewValidator(sar authorizationv1.SubjectAccessReviewInterface, projectCache controllerv3.ProjectCache, namespaceCache corecontrollers.NamespaceCache) *Validator {
 	return &Validator{
 		psaAdmitter: psaLabelAdmitter{
 			sar: sar,
@@ -33,6 +43,13 @@ func NewValidator(sar authorizationv1.SubjectAccessReviewInterface) *Validator {
 			sar: sar,
 		},
 		requestWithinLimitAdmitter: requestLimitAdmitter{},
+		deletionAdmitter: projectNamespaceDeletionAdmitter{
+			projectCache: projectCache,
+		},
+		moveQuotaAdmitter: projectMoveQuotaAdmitter{
+			projectCache: projectCache,
+		},
+		namespaceLimitAdmitter: newProjectNamespaceLimitAdmitter(projectCache, namespaceCache),
 	}
 }
 
@@ -46,10 +63,14 @@ func (v *Validator) GVR() schema.GroupVersionResource {
 
 // Operations returns list of operations handled by this validator.
 func (v *Validator) Operations() []admissionv1.OperationType {
-	return []admissionv1.OperationType{
+	operations := []admissionv1.OperationType{
 		admissionv1.Update,
 		admissionv1.Create,
 	}
+	if v.deletionAdmitter.projectCache != nil {
+		operations = append(operations, admissionv1.Delete)
+	}
+	return operations
 }
 
 // ValidatingWebhook returns the ValidatingWebhook used for this CRD.
@@ -87,10 +108,29 @@ func (v *Validator) ValidatingWebhook(clientConfig admissionv1.WebhookClientConf
 	}
 	kubeSystemCreateWebhook.FailurePolicy = admission.Ptr(admissionv1.Ignore)
 
-	return []admissionv1.ValidatingWebhook{*standardWebhook, *createWebhook, *kubeSystemCreateWebhook}
+	webhooks := []admissionv1.ValidatingWebhook{*standardWebhook, *createWebhook, *kubeSystemCreateWebhook}
+	if v.deletionAdmitter.projectCache != nil {
+		// deleteWebhook leaves out the namespaces of the cluster itself and ignores failures, so that namespaces can
+		// still be deleted to recover while the webhook is down.
+		deleteWebhook := admission.NewDefaultValidatingWebhook(v, clientConfig, admissionv1.ClusterScope, []admissionv1.OperationType{admissionv1.Delete})
+		deleteWebhook.Name = admission.CreateWebhookName(v, "delete")
+		deleteWebhook.NamespaceSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{
+					Key:      corev1.LabelMetadataName,
+					Operator: metav1.LabelSelectorOpNotIn,
+					Values:   deleteExcludedNamespaces,
+				},
+			},
+		}
+		deleteWebhook.FailurePolicy = admission.Ptr(admissionv1.Ignore)
+		webhooks = append(webhooks, *deleteWebhook)
+	}
+	return webhooks
 }
 
-// Admitters returns the psaAdmitter and the projectNamespaceAdmitter for namespaces.
+// Admitters returns the psaAdmitter, the projectNamespaceAdmitter, the requestWithinLimitAdmitter, the
+// deletionAdmitter, the moveQuotaAdmitter and the namespaceLimitAdmitter for namespaces.
 func (v *Validator) Admitters() []admission.Admitter {
-	return []admission.Admitter{&v.psaAdmitter, &v.projectNamespaceAdmitter, &v.requestWithinLimitAdmitter}
+	return []admission.Admitter{&v.psaAdmitter, &v.projectNamespaceAdmitter, &v.requestWithinLimitAdmitter, &v.deletionAdmitter, &v.moveQuotaAdmitter, &v.namespaceLimitAdmitter}
 }
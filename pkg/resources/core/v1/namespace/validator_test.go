@@ -3,14 +3,18 @@ package namespace
 import (
 	"testing"
 
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	v1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestGVR(t *testing.T) {
-	validator := NewValidator(nil)
+	validator := NewValidator(nil, nil, nil)
 	gvr := validator.GVR()
 	assert.Equal(t, "v1", gvr.Version)
 	assert.Equal(t, "namespaces", gvr.Resource)
@@ -18,19 +22,26 @@ func TestGVR(t *testing.T) {
 }
 
 func TestOperations(t *testing.T) {
-	validator := NewValidator(nil)
+	validator := NewValidator(nil, nil, nil)
 	operations := validator.Operations()
 	assert.Len(t, operations, 2)
 	assert.Contains(t, operations, v1.Update)
 	assert.Contains(t, operations, v1.Create)
+
+	ctrl := gomock.NewController(t)
+	validator = NewValidator(nil, fake.NewMockCacheInterface[*v3.Project](ctrl), nil)
+	assert.Equal(t, []v1.OperationType{v1.Update, v1.Create, v1.Delete}, validator.Operations())
 }
 
 func TestAdmitters(t *testing.T) {
-	validator := NewValidator(nil)
+	validator := NewValidator(nil, nil, nil)
 	admitters := validator.Admitters()
-	assert.Len(t, admitters, 3)
+	assert.Len(t, admitters, 6)
 	hasPSAAdmitter := false
 	hasProjectNamespaceAdmitter := false
+	hasDeletionAdmitter := false
+	hasMoveQuotaAdmitter := false
+	hasNamespaceLimitAdmitter := false
 	for i := range admitters {
 		admitter := admitters[i]
 		_, ok := admitter.(*psaLabelAdmitter)
@@ -43,9 +54,27 @@ func TestAdmitters(t *testing.T) {
 			hasProjectNamespaceAdmitter = true
 			continue
 		}
+		_, ok = admitter.(*projectNamespaceDeletionAdmitter)
+		if ok {
+			hasDeletionAdmitter = true
+			continue
+		}
+		_, ok = admitter.(*projectMoveQuotaAdmitter)
+		if ok {
+			hasMoveQuotaAdmitter = true
+			continue
+		}
+		_, ok = admitter.(*projectNamespaceLimitAdmitter)
+		if ok {
+			hasNamespaceLimitAdmitter = true
+			continue
+		}
 	}
 	assert.True(t, hasPSAAdmitter, "admitters did not contain a PSA admitter")
 	assert.True(t, hasProjectNamespaceAdmitter, "admitters did not contain a projectNamespaceAdmitter")
+	assert.True(t, hasDeletionAdmitter, "admitters did not contain a projectNamespaceDeletionAdmitter")
+	assert.True(t, hasMoveQuotaAdmitter, "admitters did not contain a projectMoveQuotaAdmitter")
+	assert.True(t, hasNamespaceLimitAdmitter, "admitters did not contain a projectNamespaceLimitAdmitter")
 }
 
 func TestValidatingWebhook(t *testing.T) {
@@ -54,7 +83,7 @@ func TestValidatingWebhook(t *testing.T) {
 		URL: &testURL,
 	}
 	wantURL := "test.cattle.io/namespaces"
-	validator := NewValidator(nil)
+	validator := NewValidator(nil, nil, nil)
 	webhooks := validator.ValidatingWebhook(clientConfig)
 	assert.Len(t, webhooks, 3)
 	hasAllUpdateWebhook := false
@@ -109,3 +138,25 @@ func TestValidatingWebhook(t *testing.T) {
 	assert.True(t, hasCreateKubeSystemWebhook, "was missing expected webhook create on kube system namespace")
 	assert.True(t, hasCreateNonKubeSystemWebhook, "was missing expected webhook create on non-kube-system namespaces")
 }
+
+func TestValidatingWebhookDelete(t *testing.T) {
+	testURL := "test.cattle.io"
+	clientConfig := v1.WebhookClientConfig{
+		URL: &testURL,
+	}
+	ctrl := gomock.NewController(t)
+	validator := NewValidator(nil, fake.NewMockCacheInterface[*v3.Project](ctrl), nil)
+	webhooks := validator.ValidatingWebhook(clientConfig)
+	assert.Len(t, webhooks, 4)
+	deleteWebhook := webhooks[3]
+	assert.Equal(t, "rancher.cattle.io.namespaces.delete", deleteWebhook.Name)
+	assert.Len(t, deleteWebhook.Rules, 1)
+	assert.Equal(t, []v1.OperationType{v1.Delete}, deleteWebhook.Rules[0].Operations)
+	require.NotNil(t, deleteWebhook.NamespaceSelector)
+	assert.Equal(t, []metav1.LabelSelectorRequirement{{
+		Key:      corev1.LabelMetadataName,
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   []string{"default", "kube-system", "kube-public", "kube-node-lease", "cattle-system"},
+	}}, deleteWebhook.NamespaceSelector.MatchExpressions)
+	assert.Equal(t, v1.Ignore, *deleteWebhook.FailurePolicy, "namespaces can be deleted while the webhook is down")
+}
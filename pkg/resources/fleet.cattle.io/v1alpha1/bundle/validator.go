@@ -0,0 +1,297 @@
+// Package bundle validates the fleet bundles deploying to the cattle-system and kube-system namespaces.
+package bundle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+
+	fleetv1alpha1 "github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	"github.com/rancher/webhook/pkg/admission"
+	objectsv1alpha1 "github.com/rancher/webhook/pkg/generated/objects/fleet.cattle.io/v1alpha1"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/utils/trace"
+)
+
+const (
+	// SystemManagedLabel marks the bundles of Rancher's own components, which may deploy privileged workloads and
+	// cluster-admin bindings to the protected namespaces.
+	SystemManagedLabel = "fleet.cattle.io/system-managed"
+
+	clusterAdminRole = "cluster-admin"
+	gzipEncoding     = "base64+gz"
+	// maxContentSize bounds the size of a decompressed resource, so that a small compressed content can't exhaust
+	// the memory of the webhook.
+	maxContentSize = 32 << 20
+)
+
+var (
+	gvr = schema.GroupVersionResource{
+		Group:    "fleet.cattle.io",
+		Version:  "v1alpha1",
+		Resource: "bundles",
+	}
+	resourcesPath = field.NewPath("spec", "resources")
+
+	protectedNamespaces = []string{"cattle-system", "kube-system"}
+
+	bundleProtectedNamespacesRule = rules.Register(rules.Rule{
+		ID:            "bundle-protected-namespaces",
+		GVR:           gvr,
+		Description:   "Bundles can't deploy privileged containers or bindings of the cluster-admin role to the cattle-system and kube-system namespaces, unless they are labeled fleet.cattle.io/system-managed=true. The raw manifests of a bundle are checked in the namespace each target deploys them to.",
+		Severity:      rules.SeverityDeny,
+		ExampleDenial: `spec.resources[0]: Forbidden: container agent of Deployment cattle-system/agent is privileged`,
+		DenialCode:    string(admission.DenialForbidden),
+	})
+)
+
+// Validator validates fleet bundles.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new Validator for fleet bundles.
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// GVR returns the GroupVersionResource.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by the validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{
+		*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.NamespacedScope, v.Operations()),
+	}
+}
+
+// Admitters returns the admitter objects.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct{}
+
+// Admit handles the webhook admission requests.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("bundleValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	bundle, err := objectsv1alpha1.BundleFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle from request: %w", err)
+	}
+	if bundle.Labels[SystemManagedLabel] == "true" {
+		return admission.ResponseAllowed(), nil
+	}
+
+	fieldErrs, err := ValidateResources(&bundle.Spec)
+	if err != nil {
+		return admission.ResponseDenied(admission.DenialBadRequest, err.Error()), nil
+	}
+	var errList field.ErrorList
+	var warnings []string
+	for _, fieldErr := range fieldErrs {
+		fieldErr.Detail = bundleProtectedNamespacesRule.Message("%s", fieldErr.Detail)
+		if fieldErr, warning := rules.EnforceError(bundleProtectedNamespacesRule, fieldErr); fieldErr != nil {
+			errList = append(errList, fieldErr)
+		} else if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	if len(errList) != 0 {
+		return admission.ResponseDeniedErrors(admission.DenialForbidden, errList), nil
+	}
+	response := admission.ResponseAllowed()
+	response.Warnings = warnings
+	return response, nil
+}
+
+// ValidateResources returns the privileged containers and cluster-admin bindings the raw manifests of the bundle deploy
+// to a protected namespace. Files which aren't manifests, like the templates of a helm chart, are skipped. An error is
+// returned if the content of a resource can't be decoded.
+func ValidateResources(spec *fleetv1alpha1.BundleSpec) (field.ErrorList, error) {
+	options := deploymentOptions(spec)
+	var errList field.ErrorList
+	for i, resource := range spec.Resources {
+		if !isManifest(resource.Name) {
+			continue
+		}
+		content, err := decodeContent(resource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the content of resource %s: %w", resource.Name, err)
+		}
+		for _, object := range decodeObjects(content) {
+			namespace, ok := protectedNamespace(options, object)
+			if !ok {
+				continue
+			}
+			for _, msg := range violations(object, namespace) {
+				errList = append(errList, field.Forbidden(resourcesPath.Index(i), msg))
+			}
+		}
+	}
+	return errList, nil
+}
+
+// deploymentOptions returns the options of each target of the bundle, which override the options of the bundle when
+// set, or the options of the bundle if it has no targets.
+func deploymentOptions(spec *fleetv1alpha1.BundleSpec) []fleetv1alpha1.BundleDeploymentOptions {
+	if len(spec.Targets) == 0 {
+		return []fleetv1alpha1.BundleDeploymentOptions{spec.BundleDeploymentOptions}
+	}
+	options := make([]fleetv1alpha1.BundleDeploymentOptions, 0, len(spec.Targets))
+	for _, target := range spec.Targets {
+		targetOptions := spec.BundleDeploymentOptions
+		if target.TargetNamespace != "" {
+			targetOptions.TargetNamespace = target.TargetNamespace
+		}
+		if target.DefaultNamespace != "" {
+			targetOptions.DefaultNamespace = target.DefaultNamespace
+		}
+		options = append(options, targetOptions)
+	}
+	return options
+}
+
+// protectedNamespace returns the first protected namespace the object is deployed to with any of the options. The
+// target namespace replaces the namespace of the object, and the default namespace is used for objects without one.
+// Cluster scoped objects are deployed to the namespace of the bundle for this purpose.
+func protectedNamespace(options []fleetv1alpha1.BundleDeploymentOptions, object *unstructured.Unstructured) (string, bool) {
+	for _, option := range options {
+		namespace := option.TargetNamespace
+		if namespace == "" {
+			namespace = object.GetNamespace()
+		}
+		if namespace == "" {
+			namespace = option.DefaultNamespace
+		}
+		if slices.Contains(protectedNamespaces, namespace) {
+			return namespace, true
+		}
+	}
+	return "", false
+}
+
+// violations returns why the object can't be deployed to the protected namespace.
+func violations(object *unstructured.Unstructured, namespace string) []string {
+	kind, name := object.GetKind(), namespace+"/"+object.GetName()
+	if kind == "ClusterRoleBinding" {
+		name = object.GetName()
+	}
+	switch kind {
+	case "ClusterRoleBinding", "RoleBinding":
+		roleKind, _, _ := unstructured.NestedString(object.Object, "roleRef", "kind")
+		roleName, _, _ := unstructured.NestedString(object.Object, "roleRef", "name")
+		if roleKind == "ClusterRole" && roleName == clusterAdminRole {
+			return []string{fmt.Sprintf("%s %s binds the %s role", kind, name, clusterAdminRole)}
+		}
+		return nil
+	}
+	podSpec, ok := podSpecPath(kind)
+	if !ok {
+		return nil
+	}
+	var msgs []string
+	for _, containersField := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(object.Object, append(podSpec, containersField)...)
+		for _, container := range containers {
+			container, ok := container.(map[string]any)
+			if !ok {
+				continue
+			}
+			if privileged, _, _ := unstructured.NestedBool(container, "securityContext", "privileged"); privileged {
+				containerName, _, _ := unstructured.NestedString(container, "name")
+				msgs = append(msgs, fmt.Sprintf("container %s of %s %s is privileged", containerName, kind, name))
+			}
+		}
+	}
+	return msgs
+}
+
+// podSpecPath returns the path of the pod spec in objects of the kind, false if they don't have one.
+func podSpecPath(kind string) ([]string, bool) {
+	switch kind {
+	case "Pod":
+		return []string{"spec"}, true
+	case "Deployment", "DaemonSet", "StatefulSet", "ReplicaSet", "ReplicationController", "Job":
+		return []string{"spec", "template", "spec"}, true
+	case "CronJob":
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}, true
+	}
+	return nil, false
+}
+
+// isManifest returns whether fleet deploys the resource as a raw manifest.
+func isManifest(name string) bool {
+	switch path.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// decodeContent returns the decompressed content of the resource.
+func decodeContent(resource fleetv1alpha1.BundleResource) ([]byte, error) {
+	switch resource.Encoding {
+	case "":
+		return []byte(resource.Content), nil
+	case gzipEncoding:
+		compressed, err := base64.StdEncoding.DecodeString(resource.Content)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		content, err := io.ReadAll(io.LimitReader(reader, maxContentSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(content) > maxContentSize {
+			return nil, fmt.Errorf("content is larger than %d bytes", maxContentSize)
+		}
+		return content, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", resource.Encoding)
+}
+
+// decodeObjects returns the objects of the YAML documents or JSON objects in the content, up to the first which can't
+// be decoded, since the file is then a template rather than a manifest, or the end of the content.
+func decodeObjects(content []byte) []*unstructured.Unstructured {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		var object map[string]any
+		if err := decoder.Decode(&object); err != nil {
+			return objects
+		}
+		if object == nil {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: object}
+		if u.GetKind() == "" {
+			continue
+		}
+		objects = append(objects, u)
+	}
+}
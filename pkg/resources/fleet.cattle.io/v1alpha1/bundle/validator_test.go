@@ -0,0 +1,185 @@
+package bundle_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	fleetv1alpha1 "github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/fleet.cattle.io/v1alpha1/bundle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const privilegedDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: setup
+      containers:
+      - name: agent
+        securityContext:
+          privileged: true
+`
+
+const clusterAdminBinding = `{
+  "apiVersion": "rbac.authorization.k8s.io/v1",
+  "kind": "ClusterRoleBinding",
+  "metadata": {"name": "agent"},
+  "roleRef": {"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": "cluster-admin"},
+  "subjects": [{"kind": "ServiceAccount", "name": "agent", "namespace": "cattle-system"}]
+}`
+
+const viewBindingAndPod = `apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: agent
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: view
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+  namespace: kube-system
+spec:
+  containers:
+  - name: debug
+    securityContext:
+      privileged: false
+`
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		spec        fleetv1alpha1.BundleSpec
+		wantCode    int32
+		wantMessage string
+	}{
+		{
+			name: "privileged container in a protected namespace",
+			spec: fleetv1alpha1.BundleSpec{
+				BundleDeploymentOptions: fleetv1alpha1.BundleDeploymentOptions{DefaultNamespace: "cattle-system"},
+				Resources:               []fleetv1alpha1.BundleResource{{Name: "deployment.yaml", Content: privilegedDeployment}},
+			},
+			wantCode:    403,
+			wantMessage: "spec.resources[0]: Forbidden: container agent of Deployment cattle-system/agent is privileged (see /rules/bundle-protected-namespaces)",
+		},
+		{
+			name: "privileged container in another namespace",
+			spec: fleetv1alpha1.BundleSpec{
+				BundleDeploymentOptions: fleetv1alpha1.BundleDeploymentOptions{DefaultNamespace: "team-a"},
+				Resources:               []fleetv1alpha1.BundleResource{{Name: "deployment.yaml", Content: privilegedDeployment}},
+			},
+		},
+		{
+			name: "target deploying to a protected namespace",
+			spec: fleetv1alpha1.BundleSpec{
+				BundleDeploymentOptions: fleetv1alpha1.BundleDeploymentOptions{DefaultNamespace: "team-a"},
+				Resources:               []fleetv1alpha1.BundleResource{{Name: "deployment.yaml", Content: privilegedDeployment}},
+				Targets: []fleetv1alpha1.BundleTarget{
+					{Name: "dev"},
+					{Name: "system", BundleDeploymentOptions: fleetv1alpha1.BundleDeploymentOptions{TargetNamespace: "kube-system"}},
+				},
+			},
+			wantCode:    403,
+			wantMessage: "container agent of Deployment kube-system/agent is privileged",
+		},
+		{
+			name: "cluster-admin binding in a compressed resource",
+			spec: fleetv1alpha1.BundleSpec{
+				BundleDeploymentOptions: fleetv1alpha1.BundleDeploymentOptions{TargetNamespace: "kube-system"},
+				Resources: []fleetv1alpha1.BundleResource{
+					{Name: "chart/Chart.yaml", Content: "name: agent\nversion: 1.0.0\n"},
+					{Name: "binding.json", Content: compress(t, clusterAdminBinding), Encoding: "base64+gz"},
+				},
+			},
+			wantCode:    403,
+			wantMessage: "spec.resources[1]: Forbidden: ClusterRoleBinding agent binds the cluster-admin role",
+		},
+		{
+			name: "unprivileged objects in a protected namespace",
+			spec: fleetv1alpha1.BundleSpec{
+				Resources: []fleetv1alpha1.BundleResource{{Name: "manifests.yml", Content: viewBindingAndPod}},
+			},
+		},
+		{
+			name:   "system managed bundle",
+			labels: map[string]string{bundle.SystemManagedLabel: "true"},
+			spec: fleetv1alpha1.BundleSpec{
+				BundleDeploymentOptions: fleetv1alpha1.BundleDeploymentOptions{DefaultNamespace: "cattle-system"},
+				Resources:               []fleetv1alpha1.BundleResource{{Name: "deployment.yaml", Content: privilegedDeployment}},
+			},
+		},
+		{
+			name: "templates and other files are skipped",
+			spec: fleetv1alpha1.BundleSpec{
+				BundleDeploymentOptions: fleetv1alpha1.BundleDeploymentOptions{DefaultNamespace: "cattle-system"},
+				Resources: []fleetv1alpha1.BundleResource{
+					{Name: "chart/templates/deployment.yaml", Content: "{{- if .Values.enabled }}\n" + privilegedDeployment + "{{- end }}\n"},
+					{Name: "README.md", Content: privilegedDeployment},
+				},
+			},
+		},
+		{
+			name: "unsupported encoding",
+			spec: fleetv1alpha1.BundleSpec{
+				Resources: []fleetv1alpha1.BundleResource{{Name: "deployment.yaml", Content: privilegedDeployment, Encoding: "zstd"}},
+			},
+			wantCode:    400,
+			wantMessage: `failed to decode the content of resource deployment.yaml: unsupported encoding "zstd"`,
+		},
+	}
+	validator := bundle.NewValidator()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			raw, err := json.Marshal(&fleetv1alpha1.Bundle{
+				ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "fleet-default", Labels: test.labels},
+				Spec:       test.spec,
+			})
+			require.NoError(t, err)
+			for _, operation := range []admissionv1.Operation{admissionv1.Create, admissionv1.Update} {
+				request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: operation,
+					Object:    runtime.RawExtension{Raw: raw},
+					OldObject: runtime.RawExtension{Raw: raw},
+				}}
+				response, err := validator.Admitters()[0].Admit(request)
+				require.NoError(t, err)
+				if test.wantMessage == "" {
+					assert.True(t, response.Allowed, operation)
+					continue
+				}
+				require.False(t, response.Allowed, operation)
+				assert.Equal(t, test.wantCode, response.Result.Code, operation)
+				assert.Contains(t, response.Result.Message, test.wantMessage, operation)
+			}
+		})
+	}
+}
+
+func compress(t *testing.T, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/distribution/reference"
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/rules"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	agentImageAllowedRegistriesEnv = "CATTLE_AGENT_IMAGE_ALLOWED_REGISTRIES"
+	agentImageAllowedTagsEnv       = "CATTLE_AGENT_IMAGE_ALLOWED_TAGS"
+	latestTag                      = "latest"
+)
+
+var (
+	agentImageRegistryRule = rules.Register(rules.Rule{
+		ID:            "cluster-agent-image-registry",
+		GVR:           managementGVR,
+		Description:   "The agent image override, desired agent image and desired auth image of a cluster must be pulled from one of the registries allowed by CATTLE_AGENT_IMAGE_ALLOWED_REGISTRIES. Images that didn't change on update aren't checked.",
+		Severity:      rules.SeverityWarn,
+		ExampleDenial: "spec.agentImageOverride: Forbidden: registry docker.io of image rancher/rancher-agent:v2.11.0 is not one of the allowed registries [registry.rancher.com]",
+		DenialCode:    string(admission.DenialBadRequest),
+	})
+	agentImageTagRule = rules.Register(rules.Rule{
+		ID:            "cluster-agent-image-tag",
+		GVR:           managementGVR,
+		Description:   "The tags of the agent image override, desired agent image and desired auth image of a cluster must match one of the patterns allowed by CATTLE_AGENT_IMAGE_ALLOWED_TAGS. Images without a tag use the latest tag.",
+		Severity:      rules.SeverityWarn,
+		ExampleDenial: "spec.agentImageOverride: Forbidden: tag latest of image rancher/rancher-agent doesn't match any of the allowed tags [v2.11.*]",
+		DenialCode:    string(admission.DenialBadRequest),
+	})
+)
+
+// agentImagePolicy restricts the images a cluster can use to override the cluster agent and auth images.
+// Empty allow-lists don't restrict anything.
+type agentImagePolicy struct {
+	// allowedRegistries are the registry hosts images can be pulled from, e.g. registry.rancher.com.
+	allowedRegistries []string
+	// allowedTags are path.Match patterns the image tags must match, e.g. v2.11.*.
+	allowedTags []string
+}
+
+// agentImagePolicyFromEnv returns the agent image policy configured through environment variables.
+func agentImagePolicyFromEnv() agentImagePolicy {
+	return agentImagePolicy{
+		allowedRegistries: common.SplitList(os.Getenv(agentImageAllowedRegistriesEnv)),
+		allowedTags:       common.SplitList(os.Getenv(agentImageAllowedTagsEnv)),
+	}
+}
+
+// validateAgentImages checks the agent image overrides of the cluster against the policy. Only images that are new
+// or changed are checked, so that clusters created before an allow-list was configured can still be updated.
+// Images using the latest tag, explicitly or implicitly, are allowed with a warning.
+func (p agentImagePolicy) validateAgentImages(oldCluster, newCluster *apisv3.Cluster, overrides rules.Overrides) (field.ErrorList, []string) {
+	specPath := field.NewPath("spec")
+	images := []struct {
+		path     *field.Path
+		oldImage string
+		newImage string
+	}{
+		{specPath.Child("agentImageOverride"), oldCluster.Spec.AgentImageOverride, newCluster.Spec.AgentImageOverride},
+		{specPath.Child("desiredAgentImage"), oldCluster.Spec.DesiredAgentImage, newCluster.Spec.DesiredAgentImage},
+		{specPath.Child("desiredAuthImage"), oldCluster.Spec.DesiredAuthImage, newCluster.Spec.DesiredAuthImage},
+	}
+
+	var errList field.ErrorList
+	var warnings []string
+	for _, image := range images {
+		if image.newImage == "" || image.newImage == image.oldImage {
+			continue
+		}
+		fieldErr, warning := p.validateImage(image.path, image.newImage, overrides)
+		if fieldErr != nil {
+			errList = append(errList, fieldErr)
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return errList, warnings
+}
+
+// validateImage checks a single image reference against the policy.
+func (p agentImagePolicy) validateImage(fldPath *field.Path, image string, overrides rules.Overrides) (*field.Error, string) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return field.Invalid(fldPath, image, fmt.Sprintf("invalid image reference: %v", err)), ""
+	}
+
+	if len(p.allowedRegistries) > 0 {
+		registry := reference.Domain(named)
+		if !slices.Contains(p.allowedRegistries, registry) {
+			fieldErr := field.Forbidden(fldPath, agentImageRegistryRule.Message("registry %s of image %s is not one of the allowed registries [%s]",
+				registry, image, strings.Join(p.allowedRegistries, ", ")))
+			if fieldErr, warning := rules.EnforceErrorWith(agentImageRegistryRule, overrides, fieldErr); fieldErr != nil || warning != "" {
+				return fieldErr, warning
+			}
+		}
+	}
+
+	tag := ""
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	} else if _, digested := named.(reference.Digested); !digested {
+		// an image without a tag or digest is pulled with the latest tag
+		tag = latestTag
+	}
+
+	if tag != "" && len(p.allowedTags) > 0 && !matchesAny(p.allowedTags, tag) {
+		fieldErr := field.Forbidden(fldPath, agentImageTagRule.Message("tag %s of image %s doesn't match any of the allowed tags [%s]",
+			tag, image, strings.Join(p.allowedTags, ", ")))
+		if fieldErr, warning := rules.EnforceErrorWith(agentImageTagRule, overrides, fieldErr); fieldErr != nil || warning != "" {
+			return fieldErr, warning
+		}
+	}
+
+	if tag == latestTag {
+		return nil, fmt.Sprintf("%s: image %s uses the %s tag, pin a version to avoid unexpected agent upgrades", fldPath, image, latestTag)
+	}
+	return nil, ""
+}
+
+// matchesAny returns true if the value matches any of the path.Match patterns.
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, value); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
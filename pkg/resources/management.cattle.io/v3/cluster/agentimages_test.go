@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAgentImages(t *testing.T) {
+	t.Parallel()
+	restricted := agentImagePolicy{
+		allowedRegistries: []string{"registry.rancher.com", "docker.io"},
+		allowedTags:       []string{"v2.11.*", "latest"},
+	}
+	clusterWithImages := func(agentImageOverride, desiredAgentImage, desiredAuthImage string) *v3.Cluster {
+		return &v3.Cluster{
+			Spec: v3.ClusterSpec{
+				ClusterSpecBase: v3.ClusterSpecBase{
+					AgentImageOverride: agentImageOverride,
+					DesiredAgentImage:  desiredAgentImage,
+					DesiredAuthImage:   desiredAuthImage,
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name         string
+		policy       agentImagePolicy
+		oldCluster   *v3.Cluster
+		newCluster   *v3.Cluster
+		wantFields   []string
+		wantWarnings int
+	}{
+		{
+			name:       "no images",
+			policy:     restricted,
+			newCluster: clusterWithImages("", "", ""),
+		},
+		{
+			name:       "no policy",
+			newCluster: clusterWithImages("example.com/agent:v1", "", ""),
+		},
+		{
+			name:       "allowed images",
+			policy:     restricted,
+			newCluster: clusterWithImages("registry.rancher.com/rancher/rancher-agent:v2.11.1", "rancher/rancher-agent:v2.11.0", ""),
+		},
+		{
+			name:       "registry not allowed",
+			policy:     restricted,
+			newCluster: clusterWithImages("example.com/rancher/rancher-agent:v2.11.1", "", ""),
+			wantFields: []string{"spec.agentImageOverride"},
+		},
+		{
+			name:       "tag not allowed",
+			policy:     restricted,
+			newCluster: clusterWithImages("", "rancher/rancher-agent:v2.10.0", "rancher/rancher-agent:v2.9.0"),
+			wantFields: []string{"spec.desiredAgentImage", "spec.desiredAuthImage"},
+		},
+		{
+			name:       "implicit latest tag is checked against the allowed tags",
+			policy:     agentImagePolicy{allowedTags: []string{"v2.11.*"}},
+			newCluster: clusterWithImages("rancher/rancher-agent", "", ""),
+			wantFields: []string{"spec.agentImageOverride"},
+		},
+		{
+			name:       "digest is allowed",
+			policy:     agentImagePolicy{allowedTags: []string{"v2.11.*"}},
+			newCluster: clusterWithImages("rancher/rancher-agent@sha256:0000000000000000000000000000000000000000000000000000000000000000", "", ""),
+		},
+		{
+			name:       "invalid image",
+			policy:     restricted,
+			newCluster: clusterWithImages("Rancher/Agent:v1", "", ""),
+			wantFields: []string{"spec.agentImageOverride"},
+		},
+		{
+			name:         "latest tag warns",
+			policy:       restricted,
+			newCluster:   clusterWithImages("rancher/rancher-agent:latest", "", "rancher/rancher-agent"),
+			wantWarnings: 2,
+		},
+		{
+			name:       "unchanged images are not checked",
+			policy:     restricted,
+			oldCluster: clusterWithImages("example.com/agent:latest", "", ""),
+			newCluster: clusterWithImages("example.com/agent:latest", "", ""),
+		},
+		{
+			name:       "changed images are checked",
+			policy:     restricted,
+			oldCluster: clusterWithImages("registry.rancher.com/rancher/rancher-agent:v2.11.1", "", ""),
+			newCluster: clusterWithImages("example.com/agent:v2.11.1", "", ""),
+			wantFields: []string{"spec.agentImageOverride"},
+		},
+	}
+	// The rules only warn unless the cluster or the enforcement setting moves them to deny.
+	deny := rules.Overrides{agentImageRegistryRule.ID: rules.StageDeny, agentImageTagRule.ID: rules.StageDeny}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v3.Cluster{}
+			}
+			errList, warnings := tt.policy.validateAgentImages(oldCluster, tt.newCluster, deny)
+			var gotFields []string
+			for _, err := range errList {
+				gotFields = append(gotFields, err.Field)
+			}
+			assert.Equal(t, tt.wantFields, gotFields)
+			assert.Len(t, warnings, tt.wantWarnings)
+		})
+	}
+}
+
+func TestValidateAgentImagesWarnsByDefault(t *testing.T) {
+	t.Parallel()
+	policy := agentImagePolicy{allowedRegistries: []string{"registry.rancher.com"}, allowedTags: []string{"v2.11.*"}}
+	newCluster := &v3.Cluster{
+		Spec: v3.ClusterSpec{
+			ClusterSpecBase: v3.ClusterSpecBase{AgentImageOverride: "example.com/rancher/rancher-agent:v2.10.0"},
+		},
+	}
+	errList, warnings := policy.validateAgentImages(&v3.Cluster{}, newCluster, nil)
+	assert.Empty(t, errList)
+	assert.Len(t, warnings, 1)
+}
+
+func TestAgentImagePolicyFromEnv(t *testing.T) {
+	t.Setenv(agentImageAllowedRegistriesEnv, "registry.rancher.com, docker.io,")
+	t.Setenv(agentImageAllowedTagsEnv, "")
+	assert.Equal(t, agentImagePolicy{allowedRegistries: []string{"registry.rancher.com", "docker.io"}}, agentImagePolicyFromEnv())
+}
@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// creatorRoleBindingsAnn lists the role templates Rancher binds to the creator of a cluster.
+	creatorRoleBindingsAnn = "authz.management.cattle.io/creator-role-bindings"
+	bindVerb               = "bind"
+	clusterContext         = "cluster"
+)
+
+var (
+	roleTemplateGVR = schema.GroupVersionResource{
+		Group:    "management.cattle.io",
+		Version:  "v3",
+		Resource: "roletemplates",
+	}
+
+	clusterCreatorRoleBindingsRule = rules.Register(rules.Rule{
+		ID:            "cluster-creator-role-bindings",
+		GVR:           managementGVR,
+		Description:   "The role templates a cluster is created with in the authz.management.cattle.io/creator-role-bindings annotation, which Rancher binds to the creator of the cluster, must be unlocked cluster role templates that are either bound to cluster creators by default, or that the creator could bind otherwise: they must have all the permissions of the role template or the bind verb on it.",
+		Severity:      rules.SeverityDeny,
+		ExampleDenial: `metadata.annotations[authz.management.cattle.io/creator-role-bindings]: Forbidden: can't bind role template restricted-admin: user "u-abc123" (groups=["system:authenticated"]) is attempting to grant RBAC permissions not currently held`,
+	})
+)
+
+func init() {
+	// the creator role bindings are set by users and validated by this validator
+	common.RegisterReservedMetadataKeys(creatorRoleBindingsAnn)
+}
+
+// creatorRoleBindings is the value of the creatorRoleBindingsAnn annotation.
+type creatorRoleBindings struct {
+	Required []string `json:"required,omitempty"`
+}
+
+// validateCreatorRoleBindings checks that the creator of a cluster could bind the role templates listed in the
+// creatorRoleBindingsAnn annotation of the cluster, since Rancher binds them to the creator once the cluster is
+// created. Role templates bound to cluster creators by default are allowed, as Rancher binds them without the
+// annotation anyway. The annotation is only checked on create, Rancher manages it afterwards.
+func (a *admitter) validateCreatorRoleBindings(request *admission.Request, cluster *apisv3.Cluster) (*field.Error, error) {
+	value, ok := cluster.Annotations[creatorRoleBindingsAnn]
+	if request.Operation != admissionv1.Create || !ok {
+		return nil, nil
+	}
+	path := field.NewPath("metadata", "annotations").Key(creatorRoleBindingsAnn)
+	var bindings creatorRoleBindings
+	if err := json.Unmarshal([]byte(value), &bindings); err != nil {
+		return field.Invalid(path, value, clusterCreatorRoleBindingsRule.Message("failed to parse: %v", err)), nil
+	}
+	for _, name := range bindings.Required {
+		roleTemplate, err := admission.Lookup(request, a.roleTemplateResolver.RoleTemplateCache(), name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return field.Invalid(path, value, clusterCreatorRoleBindingsRule.Message("role template %s not found", name)), nil
+			}
+			return nil, fmt.Errorf("failed to get role template %s: %w", name, err)
+		}
+		if roleTemplate.Context != clusterContext {
+			return field.Invalid(path, value, clusterCreatorRoleBindingsRule.Message("role template %s has the %q context, not %q", name, roleTemplate.Context, clusterContext)), nil
+		}
+		if roleTemplate.Locked {
+			return field.Forbidden(path, clusterCreatorRoleBindingsRule.Message("role template %s is locked and cannot be assigned", name)), nil
+		}
+		if roleTemplate.ClusterCreatorDefault {
+			continue
+		}
+		policyRules, err := a.roleTemplateResolver.RulesFromTemplate(roleTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rules of role template %s: %w", name, err)
+		}
+		checker := common.NewCachedVerbChecker(request, name, a.sar, roleTemplateGVR, bindVerb)
+		// the cluster doesn't exist yet, so the creator's permissions are their global ones
+		if err := checker.IsRulesAllowed(policyRules, a.resolver, ""); err != nil {
+			return field.Forbidden(path, clusterCreatorRoleBindingsRule.Message("can't bind role template %s: %v", name, err)), nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,167 @@
+package cluster
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+	"k8s.io/kubernetes/pkg/registry/rbac/validation"
+)
+
+func TestValidateCreatorRoleBindings(t *testing.T) {
+	t.Parallel()
+	const (
+		owner  = "u-owner"
+		binder = "u-binder"
+		member = "u-member"
+	)
+	readClusters := rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{"management.cattle.io"}, Resources: []string{"clusters"}}
+	allRules := rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}
+	roleTemplates := map[string]*v3.RoleTemplate{
+		"cluster-owner":  {ObjectMeta: metav1.ObjectMeta{Name: "cluster-owner"}, Context: "cluster", ClusterCreatorDefault: true, Rules: []rbacv1.PolicyRule{allRules}},
+		"cluster-viewer": {ObjectMeta: metav1.ObjectMeta{Name: "cluster-viewer"}, Context: "cluster", Rules: []rbacv1.PolicyRule{readClusters}},
+		"cluster-admin":  {ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}, Context: "cluster", Rules: []rbacv1.PolicyRule{allRules}},
+		"locked-owner":   {ObjectMeta: metav1.ObjectMeta{Name: "locked-owner"}, Context: "cluster", ClusterCreatorDefault: true, Locked: true},
+		"project-owner":  {ObjectMeta: metav1.ObjectMeta{Name: "project-owner"}, Context: "project"},
+	}
+
+	ctrl := gomock.NewController(t)
+	roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
+	roleTemplateCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.RoleTemplate, error) {
+		if roleTemplate, ok := roleTemplates[name]; ok {
+			return roleTemplate, nil
+		}
+		return nil, apierrors.NewNotFound(v3.Resource("roletemplates"), name)
+	}).AnyTimes()
+	clusterRoleCache := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
+
+	// every user can read clusters, and the binder can bind any role template
+	resolver, _ := validation.NewTestRuleResolver(nil, nil,
+		[]*rbacv1.ClusterRole{{ObjectMeta: metav1.ObjectMeta{Name: "read-clusters"}, Rules: []rbacv1.PolicyRule{readClusters}}},
+		[]*rbacv1.ClusterRoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{Name: "read-clusters"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:authenticated"}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "read-clusters"},
+		}},
+	)
+	k8Fake := &k8testing.Fake{}
+	k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+		review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+		review.Status.Allowed = review.Spec.User == binder && review.Spec.ResourceAttributes.Verb == bindVerb
+		return true, review, nil
+	})
+	a := admitter{
+		sar:                  (&k8fake.FakeAuthorizationV1{Fake: k8Fake}).SubjectAccessReviews(),
+		roleTemplateResolver: auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache),
+		resolver:             resolver,
+	}
+
+	tests := []struct {
+		name       string
+		op         admissionv1.Operation
+		user       string
+		annotation *string
+		wantErr    string
+	}{
+		{
+			name: "no annotation",
+			op:   admissionv1.Create,
+			user: member,
+		},
+		{
+			name:       "update",
+			op:         admissionv1.Update,
+			user:       member,
+			annotation: admission.Ptr(`{"required":["cluster-admin"]}`),
+		},
+		{
+			name:       "creator default role template",
+			op:         admissionv1.Create,
+			user:       member,
+			annotation: admission.Ptr(`{"required":["cluster-owner"],"created":["cluster-owner"]}`),
+		},
+		{
+			name:       "role template with permissions the creator has",
+			op:         admissionv1.Create,
+			user:       member,
+			annotation: admission.Ptr(`{"required":["cluster-owner","cluster-viewer"]}`),
+		},
+		{
+			name:       "role template the creator can bind",
+			op:         admissionv1.Create,
+			user:       binder,
+			annotation: admission.Ptr(`{"required":["cluster-admin"]}`),
+		},
+		{
+			name:       "role template the creator can't bind",
+			op:         admissionv1.Create,
+			user:       owner,
+			annotation: admission.Ptr(`{"required":["cluster-owner","cluster-admin"]}`),
+			wantErr:    "metadata.annotations[authz.management.cattle.io/creator-role-bindings]: Forbidden: can't bind role template cluster-admin: user \"u-owner\"",
+		},
+		{
+			name:       "locked role template",
+			op:         admissionv1.Create,
+			user:       binder,
+			annotation: admission.Ptr(`{"required":["locked-owner"]}`),
+			wantErr:    "role template locked-owner is locked and cannot be assigned",
+		},
+		{
+			name:       "project role template",
+			op:         admissionv1.Create,
+			user:       binder,
+			annotation: admission.Ptr(`{"required":["project-owner"]}`),
+			wantErr:    `role template project-owner has the "project" context, not "cluster"`,
+		},
+		{
+			name:       "missing role template",
+			op:         admissionv1.Create,
+			user:       binder,
+			annotation: admission.Ptr(`{"required":["missing"]}`),
+			wantErr:    "role template missing not found",
+		},
+		{
+			name:       "invalid annotation",
+			op:         admissionv1.Create,
+			user:       binder,
+			annotation: admission.Ptr(`["cluster-owner"]`),
+			wantErr:    "failed to parse",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			cluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-abc123"}}
+			if test.annotation != nil {
+				cluster.Annotations = map[string]string{creatorRoleBindingsAnn: *test.annotation}
+			}
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: test.op,
+				UserInfo:  authenticationv1.UserInfo{Username: test.user, Groups: []string{"system:authenticated"}},
+			}}
+			fieldErr, err := a.validateCreatorRoleBindings(request, cluster)
+			require.NoError(t, err)
+			if test.wantErr == "" {
+				assert.Nil(t, fieldErr)
+				return
+			}
+			require.NotNil(t, fieldErr)
+			assert.Contains(t, fieldErr.Error(), test.wantErr)
+		})
+	}
+}
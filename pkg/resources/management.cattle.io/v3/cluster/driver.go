@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const localCluster = "local"
+
+var clusterDriverChangeRule = rules.Register(rules.Rule{
+	ID:            "cluster-driver-change",
+	GVR:           managementGVR,
+	Description:   "The driver of a cluster can't be changed once set, except from imported to the detected k3s or rke2 distribution, and the provider config of a cluster can't be replaced by the config of another provider. The local cluster, and clusters migrated by Rancher with the provisioning.cattle.io/allow-driver-migration annotation, are exempt.",
+	Severity:      rules.SeverityWarn,
+	ExampleDenial: "status.driver: Forbidden: driver can't be changed from AKS to EKS, clusters can't be converted between drivers",
+	DenialCode:    string(admission.DenialBadRequest),
+})
+
+// validateDriverChange denies updates that switch the driver of a cluster, or replace its provider config with the
+// config of another provider, since Rancher can't convert a cluster in place. Rancher itself can migrate a cluster by
+// setting the common.DriverMigrationAnn annotation. The driver of an imported cluster can still be changed to the
+// detected K3s or RKE2 distribution, and the driver and provider config can always be set for the first time.
+func validateDriverChange(request *admission.Request, oldCluster, newCluster *apisv3.Cluster) *field.Error {
+	if request.Operation != admissionv1.Update || newCluster.Name == localCluster {
+		return nil
+	}
+	if common.IsDriverMigrationAllowed(request, newCluster) {
+		return nil
+	}
+
+	oldDriver, newDriver := oldCluster.Status.Driver, newCluster.Status.Driver
+	if oldDriver != "" && oldDriver != newDriver && !isImportedDistributionDetection(oldDriver, newDriver) {
+		return field.Forbidden(field.NewPath("status", "driver"), clusterDriverChangeRule.Message("driver can't be changed from %s to %s, clusters can't be converted between drivers", oldDriver, newDriver))
+	}
+
+	oldProvider, newProvider := providerConfig(oldCluster), providerConfig(newCluster)
+	if oldProvider != "" && newProvider != "" && oldProvider != newProvider {
+		return field.Forbidden(field.NewPath("spec", newProvider), clusterDriverChangeRule.Message("can't be set on a cluster with spec.%s, clusters can't be converted between providers", oldProvider))
+	}
+	return nil
+}
+
+// isImportedDistributionDetection returns true if the driver of an imported cluster is changed to the distribution
+// Rancher detected it runs.
+func isImportedDistributionDetection(oldDriver, newDriver string) bool {
+	return oldDriver == apisv3.ClusterDriverImported && (newDriver == apisv3.ClusterDriverK3s || newDriver == apisv3.ClusterDriverRke2)
+}
+
+// providerConfig returns the name of the provider config field set on the cluster, or an empty string if there is
+// none. The legacy config fields of hosted providers are treated as the same provider as their current fields.
+// The spec.k3sConfig and spec.rke2Config fields only configure upgrades of imported clusters, so they aren't providers.
+func providerConfig(cluster *apisv3.Cluster) string {
+	spec := cluster.Spec
+	switch {
+	case spec.RancherKubernetesEngineConfig != nil:
+		return "rancherKubernetesEngineConfig"
+	case spec.AKSConfig != nil, spec.AzureKubernetesServiceConfig != nil:
+		return "aksConfig"
+	case spec.EKSConfig != nil, spec.AmazonElasticContainerServiceConfig != nil:
+		return "eksConfig"
+	case spec.GKEConfig != nil, spec.GoogleKubernetesEngineConfig != nil:
+		return "gkeConfig"
+	case spec.GenericEngineConfig != nil:
+		return "genericEngineConfig"
+	case spec.ImportedConfig != nil:
+		return "importedConfig"
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package cluster
+
+import (
+	"testing"
+
+	eksv1 "github.com/rancher/eks-operator/pkg/apis/eks.cattle.io/v1"
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	rketypes "github.com/rancher/rke/types"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_validateDriverChange(t *testing.T) {
+	t.Parallel()
+	rancherUser := authenticationv1.UserInfo{
+		Username: "system:serviceaccount:cattle-system:rancher",
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:cattle-system", "system:authenticated"},
+	}
+	adminUser := authenticationv1.UserInfo{
+		Username: "u-admin",
+		Groups:   []string{"system:authenticated"},
+	}
+	migrationAnnotations := map[string]string{common.DriverMigrationAnn: "true"}
+
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		user        authenticationv1.UserInfo
+		oldCluster  *apisv3.Cluster
+		newCluster  *apisv3.Cluster
+		wantAllowed bool
+	}{
+		{
+			name:        "create with any driver",
+			operation:   admissionv1.Create,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{},
+			newCluster:  &apisv3.Cluster{Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverRKE}},
+			wantAllowed: true,
+		},
+		{
+			name:        "unchanged driver",
+			operation:   admissionv1.Update,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverEKS}},
+			newCluster:  &apisv3.Cluster{Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverEKS}},
+			wantAllowed: true,
+		},
+		{
+			name:        "set driver for the first time",
+			operation:   admissionv1.Update,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{},
+			newCluster:  &apisv3.Cluster{Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverImported}},
+			wantAllowed: true,
+		},
+		{
+			name:        "detect distribution of imported cluster",
+			operation:   admissionv1.Update,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverImported}},
+			newCluster:  &apisv3.Cluster{Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverRke2}},
+			wantAllowed: true,
+		},
+		{
+			name:        "change driver",
+			operation:   admissionv1.Update,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverRke2}},
+			newCluster:  &apisv3.Cluster{Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverRKE}},
+			wantAllowed: false,
+		},
+		{
+			name:        "remove driver",
+			operation:   admissionv1.Update,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverRke2}},
+			newCluster:  &apisv3.Cluster{},
+			wantAllowed: false,
+		},
+		{
+			name:        "change driver of the local cluster",
+			operation:   admissionv1.Update,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "local"}, Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverImported}},
+			newCluster:  &apisv3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "local"}, Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverLocal}},
+			wantAllowed: true,
+		},
+		{
+			name:      "change driver with migration annotation as rancher",
+			operation: admissionv1.Update,
+			user:      rancherUser,
+			oldCluster: &apisv3.Cluster{
+				Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverImported},
+			},
+			newCluster: &apisv3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Annotations: migrationAnnotations},
+				Status:     apisv3.ClusterStatus{Driver: apisv3.ClusterDriverRKE},
+			},
+			wantAllowed: true,
+		},
+		{
+			name:      "change driver with migration annotation as another user",
+			operation: admissionv1.Update,
+			user:      adminUser,
+			oldCluster: &apisv3.Cluster{
+				Status: apisv3.ClusterStatus{Driver: apisv3.ClusterDriverImported},
+			},
+			newCluster: &apisv3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Annotations: migrationAnnotations},
+				Status:     apisv3.ClusterStatus{Driver: apisv3.ClusterDriverRKE},
+			},
+			wantAllowed: false,
+		},
+		{
+			name:        "add provider config",
+			operation:   admissionv1.Update,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{},
+			newCluster:  &apisv3.Cluster{Spec: apisv3.ClusterSpec{ClusterSpecBase: apisv3.ClusterSpecBase{RancherKubernetesEngineConfig: &rketypes.RancherKubernetesEngineConfig{}}}},
+			wantAllowed: true,
+		},
+		{
+			name:        "migrate legacy provider config",
+			operation:   admissionv1.Update,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{Spec: apisv3.ClusterSpec{AmazonElasticContainerServiceConfig: &apisv3.MapStringInterface{}}},
+			newCluster:  &apisv3.Cluster{Spec: apisv3.ClusterSpec{EKSConfig: &eksv1.EKSClusterConfigSpec{}}},
+			wantAllowed: true,
+		},
+		{
+			name:        "replace provider config",
+			operation:   admissionv1.Update,
+			user:        adminUser,
+			oldCluster:  &apisv3.Cluster{Spec: apisv3.ClusterSpec{ImportedConfig: &apisv3.ImportedConfig{}}},
+			newCluster:  &apisv3.Cluster{Spec: apisv3.ClusterSpec{ClusterSpecBase: apisv3.ClusterSpecBase{RancherKubernetesEngineConfig: &rketypes.RancherKubernetesEngineConfig{}}}},
+			wantAllowed: false,
+		},
+		{
+			name:      "replace provider config with migration annotation as rancher",
+			operation: admissionv1.Update,
+			user:      rancherUser,
+			oldCluster: &apisv3.Cluster{
+				Spec: apisv3.ClusterSpec{ImportedConfig: &apisv3.ImportedConfig{}},
+			},
+			newCluster: &apisv3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Annotations: migrationAnnotations},
+				Spec:       apisv3.ClusterSpec{ClusterSpecBase: apisv3.ClusterSpecBase{RancherKubernetesEngineConfig: &rketypes.RancherKubernetesEngineConfig{}}},
+			},
+			wantAllowed: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: test.operation,
+					UserInfo:  test.user,
+				},
+			}
+			fieldErr := validateDriverChange(request, test.oldCluster, test.newCluster)
+			if test.wantAllowed {
+				assert.Nil(t, fieldErr)
+			} else {
+				assert.NotNil(t, fieldErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var clusterImportedKubeConfigRule = rules.Register(rules.Rule{
+	ID:            "cluster-imported-kubeconfig",
+	GVR:           managementGVR,
+	Description:   "The kubeconfig of an imported cluster must parse, its current context must resolve to a server URL, and that server must be the API endpoint recorded for the cluster, if any.",
+	Severity:      rules.SeverityWarn,
+	ExampleDenial: "spec.importedConfig.kubeConfig: Invalid value: \"https://10.0.0.12:6443\": server doesn't match the API endpoint https://10.0.0.11:6443 of the cluster",
+	DenialCode:    string(admission.DenialBadRequest),
+})
+
+// validateImportedKubeConfig validates the kubeconfig of an imported cluster when it's set or changed, so that a
+// kubeconfig Rancher can't use is rejected with an actionable error instead of failing the import later. The
+// kubeconfig is only parsed, its server isn't contacted: ImportPreChecker probes it outside of the admission path.
+// The kubeconfig holds credentials, so errors never include it.
+func validateImportedKubeConfig(oldCluster, newCluster *apisv3.Cluster, op admissionv1.Operation) *field.Error {
+	if op != admissionv1.Create && op != admissionv1.Update {
+		return nil
+	}
+	kubeConfig := importedKubeConfig(newCluster)
+	if kubeConfig == "" || (op == admissionv1.Update && kubeConfig == importedKubeConfig(oldCluster)) {
+		return nil
+	}
+
+	path := field.NewPath("spec", "importedConfig", "kubeConfig")
+	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeConfig))
+	if err != nil {
+		return field.Invalid(path, field.OmitValueType{}, clusterImportedKubeConfigRule.Message("kubeconfig can't be used: %v", err))
+	}
+	server, err := url.Parse(config.Host)
+	if err != nil || server.Host == "" {
+		return field.Invalid(path, config.Host, clusterImportedKubeConfigRule.Message("server of the current context must be a URL with a host"))
+	}
+	if endpoint := newCluster.Status.APIEndpoint; endpoint != "" && !sameEndpoint(server, endpoint) {
+		return field.Invalid(path, config.Host, clusterImportedKubeConfigRule.Message("server doesn't match the API endpoint %s of the cluster", endpoint))
+	}
+	return nil
+}
+
+// importedKubeConfig returns the kubeconfig of an imported cluster, or an empty string if there is none.
+func importedKubeConfig(cluster *apisv3.Cluster) string {
+	if cluster.Spec.ImportedConfig == nil {
+		return ""
+	}
+	return cluster.Spec.ImportedConfig.KubeConfig
+}
+
+// sameEndpoint returns true if the server and the endpoint have the same scheme, host and port. Unparsable endpoints
+// are ignored, since the webhook doesn't own the field.
+func sameEndpoint(server *url.URL, endpoint string) bool {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return true
+	}
+	return strings.EqualFold(server.Scheme, parsed.Scheme) && hostPort(server) == hostPort(parsed)
+}
+
+// hostPort returns the lowercase host and port of the URL, with the default port of its scheme if it has none.
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if strings.EqualFold(u.Scheme, "http") {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
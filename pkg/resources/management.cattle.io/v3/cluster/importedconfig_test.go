@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+func importedKubeConfigFor(server string) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: imported
+  cluster:
+    server: %s
+users:
+- name: admin
+  user:
+    token: secret-token
+contexts:
+- name: imported
+  context:
+    cluster: imported
+    user: admin
+current-context: imported
+`, server)
+}
+
+func Test_validateImportedKubeConfig(t *testing.T) {
+	t.Parallel()
+	imported := func(kubeConfig, endpoint string) *apisv3.Cluster {
+		return &apisv3.Cluster{
+			Spec:   apisv3.ClusterSpec{ImportedConfig: &apisv3.ImportedConfig{KubeConfig: kubeConfig}},
+			Status: apisv3.ClusterStatus{APIEndpoint: endpoint},
+		}
+	}
+	valid := importedKubeConfigFor("https://10.0.0.11:6443")
+
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		oldCluster  *apisv3.Cluster
+		newCluster  *apisv3.Cluster
+		wantMessage string
+	}{
+		{
+			name:       "not imported",
+			operation:  admissionv1.Create,
+			oldCluster: &apisv3.Cluster{},
+			newCluster: &apisv3.Cluster{},
+		},
+		{
+			name:       "valid kubeconfig",
+			operation:  admissionv1.Create,
+			oldCluster: &apisv3.Cluster{},
+			newCluster: imported(valid, ""),
+		},
+		{
+			name:       "server matching the endpoint",
+			operation:  admissionv1.Update,
+			oldCluster: imported("", "https://10.0.0.11:6443"),
+			newCluster: imported(valid, "https://10.0.0.11:6443/"),
+		},
+		{
+			name:       "server matching the endpoint with the default port",
+			operation:  admissionv1.Update,
+			oldCluster: imported("", "https://Rancher.example.com"),
+			newCluster: imported(importedKubeConfigFor("https://rancher.example.com:443"), "https://Rancher.example.com"),
+		},
+		{
+			name:        "server not matching the endpoint",
+			operation:   admissionv1.Update,
+			oldCluster:  imported(valid, "https://10.0.0.11:6443"),
+			newCluster:  imported(importedKubeConfigFor("https://10.0.0.12:6443"), "https://10.0.0.11:6443"),
+			wantMessage: `spec.importedConfig.kubeConfig: Invalid value: "https://10.0.0.12:6443": server doesn't match the API endpoint https://10.0.0.11:6443 of the cluster`,
+		},
+		{
+			name:        "unparsable kubeconfig",
+			operation:   admissionv1.Create,
+			oldCluster:  &apisv3.Cluster{},
+			newCluster:  imported("clusters: [", ""),
+			wantMessage: "spec.importedConfig.kubeConfig: Invalid value: kubeconfig can't be used",
+		},
+		{
+			name:        "missing current context",
+			operation:   admissionv1.Create,
+			oldCluster:  &apisv3.Cluster{},
+			newCluster:  imported("apiVersion: v1\nkind: Config\n", ""),
+			wantMessage: "spec.importedConfig.kubeConfig: Invalid value: kubeconfig can't be used",
+		},
+		{
+			name:        "server without a host",
+			operation:   admissionv1.Create,
+			oldCluster:  &apisv3.Cluster{},
+			newCluster:  imported(importedKubeConfigFor("https://"), ""),
+			wantMessage: "server of the current context must be a URL with a host",
+		},
+		{
+			name:       "unchanged invalid kubeconfig",
+			operation:  admissionv1.Update,
+			oldCluster: imported("clusters: [", ""),
+			newCluster: imported("clusters: [", ""),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fieldErr := validateImportedKubeConfig(test.oldCluster, test.newCluster, test.operation)
+			if test.wantMessage == "" {
+				assert.Nil(t, fieldErr)
+				return
+			}
+			require.NotNil(t, fieldErr)
+			assert.Contains(t, fieldErr.Error(), test.wantMessage)
+			assert.NotContains(t, fieldErr.Error(), "secret-token")
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// ImportPreChecked is the condition set on imported clusters with the result of probing their kubeconfig.
+	ImportPreChecked condition.Cond = "ImportPreChecked"
+
+	importProbeTimeout = 10 * time.Second
+
+	importInvalidKubeConfig = "InvalidKubeConfig"
+	importUnreachable       = "Unreachable"
+)
+
+// ImportPreChecker probes the API server of the clusters imported with a kubeconfig, and sets the ImportPreChecked
+// condition with the result, so that users learn about unreachable endpoints or invalid credentials before the import
+// times out. It runs on the workers of the clusters controller, never on the admission path.
+type ImportPreChecker struct {
+	clusters controllerv3.ClusterClient
+	probe    func(kubeConfig string) (string, error)
+	now      func() time.Time
+
+	mutex sync.Mutex
+	// checked holds the hash of the kubeconfig last probed for each cluster, so that each kubeconfig is probed once.
+	checked map[string][sha256.Size]byte
+}
+
+// NewImportPreChecker returns an ImportPreChecker updating the clusters with the given client.
+func NewImportPreChecker(clusters controllerv3.ClusterClient) *ImportPreChecker {
+	return &ImportPreChecker{
+		clusters: clusters,
+		probe:    probeKubeConfig,
+		now:      time.Now,
+		checked:  map[string][sha256.Size]byte{},
+	}
+}
+
+// Sync probes the kubeconfig of the cluster if it wasn't probed yet, and sets the ImportPreChecked condition.
+func (c *ImportPreChecker) Sync(_ string, cluster *v3.Cluster) (*v3.Cluster, error) {
+	if cluster == nil {
+		return nil, nil
+	}
+	if cluster.Spec.ImportedConfig == nil || cluster.Spec.ImportedConfig.KubeConfig == "" || cluster.DeletionTimestamp != nil {
+		c.forget(cluster.Name)
+		return cluster, nil
+	}
+	hash := sha256.Sum256([]byte(cluster.Spec.ImportedConfig.KubeConfig))
+	c.mutex.Lock()
+	checked, ok := c.checked[cluster.Name]
+	c.mutex.Unlock()
+	if ok && checked == hash && ImportPreChecked.GetStatus(cluster) != "" {
+		return cluster, nil
+	}
+
+	cluster = cluster.DeepCopy()
+	version, err := c.probe(cluster.Spec.ImportedConfig.KubeConfig)
+	if err != nil {
+		ImportPreChecked.False(cluster)
+		ImportPreChecked.Reason(cluster, importFailureReason(err))
+		ImportPreChecked.Message(cluster, err.Error())
+	} else {
+		ImportPreChecked.True(cluster)
+		ImportPreChecked.Reason(cluster, "")
+		ImportPreChecked.Message(cluster, fmt.Sprintf("API server reachable, Kubernetes version %s", version))
+	}
+	ImportPreChecked.LastUpdated(cluster, c.now().UTC().Format(time.RFC3339))
+	updated, err := c.clusters.UpdateStatus(cluster)
+	if err != nil {
+		return cluster, fmt.Errorf("failed to set the %s condition: %w", ImportPreChecked, err)
+	}
+	c.mutex.Lock()
+	c.checked[cluster.Name] = hash
+	c.mutex.Unlock()
+	return updated, nil
+}
+
+func (c *ImportPreChecker) forget(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.checked, name)
+}
+
+// importProbeError is returned by the probe when the kubeconfig can't be used at all.
+type importProbeError struct {
+	err error
+}
+
+func (e importProbeError) Error() string {
+	return fmt.Sprintf("invalid kubeconfig: %v", e.err)
+}
+
+func (e importProbeError) Unwrap() error {
+	return e.err
+}
+
+func importFailureReason(err error) string {
+	if errors.As(err, &importProbeError{}) {
+		return importInvalidKubeConfig
+	}
+	return importUnreachable
+}
+
+// probeKubeConfig returns the Kubernetes version of the API server of the kubeconfig.
+func probeKubeConfig(kubeConfig string) (string, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeConfig))
+	if err != nil {
+		return "", importProbeError{err: err}
+	}
+	config.Timeout = importProbeTimeout
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", importProbeError{err: err}
+	}
+	version, err := client.ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to reach the API server at %s: %w", config.Host, err)
+	}
+	return version.GitVersion, nil
+}
@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const importKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: imported
+  cluster:
+    server: %s
+contexts:
+- name: imported
+  context:
+    cluster: imported
+current-context: imported
+`
+
+func importedCluster(kubeConfig string) *apisv3.Cluster {
+	return &apisv3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-import"},
+		Spec:       apisv3.ClusterSpec{ImportedConfig: &apisv3.ImportedConfig{KubeConfig: kubeConfig}},
+	}
+}
+
+func TestImportPreChecker(t *testing.T) {
+	t.Parallel()
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"major":"1","minor":"30","gitVersion":"v1.30.4"}`)
+	}))
+	t.Cleanup(apiServer.Close)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name        string
+		kubeConfig  string
+		wantStatus  string
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name:        "reachable API server",
+			kubeConfig:  fmt.Sprintf(importKubeConfig, apiServer.URL),
+			wantStatus:  "True",
+			wantMessage: "API server reachable, Kubernetes version v1.30.4",
+		},
+		{
+			name:        "invalid kubeconfig",
+			kubeConfig:  "clusters: [",
+			wantStatus:  "False",
+			wantReason:  importInvalidKubeConfig,
+			wantMessage: "invalid kubeconfig",
+		},
+		{
+			name:        "unreachable API server",
+			kubeConfig:  fmt.Sprintf(importKubeConfig, unreachable.URL),
+			wantStatus:  "False",
+			wantReason:  importUnreachable,
+			wantMessage: "failed to reach the API server at " + unreachable.URL,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			clusters := fake.NewMockNonNamespacedClientInterface[*apisv3.Cluster, *apisv3.ClusterList](ctrl)
+			var updated *apisv3.Cluster
+			clusters.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(func(cluster *apisv3.Cluster) (*apisv3.Cluster, error) {
+				updated = cluster
+				return cluster, nil
+			}).Times(1)
+			checker := NewImportPreChecker(clusters)
+			checker.now = func() time.Time { return time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC) }
+
+			cluster := importedCluster(test.kubeConfig)
+			got, err := checker.Sync("", cluster)
+			require.NoError(t, err)
+			require.NotNil(t, updated)
+			assert.Equal(t, test.wantStatus, ImportPreChecked.GetStatus(updated))
+			assert.Equal(t, test.wantReason, ImportPreChecked.GetReason(updated))
+			assert.Contains(t, ImportPreChecked.GetMessage(updated), test.wantMessage)
+			assert.Equal(t, "2025-03-01T00:00:00Z", ImportPreChecked.GetLastUpdated(updated))
+			assert.Empty(t, ImportPreChecked.GetStatus(cluster), "the cached cluster must not be modified")
+
+			// the same kubeconfig isn't probed again
+			_, err = checker.Sync("", got)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestImportPreCheckerSkipsClusters(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	clusters := fake.NewMockNonNamespacedClientInterface[*apisv3.Cluster, *apisv3.ClusterList](ctrl)
+	checker := NewImportPreChecker(clusters)
+	checker.probe = func(string) (string, error) {
+		t.Fatal("unexpected probe")
+		return "", nil
+	}
+
+	got, err := checker.Sync("", nil)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	_, err = checker.Sync("", &apisv3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-custom"}})
+	require.NoError(t, err)
+	deleting := importedCluster("kubeconfig")
+	deleting.DeletionTimestamp = &metav1.Time{}
+	_, err = checker.Sync("", deleting)
+	require.NoError(t, err)
+}
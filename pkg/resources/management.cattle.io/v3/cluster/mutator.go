@@ -1,20 +1,22 @@
 package cluster
 
 import (
-	"encoding/json"
 	"fmt"
 	"reflect"
 
 	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/defaulting"
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
-	"github.com/rancher/webhook/pkg/patch"
+	"github.com/rancher/webhook/pkg/identity"
 	psa "github.com/rancher/webhook/pkg/podsecurityadmission"
+	"github.com/rancher/webhook/pkg/resources/common"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 )
 
 var managementGVR = schema.GroupVersionResource{
@@ -23,15 +25,24 @@ var managementGVR = schema.GroupVersionResource{
 	Resource: "clusters",
 }
 
-func NewManagementClusterMutator(cache v3.PodSecurityAdmissionConfigurationTemplateCache) *ManagementClusterMutator {
-	return &ManagementClusterMutator{
-		psact: cache,
+// NewManagementClusterMutator returns a new mutator for management clusters.
+// Creator annotations and ownership transfers are only processed when an identity resolver is given.
+func NewManagementClusterMutator(cache v3.PodSecurityAdmissionConfigurationTemplateCache, identities *identity.Resolver, sar authorizationv1.SubjectAccessReviewInterface) *ManagementClusterMutator {
+	mutator := &ManagementClusterMutator{
+		psact:      cache,
+		identities: identities,
 	}
+	if identities != nil {
+		mutator.ownership = common.NewOwnershipTransferer(managementGVR, identities, sar)
+	}
+	return mutator
 }
 
 // ManagementClusterMutator implements admission.MutatingAdmissionWebhook.
 type ManagementClusterMutator struct {
-	psact v3.PodSecurityAdmissionConfigurationTemplateCache
+	psact      v3.PodSecurityAdmissionConfigurationTemplateCache
+	identities *identity.Resolver
+	ownership  *common.OwnershipTransferer
 }
 
 // GVR returns the GroupVersionKind for this CRD.
@@ -60,25 +71,52 @@ func (m *ManagementClusterMutator) Admit(request *admission.Request) (*admission
 	if err != nil {
 		return nil, fmt.Errorf("failed to get old and new clusters from request: %w", err)
 	}
-	newClusterRaw, err := json.Marshal(newCluster)
-	if err != nil {
-		return nil, fmt.Errorf("unable to re-marshal new cluster: %w", err)
-	}
+	// diff against a copy of the decoded cluster so that the patch doesn't drop "unknown" fields which were
+	// in the json, but not in the cluster struct. This can occur due to out of date RKE versions
+	originalCluster := newCluster.DeepCopy()
 
 	err = m.mutatePSACT(oldCluster, newCluster, request.Operation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to mutate PSACT: %w", err)
 	}
 
-	m.mutateVersionManagement(newCluster, request.Operation)
+	if err := m.mutateVersionManagement(newCluster, request.Operation); err != nil {
+		return nil, fmt.Errorf("failed to default version management: %w", err)
+	}
+	common.MigrateAnnotations(newCluster, common.ClusterAnnotationMigrations)
+	warnings := common.ApplyNormanShims(newCluster, common.ClusterNormanShims)
 
-	response := &admissionv1.AdmissionResponse{}
-	// we use the re-marshalled new cluster to make sure that the patch doesn't drop "unknown" fields which were
-	// in the json, but not in the cluster struct. This can occur due to out of date RKE versions
-	if err := patch.CreatePatch(newClusterRaw, newCluster, response); err != nil {
+	if request.Operation == admissionv1.Create {
+		if m.identities != nil {
+			if err := common.SetCreatorAnnotations(request, m.identities, newCluster); err != nil {
+				return nil, fmt.Errorf("failed to set creator annotations: %w", err)
+			}
+		}
+		if err := common.SetOriginalCreatorAnnotations(newCluster); err != nil {
+			return nil, fmt.Errorf("failed to record original creator annotations: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Update {
+		common.KeepOriginalCreatorAnnotations(oldCluster, newCluster)
+	}
+
+	if request.Operation == admissionv1.Update && m.ownership != nil {
+		status, err := m.ownership.TransferOwnership(request, oldCluster, newCluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transfer ownership: %w", err)
+		}
+		if status != nil {
+			return &admissionv1.AdmissionResponse{Result: status}, nil
+		}
+	}
+
+	patch, err := admission.PatchFromObjects(originalCluster, newCluster)
+	if err != nil {
 		return nil, fmt.Errorf("failed to create patch: %w", err)
 	}
-	response.Allowed = true
+	response := admission.ResponseAllowedWithPatch(patch)
+	response.Warnings = warnings
 	return response, nil
 }
 
@@ -151,21 +189,16 @@ func (m *ManagementClusterMutator) setPSAConfig(cluster *apisv3.Cluster) error {
 	return nil
 }
 
-// mutateVersionManagement set the annotation for version management if it is missing or has empty value on an imported RKE2/K3s cluster
-func (m *ManagementClusterMutator) mutateVersionManagement(cluster *apisv3.Cluster, operation admissionv1.Operation) {
-	if operation != admissionv1.Update && operation != admissionv1.Create {
-		return
-	}
-	if cluster.Status.Driver != apisv3.ClusterDriverRke2 && cluster.Status.Driver != apisv3.ClusterDriverK3s {
-		return
-	}
+// versionManagementDefault sets the annotation for version management if it is missing or has empty value on an
+// imported RKE2/K3s cluster.
+var versionManagementDefault = defaulting.When(func(cluster *apisv3.Cluster) bool {
+	return cluster.Status.Driver == apisv3.ClusterDriverRke2 || cluster.Status.Driver == apisv3.ClusterDriverK3s
+}, defaulting.Annotation[*apisv3.Cluster](VersionManagementAnno, "system-default"))
 
-	val, ok := cluster.Annotations[VersionManagementAnno]
-	if !ok || val == "" {
-		if cluster.Annotations == nil {
-			cluster.Annotations = make(map[string]string)
-		}
-		cluster.Annotations[VersionManagementAnno] = "system-default"
+// mutateVersionManagement applies versionManagementDefault on create and update.
+func (m *ManagementClusterMutator) mutateVersionManagement(cluster *apisv3.Cluster, operation admissionv1.Operation) error {
+	if operation != admissionv1.Update && operation != admissionv1.Create {
+		return nil
 	}
-	return
+	return defaulting.Default(cluster, versionManagementDefault)
 }
@@ -1,14 +1,21 @@
 package cluster
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
+	"github.com/rancher/webhook/pkg/resources/common"
 	data2 "github.com/rancher/wrangler/v3/pkg/data"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -46,6 +53,123 @@ func TestAdmitPreserveUnknownFields(t *testing.T) {
 	assert.Nil(t, response.Patch)
 }
 
+func TestAdmitOwnershipTransfer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	userCache := fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl)
+	userCache.EXPECT().Get("u-12346").Return(&v3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-12346"},
+		PrincipalIDs: []string{"local://u-12346"},
+	}, nil)
+
+	oldCluster := &v3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "c-2bmj5",
+			Annotations: map[string]string{
+				VersionManagementAnno:   "system-default",
+				common.CreatorIDAnn:     "u-12345",
+				common.NoCreatorRBACAnn: "true",
+			},
+		},
+	}
+	newCluster := oldCluster.DeepCopy()
+	newCluster.Annotations[common.TransferOwnerToAnn] = "u-12346"
+	oldRaw, err := json.Marshal(oldCluster)
+	require.NoError(t, err)
+	newRaw, err := json.Marshal(newCluster)
+	require.NoError(t, err)
+
+	m := NewManagementClusterMutator(nil, identity.NewResolver(userCache), &mockReviewer{})
+	response, err := m.Admit(&admission.Request{
+		Context: context.Background(),
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			UserInfo:  authenticationv1.UserInfo{Username: "admin"},
+			Object:    runtime.RawExtension{Raw: newRaw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, response.Allowed)
+
+	var patchOps []map[string]any
+	require.NoError(t, json.Unmarshal(response.Patch, &patchOps))
+	assert.ElementsMatch(t, []map[string]any{
+		{"op": "replace", "path": "/metadata/annotations/field.cattle.io~1creatorId", "value": "u-12346"},
+		{"op": "remove", "path": "/metadata/annotations/field.cattle.io~1no-creator-rbac"},
+		{"op": "remove", "path": "/metadata/annotations/field.cattle.io~1transfer-owner-to"},
+		{"op": "add", "path": "/metadata/annotations/field.cattle.io~1creator-principal-name", "value": "local://u-12346"},
+		{"op": "add", "path": "/metadata/annotations/webhook.cattle.io~1original-creator-annotations", "value": `{"field.cattle.io/creator-principal-name":"local://u-12346","field.cattle.io/creatorId":"u-12346"}`},
+		{"op": "add", "path": "/metadata/annotations/webhook.cattle.io~1ownership-transfer", "value": `{"from":"u-12345","to":"u-12346","by":"admin"}`},
+	}, patchOps)
+}
+
+func TestAdmitCreatorAnnotations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	userCache := fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl)
+	userCache.EXPECT().Get("u-12345").Return(&v3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: "u-12345"},
+		PrincipalIDs: []string{"local://u-12345"},
+	}, nil).Times(2)
+
+	newRaw, err := json.Marshal(&v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-2bmj5"}})
+	require.NoError(t, err)
+
+	m := NewManagementClusterMutator(nil, identity.NewResolver(userCache), &mockReviewer{})
+	response, err := m.Admit(&admission.Request{
+		Context: context.Background(),
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			UserInfo: authenticationv1.UserInfo{
+				Username: "u-12345",
+				Extra:    map[string]authenticationv1.ExtraValue{"principalid": {"local://u-12345"}},
+			},
+			Object: runtime.RawExtension{Raw: newRaw},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, response.Allowed)
+
+	var patchOps []map[string]any
+	require.NoError(t, json.Unmarshal(response.Patch, &patchOps))
+	assert.ElementsMatch(t, []map[string]any{
+		{"op": "add", "path": "/metadata/annotations", "value": map[string]any{
+			common.CreatorIDAnn:                  "u-12345",
+			common.CreatorPrincipalNameAnn:       "local://u-12345",
+			common.OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creator-principal-name":"local://u-12345","field.cattle.io/creatorId":"u-12345"}`,
+		}},
+	}, patchOps)
+}
+
+func TestAdmitKeepsOriginalCreatorAnnotations(t *testing.T) {
+	const record = `{"field.cattle.io/creatorId":"u-12345"}`
+	oldRaw, err := json.Marshal(&v3.Cluster{ObjectMeta: metav1.ObjectMeta{
+		Name:        "c-2bmj5",
+		Annotations: map[string]string{common.CreatorIDAnn: "u-12345", common.OriginalCreatorAnnotationsAnn: record},
+	}})
+	require.NoError(t, err)
+	newRaw, err := json.Marshal(&v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-2bmj5"}})
+	require.NoError(t, err)
+
+	m := NewManagementClusterMutator(nil, nil, &mockReviewer{})
+	response, err := m.Admit(&admission.Request{
+		Context: context.Background(),
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			UserInfo:  authenticationv1.UserInfo{Username: "automation"},
+			Object:    runtime.RawExtension{Raw: newRaw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, response.Allowed)
+
+	var patchOps []map[string]any
+	require.NoError(t, json.Unmarshal(response.Patch, &patchOps))
+	assert.ElementsMatch(t, []map[string]any{
+		{"op": "add", "path": "/metadata/annotations", "value": map[string]any{common.OriginalCreatorAnnotationsAnn: record}},
+	}, patchOps, "the record stripped with the creator annotations is kept")
+}
+
 func TestMutateVersionManagement(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -98,7 +222,7 @@ func TestMutateVersionManagement(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &ManagementClusterMutator{}
-			m.mutateVersionManagement(tt.cluster, tt.operation)
+			require.NoError(t, m.mutateVersionManagement(tt.cluster, tt.operation))
 			if tt.expect {
 				assert.Equal(t, tt.cluster.Annotations[VersionManagementAnno], "system-default")
 			}
@@ -0,0 +1,33 @@
+package cluster
+
+import (
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateRuleOverrides checks the rule overrides annotation of the cluster when it is set or changed.
+func validateRuleOverrides(request *admission.Request, oldCluster, newCluster *apisv3.Cluster) *field.Error {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return nil
+	}
+	value, ok := newCluster.Annotations[rules.OverridesAnnotation]
+	if !ok || (request.Operation == admissionv1.Update && value == oldCluster.Annotations[rules.OverridesAnnotation]) {
+		return nil
+	}
+	if _, err := rules.ParseOverrides(value); err != nil {
+		return field.Invalid(field.NewPath("metadata", "annotations").Key(rules.OverridesAnnotation), value, err.Error())
+	}
+	return nil
+}
+
+// ruleOverrides returns the rule overrides the request is checked against. Updates are checked against the overrides
+// of the stored cluster, so that an update can't change the overrides it is checked against.
+func ruleOverrides(request *admission.Request, oldCluster, newCluster *apisv3.Cluster) rules.Overrides {
+	if request.Operation == admissionv1.Create {
+		return rules.OverridesOf(newCluster.Annotations)
+	}
+	return rules.OverridesOf(oldCluster.Annotations)
+}
@@ -0,0 +1,93 @@
+package cluster
+
+import (
+	"testing"
+
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateRuleOverrides(t *testing.T) {
+	t.Parallel()
+	withOverrides := func(value string) *apisv3.Cluster {
+		return &apisv3.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "c-abc12",
+			Annotations: map[string]string{rules.OverridesAnnotation: value},
+		}}
+	}
+	tests := []struct {
+		name       string
+		operation  admissionv1.Operation
+		oldCluster *apisv3.Cluster
+		newCluster *apisv3.Cluster
+		wantErr    string
+	}{
+		{
+			name:       "valid overrides on create",
+			operation:  admissionv1.Create,
+			oldCluster: &apisv3.Cluster{},
+			newCluster: withOverrides(`{"cluster-agent-image-tag": "off", "cluster-networking": "deny"}`),
+		},
+		{
+			name:       "unknown rule on create",
+			operation:  admissionv1.Create,
+			oldCluster: &apisv3.Cluster{},
+			newCluster: withOverrides(`{"unknown-rule": "off"}`),
+			wantErr:    "metadata.annotations[webhook.cattle.io/rule-overrides]: Invalid value: \"{\\\"unknown-rule\\\": \\\"off\\\"}\": unknown rule unknown-rule",
+		},
+		{
+			name:       "invalid stage on update",
+			operation:  admissionv1.Update,
+			oldCluster: &apisv3.Cluster{},
+			newCluster: withOverrides(`{"cluster-networking": "silent"}`),
+			wantErr:    `invalid stage "silent" of rule cluster-networking`,
+		},
+		{
+			name:       "unchanged invalid overrides",
+			operation:  admissionv1.Update,
+			oldCluster: withOverrides(`{"unknown-rule": "off"}`),
+			newCluster: withOverrides(`{"unknown-rule": "off"}`),
+		},
+		{
+			name:       "no overrides",
+			operation:  admissionv1.Update,
+			oldCluster: withOverrides(`{"unknown-rule": "off"}`),
+			newCluster: &apisv3.Cluster{},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: test.operation}}
+			fieldErr := validateRuleOverrides(request, test.oldCluster, test.newCluster)
+			if test.wantErr == "" {
+				assert.Nil(t, fieldErr)
+				return
+			}
+			if assert.NotNil(t, fieldErr) {
+				assert.Contains(t, fieldErr.Error(), test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleOverrides(t *testing.T) {
+	t.Parallel()
+	oldCluster := &apisv3.Cluster{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{rules.OverridesAnnotation: `{"cluster-networking": "deny"}`},
+	}}
+	newCluster := &apisv3.Cluster{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{rules.OverridesAnnotation: `{"cluster-networking": "off"}`},
+	}}
+
+	create := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Create}}
+	assert.Equal(t, rules.Overrides{"cluster-networking": rules.StageOff}, ruleOverrides(create, &apisv3.Cluster{}, newCluster))
+	update := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Update}}
+	assert.Equal(t, rules.Overrides{"cluster-networking": rules.StageDeny}, ruleOverrides(update, oldCluster, newCluster),
+		"updates must be checked against the stored overrides")
+}
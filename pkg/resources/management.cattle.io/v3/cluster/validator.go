@@ -6,13 +6,15 @@ import (
 	"net/http"
 	"reflect"
 
-	"github.com/blang/semver"
 	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/identity"
 	psa "github.com/rancher/webhook/pkg/podsecurityadmission"
 	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/rules"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
@@ -21,31 +23,48 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/kubernetes/pkg/registry/rbac/validation"
 )
 
 const (
+	// fleetAddClusterVerb is the fleet workspace verb allowing to add clusters to a workspace.
+	fleetAddClusterVerb = "fleetaddcluster"
+	// fleetWorkspaceMemberVerb is the fleet workspace verb held by the members of a workspace.
+	fleetWorkspaceMemberVerb = "get"
+
+	// annotationsPath is the path of the annotations guarded by the annotation checks.
+	annotationsPath = "metadata.annotations"
+
 	VersionManagementAnno    = "rancher.io/imported-cluster-version-management"
 	VersionManagementSetting = "imported-cluster-version-management"
 )
 
-var parsedRangeLessThan123 = semver.MustParseRange("< 1.23.0-rancher0")
-
 // NewValidator returns a new validator for management clusters.
 func NewValidator(
 	sar authorizationv1.SubjectAccessReviewInterface,
 	cache v3.PodSecurityAdmissionConfigurationTemplateCache,
-	userCache v3.UserCache,
+	identities *identity.Resolver,
 	settingCache v3.SettingCache,
+	roleTemplateResolver *auth.RoleTemplateResolver,
+	resolver validation.AuthorizationRuleResolver,
 ) *Validator {
-	return &Validator{
+	validator := &Validator{
 		admitter: admitter{
-			sar:          sar,
-			psact:        cache,
-			userCache:    userCache,    // userCache is nil for downstream clusters.
-			settingCache: settingCache, // settingCache is nil for downstream clusters
+			sar:                  sar,
+			psact:                cache,
+			identities:           identities,   // identities is nil for downstream clusters.
+			settingCache:         settingCache, // settingCache is nil for downstream clusters
+			roleTemplateResolver: roleTemplateResolver,
+			resolver:             resolver,
+			agentImages:          agentImagePolicyFromEnv(),
 		},
 	}
+	if identities != nil {
+		validator.admitter.ownership = common.NewOwnershipTransferer(managementGVR, identities, sar)
+	}
+	return validator
 }
 
 // Validator ValidatingWebhook for management clusters.
@@ -76,10 +95,14 @@ func (v *Validator) Admitters() []admission.Admitter {
 }
 
 type admitter struct {
-	sar          authorizationv1.SubjectAccessReviewInterface
-	psact        v3.PodSecurityAdmissionConfigurationTemplateCache
-	userCache    v3.UserCache
-	settingCache v3.SettingCache
+	sar                  authorizationv1.SubjectAccessReviewInterface
+	psact                v3.PodSecurityAdmissionConfigurationTemplateCache
+	identities           *identity.Resolver
+	ownership            *common.OwnershipTransferer
+	settingCache         v3.SettingCache
+	roleTemplateResolver *auth.RoleTemplateResolver
+	resolver             validation.AuthorizationRuleResolver
+	agentImages          agentImagePolicy
 }
 
 // Admit handles the webhook admission request sent to this webhook.
@@ -97,26 +120,77 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		return response, nil
 	}
 
-	if a.userCache != nil {
-		// The following checks don't make sense for downstream clusters (userCache == nil)
+	// Controllers updating the status of clusters resubmit annotations they didn't change, so their updates which
+	// leave the annotations as they are skip the annotation checks.
+	if a.identities != nil && !common.IsSystemUpdate(request, oldCluster, newCluster, annotationsPath) {
+		// The following checks don't make sense for downstream clusters (identities == nil)
+		if request.Operation == admissionv1.Create || request.Operation == admissionv1.Update {
+			if errList := common.CheckAnnotationMigrations(newCluster, common.ClusterAnnotationMigrations); len(errList) != 0 {
+				return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+			}
+		}
 		if request.Operation == admissionv1.Create {
-			if fieldErr := common.CheckCreatorIDAndNoCreatorRBAC(newCluster); fieldErr != nil {
+			if errList := common.CheckAnnotationConflicts(newCluster, common.ClusterAnnotationConflicts); len(errList) != 0 {
+				return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+			}
+			if fieldErr := common.CheckOriginalCreatorAnnotationsOnCreate(newCluster); fieldErr != nil {
 				return admission.ResponseBadRequest(fieldErr.Error()), nil
 			}
-			fieldErr, err := common.CheckCreatorPrincipalName(a.userCache, newCluster)
+			fieldErr, err := common.CheckCreatorPrincipalName(request.Context, a.identities, newCluster)
 			if err != nil {
 				return nil, fmt.Errorf("error checking creator principal: %w", err)
 			}
 			if fieldErr != nil {
 				return admission.ResponseBadRequest(fieldErr.Error()), nil
 			}
+			fieldErr, err = a.validateCreatorRoleBindings(request, newCluster)
+			if err != nil {
+				return nil, fmt.Errorf("error checking creator role bindings: %w", err)
+			}
+			if fieldErr != nil {
+				return admission.ResponseBadRequest(fieldErr.Error()), nil
+			}
 		} else if request.Operation == admissionv1.Update {
-			if fieldErr := common.CheckCreatorAnnotationsOnUpdate(oldCluster, newCluster); fieldErr != nil {
+			fieldErr, err := common.CheckCreatorAnnotationsOnUpdate(request, a.ownership, oldCluster, newCluster)
+			if err != nil {
+				return nil, fmt.Errorf("error checking creator annotations: %w", err)
+			}
+			if fieldErr != nil {
 				return admission.ResponseBadRequest(fieldErr.Error()), nil
 			}
 		}
 	}
 
+	if fieldErr := validateRuleOverrides(request, oldCluster, newCluster); fieldErr != nil {
+		return admission.ResponseBadRequest(fieldErr.Error()), nil
+	}
+	overrides := ruleOverrides(request, oldCluster, newCluster)
+
+	var warnings []string
+	if fieldErr, warning := rules.EnforceErrorWith(clusterDriverChangeRule, overrides, validateDriverChange(request, oldCluster, newCluster)); fieldErr != nil {
+		return admission.ResponseBadRequest(fieldErr.Error()), nil
+	} else if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if fieldErr, warning := rules.EnforceErrorWith(clusterImportedKubeConfigRule, overrides, validateImportedKubeConfig(oldCluster, newCluster, request.Operation)); fieldErr != nil {
+		return admission.ResponseBadRequest(fieldErr.Error()), nil
+	} else if warning != "" {
+		warnings = append(warnings, warning)
+	}
+
+	if response = validateNetworking(oldCluster, newCluster, request.Operation, overrides); !response.Allowed {
+		return response, nil
+	}
+	warnings = append(warnings, response.Warnings...)
+
+	if request.Operation == admissionv1.Create || request.Operation == admissionv1.Update {
+		errList, imageWarnings := a.agentImages.validateAgentImages(oldCluster, newCluster, overrides)
+		if len(errList) != 0 {
+			return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+		}
+		warnings = append(warnings, imageWarnings...)
+	}
+
 	response, err = a.validatePSACT(oldCluster, newCluster, request.Operation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate PodSecurityAdmissionConfigurationTemplate(PSACT): %w", err)
@@ -136,9 +210,75 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		}
 	}
 
+	response.Warnings = append(response.Warnings, warnings...)
 	return response, nil
 }
 
+var clusterNetworkingRule = rules.Register(rules.Rule{
+	ID:            "cluster-networking",
+	GVR:           managementGVR,
+	Description:   "The cluster CIDR and service CIDR of an RKE cluster must be valid and must not overlap, the service CIDR must be at most a /12 for IPv4 or /64 for IPv6, the cluster CIDR must have room for a pod CIDR of the node-cidr-mask-size of the kube-controller, /24 (IPv4) or /64 (IPv6) by default, per node, and the cluster DNS IP must be within the service CIDR. On update, only the values that changed are checked, and the room for nodes only when it shrinks.",
+	Severity:      rules.SeverityWarn,
+	ExampleDenial: "spec.rancherKubernetesEngineConfig.services.kubeApi.serviceClusterIpRange: Invalid value: \"10.42.0.0/16\": service CIDR 10.42.0.0/16 overlaps with cluster CIDR 10.42.0.0/16",
+	DenialCode:    string(admission.DenialBadRequest),
+})
+
+// validateNetworking validates the cluster CIDR, service CIDR and cluster DNS IP of an RKE cluster.
+// On update only the values that changed are checked, and the node capacity only when it gets worse.
+func validateNetworking(oldCluster, newCluster *apisv3.Cluster, op admissionv1.Operation, overrides rules.Overrides) *admissionv1.AdmissionResponse {
+	if op != admissionv1.Create && op != admissionv1.Update {
+		return admission.ResponseAllowed()
+	}
+	networking := rkeClusterNetworking(newCluster)
+	if networking == nil {
+		return admission.ResponseAllowed()
+	}
+	var errList field.ErrorList
+	if oldNetworking := rkeClusterNetworking(oldCluster); op == admissionv1.Update && oldNetworking != nil {
+		errList = common.ValidateClusterNetworkingUpdate(*oldNetworking, *networking)
+	} else {
+		errList = common.ValidateClusterNetworking(*networking)
+	}
+	if len(errList) != 0 {
+		return admission.ResponseForRule(clusterNetworkingRule, overrides, clusterNetworkingRule.Message("%s", errList.ToAggregate().Error()))
+	}
+	return admission.ResponseAllowed()
+}
+
+// rkeClusterNetworking returns the networking configuration of an RKE cluster, falling back to the RKE defaults
+// for unset values. It returns nil if the cluster has no RKE config.
+func rkeClusterNetworking(cluster *apisv3.Cluster) *common.ClusterNetworking {
+	config := cluster.Spec.RancherKubernetesEngineConfig
+	if config == nil {
+		return nil
+	}
+	servicesPath := field.NewPath("spec", "rancherKubernetesEngineConfig", "services")
+	networking := &common.ClusterNetworking{
+		ClusterCIDR:     config.Services.KubeController.ClusterCIDR,
+		ClusterCIDRPath: servicesPath.Child("kubeController", "clusterCidr"),
+		ServiceCIDR:     config.Services.KubeAPI.ServiceClusterIPRange,
+		ServiceCIDRPath: servicesPath.Child("kubeApi", "serviceClusterIpRange"),
+		ClusterDNS:      config.Services.Kubelet.ClusterDNSServer,
+		ClusterDNSPath:  servicesPath.Child("kubelet", "clusterDnsServer"),
+		NodeCount:       len(config.Nodes),
+	}
+	networking.SetNodeMaskSizes(config.Services.KubeController.ExtraArgs)
+	if networking.ClusterCIDR == "" {
+		networking.ClusterCIDR = common.DefaultClusterCIDR
+	}
+	if networking.ServiceCIDR == "" && config.Services.KubeController.ServiceClusterIPRange != "" {
+		networking.ServiceCIDR = config.Services.KubeController.ServiceClusterIPRange
+		networking.ServiceCIDRPath = servicesPath.Child("kubeController", "serviceClusterIpRange")
+	}
+	if networking.ServiceCIDR == "" {
+		networking.ServiceCIDR = common.DefaultServiceCIDR
+	}
+	if networking.ClusterDNS == "" {
+		networking.ClusterDNS = common.DefaultClusterDNSFor(networking.ServiceCIDR)
+	}
+	return networking
+}
+
 func toExtra(extra map[string]authenticationv1.ExtraValue) map[string]v1.ExtraValue {
 	result := map[string]v1.ExtraValue{}
 	for k, v := range extra {
@@ -172,14 +312,52 @@ func (a *admitter) validateFleetPermissions(request *admission.Request, oldClust
 		}, nil
 	}
 
+	workspace := newCluster.Spec.FleetWorkspaceName
+	status, err := a.fleetWorkspaceAccess(request, fleetAddClusterVerb, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check SubjectAccessReview for cluster [%s]: %w", newCluster.Name, err)
+	}
+	if !status.Allowed {
+		return fleetWorkspaceDenied(status.Reason), nil
+	}
+
+	// Moving a cluster between workspaces also requires the permission to manage the clusters of the source workspace,
+	// and membership in the target workspace, so that the permission to add clusters to a workspace can't be used to
+	// move clusters out of other workspaces into one the user can't access.
+	if request.Operation == admissionv1.Update && oldCluster.Spec.FleetWorkspaceName != "" {
+		source := oldCluster.Spec.FleetWorkspaceName
+		status, err := a.fleetWorkspaceAccess(request, fleetAddClusterVerb, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check SubjectAccessReview for cluster [%s]: %w", newCluster.Name, err)
+		}
+		if !status.Allowed {
+			return fleetWorkspaceDenied(fmt.Sprintf("user %s can't move cluster %s from fleet workspace %s to %s: not allowed to %s in fleet workspace %s",
+				request.UserInfo.Username, newCluster.Name, source, workspace, fleetAddClusterVerb, source)), nil
+		}
+
+		status, err = a.fleetWorkspaceAccess(request, fleetWorkspaceMemberVerb, workspace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check SubjectAccessReview for cluster [%s]: %w", newCluster.Name, err)
+		}
+		if !status.Allowed {
+			return fleetWorkspaceDenied(fmt.Sprintf("user %s can't move cluster %s from fleet workspace %s to %s: not a member of fleet workspace %s",
+				request.UserInfo.Username, newCluster.Name, oldCluster.Spec.FleetWorkspaceName, workspace, workspace)), nil
+		}
+	}
+
+	return admission.ResponseAllowed(), nil
+}
+
+// fleetWorkspaceAccess checks whether the requester is allowed the verb on the fleet workspace.
+func (a *admitter) fleetWorkspaceAccess(request *admission.Request, verb, workspace string) (*v1.SubjectAccessReviewStatus, error) {
 	resp, err := a.sar.Create(request.Context, &v1.SubjectAccessReview{
 		Spec: v1.SubjectAccessReviewSpec{
 			ResourceAttributes: &v1.ResourceAttributes{
-				Verb:     "fleetaddcluster",
+				Verb:     verb,
 				Version:  "v3",
 				Resource: "fleetworkspaces",
 				Group:    "management.cattle.io",
-				Name:     newCluster.Spec.FleetWorkspaceName,
+				Name:     workspace,
 			},
 			User:   request.UserInfo.Username,
 			Groups: request.UserInfo.Groups,
@@ -188,22 +366,22 @@ func (a *admitter) validateFleetPermissions(request *admission.Request, oldClust
 		},
 	}, metav1.CreateOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to check SubjectAccessReview for cluster [%s]: %w", newCluster.Name, err)
+		return nil, err
 	}
+	return &resp.Status, nil
+}
 
-	if !resp.Status.Allowed {
-		return &admissionv1.AdmissionResponse{
-			Result: &metav1.Status{
-				Status:  "Failure",
-				Message: resp.Status.Reason,
-				Reason:  metav1.StatusReasonUnauthorized,
-				Code:    http.StatusUnauthorized,
-			},
-			Allowed: false,
-		}, nil
+// fleetWorkspaceDenied returns the response denying a change of the fleet workspace of a cluster.
+func fleetWorkspaceDenied(message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Result: &metav1.Status{
+			Status:  "Failure",
+			Message: message,
+			Reason:  metav1.StatusReasonUnauthorized,
+			Code:    http.StatusUnauthorized,
+		},
+		Allowed: false,
 	}
-
-	return admission.ResponseAllowed(), nil
 }
 
 // validatePSACT validates the cluster spec when PodSecurityAdmissionConfigurationTemplate is used.
@@ -220,17 +398,20 @@ func (a *admitter) validatePSACT(oldCluster, newCluster *apisv3.Cluster, op admi
 	newTemplateName := newCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
 	oldTemplateName := oldCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
 
-	parsedVersion, err := psa.GetClusterVersion(newCluster.Spec.RancherKubernetesEngineConfig.Version)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse cluster version: %w", err)
-	}
-
-	if parsedRangeLessThan123(parsedVersion) && newTemplateName != "" {
-		return admission.ResponseBadRequest("PodSecurityAdmissionConfigurationTemplate(PSACT) is only supported in k8s version 1.23 and above"), nil
-	}
-
 	if newTemplateName != "" {
-		response, err := a.checkPSAConfigOnCluster(newCluster)
+		// validate that the template exists and can be used with the k8s version of the cluster
+		template, err := a.psact.Get(newTemplateName)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return admission.ResponseBadRequest(field.NotFound(field.NewPath("spec", "defaultPodSecurityAdmissionConfigurationTemplateName"), newTemplateName).Error()), nil
+			}
+			return nil, fmt.Errorf("failed to get PodSecurityAdmissionConfigurationTemplate [%s]: %w", newTemplateName, err)
+		}
+		if err := psa.CheckTemplateCompatibility(template, newCluster.Spec.RancherKubernetesEngineConfig.Version); err != nil {
+			return admission.ResponseBadRequest(err.Error()), nil
+		}
+
+		response, err := a.checkPSAConfigOnCluster(newCluster, template)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check the PodSecurity Config in the cluster %s: %w", newCluster.Name, err)
 		}
@@ -265,8 +446,9 @@ func (a *admitter) validatePSACT(oldCluster, newCluster *apisv3.Cluster, op admi
 	return admission.ResponseAllowed(), nil
 }
 
-// checkPSAConfigOnCluster validates the cluster spec when DefaultPodSecurityAdmissionConfigurationTemplateName is set.
-func (a *admitter) checkPSAConfigOnCluster(cluster *apisv3.Cluster) (*admissionv1.AdmissionResponse, error) {
+// checkPSAConfigOnCluster validates the cluster spec when DefaultPodSecurityAdmissionConfigurationTemplateName is set
+// to the given template.
+func (a *admitter) checkPSAConfigOnCluster(cluster *apisv3.Cluster, template *apisv3.PodSecurityAdmissionConfigurationTemplate) (*admissionv1.AdmissionResponse, error) {
 	// validate that extra_args.admission-control-config-file is not set at the same time
 	_, found := cluster.Spec.RancherKubernetesEngineConfig.Services.KubeAPI.ExtraArgs["admission-control-config-file"]
 	if found {
@@ -274,15 +456,6 @@ func (a *admitter) checkPSAConfigOnCluster(cluster *apisv3.Cluster) (*admissionv
 	}
 	// validate that the configuration for PodSecurityAdmission under the kube-api.admission_configuration section
 	// matches the content of the PodSecurityAdmissionConfigurationTemplate specified in the cluster
-	name := cluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
-	template, err := a.psact.Get(name)
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			return admission.ResponseBadRequest(err.Error()), nil
-		}
-		return nil, fmt.Errorf("failed to get PodSecurityAdmissionConfigurationTemplate [%s]: %w", name, err)
-	}
-
 	fromTemplate, err := psa.GetPluginConfigFromTemplate(template, cluster.Spec.RancherKubernetesEngineConfig.Version)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get the PluginConfig: %w", err)
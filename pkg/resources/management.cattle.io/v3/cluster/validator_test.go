@@ -3,15 +3,23 @@ package cluster
 import (
 	"context"
 	"encoding/json"
+	"slices"
 	"testing"
+	"time"
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	rketypes "github.com/rancher/rke/types"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
+	psa "github.com/rancher/webhook/pkg/podsecurityadmission"
 	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/rules"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	authorizationv1 "k8s.io/api/authorization/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -67,6 +75,7 @@ func TestAdmit(t *testing.T) {
 		oldCluster           v3.Cluster
 		newCluster           v3.Cluster
 		operation            admissionv1.Operation
+		username             string
 		expectAllowed        bool
 		expectedReason       metav1.StatusReason
 		expectContainWarning bool
@@ -472,6 +481,47 @@ func TestAdmit(t *testing.T) {
 			expectAllowed:        true,
 			expectContainWarning: true,
 		},
+		{
+			name:      "system update of the status of a cluster",
+			operation: admissionv1.Update,
+			username:  "system:serviceaccount:cattle-system:rancher",
+			oldCluster: v3.Cluster{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{common.CreatorIDAnn: "u-12345"},
+			}},
+			newCluster: v3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{common.CreatorIDAnn: "u-12345"}},
+				Status:     v3.ClusterStatus{Driver: "imported"},
+			},
+			expectAllowed: true,
+		},
+		{
+			name:      "system update of the status of a stale cluster",
+			operation: admissionv1.Update,
+			username:  "system:serviceaccount:cattle-system:rancher",
+			oldCluster: v3.Cluster{ObjectMeta: metav1.ObjectMeta{
+				Annotations:   map[string]string{common.CreatorIDAnn: "u-12345"},
+				ManagedFields: []metav1.ManagedFieldsEntry{managedFields("rancher", 1, `{"f:status":{"f:conditions":{}}}`)},
+			}},
+			newCluster: v3.Cluster{ObjectMeta: metav1.ObjectMeta{
+				Annotations:   map[string]string{common.CreatorIDAnn: "u-67890"},
+				ManagedFields: []metav1.ManagedFieldsEntry{managedFields("rancher", 1, `{"f:status":{"f:conditions":{}}}`)},
+			}},
+			expectAllowed:  false,
+			expectedReason: metav1.StatusReasonBadRequest,
+		},
+		{
+			name:      "user update of the status of a stale cluster",
+			operation: admissionv1.Update,
+			username:  "u-12345",
+			oldCluster: v3.Cluster{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{common.CreatorIDAnn: "u-12345"},
+			}},
+			newCluster: v3.Cluster{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{common.CreatorIDAnn: "u-67890"},
+			}},
+			expectAllowed:  false,
+			expectedReason: metav1.StatusReasonBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -479,7 +529,7 @@ func TestAdmit(t *testing.T) {
 			v := &Validator{
 				admitter: admitter{
 					sar:          &mockReviewer{},
-					userCache:    userCache,
+					identities:   identity.NewResolver(userCache),
 					settingCache: settingCache,
 				},
 			}
@@ -501,6 +551,7 @@ func TestAdmit(t *testing.T) {
 						Raw: oldClusterBytes,
 					},
 					Operation: tt.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.username},
 				},
 			})
 			assert.NoError(t, err)
@@ -518,6 +569,150 @@ func TestAdmit(t *testing.T) {
 	}
 }
 
+// managedFields returns the managed fields entry of an update by the manager at the time, in seconds.
+func managedFields(manager string, seconds int64, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:    manager,
+		Operation:  metav1.ManagedFieldsOperationUpdate,
+		APIVersion: "management.cattle.io/v3",
+		Time:       &metav1.Time{Time: time.Unix(seconds, 0)},
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func Test_validateNetworking(t *testing.T) {
+	t.Parallel()
+	rkeCluster := func(clusterCIDR, serviceCIDR, clusterDNS string, nodes int) *v3.Cluster {
+		return &v3.Cluster{
+			Spec: v3.ClusterSpec{
+				ClusterSpecBase: v3.ClusterSpecBase{
+					RancherKubernetesEngineConfig: &rketypes.RancherKubernetesEngineConfig{
+						Nodes: make([]rketypes.RKEConfigNode, nodes),
+						Services: rketypes.RKEConfigServices{
+							KubeController: rketypes.KubeControllerService{ClusterCIDR: clusterCIDR},
+							KubeAPI:        rketypes.KubeAPIService{ServiceClusterIPRange: serviceCIDR},
+							Kubelet:        rketypes.KubeletService{ClusterDNSServer: clusterDNS},
+						},
+					},
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name          string
+		operation     admissionv1.Operation
+		oldCluster    *v3.Cluster
+		newCluster    *v3.Cluster
+		expectAllowed bool
+	}{
+		{
+			name:          "create without rke config",
+			operation:     admissionv1.Create,
+			newCluster:    &v3.Cluster{},
+			expectAllowed: true,
+		},
+		{
+			name:          "create with default networking",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("", "", "", 3),
+			expectAllowed: true,
+		},
+		{
+			name:          "create with custom networking",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("172.16.0.0/16", "172.17.0.0/16", "172.17.0.10", 3),
+			expectAllowed: true,
+		},
+		{
+			name:          "create with custom service CIDR without cluster DNS",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("172.16.0.0/16", "172.17.0.0/16", "", 3),
+			expectAllowed: true,
+		},
+		{
+			name:          "create with overlapping CIDRs",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("10.0.0.0/8", "", "", 3),
+			expectAllowed: false,
+		},
+		{
+			name:          "create with cluster DNS outside of the default service CIDR",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("", "", "10.44.0.10", 3),
+			expectAllowed: false,
+		},
+		{
+			name:      "create with service CIDR from kube-controller",
+			operation: admissionv1.Create,
+			newCluster: func() *v3.Cluster {
+				cluster := rkeCluster("", "", "172.17.0.10", 3)
+				cluster.Spec.RancherKubernetesEngineConfig.Services.KubeController.ServiceClusterIPRange = "172.17.0.0/16"
+				return cluster
+			}(),
+			expectAllowed: true,
+		},
+		{
+			name:          "update adding nodes beyond cluster CIDR capacity",
+			operation:     admissionv1.Update,
+			oldCluster:    rkeCluster("10.42.0.0/23", "", "", 2),
+			newCluster:    rkeCluster("10.42.0.0/23", "", "", 3),
+			expectAllowed: false,
+		},
+		{
+			name:      "create with nodes fitting the cluster CIDR with a custom node mask size",
+			operation: admissionv1.Create,
+			newCluster: func() *v3.Cluster {
+				cluster := rkeCluster("10.42.0.0/23", "", "", 4)
+				cluster.Spec.RancherKubernetesEngineConfig.Services.KubeController.ExtraArgs = map[string]string{"node-cidr-mask-size": "25"}
+				return cluster
+			}(),
+			expectAllowed: true,
+		},
+		{
+			name:          "update adding nodes with an unchanged invalid cluster DNS",
+			operation:     admissionv1.Update,
+			oldCluster:    rkeCluster("", "", "10.44.0.10", 2),
+			newCluster:    rkeCluster("", "", "10.44.0.10", 3),
+			expectAllowed: true,
+		},
+		{
+			name:          "update with unchanged invalid networking",
+			operation:     admissionv1.Update,
+			oldCluster:    rkeCluster("10.0.0.0/8", "", "", 3),
+			newCluster:    rkeCluster("10.0.0.0/8", "", "", 3),
+			expectAllowed: true,
+		},
+		{
+			name:          "delete is not validated",
+			operation:     admissionv1.Delete,
+			oldCluster:    rkeCluster("10.0.0.0/8", "", "", 3),
+			newCluster:    rkeCluster("10.0.0.0/8", "", "", 3),
+			expectAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v3.Cluster{}
+			}
+			res := validateNetworking(oldCluster, tt.newCluster, tt.operation, rules.Overrides{clusterNetworkingRule.ID: rules.StageDeny})
+			assert.Equal(t, tt.expectAllowed, res.Allowed)
+			if !tt.expectAllowed {
+				assert.Equal(t, metav1.StatusReasonBadRequest, res.Result.Reason)
+
+				// Without enforcement moving the rule to deny, the request is only warned.
+				res = validateNetworking(oldCluster, tt.newCluster, tt.operation, nil)
+				assert.True(t, res.Allowed)
+				assert.NotEmpty(t, res.Warnings)
+			}
+		})
+	}
+}
+
 func Test_versionManagementEnabled(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl)
@@ -613,3 +808,198 @@ func Test_versionManagementEnabled(t *testing.T) {
 		})
 	}
 }
+
+func Test_validatePSACT(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	psactCache := fake.NewMockNonNamespacedCacheInterface[*v3.PodSecurityAdmissionConfigurationTemplate](ctrl)
+	templates := map[string]*v3.PodSecurityAdmissionConfigurationTemplate{
+		"restricted": {
+			ObjectMeta: metav1.ObjectMeta{Name: "restricted"},
+			Configuration: v3.PodSecurityAdmissionConfigurationTemplateSpec{
+				Defaults: v3.PodSecurityAdmissionConfigurationTemplateDefaults{Enforce: "restricted", EnforceVersion: "latest"},
+			},
+		},
+		"pinned": {
+			ObjectMeta: metav1.ObjectMeta{Name: "pinned"},
+			Configuration: v3.PodSecurityAdmissionConfigurationTemplateSpec{
+				Defaults: v3.PodSecurityAdmissionConfigurationTemplateDefaults{Enforce: "restricted", EnforceVersion: "v1.28"},
+			},
+		},
+	}
+	psactCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.PodSecurityAdmissionConfigurationTemplate, error) {
+		if template, ok := templates[name]; ok {
+			return template, nil
+		}
+		return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+	}).AnyTimes()
+
+	rkeCluster := func(templateName, version string) *v3.Cluster {
+		cluster := &v3.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "c-2bmj5"},
+			Spec: v3.ClusterSpec{
+				ClusterSpecBase: v3.ClusterSpecBase{
+					DefaultPodSecurityAdmissionConfigurationTemplateName: templateName,
+					RancherKubernetesEngineConfig:                        &rketypes.RancherKubernetesEngineConfig{Version: version},
+				},
+			},
+		}
+		if template, ok := templates[templateName]; ok {
+			plugin, err := psa.GetPluginConfigFromTemplate(template, version)
+			if err == nil {
+				cluster.Spec.RancherKubernetesEngineConfig.Services.KubeAPI.AdmissionConfiguration = psa.GetAdmissionConfigFromCluster(cluster)
+				cluster.Spec.RancherKubernetesEngineConfig.Services.KubeAPI.AdmissionConfiguration.Plugins = append(
+					cluster.Spec.RancherKubernetesEngineConfig.Services.KubeAPI.AdmissionConfiguration.Plugins, plugin)
+			}
+		}
+		return cluster
+	}
+
+	tests := []struct {
+		name          string
+		cluster       *v3.Cluster
+		expectAllowed bool
+		expectMessage string
+	}{
+		{
+			name:          "no template",
+			cluster:       rkeCluster("", "v1.22.17-rancher1-1"),
+			expectAllowed: true,
+		},
+		{
+			name:          "existing template",
+			cluster:       rkeCluster("restricted", "v1.27.6-rancher1-1"),
+			expectAllowed: true,
+		},
+		{
+			name:          "missing template",
+			cluster:       rkeCluster("missing", "v1.27.6-rancher1-1"),
+			expectMessage: `spec.defaultPodSecurityAdmissionConfigurationTemplateName: Not found: "missing"`,
+		},
+		{
+			name:          "k8s version without PodSecurity admission",
+			cluster:       rkeCluster("restricted", "v1.22.17-rancher1-1"),
+			expectMessage: "only supported in k8s version 1.23 and above",
+		},
+		{
+			name:          "template pinning a newer policy version",
+			cluster:       rkeCluster("pinned", "v1.27.6-rancher1-1"),
+			expectMessage: "enforce-version v1.28 of PodSecurityAdmissionConfigurationTemplate(PSACT) pinned is newer",
+		},
+		{
+			name:          "template pinning the same policy version",
+			cluster:       rkeCluster("pinned", "v1.28.2-rancher1-1"),
+			expectAllowed: true,
+		},
+	}
+	a := admitter{psact: psactCache}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := a.validatePSACT(&v3.Cluster{}, tt.cluster, admissionv1.Create)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectAllowed, response.Allowed)
+			if !tt.expectAllowed {
+				assert.Contains(t, response.Result.Message, tt.expectMessage)
+			}
+		})
+	}
+}
+
+// verbReviewer allows the fleet workspace verbs it holds for each workspace.
+type verbReviewer struct {
+	v1.SubjectAccessReviewExpansion
+	allowed map[string][]string
+}
+
+func (m *verbReviewer) Create(
+	_ context.Context,
+	review *authorizationv1.SubjectAccessReview,
+	_ metav1.CreateOptions,
+) (*authorizationv1.SubjectAccessReview, error) {
+	attributes := review.Spec.ResourceAttributes
+	review.Status.Allowed = slices.Contains(m.allowed[attributes.Name], attributes.Verb)
+	return review, nil
+}
+
+func Test_validateFleetPermissions(t *testing.T) {
+	t.Parallel()
+	withWorkspace := func(workspace string) *v3.Cluster {
+		return &v3.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "c-2bmj5"},
+			Spec:       v3.ClusterSpec{FleetWorkspaceName: workspace},
+		}
+	}
+	tests := []struct {
+		name          string
+		operation     admissionv1.Operation
+		oldWorkspace  string
+		newWorkspace  string
+		allowed       map[string][]string
+		expectAllowed bool
+		expectMessage string
+	}{
+		{
+			name:          "create in a workspace the user can add clusters to",
+			operation:     admissionv1.Create,
+			newWorkspace:  "fleet-a",
+			allowed:       map[string][]string{"fleet-a": {"fleetaddcluster"}},
+			expectAllowed: true,
+		},
+		{
+			name:         "create in a workspace the user can't add clusters to",
+			operation:    admissionv1.Create,
+			newWorkspace: "fleet-a",
+			allowed:      map[string][]string{"fleet-a": {"get"}},
+		},
+		{
+			name:          "move to a workspace the user is a member of",
+			operation:     admissionv1.Update,
+			oldWorkspace:  "fleet-a",
+			newWorkspace:  "fleet-b",
+			allowed:       map[string][]string{"fleet-a": {"fleetaddcluster"}, "fleet-b": {"fleetaddcluster", "get"}},
+			expectAllowed: true,
+		},
+		{
+			name:          "move from a workspace the user can't manage the clusters of",
+			operation:     admissionv1.Update,
+			oldWorkspace:  "fleet-a",
+			newWorkspace:  "fleet-b",
+			allowed:       map[string][]string{"fleet-a": {"get"}, "fleet-b": {"fleetaddcluster", "get"}},
+			expectMessage: "can't move cluster c-2bmj5 from fleet workspace fleet-a to fleet-b: not allowed to fleetaddcluster in fleet workspace fleet-a",
+		},
+		{
+			name:          "move to a workspace the user isn't a member of",
+			operation:     admissionv1.Update,
+			oldWorkspace:  "fleet-a",
+			newWorkspace:  "fleet-b",
+			allowed:       map[string][]string{"fleet-a": {"fleetaddcluster", "get"}, "fleet-b": {"fleetaddcluster"}},
+			expectMessage: "can't move cluster c-2bmj5 from fleet workspace fleet-a to fleet-b: not a member of fleet workspace fleet-b",
+		},
+		{
+			name:          "unchanged workspace isn't checked",
+			operation:     admissionv1.Update,
+			oldWorkspace:  "fleet-a",
+			newWorkspace:  "fleet-a",
+			expectAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := admitter{sar: &verbReviewer{allowed: tt.allowed}}
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: "u-12345"},
+				},
+				Context: context.Background(),
+			}
+			response, err := a.validateFleetPermissions(request, withWorkspace(tt.oldWorkspace), withWorkspace(tt.newWorkspace))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectAllowed, response.Allowed)
+			if tt.expectMessage != "" {
+				assert.Contains(t, response.Result.Message, tt.expectMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package clusterroletemplatebinding
+
+import (
+	"fmt"
+	"strings"
+
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+// principalSchemeSeparator separates the provider scheme of a principal ID, e.g. local://, from the provider's ID.
+const principalSchemeSeparator = "://"
+
+// Mutator implements admission.MutatingAdmissionHandler for ClusterRoleTemplateBindings.
+type Mutator struct{}
+
+// NewMutator returns a new mutator for ClusterRoleTemplateBindings.
+func NewMutator() *Mutator {
+	return &Mutator{}
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (m *Mutator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this mutator.
+func (m *Mutator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+}
+
+// MutatingWebhook returns the MutatingWebhook used for this CRD.
+func (m *Mutator) MutatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.MutatingWebhook {
+	mutatingWebhook := admission.NewDefaultMutatingWebhook(m, clientConfig, admissionregistrationv1.NamespacedScope, m.Operations())
+	return []admissionregistrationv1.MutatingWebhook{*mutatingWebhook}
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (m *Mutator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("clusterRoleTemplateBindingMutator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	crtb, err := objectsv3.ClusterRoleTemplateBindingFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode CRTB from request: %w", err)
+	}
+	if crtb.ClusterName == "" {
+		// older clients omit the cluster name, which must match the namespace of the binding
+		crtb.ClusterName = request.Namespace
+	}
+	normalizeSubjects(crtb)
+
+	response := &admissionv1.AdmissionResponse{}
+	if err := patch.CreatePatch(request.Object.Raw, crtb, response); err != nil {
+		return nil, fmt.Errorf("failed to create patch: %w", err)
+	}
+	response.Allowed = true
+	return response, nil
+}
+
+// normalizeSubjects lowercases the Rancher user name, and the provider scheme of the principal IDs, of the binding,
+// which Rancher always creates in lowercase. The rest of a principal ID belongs to the auth provider, which may be case
+// sensitive, so it's left as is.
+func normalizeSubjects(crtb *apisv3.ClusterRoleTemplateBinding) {
+	crtb.UserName = strings.ToLower(crtb.UserName)
+	crtb.UserPrincipalName = normalizePrincipal(crtb.UserPrincipalName)
+	crtb.GroupPrincipalName = normalizePrincipal(crtb.GroupPrincipalName)
+}
+
+// normalizePrincipal lowercases the provider scheme of the principal ID, e.g. GitHub_user://1234 becomes
+// github_user://1234.
+func normalizePrincipal(principal string) string {
+	scheme, id, ok := strings.Cut(principal, principalSchemeSeparator)
+	if !ok {
+		return principal
+	}
+	return strings.ToLower(scheme) + principalSchemeSeparator + id
+}
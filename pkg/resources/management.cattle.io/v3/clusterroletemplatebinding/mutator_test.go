@@ -0,0 +1,86 @@
+package clusterroletemplatebinding_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/clusterroletemplatebinding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMutatorAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		crtb apisv3.ClusterRoleTemplateBinding
+		want apisv3.ClusterRoleTemplateBinding
+	}{
+		{
+			name: "unchanged",
+			crtb: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-abc", UserName: "u-abc", RoleTemplateName: "cluster-member"},
+			want: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-abc", UserName: "u-abc", RoleTemplateName: "cluster-member"},
+		},
+		{
+			name: "cluster name from the namespace",
+			crtb: apisv3.ClusterRoleTemplateBinding{UserName: "u-abc", RoleTemplateName: "cluster-member"},
+			want: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-abc", UserName: "u-abc", RoleTemplateName: "cluster-member"},
+		},
+		{
+			name: "mismatched cluster name is left to the validator",
+			crtb: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-other", UserName: "u-abc"},
+			want: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-other", UserName: "u-abc"},
+		},
+		{
+			name: "user name casing",
+			crtb: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-abc", UserName: "U-ABC", UserPrincipalName: "Local://U-ABC"},
+			want: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-abc", UserName: "u-abc", UserPrincipalName: "local://U-ABC"},
+		},
+		{
+			name: "group principal scheme casing",
+			crtb: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-abc", GroupPrincipalName: "ActiveDirectory_Group://CN=Admins,DC=Example"},
+			want: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-abc", GroupPrincipalName: "activedirectory_group://CN=Admins,DC=Example"},
+		},
+		{
+			name: "principal without a scheme",
+			crtb: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-abc", GroupPrincipalName: "Admins"},
+			want: apisv3.ClusterRoleTemplateBinding{ClusterName: "c-abc", GroupPrincipalName: "Admins"},
+		},
+	}
+	mutator := clusterroletemplatebinding.NewMutator()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.crtb.ObjectMeta = metav1.ObjectMeta{Name: "crtb-abc", Namespace: "c-abc"}
+			test.want.ObjectMeta = test.crtb.ObjectMeta
+			raw, err := json.Marshal(test.crtb)
+			require.NoError(t, err)
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Namespace: "c-abc",
+				Object:    runtime.RawExtension{Raw: raw},
+			}}
+
+			response, err := mutator.Admit(request)
+			require.NoError(t, err)
+			require.True(t, response.Allowed)
+			got := test.crtb
+			if response.Patch != nil {
+				patch, err := jsonpatch.DecodePatch(response.Patch)
+				require.NoError(t, err)
+				patched, err := patch.Apply(raw)
+				require.NoError(t, err)
+				got = apisv3.ClusterRoleTemplateBinding{}
+				require.NoError(t, json.Unmarshal(patched, &got))
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
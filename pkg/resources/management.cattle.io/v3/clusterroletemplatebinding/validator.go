@@ -101,7 +101,7 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	}
 
 	if request.Operation == admissionv1.Create {
-		if err = a.validateCreateFields(crtb, fieldPath); err != nil {
+		if err = a.validateCreateFields(request, crtb, fieldPath); err != nil {
 			var fieldErr *field.Error
 			if errors.As(err, &fieldErr) {
 				return admission.ResponseBadRequest(fieldErr.Error()), nil
@@ -110,7 +110,7 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		}
 	}
 
-	roleTemplate, err := a.roleTemplateResolver.RoleTemplateCache().Get(crtb.RoleTemplateName)
+	roleTemplate, err := admission.Lookup(request, a.roleTemplateResolver.RoleTemplateCache(), crtb.RoleTemplateName)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return &admissionv1.AdmissionResponse{Allowed: true}, nil
@@ -155,7 +155,7 @@ func validateUpdateFields(oldCRTB, newCRTB *apisv3.ClusterRoleTemplateBinding, f
 }
 
 // validateCreateFields checks if all required fields are present and valid.
-func (a *admitter) validateCreateFields(newCRTB *apisv3.ClusterRoleTemplateBinding, fieldPath *field.Path) error {
+func (a *admitter) validateCreateFields(request *admission.Request, newCRTB *apisv3.ClusterRoleTemplateBinding, fieldPath *field.Path) error {
 	const reason = "field is required"
 
 	hasUserTarget := newCRTB.UserName != "" || newCRTB.UserPrincipalName != ""
@@ -173,7 +173,7 @@ func (a *admitter) validateCreateFields(newCRTB *apisv3.ClusterRoleTemplateBindi
 		return field.Forbidden(fieldPath, "clusterName and namespace must be the same value")
 	}
 
-	cluster, err := a.clusterCache.Get(newCRTB.ClusterName)
+	cluster, err := admission.Lookup(request, a.clusterCache, newCRTB.ClusterName)
 	clusterNotFoundErr := field.Invalid(fieldPath.Child("clusterName"), newCRTB.ClusterName, fmt.Sprintf("specified cluster %s not found", newCRTB.ClusterName))
 	if err != nil {
 		if apierrors.IsNotFound(err) {
@@ -189,7 +189,7 @@ func (a *admitter) validateCreateFields(newCRTB *apisv3.ClusterRoleTemplateBindi
 		return field.Required(fieldPath.Child("roleTemplateName"), reason)
 	}
 
-	roleTemplate, err := a.roleTemplateResolver.RoleTemplateCache().Get(newCRTB.RoleTemplateName)
+	roleTemplate, err := admission.Lookup(request, a.roleTemplateResolver.RoleTemplateCache(), newCRTB.RoleTemplateName)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return field.Invalid(fieldPath.Child("roleTemplateName"), newCRTB.RoleTemplateName, "the referenced role template was not found")
@@ -202,7 +202,7 @@ func (a *admitter) validateCreateFields(newCRTB *apisv3.ClusterRoleTemplateBindi
 		// if the grb that owns this role is active then allow this binding to use a locked roleTemplate. This allows
 		// grbs which inheritClusterRoles to rollout permissions across new clusters, even on a locked roleTemplate.
 		if hasGRBLabel {
-			grb, err := a.grbCache.Get(owningGRB)
+			grb, err := admission.Lookup(request, a.grbCache, owningGRB)
 			// confirm that the owning grb actually exists
 			if err != nil {
 				if apierrors.IsNotFound(err) {
@@ -14,6 +14,7 @@ import (
 	"github.com/rancher/webhook/pkg/resources/common"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authzv1 "k8s.io/api/authorization/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -65,6 +66,19 @@ func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.Webho
 	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations())}
 }
 
+// PrefetchReviews returns the escalate review of the requester on the created or updated global role, which the admitter
+// creates unless the request is denied first, so that it's prefetched while the request is queued.
+func (v *Validator) PrefetchReviews(request *admission.Request) []authzv1.ResourceAttributes {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return nil
+	}
+	_, newGR, err := objectsv3.GlobalRoleOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil || newGR.DeletionTimestamp != nil {
+		return nil
+	}
+	return []authzv1.ResourceAttributes{{Verb: escalateVerb, Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource, Name: newGR.Name}}
+}
+
 // Admitters returns the admitter objects used to validate globalRoles.
 func (v *Validator) Admitters() []admission.Admitter {
 	return []admission.Admitter{&v.admitter}
@@ -14,6 +14,7 @@ import (
 	"github.com/rancher/webhook/pkg/resources/common"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authzv1 "k8s.io/api/authorization/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -70,6 +71,16 @@ func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.Webho
 	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations())}
 }
 
+// PrefetchReviews returns the bind review of the requester on the global role of the binding, which the admitter
+// creates unless the request is denied first, so that it's prefetched while the request is queued.
+func (v *Validator) PrefetchReviews(request *admission.Request) []authzv1.ResourceAttributes {
+	_, newGRB, err := objectsv3.GlobalRoleBindingOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil || newGRB.DeletionTimestamp != nil {
+		return nil
+	}
+	return []authzv1.ResourceAttributes{{Verb: bindVerb, Group: globalRoleGvr.Group, Version: globalRoleGvr.Version, Resource: globalRoleGvr.Resource, Name: newGRB.GlobalRoleName}}
+}
+
 // Admitters returns the admitter objects used to validate globalRoleBindings.
 func (v *Validator) Admitters() []admission.Admitter {
 	return []admission.Admitter{&v.admitter}
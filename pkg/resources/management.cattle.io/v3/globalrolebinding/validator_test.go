@@ -854,3 +854,27 @@ func setSarResponse(allowed bool, testErr error, targetUser string, targetGrName
 		return false, nil, nil
 	})
 }
+
+func TestPrefetchReviews(t *testing.T) {
+	t.Parallel()
+	state := newDefaultState(t)
+	var reviewed []authorizationv1.ResourceAttributes
+	state.fakeClient.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+		review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviewed = append(reviewed, *review.Spec.ResourceAttributes)
+		return true, review, nil
+	})
+	grResolver := auth.NewGlobalRoleResolver(auth.NewRoleTemplateResolver(state.rtCacheMock, nil), state.grCacheMock)
+	gbrResolvers := resolvers.NewGRBRuleResolvers(state.grbCacheMock, grResolver)
+	validator := globalrolebinding.NewValidator(state.resolver, gbrResolvers, *state.sarMock, grResolver)
+	req := createGRBRequest(t, testCase{args: args{newGRB: func() *v3.GlobalRoleBinding {
+		grb := newDefaultGRB()
+		grb.GlobalRoleName = baseGR.Name
+		return grb
+	}}})
+
+	prefetched := validator.PrefetchReviews(req)
+	_, err := validator.Admitters()[0].Admit(req)
+	require.NoError(t, err)
+	assert.Equal(t, reviewed, prefetched, "the prefetched reviews are those the admitter creates")
+}
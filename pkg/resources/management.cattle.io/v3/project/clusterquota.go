@@ -0,0 +1,154 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// ClusterQuotaCapacityAnnotation holds the capacity shared by the resource quotas of all projects of a cluster, as
+	// a JSON resource quota limit, e.g. {"limitsCpu":"100","requestsMemory":"200Gi"}.
+	ClusterQuotaCapacityAnnotation = "management.cattle.io/project-quota-capacity"
+
+	projectsByClusterIndex = "webhook.cattle.io/projects-by-cluster"
+)
+
+var clusterQuotaRule = rules.Register(rules.Rule{
+	ID:            "project-cluster-quota-capacity",
+	GVR:           gvr,
+	Description:   "When a cluster has the management.cattle.io/project-quota-capacity annotation, the resource quotas of all its projects must fit within that capacity. Projects can't be created, or have their quota raised, when the sum of the quotas would exceed it. Resources left out of the capacity or of a project's quota aren't counted.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "project.spec.resourceQuota.limit: Forbidden: project quotas of cluster c-abc12 would exceed its capacity on: limitsCpu=8 (4 of 100 available)",
+	DenialCode:    string(admission.DenialConflict),
+})
+
+// projectsByCluster indexes projects by the name of their cluster.
+func projectsByCluster(project *v3.Project) ([]string, error) {
+	return []string{project.Spec.ClusterName}, nil
+}
+
+// validateClusterQuota checks that the quota of the project fits in the capacity of its cluster left by the quotas of
+// the other projects, when the project is created with a quota or its quota is raised. It returns the error and
+// warning of the current stage of the rule.
+func (a *admitter) validateClusterQuota(request *admission.Request, oldProject, newProject *v3.Project) (*field.Error, string, error) {
+	if a.projectCache == nil || a.clusterCache == nil || newProject.Spec.ResourceQuota == nil {
+		return nil, "", nil
+	}
+	newLimit, err := quota.LimitToResourceList(&newProject.Spec.ResourceQuota.Limit)
+	if err != nil {
+		// invalid quotas are denied by the other checks
+		return nil, "", nil
+	}
+	if request.Operation == admissionv1.Update && oldProject.Spec.ResourceQuota != nil {
+		oldLimit, err := quota.LimitToResourceList(&oldProject.Spec.ResourceQuota.Limit)
+		if err == nil && !raised(oldLimit, newLimit) {
+			return nil, "", nil
+		}
+	}
+
+	cluster, err := a.clusterCache.Get(newProject.Spec.ClusterName)
+	if err != nil || cluster == nil {
+		// missing clusters are denied by the other checks
+		return nil, "", nil
+	}
+	capacity, ok := clusterQuotaCapacity(cluster)
+	if !ok {
+		return nil, "", nil
+	}
+
+	projects, err := a.projectCache.GetByIndex(projectsByClusterIndex, newProject.Spec.ClusterName)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list the projects of cluster %s: %w", newProject.Spec.ClusterName, err)
+	}
+	used := corev1.ResourceList{}
+	for _, project := range projects {
+		if project.DeletionTimestamp != nil || project.Spec.ResourceQuota == nil ||
+			(project.Namespace == newProject.Namespace && project.Name == newProject.Name) {
+			continue
+		}
+		limit, err := quota.LimitToResourceList(&project.Spec.ResourceQuota.Limit)
+		if err != nil {
+			continue
+		}
+		addResources(used, limit)
+	}
+
+	var exceeded []string
+	for name, total := range capacity {
+		requested, ok := newLimit[name]
+		if !ok {
+			continue
+		}
+		available := total.DeepCopy()
+		if quantity, ok := used[name]; ok {
+			available.Sub(quantity)
+		}
+		if available.Sign() < 0 {
+			available = resource.Quantity{}
+		}
+		if requested.Cmp(available) > 0 {
+			exceeded = append(exceeded, fmt.Sprintf("%s=%s (%s of %s available)", name, requested.String(), available.String(), total.String()))
+		}
+	}
+	if len(exceeded) == 0 {
+		return nil, "", nil
+	}
+	sort.Strings(exceeded)
+	fieldErr := field.Forbidden(projectSpecFieldPath.Child(projectQuotaField, "limit"), clusterQuotaRule.Message(
+		"project quotas of cluster %s would exceed its capacity on: %s", newProject.Spec.ClusterName, strings.Join(exceeded, ",")))
+	fieldErr, warning := rules.EnforceErrorWith(clusterQuotaRule, rules.OverridesOf(cluster.Annotations), fieldErr)
+	return fieldErr, warning, nil
+}
+
+// clusterQuotaCapacity returns the capacity shared by the project quotas of the cluster, false if it has none. Invalid
+// capacities are ignored.
+func clusterQuotaCapacity(cluster *v3.Cluster) (corev1.ResourceList, bool) {
+	value, ok := cluster.Annotations[ClusterQuotaCapacityAnnotation]
+	if !ok {
+		return nil, false
+	}
+	var limit v3.ResourceQuotaLimit
+	if err := json.Unmarshal([]byte(value), &limit); err != nil {
+		logrus.Warnf("[project-validation] ignoring invalid %s annotation of cluster %s: %v", ClusterQuotaCapacityAnnotation, cluster.Name, err)
+		return nil, false
+	}
+	capacity, err := quota.LimitToResourceList(&limit)
+	if err != nil {
+		logrus.Warnf("[project-validation] ignoring invalid %s annotation of cluster %s: %v", ClusterQuotaCapacityAnnotation, cluster.Name, err)
+		return nil, false
+	}
+	return capacity, len(capacity) != 0
+}
+
+// raised returns whether the new limit is above the old one for any resource, including resources the old limit
+// left out.
+func raised(oldLimit, newLimit corev1.ResourceList) bool {
+	for name, quantity := range newLimit {
+		oldQuantity, ok := oldLimit[name]
+		if !ok || quantity.Cmp(oldQuantity) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// addResources adds the quantities of the resources to the sum.
+func addResources(sum, resources corev1.ResourceList) {
+	for name, quantity := range resources {
+		total := sum[name]
+		total.Add(quantity)
+		sum[name] = total
+	}
+}
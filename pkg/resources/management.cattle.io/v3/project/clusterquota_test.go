@@ -0,0 +1,146 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func projectWithQuota(name, limitsCPU, requestsMemory string) *v3.Project {
+	return &v3.Project{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "c-abc12"},
+		Spec: v3.ProjectSpec{
+			ClusterName: "c-abc12",
+			ResourceQuota: &v3.ProjectResourceQuota{
+				Limit: v3.ResourceQuotaLimit{LimitsCPU: limitsCPU, RequestsMemory: requestsMemory},
+			},
+		},
+	}
+}
+
+func TestValidateClusterQuota(t *testing.T) {
+	t.Parallel()
+	deleting := projectWithQuota("p-deleting", "50", "")
+	deleting.DeletionTimestamp = &metav1.Time{}
+	otherProjects := []*v3.Project{
+		projectWithQuota("p-other1", "40", "10Gi"),
+		projectWithQuota("p-other2", "56", ""),
+		deleting,
+		{ObjectMeta: metav1.ObjectMeta{Name: "p-noquota", Namespace: "c-abc12"}, Spec: v3.ProjectSpec{ClusterName: "c-abc12"}},
+	}
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		oldProject  *v3.Project
+		newProject  *v3.Project
+		capacity    string
+		wantMessage string
+	}{
+		{
+			name:       "create within the capacity",
+			operation:  admissionv1.Create,
+			newProject: projectWithQuota("p-new", "4", "1Gi"),
+			capacity:   `{"limitsCpu":"100","requestsMemory":"20Gi"}`,
+		},
+		{
+			name:        "create over the capacity",
+			operation:   admissionv1.Create,
+			newProject:  projectWithQuota("p-new", "8", "1Gi"),
+			capacity:    `{"limitsCpu":"100","requestsMemory":"20Gi"}`,
+			wantMessage: "project quotas of cluster c-abc12 would exceed its capacity on: limitsCpu=8 (4 of 100 available)",
+		},
+		{
+			name:        "create over the capacity of several resources",
+			operation:   admissionv1.Create,
+			newProject:  projectWithQuota("p-new", "8", "12Gi"),
+			capacity:    `{"limitsCpu":"100","requestsMemory":"20Gi"}`,
+			wantMessage: "limitsCpu=8 (4 of 100 available),requestsMemory=12Gi (10Gi of 20Gi available)",
+		},
+		{
+			name:       "resources left out of the capacity aren't limited",
+			operation:  admissionv1.Create,
+			newProject: projectWithQuota("p-new", "8", "1Gi"),
+			capacity:   `{"requestsMemory":"20Gi"}`,
+		},
+		{
+			name:       "cluster without capacity",
+			operation:  admissionv1.Create,
+			newProject: projectWithQuota("p-new", "800", "1Ti"),
+		},
+		{
+			name:       "invalid capacity is ignored",
+			operation:  admissionv1.Create,
+			newProject: projectWithQuota("p-new", "800", "1Ti"),
+			capacity:   `{"limitsCpu":"lots"}`,
+		},
+		{
+			name:        "raise over the capacity",
+			operation:   admissionv1.Update,
+			oldProject:  projectWithQuota("p-other2", "56", ""),
+			newProject:  projectWithQuota("p-other2", "64", ""),
+			capacity:    `{"limitsCpu":"100"}`,
+			wantMessage: "limitsCpu=64 (60 of 100 available)",
+		},
+		{
+			name:       "raise within the capacity",
+			operation:  admissionv1.Update,
+			oldProject: projectWithQuota("p-other2", "56", ""),
+			newProject: projectWithQuota("p-other2", "60", ""),
+			capacity:   `{"limitsCpu":"100"}`,
+		},
+		{
+			name:       "lowering a quota over the capacity",
+			operation:  admissionv1.Update,
+			oldProject: projectWithQuota("p-other2", "56", ""),
+			newProject: projectWithQuota("p-other2", "50", ""),
+			capacity:   `{"limitsCpu":"80"}`,
+		},
+		{
+			name:        "adding a resource to a quota over the capacity",
+			operation:   admissionv1.Update,
+			oldProject:  projectWithQuota("p-other2", "56", ""),
+			newProject:  projectWithQuota("p-other2", "56", "12Gi"),
+			capacity:    `{"requestsMemory":"20Gi"}`,
+			wantMessage: "requestsMemory=12Gi (10Gi of 20Gi available)",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			cluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-abc12"}}
+			if test.capacity != "" {
+				cluster.Annotations = map[string]string{ClusterQuotaCapacityAnnotation: test.capacity}
+			}
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			clusterCache.EXPECT().Get("c-abc12").Return(cluster, nil).AnyTimes()
+			projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+			projectCache.EXPECT().GetByIndex(projectsByClusterIndex, "c-abc12").Return(otherProjects, nil).AnyTimes()
+
+			oldProject := test.oldProject
+			if oldProject == nil {
+				oldProject = &v3.Project{}
+			}
+			req, err := createProjectRequest(test.oldProject, test.newProject, test.operation, false)
+			require.NoError(t, err)
+			a := admitter{clusterCache: clusterCache, projectCache: projectCache}
+			fieldErr, warning, err := a.validateClusterQuota(req, oldProject, test.newProject)
+			require.NoError(t, err)
+			assert.Empty(t, warning)
+			if test.wantMessage == "" {
+				assert.Nil(t, fieldErr)
+				return
+			}
+			require.NotNil(t, fieldErr)
+			assert.Contains(t, fieldErr.Error(), "project.spec.resourceQuota.limit: Forbidden: ")
+			assert.Contains(t, fieldErr.Error(), test.wantMessage)
+		})
+	}
+}
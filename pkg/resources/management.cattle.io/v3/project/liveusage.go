@@ -0,0 +1,180 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/rules"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// quotaUsageEnv is what decreases of project quotas are checked against: quotaUsageUsedLimit, the default, or
+	// quotaUsageLive.
+	quotaUsageEnv = "CATTLE_PROJECT_QUOTA_USAGE"
+	// quotaUsageUsedLimit checks decreases against the used limit of the project only.
+	quotaUsageUsedLimit = "used-limit"
+	// quotaUsageLive also checks decreases against the usage of the resource quotas of the project's namespaces.
+	quotaUsageLive = "live"
+)
+
+var liveQuotaUsageRule = rules.Register(rules.Rule{
+	ID:            "project-live-quota-usage",
+	GVR:           gvr,
+	Description:   "When CATTLE_PROJECT_QUOTA_USAGE is live, the resource quota of a project can't be lowered below the usage reported by the resource quotas of its namespaces, summed over the namespaces. Only the namespaces of the cluster the webhook runs in are counted.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "project.spec.resourceQuota: Forbidden: resourceQuota is below the usage of the project's namespaces on: pods=10 (12 used: team-a=8, team-b=4)",
+	DenialCode:    string(admission.DenialConflict),
+})
+
+// liveQuotaUsage is the configuration of the live quota usage rule.
+type liveQuotaUsage struct {
+	// enabled is true when project quota decreases are checked against the live usage of the namespaces.
+	enabled            bool
+	namespaceCache     corecontrollers.NamespaceCache
+	resourceQuotaCache generic.CacheInterface[*corev1.ResourceQuota]
+}
+
+// liveQuotaUsageFromEnv returns the configuration of the live quota usage rule. An invalid mode is ignored in favor of
+// the used limit.
+func liveQuotaUsageFromEnv(namespaceCache corecontrollers.NamespaceCache, resourceQuotaCache generic.CacheInterface[*corev1.ResourceQuota]) liveQuotaUsage {
+	usage := liveQuotaUsage{
+		namespaceCache:     namespaceCache,
+		resourceQuotaCache: resourceQuotaCache,
+	}
+	switch value := strings.TrimSpace(os.Getenv(quotaUsageEnv)); value {
+	case "", quotaUsageUsedLimit:
+	case quotaUsageLive:
+		usage.enabled = namespaceCache != nil && resourceQuotaCache != nil
+	default:
+		logrus.Warnf("[project-validation] ignoring invalid %s value %q, using %s", quotaUsageEnv, value, quotaUsageUsedLimit)
+	}
+	if usage.enabled {
+		admission.IndexNamespacesByProject(namespaceCache)
+	}
+	return usage
+}
+
+// validateLiveQuotaUsage checks that the resources whose project quota is lowered, or newly set, still fit the usage
+// of the resource quotas of the project's namespaces. It returns the error and warning of the current stage of the
+// rule.
+func (a *admitter) validateLiveQuotaUsage(oldProject, newProject *v3.Project) (*field.Error, string, error) {
+	usage := a.liveQuotaUsage
+	if !usage.enabled || oldProject == nil || newProject.Spec.ResourceQuota == nil {
+		return nil, "", nil
+	}
+	lowered, err := loweredQuotas(oldProject.Spec.ResourceQuota, newProject.Spec.ResourceQuota)
+	if err != nil || len(lowered) == 0 {
+		return nil, "", err
+	}
+	projectID := admission.Owner{Cluster: newProject.Spec.ClusterName, Project: newProject.Name}.ProjectID()
+	used, err := usage.namespaceUsage(projectID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var exceeded []string
+	for _, name := range sortedResourceNames(lowered) {
+		limit := lowered[name]
+		total := resource.Quantity{}
+		var namespaces []string
+		for _, namespace := range sortedKeys(used) {
+			quantity, ok := used[namespace][name]
+			if !ok || quantity.IsZero() {
+				continue
+			}
+			total.Add(quantity)
+			namespaces = append(namespaces, fmt.Sprintf("%s=%s", namespace, quantity.String()))
+		}
+		if total.Cmp(limit) > 0 {
+			exceeded = append(exceeded, fmt.Sprintf("%s=%s (%s used: %s)", name, limit.String(), total.String(), strings.Join(namespaces, ", ")))
+		}
+	}
+	if len(exceeded) == 0 {
+		return nil, "", nil
+	}
+
+	fieldErr := field.Forbidden(projectSpecFieldPath.Child(projectQuotaField),
+		liveQuotaUsageRule.Message("resourceQuota is below the usage of the project's namespaces on: %s", strings.Join(exceeded, "; ")))
+	fieldErr, warning := rules.EnforceErrorWith(liveQuotaUsageRule, a.ruleOverrides(newProject), fieldErr)
+	return fieldErr, warning, nil
+}
+
+// namespaceUsage returns the usage of each namespace of the project, by namespace. The usage of a namespace with
+// several resource quotas is the highest usage any of them reports for each resource.
+func (u liveQuotaUsage) namespaceUsage(projectID string) (map[string]corev1.ResourceList, error) {
+	namespaces, err := u.namespaceCache.GetByIndex(admission.NamespacesByProjectIndex, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the namespaces of project %s: %w", projectID, err)
+	}
+	usage := make(map[string]corev1.ResourceList, len(namespaces))
+	for _, namespace := range namespaces {
+		resourceQuotas, err := u.resourceQuotaCache.List(namespace.Name, labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the resource quotas of namespace %s: %w", namespace.Name, err)
+		}
+		used := corev1.ResourceList{}
+		for _, resourceQuota := range resourceQuotas {
+			for name, quantity := range quota.FromCoreResourceList(resourceQuota.Status.Used) {
+				if current, ok := used[name]; !ok || quantity.Cmp(current) > 0 {
+					used[name] = quantity
+				}
+			}
+		}
+		usage[namespace.Name] = used
+	}
+	return usage, nil
+}
+
+// loweredQuotas returns the limits of the new quota which are lower than the old ones, or which the old quota didn't
+// set.
+func loweredQuotas(oldQuota, newQuota *v3.ProjectResourceQuota) (corev1.ResourceList, error) {
+	newLimits, err := quota.LimitToResourceList(&newQuota.Limit)
+	if err != nil {
+		return nil, err
+	}
+	oldLimits := corev1.ResourceList{}
+	if oldQuota != nil {
+		if oldLimits, err = quota.LimitToResourceList(&oldQuota.Limit); err != nil {
+			return nil, err
+		}
+	}
+	lowered := corev1.ResourceList{}
+	for name, limit := range newLimits {
+		if oldLimit, ok := oldLimits[name]; !ok || limit.Cmp(oldLimit) < 0 {
+			lowered[name] = limit
+		}
+	}
+	return lowered, nil
+}
+
+// sortedResourceNames returns the names of the resources of the list in order.
+func sortedResourceNames(list corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
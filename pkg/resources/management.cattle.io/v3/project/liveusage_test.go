@@ -0,0 +1,163 @@
+package project
+
+import (
+	"errors"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestValidateLiveQuotaUsage(t *testing.T) {
+	t.Parallel()
+	projectWithQuota := func(pods, configMaps string) *v3.Project {
+		return &v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: "p-xyz34", Namespace: "c-abc12"},
+			Spec: v3.ProjectSpec{
+				ClusterName: "c-abc12",
+				ResourceQuota: &v3.ProjectResourceQuota{
+					Limit: v3.ResourceQuotaLimit{Pods: pods, ConfigMaps: configMaps},
+				},
+			},
+		}
+	}
+	resourceQuota := func(name string, used corev1.ResourceList) *corev1.ResourceQuota {
+		return &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.ResourceQuotaStatus{Used: used},
+		}
+	}
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-c"}},
+	}
+	resourceQuotas := map[string][]*corev1.ResourceQuota{
+		"team-a": {
+			resourceQuota("default-abc", corev1.ResourceList{corev1.ResourcePods: resource.MustParse("8"), corev1.ResourceConfigMaps: resource.MustParse("3")}),
+			// the usage of overlapping quotas is only counted once
+			resourceQuota("extra", corev1.ResourceList{corev1.ResourcePods: resource.MustParse("8")}),
+		},
+		"team-b": {
+			resourceQuota("default-def", corev1.ResourceList{corev1.ResourcePods: resource.MustParse("4"), corev1.ResourceConfigMaps: resource.MustParse("0")}),
+		},
+		"team-c": nil,
+	}
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		oldProject  *v3.Project
+		newProject  *v3.Project
+		listErr     error
+		wantMessage string
+		wantErr     bool
+	}{
+		{
+			name:       "disabled",
+			oldProject: projectWithQuota("20", "10"),
+			newProject: projectWithQuota("5", "10"),
+		},
+		{
+			name:       "create",
+			enabled:    true,
+			newProject: projectWithQuota("5", "10"),
+		},
+		{
+			name:       "quota raised",
+			enabled:    true,
+			oldProject: projectWithQuota("5", "10"),
+			newProject: projectWithQuota("20", "10"),
+		},
+		{
+			name:       "quota lowered above the usage",
+			enabled:    true,
+			oldProject: projectWithQuota("20", "10"),
+			newProject: projectWithQuota("12", "3"),
+		},
+		{
+			name:        "quota lowered below the usage",
+			enabled:     true,
+			oldProject:  projectWithQuota("20", "10"),
+			newProject:  projectWithQuota("10", "2"),
+			wantMessage: "project.spec.resourceQuota: Forbidden: resourceQuota is below the usage of the project's namespaces on: configMaps=2 (3 used: team-a=3); pods=10 (12 used: team-a=8, team-b=4)",
+		},
+		{
+			name:        "quota set below the usage",
+			enabled:     true,
+			oldProject:  projectWithQuota("", "10"),
+			newProject:  projectWithQuota("10", "10"),
+			wantMessage: "pods=10 (12 used: team-a=8, team-b=4)",
+		},
+		{
+			name:       "quota removed",
+			enabled:    true,
+			oldProject: projectWithQuota("20", "10"),
+			newProject: &v3.Project{ObjectMeta: metav1.ObjectMeta{Name: "p-xyz34", Namespace: "c-abc12"}, Spec: v3.ProjectSpec{ClusterName: "c-abc12"}},
+		},
+		{
+			name:       "resource quotas can't be listed",
+			enabled:    true,
+			oldProject: projectWithQuota("20", "10"),
+			newProject: projectWithQuota("10", "10"),
+			listErr:    errors.New("unavailable"),
+			wantErr:    true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](ctrl)
+			namespaceCache.EXPECT().GetByIndex(admission.NamespacesByProjectIndex, "c-abc12:p-xyz34").Return(namespaces, nil).AnyTimes()
+			resourceQuotaCache := fake.NewMockCacheInterface[*corev1.ResourceQuota](ctrl)
+			resourceQuotaCache.EXPECT().List(gomock.Any(), labels.Everything()).DoAndReturn(func(namespace string, _ labels.Selector) ([]*corev1.ResourceQuota, error) {
+				return resourceQuotas[namespace], test.listErr
+			}).AnyTimes()
+
+			a := admitter{liveQuotaUsage: liveQuotaUsage{
+				enabled:            test.enabled,
+				namespaceCache:     namespaceCache,
+				resourceQuotaCache: resourceQuotaCache,
+			}}
+			fieldErr, warning, err := a.validateLiveQuotaUsage(test.oldProject, test.newProject)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, warning)
+			if test.wantMessage == "" {
+				assert.Nil(t, fieldErr)
+				return
+			}
+			require.NotNil(t, fieldErr)
+			assert.Contains(t, fieldErr.Error(), test.wantMessage)
+		})
+	}
+}
+
+func TestLiveQuotaUsageFromEnv(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](ctrl)
+	resourceQuotaCache := fake.NewMockCacheInterface[*corev1.ResourceQuota](ctrl)
+
+	t.Setenv(quotaUsageEnv, quotaUsageLive)
+	namespaceCache.EXPECT().AddIndexer(admission.NamespacesByProjectIndex, gomock.Any())
+	assert.True(t, liveQuotaUsageFromEnv(namespaceCache, resourceQuotaCache).enabled)
+	assert.False(t, liveQuotaUsageFromEnv(nil, nil).enabled)
+
+	t.Setenv(quotaUsageEnv, quotaUsageUsedLimit)
+	assert.False(t, liveQuotaUsageFromEnv(namespaceCache, resourceQuotaCache).enabled)
+	t.Setenv(quotaUsageEnv, "namespaces")
+	assert.False(t, liveQuotaUsageFromEnv(namespaceCache, resourceQuotaCache).enabled)
+}
@@ -0,0 +1,20 @@
+package project
+
+import (
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/quota"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateMaxNamespaces checks that the quota.MaxNamespacesAnnotation annotation of the project, if set, is a valid
+// number of namespaces. The limit itself is enforced by the namespace validator.
+func validateMaxNamespaces(project *v3.Project) *field.Error {
+	value, ok := project.Annotations[quota.MaxNamespacesAnnotation]
+	if !ok {
+		return nil
+	}
+	if _, err := quota.ParseMaxNamespaces(value); err != nil {
+		return field.Invalid(projectAnnotationsFieldPath.Key(quota.MaxNamespacesAnnotation), value, err.Error())
+	}
+	return nil
+}
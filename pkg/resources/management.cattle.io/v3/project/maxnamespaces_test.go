@@ -0,0 +1,25 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateMaxNamespaces(t *testing.T) {
+	t.Parallel()
+	withAnnotations := func(annotations map[string]string) *v3.Project {
+		return &v3.Project{ObjectMeta: metav1.ObjectMeta{Name: "p-xyz34", Namespace: "c-abc12", Annotations: annotations}}
+	}
+	assert.Nil(t, validateMaxNamespaces(withAnnotations(nil)))
+	assert.Nil(t, validateMaxNamespaces(withAnnotations(map[string]string{quota.MaxNamespacesAnnotation: "10"})))
+	assert.Nil(t, validateMaxNamespaces(withAnnotations(map[string]string{quota.MaxNamespacesAnnotation: ""})))
+
+	fieldErr := validateMaxNamespaces(withAnnotations(map[string]string{quota.MaxNamespacesAnnotation: "-2"}))
+	if assert.NotNil(t, fieldErr) {
+		assert.Equal(t, `project.metadata.annotations[management.cattle.io/max-namespaces]: Invalid value: "-2": must be a non-negative number of namespaces`, fieldErr.Error())
+	}
+}
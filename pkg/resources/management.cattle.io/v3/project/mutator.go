@@ -8,11 +8,14 @@ import (
 	"github.com/rancher/webhook/pkg/admission"
 	ctrlv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/identity"
 	"github.com/rancher/webhook/pkg/patch"
+	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/utils/trace"
 )
 
@@ -31,13 +34,17 @@ var gvr = schema.GroupVersionResource{
 // Mutator implements admission.MutatingAdmissionWebhook.
 type Mutator struct {
 	roleTemplateCache ctrlv3.RoleTemplateCache
+	clusterCache      ctrlv3.ClusterCache
+	ownership         *common.OwnershipTransferer
 }
 
 // NewMutator returns a new mutator which mutates projects
-func NewMutator(roleTemplateCache ctrlv3.RoleTemplateCache) *Mutator {
+func NewMutator(roleTemplateCache ctrlv3.RoleTemplateCache, clusterCache ctrlv3.ClusterCache, identities *identity.Resolver, sar authorizationv1.SubjectAccessReviewInterface) *Mutator {
 	roleTemplateCache.AddIndexer(mutatorCreatorRoleTemplateIndex, creatorRoleTemplateIndexer)
 	return &Mutator{
 		roleTemplateCache: roleTemplateCache,
+		clusterCache:      clusterCache,
+		ownership:         common.NewOwnershipTransferer(gvr, identities, sar),
 	}
 }
 
@@ -58,6 +65,7 @@ func (m *Mutator) GVR() schema.GroupVersionResource {
 func (m *Mutator) Operations() []admissionregistrationv1.OperationType {
 	return []admissionregistrationv1.OperationType{
 		admissionregistrationv1.Create,
+		admissionregistrationv1.Update,
 	}
 }
 
@@ -79,13 +87,15 @@ func (m *Mutator) Admit(request *admission.Request) (*admissionv1.AdmissionRespo
 	listTrace := trace.New("project Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
-	project, err := objectsv3.ProjectFromRequest(&request.AdmissionRequest)
+	oldProject, project, err := objectsv3.ProjectOldAndNewFromRequest(&request.AdmissionRequest)
 	if err != nil {
 		return nil, err
 	}
 	switch request.Operation {
 	case admissionv1.Create:
 		return m.admitCreate(project, request)
+	case admissionv1.Update:
+		return m.admitUpdate(oldProject, project, request)
 	default:
 		return nil, fmt.Errorf("operation type %q not handled", request.Operation)
 	}
@@ -103,11 +113,45 @@ func (m *Mutator) admitCreate(project *v3.Project, request *admission.Request) (
 		return nil, fmt.Errorf("failed to add annotation to project %s: %w", project.Name, err)
 	}
 	newProject.Annotations[roleTemplatesRequired] = annotations
+	common.MigrateAnnotations(newProject, common.ProjectAnnotationMigrations)
+	warnings := common.ApplyNormanShims(newProject, common.ProjectNormanShims)
+	m.defaultNamespaceQuota(newProject)
+	normalizeQuotas(newProject)
+	// the revision of a copied project doesn't apply to the new one
+	delete(newProject.Annotations, QuotaRevisionAnnotation)
+	if err := common.SetOriginalCreatorAnnotations(newProject); err != nil {
+		return nil, fmt.Errorf("failed to record original creator annotations on project %s: %w", project.Name, err)
+	}
 	response := &admissionv1.AdmissionResponse{}
 	if err := patch.CreatePatch(request.Object.Raw, newProject, response); err != nil {
 		return nil, fmt.Errorf("failed to create patch: %w", err)
 	}
 	response.Allowed = true
+	response.Warnings = warnings
+	return response, nil
+}
+
+func (m *Mutator) admitUpdate(oldProject, project *v3.Project, request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	newProject := project.DeepCopy()
+	common.MigrateAnnotations(newProject, common.ProjectAnnotationMigrations)
+	warnings := common.ApplyNormanShims(newProject, common.ProjectNormanShims)
+	m.defaultNamespaceQuota(newProject)
+	normalizeQuotas(newProject)
+	stampQuotaRevision(oldProject, newProject)
+	common.KeepOriginalCreatorAnnotations(oldProject, newProject)
+	status, err := m.ownership.TransferOwnership(request, oldProject, newProject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer ownership of project %s: %w", project.Name, err)
+	}
+	if status != nil {
+		return &admissionv1.AdmissionResponse{Result: status}, nil
+	}
+	patch, err := admission.PatchFromObjects(project, newProject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create patch: %w", err)
+	}
+	response := admission.ResponseAllowedWithPatch(patch)
+	response.Warnings = warnings
 	return response, nil
 }
 
@@ -6,11 +6,18 @@ import (
 	"testing"
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/identity"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
 )
 
 const (
@@ -28,6 +35,7 @@ func TestAdmit(t *testing.T) {
 		newProject *v3.Project
 		indexer    func() ([]*v3.RoleTemplate, error)
 		wantPatch  []map[string]interface{}
+		wantDenied bool
 		wantErr    bool
 	}{
 		{
@@ -49,11 +57,97 @@ func TestAdmit(t *testing.T) {
 			wantErr:    true,
 		},
 		{
-			name:       "update operation is invalid",
+			name:       "update without ownership transfer has no patch",
 			operation:  admissionv1.Update,
 			newProject: &v3.Project{},
 			oldProject: &v3.Project{},
-			wantErr:    true,
+		},
+		{
+			name:       "update normalizes quota quantities",
+			operation:  admissionv1.Update,
+			oldProject: &v3.Project{},
+			newProject: &v3.Project{
+				Spec: v3.ProjectSpec{
+					ResourceQuota:                 &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsMemory: "1024Mi"}},
+					NamespaceDefaultResourceQuota: &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsMemory: "512Mi"}},
+				},
+			},
+			wantPatch: []map[string]interface{}{
+				{"op": "replace", "path": "/spec/resourceQuota/limit/limitsMemory", "value": "1Gi"},
+			},
+		},
+		{
+			name:       "update defaults the namespace quota",
+			operation:  admissionv1.Update,
+			oldProject: &v3.Project{},
+			newProject: &v3.Project{
+				Spec: v3.ProjectSpec{
+					ResourceQuota: &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsMemory: "1Gi"}},
+				},
+			},
+			wantPatch: []map[string]interface{}{
+				{"op": "add", "path": "/spec/namespaceDefaultResourceQuota", "value": map[string]interface{}{"limit": map[string]interface{}{"limitsMemory": "1Gi"}}},
+			},
+		},
+		{
+			name:      "update with ownership transfer",
+			operation: admissionv1.Update,
+			oldProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "testproject",
+					Annotations: map[string]string{
+						"field.cattle.io/creatorId": "u-12345",
+					},
+				},
+			},
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "testproject",
+					Annotations: map[string]string{
+						"field.cattle.io/creatorId":         "u-12345",
+						"field.cattle.io/transfer-owner-to": "u-12346",
+					},
+				},
+			},
+			wantPatch: []map[string]interface{}{
+				{
+					"op":    "replace",
+					"path":  "/metadata/annotations/field.cattle.io~1creatorId",
+					"value": "u-12346",
+				},
+				{
+					"op":   "remove",
+					"path": "/metadata/annotations/field.cattle.io~1transfer-owner-to",
+				},
+				{
+					"op":    "add",
+					"path":  "/metadata/annotations/webhook.cattle.io~1original-creator-annotations",
+					"value": "{\"field.cattle.io/creatorId\":\"u-12346\"}",
+				},
+				{
+					"op":    "add",
+					"path":  "/metadata/annotations/webhook.cattle.io~1ownership-transfer",
+					"value": "{\"from\":\"u-12345\",\"to\":\"u-12346\",\"by\":\"\"}",
+				},
+			},
+		},
+		{
+			name:      "update with ownership transfer to a missing user is denied",
+			operation: admissionv1.Update,
+			oldProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "testproject",
+				},
+			},
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "testproject",
+					Annotations: map[string]string{
+						"field.cattle.io/transfer-owner-to": "u-missing",
+					},
+				},
+			},
+			wantDenied: true,
 		},
 		{
 			name:       "connect operation is invalid",
@@ -125,6 +219,30 @@ func TestAdmit(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "created project records original creator annotations",
+			operation: admissionv1.Create,
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "testproject",
+					Annotations: map[string]string{
+						"field.cattle.io/creatorId": "u-12345",
+					},
+				},
+			},
+			wantPatch: []map[string]interface{}{
+				{
+					"op":    "add",
+					"path":  "/metadata/annotations/authz.management.cattle.io~1creator-role-bindings",
+					"value": "{\"required\":[\"project-owner\"]}",
+				},
+				{
+					"op":    "add",
+					"path":  "/metadata/annotations/webhook.cattle.io~1original-creator-annotations",
+					"value": "{\"field.cattle.io/creatorId\":\"u-12345\"}",
+				},
+			},
+		},
 	}
 
 	roleTemplates := []*v3.RoleTemplate{
@@ -147,7 +265,8 @@ func TestAdmit(t *testing.T) {
 			t.Parallel()
 			req, err := createProjectRequest(test.oldProject, test.newProject, test.operation, test.dryRun)
 			assert.NoError(t, err)
-			roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](gomock.NewController(t))
+			ctrl := gomock.NewController(t)
+			roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
 			roleTemplateCache.EXPECT().AddIndexer(expectedIndexerName, gomock.Any())
 			indexer := defaultIndexer
 			if test.indexer != nil {
@@ -155,19 +274,42 @@ func TestAdmit(t *testing.T) {
 			}
 			returnedRTs, returnedErr := indexer()
 			roleTemplateCache.EXPECT().GetByIndex(expectedIndexerName, expectedIndexKey).Return(returnedRTs, returnedErr).AnyTimes()
-			m := NewMutator(roleTemplateCache)
+			userCache := fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl)
+			userCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.User, error) {
+				if name == "u-12346" {
+					return &v3.User{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+			}).AnyTimes()
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				review.Status.Allowed = true
+				return true, review, nil
+			})
+			sar := (&k8fake.FakeAuthorizationV1{Fake: k8Fake}).SubjectAccessReviews()
+			m := NewMutator(roleTemplateCache, nil, identity.NewResolver(userCache), sar)
 			resp, err := m.Admit(req)
 			if test.wantErr {
 				assert.Error(t, err)
 				return
 			}
+			if test.wantDenied {
+				assert.False(t, resp.Allowed)
+				return
+			}
 			assert.Equal(t, true, resp.Allowed)
-			var wantPatch []byte
-			if test.wantPatch != nil {
-				wantPatch, err = json.Marshal(test.wantPatch)
-				assert.NoError(t, err)
+			if test.wantPatch == nil {
+				assert.Empty(t, resp.Patch)
+				return
 			}
-			assert.Equal(t, string(wantPatch), string(resp.Patch))
+			// patch operations generated from map fields have no guaranteed order
+			wantPatch, err := json.Marshal(test.wantPatch)
+			assert.NoError(t, err)
+			var want, got []map[string]interface{}
+			assert.NoError(t, json.Unmarshal(wantPatch, &want))
+			assert.NoError(t, json.Unmarshal(resp.Patch, &got))
+			assert.ElementsMatch(t, want, got)
 		})
 	}
 }
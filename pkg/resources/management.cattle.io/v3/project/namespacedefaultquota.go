@@ -0,0 +1,77 @@
+package project
+
+import (
+	"encoding/json"
+	"reflect"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// NamespaceDefaultQuotaAnnotation holds the namespace default quota given to the projects of a cluster which set a
+// resource quota without a namespace default quota, as a JSON resource quota limit, e.g.
+// {"limitsCpu":"2","requestsMemory":"4Gi"}.
+const NamespaceDefaultQuotaAnnotation = "management.cattle.io/project-namespace-default-quota"
+
+// defaultNamespaceQuota sets the namespace default quota of a project which sets a resource quota without one, since the
+// validator requires both. Each resource of the project quota gets the default of the project's cluster, capped at the
+// project quota, or the project quota itself if the cluster has no default for it. Projects which already set a
+// namespace default quota, or no resource quota, are left unchanged.
+func (m *Mutator) defaultNamespaceQuota(project *v3.Project) {
+	if project.Spec.ResourceQuota == nil || project.Spec.NamespaceDefaultResourceQuota != nil {
+		return
+	}
+	defaults := m.clusterNamespaceDefaultQuota(project.Spec.ClusterName)
+	projectLimit := reflect.ValueOf(&project.Spec.ResourceQuota.Limit).Elem()
+	defaultLimit := reflect.ValueOf(&defaults).Elem()
+	nsQuota := &v3.NamespaceResourceQuota{}
+	nsLimit := reflect.ValueOf(&nsQuota.Limit).Elem()
+	for i := 0; i < projectLimit.NumField(); i++ {
+		limit := projectLimit.Field(i).String()
+		if limit == "" {
+			continue
+		}
+		nsLimit.Field(i).SetString(cappedDefault(defaultLimit.Field(i).String(), limit))
+	}
+	project.Spec.NamespaceDefaultResourceQuota = nsQuota
+}
+
+// clusterNamespaceDefaultQuota returns the namespace default quota of the cluster, empty if it has none. Missing
+// clusters, which the validator denies, and invalid defaults are ignored.
+func (m *Mutator) clusterNamespaceDefaultQuota(clusterName string) v3.ResourceQuotaLimit {
+	var limit v3.ResourceQuotaLimit
+	if m.clusterCache == nil || clusterName == "" {
+		return limit
+	}
+	cluster, err := m.clusterCache.Get(clusterName)
+	if err != nil || cluster == nil {
+		return limit
+	}
+	value, ok := cluster.Annotations[NamespaceDefaultQuotaAnnotation]
+	if !ok {
+		return limit
+	}
+	if err := json.Unmarshal([]byte(value), &limit); err != nil {
+		logrus.Warnf("[project-mutation] ignoring invalid %s annotation of cluster %s: %v", NamespaceDefaultQuotaAnnotation, clusterName, err)
+		return v3.ResourceQuotaLimit{}
+	}
+	return limit
+}
+
+// cappedDefault returns the default quantity, or the project limit if the default is unset, invalid or above it.
+// Invalid project limits are returned as is for the validator to deny.
+func cappedDefault(defaultQuantity, projectLimit string) string {
+	if defaultQuantity == "" {
+		return projectLimit
+	}
+	quantity, err := resource.ParseQuantity(defaultQuantity)
+	if err != nil {
+		return projectLimit
+	}
+	limit, err := resource.ParseQuantity(projectLimit)
+	if err != nil || quantity.Cmp(limit) > 0 {
+		return projectLimit
+	}
+	return defaultQuantity
+}
@@ -0,0 +1,118 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDefaultNamespaceQuota(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		clusterDefault string
+		projectQuota   *v3.ProjectResourceQuota
+		nsQuota        *v3.NamespaceResourceQuota
+		want           *v3.NamespaceResourceQuota
+	}{
+		{
+			name:           "defaults from the cluster",
+			clusterDefault: `{"limitsCpu":"2","requestsMemory":"4Gi","pods":"20"}`,
+			projectQuota:   &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10", RequestsMemory: "16Gi"}},
+			want:           &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "2", RequestsMemory: "4Gi"}},
+		},
+		{
+			name:           "cluster default capped at the project quota",
+			clusterDefault: `{"limitsCpu":"20"}`,
+			projectQuota:   &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10"}},
+			want:           &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10"}},
+		},
+		{
+			name:           "resources without a cluster default get the project quota",
+			clusterDefault: `{"limitsCpu":"2"}`,
+			projectQuota:   &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10", ConfigMaps: "50"}},
+			want:           &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "2", ConfigMaps: "50"}},
+		},
+		{
+			name:         "cluster without default",
+			projectQuota: &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10"}},
+			want:         &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10"}},
+		},
+		{
+			name:           "invalid cluster default",
+			clusterDefault: `{"limitsCpu":2}`,
+			projectQuota:   &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10"}},
+			want:           &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10"}},
+		},
+		{
+			name:           "invalid cluster default quantity",
+			clusterDefault: `{"limitsCpu":"two"}`,
+			projectQuota:   &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10"}},
+			want:           &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10"}},
+		},
+		{
+			name:           "namespace default quota already set",
+			clusterDefault: `{"limitsCpu":"2"}`,
+			projectQuota:   &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "10"}},
+			nsQuota:        &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "5"}},
+			want:           &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "5"}},
+		},
+		{
+			name:           "no project quota",
+			clusterDefault: `{"limitsCpu":"2"}`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			clusterCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.Cluster, error) {
+				if name != "c-abc12" {
+					return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+				}
+				cluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}}
+				if test.clusterDefault != "" {
+					cluster.Annotations = map[string]string{NamespaceDefaultQuotaAnnotation: test.clusterDefault}
+				}
+				return cluster, nil
+			}).AnyTimes()
+			m := &Mutator{clusterCache: clusterCache}
+			project := &v3.Project{Spec: v3.ProjectSpec{
+				ClusterName:                   "c-abc12",
+				ResourceQuota:                 test.projectQuota,
+				NamespaceDefaultResourceQuota: test.nsQuota,
+			}}
+			m.defaultNamespaceQuota(project)
+			assert.Equal(t, test.want, project.Spec.NamespaceDefaultResourceQuota)
+
+			// the defaulted quotas must pass the validator's consistency check
+			if project.Spec.ResourceQuota != nil {
+				fieldErr, err := checkQuotaFields(project.Spec.ResourceQuota, project.Spec.NamespaceDefaultResourceQuota)
+				assert.NoError(t, err)
+				assert.Nil(t, fieldErr)
+			}
+		})
+	}
+}
+
+func TestDefaultNamespaceQuotaMissingCluster(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+	clusterCache.EXPECT().Get("c-missing").Return(nil, apierrors.NewNotFound(schema.GroupResource{}, "c-missing"))
+	m := &Mutator{clusterCache: clusterCache}
+	project := &v3.Project{Spec: v3.ProjectSpec{
+		ClusterName:   "c-missing",
+		ResourceQuota: &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{Pods: "10"}},
+	}}
+	m.defaultNamespaceQuota(project)
+	assert.Equal(t, &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{Pods: "10"}}, project.Spec.NamespaceDefaultResourceQuota)
+}
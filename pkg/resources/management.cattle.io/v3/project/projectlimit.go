@@ -0,0 +1,108 @@
+package project
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// MaxProjectsPerClusterSetting is the name of the setting holding the largest number of projects users can create in
+	// a cluster. Projects aren't limited if it's empty or 0.
+	MaxProjectsPerClusterSetting = "webhook-max-projects-per-cluster"
+
+	defaultProjectLabel = "authz.management.cattle.io/default-project"
+)
+
+var projectLimitRule = rules.Register(rules.Rule{
+	ID:            "project-cluster-limit",
+	GVR:           gvr,
+	Description:   "When the webhook-max-projects-per-cluster setting is set, projects can't be created in a cluster which already has that many projects. The default and system projects Rancher creates are neither limited nor counted.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "project.spec.clusterName: Forbidden: cluster c-abc12 already has 20 projects, the most allowed by the webhook-max-projects-per-cluster setting",
+	DenialCode:    string(admission.DenialConflict),
+})
+
+// ParseMaxProjectsPerCluster parses the value of the MaxProjectsPerClusterSetting setting, returning 0 if it's empty.
+func ParseMaxProjectsPerCluster(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("must be a non-negative number of projects")
+	}
+	return limit, nil
+}
+
+// validateProjectLimit checks that the cluster of a new project has fewer projects than the limit of the
+// MaxProjectsPerClusterSetting setting. It returns the error and warning of the current stage of the rule.
+func (a *admitter) validateProjectLimit(project *v3.Project) (*field.Error, string, error) {
+	if a.projectCache == nil || a.settingCache == nil || isRancherProject(project) {
+		return nil, "", nil
+	}
+	limit := a.maxProjectsPerCluster()
+	if limit == 0 {
+		return nil, "", nil
+	}
+	projects, err := a.projectCache.GetByIndex(projectsByClusterIndex, project.Spec.ClusterName)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list the projects of cluster %s: %w", project.Spec.ClusterName, err)
+	}
+	count := 0
+	for _, existing := range projects {
+		if existing.DeletionTimestamp != nil || isRancherProject(existing) ||
+			(existing.Namespace == project.Namespace && existing.Name == project.Name) {
+			continue
+		}
+		count++
+	}
+	if count < limit {
+		return nil, "", nil
+	}
+
+	fieldErr := field.Forbidden(projectSpecFieldPath.Child(clusterNameField), projectLimitRule.Message(
+		"cluster %s already has %d projects, the most allowed by the %s setting", project.Spec.ClusterName, count, MaxProjectsPerClusterSetting))
+	var overrides rules.Overrides
+	if a.clusterCache != nil {
+		if cluster, err := a.clusterCache.Get(project.Spec.ClusterName); err == nil && cluster != nil {
+			overrides = rules.OverridesOf(cluster.Annotations)
+		}
+	}
+	fieldErr, warning := rules.EnforceErrorWith(projectLimitRule, overrides, fieldErr)
+	return fieldErr, warning, nil
+}
+
+// maxProjectsPerCluster returns the limit of the MaxProjectsPerClusterSetting setting, 0 if it isn't set or invalid.
+func (a *admitter) maxProjectsPerCluster() int {
+	setting, err := a.settingCache.Get(MaxProjectsPerClusterSetting)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logrus.Warnf("[project-validation] failed to get setting %s: %v", MaxProjectsPerClusterSetting, err)
+		}
+		return 0
+	}
+	value := setting.Value
+	if value == "" {
+		value = setting.Default
+	}
+	limit, err := ParseMaxProjectsPerCluster(value)
+	if err != nil {
+		logrus.Warnf("[project-validation] ignoring invalid %s setting %q: %v", MaxProjectsPerClusterSetting, value, err)
+		return 0
+	}
+	return limit
+}
+
+// isRancherProject returns true if the project is the default or system project Rancher creates in each cluster.
+func isRancherProject(project *v3.Project) bool {
+	return project.Labels[systemProjectLabel] == "true" || project.Labels[defaultProjectLabel] == "true"
+}
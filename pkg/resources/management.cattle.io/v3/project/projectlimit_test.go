@@ -0,0 +1,136 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestValidateProjectLimit(t *testing.T) {
+	t.Parallel()
+	project := func(name string, labels map[string]string) *v3.Project {
+		return &v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "c-abc12", Labels: labels},
+			Spec:       v3.ProjectSpec{ClusterName: "c-abc12"},
+		}
+	}
+	deleting := project("p-deleting", nil)
+	deleting.DeletionTimestamp = &metav1.Time{}
+	existingProjects := []*v3.Project{
+		project("p-default", map[string]string{defaultProjectLabel: "true"}),
+		project("p-system", map[string]string{systemProjectLabel: "true"}),
+		project("p-1", nil),
+		project("p-2", nil),
+		deleting,
+	}
+
+	tests := []struct {
+		name        string
+		setting     *v3.Setting
+		project     *v3.Project
+		overrides   string
+		wantMessage string
+	}{
+		{
+			name:    "setting not found",
+			project: project("p-new", nil),
+		},
+		{
+			name:    "no limit",
+			setting: &v3.Setting{Value: ""},
+			project: project("p-new", nil),
+		},
+		{
+			name:    "zero limit",
+			setting: &v3.Setting{Value: "0"},
+			project: project("p-new", nil),
+		},
+		{
+			name:    "under the limit",
+			setting: &v3.Setting{Value: "3"},
+			project: project("p-new", nil),
+		},
+		{
+			name:        "limit reached",
+			setting:     &v3.Setting{Value: "2"},
+			project:     project("p-new", nil),
+			wantMessage: "project.spec.clusterName: Forbidden: cluster c-abc12 already has 2 projects, the most allowed by the webhook-max-projects-per-cluster setting",
+		},
+		{
+			name:        "limit from the default value",
+			setting:     &v3.Setting{Default: "1"},
+			project:     project("p-new", nil),
+			wantMessage: "cluster c-abc12 already has 2 projects",
+		},
+		{
+			name:    "invalid limit is ignored",
+			setting: &v3.Setting{Value: "lots"},
+			project: project("p-new", nil),
+		},
+		{
+			name:    "projects created by Rancher aren't limited",
+			setting: &v3.Setting{Value: "1"},
+			project: project("p-default-2", map[string]string{defaultProjectLabel: "true"}),
+		},
+		{
+			name:        "cluster overrides can't turn the rule off",
+			setting:     &v3.Setting{Value: "2"},
+			project:     project("p-new", nil),
+			overrides:   `{"project-cluster-limit":"off"}`,
+			wantMessage: "cluster c-abc12 already has 2 projects",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl)
+			if test.setting == nil {
+				settingCache.EXPECT().Get(MaxProjectsPerClusterSetting).Return(nil, apierrors.NewNotFound(schema.GroupResource{}, MaxProjectsPerClusterSetting)).AnyTimes()
+			} else {
+				settingCache.EXPECT().Get(MaxProjectsPerClusterSetting).Return(test.setting, nil).AnyTimes()
+			}
+			cluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-abc12"}}
+			if test.overrides != "" {
+				cluster.Annotations = map[string]string{rules.OverridesAnnotation: test.overrides}
+			}
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			clusterCache.EXPECT().Get("c-abc12").Return(cluster, nil).AnyTimes()
+			projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+			projectCache.EXPECT().GetByIndex(projectsByClusterIndex, "c-abc12").Return(existingProjects, nil).AnyTimes()
+
+			a := admitter{clusterCache: clusterCache, projectCache: projectCache, settingCache: settingCache}
+			fieldErr, warning, err := a.validateProjectLimit(test.project)
+			require.NoError(t, err)
+			assert.Empty(t, warning)
+			if test.wantMessage == "" {
+				assert.Nil(t, fieldErr)
+				return
+			}
+			require.NotNil(t, fieldErr)
+			assert.Contains(t, fieldErr.Error(), test.wantMessage)
+		})
+	}
+}
+
+func TestParseMaxProjectsPerCluster(t *testing.T) {
+	t.Parallel()
+	for value, want := range map[string]int{"": 0, "0": 0, " 25 ": 25} {
+		limit, err := ParseMaxProjectsPerCluster(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, limit, value)
+	}
+	for _, value := range []string{"-1", "ten", "1.5"} {
+		_, err := ParseMaxProjectsPerCluster(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,75 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/stretchr/testify/require"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+)
+
+// FuzzCheckQuota feeds random project, namespace default and used quota values through checkQuotaFields and
+// checkQuotaValues. Besides not panicking, the checks must treat the project and namespace default quotas symmetrically.
+func FuzzCheckQuota(f *testing.F) {
+	f.Add("10", "5", "2", "1Gi", "512Mi", "100Mi")
+	f.Add("10", "100", "", "1", "1", "")
+	f.Add("-1", "-100", "5", "1e18", "9223372036854775807", "1Ei")
+	f.Add("0.5", "500m", "1", "1.5Gi", "1536Mi", "2Gi")
+	f.Add("", "10", "x", "1G", "1Gi", "NaN")
+	f.Add("1e-9", "1n", "0", "999999999999999999999999Ei", "1", "-0")
+	f.Fuzz(func(t *testing.T, projectPods, nsPods, usedPods, projectMemory, nsMemory, usedMemory string) {
+		projectQuota := &v3.ProjectResourceQuota{
+			Limit: v3.ResourceQuotaLimit{Pods: projectPods, LimitsMemory: projectMemory},
+		}
+		nsQuota := &v3.NamespaceResourceQuota{
+			Limit: v3.ResourceQuotaLimit{Pods: nsPods, LimitsMemory: nsMemory},
+		}
+		oldProject := &v3.Project{
+			Spec: v3.ProjectSpec{
+				ResourceQuota: &v3.ProjectResourceQuota{
+					UsedLimit: v3.ResourceQuotaLimit{Pods: usedPods, LimitsMemory: usedMemory},
+				},
+			},
+		}
+
+		fieldErr, err := checkQuotaFields(projectQuota, nsQuota)
+		require.NoError(t, err)
+		swappedFieldErr, err := checkQuotaFields(&v3.ProjectResourceQuota{Limit: nsQuota.Limit}, &v3.NamespaceResourceQuota{Limit: projectQuota.Limit})
+		require.NoError(t, err)
+		require.Equal(t, fieldErr == nil, swappedFieldErr == nil, "checkQuotaFields must not depend on which quota defines a resource")
+		if fieldErr != nil {
+			return
+		}
+
+		a := &admitter{}
+		fieldErr, err = a.checkQuotaValues(&nsQuota.Limit, &projectQuota.Limit, oldProject)
+		if err != nil {
+			require.Nil(t, fieldErr)
+			return
+		}
+		// the used quota can only add denials
+		withoutUsedErr, err := a.checkQuotaValues(&nsQuota.Limit, &projectQuota.Limit, nil)
+		require.NoError(t, err)
+		if withoutUsedErr != nil {
+			require.NotNil(t, fieldErr, "a quota denied without a used quota must be denied with one")
+		}
+
+		projectList, err := quota.LimitToResourceList(&projectQuota.Limit)
+		require.NoError(t, err)
+		nsList, err := quota.LimitToResourceList(&nsQuota.Limit)
+		require.NoError(t, err)
+
+		// a namespace default equal to the project quota is only denied for negative values
+		sameErr, err := a.checkQuotaValues(&projectQuota.Limit, &projectQuota.Limit, nil)
+		require.NoError(t, err)
+		require.Equal(t, len(quotav1.IsNegative(projectList)) == 0, sameErr == nil)
+
+		// if each quota fits in the other, they must be equal
+		swappedErr, err := a.checkQuotaValues(&projectQuota.Limit, &nsQuota.Limit, nil)
+		require.NoError(t, err)
+		if withoutUsedErr == nil && swappedErr == nil {
+			require.True(t, quotav1.Equals(projectList, nsList), "quotas %v and %v fit in each other but are not equal", projectList, nsList)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package project
+
+import (
+	"reflect"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/rules"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// QuotaRevisionAnnotation holds the resourceVersion of the project the last change of its quota was made on. The
+// mutator sets it whenever the quota changes. Writers keep the value they read, so that a change of the quota made on
+// a read of the project older than the last quota change is detected.
+const QuotaRevisionAnnotation = "management.cattle.io/quota-revision"
+
+var quotaConflictRule = rules.Register(rules.Rule{
+	ID:            "project-quota-conflict",
+	GVR:           gvr,
+	Description:   "A change of the resource quota or the namespace default quota of a project must keep the management.cattle.io/quota-revision annotation of the project it was read from. The annotation is set to the project's resourceVersion whenever its quota changes, so a change made on a read of the project older than the last quota change is denied, instead of overwriting the other change and the used limit computed from it. The annotation can't be set to any other value. Changes that drop the annotation aren't checked.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: `project.metadata.annotations[management.cattle.io/quota-revision]: Invalid value: "10254": the quota of the project was changed since revision 10254, get the project again and reapply the change`,
+	DenialCode:    string(admission.DenialConflict),
+})
+
+// quotaChanged returns whether the limits of the resource quota or of the namespace default quota differ between the
+// projects. The used limit, which Rancher keeps up to date, isn't part of the quota.
+func quotaChanged(oldProject, newProject *v3.Project) bool {
+	return !reflect.DeepEqual(quotaLimit(oldProject), quotaLimit(newProject)) ||
+		!reflect.DeepEqual(namespaceDefaultQuotaLimit(oldProject), namespaceDefaultQuotaLimit(newProject))
+}
+
+func quotaLimit(project *v3.Project) v3.ResourceQuotaLimit {
+	if project.Spec.ResourceQuota == nil {
+		return v3.ResourceQuotaLimit{}
+	}
+	return project.Spec.ResourceQuota.Limit
+}
+
+func namespaceDefaultQuotaLimit(project *v3.Project) v3.ResourceQuotaLimit {
+	if project.Spec.NamespaceDefaultResourceQuota == nil {
+		return v3.ResourceQuotaLimit{}
+	}
+	return project.Spec.NamespaceDefaultResourceQuota.Limit
+}
+
+// stampQuotaRevision sets the QuotaRevisionAnnotation of the updated project to the resourceVersion of the old
+// project when its quota changed, unless the update carries a revision other than the old project's. Such an update
+// was made on a stale read of the project, and is denied by the validator.
+func stampQuotaRevision(oldProject, newProject *v3.Project) {
+	if oldProject.ResourceVersion == "" || !quotaChanged(oldProject, newProject) {
+		return
+	}
+	revision, ok := newProject.Annotations[QuotaRevisionAnnotation]
+	if ok && revision != oldProject.Annotations[QuotaRevisionAnnotation] {
+		return
+	}
+	if newProject.Annotations == nil {
+		newProject.Annotations = map[string]string{}
+	}
+	newProject.Annotations[QuotaRevisionAnnotation] = oldProject.ResourceVersion
+}
+
+// validateQuotaRevision checks that the QuotaRevisionAnnotation of the project only changes with its quota, to the
+// resourceVersion the change was made on, as set by stampQuotaRevision. It returns the error and warning of the
+// current stage of the rule.
+func (a *admitter) validateQuotaRevision(oldProject, newProject *v3.Project) (*field.Error, string) {
+	revision, ok := newProject.Annotations[QuotaRevisionAnnotation]
+	if !ok {
+		return nil, ""
+	}
+	var fieldErr *field.Error
+	if quotaChanged(oldProject, newProject) {
+		if revision == oldProject.ResourceVersion {
+			return nil, ""
+		}
+		fieldErr = field.Invalid(projectAnnotationsFieldPath.Key(QuotaRevisionAnnotation), revision,
+			quotaConflictRule.Message("the quota of the project was changed since revision %s, get the project again and reapply the change", revision))
+	} else {
+		if old, ok := oldProject.Annotations[QuotaRevisionAnnotation]; ok && revision == old {
+			return nil, ""
+		}
+		fieldErr = field.Invalid(projectAnnotationsFieldPath.Key(QuotaRevisionAnnotation), revision,
+			quotaConflictRule.Message("the revision is set by the webhook when the quota of the project changes"))
+	}
+	return rules.EnforceErrorWith(quotaConflictRule, a.ruleOverrides(newProject), fieldErr)
+}
@@ -0,0 +1,124 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// quotaProject returns a project at the given resourceVersion with the given quota revision, if any, and configMaps
+// quota limit.
+func quotaProject(resourceVersion, revision, configMaps string) *v3.Project {
+	project := &v3.Project{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "testcluster", ResourceVersion: resourceVersion},
+		Spec: v3.ProjectSpec{
+			ClusterName: "testcluster",
+			ResourceQuota: &v3.ProjectResourceQuota{
+				Limit: v3.ResourceQuotaLimit{ConfigMaps: configMaps},
+			},
+		},
+	}
+	if revision != "" {
+		project.Annotations = map[string]string{QuotaRevisionAnnotation: revision}
+	}
+	return project
+}
+
+func TestQuotaRevisionRace(t *testing.T) {
+	t.Parallel()
+	a := &admitter{}
+	// two writers read the project at resourceVersion 10, whose quota was last changed at resourceVersion 5
+	stored := quotaProject("10", "5", "100")
+
+	first := quotaProject("10", "5", "80")
+	stampQuotaRevision(stored, first)
+	assert.Equal(t, "10", first.Annotations[QuotaRevisionAnnotation])
+	fieldErr, _ := a.validateQuotaRevision(stored, first)
+	assert.Nil(t, fieldErr)
+
+	// the second writer's change is made on the quota the first writer changed
+	stored = first.DeepCopy()
+	stored.ResourceVersion = "11"
+	second := quotaProject("10", "5", "120")
+	stampQuotaRevision(stored, second)
+	assert.Equal(t, "5", second.Annotations[QuotaRevisionAnnotation], "a stale revision isn't stamped")
+	fieldErr, _ = a.validateQuotaRevision(stored, second)
+	if assert.NotNil(t, fieldErr) {
+		assert.Contains(t, fieldErr.Error(), "the quota of the project was changed since revision 5")
+	}
+
+	// once it got the project again, its change applies
+	second = quotaProject("11", "10", "120")
+	stampQuotaRevision(stored, second)
+	assert.Equal(t, "11", second.Annotations[QuotaRevisionAnnotation])
+	fieldErr, _ = a.validateQuotaRevision(stored, second)
+	assert.Nil(t, fieldErr)
+}
+
+func TestValidateQuotaRevision(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		oldProject *v3.Project
+		newProject *v3.Project
+		wantErr    bool
+	}{
+		{
+			name:       "quota change without a revision",
+			oldProject: quotaProject("10", "5", "100"),
+			newProject: quotaProject("10", "", "80"),
+		},
+		{
+			name:       "stamped quota change",
+			oldProject: quotaProject("10", "5", "100"),
+			newProject: quotaProject("10", "10", "80"),
+		},
+		{
+			name:       "quota change on a stale revision",
+			oldProject: quotaProject("10", "5", "100"),
+			newProject: quotaProject("10", "3", "80"),
+			wantErr:    true,
+		},
+		{
+			name:       "revision kept without a quota change",
+			oldProject: quotaProject("10", "5", "100"),
+			newProject: quotaProject("10", "5", "100"),
+		},
+		{
+			name:       "revision set without a quota change",
+			oldProject: quotaProject("10", "5", "100"),
+			newProject: quotaProject("10", "10", "100"),
+			wantErr:    true,
+		},
+		{
+			name:       "revision added without a quota change",
+			oldProject: quotaProject("10", "", "100"),
+			newProject: quotaProject("10", "10", "100"),
+			wantErr:    true,
+		},
+		{
+			name:       "used limit change isn't a quota change",
+			oldProject: quotaProject("10", "5", "100"),
+			newProject: func() *v3.Project {
+				project := quotaProject("10", "5", "100")
+				project.Spec.ResourceQuota.UsedLimit = v3.ResourceQuotaLimit{ConfigMaps: "20"}
+				return project
+			}(),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			a := &admitter{}
+			fieldErr, _ := a.validateQuotaRevision(test.oldProject, test.newProject)
+			if test.wantErr {
+				assert.NotNil(t, fieldErr)
+			} else {
+				assert.Nil(t, fieldErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// quotaDecreaseApproverRoleEnv is the global role whose users can approve decreases of project quotas close to, or
+	// below, the used limit. The approval rule is off when it's empty.
+	quotaDecreaseApproverRoleEnv = "CATTLE_PROJECT_QUOTA_DECREASE_APPROVER_ROLE"
+	// quotaDecreaseMarginEnv is the margin above the used limit, in percent, below which decreasing a project quota
+	// needs an approval.
+	quotaDecreaseMarginEnv = "CATTLE_PROJECT_QUOTA_DECREASE_MARGIN"
+	// defaultQuotaDecreaseMargin is the margin used when quotaDecreaseMarginEnv isn't set.
+	defaultQuotaDecreaseMargin = 10
+
+	// QuotaDecreaseApprovedByAnnotation names the user approving a decrease of the project quota close to the used limit.
+	QuotaDecreaseApprovedByAnnotation = "management.cattle.io/quota-decrease-approved-by"
+
+	// quotaDecreaseAuditKey and quotaDecreaseApproverAuditKey are the audit annotations recording approved decreases.
+	quotaDecreaseAuditKey         = "quota-decrease"
+	quotaDecreaseApproverAuditKey = "quota-decrease-approved-by"
+
+	grbUserIndex = "webhook.cattle.io/project-grb-by-user"
+)
+
+var quotaDecreaseRule = rules.Register(rules.Rule{
+	ID:            "project-quota-decrease-approval",
+	GVR:           gvr,
+	Description:   "When CATTLE_PROJECT_QUOTA_DECREASE_APPROVER_ROLE is set, decreasing the resource quota of a project below its used limit plus CATTLE_PROJECT_QUOTA_DECREASE_MARGIN percent must be made by a user bound to that global role, who names themselves in the management.cattle.io/quota-decrease-approved-by annotation. This prevents accidentally squeezing out the namespaces of a project.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "project.spec.resourceQuota: Forbidden: the quota decrease configMaps=100->20 is within 10% of the used limit and needs the approval of a user with the global role quota-approver",
+	DenialCode:    string(admission.DenialBadRequest),
+})
+
+// quotaDecreaseApproval is the configuration of the quota decrease approval rule.
+type quotaDecreaseApproval struct {
+	// role is the global role of the users allowed to approve decreases. The rule is off when it's empty.
+	role string
+	// margin is the margin above the used limit, in percent, below which decreases need an approval.
+	margin   int
+	grbCache controllerv3.GlobalRoleBindingCache
+}
+
+// quotaDecreaseApprovalFromEnv returns the configuration of the quota decrease approval rule. An invalid margin is
+// ignored in favor of the default.
+func quotaDecreaseApprovalFromEnv(grbCache controllerv3.GlobalRoleBindingCache) quotaDecreaseApproval {
+	approval := quotaDecreaseApproval{
+		role:     strings.TrimSpace(os.Getenv(quotaDecreaseApproverRoleEnv)),
+		margin:   defaultQuotaDecreaseMargin,
+		grbCache: grbCache,
+	}
+	if value := os.Getenv(quotaDecreaseMarginEnv); value != "" {
+		margin, err := strconv.Atoi(value)
+		if err != nil || margin < 0 {
+			logrus.Warnf("[project-validation] ignoring invalid %s value %q, using %d", quotaDecreaseMarginEnv, value, defaultQuotaDecreaseMargin)
+		} else {
+			approval.margin = margin
+		}
+	}
+	if approval.role != "" && grbCache != nil {
+		grbCache.AddIndexer(grbUserIndex, grbByUser)
+	}
+	return approval
+}
+
+// grbByUser indexes global role bindings by the name of the user they bind.
+func grbByUser(grb *v3.GlobalRoleBinding) ([]string, error) {
+	if grb.UserName == "" {
+		return nil, nil
+	}
+	return []string{grb.UserName}, nil
+}
+
+// validateQuotaDecrease checks that decreases of the project quota below the used limit plus the margin are approved
+// by the requesting user, who must be bound to the approver role. It returns the error and warning of the current stage
+// of the rule, and the audit annotations recording the decrease.
+func (a *admitter) validateQuotaDecrease(request *admission.Request, oldProject, newProject *v3.Project) (*field.Error, string, map[string]string, error) {
+	approval := a.quotaDecreaseApproval
+	if approval.role == "" || oldProject == nil || oldProject.Spec.ResourceQuota == nil || newProject.Spec.ResourceQuota == nil {
+		return nil, "", nil, nil
+	}
+	decreased, err := approval.squeezingDecreases(oldProject.Spec.ResourceQuota, newProject.Spec.ResourceQuota)
+	if err != nil || len(decreased) == 0 {
+		return nil, "", nil, err
+	}
+
+	username := request.UserInfo.Username
+	approved := newProject.Annotations[QuotaDecreaseApprovedByAnnotation] == username
+	if approved {
+		approved, err = approval.isApprover(username)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+	if approved {
+		return nil, "", map[string]string{
+			quotaDecreaseAuditKey:         strings.Join(decreased, ","),
+			quotaDecreaseApproverAuditKey: username,
+		}, nil
+	}
+
+	fieldErr := field.Forbidden(projectSpecFieldPath.Child(projectQuotaField),
+		quotaDecreaseRule.Message("the quota decrease %s is within %d%% of the used limit and needs the approval of a user with the global role %s",
+			strings.Join(decreased, ","), approval.margin, approval.role))
+	fieldErr, warning := rules.EnforceErrorWith(quotaDecreaseRule, a.ruleOverrides(newProject), fieldErr)
+	return fieldErr, warning, nil, nil
+}
+
+// squeezingDecreases returns the resources whose limit decreased below the used limit plus the margin, formatted as
+// resource=old->new, sorted by resource.
+func (q quotaDecreaseApproval) squeezingDecreases(oldQuota, newQuota *v3.ProjectResourceQuota) ([]string, error) {
+	oldLimits, err := quota.LimitToResourceList(&oldQuota.Limit)
+	if err != nil {
+		return nil, err
+	}
+	newLimits, err := quota.LimitToResourceList(&newQuota.Limit)
+	if err != nil {
+		return nil, err
+	}
+	usedLimits, err := quota.LimitToResourceList(&oldQuota.UsedLimit)
+	if err != nil {
+		return nil, err
+	}
+	var decreased []string
+	for name, newLimit := range newLimits {
+		oldLimit, ok := oldLimits[name]
+		if !ok || newLimit.Cmp(oldLimit) >= 0 {
+			continue
+		}
+		used := usedLimits[name]
+		threshold := used.AsApproximateFloat64() * float64(100+q.margin) / 100
+		if newLimit.AsApproximateFloat64() < threshold {
+			decreased = append(decreased, fmt.Sprintf("%s=%s->%s", name, oldLimit.String(), newLimit.String()))
+		}
+	}
+	sort.Strings(decreased)
+	return decreased, nil
+}
+
+// isApprover returns whether the user is bound to the approver role.
+func (q quotaDecreaseApproval) isApprover(username string) (bool, error) {
+	grbs, err := q.grbCache.GetByIndex(grbUserIndex, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to get global role bindings of user %s: %w", username, err)
+	}
+	for _, grb := range grbs {
+		if grb.GlobalRoleName == q.role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
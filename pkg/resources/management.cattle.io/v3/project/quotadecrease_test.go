@@ -0,0 +1,152 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateQuotaDecrease(t *testing.T) {
+	t.Parallel()
+	const approver = "u-approver"
+	projectWithQuota := func(configMaps, used, approvedBy string) *v3.Project {
+		project := &v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "testcluster"},
+			Spec: v3.ProjectSpec{
+				ClusterName: "testcluster",
+				ResourceQuota: &v3.ProjectResourceQuota{
+					Limit:     v3.ResourceQuotaLimit{ConfigMaps: configMaps},
+					UsedLimit: v3.ResourceQuotaLimit{ConfigMaps: used},
+				},
+				NamespaceDefaultResourceQuota: &v3.NamespaceResourceQuota{
+					Limit: v3.ResourceQuotaLimit{ConfigMaps: "10"},
+				},
+			},
+		}
+		if approvedBy != "" {
+			project.Annotations = map[string]string{QuotaDecreaseApprovedByAnnotation: approvedBy}
+		}
+		return project
+	}
+
+	tests := []struct {
+		name          string
+		role          string
+		username      string
+		oldProject    *v3.Project
+		newProject    *v3.Project
+		wantAllowed   bool
+		wantMessage   string
+		wantAuditInfo map[string]string
+	}{
+		{
+			name:        "decrease well above the used limit",
+			role:        "quota-approver",
+			username:    "u-abc123",
+			oldProject:  projectWithQuota("100", "40", ""),
+			newProject:  projectWithQuota("50", "40", ""),
+			wantAllowed: true,
+		},
+		{
+			name:        "increase",
+			role:        "quota-approver",
+			username:    "u-abc123",
+			oldProject:  projectWithQuota("100", "95", ""),
+			newProject:  projectWithQuota("200", "95", ""),
+			wantAllowed: true,
+		},
+		{
+			name:        "unapproved decrease within the margin",
+			role:        "quota-approver",
+			username:    "u-abc123",
+			oldProject:  projectWithQuota("100", "40", ""),
+			newProject:  projectWithQuota("42", "40", ""),
+			wantMessage: "project.spec.resourceQuota: Forbidden: the quota decrease configMaps=100->42 is within 10% of the used limit and needs the approval of a user with the global role quota-approver",
+		},
+		{
+			name:        "decrease approved by another user",
+			role:        "quota-approver",
+			username:    "u-abc123",
+			oldProject:  projectWithQuota("100", "40", ""),
+			newProject:  projectWithQuota("42", "40", approver),
+			wantMessage: "needs the approval of a user with the global role quota-approver",
+		},
+		{
+			name:        "decrease approved by a user without the role",
+			role:        "quota-approver",
+			username:    "u-abc123",
+			oldProject:  projectWithQuota("100", "40", ""),
+			newProject:  projectWithQuota("42", "40", "u-abc123"),
+			wantMessage: "needs the approval of a user with the global role quota-approver",
+		},
+		{
+			name:          "decrease approved by the approver",
+			role:          "quota-approver",
+			username:      approver,
+			oldProject:    projectWithQuota("100", "40", ""),
+			newProject:    projectWithQuota("42", "40", approver),
+			wantAllowed:   true,
+			wantAuditInfo: map[string]string{quotaDecreaseAuditKey: "configMaps=100->42", quotaDecreaseApproverAuditKey: approver},
+		},
+		{
+			name:        "approved decrease is still limited by the used limit",
+			role:        "quota-approver",
+			username:    approver,
+			oldProject:  projectWithQuota("100", "40", ""),
+			newProject:  projectWithQuota("30", "40", approver),
+			wantMessage: "resourceQuota is below the used limit",
+		},
+		{
+			name:        "rule is off without an approver role",
+			username:    "u-abc123",
+			oldProject:  projectWithQuota("100", "40", ""),
+			newProject:  projectWithQuota("42", "40", ""),
+			wantAllowed: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			grbCache := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRoleBinding](ctrl)
+			grbCache.EXPECT().GetByIndex(grbUserIndex, approver).Return([]*v3.GlobalRoleBinding{
+				{GlobalRoleName: "user", UserName: approver},
+				{GlobalRoleName: "quota-approver", UserName: approver},
+			}, nil).AnyTimes()
+			grbCache.EXPECT().GetByIndex(grbUserIndex, "u-abc123").Return([]*v3.GlobalRoleBinding{
+				{GlobalRoleName: "user", UserName: "u-abc123"},
+			}, nil).AnyTimes()
+
+			req, err := createProjectRequest(test.oldProject, test.newProject, admissionv1.Update, false)
+			require.NoError(t, err)
+			req.UserInfo.Username = test.username
+			a := admitter{quotaDecreaseApproval: quotaDecreaseApproval{role: test.role, margin: 10, grbCache: grbCache}}
+			response, err := a.Admit(req)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+			assert.Equal(t, test.wantAuditInfo, response.AuditAnnotations)
+			if !test.wantAllowed {
+				require.NotNil(t, response.Result)
+				assert.Contains(t, response.Result.Message, test.wantMessage)
+			}
+		})
+	}
+}
+
+func TestQuotaDecreaseApprovalFromEnv(t *testing.T) {
+	t.Setenv(quotaDecreaseApproverRoleEnv, "quota-approver")
+	t.Setenv(quotaDecreaseMarginEnv, "25")
+	approval := quotaDecreaseApprovalFromEnv(nil)
+	assert.Equal(t, "quota-approver", approval.role)
+	assert.Equal(t, 25, approval.margin)
+
+	t.Setenv(quotaDecreaseMarginEnv, "-5")
+	assert.Equal(t, defaultQuotaDecreaseMargin, quotaDecreaseApprovalFromEnv(nil).margin)
+}
@@ -0,0 +1,43 @@
+package project
+
+import (
+	"reflect"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// normalizeQuotas rewrites the quantities of the quotas and default limits set by users on the project to their
+// canonical form, e.g. 1024Mi to 1Gi and 1000m to 1, so that controllers comparing them with the quotas of namespaces,
+// which Kubernetes stores in canonical form, don't see a difference. The used limit, computed by Rancher, is left
+// unchanged. Invalid quantities are left for the validator to deny.
+func normalizeQuotas(project *v3.Project) {
+	if quota := project.Spec.ResourceQuota; quota != nil {
+		normalizeQuantities(&quota.Limit)
+	}
+	if quota := project.Spec.NamespaceDefaultResourceQuota; quota != nil {
+		normalizeQuantities(&quota.Limit)
+	}
+	if limit := project.Spec.ContainerDefaultResourceLimit; limit != nil {
+		normalizeQuantities(limit)
+	}
+}
+
+// normalizeQuantities rewrites the string fields of the struct pointed to by limits, which hold quantities, to their
+// canonical form.
+func normalizeQuantities(limits any) {
+	value := reflect.ValueOf(limits).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() != reflect.String || field.String() == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(field.String())
+		if err != nil {
+			continue
+		}
+		// adding zero drops the string the quantity was parsed from, which String returns as is for integers like 05
+		quantity.Add(resource.Quantity{})
+		field.SetString(quantity.String())
+	}
+}
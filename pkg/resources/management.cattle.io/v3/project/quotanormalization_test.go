@@ -0,0 +1,71 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestNormalizeQuantities(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "1024Mi", want: "1Gi"},
+		{value: "1536Mi", want: "1536Mi"},
+		{value: "0.5Gi", want: "512Mi"},
+		{value: "1000m", want: "1"},
+		{value: "1500m", want: "1500m"},
+		{value: "0.5", want: "500m"},
+		{value: "1000", want: "1k"},
+		{value: "1000000k", want: "1G"},
+		{value: "1e3", want: "1e3"},
+		{value: "10", want: "10"},
+		{value: "0", want: "0"},
+		{value: "+5", want: "5"},
+		{value: "05", want: "5"},
+		{value: "2.50Gi", want: "2560Mi"},
+		{value: "invalid", want: "invalid"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.value, func(t *testing.T) {
+			t.Parallel()
+			limit := v3.ContainerResourceLimit{LimitsMemory: test.value}
+			normalizeQuantities(&limit)
+			assert.Equal(t, test.want, limit.LimitsMemory)
+
+			original, err := resource.ParseQuantity(test.value)
+			if err != nil {
+				return
+			}
+			normalized, err := resource.ParseQuantity(limit.LimitsMemory)
+			require.NoError(t, err)
+			assert.Zero(t, original.Cmp(normalized), "the normalized quantity must be equal to the original one")
+			assert.Equal(t, normalized.String(), limit.LimitsMemory, "the normalized quantity must be canonical")
+		})
+	}
+}
+
+func TestNormalizeQuotas(t *testing.T) {
+	t.Parallel()
+	project := &v3.Project{Spec: v3.ProjectSpec{
+		ResourceQuota: &v3.ProjectResourceQuota{
+			Limit:     v3.ResourceQuotaLimit{LimitsMemory: "2048Mi", Pods: "10"},
+			UsedLimit: v3.ResourceQuotaLimit{LimitsMemory: "1024Mi"},
+		},
+		NamespaceDefaultResourceQuota: &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{RequestsCPU: "2000m"}},
+		ContainerDefaultResourceLimit: &v3.ContainerResourceLimit{LimitsCPU: "0.5"},
+	}}
+	normalizeQuotas(project)
+	assert.Equal(t, v3.ResourceQuotaLimit{LimitsMemory: "2Gi", Pods: "10"}, project.Spec.ResourceQuota.Limit)
+	assert.Equal(t, "1024Mi", project.Spec.ResourceQuota.UsedLimit.LimitsMemory, "the used limit must be left unchanged")
+	assert.Equal(t, "2", project.Spec.NamespaceDefaultResourceQuota.Limit.RequestsCPU)
+	assert.Equal(t, "500m", project.Spec.ContainerDefaultResourceLimit.LimitsCPU)
+
+	normalizeQuotas(&v3.Project{})
+}
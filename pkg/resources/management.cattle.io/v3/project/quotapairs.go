@@ -0,0 +1,108 @@
+package project
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// quotaPairsEnv lists the resource:recommended pairs of quota resources, see quota.ParseRecommendedPairs.
+const quotaPairsEnv = "CATTLE_PROJECT_QUOTA_RECOMMENDED_PAIRS"
+
+var quotaPairsRule = rules.Register(rules.Rule{
+	ID:            "project-quota-pairs",
+	GVR:           gvr,
+	Description:   "When the project quota or the namespace default quota sets a resource without the resource recommended along with it, e.g. requestsCpu without requestsMemory, or limitsCpu without requestsCpu, the request is allowed with a warning, since workloads that only set the other resource, or don't set it at all, get stranded. The pairs are configured by CATTLE_PROJECT_QUOTA_RECOMMENDED_PAIRS. On update, quotas that didn't change aren't checked.",
+	Severity:      rules.SeverityWarn,
+	ExampleDenial: "project.spec.resourceQuota.limit.requestsMemory: Required value: recommended since requestsCpu is set",
+})
+
+// quotaPairsFromEnv returns the recommended pairs of quota resources. An invalid list is ignored in favor of the
+// default pairs.
+func quotaPairsFromEnv() []quota.RecommendedPair {
+	pairs, err := quota.ParseRecommendedPairs(os.Getenv(quotaPairsEnv))
+	if err != nil {
+		logrus.Warnf("[project-validation] ignoring invalid %s value: %v", quotaPairsEnv, err)
+		return quota.DefaultRecommendedPairs
+	}
+	return pairs
+}
+
+// validateQuotaPairs checks that the quotas of the project set the resources recommended along with the ones they set.
+// Quotas that didn't change on update aren't checked, so that only the user changing them is warned. It returns the
+// errors and warnings of the current stage of the rule.
+func (a *admitter) validateQuotaPairs(oldProject, newProject *v3.Project) (field.ErrorList, []string, error) {
+	var fieldErrs field.ErrorList
+	check := func(limit, oldLimit *v3.ResourceQuotaLimit, path *field.Path) error {
+		if limit == nil || (oldLimit != nil && reflect.DeepEqual(limit, oldLimit)) {
+			return nil
+		}
+		resources, err := quotaResources(limit)
+		if err != nil {
+			return err
+		}
+		// the resources recommended by each missing resource, in the order of the pairs
+		missing := map[string][]string{}
+		var order []string
+		for _, pair := range a.quotaPairs {
+			if !slices.Contains(resources, pair.Resource) || slices.Contains(resources, pair.Recommended) {
+				continue
+			}
+			if _, ok := missing[pair.Recommended]; !ok {
+				order = append(order, pair.Recommended)
+			}
+			missing[pair.Recommended] = append(missing[pair.Recommended], pair.Resource)
+		}
+		for _, recommended := range order {
+			verb := "is"
+			if len(missing[recommended]) > 1 {
+				verb = "are"
+			}
+			fieldErrs = append(fieldErrs, field.Required(path.Child(recommended),
+				quotaPairsRule.Message("recommended since %s %s set", strings.Join(missing[recommended], " and "), verb)))
+		}
+		return nil
+	}
+	var limit, oldLimit *v3.ResourceQuotaLimit
+	if newProject.Spec.ResourceQuota != nil {
+		limit = &newProject.Spec.ResourceQuota.Limit
+	}
+	if oldProject != nil && oldProject.Spec.ResourceQuota != nil {
+		oldLimit = &oldProject.Spec.ResourceQuota.Limit
+	}
+	if err := check(limit, oldLimit, projectSpecFieldPath.Child(projectQuotaField, "limit")); err != nil {
+		return nil, nil, err
+	}
+	limit, oldLimit = nil, nil
+	if newProject.Spec.NamespaceDefaultResourceQuota != nil {
+		limit = &newProject.Spec.NamespaceDefaultResourceQuota.Limit
+	}
+	if oldProject != nil && oldProject.Spec.NamespaceDefaultResourceQuota != nil {
+		oldLimit = &oldProject.Spec.NamespaceDefaultResourceQuota.Limit
+	}
+	if err := check(limit, oldLimit, projectSpecFieldPath.Child(namespaceQuotaField, "limit")); err != nil {
+		return nil, nil, err
+	}
+	if len(fieldErrs) == 0 {
+		return nil, nil, nil
+	}
+
+	var errList field.ErrorList
+	var warnings []string
+	overrides := a.ruleOverrides(newProject)
+	for _, fieldErr := range fieldErrs {
+		if fieldErr, warning := rules.EnforceErrorWith(quotaPairsRule, overrides, fieldErr); fieldErr != nil {
+			errList = append(errList, fieldErr)
+		} else if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return errList, warnings, nil
+}
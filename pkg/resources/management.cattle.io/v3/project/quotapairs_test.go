@@ -0,0 +1,138 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateQuotaPairs(t *testing.T) {
+	t.Parallel()
+	project := func(limit, nsLimit v3.ResourceQuotaLimit) *v3.Project {
+		return &v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: "p-abc12", Namespace: "c-abc12"},
+			Spec: v3.ProjectSpec{
+				ClusterName:                   "c-abc12",
+				ResourceQuota:                 &v3.ProjectResourceQuota{Limit: limit},
+				NamespaceDefaultResourceQuota: &v3.NamespaceResourceQuota{Limit: nsLimit},
+			},
+		}
+	}
+	tests := []struct {
+		name         string
+		pairs        []quota.RecommendedPair
+		overrides    string
+		oldProject   *v3.Project
+		newProject   *v3.Project
+		wantWarnings []string
+		wantErrors   []string
+	}{
+		{
+			name:       "recommended resources set",
+			newProject: project(v3.ResourceQuotaLimit{RequestsCPU: "4", RequestsMemory: "4Gi"}, v3.ResourceQuotaLimit{RequestsCPU: "1", RequestsMemory: "1Gi"}),
+		},
+		{
+			name:       "resources outside the pairs",
+			newProject: project(v3.ResourceQuotaLimit{Pods: "10"}, v3.ResourceQuotaLimit{Pods: "2"}),
+		},
+		{
+			name:       "requests cpu without requests memory",
+			newProject: project(v3.ResourceQuotaLimit{RequestsCPU: "4"}, v3.ResourceQuotaLimit{}),
+			wantWarnings: []string{
+				"project.spec.resourceQuota.limit.requestsMemory: Required value: recommended since requestsCpu is set",
+			},
+		},
+		{
+			name:       "limits cpu without requests cpu and limits memory",
+			newProject: project(v3.ResourceQuotaLimit{}, v3.ResourceQuotaLimit{LimitsCPU: "1"}),
+			wantWarnings: []string{
+				"project.spec.namespaceDefaultResourceQuota.limit.limitsMemory: Required value: recommended since limitsCpu is set",
+				"project.spec.namespaceDefaultResourceQuota.limit.requestsCpu: Required value: recommended since limitsCpu is set",
+			},
+		},
+		{
+			name:       "resource recommended by several resources",
+			newProject: project(v3.ResourceQuotaLimit{LimitsCPU: "4", LimitsMemory: "4Gi", RequestsCPU: "2"}, v3.ResourceQuotaLimit{}),
+			wantWarnings: []string{
+				"project.spec.resourceQuota.limit.requestsMemory: Required value: recommended since requestsCpu and limitsMemory are set",
+			},
+		},
+		{
+			name:       "quota unchanged on update",
+			oldProject: project(v3.ResourceQuotaLimit{RequestsCPU: "4"}, v3.ResourceQuotaLimit{}),
+			newProject: project(v3.ResourceQuotaLimit{RequestsCPU: "4"}, v3.ResourceQuotaLimit{}),
+		},
+		{
+			name:       "quota changed on update",
+			oldProject: project(v3.ResourceQuotaLimit{RequestsCPU: "4"}, v3.ResourceQuotaLimit{}),
+			newProject: project(v3.ResourceQuotaLimit{RequestsCPU: "8"}, v3.ResourceQuotaLimit{}),
+			wantWarnings: []string{
+				"project.spec.resourceQuota.limit.requestsMemory: Required value: recommended since requestsCpu is set",
+			},
+		},
+		{
+			name:       "custom pairs",
+			pairs:      []quota.RecommendedPair{{Resource: "pods", Recommended: "configMaps"}},
+			newProject: project(v3.ResourceQuotaLimit{Pods: "10", RequestsCPU: "4"}, v3.ResourceQuotaLimit{}),
+			wantWarnings: []string{
+				"project.spec.resourceQuota.limit.configMaps: Required value: recommended since pods is set",
+			},
+		},
+		{
+			name:       "denied by the overrides of the cluster",
+			overrides:  `{"project-quota-pairs": "deny"}`,
+			newProject: project(v3.ResourceQuotaLimit{RequestsCPU: "4"}, v3.ResourceQuotaLimit{}),
+			wantErrors: []string{
+				"project.spec.resourceQuota.limit.requestsMemory: Required value: recommended since requestsCpu is set",
+			},
+		},
+		{
+			name:       "silenced by the overrides of the cluster",
+			overrides:  `{"project-quota-pairs": "off"}`,
+			newProject: project(v3.ResourceQuotaLimit{RequestsCPU: "4"}, v3.ResourceQuotaLimit{}),
+		},
+		{
+			name:       "project without quotas",
+			newProject: &v3.Project{Spec: v3.ProjectSpec{ClusterName: "c-abc12"}},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			cluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-abc12"}}
+			if test.overrides != "" {
+				cluster.Annotations = map[string]string{rules.OverridesAnnotation: test.overrides}
+			}
+			clusterCache.EXPECT().Get("c-abc12").Return(cluster, nil).AnyTimes()
+			pairs := test.pairs
+			if pairs == nil {
+				pairs = quota.DefaultRecommendedPairs
+			}
+			a := &admitter{clusterCache: clusterCache, quotaPairs: pairs}
+
+			errList, warnings, err := a.validateQuotaPairs(test.oldProject, test.newProject)
+			require.NoError(t, err)
+			if assert.Len(t, warnings, len(test.wantWarnings), "%v", warnings) {
+				for i, wantWarning := range test.wantWarnings {
+					assert.Contains(t, warnings[i], wantWarning)
+					assert.Contains(t, warnings[i], "/rules/project-quota-pairs")
+				}
+			}
+			if assert.Len(t, errList, len(test.wantErrors), "%v", errList) {
+				for i, wantError := range test.wantErrors {
+					assert.Contains(t, errList[i].Error(), wantError)
+				}
+			}
+		})
+	}
+}
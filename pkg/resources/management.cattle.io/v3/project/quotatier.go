@@ -0,0 +1,149 @@
+package project
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var quotaTierRule = rules.Register(rules.Rule{
+	ID:            "project-quota-tier",
+	GVR:           gvr,
+	Description:   "When the cluster of a project has the management.cattle.io/cluster-tier label and ClusterTierPolicy objects exist for its tier, the project quota and namespace default quota may only set the resources allowed by one of the policies. On update, resources the quotas already set aren't checked.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "project.spec.resourceQuota.limit: Forbidden: resources servicesLoadBalancers aren't allowed on clusters of tier free",
+	DenialCode:    string(admission.DenialForbidden),
+})
+
+// validateQuotaTier checks that the quotas of the project only set the resources allowed by the policies of the tier
+// of its cluster. Nothing is checked for clusters without a tier, or tiers without policies. On update, the resources
+// the quotas already set aren't checked, so that projects can still be updated after their cluster moves to a more
+// restricted tier. It returns the errors and warnings of the current stage of the rule.
+func (a *admitter) validateQuotaTier(oldProject, newProject *v3.Project) (field.ErrorList, []string, error) {
+	if a.tierPolicyCache == nil || a.clusterCache == nil ||
+		(newProject.Spec.ResourceQuota == nil && newProject.Spec.NamespaceDefaultResourceQuota == nil) {
+		return nil, nil, nil
+	}
+	cluster, err := a.clusterCache.Get(newProject.Spec.ClusterName)
+	if err != nil || cluster == nil {
+		// missing clusters are denied by the other checks
+		return nil, nil, nil
+	}
+	tier := cluster.Labels[webhookv1.ClusterTierLabel]
+	if tier == "" {
+		return nil, nil, nil
+	}
+	policies, err := a.tierPolicies(tier)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(policies) == 0 {
+		return nil, nil, nil
+	}
+
+	var fieldErrs field.ErrorList
+	check := func(limit, oldLimit *v3.ResourceQuotaLimit, path *field.Path) error {
+		resources, err := quotaResources(limit)
+		if err != nil {
+			return err
+		}
+		oldResources, err := quotaResources(oldLimit)
+		if err != nil {
+			return err
+		}
+		var denied []string
+		for _, resource := range resources {
+			if slices.Contains(oldResources, resource) || allowedByTierPolicies(policies, resource) {
+				continue
+			}
+			denied = append(denied, resource)
+		}
+		if len(denied) != 0 {
+			fieldErrs = append(fieldErrs, field.Forbidden(path, quotaTierRule.Message(
+				"resources %s aren't allowed on clusters of tier %s", strings.Join(denied, ","), tier)))
+		}
+		return nil
+	}
+	var limit, oldLimit *v3.ResourceQuotaLimit
+	if newProject.Spec.ResourceQuota != nil {
+		limit = &newProject.Spec.ResourceQuota.Limit
+	}
+	if oldProject != nil && oldProject.Spec.ResourceQuota != nil {
+		oldLimit = &oldProject.Spec.ResourceQuota.Limit
+	}
+	if err := check(limit, oldLimit, projectSpecFieldPath.Child(projectQuotaField, "limit")); err != nil {
+		return nil, nil, err
+	}
+	limit, oldLimit = nil, nil
+	if newProject.Spec.NamespaceDefaultResourceQuota != nil {
+		limit = &newProject.Spec.NamespaceDefaultResourceQuota.Limit
+	}
+	if oldProject != nil && oldProject.Spec.NamespaceDefaultResourceQuota != nil {
+		oldLimit = &oldProject.Spec.NamespaceDefaultResourceQuota.Limit
+	}
+	if err := check(limit, oldLimit, projectSpecFieldPath.Child(namespaceQuotaField, "limit")); err != nil {
+		return nil, nil, err
+	}
+
+	var errList field.ErrorList
+	var warnings []string
+	for _, fieldErr := range fieldErrs {
+		if fieldErr, warning := rules.EnforceErrorWith(quotaTierRule, rules.OverridesOf(cluster.Annotations), fieldErr); fieldErr != nil {
+			errList = append(errList, fieldErr)
+		} else if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return errList, warnings, nil
+}
+
+// tierPolicies returns the cluster tier policies of the tier.
+func (a *admitter) tierPolicies(tier string) ([]*webhookv1.ClusterTierPolicy, error) {
+	policies, err := a.tierPolicyCache.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster tier policies: %w", err)
+	}
+	var tierPolicies []*webhookv1.ClusterTierPolicy
+	for _, policy := range policies {
+		if policy.Spec.Tier == tier {
+			tierPolicies = append(tierPolicies, policy)
+		}
+	}
+	return tierPolicies, nil
+}
+
+// quotaResources returns the sorted names of the resources set by the quota limit, none if it's nil.
+func quotaResources(limit *v3.ResourceQuotaLimit) ([]string, error) {
+	if limit == nil {
+		return nil, nil
+	}
+	limitMap, err := convert.EncodeToMap(limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode quota limit: %w", err)
+	}
+	resources := make([]string, 0, len(limitMap))
+	for resource := range limitMap {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources, nil
+}
+
+// allowedByTierPolicies returns true if one of the policies allows the quota resource.
+func allowedByTierPolicies(policies []*webhookv1.ClusterTierPolicy, resource string) bool {
+	for _, policy := range policies {
+		if policy.Spec.AllowsQuotaResource(resource) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateQuotaTier(t *testing.T) {
+	t.Parallel()
+	policies := []*webhookv1.ClusterTierPolicy{
+		{Spec: webhookv1.ClusterTierPolicySpec{Tier: "free", AllowedQuotaResources: []string{"limitsCpu", "limitsMemory"}}},
+		{Spec: webhookv1.ClusterTierPolicySpec{Tier: "free", AllowedQuotaResources: []string{"pods"}}},
+		{Spec: webhookv1.ClusterTierPolicySpec{Tier: "paid", AllowedQuotaResources: []string{"limitsCpu", "servicesLoadBalancers"}}},
+	}
+	project := func(limit v3.ResourceQuotaLimit) *v3.Project {
+		return &v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: "p-abc12", Namespace: "c-abc12"},
+			Spec: v3.ProjectSpec{
+				ClusterName:                   "c-abc12",
+				ResourceQuota:                 &v3.ProjectResourceQuota{Limit: limit},
+				NamespaceDefaultResourceQuota: &v3.NamespaceResourceQuota{Limit: limit},
+			},
+		}
+	}
+	tests := []struct {
+		name         string
+		tier         string
+		oldProject   *v3.Project
+		newProject   *v3.Project
+		wantMessages []string
+	}{
+		{
+			name:       "allowed resources",
+			tier:       "free",
+			newProject: project(v3.ResourceQuotaLimit{LimitsCPU: "4", Pods: "10"}),
+		},
+		{
+			name:       "resource outside the tier",
+			tier:       "free",
+			newProject: project(v3.ResourceQuotaLimit{LimitsCPU: "4", ServicesLoadBalancers: "1", ConfigMaps: "10"}),
+			wantMessages: []string{
+				"project.spec.resourceQuota.limit: Forbidden: resources configMaps,servicesLoadBalancers aren't allowed on clusters of tier free",
+				"project.spec.namespaceDefaultResourceQuota.limit: Forbidden: resources configMaps,servicesLoadBalancers aren't allowed on clusters of tier free",
+			},
+		},
+		{
+			name:       "resource allowed by the cluster's tier",
+			tier:       "paid",
+			newProject: project(v3.ResourceQuotaLimit{ServicesLoadBalancers: "1"}),
+		},
+		{
+			name:       "cluster without tier",
+			newProject: project(v3.ResourceQuotaLimit{ServicesLoadBalancers: "1"}),
+		},
+		{
+			name:       "tier without policies",
+			tier:       "enterprise",
+			newProject: project(v3.ResourceQuotaLimit{ServicesLoadBalancers: "1"}),
+		},
+		{
+			name:       "project without quotas",
+			tier:       "free",
+			newProject: &v3.Project{Spec: v3.ProjectSpec{ClusterName: "c-abc12"}},
+		},
+		{
+			name:       "resource kept on update",
+			tier:       "free",
+			oldProject: project(v3.ResourceQuotaLimit{ServicesLoadBalancers: "1"}),
+			newProject: project(v3.ResourceQuotaLimit{ServicesLoadBalancers: "2", LimitsCPU: "4"}),
+		},
+		{
+			name:       "resource added on update",
+			tier:       "free",
+			oldProject: project(v3.ResourceQuotaLimit{LimitsCPU: "4"}),
+			newProject: project(v3.ResourceQuotaLimit{LimitsCPU: "4", Secrets: "10"}),
+			wantMessages: []string{
+				"project.spec.resourceQuota.limit: Forbidden: resources secrets aren't allowed",
+				"project.spec.namespaceDefaultResourceQuota.limit: Forbidden: resources secrets aren't allowed",
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			cluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-abc12"}}
+			if test.tier != "" {
+				cluster.Labels = map[string]string{webhookv1.ClusterTierLabel: test.tier}
+			}
+			clusterCache.EXPECT().Get("c-abc12").Return(cluster, nil).AnyTimes()
+			tierPolicyCache := fake.NewMockNonNamespacedCacheInterface[*webhookv1.ClusterTierPolicy](ctrl)
+			tierPolicyCache.EXPECT().List(gomock.Any()).Return(policies, nil).AnyTimes()
+			a := &admitter{clusterCache: clusterCache, tierPolicyCache: tierPolicyCache}
+
+			oldProject := test.oldProject
+			if oldProject == nil {
+				oldProject = &v3.Project{}
+			}
+			errList, warnings, err := a.validateQuotaTier(oldProject, test.newProject)
+			require.NoError(t, err)
+			assert.Empty(t, warnings)
+			if assert.Len(t, errList, len(test.wantMessages), "%v", errList) {
+				for i, wantMessage := range test.wantMessages {
+					assert.Contains(t, errList[i].Error(), wantMessage)
+					assert.Contains(t, errList[i].Error(), "/rules/project-quota-tier")
+				}
+			}
+		})
+	}
+}
+
+func TestValidateQuotaTierWithoutPolicies(t *testing.T) {
+	t.Parallel()
+	a := &admitter{}
+	errList, warnings, err := a.validateQuotaTier(&v3.Project{}, projectWithQuota("p-abc12", "4", ""))
+	require.NoError(t, err)
+	assert.Empty(t, errList)
+	assert.Empty(t, warnings)
+}
@@ -0,0 +1,72 @@
+package project
+
+import (
+	"os"
+	"reflect"
+	"slices"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// usedLimitWritersEnv lists the usernames of the service accounts, besides Rancher's, allowed to change the used
+// limit of project quotas, e.g. system:serviceaccount:cattle-system:quota-sync.
+const usedLimitWritersEnv = "CATTLE_PROJECT_USED_LIMIT_WRITERS"
+
+// rancherServiceAccount is the user Rancher's controllers make requests as. They keep the used limit of project
+// quotas up to date.
+var rancherServiceAccount = serviceaccount.MakeUsername("cattle-system", "rancher")
+
+var usedLimitRule = rules.Register(rules.Rule{
+	ID:            "project-used-limit",
+	GVR:           gvr,
+	Description:   "Only Rancher, the service accounts listed in CATTLE_PROJECT_USED_LIMIT_WRITERS and members of system:masters can change the used limit of a project's resource quota on update. The used limit is computed by Rancher from the quotas of the project's namespaces.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: "project.spec.resourceQuota.usedLimit: Forbidden: the used limit is managed by Rancher and can't be changed by user u-abc123",
+	DenialCode:    string(admission.DenialBadRequest),
+})
+
+// usedLimitWritersFromEnv returns the users allowed to change the used limit of project quotas. Entries that aren't
+// service account usernames are ignored.
+func usedLimitWritersFromEnv() []string {
+	writers := []string{rancherServiceAccount}
+	for _, username := range common.SplitList(os.Getenv(usedLimitWritersEnv)) {
+		if _, _, err := serviceaccount.SplitUsername(username); err != nil {
+			logrus.Warnf("[project-validation] ignoring %s entry %q: %v", usedLimitWritersEnv, username, err)
+			continue
+		}
+		writers = append(writers, username)
+	}
+	return writers
+}
+
+// validateUsedLimitWriter checks that the used limit of the project quota is only changed by the writers, or by members
+// of system:masters, who bypass authorization. Only updates are checked: the used limit of created projects is kept,
+// so that restored and re-applied projects can be created by anyone allowed to create them. It returns the error and
+// warning of the current stage of the rule.
+func (a *admitter) validateUsedLimitWriter(request *admission.Request, oldProject, newProject *v3.Project) (*field.Error, string) {
+	if request.Operation != admissionv1.Update || reflect.DeepEqual(usedLimit(oldProject), usedLimit(newProject)) {
+		return nil, ""
+	}
+	if slices.Contains(a.usedLimitWriters, request.UserInfo.Username) || slices.Contains(request.UserInfo.Groups, user.SystemPrivilegedGroup) {
+		return nil, ""
+	}
+	fieldErr := field.Forbidden(projectSpecFieldPath.Child(projectQuotaField, "usedLimit"),
+		usedLimitRule.Message("the used limit is managed by Rancher and can't be changed by user %s", request.UserInfo.Username))
+	return rules.EnforceErrorWith(usedLimitRule, a.ruleOverrides(newProject), fieldErr)
+}
+
+// usedLimit returns the used limit of the project quota, empty if the project or its quota is nil.
+func usedLimit(project *v3.Project) v3.ResourceQuotaLimit {
+	if project == nil || project.Spec.ResourceQuota == nil {
+		return v3.ResourceQuotaLimit{}
+	}
+	return project.Spec.ResourceQuota.UsedLimit
+}
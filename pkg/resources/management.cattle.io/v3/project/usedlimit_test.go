@@ -0,0 +1,135 @@
+package project
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateUsedLimitWriter(t *testing.T) {
+	t.Parallel()
+	const quotaSync = "system:serviceaccount:cattle-system:quota-sync"
+	projectWithUsedLimit := func(configMaps string) *v3.Project {
+		return &v3.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "testcluster"},
+			Spec: v3.ProjectSpec{
+				ClusterName: "testcluster",
+				ResourceQuota: &v3.ProjectResourceQuota{
+					Limit:     v3.ResourceQuotaLimit{ConfigMaps: "100"},
+					UsedLimit: v3.ResourceQuotaLimit{ConfigMaps: configMaps},
+				},
+				NamespaceDefaultResourceQuota: &v3.NamespaceResourceQuota{
+					Limit: v3.ResourceQuotaLimit{ConfigMaps: "10"},
+				},
+			},
+		}
+	}
+	withoutQuota := projectWithUsedLimit("")
+	withoutQuota.Spec.ResourceQuota = nil
+	withoutQuota.Spec.NamespaceDefaultResourceQuota = nil
+
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		username    string
+		groups      []string
+		oldProject  *v3.Project
+		newProject  *v3.Project
+		wantAllowed bool
+	}{
+		{
+			name:        "user updating a project without changing the used limit",
+			operation:   admissionv1.Update,
+			username:    "u-abc123",
+			oldProject:  projectWithUsedLimit("20"),
+			newProject:  projectWithUsedLimit("20"),
+			wantAllowed: true,
+		},
+		{
+			name:       "user changing the used limit",
+			operation:  admissionv1.Update,
+			username:   "u-abc123",
+			oldProject: projectWithUsedLimit("20"),
+			newProject: projectWithUsedLimit("10"),
+		},
+		{
+			name:       "user adding a quota with a used limit",
+			operation:  admissionv1.Update,
+			username:   "u-abc123",
+			oldProject: withoutQuota,
+			newProject: projectWithUsedLimit("10"),
+		},
+		{
+			name:        "user removing the quota",
+			operation:   admissionv1.Update,
+			username:    "u-abc123",
+			oldProject:  projectWithUsedLimit(""),
+			newProject:  withoutQuota,
+			wantAllowed: true,
+		},
+		{
+			name:        "rancher changing the used limit",
+			operation:   admissionv1.Update,
+			username:    rancherServiceAccount,
+			oldProject:  projectWithUsedLimit("10"),
+			newProject:  projectWithUsedLimit("20"),
+			wantAllowed: true,
+		},
+		{
+			name:        "listed service account changing the used limit",
+			operation:   admissionv1.Update,
+			username:    quotaSync,
+			oldProject:  projectWithUsedLimit("10"),
+			newProject:  projectWithUsedLimit("20"),
+			wantAllowed: true,
+		},
+		{
+			name:        "cluster admin changing the used limit",
+			operation:   admissionv1.Update,
+			username:    "system:admin",
+			groups:      []string{"system:masters", "system:authenticated"},
+			oldProject:  projectWithUsedLimit("10"),
+			newProject:  projectWithUsedLimit("20"),
+			wantAllowed: true,
+		},
+		{
+			name:        "user creating a project with a used limit",
+			operation:   admissionv1.Create,
+			username:    "u-abc123",
+			newProject:  projectWithUsedLimit("10"),
+			wantAllowed: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := createProjectRequest(test.oldProject, test.newProject, test.operation, false)
+			require.NoError(t, err)
+			req.UserInfo.Username = test.username
+			req.UserInfo.Groups = test.groups
+			a := admitter{usedLimitWriters: []string{rancherServiceAccount, quotaSync}}
+			fieldErr, warning := a.validateUsedLimitWriter(req, test.oldProject, test.newProject)
+			assert.Empty(t, warning)
+			if test.wantAllowed {
+				assert.Nil(t, fieldErr)
+				return
+			}
+			require.NotNil(t, fieldErr)
+			assert.Contains(t, fieldErr.Error(), "project.spec.resourceQuota.usedLimit: Forbidden: the used limit is managed by Rancher")
+		})
+	}
+}
+
+func TestUsedLimitWritersFromEnv(t *testing.T) {
+	t.Setenv(usedLimitWritersEnv, "system:serviceaccount:cattle-system:quota-sync, u-abc123,,system:serviceaccount:kube-system:quota-audit")
+	assert.Equal(t, []string{
+		rancherServiceAccount,
+		"system:serviceaccount:cattle-system:quota-sync",
+		"system:serviceaccount:kube-system:quota-audit",
+	}, usedLimitWritersFromEnv())
+}
@@ -9,9 +9,15 @@ import (
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
 	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	webhookcontrollers "github.com/rancher/webhook/pkg/generated/controllers/webhook.cattle.io/v1"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/identity"
+	"github.com/rancher/webhook/pkg/quota"
 	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/rules"
 	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	v1 "k8s.io/api/core/v1"
@@ -19,6 +25,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/utils/trace"
 )
 
@@ -30,7 +37,17 @@ const (
 	containerLimitField = "containerDefaultResourceLimit"
 )
 
-var projectSpecFieldPath = field.NewPath("project").Child("spec")
+var (
+	projectSpecFieldPath        = field.NewPath("project").Child("spec")
+	projectAnnotationsFieldPath = field.NewPath("project").Child("metadata", "annotations")
+)
+
+func init() {
+	// the quota annotations are set by users and validated by this validator, or ignored by it and the mutator when invalid
+	common.RegisterReservedMetadataKeys(ClusterQuotaCapacityAnnotation, NamespaceDefaultQuotaAnnotation, QuotaDecreaseApprovedByAnnotation, quota.MaxNamespacesAnnotation, QuotaRevisionAnnotation)
+	// the creator role bindings are set by the mutator on behalf of the creator
+	common.RegisterReservedMetadataKeys(roleTemplatesRequired)
+}
 
 // Validator implements admission.ValidatingAdmissionWebhook.
 type Validator struct {
@@ -38,11 +55,23 @@ type Validator struct {
 }
 
 // NewValidator returns a project validator.
-func NewValidator(clusterCache controllerv3.ClusterCache, userCache controllerv3.UserCache) *Validator {
+func NewValidator(clusterCache controllerv3.ClusterCache, projectCache controllerv3.ProjectCache, identities *identity.Resolver, sar authorizationv1.SubjectAccessReviewInterface, grbCache controllerv3.GlobalRoleBindingCache, tierPolicyCache webhookcontrollers.ClusterTierPolicyCache, settingCache controllerv3.SettingCache,
+	namespaceCache corecontrollers.NamespaceCache, resourceQuotaCache generic.CacheInterface[*v1.ResourceQuota]) *Validator {
+	if projectCache != nil {
+		projectCache.AddIndexer(projectsByClusterIndex, projectsByCluster)
+	}
 	return &Validator{
 		admitter: admitter{
-			clusterCache: clusterCache,
-			userCache:    userCache,
+			clusterCache:          clusterCache,
+			projectCache:          projectCache,
+			identities:            identities,
+			ownership:             common.NewOwnershipTransferer(gvr, identities, sar),
+			usedLimitWriters:      usedLimitWritersFromEnv(),
+			quotaDecreaseApproval: quotaDecreaseApprovalFromEnv(grbCache),
+			tierPolicyCache:       tierPolicyCache,
+			settingCache:          settingCache,
+			liveQuotaUsage:        liveQuotaUsageFromEnv(namespaceCache, resourceQuotaCache),
+			quotaPairs:            quotaPairsFromEnv(),
 		},
 	}
 }
@@ -74,7 +103,22 @@ func (v *Validator) Admitters() []admission.Admitter {
 
 type admitter struct {
 	clusterCache controllerv3.ClusterCache
-	userCache    controllerv3.UserCache
+	projectCache controllerv3.ProjectCache
+	identities   *identity.Resolver
+	// ownership verifies the ownership transfers of projects.
+	ownership *common.OwnershipTransferer
+	// usedLimitWriters are the users allowed to change the used limit of project quotas.
+	usedLimitWriters []string
+	// quotaDecreaseApproval configures the approval of quota decreases close to the used limit.
+	quotaDecreaseApproval quotaDecreaseApproval
+	// tierPolicyCache holds the policies restricting the quota resources of the projects of cluster tiers.
+	tierPolicyCache webhookcontrollers.ClusterTierPolicyCache
+	// settingCache holds the setting limiting the number of projects per cluster.
+	settingCache controllerv3.SettingCache
+	// liveQuotaUsage configures checking quota decreases against the usage of the project's namespaces.
+	liveQuotaUsage liveQuotaUsage
+	// quotaPairs are the pairs of quota resources recommended to be set together.
+	quotaPairs []quota.RecommendedPair
 }
 
 // Admit handles the webhook admission request sent to this webhook.
@@ -87,16 +131,111 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		return nil, fmt.Errorf("failed to get old and new projects from request: %w", err)
 	}
 
+	var warnings []string
+	if request.Operation == admissionv1.Create || request.Operation == admissionv1.Update {
+		if fieldErr := validateMaxNamespaces(newProject); fieldErr != nil {
+			return admission.ResponseBadRequest(fieldErr.Error()), nil
+		}
+		fieldErr, warning := a.validateUsedLimitWriter(request, oldProject, newProject)
+		if fieldErr != nil {
+			return admission.ResponseBadRequest(fieldErr.Error()), nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		fieldErr, warning, err := a.validateClusterQuota(request, oldProject, newProject)
+		if err != nil {
+			return nil, fmt.Errorf("error checking cluster quota capacity: %w", err)
+		}
+		if fieldErr != nil {
+			return admission.ResponseDenied(admission.DenialConflict, fieldErr.Error()), nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		errList, tierWarnings, err := a.validateQuotaTier(oldProject, newProject)
+		if err != nil {
+			return nil, fmt.Errorf("error checking quota resources of the cluster tier: %w", err)
+		}
+		if len(errList) != 0 {
+			return admission.ResponseDeniedErrors(admission.DenialForbidden, errList), nil
+		}
+		warnings = append(warnings, tierWarnings...)
+	}
+	if request.Operation == admissionv1.Create {
+		fieldErr, warning, err := a.validateProjectLimit(newProject)
+		if err != nil {
+			return nil, fmt.Errorf("error checking project limit of the cluster: %w", err)
+		}
+		if fieldErr != nil {
+			return admission.ResponseDenied(admission.DenialConflict, fieldErr.Error()), nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	var auditAnnotations map[string]string
+	if request.Operation == admissionv1.Update {
+		fieldErr, warning := a.validateQuotaRevision(oldProject, newProject)
+		if fieldErr != nil {
+			return admission.ResponseDenied(admission.DenialConflict, fieldErr.Error()), nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		fieldErr, warning, err := a.validateLiveQuotaUsage(oldProject, newProject)
+		if err != nil {
+			return nil, fmt.Errorf("error checking quota usage of the namespaces: %w", err)
+		}
+		if fieldErr != nil {
+			return admission.ResponseDenied(admission.DenialConflict, fieldErr.Error()), nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		fieldErr, warning, annotations, err := a.validateQuotaDecrease(request, oldProject, newProject)
+		if err != nil {
+			return nil, fmt.Errorf("error checking quota decrease approval: %w", err)
+		}
+		if fieldErr != nil {
+			return admission.ResponseBadRequest(fieldErr.Error()), nil
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		auditAnnotations = annotations
+	}
+
+	var response *admissionv1.AdmissionResponse
 	switch request.Operation {
 	case admissionv1.Create:
-		return a.admitCreate(newProject)
+		response, err = a.admitCreate(request, newProject)
 	case admissionv1.Update:
-		return a.admitUpdate(oldProject, newProject)
+		response, err = a.admitUpdate(request, oldProject, newProject)
 	case admissionv1.Delete:
 		return a.admitDelete(oldProject)
 	default:
 		return nil, admission.ErrUnsupportedOperation
 	}
+	if err != nil {
+		return nil, err
+	}
+	if response.Allowed {
+		// the recommended resources are only checked for quotas that are otherwise valid
+		errList, pairWarnings, err := a.validateQuotaPairs(oldProject, newProject)
+		if err != nil {
+			return nil, fmt.Errorf("error checking recommended quota resources: %w", err)
+		}
+		if len(errList) != 0 {
+			return admission.ResponseDeniedErrors(admission.DenialInvalid, errList), nil
+		}
+		warnings = append(warnings, pairWarnings...)
+	}
+	response.Warnings = append(response.Warnings, warnings...)
+	if response.Allowed {
+		response.AuditAnnotations = auditAnnotations
+	}
+	return response, nil
 }
 
 func (a *admitter) admitDelete(project *v3.Project) (*admissionv1.AdmissionResponse, error) {
@@ -106,7 +245,7 @@ func (a *admitter) admitDelete(project *v3.Project) (*admissionv1.AdmissionRespo
 	return admission.ResponseAllowed(), nil
 }
 
-func (a *admitter) admitCreate(project *v3.Project) (*admissionv1.AdmissionResponse, error) {
+func (a *admitter) admitCreate(request *admission.Request, project *v3.Project) (*admissionv1.AdmissionResponse, error) {
 	fieldErr, err := a.checkClusterExists(project)
 	if err != nil {
 		return nil, fmt.Errorf("error checking cluster name: %w", err)
@@ -114,10 +253,16 @@ func (a *admitter) admitCreate(project *v3.Project) (*admissionv1.AdmissionRespo
 	if fieldErr != nil {
 		return admission.ResponseBadRequest(fieldErr.Error()), nil
 	}
-	if fieldErr := common.CheckCreatorIDAndNoCreatorRBAC(project); fieldErr != nil {
+	if errList := common.CheckAnnotationConflicts(project, common.ProjectAnnotationConflicts); len(errList) != 0 {
+		return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+	}
+	if errList := common.CheckAnnotationMigrations(project, common.ProjectAnnotationMigrations); len(errList) != 0 {
+		return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+	}
+	if fieldErr := common.CheckOriginalCreatorAnnotationsOnCreate(project); fieldErr != nil {
 		return admission.ResponseBadRequest(fieldErr.Error()), nil
 	}
-	fieldErr, err = common.CheckCreatorPrincipalName(a.userCache, project)
+	fieldErr, err = common.CheckCreatorPrincipalName(request.Context, a.identities, project)
 	if err != nil {
 		return nil, fmt.Errorf("error checking creator principal: %w", err)
 	}
@@ -128,16 +273,24 @@ func (a *admitter) admitCreate(project *v3.Project) (*admissionv1.AdmissionRespo
 	return a.admitCommonCreateUpdate(nil, project)
 }
 
-func (a *admitter) admitUpdate(oldProject, newProject *v3.Project) (*admissionv1.AdmissionResponse, error) {
+func (a *admitter) admitUpdate(request *admission.Request, oldProject, newProject *v3.Project) (*admissionv1.AdmissionResponse, error) {
 	if oldProject.Spec.ClusterName != newProject.Spec.ClusterName {
 		fieldErr := field.Invalid(projectSpecFieldPath.Child(clusterNameField), newProject.Spec.ClusterName, "field is immutable")
 		return admission.ResponseBadRequest(fieldErr.Error()), nil
 	}
 
-	if fieldErr := common.CheckCreatorAnnotationsOnUpdate(oldProject, newProject); fieldErr != nil {
+	fieldErr, err := common.CheckCreatorAnnotationsOnUpdate(request, a.ownership, oldProject, newProject)
+	if err != nil {
+		return nil, fmt.Errorf("error checking creator annotations: %w", err)
+	}
+	if fieldErr != nil {
 		return admission.ResponseBadRequest(fieldErr.Error()), nil
 	}
 
+	if errList := common.CheckAnnotationMigrations(newProject, common.ProjectAnnotationMigrations); len(errList) != 0 {
+		return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+	}
+
 	return a.admitCommonCreateUpdate(oldProject, newProject)
 
 }
@@ -159,6 +312,13 @@ func (a *admitter) admitCommonCreateUpdate(oldProject, newProject *v3.Project) (
 	if fieldErr != nil {
 		return admission.ResponseBadRequest(fieldErr.Error()), nil
 	}
+	fieldErr, warnings, err := checkZeroQuotas(&projectQuota.Limit, &nsQuota.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("error checking zero quotas: %w", err)
+	}
+	if fieldErr != nil {
+		return admission.ResponseBadRequest(fieldErr.Error()), nil
+	}
 	fieldErr, err = a.checkQuotaValues(&nsQuota.Limit, &projectQuota.Limit, oldProject)
 	if err != nil {
 		return nil, fmt.Errorf("error checking quota values: %w", err)
@@ -166,7 +326,9 @@ func (a *admitter) admitCommonCreateUpdate(oldProject, newProject *v3.Project) (
 	if fieldErr != nil {
 		return admission.ResponseBadRequest(fieldErr.Error()), nil
 	}
-	return admission.ResponseAllowed(), nil
+	response := admission.ResponseAllowed()
+	response.Warnings = warnings
+	return response, nil
 }
 
 // validateContainerDefaultResourceLimit checks all resource requests and limits.
@@ -225,6 +387,18 @@ func (a *admitter) checkClusterExists(project *v3.Project) (*field.Error, error)
 	return nil, nil
 }
 
+// ruleOverrides returns the rule overrides of the project's cluster, none if the cluster can't be found.
+func (a *admitter) ruleOverrides(project *v3.Project) rules.Overrides {
+	if a.clusterCache == nil {
+		return nil
+	}
+	cluster, err := a.clusterCache.Get(project.Spec.ClusterName)
+	if err != nil || cluster == nil {
+		return nil
+	}
+	return rules.OverridesOf(cluster.Annotations)
+}
+
 func checkQuotaFields(projectQuota *v3.ProjectResourceQuota, nsQuota *v3.NamespaceResourceQuota) (*field.Error, error) {
 	if projectQuota == nil && nsQuota != nil {
 		return field.Required(projectSpecFieldPath.Child(projectQuotaField), fmt.Sprintf("required when %s is set", namespaceQuotaField)), nil
@@ -252,6 +426,52 @@ func checkQuotaFields(projectQuota *v3.ProjectResourceQuota, nsQuota *v3.Namespa
 	return nil, nil
 }
 
+// checkZeroQuotas checks the resources with a zero quota, which are set, unlike the resources left out of the quotas,
+// and can't be used. A namespace default above a zero project quota can never be satisfied, so it's denied. Zero
+// namespace defaults only get a warning, since namespaces can still be given a quota of their own.
+func checkZeroQuotas(projectQuota, nsQuota *v3.ResourceQuotaLimit) (*field.Error, []string, error) {
+	projectQuotaResourceList, err := quota.LimitToResourceList(projectQuota)
+	if err != nil {
+		return nil, nil, err
+	}
+	nsQuotaResourceList, err := quota.LimitToResourceList(nsQuota)
+	if err != nil {
+		return nil, nil, err
+	}
+	var unsatisfiable, blocked, defaultZero []string
+	for name, projectLimit := range projectQuotaResourceList {
+		nsLimit, ok := nsQuotaResourceList[name]
+		if !ok {
+			continue
+		}
+		switch {
+		case projectLimit.IsZero() && nsLimit.Sign() > 0:
+			unsatisfiable = append(unsatisfiable, fmt.Sprintf("%s=%s", name, nsLimit.String()))
+		case projectLimit.IsZero() && nsLimit.IsZero():
+			blocked = append(blocked, string(name))
+		case nsLimit.IsZero():
+			defaultZero = append(defaultZero, string(name))
+		}
+	}
+	if len(unsatisfiable) != 0 {
+		sort.Strings(unsatisfiable)
+		return field.Forbidden(projectSpecFieldPath.Child(namespaceQuotaField), namespaceQuotaRule.Message(
+			"namespace default quota can never be satisfied for resources with a project quota of 0: %s", strings.Join(unsatisfiable, ","))), nil, nil
+	}
+	var warnings []string
+	if len(blocked) != 0 {
+		sort.Strings(blocked)
+		warnings = append(warnings, fmt.Sprintf("%s: the quota is 0 for %s, so no namespace of the project can use them; "+
+			"leave them out of the quotas for the project to use them without limits", projectSpecFieldPath.Child(projectQuotaField), strings.Join(blocked, ",")))
+	}
+	if len(defaultZero) != 0 {
+		sort.Strings(defaultZero)
+		warnings = append(warnings, fmt.Sprintf("%s: the default quota is 0 for %s, so namespaces of the project can't use them unless their quota is overridden",
+			projectSpecFieldPath.Child(namespaceQuotaField), strings.Join(defaultZero, ",")))
+	}
+	return nil, warnings, nil
+}
+
 func (a *admitter) checkQuotaValues(nsQuota, projectQuota *v3.ResourceQuotaLimit, oldProject *v3.Project) (*field.Error, error) {
 	// check quota on new project
 	fieldErr, err := namespaceQuotaFits(nsQuota, projectQuota)
@@ -268,34 +488,53 @@ func (a *admitter) checkQuotaValues(nsQuota, projectQuota *v3.ResourceQuotaLimit
 	return usedQuotaFits(&oldProject.Spec.ResourceQuota.UsedLimit, projectQuota)
 }
 
+var (
+	namespaceQuotaRule = rules.Register(rules.Rule{
+		ID:            "project-namespace-default-quota",
+		GVR:           gvr,
+		Description:   "The namespace default resource quota of a project must not exceed the project's resource quota for any resource, and must not be negative.",
+		Severity:      rules.SeverityDeny,
+		ExampleDenial: "spec.namespaceDefaultResourceQuota: Forbidden: namespace default quota limit exceeds project limit on fields: configMaps=100",
+		DenialCode:    string(admission.DenialBadRequest),
+	})
+	usedQuotaRule = rules.Register(rules.Rule{
+		ID:            "project-used-quota",
+		GVR:           gvr,
+		Description:   "The resource quota of a project can't be lowered below the quota already used by its namespaces.",
+		Severity:      rules.SeverityDeny,
+		ExampleDenial: "spec.resourceQuota: Forbidden: resourceQuota is below the used limit on fields: configMaps=20",
+		DenialCode:    string(admission.DenialBadRequest),
+	})
+)
+
 func namespaceQuotaFits(namespaceQuota, projectQuota *v3.ResourceQuotaLimit) (*field.Error, error) {
-	namespaceQuotaResourceList, err := convertLimitToResourceList(namespaceQuota)
+	namespaceQuotaResourceList, err := quota.LimitToResourceList(namespaceQuota)
 	if err != nil {
 		return nil, err
 	}
-	projectQuotaResourceList, err := convertLimitToResourceList(projectQuota)
+	projectQuotaResourceList, err := quota.LimitToResourceList(projectQuota)
 	if err != nil {
 		return nil, err
 	}
-	fits, exceeded := quotaFits(namespaceQuotaResourceList, projectQuotaResourceList)
+	fits, exceeded := quota.Fits(namespaceQuotaResourceList, projectQuotaResourceList)
 	if !fits {
-		return field.Forbidden(projectSpecFieldPath.Child(namespaceQuotaField), fmt.Sprintf("namespace default quota limit exceeds project limit on fields: %s", formatResourceList(exceeded))), nil
+		return field.Forbidden(projectSpecFieldPath.Child(namespaceQuotaField), namespaceQuotaRule.Message("namespace default quota limit exceeds project limit on fields: %s", formatResourceList(exceeded))), nil
 	}
 	return nil, nil
 }
 
 func usedQuotaFits(usedQuota, projectQuota *v3.ResourceQuotaLimit) (*field.Error, error) {
-	usedQuotaResourceList, err := convertLimitToResourceList(usedQuota)
+	usedQuotaResourceList, err := quota.LimitToResourceList(usedQuota)
 	if err != nil {
 		return nil, err
 	}
-	projectQuotaResourceList, err := convertLimitToResourceList(projectQuota)
+	projectQuotaResourceList, err := quota.LimitToResourceList(projectQuota)
 	if err != nil {
 		return nil, err
 	}
-	fits, exceeded := quotaFits(usedQuotaResourceList, projectQuotaResourceList)
+	fits, exceeded := quota.Fits(usedQuotaResourceList, projectQuotaResourceList)
 	if !fits {
-		return field.Forbidden(projectSpecFieldPath.Child(projectQuotaField), fmt.Sprintf("resourceQuota is below the used limit on fields: %s", formatResourceList(exceeded))), nil
+		return field.Forbidden(projectSpecFieldPath.Child(projectQuotaField), usedQuotaRule.Message("resourceQuota is below the used limit on fields: %s", formatResourceList(exceeded))), nil
 	}
 	return nil, nil
 }
@@ -9,9 +9,11 @@ import (
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/identity"
 	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -597,6 +599,31 @@ func TestProjectValidation(t *testing.T) {
 			},
 			wantAllowed: true,
 		},
+		{
+			name:      "create with a forged original creator annotations record",
+			operation: admissionv1.Create,
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "testcluster",
+					Annotations: map[string]string{
+						common.CreatorIDAnn:                  "u-12345",
+						common.OriginalCreatorAnnotationsAnn: `{"field.cattle.io/creatorId":"admin"}`,
+					},
+				},
+				Spec: v3.ProjectSpec{
+					ClusterName: "testcluster",
+				},
+			},
+			stateSetup: func(state *testState) {
+				state.clusterCache.EXPECT().Get("testcluster").Return(&v3.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "testcluster",
+					},
+				}, nil)
+			},
+			wantAllowed: false,
+		},
 		{
 			name:      "create with principal name annotation but no creator id",
 			operation: admissionv1.Create,
@@ -1333,7 +1360,7 @@ func TestProjectValidation(t *testing.T) {
 			}
 			req, err := createProjectRequest(test.oldProject, test.newProject, test.operation, false)
 			assert.NoError(t, err)
-			validator := NewValidator(state.clusterCache, state.userCache)
+			validator := NewValidator(state.clusterCache, nil, identity.NewResolver(state.userCache), nil, nil, nil, nil, nil, nil)
 			admitters := validator.Admitters()
 			assert.Len(t, admitters, 1)
 			response, err := admitters[0].Admit(req)
@@ -1536,7 +1563,7 @@ func TestProjectContainerDefaultLimitsValidation(t *testing.T) {
 				}
 				req, err := createProjectRequest(oldProject, newProject, test.operation, false)
 				assert.NoError(t, err)
-				validator := NewValidator(state.clusterCache, nil)
+				validator := NewValidator(state.clusterCache, nil, nil, nil, nil, nil, nil, nil, nil)
 				admitters := validator.Admitters()
 				assert.Len(t, admitters, 1)
 				response, err := admitters[0].Admit(req)
@@ -1547,6 +1574,64 @@ func TestProjectContainerDefaultLimitsValidation(t *testing.T) {
 	}
 }
 
+func TestCheckZeroQuotas(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		projectQuota v3.ResourceQuotaLimit
+		nsQuota      v3.ResourceQuotaLimit
+		wantErr      string
+		wantWarnings []string
+	}{
+		{
+			name:         "no zero quotas",
+			projectQuota: v3.ResourceQuotaLimit{Pods: "10", ConfigMaps: "20"},
+			nsQuota:      v3.ResourceQuotaLimit{Pods: "5", ConfigMaps: "10"},
+		},
+		{
+			name:         "zero project quota with a namespace default",
+			projectQuota: v3.ResourceQuotaLimit{Pods: "0", ConfigMaps: "0", Secrets: "10"},
+			nsQuota:      v3.ResourceQuotaLimit{Pods: "5", ConfigMaps: "1", Secrets: "10"},
+			wantErr:      "project.spec.namespaceDefaultResourceQuota: Forbidden: namespace default quota can never be satisfied for resources with a project quota of 0: configMaps=1,pods=5",
+		},
+		{
+			name:         "zero quotas",
+			projectQuota: v3.ResourceQuotaLimit{Pods: "0", LimitsCPU: "0m"},
+			nsQuota:      v3.ResourceQuotaLimit{Pods: "0", LimitsCPU: "0"},
+			wantWarnings: []string{"project.spec.resourceQuota: the quota is 0 for limitsCpu,pods, so no namespace of the project can use them; leave them out of the quotas for the project to use them without limits"},
+		},
+		{
+			name:         "zero namespace default",
+			projectQuota: v3.ResourceQuotaLimit{Pods: "10", ConfigMaps: "0"},
+			nsQuota:      v3.ResourceQuotaLimit{Pods: "0", ConfigMaps: "0"},
+			wantWarnings: []string{
+				"project.spec.resourceQuota: the quota is 0 for configMaps, so no namespace of the project can use them; leave them out of the quotas for the project to use them without limits",
+				"project.spec.namespaceDefaultResourceQuota: the default quota is 0 for pods, so namespaces of the project can't use them unless their quota is overridden",
+			},
+		},
+		{
+			name:         "unset quotas are left to the same keys check",
+			projectQuota: v3.ResourceQuotaLimit{Pods: "0"},
+			nsQuota:      v3.ResourceQuotaLimit{ConfigMaps: "0"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fieldErr, warnings, err := checkZeroQuotas(&test.projectQuota, &test.nsQuota)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantWarnings, warnings)
+			if test.wantErr == "" {
+				assert.Nil(t, fieldErr)
+				return
+			}
+			require.NotNil(t, fieldErr)
+			assert.Contains(t, fieldErr.Error(), test.wantErr)
+		})
+	}
+}
+
 func createProjectRequest(oldProject, newProject *v3.Project, operation admissionv1.Operation, dryRun bool) (*admission.Request, error) {
 	gvk := metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"}
 	gvr := metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"}
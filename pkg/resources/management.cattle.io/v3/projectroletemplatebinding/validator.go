@@ -4,7 +4,6 @@ package projectroletemplatebinding
 import (
 	"errors"
 	"fmt"
-	"strings"
 
 	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
@@ -102,7 +101,7 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 
 	if request.Operation == admissionv1.Create {
 		var fieldErr *field.Error
-		if err := a.validateCreateFields(prtb, fieldPath); err != nil {
+		if err := a.validateCreateFields(request, prtb, fieldPath); err != nil {
 			if errors.As(err, &fieldErr) {
 				return admission.ResponseBadRequest(err.Error()), nil
 			}
@@ -110,7 +109,7 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		}
 	}
 
-	roleTemplate, err := a.roleTemplateResolver.RoleTemplateCache().Get(prtb.RoleTemplateName)
+	roleTemplate, err := admission.Lookup(request, a.roleTemplateResolver.RoleTemplateCache(), prtb.RoleTemplateName)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return &admissionv1.AdmissionResponse{
@@ -125,26 +124,21 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		return nil, fmt.Errorf("failed to get rules from referenced roleTemplate '%s': %w", roleTemplate.Name, err)
 	}
 
-	clusterNS, projectNS := clusterAndProjectID(prtb.ProjectName)
-	err = auth.ConfirmNoEscalation(request, rules, clusterNS, a.clusterResolver)
+	owner, err := admission.OwnerFromRequest(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the owner of the PRTB: %w", err)
+	}
+	err = auth.ConfirmNoEscalation(request, rules, owner.Cluster, a.clusterResolver)
 	if err == nil {
 		return &admissionv1.AdmissionResponse{Allowed: true}, nil
 	}
 
 	response := &admissionv1.AdmissionResponse{}
-	auth.SetEscalationResponse(response, auth.ConfirmNoEscalation(request, rules, projectNS, a.projectResolver))
+	auth.SetEscalationResponse(response, auth.ConfirmNoEscalation(request, rules, owner.Project, a.projectResolver))
 
 	return response, nil
 }
 
-func clusterAndProjectID(projectName string) (string, string) {
-	pieces := strings.Split(projectName, ":")
-	if len(pieces) < 2 {
-		return "", ""
-	}
-	return pieces[0], pieces[1]
-}
-
 // validUpdateFields checks if the fields being changed are valid update fields.
 func validateUpdateFields(oldPRTB, newPRTB *apisv3.ProjectRoleTemplateBinding, fieldPath *field.Path) *field.Error {
 	const reason = "field is immutable"
@@ -172,7 +166,7 @@ func validateUpdateFields(oldPRTB, newPRTB *apisv3.ProjectRoleTemplateBinding, f
 }
 
 // validateCreateFields checks if all required fields are present and valid.
-func (a *admitter) validateCreateFields(newPRTB *apisv3.ProjectRoleTemplateBinding, fieldPath *field.Path) error {
+func (a *admitter) validateCreateFields(request *admission.Request, newPRTB *apisv3.ProjectRoleTemplateBinding, fieldPath *field.Path) error {
 	hasUserTarget := newPRTB.UserName != "" || newPRTB.UserPrincipalName != ""
 	hasGroupTarget := newPRTB.GroupName != "" || newPRTB.GroupPrincipalName != ""
 	hasServiceAccountTarget := newPRTB.ServiceAccount != ""
@@ -190,7 +184,7 @@ func (a *admitter) validateCreateFields(newPRTB *apisv3.ProjectRoleTemplateBindi
 		return field.Required(fieldPath.Child("roleTemplateName"), "")
 	}
 
-	roleTemplate, err := a.roleTemplateResolver.RoleTemplateCache().Get(newPRTB.RoleTemplateName)
+	roleTemplate, err := admission.Lookup(request, a.roleTemplateResolver.RoleTemplateCache(), newPRTB.RoleTemplateName)
 	if err != nil {
 		return err
 	}
@@ -206,14 +200,15 @@ func (a *admitter) validateCreateFields(newPRTB *apisv3.ProjectRoleTemplateBindi
 	if newPRTB.ProjectName == "" {
 		return field.Required(fieldPath.Child("projectName"), "projectName is required")
 	}
-	clusterName, projectName := clusterAndProjectID(newPRTB.ProjectName)
-	if clusterName == "" || projectName == "" {
+	owner, ok := admission.ParseProjectID(newPRTB.ProjectName)
+	clusterName, projectName := owner.Cluster, owner.Project
+	if !ok {
 		return field.Invalid(fieldPath.Child("projectName"), newPRTB.ProjectName, "projectName must be of the form cluster.metadata.name:project.metadata.name, and both must refer to an existing object")
 	}
 	if projectName != newPRTB.Namespace {
 		return field.Forbidden(fieldPath, "namespace and the projectName part of projectName must match")
 	}
-	cluster, err := a.clusterCache.Get(clusterName)
+	cluster, err := admission.Lookup(request, a.clusterCache, clusterName)
 	clusterNotFoundErr := field.Invalid(fieldPath.Child("projectName"), newPRTB.ProjectName, fmt.Sprintf("specified cluster %s not found", clusterName))
 	if err != nil {
 		if apierrors.IsNotFound(err) {
@@ -224,7 +219,7 @@ func (a *admitter) validateCreateFields(newPRTB *apisv3.ProjectRoleTemplateBindi
 	if cluster == nil {
 		return clusterNotFoundErr
 	}
-	project, err := a.projectCache.Get(clusterName, projectName)
+	project, err := admission.LookupNamespaced(request, a.projectCache, clusterName, projectName)
 	projectNotFoundErr := field.Invalid(fieldPath.Child("projectName"), newPRTB.ProjectName, fmt.Sprintf("specified project %s not found in %s", projectName, clusterName))
 	if err != nil {
 		if apierrors.IsNotFound(err) {
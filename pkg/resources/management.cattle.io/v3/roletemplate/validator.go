@@ -14,6 +14,7 @@ import (
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authzv1 "k8s.io/api/authorization/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -72,6 +73,20 @@ func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.Webho
 	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations())}
 }
 
+// PrefetchReviews returns the escalate review of the requester on role templates, which the admitter creates for
+// created and updated role templates unless the request is denied first, so that it's prefetched while the request is
+// queued.
+func (v *Validator) PrefetchReviews(request *admission.Request) []authzv1.ResourceAttributes {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return nil
+	}
+	_, newRT, err := objectsv3.RoleTemplateOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil || newRT.DeletionTimestamp != nil {
+		return nil
+	}
+	return []authzv1.ResourceAttributes{{Verb: escalateVerb, Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource}}
+}
+
 // Admitters returns the admitter objects used to validate RoleTemplates.
 func (v *Validator) Admitters() []admission.Admitter {
 	return []admission.Admitter{&v.admitter}
@@ -10,6 +10,8 @@ import (
 	"github.com/rancher/webhook/pkg/admission"
 	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/project"
+	"github.com/rancher/webhook/pkg/rules"
 	"github.com/robfig/cron"
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
@@ -139,6 +141,12 @@ func (a *admitter) admitCommonCreateUpdate(_, newSetting *v3.Setting) (*admissio
 		err = a.validateUserRetentionCron(newSetting)
 	case AuthUserSessionTTLMinutes:
 		err = a.validateAuthUserSessionTTLMinutes(newSetting)
+	case rules.EnforcementSetting:
+		err = a.validateRuleEnforcement(newSetting)
+	case admission.DeniedObjectLoggingSetting:
+		err = a.validateDeniedObjectLogging(newSetting)
+	case project.MaxProjectsPerClusterSetting:
+		err = a.validateMaxProjectsPerCluster(newSetting)
 	default:
 	}
 
@@ -280,6 +288,39 @@ func (a *admitter) validateUserRetentionCron(s *v3.Setting) error {
 	return nil
 }
 
+// validateRuleEnforcement validates the webhook-rule-enforcement setting
+// to make sure it holds valid enforcement schedules of the webhook rules.
+func (a *admitter) validateRuleEnforcement(s *v3.Setting) error {
+	value := effectiveValue(s)
+	if _, err := rules.ParseSchedules(value); err != nil {
+		return field.Invalid(valuePath, value, err.Error())
+	}
+
+	return nil
+}
+
+// validateDeniedObjectLogging validates the webhook-log-denied-objects setting
+// to make sure it selects resources in the resource.group form and has a valid size limit.
+func (a *admitter) validateDeniedObjectLogging(s *v3.Setting) error {
+	value := effectiveValue(s)
+	if _, err := admission.ParseDeniedObjectLogging(value); err != nil {
+		return field.Invalid(valuePath, value, err.Error())
+	}
+
+	return nil
+}
+
+// validateMaxProjectsPerCluster validates the webhook-max-projects-per-cluster setting
+// to make sure it's empty or a non-negative number of projects.
+func (a *admitter) validateMaxProjectsPerCluster(s *v3.Setting) error {
+	value := effectiveValue(s)
+	if _, err := project.ParseMaxProjectsPerCluster(value); err != nil {
+		return field.Invalid(valuePath, value, err.Error())
+	}
+
+	return nil
+}
+
 // validateUserLastLoginDefault validates the user-last-login-default setting
 // to make sure it's a valid RFC3339 formatted date time.
 func (a *admitter) validateUserLastLoginDefault(s *v3.Setting) error {
@@ -10,7 +10,9 @@ import (
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/project"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/setting"
+	"github.com/rancher/webhook/pkg/rules"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -295,6 +297,194 @@ func (s *SettingSuite) validateUserRetentionCron(op v1.Operation) {
 	}
 }
 
+func (s *SettingSuite) TestValidateRuleEnforcementOnUpdate() {
+	s.validateRuleEnforcement(v1.Update)
+}
+
+func (s *SettingSuite) TestValidateRuleEnforcementOnCreate() {
+	s.validateRuleEnforcement(v1.Create)
+}
+
+func (s *SettingSuite) validateRuleEnforcement(op v1.Operation) {
+	tests := []struct {
+		desc    string
+		value   string
+		allowed bool
+	}{
+		{
+			desc:    "no schedules",
+			value:   "",
+			allowed: true,
+		},
+		{
+			desc:    "valid schedules",
+			value:   `{"cluster-agent-image-tag": {"warn": "2025-03-01T00:00:00Z", "deny": "2025-06-01T00:00:00Z"}, "cluster-networking": {"warn": "2025-03-01T00:00:00Z"}}`,
+			allowed: true,
+		},
+		{
+			desc:  "deny before warn",
+			value: `{"cluster-agent-image-tag": {"warn": "2025-06-01T00:00:00Z", "deny": "2025-03-01T00:00:00Z"}}`,
+		},
+		{
+			desc:  "invalid date",
+			value: `{"cluster-agent-image-tag": {"warn": "2025-03-01"}}`,
+		},
+		{
+			desc:  "nonsensical value",
+			value: "foo",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		s.T().Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			validator := setting.NewValidator(nil, nil)
+			s.testAdmit(t, validator, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: rules.EnforcementSetting,
+				},
+			}, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: rules.EnforcementSetting,
+				},
+				Value: test.value,
+			}, op, test.allowed)
+		})
+	}
+}
+
+func (s *SettingSuite) TestValidateDeniedObjectLoggingOnUpdate() {
+	s.validateDeniedObjectLogging(v1.Update)
+}
+
+func (s *SettingSuite) TestValidateDeniedObjectLoggingOnCreate() {
+	s.validateDeniedObjectLogging(v1.Create)
+}
+
+func (s *SettingSuite) validateDeniedObjectLogging(op v1.Operation) {
+	tests := []struct {
+		desc    string
+		value   string
+		allowed bool
+	}{
+		{
+			desc:    "disabled",
+			value:   "",
+			allowed: true,
+		},
+		{
+			desc:    "resources with the default size",
+			value:   `{"resources": ["projects.management.cattle.io", "namespaces"]}`,
+			allowed: true,
+		},
+		{
+			desc:    "resources with a size",
+			value:   `{"resources": ["projects.management.cattle.io"], "maxBytes": 2048}`,
+			allowed: true,
+		},
+		{
+			desc:  "resource with a version",
+			value: `{"resources": ["management.cattle.io/v3/projects"]}`,
+		},
+		{
+			desc:  "size too large",
+			value: `{"resources": ["projects.management.cattle.io"], "maxBytes": 1048576}`,
+		},
+		{
+			desc:  "negative size",
+			value: `{"resources": ["projects.management.cattle.io"], "maxBytes": -1}`,
+		},
+		{
+			desc:  "nonsensical value",
+			value: "foo",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		s.T().Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			validator := setting.NewValidator(nil, nil)
+			s.testAdmit(t, validator, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: admission.DeniedObjectLoggingSetting,
+				},
+			}, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: admission.DeniedObjectLoggingSetting,
+				},
+				Value: test.value,
+			}, op, test.allowed)
+		})
+	}
+}
+
+func (s *SettingSuite) TestValidateMaxProjectsPerClusterOnUpdate() {
+	s.validateMaxProjectsPerCluster(v1.Update)
+}
+
+func (s *SettingSuite) TestValidateMaxProjectsPerClusterOnCreate() {
+	s.validateMaxProjectsPerCluster(v1.Create)
+}
+
+func (s *SettingSuite) validateMaxProjectsPerCluster(op v1.Operation) {
+	tests := []struct {
+		desc    string
+		value   string
+		allowed bool
+	}{
+		{
+			desc:    "disabled",
+			value:   "",
+			allowed: true,
+		},
+		{
+			desc:    "zero",
+			value:   "0",
+			allowed: true,
+		},
+		{
+			desc:    "limit",
+			value:   "50",
+			allowed: true,
+		},
+		{
+			desc:  "negative limit",
+			value: "-1",
+		},
+		{
+			desc:  "fractional limit",
+			value: "2.5",
+		},
+		{
+			desc:  "nonsensical value",
+			value: "foo",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		s.T().Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			validator := setting.NewValidator(nil, nil)
+			s.testAdmit(t, validator, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: project.MaxProjectsPerClusterSetting,
+				},
+			}, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: project.MaxProjectsPerClusterSetting,
+				},
+				Value: test.value,
+			}, op, test.allowed)
+		})
+	}
+}
+
 func (s *SettingSuite) TestValidateUserLastLoginDefaultOnUpdate() {
 	s.validateUserLastLoginDefault(v1.Update)
 }
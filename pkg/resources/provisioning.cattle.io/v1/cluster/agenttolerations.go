@@ -0,0 +1,93 @@
+package cluster
+
+import (
+	"fmt"
+	"slices"
+
+	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	k8sv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var agentTolerationsRule = rules.Register(rules.Rule{
+	ID:            "cluster-agent-tolerations",
+	GVR:           gvr,
+	Description:   "The tolerations appended to the cluster and fleet agents of a cluster must be allowed by a TaintPolicy, when any exists, so that agents can't be scheduled on quarantined nodes. On update, tolerations the agent already had aren't checked.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: `spec.clusterAgentDeploymentCustomization.appendTolerations[0]: Forbidden: toleration of quarantine=true:NoSchedule isn't allowed by any TaintPolicy`,
+})
+
+// validateAgentTolerations checks that the tolerations appended to the cluster and fleet agents are allowed by one of
+// the taint policies. Nothing is checked without policies. On update, the tolerations the agent already had aren't
+// checked, so that clusters can still be updated after a policy is tightened. Warnings are returned if the rule is at
+// the warn stage.
+func validateAgentTolerations(policies []*webhookv1.TaintPolicy, oldCluster, newCluster *v1.Cluster, op admissionv1.Operation) (field.ErrorList, []string) {
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	var fieldErrs field.ErrorList
+	check := func(customization, oldCustomization *v1.AgentDeploymentCustomization, path *field.Path) {
+		if customization == nil {
+			return
+		}
+		var oldTolerations []k8sv1.Toleration
+		if op == admissionv1.Update && oldCustomization != nil {
+			oldTolerations = oldCustomization.AppendTolerations
+		}
+		for i, toleration := range customization.AppendTolerations {
+			had := slices.ContainsFunc(oldTolerations, func(old k8sv1.Toleration) bool { return old.MatchToleration(&toleration) })
+			if had || allowedByPolicies(policies, toleration) {
+				continue
+			}
+			fieldErrs = append(fieldErrs, field.Forbidden(path.Child("appendTolerations").Index(i),
+				fmt.Sprintf("toleration of %s isn't allowed by any TaintPolicy", describeToleration(toleration))))
+		}
+	}
+	check(newCluster.Spec.ClusterAgentDeploymentCustomization, oldCluster.Spec.ClusterAgentDeploymentCustomization,
+		field.NewPath("spec", "clusterAgentDeploymentCustomization"))
+	check(newCluster.Spec.FleetAgentDeploymentCustomization, oldCluster.Spec.FleetAgentDeploymentCustomization,
+		field.NewPath("spec", "fleetAgentDeploymentCustomization"))
+
+	var errList field.ErrorList
+	var warnings []string
+	for _, fieldErr := range fieldErrs {
+		fieldErr.Detail = agentTolerationsRule.Message("%s", fieldErr.Detail)
+		if fieldErr, warning := rules.EnforceError(agentTolerationsRule, fieldErr); fieldErr != nil {
+			errList = append(errList, fieldErr)
+		} else if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return errList, warnings
+}
+
+// allowedByPolicies returns true if one of the policies allows the toleration.
+func allowedByPolicies(policies []*webhookv1.TaintPolicy, toleration k8sv1.Toleration) bool {
+	for _, policy := range policies {
+		if policy.Spec.Allows(toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeToleration formats the taints tolerated by the toleration like kubectl formats taints, e.g. key=value:effect,
+// with * for any key, value or effect.
+func describeToleration(toleration k8sv1.Toleration) string {
+	key, value, effect := toleration.Key, "="+toleration.Value, string(toleration.Effect)
+	if key == "" {
+		key = "*"
+	}
+	if toleration.Operator == k8sv1.TolerationOpExists {
+		value = "=*"
+	} else if toleration.Value == "" {
+		value = ""
+	}
+	if effect == "" {
+		effect = "*"
+	}
+	return key + value + ":" + effect
+}
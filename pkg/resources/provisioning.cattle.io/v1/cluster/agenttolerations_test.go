@@ -0,0 +1,118 @@
+package cluster
+
+import (
+	"testing"
+
+	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateAgentTolerations(t *testing.T) {
+	t.Parallel()
+	policies := []*webhookv1.TaintPolicy{
+		{Spec: webhookv1.TaintPolicySpec{AllowedTolerations: []corev1.Toleration{
+			{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "agents", Effect: corev1.TaintEffectNoSchedule},
+		}}},
+		{Spec: webhookv1.TaintPolicySpec{AllowedTolerations: []corev1.Toleration{
+			{Key: "node-role.kubernetes.io/control-plane", Operator: corev1.TolerationOpExists},
+		}}},
+	}
+	cluster := func(clusterAgent, fleetAgent []corev1.Toleration) *v1.Cluster {
+		cluster := &v1.Cluster{}
+		if clusterAgent != nil {
+			cluster.Spec.ClusterAgentDeploymentCustomization = &v1.AgentDeploymentCustomization{AppendTolerations: clusterAgent}
+		}
+		if fleetAgent != nil {
+			cluster.Spec.FleetAgentDeploymentCustomization = &v1.AgentDeploymentCustomization{AppendTolerations: fleetAgent}
+		}
+		return cluster
+	}
+	dedicated := corev1.Toleration{Key: "dedicated", Value: "agents", Effect: corev1.TaintEffectNoSchedule}
+	controlPlane := corev1.Toleration{Key: "node-role.kubernetes.io/control-plane", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoExecute}
+	quarantine := corev1.Toleration{Key: "quarantine", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule}
+
+	tests := []struct {
+		name       string
+		policies   []*webhookv1.TaintPolicy
+		op         admissionv1.Operation
+		oldCluster *v1.Cluster
+		newCluster *v1.Cluster
+		wantErrs   []string
+	}{
+		{
+			name:       "no policies",
+			op:         admissionv1.Create,
+			newCluster: cluster([]corev1.Toleration{quarantine}, nil),
+		},
+		{
+			name:       "allowed tolerations",
+			policies:   policies,
+			op:         admissionv1.Create,
+			newCluster: cluster([]corev1.Toleration{dedicated, controlPlane}, []corev1.Toleration{controlPlane}),
+		},
+		{
+			name:       "no customization",
+			policies:   policies,
+			op:         admissionv1.Create,
+			newCluster: cluster(nil, nil),
+		},
+		{
+			name:       "toleration outside the policies",
+			policies:   policies,
+			op:         admissionv1.Create,
+			newCluster: cluster([]corev1.Toleration{dedicated}, []corev1.Toleration{quarantine}),
+			wantErrs:   []string{"spec.fleetAgentDeploymentCustomization.appendTolerations[0]: Forbidden: toleration of quarantine=true:NoSchedule isn't allowed by any TaintPolicy"},
+		},
+		{
+			name:     "broader toleration than allowed",
+			policies: policies,
+			op:       admissionv1.Create,
+			newCluster: cluster([]corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+				{Key: "dedicated", Value: "agents"},
+				{Operator: corev1.TolerationOpExists},
+			}, nil),
+			wantErrs: []string{
+				"spec.clusterAgentDeploymentCustomization.appendTolerations[0]: Forbidden: toleration of dedicated=*:NoSchedule",
+				"spec.clusterAgentDeploymentCustomization.appendTolerations[1]: Forbidden: toleration of dedicated=agents:*",
+				"spec.clusterAgentDeploymentCustomization.appendTolerations[2]: Forbidden: toleration of *=*:*",
+			},
+		},
+		{
+			name:       "existing toleration kept on update",
+			policies:   policies,
+			op:         admissionv1.Update,
+			oldCluster: cluster([]corev1.Toleration{quarantine}, nil),
+			newCluster: cluster([]corev1.Toleration{quarantine, dedicated}, nil),
+		},
+		{
+			name:       "toleration moved to another agent on update",
+			policies:   policies,
+			op:         admissionv1.Update,
+			oldCluster: cluster([]corev1.Toleration{quarantine}, nil),
+			newCluster: cluster(nil, []corev1.Toleration{quarantine}),
+			wantErrs:   []string{"spec.fleetAgentDeploymentCustomization.appendTolerations[0]: Forbidden"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := test.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			errList, warnings := validateAgentTolerations(test.policies, oldCluster, test.newCluster, test.op)
+			assert.Empty(t, warnings)
+			if assert.Len(t, errList, len(test.wantErrs), "%v", errList) {
+				for i, wantErr := range test.wantErrs {
+					assert.Contains(t, errList[i].Error(), wantErr)
+					assert.Contains(t, errList[i].Error(), "/rules/cluster-agent-tolerations")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package cluster
+
+import (
+	"slices"
+
+	psa "github.com/rancher/webhook/pkg/podsecurityadmission"
+)
+
+// configArgCatalog lists the configuration arguments of a Kubernetes distro, the keys of its config file.
+type configArgCatalog struct {
+	// minMinor and maxMinor are the Kubernetes 1.x minor versions the catalog covers.
+	minMinor, maxMinor uint64
+	// args are the arguments of all the covered versions.
+	args []string
+	// added are the arguments added in a minor version.
+	added map[uint64][]string
+	// removed are the arguments removed in a minor version.
+	removed map[uint64][]string
+}
+
+// commonConfigArgs are the server and agent arguments shared by RKE2 and K3s.
+var commonConfigArgs = []string{
+	"advertise-address", "agent-token", "agent-token-file", "airgap-extra-registry", "alsologtostderr", "bind-address",
+	"cluster-cidr", "cluster-dns", "cluster-domain", "cluster-reset", "cluster-reset-restore-path", "config",
+	"container-runtime-endpoint", "data-dir", "datastore-cafile", "datastore-certfile", "datastore-endpoint",
+	"datastore-keyfile", "debug", "disable", "disable-apiserver", "disable-cloud-controller",
+	"disable-controller-manager", "disable-etcd", "disable-kube-proxy", "disable-scheduler", "egress-selector-mode",
+	"enable-pprof", "etcd-arg", "etcd-disable-snapshots", "etcd-expose-metrics", "etcd-s3", "etcd-s3-access-key",
+	"etcd-s3-bucket", "etcd-s3-endpoint", "etcd-s3-endpoint-ca", "etcd-s3-folder", "etcd-s3-insecure",
+	"etcd-s3-region", "etcd-s3-secret-key", "etcd-s3-skip-ssl-verify", "etcd-s3-timeout", "etcd-snapshot-compress",
+	"etcd-snapshot-dir", "etcd-snapshot-name", "etcd-snapshot-retention", "etcd-snapshot-schedule-cron",
+	"image-credential-provider-bin-dir", "image-credential-provider-config", "kube-apiserver-arg",
+	"kube-cloud-controller-manager-arg", "kube-controller-manager-arg", "kube-proxy-arg", "kube-scheduler-arg",
+	"kubelet-arg", "lb-server-port", "log", "node-external-ip", "node-ip", "node-label", "node-name", "node-taint",
+	"private-registry", "protect-kernel-defaults", "resolv-conf", "secrets-encryption", "selinux", "server",
+	"service-cidr", "service-node-port-range", "snapshotter", "tls-san", "token", "token-file", "v", "vmodule",
+	"with-node-id", "write-kubeconfig", "write-kubeconfig-mode",
+}
+
+// configArgCatalogs are the configuration argument catalogs of RKE2 and K3s.
+var configArgCatalogs = map[string]configArgCatalog{
+	runtimeRKE2: {
+		minMinor: 25,
+		maxMinor: 32,
+		args: append(slices.Clone(commonConfigArgs),
+			"audit-policy-file", "cloud-controller-manager-extra-env", "cloud-controller-manager-extra-mount",
+			"cloud-provider-config", "cloud-provider-name", "cni", "control-plane-probe-configuration",
+			"control-plane-resource-limits", "control-plane-resource-requests", "enable-servicelb", "etcd-extra-env",
+			"etcd-extra-mount", "etcd-image", "kube-apiserver-extra-env", "kube-apiserver-extra-mount",
+			"kube-apiserver-image", "kube-controller-manager-extra-env", "kube-controller-manager-extra-mount",
+			"kube-controller-manager-image", "kube-proxy-extra-env", "kube-proxy-extra-mount", "kube-proxy-image",
+			"kube-scheduler-extra-env", "kube-scheduler-extra-mount", "kube-scheduler-image", "kubelet-path",
+			"pause-image", "pod-security-admission-config-file", "profile", "runtime-image", "system-default-registry",
+		),
+		added: map[uint64][]string{
+			29: {"embedded-registry", "tls-san-security"},
+			30: {"ingress-controller"},
+			31: {"supervisor-metrics"},
+		},
+	},
+	runtimeK3S: {
+		minMinor: 25,
+		maxMinor: 32,
+		args: append(slices.Clone(commonConfigArgs),
+			"default-local-storage-path", "disable-helm-controller", "disable-network-policy", "docker",
+			"flannel-backend", "flannel-cni-conf", "flannel-conf", "flannel-external-ip", "flannel-iface",
+			"flannel-ipv6-masq", "helm-job-image", "https-listen-port", "pause-image", "prefer-bundled-bin", "rootless",
+			"servicelb-namespace",
+		),
+		added: map[uint64][]string{
+			26: {"multi-cluster-cidr"},
+			28: {"vpn-auth", "vpn-auth-file"},
+			29: {"embedded-registry", "tls-san-security"},
+			31: {"supervisor-metrics"},
+		},
+		removed: map[uint64][]string{
+			29: {"multi-cluster-cidr"},
+		},
+	},
+}
+
+// knownConfigArg returns whether the argument is known for the distro and Kubernetes version, and whether the
+// version is covered by a catalog at all. Arguments of uncovered versions are neither known nor unknown.
+func knownConfigArg(kubernetesVersion, arg string) (known, covered bool) {
+	catalog, ok := configArgCatalogs[getRuntime(kubernetesVersion)]
+	if !ok {
+		return false, false
+	}
+	version, err := psa.GetClusterVersion(kubernetesVersion)
+	if err != nil || version.Major != 1 || version.Minor < catalog.minMinor || version.Minor > catalog.maxMinor {
+		return false, false
+	}
+	known = slices.Contains(catalog.args, arg)
+	for minor := catalog.minMinor; minor <= version.Minor; minor++ {
+		if slices.Contains(catalog.added[minor], arg) {
+			known = true
+		}
+		if slices.Contains(catalog.removed[minor], arg) {
+			known = false
+		}
+	}
+	return known, true
+}
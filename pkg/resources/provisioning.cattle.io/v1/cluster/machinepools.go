@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"strings"
+
+	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var machinePoolsRule = rules.Register(rules.Rule{
+	ID:            "cluster-machine-pools",
+	GVR:           gvr,
+	Description:   "The machine pools of an RKE2 or K3s cluster must have unique names that are DNS-1123 labels, can't be renamed, since the machines of the old pool would be orphaned, and must together provide the etcd and control plane roles. On update, only new names are checked, and the roles are only checked if the cluster had them.",
+	Severity:      rules.SeverityDeny,
+	ExampleDenial: `spec.rkeConfig.machinePools[1].name: Invalid value: "pool1": machine pool names must be unique`,
+})
+
+// validateMachinePools validates the names and roles of the machine pools of an RKE2/K3s cluster. Warnings are
+// returned if the rule is at the warn stage.
+func validateMachinePools(oldCluster, newCluster *v1.Cluster, op admissionv1.Operation) (field.ErrorList, []string) {
+	if newCluster.Spec.RKEConfig == nil {
+		return nil, nil
+	}
+	pools := newCluster.Spec.RKEConfig.MachinePools
+	var oldPools []v1.RKEMachinePool
+	if op == admissionv1.Update && oldCluster.Spec.RKEConfig != nil {
+		oldPools = oldCluster.Spec.RKEConfig.MachinePools
+	}
+	oldNames := make(map[string]bool, len(oldPools))
+	oldNamesByConfig := make(map[string]string, len(oldPools))
+	for _, pool := range oldPools {
+		oldNames[pool.Name] = true
+		if key := machineConfigKey(pool); key != "" {
+			oldNamesByConfig[key] = pool.Name
+		}
+	}
+
+	var fieldErrs field.ErrorList
+	path := field.NewPath("spec", "rkeConfig", "machinePools")
+	names := make(map[string]bool, len(pools))
+	for i, pool := range pools {
+		namePath := path.Index(i).Child("name")
+		if names[pool.Name] {
+			fieldErrs = append(fieldErrs, field.Invalid(namePath, pool.Name, "machine pool names must be unique"))
+			continue
+		}
+		names[pool.Name] = true
+		if oldNames[pool.Name] {
+			continue
+		}
+		if oldName, ok := oldNamesByConfig[machineConfigKey(pool)]; ok {
+			fieldErrs = append(fieldErrs, field.Forbidden(namePath, "machine pools can't be renamed from "+oldName+", the machines of the pool would be orphaned"))
+			continue
+		}
+		if errs := validation.IsDNS1123Label(pool.Name); len(errs) != 0 {
+			fieldErrs = append(fieldErrs, field.Invalid(namePath, pool.Name, strings.Join(errs, ", ")))
+		}
+	}
+
+	if len(pools) != 0 && !hasControlPlaneRoles(pools) && (op == admissionv1.Create || len(oldPools) == 0 || hasControlPlaneRoles(oldPools)) {
+		fieldErrs = append(fieldErrs, field.Required(path, "at least one machine pool must have the etcd role and one the control plane role"))
+	}
+
+	var errList field.ErrorList
+	var warnings []string
+	for _, fieldErr := range fieldErrs {
+		fieldErr.Detail = machinePoolsRule.Message("%s", fieldErr.Detail)
+		if fieldErr, warning := rules.EnforceError(machinePoolsRule, fieldErr); fieldErr != nil {
+			errList = append(errList, fieldErr)
+		} else if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return errList, warnings
+}
+
+// machineConfigKey identifies the machine config of a pool, which Rancher creates for each pool. It returns an empty
+// string for pools without a machine config.
+func machineConfigKey(pool v1.RKEMachinePool) string {
+	if pool.NodeConfig == nil || pool.NodeConfig.Name == "" {
+		return ""
+	}
+	return pool.NodeConfig.Kind + "/" + pool.NodeConfig.Name
+}
+
+// hasControlPlaneRoles returns true if the pools provide both the etcd and control plane roles.
+func hasControlPlaneRoles(pools []v1.RKEMachinePool) bool {
+	var etcd, controlPlane bool
+	for _, pool := range pools {
+		etcd = etcd || pool.EtcdRole
+		controlPlane = controlPlane || pool.ControlPlaneRole
+	}
+	return etcd && controlPlane
+}
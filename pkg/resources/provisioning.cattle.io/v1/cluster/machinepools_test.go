@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"testing"
+
+	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateMachinePools(t *testing.T) {
+	t.Parallel()
+	pool := func(name, config string, etcd, controlPlane bool) v1.RKEMachinePool {
+		return v1.RKEMachinePool{
+			Name:             name,
+			NodeConfig:       &corev1.ObjectReference{Kind: "Amazonec2Config", Name: config},
+			EtcdRole:         etcd,
+			ControlPlaneRole: controlPlane,
+			WorkerRole:       !etcd && !controlPlane,
+		}
+	}
+	cluster := func(pools ...v1.RKEMachinePool) *v1.Cluster {
+		return &v1.Cluster{Spec: v1.ClusterSpec{RKEConfig: &v1.RKEConfig{MachinePools: pools}}}
+	}
+
+	tests := []struct {
+		name       string
+		op         admissionv1.Operation
+		oldCluster *v1.Cluster
+		newCluster *v1.Cluster
+		wantErrs   []string
+	}{
+		{
+			name:       "imported cluster",
+			op:         admissionv1.Create,
+			newCluster: &v1.Cluster{},
+		},
+		{
+			name:       "custom cluster",
+			op:         admissionv1.Create,
+			newCluster: cluster(),
+		},
+		{
+			name:       "valid pools",
+			op:         admissionv1.Create,
+			newCluster: cluster(pool("pool1", "nc-1", true, true), pool("pool2", "nc-2", false, false)),
+		},
+		{
+			name:       "split control plane pools",
+			op:         admissionv1.Create,
+			newCluster: cluster(pool("etcd", "nc-1", true, false), pool("cp", "nc-2", false, true)),
+		},
+		{
+			name:       "duplicate names",
+			op:         admissionv1.Create,
+			newCluster: cluster(pool("pool1", "nc-1", true, true), pool("pool1", "nc-2", false, false)),
+			wantErrs:   []string{`spec.rkeConfig.machinePools[1].name: Invalid value: "pool1": machine pool names must be unique`},
+		},
+		{
+			name:       "invalid name",
+			op:         admissionv1.Create,
+			newCluster: cluster(pool("Pool_1", "nc-1", true, true)),
+			wantErrs:   []string{`spec.rkeConfig.machinePools[0].name: Invalid value: "Pool_1": a lowercase RFC 1123 label`},
+		},
+		{
+			name:       "missing control plane",
+			op:         admissionv1.Create,
+			newCluster: cluster(pool("etcd", "nc-1", true, false), pool("workers", "nc-2", false, false)),
+			wantErrs:   []string{"spec.rkeConfig.machinePools: Required value: at least one machine pool must have the etcd role and one the control plane role"},
+		},
+		{
+			name:       "pool added on update",
+			op:         admissionv1.Update,
+			oldCluster: cluster(pool("pool1", "nc-1", true, true)),
+			newCluster: cluster(pool("pool1", "nc-1", true, true), pool("pool2", "nc-2", false, false)),
+		},
+		{
+			name:       "pool renamed",
+			op:         admissionv1.Update,
+			oldCluster: cluster(pool("pool1", "nc-1", true, true)),
+			newCluster: cluster(pool("control-plane", "nc-1", true, true)),
+			wantErrs:   []string{"spec.rkeConfig.machinePools[0].name: Forbidden: machine pools can't be renamed from pool1"},
+		},
+		{
+			name:       "existing invalid name kept",
+			op:         admissionv1.Update,
+			oldCluster: cluster(pool("Pool_1", "nc-1", true, true)),
+			newCluster: cluster(pool("Pool_1", "nc-1", true, true), pool("pool2", "nc-2", false, false)),
+		},
+		{
+			name:       "control plane removed",
+			op:         admissionv1.Update,
+			oldCluster: cluster(pool("pool1", "nc-1", true, true), pool("pool2", "nc-2", false, false)),
+			newCluster: cluster(pool("pool2", "nc-2", false, false)),
+			wantErrs:   []string{"spec.rkeConfig.machinePools: Required value"},
+		},
+		{
+			name:       "cluster without control plane updated",
+			op:         admissionv1.Update,
+			oldCluster: cluster(pool("pool1", "nc-1", false, false)),
+			newCluster: cluster(pool("pool1", "nc-1", false, false), pool("pool2", "nc-2", false, false)),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := test.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			errList, warnings := validateMachinePools(oldCluster, test.newCluster, test.op)
+			assert.Empty(t, warnings)
+			if assert.Len(t, errList, len(test.wantErrs), "%v", errList) {
+				for i, wantErr := range test.wantErrs {
+					assert.Contains(t, errList[i].Error(), wantErr)
+					assert.Contains(t, errList[i].Error(), "/rules/cluster-machine-pools")
+				}
+			}
+		})
+	}
+}
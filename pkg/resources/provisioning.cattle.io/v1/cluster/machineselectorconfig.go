@@ -0,0 +1,174 @@
+package cluster
+
+import (
+	"reflect"
+	"slices"
+
+	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	machineSelectorConfigKeyRule = rules.Register(rules.Rule{
+		ID:            "cluster-machine-selector-config-key",
+		GVR:           gvr,
+		Description:   "The config keys of the machineSelectorConfig entries of an RKE2 or K3s cluster must be arguments of the distro at the cluster's Kubernetes version. Versions without a bundled catalog of arguments aren't checked.",
+		Severity:      rules.SeverityDeny,
+		ExampleDenial: `spec.rkeConfig.machineSelectorConfig[0].config[kubelet-args]: Invalid value: "kubelet-args": not an argument of rke2 v1.31.4+rke2r1`,
+	})
+	machineSelectorConfigConflictRule = rules.Register(rules.Rule{
+		ID:            "cluster-machine-selector-config-conflict",
+		GVR:           gvr,
+		Description:   "machineSelectorConfig entries whose selectors can match the same machines can't set a config key to different values.",
+		Severity:      rules.SeverityDeny,
+		ExampleDenial: `spec.rkeConfig.machineSelectorConfig[1].config[protect-kernel-defaults]: Invalid value: true: conflicts with machineSelectorConfig[0], which can match the same machines and sets it to false`,
+	})
+)
+
+// validateMachineSelectorConfig validates the machineSelectorConfig entries of an RKE2/K3s cluster: their selectors
+// must parse, their config keys must be arguments of the distro, and entries matching the same machines can't set a
+// key to different values. On update the entries are only checked if they or the Kubernetes version changed, and keys
+// already set at the same version aren't checked against the catalog of arguments. Warnings are returned for the rules
+// at the warn stage.
+func validateMachineSelectorConfig(oldCluster, newCluster *v1.Cluster, op admissionv1.Operation) (field.ErrorList, []string) {
+	if newCluster.Spec.RKEConfig == nil {
+		return nil, nil
+	}
+	configs := newCluster.Spec.RKEConfig.MachineSelectorConfig
+	version := newCluster.Spec.KubernetesVersion
+	checkedKeys := func(string) bool { return true }
+	if op == admissionv1.Update && oldCluster.Spec.RKEConfig != nil && oldCluster.Spec.KubernetesVersion == version {
+		oldConfigs := oldCluster.Spec.RKEConfig.MachineSelectorConfig
+		if reflect.DeepEqual(oldConfigs, configs) {
+			return nil, nil
+		}
+		oldKeys := sets.New[string]()
+		for _, config := range oldConfigs {
+			for key := range config.Config.Data {
+				oldKeys.Insert(key)
+			}
+		}
+		checkedKeys = func(key string) bool { return !oldKeys.Has(key) }
+	}
+
+	var errList field.ErrorList
+	var warnings []string
+	enforce := func(rule rules.Rule, fieldErr *field.Error) {
+		if fieldErr, warning := rules.EnforceError(rule, fieldErr); fieldErr != nil {
+			errList = append(errList, fieldErr)
+		} else if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	path := field.NewPath("spec", "rkeConfig", "machineSelectorConfig")
+	selectors := make([]labels.Selector, len(configs))
+	for i, config := range configs {
+		selector, err := machineSelector(config)
+		if err != nil {
+			errList = append(errList, field.Invalid(path.Index(i).Child("machineLabelSelector"), config.MachineLabelSelector, err.Error()))
+			continue
+		}
+		selectors[i] = selector
+		for _, key := range sortedKeys(config.Config.Data) {
+			if !checkedKeys(key) {
+				continue
+			}
+			if known, covered := knownConfigArg(version, key); covered && !known {
+				enforce(machineSelectorConfigKeyRule, field.Invalid(path.Index(i).Child("config").Key(key), key,
+					machineSelectorConfigKeyRule.Message("not an argument of %s %s", getRuntime(version), version)))
+			}
+		}
+	}
+
+	for j := range configs {
+		for i := 0; i < j; i++ {
+			if selectors[i] == nil || selectors[j] == nil || !selectorsOverlap(selectors[i], selectors[j]) {
+				continue
+			}
+			for _, key := range sortedKeys(configs[j].Config.Data) {
+				value, ok := configs[i].Config.Data[key]
+				if !ok || reflect.DeepEqual(value, configs[j].Config.Data[key]) {
+					continue
+				}
+				enforce(machineSelectorConfigConflictRule, field.Invalid(path.Index(j).Child("config").Key(key), configs[j].Config.Data[key],
+					machineSelectorConfigConflictRule.Message("conflicts with machineSelectorConfig[%d], which can match the same machines and sets it to %v", i, value)))
+			}
+		}
+	}
+	return errList, warnings
+}
+
+// machineSelector returns the selector of the machines the config applies to. Configs without a selector apply to all
+// machines.
+func machineSelector(config rkev1.RKESystemConfig) (labels.Selector, error) {
+	if config.MachineLabelSelector == nil {
+		return labels.Everything(), nil
+	}
+	return metav1.LabelSelectorAsSelector(config.MachineLabelSelector)
+}
+
+// selectorsOverlap returns true if some set of labels can match both selectors, which is the case unless the
+// requirements of the selectors on one of the labels contradict each other.
+func selectorsOverlap(a, b labels.Selector) bool {
+	requirements, _ := a.Requirements()
+	other, _ := b.Requirements()
+	byKey := map[string][]labels.Requirement{}
+	for _, requirement := range append(requirements, other...) {
+		byKey[requirement.Key()] = append(byKey[requirement.Key()], requirement)
+	}
+	for _, keyRequirements := range byKey {
+		if !satisfiable(keyRequirements) {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiable returns true if the label of the requirements can be missing, or set to a value, so that all the
+// requirements match.
+func satisfiable(requirements []labels.Requirement) bool {
+	canBeMissing, canBeSet := true, true
+	var allowed sets.Set[string]
+	excluded := sets.New[string]()
+	for _, requirement := range requirements {
+		switch requirement.Operator() {
+		case selection.In, selection.Equals, selection.DoubleEquals:
+			canBeMissing = false
+			values := sets.New(requirement.Values().UnsortedList()...)
+			if allowed == nil {
+				allowed = values
+			} else {
+				allowed = allowed.Intersection(values)
+			}
+		case selection.NotIn, selection.NotEquals:
+			excluded.Insert(requirement.Values().UnsortedList()...)
+		case selection.Exists, selection.GreaterThan, selection.LessThan:
+			canBeMissing = false
+		case selection.DoesNotExist:
+			canBeSet = false
+		}
+	}
+	if canBeMissing {
+		return true
+	}
+	// without a set of allowed values, the label can be set to a value none of the requirements exclude
+	return canBeSet && (allowed == nil || allowed.Difference(excluded).Len() > 0)
+}
+
+// sortedKeys returns the keys of the config in order, so that errors are reported in a stable order.
+func sortedKeys(config map[string]any) []string {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
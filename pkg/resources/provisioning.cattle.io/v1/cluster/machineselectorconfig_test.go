@@ -0,0 +1,178 @@
+package cluster
+
+import (
+	"testing"
+
+	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateMachineSelectorConfig(t *testing.T) {
+	t.Parallel()
+	controlPlane := &metav1.LabelSelector{MatchLabels: map[string]string{"rke.cattle.io/control-plane-role": "true"}}
+	worker := &metav1.LabelSelector{MatchLabels: map[string]string{"rke.cattle.io/control-plane-role": "false"}}
+	linux := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "kubernetes.io/os", Operator: metav1.LabelSelectorOpIn, Values: []string{"linux"}},
+	}}
+	notLinux := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "kubernetes.io/os", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"linux"}},
+	}}
+	config := func(selector *metav1.LabelSelector, data map[string]any) rkev1.RKESystemConfig {
+		return rkev1.RKESystemConfig{MachineLabelSelector: selector, Config: rkev1.GenericMap{Data: data}}
+	}
+	cluster := func(version string, configs ...rkev1.RKESystemConfig) *v1.Cluster {
+		return &v1.Cluster{Spec: v1.ClusterSpec{
+			KubernetesVersion: version,
+			RKEConfig: &v1.RKEConfig{
+				RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{MachineSelectorConfig: configs},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		op         admissionv1.Operation
+		oldCluster *v1.Cluster
+		newCluster *v1.Cluster
+		wantErrs   []string
+	}{
+		{
+			name:       "imported cluster",
+			op:         admissionv1.Create,
+			newCluster: &v1.Cluster{},
+		},
+		{
+			name: "known keys",
+			op:   admissionv1.Create,
+			newCluster: cluster("v1.31.4+rke2r1",
+				config(nil, map[string]any{"protect-kernel-defaults": false, "cloud-provider-name": ""}),
+				config(controlPlane, map[string]any{"kube-apiserver-arg": []any{"audit-log-maxage=30"}}),
+			),
+		},
+		{
+			name:       "unknown key",
+			op:         admissionv1.Create,
+			newCluster: cluster("v1.31.4+rke2r1", config(nil, map[string]any{"kubelet-args": []any{"max-pods=250"}})),
+			wantErrs:   []string{`spec.rkeConfig.machineSelectorConfig[0].config[kubelet-args]: Invalid value: "kubelet-args": not an argument of rke2 v1.31.4+rke2r1`},
+		},
+		{
+			name:       "key of the other distro",
+			op:         admissionv1.Create,
+			newCluster: cluster("v1.31.4+k3s1", config(nil, map[string]any{"cni": "calico"})),
+			wantErrs:   []string{"config[cni]"},
+		},
+		{
+			name:       "key added in a later version",
+			op:         admissionv1.Create,
+			newCluster: cluster("v1.29.12+rke2r1", config(nil, map[string]any{"ingress-controller": "traefik"})),
+			wantErrs:   []string{"config[ingress-controller]"},
+		},
+		{
+			name:       "key removed in an earlier version",
+			op:         admissionv1.Create,
+			newCluster: cluster("v1.30.8+k3s1", config(nil, map[string]any{"multi-cluster-cidr": true})),
+			wantErrs:   []string{"config[multi-cluster-cidr]"},
+		},
+		{
+			name:       "version without a catalog",
+			op:         admissionv1.Create,
+			newCluster: cluster("v1.40.0+rke2r1", config(nil, map[string]any{"future-arg": true})),
+		},
+		{
+			name: "invalid selector",
+			op:   admissionv1.Create,
+			newCluster: cluster("v1.31.4+rke2r1", config(&metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "kubernetes.io/os", Operator: metav1.LabelSelectorOpIn},
+			}}, nil)),
+			wantErrs: []string{"spec.rkeConfig.machineSelectorConfig[0].machineLabelSelector: Invalid value"},
+		},
+		{
+			name: "conflicting values of overlapping selectors",
+			op:   admissionv1.Create,
+			newCluster: cluster("v1.31.4+rke2r1",
+				config(nil, map[string]any{"protect-kernel-defaults": false}),
+				config(controlPlane, map[string]any{"protect-kernel-defaults": true}),
+			),
+			wantErrs: []string{"spec.rkeConfig.machineSelectorConfig[1].config[protect-kernel-defaults]: Invalid value: true: conflicts with machineSelectorConfig[0], which can match the same machines and sets it to false"},
+		},
+		{
+			name: "conflicting values of selectors on different labels",
+			op:   admissionv1.Create,
+			newCluster: cluster("v1.31.4+rke2r1",
+				config(linux, map[string]any{"selinux": true}),
+				config(controlPlane, map[string]any{"selinux": false}),
+			),
+			wantErrs: []string{"config[selinux]"},
+		},
+		{
+			name: "different values of disjoint label values",
+			op:   admissionv1.Create,
+			newCluster: cluster("v1.31.4+rke2r1",
+				config(controlPlane, map[string]any{"protect-kernel-defaults": true}),
+				config(worker, map[string]any{"protect-kernel-defaults": false}),
+			),
+		},
+		{
+			name: "different values of disjoint expressions",
+			op:   admissionv1.Create,
+			newCluster: cluster("v1.31.4+rke2r1",
+				config(linux, map[string]any{"selinux": true}),
+				config(notLinux, map[string]any{"selinux": false}),
+			),
+		},
+		{
+			name: "same values of overlapping selectors",
+			op:   admissionv1.Create,
+			newCluster: cluster("v1.31.4+rke2r1",
+				config(nil, map[string]any{"kubelet-arg": []any{"max-pods=250"}}),
+				config(controlPlane, map[string]any{"kubelet-arg": []any{"max-pods=250"}}),
+			),
+		},
+		{
+			name:       "unchanged configs on update",
+			op:         admissionv1.Update,
+			oldCluster: cluster("v1.31.4+rke2r1", config(nil, map[string]any{"kubelet-args": true})),
+			newCluster: cluster("v1.31.4+rke2r1", config(nil, map[string]any{"kubelet-args": true})),
+		},
+		{
+			name:       "existing unknown key on update",
+			op:         admissionv1.Update,
+			oldCluster: cluster("v1.31.4+rke2r1", config(nil, map[string]any{"kubelet-args": true})),
+			newCluster: cluster("v1.31.4+rke2r1", config(nil, map[string]any{"kubelet-args": true, "selinux": true})),
+		},
+		{
+			name:       "existing unknown key on upgrade",
+			op:         admissionv1.Update,
+			oldCluster: cluster("v1.31.4+rke2r1", config(nil, map[string]any{"kubelet-args": true})),
+			newCluster: cluster("v1.32.1+rke2r1", config(nil, map[string]any{"kubelet-args": true})),
+			wantErrs:   []string{"config[kubelet-args]"},
+		},
+		{
+			name:       "new unknown key on update",
+			op:         admissionv1.Update,
+			oldCluster: cluster("v1.31.4+rke2r1", config(nil, map[string]any{"selinux": true})),
+			newCluster: cluster("v1.31.4+rke2r1", config(nil, map[string]any{"selinux": true, "kubelet-args": true})),
+			wantErrs:   []string{"config[kubelet-args]"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := test.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			errList, warnings := validateMachineSelectorConfig(oldCluster, test.newCluster, test.op)
+			assert.Empty(t, warnings)
+			if assert.Len(t, errList, len(test.wantErrs), "%v", errList) {
+				for i, wantErr := range test.wantErrs {
+					assert.Contains(t, errList[i].Error(), wantErr)
+				}
+			}
+		})
+	}
+}
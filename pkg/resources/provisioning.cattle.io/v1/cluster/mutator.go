@@ -28,6 +28,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/utils/trace"
 )
 
@@ -117,6 +118,12 @@ func (m *ProvisioningClusterMutator) Admit(request *admission.Request) (*admissi
 
 	if request.Operation == admissionv1.Create {
 		common.SetCreatorIDAnnotation(request, cluster)
+		if err := common.SetOriginalCreatorAnnotations(cluster); err != nil {
+			return nil, err
+		}
+	}
+	if request.Operation == admissionv1.Update {
+		common.KeepOriginalCreatorAnnotations(oldCluster, cluster)
 	}
 
 	response, err := m.handlePSACT(request, cluster)
@@ -219,10 +226,14 @@ func (m *ProvisioningClusterMutator) handlePSACT(request *admission.Request, clu
 			template, err := m.psact.Get(templateName)
 			if err != nil {
 				if apierrors.IsNotFound(err) {
-					return admission.ResponseBadRequest(err.Error()), nil
+					return admission.ResponseBadRequest(field.NotFound(field.NewPath("spec", "defaultPodSecurityAdmissionConfigurationTemplateName"), templateName).Error()), nil
 				}
 				return nil, fmt.Errorf("[provisioning cluster mutator] failed to get psact: %w", err)
 			}
+			// deny templates the cluster can't use before creating the secret for them
+			if err := psa.CheckTemplateCompatibility(template, cluster.Spec.KubernetesVersion); err != nil {
+				return admission.ResponseBadRequest(err.Error()), nil
+			}
 			fileContent, err := psa.GenerateAdmissionConfigFile(template, cluster.Spec.KubernetesVersion)
 			if err != nil {
 				return nil, fmt.Errorf("[provisioning cluster mutator] failed to generate admission configuration from PSACT [%s]: %w", templateName, err)
@@ -13,8 +13,10 @@ import (
 	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/clients"
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	webhookcontrollers "github.com/rancher/webhook/pkg/generated/controllers/webhook.cattle.io/v1"
 	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/provisioning.cattle.io/v1"
 	psa "github.com/rancher/webhook/pkg/podsecurityadmission"
 	"github.com/rancher/webhook/pkg/resources/common"
@@ -27,6 +29,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
@@ -46,14 +49,19 @@ var (
 
 // NewProvisioningClusterValidator returns a new validator for provisioning clusters
 func NewProvisioningClusterValidator(client *clients.Clients) *ProvisioningClusterValidator {
-	return &ProvisioningClusterValidator{
+	validator := &ProvisioningClusterValidator{
 		admitter: provisioningAdmitter{
-			sar:               client.K8s.AuthorizationV1().SubjectAccessReviews(),
+			sar:               client.SubjectAccessReviews(),
 			mgmtClusterClient: client.Management.Cluster(),
 			secretCache:       client.Core.Secret().Cache(),
 			psactCache:        client.Management.PodSecurityAdmissionConfigurationTemplate().Cache(),
 		},
 	}
+	// taint policies only exist on the local cluster
+	if client.Webhook != nil {
+		validator.admitter.taintPolicyCache = client.Webhook.TaintPolicy().Cache()
+	}
+	return validator
 }
 
 type ProvisioningClusterValidator struct {
@@ -85,6 +93,7 @@ type provisioningAdmitter struct {
 	mgmtClusterClient v3.ClusterClient
 	secretCache       corev1controller.SecretCache
 	psactCache        v3.PodSecurityAdmissionConfigurationTemplateCache
+	taintPolicyCache  webhookcontrollers.TaintPolicyCache
 }
 
 // Admit handles the webhook admission request sent to this webhook.
@@ -98,6 +107,7 @@ func (p *provisioningAdmitter) Admit(request *admission.Request) (*admissionv1.A
 	}
 
 	response := &admissionv1.AdmissionResponse{}
+	var warnings []string
 	if request.Operation == admissionv1.Create || request.Operation == admissionv1.Update {
 		if err := p.validateClusterName(request, response, cluster); err != nil || response.Result != nil {
 			return response, err
@@ -125,6 +135,36 @@ func (p *provisioningAdmitter) Admit(request *admission.Request) (*admissionv1.A
 			return response, nil
 		}
 
+		if response.Result = errorListToStatus(validateProvisioningChange(request, oldCluster, cluster)); response.Result != nil {
+			return response, nil
+		}
+
+		if response.Result = errorListToStatus(validateNetworking(oldCluster, cluster, request.Operation)); response.Result != nil {
+			return response, nil
+		}
+
+		errList, configWarnings := validateMachineSelectorConfig(oldCluster, cluster, request.Operation)
+		if response.Result = errorListToStatus(errList); response.Result != nil {
+			return response, nil
+		}
+		warnings = configWarnings
+
+		errList, poolWarnings := validateMachinePools(oldCluster, cluster, request.Operation)
+		if response.Result = errorListToStatus(errList); response.Result != nil {
+			return response, nil
+		}
+		warnings = append(warnings, poolWarnings...)
+
+		policies, err := p.taintPolicies()
+		if err != nil {
+			return nil, err
+		}
+		errList, tolerationWarnings := validateAgentTolerations(policies, oldCluster, cluster, request.Operation)
+		if response.Result = errorListToStatus(errList); response.Result != nil {
+			return response, nil
+		}
+		warnings = append(warnings, tolerationWarnings...)
+
 		if err := p.validateCloudCredentialAccess(request, response, oldCluster, cluster); err != nil || response.Result != nil {
 			return response, err
 		}
@@ -139,9 +179,22 @@ func (p *provisioningAdmitter) Admit(request *admission.Request) (*admissionv1.A
 	}
 
 	response.Allowed = true
+	response.Warnings = append(response.Warnings, warnings...)
 	return response, nil
 }
 
+// taintPolicies returns the taint policies restricting the tolerations of agents, none on downstream clusters.
+func (p *provisioningAdmitter) taintPolicies() ([]*webhookv1.TaintPolicy, error) {
+	if p.taintPolicyCache == nil {
+		return nil, nil
+	}
+	policies, err := p.taintPolicyCache.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list taint policies: %w", err)
+	}
+	return policies, nil
+}
+
 func getEnvVar(name string, envVars []rkev1.EnvVar) *rkev1.EnvVar {
 	var envVar *rkev1.EnvVar
 	for _, e := range envVars {
@@ -457,26 +510,13 @@ func (p *provisioningAdmitter) validatePSACT(request *admission.Request, respons
 				return fmt.Errorf("[provisioning cluster validator] admission-control-config-file under kube-apiserver-arg should not be set to %s", mountPath)
 			}
 		} else {
-			parsedVersion, err := psa.GetClusterVersion(cluster.Spec.KubernetesVersion)
+			// validate that the psact exists and can be used with the k8s version of the cluster
+			template, err := p.psactCache.Get(templateName)
 			if err != nil {
-				return fmt.Errorf("[provisioning cluster validator] failed to parse cluster version: %w", err)
-			}
-			if parsedRangeLessThan123(parsedVersion) {
-				response.Result = &metav1.Status{
-					Status:  failureStatus,
-					Message: "PodSecurityAdmissionConfigurationTemplate(PSACT) is only supported in k8s version 1.23 and above",
-					Reason:  metav1.StatusReasonBadRequest,
-					Code:    http.StatusBadRequest,
-				}
-				return nil
-			}
-
-			// validate that the psact exists
-			if _, err := p.psactCache.Get(templateName); err != nil {
 				if apierrors.IsNotFound(err) {
 					response.Result = &metav1.Status{
 						Status:  failureStatus,
-						Message: err.Error(),
+						Message: field.NotFound(field.NewPath("spec", "defaultPodSecurityAdmissionConfigurationTemplateName"), templateName).Error(),
 						Reason:  metav1.StatusReasonBadRequest,
 						Code:    http.StatusBadRequest,
 					}
@@ -484,6 +524,15 @@ func (p *provisioningAdmitter) validatePSACT(request *admission.Request, respons
 				}
 				return fmt.Errorf("[provisioning cluster validator] failed to get PodSecurityAdmissionConfigurationTemplate: %w", err)
 			}
+			if err := psa.CheckTemplateCompatibility(template, cluster.Spec.KubernetesVersion); err != nil {
+				response.Result = &metav1.Status{
+					Status:  failureStatus,
+					Message: err.Error(),
+					Reason:  metav1.StatusReasonBadRequest,
+					Code:    http.StatusBadRequest,
+				}
+				return nil
+			}
 			// validate that the secret for PSA exists
 			secret, err := p.secretCache.Get(cluster.Namespace, name)
 			if err != nil {
@@ -628,6 +677,99 @@ func validateAppendToleration(toleration []k8sv1.Toleration, path *field.Path) f
 	return errList
 }
 
+// validateProvisioningChange denies updates that add spec.rkeConfig to an imported cluster or remove it from a
+// provisioned cluster, since Rancher can't convert a cluster in place. Rancher itself can migrate a cluster by setting
+// the common.DriverMigrationAnn annotation.
+func validateProvisioningChange(request *admission.Request, oldCluster, newCluster *v1.Cluster) field.ErrorList {
+	if request.Operation != admissionv1.Update || common.IsDriverMigrationAllowed(request, newCluster) {
+		return nil
+	}
+	path := field.NewPath("spec", "rkeConfig")
+	if oldCluster.Spec.RKEConfig == nil && newCluster.Spec.RKEConfig != nil {
+		return field.ErrorList{field.Forbidden(path, "can't be added to an imported cluster, imported clusters can't be converted to provisioned clusters")}
+	}
+	if oldCluster.Spec.RKEConfig != nil && newCluster.Spec.RKEConfig == nil {
+		return field.ErrorList{field.Forbidden(path, "can't be removed from a provisioned cluster, provisioned clusters can't be converted to imported clusters")}
+	}
+	return nil
+}
+
+// validateNetworking validates the cluster-cidr, service-cidr and cluster-dns values of the machine global config.
+// On update only the values that changed are checked, and the node capacity only when it gets worse.
+func validateNetworking(oldCluster, newCluster *v1.Cluster, op admissionv1.Operation) field.ErrorList {
+	networking := rkeClusterNetworking(newCluster)
+	if networking == nil {
+		return nil
+	}
+	if oldNetworking := rkeClusterNetworking(oldCluster); op == admissionv1.Update && oldNetworking != nil {
+		return common.ValidateClusterNetworkingUpdate(*oldNetworking, *networking)
+	}
+	return common.ValidateClusterNetworking(*networking)
+}
+
+// rkeClusterNetworking returns the networking configuration of an RKE2/K3s cluster, falling back to the distro
+// defaults for unset values. The default cluster DNS IP is derived from the service CIDR. It returns nil if the cluster
+// has no RKE config.
+func rkeClusterNetworking(cluster *v1.Cluster) *common.ClusterNetworking {
+	if cluster.Spec.RKEConfig == nil {
+		return nil
+	}
+	configPath := field.NewPath("spec", "rkeConfig", "machineGlobalConfig")
+	config := cluster.Spec.RKEConfig.MachineGlobalConfig.Data
+	networking := &common.ClusterNetworking{
+		ClusterCIDR:     common.DefaultClusterCIDR,
+		ClusterCIDRPath: configPath.Key("cluster-cidr"),
+		ServiceCIDR:     common.DefaultServiceCIDR,
+		ServiceCIDRPath: configPath.Key("service-cidr"),
+		ClusterDNSPath:  configPath.Key("cluster-dns"),
+	}
+	if value, ok := config["cluster-cidr"].(string); ok && value != "" {
+		networking.ClusterCIDR = value
+	}
+	if value, ok := config["service-cidr"].(string); ok && value != "" {
+		networking.ServiceCIDR = value
+	}
+	if value, ok := config["cluster-dns"].(string); ok && value != "" {
+		networking.ClusterDNS = value
+	} else {
+		networking.ClusterDNS = common.DefaultClusterDNSFor(networking.ServiceCIDR)
+	}
+	networking.SetNodeMaskSizes(kubeControllerManagerArgs(config))
+	for _, pool := range cluster.Spec.RKEConfig.MachinePools {
+		if pool.Quantity == nil {
+			networking.NodeCount++
+			continue
+		}
+		networking.NodeCount += int(*pool.Quantity)
+	}
+	return networking
+}
+
+// kubeControllerManagerArgs returns the kube-controller-manager-arg values of the machine global config by name, given
+// as name=value with or without leading dashes, in a list or as a single string.
+func kubeControllerManagerArgs(config map[string]interface{}) map[string]string {
+	var values []string
+	switch value := config["kube-controller-manager-arg"].(type) {
+	case string:
+		values = []string{value}
+	case []string:
+		values = value
+	case []interface{}:
+		for _, element := range value {
+			if s, ok := element.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	args := map[string]string{}
+	for _, value := range values {
+		if name, arg, ok := strings.Cut(strings.TrimLeft(strings.TrimSpace(value), "-"), "="); ok {
+			args[name] = arg
+		}
+	}
+	return args
+}
+
 // errorListToStatus convert an errorList to failure status, it breaks a line for each entry and adds a * in front
 func errorListToStatus(errList field.ErrorList) *metav1.Status {
 	if len(errList) == 0 {
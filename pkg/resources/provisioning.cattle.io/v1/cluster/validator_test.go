@@ -8,8 +8,10 @@ import (
 	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/stretchr/testify/assert"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	k8sv1 "k8s.io/api/core/v1"
 	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -1505,3 +1507,254 @@ func Test_validateAgentDeploymentCustomization(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateNetworking(t *testing.T) {
+	t.Parallel()
+	rkeCluster := func(config map[string]interface{}, quantities ...*int32) *v1.Cluster {
+		cluster := &v1.Cluster{
+			Spec: v1.ClusterSpec{
+				RKEConfig: &v1.RKEConfig{
+					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+						MachineGlobalConfig: rkev1.GenericMap{Data: config},
+					},
+				},
+			},
+		}
+		for _, quantity := range quantities {
+			cluster.Spec.RKEConfig.MachinePools = append(cluster.Spec.RKEConfig.MachinePools, v1.RKEMachinePool{Quantity: quantity})
+		}
+		return cluster
+	}
+	quantity := func(q int32) *int32 { return &q }
+
+	tests := []struct {
+		name       string
+		operation  admissionv1.Operation
+		oldCluster *v1.Cluster
+		newCluster *v1.Cluster
+		wantFields []string
+	}{
+		{
+			name:       "no rke config",
+			operation:  admissionv1.Create,
+			newCluster: &v1.Cluster{},
+		},
+		{
+			name:       "default networking",
+			operation:  admissionv1.Create,
+			newCluster: rkeCluster(nil, quantity(3), nil),
+		},
+		{
+			name:      "custom networking",
+			operation: admissionv1.Create,
+			newCluster: rkeCluster(map[string]interface{}{
+				"cluster-cidr": "172.16.0.0/16",
+				"service-cidr": "172.17.0.0/16",
+				"cluster-dns":  "172.17.0.10",
+			}, quantity(3)),
+		},
+		{
+			name:      "invalid cluster CIDR",
+			operation: admissionv1.Create,
+			newCluster: rkeCluster(map[string]interface{}{
+				"cluster-cidr": "172.16.0.0",
+			}),
+			wantFields: []string{"spec.rkeConfig.machineGlobalConfig[cluster-cidr]"},
+		},
+		{
+			name:      "service CIDR overlapping the default cluster CIDR",
+			operation: admissionv1.Create,
+			newCluster: rkeCluster(map[string]interface{}{
+				"service-cidr": "10.42.0.0/16",
+				"cluster-dns":  "10.42.0.10",
+			}),
+			wantFields: []string{"spec.rkeConfig.machineGlobalConfig[service-cidr]"},
+		},
+		{
+			name:      "cluster DNS outside of the service CIDR",
+			operation: admissionv1.Create,
+			newCluster: rkeCluster(map[string]interface{}{
+				"service-cidr": "172.17.0.0/16",
+				"cluster-dns":  "10.43.0.10",
+			}),
+			wantFields: []string{"spec.rkeConfig.machineGlobalConfig[cluster-dns]"},
+		},
+		{
+			name:      "custom service CIDR without cluster DNS",
+			operation: admissionv1.Create,
+			newCluster: rkeCluster(map[string]interface{}{
+				"service-cidr": "172.17.0.0/16",
+			}),
+		},
+		{
+			name:      "scaling machine pools with a custom service CIDR without cluster DNS",
+			operation: admissionv1.Update,
+			oldCluster: rkeCluster(map[string]interface{}{
+				"service-cidr": "172.17.0.0/16",
+			}, quantity(1)),
+			newCluster: rkeCluster(map[string]interface{}{
+				"service-cidr": "172.17.0.0/16",
+			}, quantity(2)),
+		},
+		{
+			name:      "machine pools filling the cluster CIDR capacity",
+			operation: admissionv1.Create,
+			newCluster: rkeCluster(map[string]interface{}{
+				"cluster-cidr": "10.42.0.0/23",
+			}, quantity(1), nil),
+		},
+		{
+			name:      "scaling machine pools beyond the cluster CIDR capacity",
+			operation: admissionv1.Update,
+			oldCluster: rkeCluster(map[string]interface{}{
+				"cluster-cidr": "10.42.0.0/23",
+			}, quantity(2)),
+			newCluster: rkeCluster(map[string]interface{}{
+				"cluster-cidr": "10.42.0.0/23",
+			}, quantity(3)),
+			wantFields: []string{"spec.rkeConfig.machineGlobalConfig[cluster-cidr]"},
+		},
+		{
+			name:      "scaling machine pools with an unchanged invalid cluster DNS",
+			operation: admissionv1.Update,
+			oldCluster: rkeCluster(map[string]interface{}{
+				"cluster-dns": "10.44.0.10",
+			}, quantity(1)),
+			newCluster: rkeCluster(map[string]interface{}{
+				"cluster-dns": "10.44.0.10",
+			}, quantity(2)),
+		},
+		{
+			name:      "machine pools fitting the cluster CIDR with a custom node mask size",
+			operation: admissionv1.Create,
+			newCluster: rkeCluster(map[string]interface{}{
+				"cluster-cidr":                "10.42.0.0/23",
+				"kube-controller-manager-arg": []interface{}{"node-cidr-mask-size=25"},
+			}, quantity(4)),
+		},
+		{
+			name:      "unchanged invalid networking",
+			operation: admissionv1.Update,
+			oldCluster: rkeCluster(map[string]interface{}{
+				"cluster-cidr": "10.0.0.0/8",
+			}),
+			newCluster: rkeCluster(map[string]interface{}{
+				"cluster-cidr": "10.0.0.0/8",
+			}),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			var gotFields []string
+			for _, err := range validateNetworking(oldCluster, tt.newCluster, tt.operation) {
+				gotFields = append(gotFields, err.Field)
+			}
+			assert.Equal(t, tt.wantFields, gotFields)
+		})
+	}
+}
+
+func TestValidateProvisioningChange(t *testing.T) {
+	t.Parallel()
+	rancherUser := authenticationv1.UserInfo{
+		Username: "system:serviceaccount:cattle-system:rancher",
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:cattle-system", "system:authenticated"},
+	}
+	adminUser := authenticationv1.UserInfo{
+		Username: "u-admin",
+		Groups:   []string{"system:authenticated"},
+	}
+	imported := &v1.Cluster{}
+	provisioned := &v1.Cluster{Spec: v1.ClusterSpec{RKEConfig: &v1.RKEConfig{}}}
+	withMigration := func(cluster *v1.Cluster) *v1.Cluster {
+		cluster = cluster.DeepCopy()
+		cluster.Annotations = map[string]string{common.DriverMigrationAnn: "true"}
+		return cluster
+	}
+
+	tests := []struct {
+		name       string
+		operation  admissionv1.Operation
+		user       authenticationv1.UserInfo
+		oldCluster *v1.Cluster
+		newCluster *v1.Cluster
+		wantErr    bool
+	}{
+		{
+			name:       "create provisioned cluster",
+			operation:  admissionv1.Create,
+			user:       adminUser,
+			oldCluster: imported,
+			newCluster: provisioned,
+		},
+		{
+			name:       "update imported cluster",
+			operation:  admissionv1.Update,
+			user:       adminUser,
+			oldCluster: imported,
+			newCluster: imported,
+		},
+		{
+			name:       "update provisioned cluster",
+			operation:  admissionv1.Update,
+			user:       adminUser,
+			oldCluster: provisioned,
+			newCluster: provisioned,
+		},
+		{
+			name:       "convert imported cluster to provisioned",
+			operation:  admissionv1.Update,
+			user:       adminUser,
+			oldCluster: imported,
+			newCluster: provisioned,
+			wantErr:    true,
+		},
+		{
+			name:       "convert provisioned cluster to imported",
+			operation:  admissionv1.Update,
+			user:       adminUser,
+			oldCluster: provisioned,
+			newCluster: imported,
+			wantErr:    true,
+		},
+		{
+			name:       "convert with migration annotation as rancher",
+			operation:  admissionv1.Update,
+			user:       rancherUser,
+			oldCluster: imported,
+			newCluster: withMigration(provisioned),
+		},
+		{
+			name:       "convert with migration annotation as another user",
+			operation:  admissionv1.Update,
+			user:       adminUser,
+			oldCluster: imported,
+			newCluster: withMigration(provisioned),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					UserInfo:  tt.user,
+				},
+			}
+			errList := validateProvisioningChange(request, tt.oldCluster, tt.newCluster)
+			if tt.wantErr {
+				assert.NotEmpty(t, errList)
+			} else {
+				assert.Empty(t, errList)
+			}
+		})
+	}
+}
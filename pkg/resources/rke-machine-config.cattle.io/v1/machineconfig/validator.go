@@ -2,7 +2,6 @@ package machineconfig
 
 import (
 	"github.com/rancher/webhook/pkg/admission"
-	v1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
 	"github.com/rancher/webhook/pkg/resources/common"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
@@ -55,13 +54,14 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	listTrace := trace.New("machineConfigValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
-	oldUnstrConfig, unstrConfig, err := v1.UnstructuredOldAndNewFromRequest(&request.AdmissionRequest)
+	// Only the creator annotations are checked, so the metadata is decoded without the rest of the config.
+	oldConfig, config, err := admission.MetadataFromRequest(request)
 	if err != nil {
 		return nil, err
 	}
 
 	response := &admissionv1.AdmissionResponse{}
-	if response.Result = common.CheckCreatorID(request, oldUnstrConfig, unstrConfig); response.Result != nil {
+	if response.Result = common.CheckCreatorID(request, oldConfig, config); response.Result != nil {
 		return response, nil
 	}
 
@@ -0,0 +1,101 @@
+// Package clustertierpolicy validates the webhook.cattle.io cluster tier policies restricting the quotas of projects.
+package clustertierpolicy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/quota"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+var (
+	gvr = schema.GroupVersionResource{
+		Group:    "webhook.cattle.io",
+		Version:  "v1",
+		Resource: "clustertierpolicies",
+	}
+	tierPath                  = field.NewPath("spec", "tier")
+	allowedQuotaResourcesPath = field.NewPath("spec", "allowedQuotaResources")
+)
+
+// Validator validates cluster tier policies.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new Validator for cluster tier policies.
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// GVR returns the GroupVersionResource.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by the validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{
+		*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations()),
+	}
+}
+
+// Admitters returns the admitter objects.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct{}
+
+// Admit handles the webhook admission requests.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("clusterTierPolicyValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	policy, err := objectsv1.ClusterTierPolicyFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster tier policy from request: %w", err)
+	}
+	if errList := ValidateSpec(&policy.Spec); len(errList) != 0 {
+		return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// ValidateSpec checks that the tier of the policy is a valid label value and that its allowed quota resources are
+// resources of project quotas, each listed once.
+func ValidateSpec(spec *webhookv1.ClusterTierPolicySpec) field.ErrorList {
+	var errList field.ErrorList
+	if spec.Tier == "" {
+		errList = append(errList, field.Required(tierPath, "tier is required"))
+	}
+	for _, msg := range validation.IsValidLabelValue(spec.Tier) {
+		errList = append(errList, field.Invalid(tierPath, spec.Tier, msg))
+	}
+	supported := quota.LimitResourceNames()
+	for i, resource := range spec.AllowedQuotaResources {
+		path := allowedQuotaResourcesPath.Index(i)
+		if !slices.Contains(supported, resource) {
+			errList = append(errList, field.NotSupported(path, resource, supported))
+			continue
+		}
+		if slices.Contains(spec.AllowedQuotaResources[:i], resource) {
+			errList = append(errList, field.Duplicate(path, resource))
+		}
+	}
+	return errList
+}
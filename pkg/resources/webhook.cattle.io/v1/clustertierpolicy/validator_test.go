@@ -0,0 +1,81 @@
+package clustertierpolicy_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/clustertierpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		spec        webhookv1.ClusterTierPolicySpec
+		wantMessage string
+	}{
+		{
+			name: "valid policy",
+			spec: webhookv1.ClusterTierPolicySpec{Tier: "free", AllowedQuotaResources: []string{"limitsCpu", "limitsMemory", "pods"}},
+		},
+		{
+			name: "policy allowing no resources",
+			spec: webhookv1.ClusterTierPolicySpec{Tier: "free"},
+		},
+		{
+			name:        "missing tier",
+			spec:        webhookv1.ClusterTierPolicySpec{AllowedQuotaResources: []string{"pods"}},
+			wantMessage: "spec.tier: Required value: tier is required",
+		},
+		{
+			name:        "invalid tier",
+			spec:        webhookv1.ClusterTierPolicySpec{Tier: "free tier"},
+			wantMessage: "spec.tier: Invalid value: \"free tier\"",
+		},
+		{
+			name:        "unknown resource",
+			spec:        webhookv1.ClusterTierPolicySpec{Tier: "free", AllowedQuotaResources: []string{"pods", "services.loadbalancers"}},
+			wantMessage: "spec.allowedQuotaResources[1]: Unsupported value: \"services.loadbalancers\"",
+		},
+		{
+			name:        "duplicate resource",
+			spec:        webhookv1.ClusterTierPolicySpec{Tier: "free", AllowedQuotaResources: []string{"pods", "limitsCpu", "pods"}},
+			wantMessage: "spec.allowedQuotaResources[2]: Duplicate value: \"pods\"",
+		},
+	}
+	validator := clustertierpolicy.NewValidator()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			policy := webhookv1.ClusterTierPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "free"},
+				Spec:       test.spec,
+			}
+			raw, err := json.Marshal(policy)
+			require.NoError(t, err)
+			for _, operation := range []admissionv1.Operation{admissionv1.Create, admissionv1.Update} {
+				request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: operation,
+					Object:    runtime.RawExtension{Raw: raw},
+					OldObject: runtime.RawExtension{Raw: raw},
+				}}
+				response, err := validator.Admitters()[0].Admit(request)
+				require.NoError(t, err)
+				if test.wantMessage == "" {
+					assert.True(t, response.Allowed, operation)
+					continue
+				}
+				require.False(t, response.Allowed, operation)
+				assert.Contains(t, response.Result.Message, test.wantMessage, operation)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package custompolicy validates the webhook.cattle.io custom policies adding CEL checks to admission requests.
+package custompolicy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/policy"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+var (
+	gvr = schema.GroupVersionResource{
+		Group:    "webhook.cattle.io",
+		Version:  "v1",
+		Resource: "custompolicies",
+	}
+	resourcesPath  = field.NewPath("spec", "resources")
+	operationsPath = field.NewPath("spec", "operations")
+	expressionPath = field.NewPath("spec", "expression")
+	messagePath    = field.NewPath("spec", "message")
+
+	supportedOperations = []string{
+		string(admissionv1.Create),
+		string(admissionv1.Update),
+		string(admissionv1.Delete),
+	}
+)
+
+// Validator validates custom policies.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new Validator for custom policies.
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// GVR returns the GroupVersionResource.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by the validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{
+		*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations()),
+	}
+}
+
+// Admitters returns the admitter objects.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct{}
+
+// Admit handles the webhook admission requests.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("customPolicyValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	customPolicy, err := objectsv1.CustomPolicyFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom policy from request: %w", err)
+	}
+	if errList := ValidateSpec(&customPolicy.Spec); len(errList) != 0 {
+		return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// ValidateSpec checks that the policy lists valid resources and operations, each once, that its expression compiles
+// to a bool, and that it has a message.
+func ValidateSpec(spec *webhookv1.CustomPolicySpec) field.ErrorList {
+	var errList field.ErrorList
+	if len(spec.Resources) == 0 {
+		errList = append(errList, field.Required(resourcesPath, "at least one resource is required"))
+	}
+	for i, resource := range spec.Resources {
+		path := resourcesPath.Index(i)
+		for _, msg := range validation.IsDNS1123Subdomain(resource) {
+			errList = append(errList, field.Invalid(path, resource, msg))
+		}
+		if slices.Contains(spec.Resources[:i], resource) {
+			errList = append(errList, field.Duplicate(path, resource))
+		}
+	}
+	for i, operation := range spec.Operations {
+		path := operationsPath.Index(i)
+		if !slices.Contains(supportedOperations, operation) {
+			errList = append(errList, field.NotSupported(path, operation, supportedOperations))
+			continue
+		}
+		if slices.Contains(spec.Operations[:i], operation) {
+			errList = append(errList, field.Duplicate(path, operation))
+		}
+	}
+	if spec.Expression == "" {
+		errList = append(errList, field.Required(expressionPath, "expression is required"))
+	} else if _, err := policy.Compile(spec.Expression); err != nil {
+		errList = append(errList, field.Invalid(expressionPath, spec.Expression, err.Error()))
+	}
+	if spec.Message == "" {
+		errList = append(errList, field.Required(messagePath, "message is required"))
+	}
+	return errList
+}
@@ -0,0 +1,121 @@
+package custompolicy_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/custompolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	valid := webhookv1.CustomPolicySpec{
+		Resources:  []string{"clusters.management.cattle.io"},
+		Expression: "has(object.metadata.annotations) && 'example.com/cost-center' in object.metadata.annotations",
+		Message:    "clusters must have a cost-center annotation",
+	}
+	tests := []struct {
+		name        string
+		spec        func(spec *webhookv1.CustomPolicySpec)
+		wantMessage string
+	}{
+		{
+			name: "valid policy",
+			spec: func(_ *webhookv1.CustomPolicySpec) {},
+		},
+		{
+			name: "valid policy with operations and a core resource",
+			spec: func(spec *webhookv1.CustomPolicySpec) {
+				spec.Resources = []string{"namespaces"}
+				spec.Operations = []string{"CREATE", "DELETE"}
+				spec.Expression = "request.userInfo.username != 'system:anonymous'"
+			},
+		},
+		{
+			name:        "missing resources",
+			spec:        func(spec *webhookv1.CustomPolicySpec) { spec.Resources = nil },
+			wantMessage: "spec.resources: Required value",
+		},
+		{
+			name:        "invalid resource",
+			spec:        func(spec *webhookv1.CustomPolicySpec) { spec.Resources = []string{"Clusters"} },
+			wantMessage: "spec.resources[0]: Invalid value: \"Clusters\"",
+		},
+		{
+			name: "duplicate resource",
+			spec: func(spec *webhookv1.CustomPolicySpec) {
+				spec.Resources = []string{"clusters.management.cattle.io", "clusters.management.cattle.io"}
+			},
+			wantMessage: "spec.resources[1]: Duplicate value",
+		},
+		{
+			name:        "unsupported operation",
+			spec:        func(spec *webhookv1.CustomPolicySpec) { spec.Operations = []string{"CONNECT"} },
+			wantMessage: "spec.operations[0]: Unsupported value: \"CONNECT\"",
+		},
+		{
+			name:        "duplicate operation",
+			spec:        func(spec *webhookv1.CustomPolicySpec) { spec.Operations = []string{"UPDATE", "UPDATE"} },
+			wantMessage: "spec.operations[1]: Duplicate value",
+		},
+		{
+			name:        "missing expression",
+			spec:        func(spec *webhookv1.CustomPolicySpec) { spec.Expression = "" },
+			wantMessage: "spec.expression: Required value",
+		},
+		{
+			name:        "expression which doesn't compile",
+			spec:        func(spec *webhookv1.CustomPolicySpec) { spec.Expression = "object.metadata.name ==" },
+			wantMessage: "spec.expression: Invalid value",
+		},
+		{
+			name:        "expression which isn't a bool",
+			spec:        func(spec *webhookv1.CustomPolicySpec) { spec.Expression = "'cost-center'" },
+			wantMessage: "expression must evaluate to a bool, not string",
+		},
+		{
+			name:        "unknown variable",
+			spec:        func(spec *webhookv1.CustomPolicySpec) { spec.Expression = "cluster.metadata.name == 'local'" },
+			wantMessage: "undeclared reference to 'cluster'",
+		},
+		{
+			name:        "missing message",
+			spec:        func(spec *webhookv1.CustomPolicySpec) { spec.Message = "" },
+			wantMessage: "spec.message: Required value",
+		},
+	}
+	validator := custompolicy.NewValidator()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			customPolicy := webhookv1.CustomPolicy{ObjectMeta: metav1.ObjectMeta{Name: "cost-center"}, Spec: valid}
+			customPolicy.Spec.Resources = append([]string{}, valid.Resources...)
+			test.spec(&customPolicy.Spec)
+			raw, err := json.Marshal(customPolicy)
+			require.NoError(t, err)
+			for _, operation := range []admissionv1.Operation{admissionv1.Create, admissionv1.Update} {
+				request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: operation,
+					Object:    runtime.RawExtension{Raw: raw},
+					OldObject: runtime.RawExtension{Raw: raw},
+				}}
+				response, err := validator.Admitters()[0].Admit(request)
+				require.NoError(t, err)
+				if test.wantMessage == "" {
+					assert.True(t, response.Allowed, operation)
+					continue
+				}
+				require.False(t, response.Allowed, operation)
+				assert.Contains(t, response.Result.Message, test.wantMessage, operation)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Package maintenancewindow validates the webhook.cattle.io maintenance windows relaxing the webhook's rules.
+package maintenancewindow
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+const (
+	// maxDurationEnv overrides how long maintenance windows can last.
+	maxDurationEnv = "CATTLE_WEBHOOK_MAINTENANCE_WINDOW_MAX_DURATION"
+	// DefaultMaxDuration is how long maintenance windows can last when maxDurationEnv isn't set.
+	DefaultMaxDuration = 24 * time.Hour
+)
+
+var (
+	gvr = schema.GroupVersionResource{
+		Group:    "webhook.cattle.io",
+		Version:  "v1",
+		Resource: "maintenancewindows",
+	}
+	specPath = field.NewPath("spec")
+)
+
+// Validator validates maintenance windows.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new Validator for maintenance windows.
+func NewValidator() *Validator {
+	return &Validator{
+		admitter: admitter{maxDuration: maxDurationFromEnv()},
+	}
+}
+
+// GVR returns the GroupVersionResource.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by the validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{
+		*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations()),
+	}
+}
+
+// Admitters returns the admitter objects.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+// maxDurationFromEnv returns how long maintenance windows can last. An invalid duration is ignored in favor of the
+// default.
+func maxDurationFromEnv() time.Duration {
+	value := os.Getenv(maxDurationEnv)
+	if value == "" {
+		return DefaultMaxDuration
+	}
+	maxDuration, err := time.ParseDuration(value)
+	if err != nil || maxDuration <= 0 {
+		logrus.Warnf("[maintenance-window-validation] ignoring invalid %s value %q, using %s", maxDurationEnv, value, DefaultMaxDuration)
+		return DefaultMaxDuration
+	}
+	return maxDuration
+}
+
+type admitter struct {
+	// maxDuration is how long maintenance windows can last.
+	maxDuration time.Duration
+}
+
+// Admit handles the webhook admission requests.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("maintenanceWindowValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	window, err := objectsv1.MaintenanceWindowFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance window from request: %w", err)
+	}
+	if errList := a.validateSpec(request.UserInfo.Username, &window.Spec); len(errList) != 0 {
+		return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// validateSpec checks that the window is valid and is approved by a user other than the one making the request.
+func (a *admitter) validateSpec(username string, spec *webhookv1.MaintenanceWindowSpec) field.ErrorList {
+	errList := ValidateSpec(spec, a.maxDuration)
+	if spec.Approver != "" && spec.Approver == username {
+		errList = append(errList, field.Forbidden(specPath.Child("approver"), "maintenance windows can't be approved by the user making the request"))
+	}
+	return errList
+}
+
+// ValidateSpec checks that the window relaxes known rules, for no longer than maxDuration, and names its approver.
+// It doesn't depend on the user making the request, so that windows can be checked before they're applied.
+func ValidateSpec(spec *webhookv1.MaintenanceWindowSpec, maxDuration time.Duration) field.ErrorList {
+	var errList field.ErrorList
+	rulesPath := specPath.Child("rules")
+	if len(spec.Rules) == 0 {
+		errList = append(errList, field.Required(rulesPath, "at least one rule must be relaxed"))
+	}
+	for i, id := range spec.Rules {
+		if _, ok := rules.Get(id); !ok {
+			errList = append(errList, field.NotFound(rulesPath.Index(i), id))
+		}
+	}
+
+	switch {
+	case spec.Start.IsZero():
+		errList = append(errList, field.Required(specPath.Child("start"), ""))
+	case spec.End.IsZero():
+		errList = append(errList, field.Required(specPath.Child("end"), ""))
+	case !spec.Start.Before(&spec.End):
+		errList = append(errList, field.Invalid(specPath.Child("end"), spec.End, "must be after the start"))
+	case spec.End.Sub(spec.Start.Time) > maxDuration:
+		errList = append(errList, field.Invalid(specPath.Child("end"), spec.End, fmt.Sprintf("maintenance windows can't last longer than %s", maxDuration)))
+	}
+
+	if spec.Approver == "" {
+		errList = append(errList, field.Required(specPath.Child("approver"), "maintenance windows must be approved"))
+	}
+	return errList
+}
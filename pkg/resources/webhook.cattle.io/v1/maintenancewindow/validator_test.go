@@ -0,0 +1,108 @@
+package maintenancewindow_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/maintenancewindow"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var testRule = rules.Register(rules.Rule{ID: "maintenance-window-test", Severity: rules.SeverityDeny})
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	spec := func(mutate func(*webhookv1.MaintenanceWindowSpec)) webhookv1.MaintenanceWindowSpec {
+		spec := webhookv1.MaintenanceWindowSpec{
+			Rules:    []string{testRule.ID},
+			Start:    metav1.NewTime(start),
+			End:      metav1.NewTime(start.Add(4 * time.Hour)),
+			Reason:   "freeze exception for incident 123",
+			Approver: "u-approver",
+		}
+		if mutate != nil {
+			mutate(&spec)
+		}
+		return spec
+	}
+
+	tests := []struct {
+		name        string
+		spec        webhookv1.MaintenanceWindowSpec
+		wantMessage string
+	}{
+		{
+			name: "valid window",
+			spec: spec(nil),
+		},
+		{
+			name:        "no rules",
+			spec:        spec(func(s *webhookv1.MaintenanceWindowSpec) { s.Rules = nil }),
+			wantMessage: "spec.rules: Required value: at least one rule must be relaxed",
+		},
+		{
+			name:        "unknown rule",
+			spec:        spec(func(s *webhookv1.MaintenanceWindowSpec) { s.Rules = append(s.Rules, "no-such-rule") }),
+			wantMessage: `spec.rules[1]: Not found: "no-such-rule"`,
+		},
+		{
+			name:        "no end",
+			spec:        spec(func(s *webhookv1.MaintenanceWindowSpec) { s.End = metav1.Time{} }),
+			wantMessage: "spec.end: Required value",
+		},
+		{
+			name:        "end before start",
+			spec:        spec(func(s *webhookv1.MaintenanceWindowSpec) { s.End = metav1.NewTime(start.Add(-time.Hour)) }),
+			wantMessage: "must be after the start",
+		},
+		{
+			name:        "longer than the maximum duration",
+			spec:        spec(func(s *webhookv1.MaintenanceWindowSpec) { s.End = metav1.NewTime(start.Add(25 * time.Hour)) }),
+			wantMessage: "maintenance windows can't last longer than 24h0m0s",
+		},
+		{
+			name:        "no approver",
+			spec:        spec(func(s *webhookv1.MaintenanceWindowSpec) { s.Approver = "" }),
+			wantMessage: "spec.approver: Required value: maintenance windows must be approved",
+		},
+		{
+			name:        "approved by the requesting user",
+			spec:        spec(func(s *webhookv1.MaintenanceWindowSpec) { s.Approver = "u-requester" }),
+			wantMessage: "spec.approver: Forbidden: maintenance windows can't be approved by the user making the request",
+		},
+	}
+	validator := maintenancewindow.NewValidator()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			raw, err := json.Marshal(webhookv1.MaintenanceWindow{ObjectMeta: metav1.ObjectMeta{Name: "freeze"}, Spec: test.spec})
+			require.NoError(t, err)
+			for _, operation := range []admissionv1.Operation{admissionv1.Create, admissionv1.Update} {
+				request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: operation,
+					Object:    runtime.RawExtension{Raw: raw},
+					OldObject: runtime.RawExtension{Raw: raw},
+				}}
+				request.UserInfo.Username = "u-requester"
+				response, err := validator.Admitters()[0].Admit(request)
+				require.NoError(t, err)
+				if test.wantMessage == "" {
+					assert.True(t, response.Allowed, operation)
+					continue
+				}
+				require.False(t, response.Allowed, operation)
+				assert.Contains(t, response.Result.Message, test.wantMessage, operation)
+			}
+		})
+	}
+}
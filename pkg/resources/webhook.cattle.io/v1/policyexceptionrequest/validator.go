@@ -0,0 +1,206 @@
+// Package policyexceptionrequest validates the webhook.cattle.io policy exception requests excepting a user's requests
+// from the webhook's rules.
+package policyexceptionrequest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/rules"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/utils/trace"
+)
+
+const (
+	// ApproveVerb is the verb users need on policy exception requests to approve them.
+	ApproveVerb = "approve"
+	// MaxDuration is how long after their creation exceptions can expire.
+	MaxDuration = 30 * 24 * time.Hour
+)
+
+var (
+	gvr = schema.GroupVersionResource{
+		Group:    "webhook.cattle.io",
+		Version:  "v1",
+		Resource: "policyexceptionrequests",
+	}
+	specPath     = field.NewPath("spec")
+	approverPath = specPath.Child("approver")
+)
+
+// Validator validates policy exception requests.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new Validator for policy exception requests, checking the permission of approvers with the
+// SubjectAccessReviews.
+func NewValidator(sar authorizationv1client.SubjectAccessReviewInterface) *Validator {
+	return &Validator{
+		admitter: admitter{sar: sar, now: time.Now},
+	}
+}
+
+// GVR returns the GroupVersionResource.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by the validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{
+		*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations()),
+	}
+}
+
+// Admitters returns the admitter objects.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	sar authorizationv1client.SubjectAccessReviewInterface
+	now func() time.Time
+}
+
+// Admit handles the webhook admission requests.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("policyExceptionRequestValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	oldException, exception, err := objectsv1.PolicyExceptionRequestOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy exception request from request: %w", err)
+	}
+
+	switch request.Operation {
+	case admissionv1.Create:
+		errList := ValidateSpec(&exception.Spec, metav1.NewTime(a.now()))
+		if exception.Spec.Requester != request.UserInfo.Username {
+			errList = append(errList, field.Forbidden(specPath.Child("requester"), "exceptions can only be requested for the user making the request"))
+		}
+		if len(errList) != 0 {
+			return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+		}
+	case admissionv1.Update:
+		if errList := validateUpdate(&oldException.Spec, &exception.Spec); len(errList) != 0 {
+			return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+		}
+	}
+
+	if exception.Spec.Approver == "" || exception.Spec.Approver == oldException.Spec.Approver {
+		return admission.ResponseAllowed(), nil
+	}
+	return a.validateApproval(request, exception)
+}
+
+// validateApproval checks that the exception is approved by the user making the request, who isn't its requester and
+// is allowed to approve it.
+func (a *admitter) validateApproval(request *admission.Request, exception *webhookv1.PolicyExceptionRequest) (*admissionv1.AdmissionResponse, error) {
+	approver := exception.Spec.Approver
+	if approver != request.UserInfo.Username {
+		return admission.ResponseDeniedErrors(admission.DenialForbidden, field.ErrorList{
+			field.Forbidden(approverPath, "exceptions can only be approved by the user making the request"),
+		}), nil
+	}
+	if approver == exception.Spec.Requester {
+		return admission.ResponseDeniedErrors(admission.DenialForbidden, field.ErrorList{
+			field.Forbidden(approverPath, "exceptions can't be approved by their requester"),
+		}), nil
+	}
+
+	extras := map[string]authorizationv1.ExtraValue{}
+	for k, v := range request.UserInfo.Extra {
+		extras[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := a.sar.Create(request.Context, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:     ApproveVerb,
+				Group:    gvr.Group,
+				Version:  gvr.Version,
+				Resource: gvr.Resource,
+				Name:     exception.Name,
+			},
+			User:   request.UserInfo.Username,
+			Groups: request.UserInfo.Groups,
+			UID:    request.UserInfo.UID,
+			Extra:  extras,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the permission of the approver: %w", err)
+	}
+	if !review.Status.Allowed {
+		return admission.ResponseDeniedErrors(admission.DenialForbidden, field.ErrorList{
+			field.Forbidden(approverPath, fmt.Sprintf("user %s isn't allowed to %s %s", approver, ApproveVerb, gvr.GroupResource())),
+		}), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// validateUpdate checks that only the approver of the exception changes, so that an approved exception can't be
+// widened or extended.
+func validateUpdate(oldSpec, newSpec *webhookv1.PolicyExceptionRequestSpec) field.ErrorList {
+	oldRequest, newRequest := *oldSpec, *newSpec
+	oldRequest.Approver, newRequest.Approver = "", ""
+	if oldRequest != newRequest {
+		return field.ErrorList{field.Forbidden(specPath, "only spec.approver can be changed")}
+	}
+	return nil
+}
+
+// ValidateSpec checks that the exception references a denial and a registered rule, names the object, explains why
+// it's needed, and expires after now but no later than MaxDuration from now.
+func ValidateSpec(spec *webhookv1.PolicyExceptionRequestSpec, now metav1.Time) field.ErrorList {
+	var errList field.ErrorList
+	if spec.CorrelationID == "" {
+		errList = append(errList, field.Required(specPath.Child("correlationID"), "the correlation ID of the denial is required"))
+	}
+	if spec.Rule == "" {
+		errList = append(errList, field.Required(specPath.Child("rule"), ""))
+	} else if _, ok := rules.Get(spec.Rule); !ok {
+		errList = append(errList, field.NotFound(specPath.Child("rule"), spec.Rule))
+	}
+	if spec.Resource == "" {
+		errList = append(errList, field.Required(specPath.Child("resource"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.Resource) {
+			errList = append(errList, field.Invalid(specPath.Child("resource"), spec.Resource, msg))
+		}
+	}
+	if spec.Name == "" {
+		errList = append(errList, field.Required(specPath.Child("name"), ""))
+	}
+	if spec.Requester == "" {
+		errList = append(errList, field.Required(specPath.Child("requester"), ""))
+	}
+	if spec.Reason == "" {
+		errList = append(errList, field.Required(specPath.Child("reason"), "exceptions must explain why they are needed"))
+	}
+	expiresPath := specPath.Child("expires")
+	switch {
+	case spec.Expires.IsZero():
+		errList = append(errList, field.Required(expiresPath, ""))
+	case !now.Before(&spec.Expires):
+		errList = append(errList, field.Invalid(expiresPath, spec.Expires, "must be in the future"))
+	case spec.Expires.Sub(now.Time) > MaxDuration:
+		errList = append(errList, field.Invalid(expiresPath, spec.Expires, fmt.Sprintf("exceptions can't expire later than %s from now", MaxDuration)))
+	}
+	return errList
+}
@@ -0,0 +1,210 @@
+package policyexceptionrequest_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/policyexceptionrequest"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+const (
+	requester = "u-requester"
+	approver  = "u-approver"
+)
+
+var exceptedRule = rules.Register(rules.Rule{
+	ID:          "policy-exception-request-test",
+	GVR:         schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "clusters"},
+	Description: "Rule excepted in the tests of policy exception requests.",
+	Severity:    rules.SeverityDeny,
+})
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	valid := webhookv1.PolicyExceptionRequestSpec{
+		CorrelationID: "0df28fce-a41f-4b0f-9f5e-0c7e4ac8b2f3",
+		Rule:          exceptedRule.ID,
+		Resource:      "clusters.management.cattle.io",
+		Name:          "c-m-abc12",
+		Requester:     requester,
+		Reason:        "Pin the agent to the hotfix build",
+		Expires:       metav1.NewTime(time.Now().Add(24 * time.Hour)),
+	}
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		user        string
+		oldSpec     func(spec *webhookv1.PolicyExceptionRequestSpec)
+		spec        func(spec *webhookv1.PolicyExceptionRequestSpec)
+		wantMessage string
+	}{
+		{
+			name:      "valid request",
+			operation: admissionv1.Create,
+			user:      requester,
+			spec:      func(_ *webhookv1.PolicyExceptionRequestSpec) {},
+		},
+		{
+			name:        "request for another user",
+			operation:   admissionv1.Create,
+			user:        "u-other",
+			spec:        func(_ *webhookv1.PolicyExceptionRequestSpec) {},
+			wantMessage: "spec.requester: Forbidden: exceptions can only be requested for the user making the request",
+		},
+		{
+			name:        "missing correlation ID",
+			operation:   admissionv1.Create,
+			user:        requester,
+			spec:        func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.CorrelationID = "" },
+			wantMessage: "spec.correlationID: Required value",
+		},
+		{
+			name:        "unknown rule",
+			operation:   admissionv1.Create,
+			user:        requester,
+			spec:        func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Rule = "no-such-rule" },
+			wantMessage: "spec.rule: Not found: \"no-such-rule\"",
+		},
+		{
+			name:        "invalid resource",
+			operation:   admissionv1.Create,
+			user:        requester,
+			spec:        func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Resource = "Clusters" },
+			wantMessage: "spec.resource: Invalid value: \"Clusters\"",
+		},
+		{
+			name:        "missing reason",
+			operation:   admissionv1.Create,
+			user:        requester,
+			spec:        func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Reason = "" },
+			wantMessage: "spec.reason: Required value",
+		},
+		{
+			name:      "expired",
+			operation: admissionv1.Create,
+			user:      requester,
+			spec: func(spec *webhookv1.PolicyExceptionRequestSpec) {
+				spec.Expires = metav1.NewTime(time.Now().Add(-time.Minute))
+			},
+			wantMessage: "must be in the future",
+		},
+		{
+			name:      "expiring too late",
+			operation: admissionv1.Create,
+			user:      requester,
+			spec: func(spec *webhookv1.PolicyExceptionRequestSpec) {
+				spec.Expires = metav1.NewTime(time.Now().Add(policyexceptionrequest.MaxDuration + time.Hour))
+			},
+			wantMessage: "exceptions can't expire later than",
+		},
+		{
+			name:        "approved by the requester",
+			operation:   admissionv1.Create,
+			user:        requester,
+			spec:        func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Approver = requester },
+			wantMessage: "spec.approver: Forbidden: exceptions can't be approved by their requester",
+		},
+		{
+			name:      "approval",
+			operation: admissionv1.Update,
+			user:      approver,
+			spec:      func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Approver = approver },
+		},
+		{
+			name:        "approval by a user without the approve verb",
+			operation:   admissionv1.Update,
+			user:        "u-other",
+			spec:        func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Approver = "u-other" },
+			wantMessage: "spec.approver: Forbidden: user u-other isn't allowed to approve policyexceptionrequests.webhook.cattle.io",
+		},
+		{
+			name:        "approval on behalf of another user",
+			operation:   admissionv1.Update,
+			user:        requester,
+			spec:        func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Approver = approver },
+			wantMessage: "spec.approver: Forbidden: exceptions can only be approved by the user making the request",
+		},
+		{
+			name:      "update of an approved exception keeping its approver",
+			operation: admissionv1.Update,
+			user:      requester,
+			oldSpec:   func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Approver = approver },
+			spec:      func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Approver = approver },
+		},
+		{
+			name:      "revoking the approval",
+			operation: admissionv1.Update,
+			user:      requester,
+			oldSpec:   func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Approver = approver },
+			spec:      func(_ *webhookv1.PolicyExceptionRequestSpec) {},
+		},
+		{
+			name:      "extending an approved exception",
+			operation: admissionv1.Update,
+			user:      approver,
+			oldSpec:   func(spec *webhookv1.PolicyExceptionRequestSpec) { spec.Approver = approver },
+			spec: func(spec *webhookv1.PolicyExceptionRequestSpec) {
+				spec.Approver = approver
+				spec.Expires = metav1.NewTime(spec.Expires.Add(time.Hour))
+			},
+			wantMessage: "spec: Forbidden: only spec.approver can be changed",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				attributes := review.Spec.ResourceAttributes
+				review.Status.Allowed = review.Spec.User == approver && attributes.Verb == policyexceptionrequest.ApproveVerb &&
+					attributes.Resource == "policyexceptionrequests" && attributes.Name == "agent-tag"
+				return true, review, nil
+			})
+			validator := policyexceptionrequest.NewValidator((&k8fake.FakeAuthorizationV1{Fake: k8Fake}).SubjectAccessReviews())
+
+			oldException := &webhookv1.PolicyExceptionRequest{ObjectMeta: metav1.ObjectMeta{Name: "agent-tag"}, Spec: valid}
+			if test.oldSpec != nil {
+				test.oldSpec(&oldException.Spec)
+			}
+			exception := &webhookv1.PolicyExceptionRequest{ObjectMeta: metav1.ObjectMeta{Name: "agent-tag"}, Spec: valid}
+			test.spec(&exception.Spec)
+			raw, err := json.Marshal(exception)
+			require.NoError(t, err)
+			oldRaw, err := json.Marshal(oldException)
+			require.NoError(t, err)
+
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: test.operation,
+				UserInfo:  authenticationv1.UserInfo{Username: test.user},
+				Object:    runtime.RawExtension{Raw: raw},
+			}}
+			if test.operation == admissionv1.Update {
+				request.OldObject = runtime.RawExtension{Raw: oldRaw}
+			}
+			response, err := validator.Admitters()[0].Admit(request)
+			require.NoError(t, err)
+			if test.wantMessage == "" {
+				assert.True(t, response.Allowed)
+				return
+			}
+			require.False(t, response.Allowed)
+			assert.Contains(t, response.Result.Message, test.wantMessage)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Package taintpolicy validates the webhook.cattle.io taint policies restricting the tolerations of cluster agents.
+package taintpolicy
+
+import (
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/webhook.cattle.io/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metavalidation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+var (
+	gvr = schema.GroupVersionResource{
+		Group:    "webhook.cattle.io",
+		Version:  "v1",
+		Resource: "taintpolicies",
+	}
+	allowedTolerationsPath = field.NewPath("spec", "allowedTolerations")
+	supportedOperators     = []string{string(corev1.TolerationOpEqual), string(corev1.TolerationOpExists)}
+	supportedEffects       = []string{string(corev1.TaintEffectNoSchedule), string(corev1.TaintEffectPreferNoSchedule), string(corev1.TaintEffectNoExecute)}
+)
+
+// Validator validates taint policies.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new Validator for taint policies.
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// GVR returns the GroupVersionResource.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by the validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{
+		*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations()),
+	}
+}
+
+// Admitters returns the admitter objects.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct{}
+
+// Admit handles the webhook admission requests.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("taintPolicyValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	policy, err := objectsv1.TaintPolicyFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get taint policy from request: %w", err)
+	}
+	if errList := ValidateSpec(&policy.Spec); len(errList) != 0 {
+		return admission.ResponseDeniedErrors(admission.DenialBadRequest, errList), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// ValidateSpec checks that the allowed tolerations of the policy are valid tolerations.
+func ValidateSpec(spec *webhookv1.TaintPolicySpec) field.ErrorList {
+	var errList field.ErrorList
+	for i, toleration := range spec.AllowedTolerations {
+		path := allowedTolerationsPath.Index(i)
+		if toleration.Key != "" {
+			errList = append(errList, metavalidation.ValidateLabelName(toleration.Key, path.Child("key"))...)
+		}
+		switch toleration.Operator {
+		case "", corev1.TolerationOpEqual:
+			if toleration.Key == "" {
+				errList = append(errList, field.Invalid(path.Child("operator"), toleration.Operator, "operator must be Exists when key is empty"))
+			}
+		case corev1.TolerationOpExists:
+			if toleration.Value != "" {
+				errList = append(errList, field.Invalid(path.Child("value"), toleration.Value, "value must be empty when operator is Exists"))
+			}
+		default:
+			errList = append(errList, field.NotSupported(path.Child("operator"), toleration.Operator, supportedOperators))
+		}
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			errList = append(errList, field.NotSupported(path.Child("effect"), toleration.Effect, supportedEffects))
+		}
+	}
+	return errList
+}
@@ -0,0 +1,87 @@
+package taintpolicy_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/taintpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		tolerations []corev1.Toleration
+		wantMessage string
+	}{
+		{
+			name: "valid policy",
+			tolerations: []corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "agents", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "node.kubernetes.io/unreachable", Operator: corev1.TolerationOpExists},
+				{Key: "gpu", Value: "true"},
+			},
+		},
+		{
+			name:        "invalid key",
+			tolerations: []corev1.Toleration{{Key: "not a key", Operator: corev1.TolerationOpExists}},
+			wantMessage: "spec.allowedTolerations[0].key: Invalid value",
+		},
+		{
+			name:        "empty key with the Equal operator",
+			tolerations: []corev1.Toleration{{Value: "true"}},
+			wantMessage: "spec.allowedTolerations[0].operator: Invalid value: \"\": operator must be Exists when key is empty",
+		},
+		{
+			name:        "value with the Exists operator",
+			tolerations: []corev1.Toleration{{Key: "gpu", Operator: corev1.TolerationOpExists, Value: "true"}},
+			wantMessage: "spec.allowedTolerations[0].value: Invalid value: \"true\": value must be empty when operator is Exists",
+		},
+		{
+			name:        "unknown operator",
+			tolerations: []corev1.Toleration{{Key: "gpu", Operator: "In"}},
+			wantMessage: "spec.allowedTolerations[0].operator: Unsupported value: \"In\"",
+		},
+		{
+			name:        "unknown effect",
+			tolerations: []corev1.Toleration{{Key: "gpu", Operator: corev1.TolerationOpExists, Effect: "NoRun"}},
+			wantMessage: "spec.allowedTolerations[0].effect: Unsupported value: \"NoRun\"",
+		},
+	}
+	validator := taintpolicy.NewValidator()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			policy := webhookv1.TaintPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "agents"},
+				Spec:       webhookv1.TaintPolicySpec{AllowedTolerations: test.tolerations},
+			}
+			raw, err := json.Marshal(policy)
+			require.NoError(t, err)
+			for _, operation := range []admissionv1.Operation{admissionv1.Create, admissionv1.Update} {
+				request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: operation,
+					Object:    runtime.RawExtension{Raw: raw},
+					OldObject: runtime.RawExtension{Raw: raw},
+				}}
+				response, err := validator.Admitters()[0].Admit(request)
+				require.NoError(t, err)
+				if test.wantMessage == "" {
+					assert.True(t, response.Allowed, operation)
+					continue
+				}
+				require.False(t, response.Allowed, operation)
+				assert.Contains(t, response.Result.Message, test.wantMessage, operation)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Stage is how strictly a rule is currently enforced.
+type Stage string
+
+const (
+	// StageOff rules are not enforced.
+	StageOff Stage = "off"
+	// StageWarn rules allow requests breaking them with a warning.
+	StageWarn Stage = "warn"
+	// StageDeny rules reject requests breaking them.
+	StageDeny Stage = "deny"
+
+	// EnforcementSetting is the name of the setting holding the enforcement schedules of the rules.
+	EnforcementSetting = "webhook-rule-enforcement"
+)
+
+// Schedule holds the dates a rule moves to the warn and deny stages. A rule is off until the first date is reached,
+// and a rule without a date for a stage never enters it.
+type Schedule struct {
+	Warn *time.Time `json:"warn,omitempty"`
+	Deny *time.Time `json:"deny,omitempty"`
+}
+
+// StageAt returns the stage of the schedule at the given time.
+func (s Schedule) StageAt(now time.Time) Stage {
+	if s.Deny != nil && !now.Before(*s.Deny) {
+		return StageDeny
+	}
+	if s.Warn != nil && !now.Before(*s.Warn) {
+		return StageWarn
+	}
+	return StageOff
+}
+
+// ParseSchedules parses the value of the enforcement setting, a JSON object mapping rule IDs to their schedule, e.g.
+// {"cluster-agent-image-tag": {"warn": "2025-03-01T00:00:00Z", "deny": "2025-06-01T00:00:00Z"}}.
+// An empty value has no schedules.
+func ParseSchedules(value string) (map[string]Schedule, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var schedules map[string]Schedule
+	if err := json.Unmarshal([]byte(value), &schedules); err != nil {
+		return nil, fmt.Errorf("invalid rule enforcement schedules: %w", err)
+	}
+	for id, schedule := range schedules {
+		if schedule.Warn != nil && schedule.Deny != nil && schedule.Deny.Before(*schedule.Warn) {
+			return nil, fmt.Errorf("the deny date of rule %s is before its warn date", id)
+		}
+	}
+	return schedules, nil
+}
+
+// defaultStage returns the stage of a rule without a schedule, which follows its severity.
+func defaultStage(rule Rule) Stage {
+	if rule.Severity == SeverityWarn {
+		return StageWarn
+	}
+	return StageDeny
+}
+
+// SettingGetter gets management.cattle.io settings by name, e.g. a Setting cache.
+type SettingGetter interface {
+	Get(name string) (*v3.Setting, error)
+}
+
+// Enforcer decides the stage of rules from the schedules of the enforcement setting. Rules move to the next stage
+// as soon as its date passes, without the setting changing.
+type Enforcer struct {
+	mutex    sync.Mutex
+	settings SettingGetter
+	windows  MaintenanceWindowLister
+	now      func() time.Time
+	// value and schedules cache the last parsed setting value.
+	value     string
+	schedules map[string]Schedule
+}
+
+// NewEnforcer returns an Enforcer reading schedules from the given settings. Without settings, all rules are
+// enforced according to their severity.
+func NewEnforcer(settings SettingGetter) *Enforcer {
+	return &Enforcer{settings: settings, now: time.Now}
+}
+
+// SetSettings changes the settings the schedules are read from.
+func (e *Enforcer) SetSettings(settings SettingGetter) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.settings = settings
+	e.value = ""
+	e.schedules = nil
+}
+
+// Stage returns the current stage of the rule. Rules without a schedule, and all rules if the setting can't be
+// read, are enforced according to their severity. Rules relaxed by an active maintenance window only warn.
+func (e *Enforcer) Stage(rule Rule) Stage {
+	return e.StageWith(rule, nil)
+}
+
+// StageWith returns the current stage of the rule once the overrides of a cluster are applied. Maintenance windows
+// still relax rules a cluster overrides to deny.
+func (e *Enforcer) StageWith(rule Rule, overrides Overrides) Stage {
+	stage := defaultStage(rule)
+	if schedule, ok := e.currentSchedules()[rule.ID]; ok {
+		stage = schedule.StageAt(e.now())
+	}
+	stage = overrides.apply(rule.ID, stage)
+	if stage == StageDeny && e.relaxed(rule.ID) {
+		return StageWarn
+	}
+	return stage
+}
+
+// Enforce returns the current stage of the rule for a request breaking it, and records the violation.
+// Callers deny the request at StageDeny, add a warning at StageWarn, and let the request through at StageOff.
+func (e *Enforcer) Enforce(rule Rule) Stage {
+	return e.EnforceWith(rule, nil)
+}
+
+// EnforceWith is Enforce for a request checked against a cluster with the given overrides.
+func (e *Enforcer) EnforceWith(rule Rule, overrides Overrides) Stage {
+	stage := e.StageWith(rule, overrides)
+	violations.WithLabelValues(rule.ID, string(stage)).Inc()
+	return stage
+}
+
+// currentSchedules returns the schedules of the enforcement setting.
+func (e *Enforcer) currentSchedules() map[string]Schedule {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.settings == nil {
+		return nil
+	}
+	setting, err := e.settings.Get(EnforcementSetting)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logrus.Warnf("[rules] failed to get setting %s, enforcing rules by severity: %v", EnforcementSetting, err)
+		}
+		return nil
+	}
+	value := setting.Value
+	if value == "" {
+		value = setting.Default
+	}
+	if value == e.value {
+		return e.schedules
+	}
+	schedules, err := ParseSchedules(value)
+	if err != nil {
+		logrus.Warnf("[rules] failed to parse setting %s, enforcing rules by severity: %v", EnforcementSetting, err)
+		return nil
+	}
+	e.value = value
+	e.schedules = schedules
+	return schedules
+}
+
+var defaultEnforcer = NewEnforcer(nil)
+
+// ConfigureEnforcement makes the default enforcer read schedules from the given settings.
+func ConfigureEnforcement(settings SettingGetter) {
+	defaultEnforcer.SetSettings(settings)
+}
+
+// Enforce returns the current stage of the rule from the default enforcer and records the violation.
+// See Enforcer.Enforce.
+func Enforce(rule Rule) Stage {
+	return defaultEnforcer.Enforce(rule)
+}
+
+// EnforceWith returns the current stage of the rule from the default enforcer once the overrides of a cluster are
+// applied, and records the violation. See Enforcer.EnforceWith.
+func EnforceWith(rule Rule, overrides Overrides) Stage {
+	return defaultEnforcer.EnforceWith(rule, overrides)
+}
+
+// EnforceError returns the field error of a request breaking the rule if the rule denies it, or the error as a warning
+// if the rule only warns, and records the violation. Nothing is returned if the rule is off. A nil error is not a
+// violation.
+func EnforceError(rule Rule, fieldErr *field.Error) (*field.Error, string) {
+	return EnforceErrorWith(rule, nil, fieldErr)
+}
+
+// EnforceErrorWith is EnforceError for a request checked against a cluster with the given overrides.
+func EnforceErrorWith(rule Rule, overrides Overrides, fieldErr *field.Error) (*field.Error, string) {
+	if fieldErr == nil {
+		return nil, ""
+	}
+	switch EnforceWith(rule, overrides) {
+	case StageDeny:
+		return fieldErr, ""
+	case StageWarn:
+		return nil, fieldErr.Error()
+	default:
+		return nil, ""
+	}
+}
@@ -0,0 +1,203 @@
+package rules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// fakeSettings returns the setting with the given value, or the given error.
+type fakeSettings struct {
+	value string
+	err   error
+}
+
+func (f *fakeSettings) Get(name string) (*v3.Setting, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &v3.Setting{ObjectMeta: metav1.ObjectMeta{Name: name}, Value: f.value}, nil
+}
+
+func TestScheduleStageAt(t *testing.T) {
+	t.Parallel()
+	warn := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	deny := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		now      time.Time
+		want     Stage
+	}{
+		{name: "no dates", schedule: Schedule{}, now: deny, want: StageOff},
+		{name: "before warn", schedule: Schedule{Warn: &warn, Deny: &deny}, now: warn.Add(-time.Second), want: StageOff},
+		{name: "at warn", schedule: Schedule{Warn: &warn, Deny: &deny}, now: warn, want: StageWarn},
+		{name: "between warn and deny", schedule: Schedule{Warn: &warn, Deny: &deny}, now: deny.Add(-time.Second), want: StageWarn},
+		{name: "at deny", schedule: Schedule{Warn: &warn, Deny: &deny}, now: deny, want: StageDeny},
+		{name: "deny without warn", schedule: Schedule{Deny: &deny}, now: warn, want: StageOff},
+		{name: "after deny without warn", schedule: Schedule{Deny: &deny}, now: deny.Add(time.Hour), want: StageDeny},
+		{name: "warn only", schedule: Schedule{Warn: &warn}, now: deny.Add(time.Hour), want: StageWarn},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, test.schedule.StageAt(test.now))
+		})
+	}
+}
+
+func TestParseSchedules(t *testing.T) {
+	t.Parallel()
+	schedules, err := ParseSchedules("")
+	require.NoError(t, err)
+	assert.Empty(t, schedules)
+
+	schedules, err = ParseSchedules(`{"test-rule": {"warn": "2025-03-01T00:00:00Z", "deny": "2025-06-01T00:00:00Z"}, "other-rule": {"deny": "2025-06-01T00:00:00Z"}}`)
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), *schedules["test-rule"].Warn)
+	assert.Nil(t, schedules["other-rule"].Warn)
+
+	_, err = ParseSchedules(`{"test-rule": {"warn": "2025-06-01T00:00:00Z", "deny": "2025-03-01T00:00:00Z"}}`)
+	assert.ErrorContains(t, err, "before its warn date")
+	_, err = ParseSchedules(`{"test-rule": {"warn": "March"}}`)
+	assert.Error(t, err)
+	_, err = ParseSchedules(`["test-rule"]`)
+	assert.Error(t, err)
+}
+
+func TestEnforcerStage(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	warnRule := Rule{ID: "warn-rule", Severity: SeverityWarn}
+
+	tests := []struct {
+		name     string
+		settings SettingGetter
+		rule     Rule
+		want     Stage
+	}{
+		{name: "no settings", rule: testRule, want: StageDeny},
+		{name: "no settings with a warn rule", rule: warnRule, want: StageWarn},
+		{name: "missing setting", settings: &fakeSettings{err: apierrors.NewNotFound(schema.GroupResource{}, EnforcementSetting)}, rule: testRule, want: StageDeny},
+		{name: "failing settings", settings: &fakeSettings{err: errors.New("unavailable")}, rule: testRule, want: StageDeny},
+		{name: "invalid setting", settings: &fakeSettings{value: "foo"}, rule: testRule, want: StageDeny},
+		{name: "rule without a schedule", settings: &fakeSettings{value: `{"other-rule": {"deny": "2025-01-01T00:00:00Z"}}`}, rule: testRule, want: StageDeny},
+		{name: "scheduled rule before warn", settings: &fakeSettings{value: `{"test-rule": {"warn": "2025-05-01T00:00:00Z"}}`}, rule: testRule, want: StageOff},
+		{name: "scheduled rule in warn", settings: &fakeSettings{value: `{"test-rule": {"warn": "2025-03-01T00:00:00Z", "deny": "2025-06-01T00:00:00Z"}}`}, rule: testRule, want: StageWarn},
+		{name: "scheduled rule in deny", settings: &fakeSettings{value: `{"warn-rule": {"deny": "2025-03-01T00:00:00Z"}}`}, rule: warnRule, want: StageDeny},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			enforcer := NewEnforcer(test.settings)
+			enforcer.now = func() time.Time { return now }
+			assert.Equal(t, test.want, enforcer.Stage(test.rule))
+		})
+	}
+}
+
+func TestEnforcerTransitions(t *testing.T) {
+	t.Parallel()
+	settings := &fakeSettings{value: `{"test-rule": {"warn": "2025-03-01T00:00:00Z", "deny": "2025-06-01T00:00:00Z"}}`}
+	now := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	enforcer := NewEnforcer(settings)
+	enforcer.now = func() time.Time { return now }
+
+	assert.Equal(t, StageOff, enforcer.Stage(testRule))
+	now = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, StageWarn, enforcer.Stage(testRule), "the rule must move to warn once the date passes")
+	now = time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, StageDeny, enforcer.Stage(testRule), "the rule must move to deny once the date passes")
+
+	settings.value = `{"test-rule": {"warn": "2025-03-01T00:00:00Z"}}`
+	assert.Equal(t, StageWarn, enforcer.Stage(testRule), "changes to the setting must be picked up")
+	enforcer.SetSettings(nil)
+	assert.Equal(t, StageDeny, enforcer.Stage(testRule))
+}
+
+func TestEnforceRecordsViolations(t *testing.T) {
+	t.Parallel()
+	rule := Rule{ID: "enforce-metrics-rule", Severity: SeverityWarn}
+	enforcer := NewEnforcer(nil)
+	before := testutil.ToFloat64(violations.WithLabelValues(rule.ID, string(StageWarn)))
+	assert.Equal(t, StageWarn, enforcer.Enforce(rule))
+	assert.Equal(t, StageWarn, enforcer.Enforce(rule))
+	assert.Equal(t, before+2, testutil.ToFloat64(violations.WithLabelValues(rule.ID, string(StageWarn))))
+}
+
+func TestEnforceErrorWith(t *testing.T) {
+	t.Parallel()
+	rule := Rule{ID: "enforce-error-rule", Severity: SeverityWarn}
+	fieldErr := field.Invalid(field.NewPath("spec"), "value", "is invalid")
+	tests := []struct {
+		name        string
+		overrides   Overrides
+		fieldErr    *field.Error
+		wantErr     bool
+		wantWarning string
+	}{
+		{
+			name:     "no violation",
+			fieldErr: nil,
+		},
+		{
+			name:        "warn",
+			fieldErr:    fieldErr,
+			wantWarning: fieldErr.Error(),
+		},
+		{
+			name:      "deny",
+			overrides: Overrides{rule.ID: StageDeny},
+			fieldErr:  fieldErr,
+			wantErr:   true,
+		},
+		{
+			name:      "off",
+			overrides: Overrides{rule.ID: StageOff},
+			fieldErr:  fieldErr,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			gotErr, gotWarning := EnforceErrorWith(rule, test.overrides, test.fieldErr)
+			assert.Equal(t, test.wantErr, gotErr != nil)
+			assert.Equal(t, test.wantWarning, gotWarning)
+		})
+	}
+}
+
+func TestStageCollector(t *testing.T) {
+	t.Parallel()
+	registry := NewRegistry()
+	registry.Register(testRule)
+	enforcer := NewEnforcer(&fakeSettings{value: `{"test-rule": {"warn": "2025-03-01T00:00:00Z"}}`})
+	enforcer.now = func() time.Time { return time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC) }
+	collector := &stageCollector{registry: registry, enforcer: enforcer}
+
+	expected := `
+# HELP rancher_webhook_rule_stage Current enforcement stage of a rule, 1 for the current stage and 0 for the others.
+# TYPE rancher_webhook_rule_stage gauge
+rancher_webhook_rule_stage{rule="test-rule",stage="deny"} 0
+rancher_webhook_rule_stage{rule="test-rule",stage="off"} 0
+rancher_webhook_rule_stage{rule="test-rule",stage="warn"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+	require.NoError(t, prometheus.NewPedanticRegistry().Register(collector))
+}
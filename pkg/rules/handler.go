@@ -0,0 +1,37 @@
+package rules
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// RegisterHandlers adds the endpoints documenting the rules of the default registry to the router. Path lists all
+// rules and Path/{id} returns a single rule.
+func RegisterHandlers(router *mux.Router) {
+	registerHandlers(router, defaultRegistry)
+}
+
+func registerHandlers(router *mux.Router, registry *Registry) {
+	router.HandleFunc(Path, func(rw http.ResponseWriter, _ *http.Request) {
+		writeJSON(rw, registry.List())
+	}).Methods(http.MethodGet)
+	router.HandleFunc(Path+"/{id}", func(rw http.ResponseWriter, req *http.Request) {
+		id := mux.Vars(req)["id"]
+		rule, ok := registry.Get(id)
+		if !ok {
+			http.Error(rw, "rule "+id+" not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(rw, rule)
+	}).Methods(http.MethodGet)
+}
+
+func writeJSON(rw http.ResponseWriter, obj any) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(obj); err != nil {
+		logrus.Errorf("failed to write rules response: %v", err)
+	}
+}
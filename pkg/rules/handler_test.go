@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers(t *testing.T) {
+	t.Parallel()
+	registry := NewRegistry()
+	registry.Register(testRule)
+	router := mux.NewRouter()
+	registerHandlers(router, registry)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+		wantBody any
+	}{
+		{
+			name:     "list rules",
+			method:   http.MethodGet,
+			path:     "/rules",
+			wantCode: http.StatusOK,
+			wantBody: []Rule{testRule},
+		},
+		{
+			name:     "get rule",
+			method:   http.MethodGet,
+			path:     "/rules/test-rule",
+			wantCode: http.StatusOK,
+			wantBody: testRule,
+		},
+		{
+			name:     "missing rule",
+			method:   http.MethodGet,
+			path:     "/rules/missing",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "rules are read only",
+			method:   http.MethodPost,
+			path:     "/rules",
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+			require.Equal(t, test.wantCode, recorder.Code)
+			if test.wantBody == nil {
+				return
+			}
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			want, err := json.Marshal(test.wantBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), recorder.Body.String())
+		})
+	}
+}
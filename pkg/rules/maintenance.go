@@ -0,0 +1,50 @@
+package rules
+
+import (
+	"slices"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// MaintenanceWindowLister lists webhook.cattle.io maintenance windows, e.g. a MaintenanceWindow cache.
+type MaintenanceWindowLister interface {
+	List(selector labels.Selector) ([]*webhookv1.MaintenanceWindow, error)
+}
+
+// SetMaintenanceWindows changes the maintenance windows relaxing rules.
+func (e *Enforcer) SetMaintenanceWindows(windows MaintenanceWindowLister) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.windows = windows
+}
+
+// relaxed returns whether a maintenance window active now relaxes the rule. Windows expire on their own, since they
+// are only active until their end.
+func (e *Enforcer) relaxed(id string) bool {
+	e.mutex.Lock()
+	windows := e.windows
+	e.mutex.Unlock()
+	if windows == nil {
+		return false
+	}
+	list, err := windows.List(labels.Everything())
+	if err != nil {
+		logrus.Warnf("[rules] failed to list maintenance windows, enforcing rule %s: %v", id, err)
+		return false
+	}
+	now := metav1.NewTime(e.now())
+	for _, window := range list {
+		if window.ActiveAt(now) && slices.Contains(window.Spec.Rules, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigureMaintenanceWindows makes the default enforcer relax the rules listed by the active maintenance windows.
+func ConfigureMaintenanceWindows(windows MaintenanceWindowLister) {
+	defaultEnforcer.SetMaintenanceWindows(windows)
+}
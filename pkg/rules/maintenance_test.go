@@ -0,0 +1,101 @@
+package rules
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// fakeWindows returns the given maintenance windows, or the given error.
+type fakeWindows struct {
+	windows []*webhookv1.MaintenanceWindow
+	err     error
+}
+
+func (f *fakeWindows) List(labels.Selector) ([]*webhookv1.MaintenanceWindow, error) {
+	return f.windows, f.err
+}
+
+func TestEnforcerMaintenanceWindows(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	window := func(start, end time.Time, rules ...string) *webhookv1.MaintenanceWindow {
+		return &webhookv1.MaintenanceWindow{
+			ObjectMeta: metav1.ObjectMeta{Name: "freeze"},
+			Spec: webhookv1.MaintenanceWindowSpec{
+				Rules:    rules,
+				Start:    metav1.NewTime(start),
+				End:      metav1.NewTime(end),
+				Approver: "u-approver",
+			},
+		}
+	}
+	denyRule := Rule{ID: "deny-rule", Severity: SeverityDeny}
+	warnRule := Rule{ID: "warn-rule", Severity: SeverityWarn}
+	scheduledRule := Rule{ID: "scheduled-rule", Severity: SeverityDeny}
+
+	tests := []struct {
+		name    string
+		windows *fakeWindows
+		rule    Rule
+		want    Stage
+	}{
+		{
+			name:    "active window relaxes the rule",
+			windows: &fakeWindows{windows: []*webhookv1.MaintenanceWindow{window(now.Add(-time.Hour), now.Add(time.Hour), "deny-rule")}},
+			rule:    denyRule,
+			want:    StageWarn,
+		},
+		{
+			name:    "window for other rules",
+			windows: &fakeWindows{windows: []*webhookv1.MaintenanceWindow{window(now.Add(-time.Hour), now.Add(time.Hour), "other-rule")}},
+			rule:    denyRule,
+			want:    StageDeny,
+		},
+		{
+			name:    "window starting later",
+			windows: &fakeWindows{windows: []*webhookv1.MaintenanceWindow{window(now.Add(time.Minute), now.Add(time.Hour), "deny-rule")}},
+			rule:    denyRule,
+			want:    StageDeny,
+		},
+		{
+			name:    "expired window",
+			windows: &fakeWindows{windows: []*webhookv1.MaintenanceWindow{window(now.Add(-time.Hour), now, "deny-rule")}},
+			rule:    denyRule,
+			want:    StageDeny,
+		},
+		{
+			name:    "windows don't change rules that only warn",
+			windows: &fakeWindows{windows: []*webhookv1.MaintenanceWindow{window(now.Add(-time.Hour), now.Add(time.Hour), "warn-rule")}},
+			rule:    warnRule,
+			want:    StageWarn,
+		},
+		{
+			name:    "windows don't change rules that are off",
+			windows: &fakeWindows{windows: []*webhookv1.MaintenanceWindow{window(now.Add(-time.Hour), now.Add(time.Hour), "scheduled-rule")}},
+			rule:    scheduledRule,
+			want:    StageOff,
+		},
+		{
+			name:    "rules are enforced if the windows can't be listed",
+			windows: &fakeWindows{err: errors.New("unexpected error")},
+			rule:    denyRule,
+			want:    StageDeny,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			enforcer := NewEnforcer(&fakeSettings{value: `{"scheduled-rule": {"warn": "2025-06-01T00:00:00Z"}}`})
+			enforcer.now = func() time.Time { return now }
+			enforcer.SetMaintenanceWindows(test.windows)
+			assert.Equal(t, test.want, enforcer.Stage(test.rule))
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package rules
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "rancher_webhook"
+
+var (
+	violations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rule_violations_total",
+		Help:      "Number of requests breaking a rule, by the stage of the rule when the request was made.",
+	}, []string{"rule", "stage"})
+
+	stageDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "rule_stage"),
+		"Current enforcement stage of a rule, 1 for the current stage and 0 for the others.",
+		[]string{"rule", "stage"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(violations, &stageCollector{registry: defaultRegistry, enforcer: defaultEnforcer})
+}
+
+// stageCollector reports the current stage of the rules of a registry. Stages are computed when the metrics are
+// collected, so that scheduled transitions show up without any request breaking the rule.
+type stageCollector struct {
+	registry *Registry
+	enforcer *Enforcer
+}
+
+// Describe implements prometheus.Collector.
+func (c *stageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stageDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *stageCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, rule := range c.registry.List() {
+		current := c.enforcer.Stage(rule)
+		for _, stage := range []Stage{StageOff, StageWarn, StageDeny} {
+			value := 0.0
+			if stage == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(stageDesc, prometheus.GaugeValue, value, rule.ID, string(stage))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OverridesAnnotation is the annotation of a management.cattle.io cluster overriding the stage of rules for the
+// requests checked against that cluster, as a JSON object mapping rule IDs to their stage, e.g.
+// {"cluster-agent-image-tag": "off", "project-used-limit-writer": "deny"}.
+const OverridesAnnotation = "webhook.cattle.io/rule-overrides"
+
+// Overrides maps rule IDs to the stage a cluster asks them to be enforced at.
+type Overrides map[string]Stage
+
+// stageStrictness orders the stages from the least to the most strict.
+var stageStrictness = map[Stage]int{
+	StageOff:  0,
+	StageWarn: 1,
+	StageDeny: 2,
+}
+
+// ParseOverrides parses the value of the overrides annotation. Every rule must be registered in the default registry,
+// and every stage must be off, warn or deny. An empty value has no overrides.
+func ParseOverrides(value string) (Overrides, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var overrides Overrides
+	if err := json.Unmarshal([]byte(value), &overrides); err != nil {
+		return nil, fmt.Errorf("invalid rule overrides: %w", err)
+	}
+	ids := make([]string, 0, len(overrides))
+	for id := range overrides {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, ok := Get(id); !ok {
+			return nil, fmt.Errorf("unknown rule %s", id)
+		}
+		if _, ok := stageStrictness[overrides[id]]; !ok {
+			return nil, fmt.Errorf("invalid stage %q of rule %s, must be one of off, warn or deny", overrides[id], id)
+		}
+	}
+	return overrides, nil
+}
+
+// OverridesOf returns the overrides of the annotations, ignoring an invalid annotation with a warning, since
+// annotations are validated when they are set.
+func OverridesOf(annotations map[string]string) Overrides {
+	value, ok := annotations[OverridesAnnotation]
+	if !ok {
+		return nil
+	}
+	overrides, err := ParseOverrides(value)
+	if err != nil {
+		logrus.Warnf("[rules] ignoring annotation %s: %v", OverridesAnnotation, err)
+		return nil
+	}
+	return overrides
+}
+
+// apply returns the stage of the rule once overridden. Overrides can make a rule stricter, e.g. deny a rule that warns,
+// or silence a rule that warns, but never relax a rule that denies.
+func (o Overrides) apply(id string, stage Stage) Stage {
+	override, ok := o[id]
+	if !ok {
+		return stage
+	}
+	if stageStrictness[override] > stageStrictness[stage] || (override == StageOff && stage == StageWarn) {
+		return override
+	}
+	return stage
+}
@@ -0,0 +1,87 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var overrideTestRule = Register(Rule{ID: "override-test-rule", Severity: SeverityDeny})
+
+func TestParseOverrides(t *testing.T) {
+	t.Parallel()
+	overrides, err := ParseOverrides("")
+	require.NoError(t, err)
+	assert.Empty(t, overrides)
+
+	overrides, err = ParseOverrides(`{"override-test-rule": "off"}`)
+	require.NoError(t, err)
+	assert.Equal(t, Overrides{overrideTestRule.ID: StageOff}, overrides)
+
+	_, err = ParseOverrides(`{"unknown-rule": "off"}`)
+	assert.ErrorContains(t, err, "unknown rule unknown-rule")
+	_, err = ParseOverrides(`{"override-test-rule": "silent"}`)
+	assert.ErrorContains(t, err, `invalid stage "silent" of rule override-test-rule`)
+	_, err = ParseOverrides(`["override-test-rule"]`)
+	assert.Error(t, err)
+}
+
+func TestOverridesOf(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, OverridesOf(nil))
+	assert.Nil(t, OverridesOf(map[string]string{OverridesAnnotation: "foo"}), "invalid overrides must be ignored")
+	assert.Equal(t, Overrides{overrideTestRule.ID: StageDeny}, OverridesOf(map[string]string{OverridesAnnotation: `{"override-test-rule": "deny"}`}))
+}
+
+func TestEnforcerStageWith(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	warning := &fakeSettings{value: `{"test-rule": {"warn": "2025-03-01T00:00:00Z", "deny": "2025-06-01T00:00:00Z"}}`}
+	off := &fakeSettings{value: `{"test-rule": {"warn": "2025-05-01T00:00:00Z"}}`}
+
+	tests := []struct {
+		name      string
+		settings  SettingGetter
+		overrides Overrides
+		windows   MaintenanceWindowLister
+		want      Stage
+	}{
+		{name: "no overrides", settings: warning, want: StageWarn},
+		{name: "override of another rule", settings: warning, overrides: Overrides{"other-rule": StageOff}, want: StageWarn},
+		{name: "warning rule silenced", settings: warning, overrides: Overrides{"test-rule": StageOff}, want: StageOff},
+		{name: "warning rule denied", settings: warning, overrides: Overrides{"test-rule": StageDeny}, want: StageDeny},
+		{name: "rule off before its schedule warned", settings: off, overrides: Overrides{"test-rule": StageWarn}, want: StageWarn},
+		{name: "denying rule can't be silenced", overrides: Overrides{"test-rule": StageOff}, want: StageDeny},
+		{name: "denying rule can't be relaxed", overrides: Overrides{"test-rule": StageWarn}, want: StageDeny},
+		{
+			name:      "maintenance windows relax overridden rules",
+			settings:  warning,
+			overrides: Overrides{"test-rule": StageDeny},
+			windows: &fakeWindows{windows: []*webhookv1.MaintenanceWindow{{
+				ObjectMeta: metav1.ObjectMeta{Name: "freeze"},
+				Spec: webhookv1.MaintenanceWindowSpec{
+					Rules: []string{"test-rule"},
+					Start: metav1.NewTime(now.Add(-time.Hour)),
+					End:   metav1.NewTime(now.Add(time.Hour)),
+				},
+			}}},
+			want: StageWarn,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			enforcer := NewEnforcer(test.settings)
+			enforcer.now = func() time.Time { return now }
+			if test.windows != nil {
+				enforcer.SetMaintenanceWindows(test.windows)
+			}
+			assert.Equal(t, test.want, enforcer.StageWith(testRule, test.overrides))
+		})
+	}
+}
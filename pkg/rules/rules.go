@@ -0,0 +1,163 @@
+// Package rules holds the registry of the validation rules enforced by the webhook, which is used to document them
+// and to link denial messages to their documentation.
+package rules
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Severity is how a request that breaks a rule is handled.
+type Severity string
+
+const (
+	// SeverityDeny rules reject the request.
+	SeverityDeny Severity = "deny"
+	// SeverityWarn rules allow the request with a warning.
+	SeverityWarn Severity = "warn"
+
+	// Path is the path the rules are documented under.
+	Path = "/rules"
+)
+
+// Rule describes a single validation rule enforced by the webhook.
+type Rule struct {
+	// ID uniquely identifies the rule. It is part of the rule's documentation URL, so it must not change once released.
+	ID string
+	// GVR is the resource the rule applies to.
+	GVR schema.GroupVersionResource
+	// Description explains what the rule checks and why.
+	Description string
+	// Severity is how a request breaking the rule is handled.
+	Severity Severity
+	// ExampleDenial is an example of the message returned when the rule is broken.
+	ExampleDenial string
+	// DenialCode is the admission.DenialCode of the requests denied by the rule. Empty means Invalid.
+	DenialCode string
+}
+
+// URL returns the path of the rule's documentation.
+func (r Rule) URL() string {
+	return Path + "/" + r.ID
+}
+
+// Message appends a link to the rule's documentation to the given message.
+func (r Rule) Message(format string, args ...any) string {
+	return fmt.Sprintf("%s (see %s)", fmt.Sprintf(format, args...), r.URL())
+}
+
+// ruleJSON is the JSON representation of a Rule.
+type ruleJSON struct {
+	ID            string   `json:"id"`
+	GVR           gvrJSON  `json:"gvr"`
+	Description   string   `json:"description"`
+	Severity      Severity `json:"severity"`
+	ExampleDenial string   `json:"exampleDenial,omitempty"`
+	DenialCode    string   `json:"denialCode,omitempty"`
+}
+
+type gvrJSON struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+// MarshalJSON encodes the rule with lower case keys.
+func (r Rule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ruleJSON{
+		ID: r.ID,
+		GVR: gvrJSON{
+			Group:    r.GVR.Group,
+			Version:  r.GVR.Version,
+			Resource: r.GVR.Resource,
+		},
+		Description:   r.Description,
+		Severity:      r.Severity,
+		ExampleDenial: r.ExampleDenial,
+		DenialCode:    r.DenialCode,
+	})
+}
+
+// Registry holds rules by their ID.
+type Registry struct {
+	mutex sync.RWMutex
+	rules map[string]Rule
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{rules: map[string]Rule{}}
+}
+
+// Register adds the rule to the registry and returns it. It panics if the rule has no ID or if a rule with the
+// same ID is already registered, since rules are registered when packages are initialized.
+func (r *Registry) Register(rule Rule) Rule {
+	if rule.ID == "" {
+		panic("rules: rule must have an ID")
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.rules[rule.ID]; ok {
+		panic(fmt.Sprintf("rules: rule %s is already registered", rule.ID))
+	}
+	r.rules[rule.ID] = rule
+	return rule
+}
+
+// Get returns the rule with the given ID and whether it was found.
+func (r *Registry) Get(id string) (Rule, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	rule, ok := r.rules[id]
+	return rule, ok
+}
+
+// List returns all registered rules sorted by ID.
+func (r *Registry) List() []Rule {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	rules := make([]Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// Hash returns the SHA-256 hash of the JSON encoding of the rules sorted by ID, as served on the rules endpoint, in
+// the sha256:<hex> form. It changes whenever a rule is added, removed or changed.
+func (r *Registry) Hash() string {
+	data, err := json.Marshal(r.List())
+	if err != nil {
+		// rules only hold strings, so they always encode
+		panic(fmt.Sprintf("rules: failed to encode rules: %v", err))
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+var defaultRegistry = NewRegistry()
+
+// Register adds the rule to the default registry and returns it. See Registry.Register.
+func Register(rule Rule) Rule {
+	return defaultRegistry.Register(rule)
+}
+
+// Get returns the rule with the given ID from the default registry.
+func Get(id string) (Rule, bool) {
+	return defaultRegistry.Get(id)
+}
+
+// List returns all rules of the default registry sorted by ID.
+func List() []Rule {
+	return defaultRegistry.List()
+}
+
+// Hash returns the hash of the rules of the default registry. See Registry.Hash.
+func Hash() string {
+	return defaultRegistry.Hash()
+}
@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var testRule = Rule{
+	ID:            "test-rule",
+	GVR:           schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "clusters"},
+	Description:   "A test rule.",
+	Severity:      SeverityDeny,
+	ExampleDenial: "test denied",
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+	registry := NewRegistry()
+	assert.Empty(t, registry.List())
+
+	rule := registry.Register(testRule)
+	assert.Equal(t, testRule, rule)
+	other := registry.Register(Rule{ID: "another-rule"})
+
+	got, ok := registry.Get(testRule.ID)
+	require.True(t, ok)
+	assert.Equal(t, testRule, got)
+	_, ok = registry.Get("missing")
+	assert.False(t, ok)
+
+	assert.Equal(t, []Rule{other, testRule}, registry.List())
+
+	assert.Panics(t, func() { registry.Register(Rule{ID: testRule.ID}) }, "duplicate IDs must be rejected")
+	assert.Panics(t, func() { registry.Register(Rule{}) }, "rules without an ID must be rejected")
+}
+
+func TestRegistryHash(t *testing.T) {
+	t.Parallel()
+	registry := NewRegistry()
+	empty := registry.Hash()
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", empty)
+
+	registry.Register(testRule)
+	hash := registry.Hash()
+	assert.NotEqual(t, empty, hash)
+
+	same := NewRegistry()
+	same.Register(testRule)
+	assert.Equal(t, hash, same.Hash(), "the hash only depends on the rules")
+
+	changed := NewRegistry()
+	rule := testRule
+	rule.Severity = SeverityWarn
+	changed.Register(rule)
+	assert.NotEqual(t, hash, changed.Hash())
+}
+
+func TestRuleMessage(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "/rules/test-rule", testRule.URL())
+	assert.Equal(t, "cluster c-123 is invalid (see /rules/test-rule)", testRule.Message("cluster %s is invalid", "c-123"))
+}
+
+func TestRuleMarshalJSON(t *testing.T) {
+	t.Parallel()
+	data, err := json.Marshal(testRule)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "test-rule",
+		"gvr": {"group": "management.cattle.io", "version": "v3", "resource": "clusters"},
+		"description": "A test rule.",
+		"severity": "deny",
+		"exampleDenial": "test denied"
+	}`, string(data))
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// bindAddressesEnvKey is the environment variable holding the comma separated IP addresses the webhook listens on,
+// e.g. 0.0.0.0,:: for all the addresses of both families. The webhook listens on all addresses if it's empty, on both
+// families where the node supports them.
+const bindAddressesEnvKey = "CATTLE_WEBHOOK_BIND_ADDRESSES"
+
+// bindAddressesFromEnv returns the addresses the webhook listens on, nil to listen on all addresses.
+func bindAddressesFromEnv() ([]net.IP, error) {
+	addresses, err := ParseBindAddresses(os.Getenv(bindAddressesEnvKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", bindAddressesEnvKey, err)
+	}
+	return addresses, nil
+}
+
+// ParseBindAddresses parses a comma separated list of IP addresses, without ports.
+func ParseBindAddresses(value string) ([]net.IP, error) {
+	var addresses []net.IP
+	seen := map[string]bool{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		address := net.ParseIP(strings.Trim(field, "[]"))
+		if address == nil {
+			return nil, fmt.Errorf("%q isn't an IP address", field)
+		}
+		if seen[address.String()] {
+			return nil, fmt.Errorf("duplicate address %s", address)
+		}
+		seen[address.String()] = true
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// CheckWebhookURL returns an error if the URL set in CATTLE_WEBHOOK_URL can't be called by the API server: it must be
+// an https URL, with IPv6 hosts in brackets, e.g. https://[fd00::10]:9443.
+func CheckWebhookURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "https" || parsed.Host == "" {
+		return errors.New("must be an https URL with a host")
+	}
+	if strings.Contains(parsed.Hostname(), ":") && !strings.HasPrefix(parsed.Host, "[") {
+		return fmt.Errorf("IPv6 host %s must be in brackets", parsed.Host)
+	}
+	if port := parsed.Port(); port != "" {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return fmt.Errorf("invalid port %s", port)
+		}
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return errors.New("must not have a query or fragment")
+	}
+	return nil
+}
+
+// listen listens on the port of each address, or of all addresses if there are none. An address that can't be
+// listened on, e.g. an IPv6 address on a node without IPv6, is only logged as long as another address is listened on,
+// so that a rollout to nodes of mixed families doesn't fail.
+func listen(ctx context.Context, listenConfig net.ListenConfig, addresses []net.IP, port int) (net.Listener, error) {
+	if len(addresses) == 0 {
+		listener, err := listenConfig.Listen(ctx, "tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on port %d: %w", port, err)
+		}
+		return listener, nil
+	}
+	var listeners []net.Listener
+	var errs []error
+	for _, address := range addresses {
+		network := "tcp6"
+		if address.To4() != nil {
+			network = "tcp4"
+		}
+		listener, err := listenConfig.Listen(ctx, network, net.JoinHostPort(address.String(), strconv.Itoa(port)))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("failed to listen on port %d: %w", port, errors.Join(errs...))
+	}
+	for _, err := range errs {
+		logrus.Warnf("Not listening on an address of %s: %v", bindAddressesEnvKey, err)
+	}
+	if len(listeners) == 1 {
+		return listeners[0], nil
+	}
+	return newMultiListener(listeners), nil
+}
+
+// localAddress returns the address the webhook itself reaches its server on: the loopback address of the family of
+// the first bind address if it's unspecified, the first bind address otherwise, and localhost, which resolves to the
+// loopback addresses of both families, without bind addresses.
+func localAddress(addresses []net.IP, port int) string {
+	host := "localhost"
+	if len(addresses) != 0 {
+		switch address := addresses[0]; {
+		case address.IsUnspecified() && address.To4() != nil:
+			host = net.IPv4(127, 0, 0, 1).String()
+		case address.IsUnspecified():
+			host = net.IPv6loopback.String()
+		default:
+			host = address.String()
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// acceptResult is a connection accepted by a listener of a multiListener.
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// multiListener accepts the connections of several listeners, e.g. one per address family.
+type multiListener struct {
+	listeners []net.Listener
+	accepted  chan acceptResult
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newMultiListener(listeners []net.Listener) *multiListener {
+	m := &multiListener{
+		listeners: listeners,
+		accepted:  make(chan acceptResult),
+		closed:    make(chan struct{}),
+	}
+	for _, listener := range listeners {
+		go m.acceptFrom(listener)
+	}
+	return m
+}
+
+// acceptFrom passes the connections of the listener to Accept until it's closed.
+func (m *multiListener) acceptFrom(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		select {
+		case m.accepted <- acceptResult{conn: conn, err: err}:
+		case <-m.closed:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		if err != nil && errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+// Accept returns the next connection accepted by any of the listeners.
+func (m *multiListener) Accept() (net.Conn, error) {
+	select {
+	case result := <-m.accepted:
+		return result.conn, result.err
+	case <-m.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes all the listeners.
+func (m *multiListener) Close() error {
+	var errs []error
+	m.closeOnce.Do(func() {
+		close(m.closed)
+		for _, listener := range m.listeners {
+			errs = append(errs, listener.Close())
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// Addr returns the address of the first listener.
+func (m *multiListener) Addr() net.Addr {
+	return m.listeners[0].Addr()
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBindAddresses(t *testing.T) {
+	t.Parallel()
+	addresses, err := ParseBindAddresses(" 0.0.0.0, [::], fd00::10 ,")
+	require.NoError(t, err)
+	assert.Equal(t, []net.IP{net.IPv4zero, net.IPv6unspecified, net.ParseIP("fd00::10")}, addresses)
+
+	addresses, err = ParseBindAddresses("")
+	require.NoError(t, err)
+	assert.Nil(t, addresses)
+
+	_, err = ParseBindAddresses("0.0.0.0:9443")
+	assert.ErrorContains(t, err, "isn't an IP address")
+	_, err = ParseBindAddresses("::,0::0")
+	assert.ErrorContains(t, err, "duplicate address ::")
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url     string
+		wantErr string
+	}{
+		{url: "https://rancher-webhook.example.com"},
+		{url: "https://10.0.0.10:9443"},
+		{url: "https://[fd00::10]:9443"},
+		{url: "https://fd00::10:9443", wantErr: "must be in brackets"},
+		{url: "http://10.0.0.10:9443", wantErr: "must be an https URL"},
+		{url: "https://[fd00::10]:99999", wantErr: "invalid port"},
+		{url: "https://10.0.0.10?debug=true", wantErr: "must not have a query"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.url, func(t *testing.T) {
+			t.Parallel()
+			err := CheckWebhookURL(test.url)
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestLocalAddress(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "localhost:9443", localAddress(nil, 9443))
+	assert.Equal(t, "127.0.0.1:9443", localAddress([]net.IP{net.IPv4zero, net.IPv6unspecified}, 9443))
+	assert.Equal(t, "[::1]:9443", localAddress([]net.IP{net.IPv6unspecified}, 9443))
+	assert.Equal(t, "[fd00::10]:9443", localAddress([]net.IP{net.ParseIP("fd00::10")}, 9443))
+}
+
+func TestListen(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// an address that can't be listened on doesn't fail as long as another one is
+	listener, err := listen(ctx, net.ListenConfig{}, []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("192.0.2.1")}, 0)
+	require.NoError(t, err)
+	assert.IsType(t, &net.TCPListener{}, listener)
+	require.NoError(t, listener.Close())
+
+	_, err = listen(ctx, net.ListenConfig{}, []net.IP{net.ParseIP("192.0.2.1")}, 0)
+	assert.ErrorContains(t, err, "failed to listen on port 0")
+}
+
+func TestMultiListener(t *testing.T) {
+	t.Parallel()
+	first, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	second, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener := newMultiListener([]net.Listener{first, second})
+	assert.Equal(t, first.Addr(), listener.Addr())
+
+	for _, address := range []net.Addr{first.Addr(), second.Addr()} {
+		port := address.(*net.TCPAddr).Port
+		client, err := net.Dial("tcp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		require.NoError(t, err)
+		conn, err := listener.Accept()
+		require.NoError(t, err)
+		assert.Equal(t, client.LocalAddr().String(), conn.RemoteAddr().String())
+		conn.Close()
+		client.Close()
+	}
+
+	require.NoError(t, listener.Close())
+	require.NoError(t, listener.Close(), "closing twice doesn't fail")
+	_, err = listener.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rancher/webhook/pkg/certs"
+	admissionregistration "github.com/rancher/wrangler/v3/pkg/generated/controllers/admissionregistration.k8s.io/v1"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	caBundleCheckIntervalEnvKey = "CATTLE_WEBHOOK_CA_BUNDLE_CHECK_INTERVAL"
+
+	// defaultCABundleCheckInterval is how often the caBundle of the webhook configurations is checked.
+	defaultCABundleCheckInterval = 5 * time.Minute
+	caBundleDialTimeout          = 10 * time.Second
+
+	validatingConfiguration = "validating"
+	mutatingConfiguration   = "mutating"
+)
+
+var (
+	caBundleMismatches = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "rancher_webhook",
+		Name:      "ca_bundle_mismatches",
+		Help:      "Number of registered webhooks whose caBundle doesn't verify the certificate served by the webhook, by configuration, as of the last check.",
+	}, []string{"configuration"})
+	caBundleRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rancher_webhook",
+		Name:      "ca_bundle_repairs_total",
+		Help:      "Number of times the caBundle of a webhook configuration was repaired, by configuration.",
+	}, []string{"configuration"})
+)
+
+func init() {
+	prometheus.MustRegister(caBundleMismatches, caBundleRepairs)
+}
+
+// caBundleChecker periodically checks that the caBundle registered for each webhook verifies the certificate chain
+// the webhook actually serves, and repairs the configurations that don't. Without it, a mismatch only shows up as x509
+// errors in the logs of the API server.
+type caBundleChecker struct {
+	shard   Shard
+	secrets corecontrollers.SecretCache
+	// source provides the webhook's CA instead of the CA secret, when the certificates aren't stored in secrets.
+	source               certs.Source
+	validatingController admissionregistration.ValidatingWebhookConfigurationClient
+	mutatingController   admissionregistration.MutatingWebhookConfigurationClient
+	// servedChain returns the certificate chain currently served by the webhook.
+	servedChain func() ([]*x509.Certificate, error)
+}
+
+// newCABundleChecker returns a caBundleChecker for the webhook served at the given local address, whose CA is provided
+// by the source, or stored in the CA secret if it's nil.
+func newCABundleChecker(address string, shard Shard, secrets corecontrollers.SecretCache, source certs.Source, validatingController admissionregistration.ValidatingWebhookConfigurationClient, mutatingController admissionregistration.MutatingWebhookConfigurationClient) *caBundleChecker {
+	return &caBundleChecker{
+		shard:                shard,
+		secrets:              secrets,
+		source:               source,
+		validatingController: validatingController,
+		mutatingController:   mutatingController,
+		servedChain: func() ([]*x509.Certificate, error) {
+			return dialServedChain(address, shard.tlsName())
+		},
+	}
+}
+
+// dialServedChain returns the certificate chain served at the given address for the given server name. The chain isn't
+// verified here, verifying it against the registered caBundle is the point of the check.
+func dialServedChain(address, serverName string) ([]*x509.Certificate, error) {
+	dialer := &net.Dialer{Timeout: caBundleDialTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true, //nolint:gosec // the chain is verified against the registered caBundle afterwards
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	defer conn.Close()
+	chain := conn.ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%s didn't present a certificate", address)
+	}
+	return chain, nil
+}
+
+// caBundleCheckIntervalFromEnv returns how often the caBundle is checked. A zero interval disables the check.
+func caBundleCheckIntervalFromEnv() (time.Duration, error) {
+	value := os.Getenv(caBundleCheckIntervalEnvKey)
+	if value == "" {
+		return defaultCABundleCheckInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode %s value '%s': %w", caBundleCheckIntervalEnvKey, value, err)
+	}
+	if interval < 0 {
+		return 0, fmt.Errorf("%s value '%s' must not be negative", caBundleCheckIntervalEnvKey, value)
+	}
+	return interval, nil
+}
+
+// run checks the caBundle every interval until the context is done.
+func (c *caBundleChecker) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.check(); err != nil {
+				logrus.Errorf("[caBundleChecker] failed to check the caBundle of the webhook configurations: %v", err)
+			}
+		}
+	}
+}
+
+// check verifies the caBundle of every webhook of the validating and mutating configurations against the served
+// certificate chain. Configurations with a mismatch have the caBundle of all their webhooks replaced by the webhook's
+// CA, as long as that CA verifies the served chain.
+func (c *caBundleChecker) check() error {
+	chain, err := c.servedChain()
+	if err != nil {
+		return err
+	}
+	// the CA is only read when a repair is needed, and checked before it's used
+	repairBundle := func() ([]byte, error) {
+		if c.source != nil {
+			caBundle := c.source.CABundle()
+			if err := verifyServedChain(caBundle, chain, c.shard.tlsName()); err != nil {
+				return nil, fmt.Errorf("the webhook's CA doesn't verify the served certificate either: %w", err)
+			}
+			return caBundle, nil
+		}
+		secret, err := c.secrets.Get(namespace, caName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get CA secret %s/%s: %w", namespace, caName, err)
+		}
+		caBundle := secret.Data[corev1.TLSCertKey]
+		if err := verifyServedChain(caBundle, chain, c.shard.tlsName()); err != nil {
+			return nil, fmt.Errorf("CA in secret %s/%s doesn't verify the served certificate either: %w", namespace, caName, err)
+		}
+		return caBundle, nil
+	}
+	return errors.Join(c.checkValidating(chain, repairBundle), c.checkMutating(chain, repairBundle))
+}
+
+func (c *caBundleChecker) checkValidating(chain []*x509.Certificate, repairBundle func() ([]byte, error)) error {
+	config, err := c.validatingController.Get(c.shard.configurationName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// the configuration is created once the CA is ready
+		caBundleMismatches.WithLabelValues(validatingConfiguration).Set(0)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get validating configuration: %w", err)
+	}
+	clientConfigs := make([]*v1.WebhookClientConfig, 0, len(config.Webhooks))
+	for i := range config.Webhooks {
+		clientConfigs = append(clientConfigs, &config.Webhooks[i].ClientConfig)
+	}
+	repaired, err := repairClientConfigs(validatingConfiguration, clientConfigs, chain, c.shard.tlsName(), repairBundle)
+	if err != nil || !repaired {
+		return err
+	}
+	if _, err := c.validatingController.Update(config); err != nil {
+		return fmt.Errorf("failed to repair the caBundle of the validating configuration: %w", err)
+	}
+	caBundleRepairs.WithLabelValues(validatingConfiguration).Inc()
+	logrus.Warnf("[caBundleChecker] repaired the caBundle of validating configuration %s", config.Name)
+	return nil
+}
+
+func (c *caBundleChecker) checkMutating(chain []*x509.Certificate, repairBundle func() ([]byte, error)) error {
+	config, err := c.mutatingController.Get(c.shard.configurationName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		caBundleMismatches.WithLabelValues(mutatingConfiguration).Set(0)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get mutating configuration: %w", err)
+	}
+	clientConfigs := make([]*v1.WebhookClientConfig, 0, len(config.Webhooks))
+	for i := range config.Webhooks {
+		clientConfigs = append(clientConfigs, &config.Webhooks[i].ClientConfig)
+	}
+	repaired, err := repairClientConfigs(mutatingConfiguration, clientConfigs, chain, c.shard.tlsName(), repairBundle)
+	if err != nil || !repaired {
+		return err
+	}
+	if _, err := c.mutatingController.Update(config); err != nil {
+		return fmt.Errorf("failed to repair the caBundle of the mutating configuration: %w", err)
+	}
+	caBundleRepairs.WithLabelValues(mutatingConfiguration).Inc()
+	logrus.Warnf("[caBundleChecker] repaired the caBundle of mutating configuration %s", config.Name)
+	return nil
+}
+
+// repairClientConfigs records the number of client configs whose caBundle doesn't verify the served chain, and sets
+// their caBundle to the one returned by repairBundle. It returns true if any client config was changed. Client configs
+// calling a URL instead of the service aren't checked, as they don't reach this webhook.
+func repairClientConfigs(configuration string, clientConfigs []*v1.WebhookClientConfig, chain []*x509.Certificate, dnsName string, repairBundle func() ([]byte, error)) (bool, error) {
+	var mismatched []*v1.WebhookClientConfig
+	var lastErr error
+	for _, clientConfig := range clientConfigs {
+		if clientConfig.Service == nil {
+			continue
+		}
+		if err := verifyServedChain(clientConfig.CABundle, chain, dnsName); err != nil {
+			mismatched = append(mismatched, clientConfig)
+			lastErr = err
+		}
+	}
+	caBundleMismatches.WithLabelValues(configuration).Set(float64(len(mismatched)))
+	if len(mismatched) == 0 {
+		return false, nil
+	}
+	logrus.Warnf("[caBundleChecker] the caBundle of %d webhooks of the %s configuration doesn't verify the served certificate: %v", len(mismatched), configuration, lastErr)
+	caBundle, err := repairBundle()
+	if err != nil {
+		return false, err
+	}
+	for _, clientConfig := range mismatched {
+		clientConfig.CABundle = bytes.Clone(caBundle)
+	}
+	return true, nil
+}
+
+// verifyServedChain returns an error if the PEM encoded caBundle doesn't verify the served certificate chain for the
+// given DNS name.
+func verifyServedChain(caBundle []byte, chain []*x509.Certificate, dnsName string) error {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBundle) {
+		return errors.New("caBundle has no certificates")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		DNSName:       dnsName,
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return err
+}
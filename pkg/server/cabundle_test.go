@@ -0,0 +1,183 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testCA is a CA that signs serving certificates for the webhook.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// serve returns a serving certificate chain signed by the CA.
+func (c *testCA) serve(t *testing.T) []*x509.Certificate {
+	t.Helper()
+	tlsName := Shard{}.tlsName()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: tlsName},
+		DNSNames:     []string{tlsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.cert, &key.PublicKey, c.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return []*x509.Certificate{cert}
+}
+
+func TestCABundleChecker(t *testing.T) {
+	t.Parallel()
+	current := newTestCA(t, "current")
+	previous := newTestCA(t, "previous")
+	served := current.serve(t)
+	serviceConfig := func(caBundle []byte) v1.WebhookClientConfig {
+		return v1.WebhookClientConfig{Service: &v1.ServiceReference{Namespace: namespace, Name: serviceName}, CABundle: caBundle}
+	}
+	notFound := func(name string) error {
+		return apierrors.NewNotFound(v1.Resource("mutatingwebhookconfigurations"), name)
+	}
+
+	tests := []struct {
+		name           string
+		validating     []v1.ValidatingWebhook
+		caSecret       []byte
+		wantValidating []byte
+		wantErr        bool
+	}{
+		{
+			name:       "caBundle verifies the served certificate",
+			validating: []v1.ValidatingWebhook{{Name: "a", ClientConfig: serviceConfig(current.pem)}},
+		},
+		{
+			name: "webhooks calling a URL aren't checked",
+			validating: []v1.ValidatingWebhook{
+				{Name: "a", ClientConfig: serviceConfig(current.pem)},
+				{Name: "b", ClientConfig: v1.WebhookClientConfig{URL: admission.Ptr("https://localhost:9443" + validationPath)}},
+			},
+		},
+		{
+			name: "stale caBundle is repaired",
+			validating: []v1.ValidatingWebhook{
+				{Name: "a", ClientConfig: serviceConfig(current.pem)},
+				{Name: "b", ClientConfig: serviceConfig(previous.pem)},
+			},
+			caSecret:       current.pem,
+			wantValidating: current.pem,
+		},
+		{
+			name:           "empty caBundle is repaired",
+			validating:     []v1.ValidatingWebhook{{Name: "a", ClientConfig: serviceConfig(nil)}},
+			caSecret:       current.pem,
+			wantValidating: current.pem,
+		},
+		{
+			name:       "CA secret doesn't verify the served certificate",
+			validating: []v1.ValidatingWebhook{{Name: "a", ClientConfig: serviceConfig(previous.pem)}},
+			caSecret:   previous.pem,
+			wantErr:    true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			secrets := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+			if test.caSecret != nil {
+				secrets.EXPECT().Get(namespace, caName).Return(&corev1.Secret{Data: map[string][]byte{corev1.TLSCertKey: test.caSecret}}, nil)
+			}
+			validatingController := fake.NewMockNonNamespacedClientInterface[*v1.ValidatingWebhookConfiguration, *v1.ValidatingWebhookConfigurationList](ctrl)
+			validatingController.EXPECT().Get(webhookConfigurationName, gomock.Any()).Return(&v1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: webhookConfigurationName},
+				Webhooks:   test.validating,
+			}, nil)
+			var updated *v1.ValidatingWebhookConfiguration
+			if test.wantValidating != nil {
+				validatingController.EXPECT().Update(gomock.Any()).DoAndReturn(func(config *v1.ValidatingWebhookConfiguration) (*v1.ValidatingWebhookConfiguration, error) {
+					updated = config
+					return config, nil
+				})
+			}
+			mutatingController := fake.NewMockNonNamespacedClientInterface[*v1.MutatingWebhookConfiguration, *v1.MutatingWebhookConfigurationList](ctrl)
+			mutatingController.EXPECT().Get(webhookConfigurationName, gomock.Any()).Return(nil, notFound(webhookConfigurationName))
+
+			checker := &caBundleChecker{
+				secrets:              secrets,
+				validatingController: validatingController,
+				mutatingController:   mutatingController,
+				servedChain:          func() ([]*x509.Certificate, error) { return served, nil },
+			}
+			err := checker.check()
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if test.wantValidating == nil {
+				return
+			}
+			require.NotNil(t, updated)
+			for _, webhook := range updated.Webhooks {
+				if webhook.ClientConfig.Service != nil {
+					assert.Equal(t, test.wantValidating, webhook.ClientConfig.CABundle, webhook.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyServedChain(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t, "ca")
+	served := ca.serve(t)
+	tlsName := Shard{}.tlsName()
+	assert.NoError(t, verifyServedChain(ca.pem, served, tlsName))
+	assert.Error(t, verifyServedChain(newTestCA(t, "other").pem, served, tlsName))
+	assert.Error(t, verifyServedChain([]byte("not a certificate"), served, tlsName))
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// caBundlePath is the path of the endpoint serving the CA bundle trusted for the webhook.
+	caBundlePath = "/ca.crt"
+	// caConfigMapName is the well-known ConfigMap of the cattle-system namespace the CA bundle is published to, under
+	// the caConfigMapKey key.
+	caConfigMapName = "rancher-webhook-ca"
+	caConfigMapKey  = "ca.crt"
+)
+
+// caDistributor publishes the CA of the webhook, so that other components can trust the webhook's certificate: it
+// serves the CA at caBundlePath, and copies it into the caConfigMapName ConfigMap whenever the CA secret, or the CA
+// bundle of the certificate source, changes.
+type caDistributor struct {
+	configMaps corecontrollers.ConfigMapClient
+
+	mutex    sync.RWMutex
+	caBundle []byte
+}
+
+// sync publishes the CA of the CA secret.
+func (d *caDistributor) sync(_ string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil || secret.Name != caName || secret.Namespace != namespace || len(secret.Data[corev1.TLSCertKey]) == 0 {
+		return secret, nil
+	}
+	return secret, d.setCABundle(secret.Data[corev1.TLSCertKey])
+}
+
+// setCABundle serves and publishes the CA bundle.
+func (d *caDistributor) setCABundle(caBundle []byte) error {
+	d.mutex.Lock()
+	d.caBundle = bytes.Clone(caBundle)
+	d.mutex.Unlock()
+	return d.publish(string(caBundle))
+}
+
+// publish creates or updates the caConfigMapName ConfigMap with the CA bundle.
+func (d *caDistributor) publish(caBundle string) error {
+	configMap, err := d.configMaps.Get(namespace, caConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = d.configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: caConfigMapName, Namespace: namespace},
+			Data:       map[string]string{caConfigMapKey: caBundle},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create ConfigMap %s/%s: %w", namespace, caConfigMapName, err)
+		}
+		logrus.Infof("[caDistributor] published the webhook's CA in ConfigMap %s/%s", namespace, caConfigMapName)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get ConfigMap %s/%s: %w", namespace, caConfigMapName, err)
+	}
+	if configMap.Data[caConfigMapKey] == caBundle {
+		return nil
+	}
+	configMap = configMap.DeepCopy()
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[caConfigMapKey] = caBundle
+	if _, err := d.configMaps.Update(configMap); err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s/%s: %w", namespace, caConfigMapName, err)
+	}
+	logrus.Infof("[caDistributor] updated the webhook's CA in ConfigMap %s/%s", namespace, caConfigMapName)
+	return nil
+}
+
+// ServeHTTP serves the PEM encoded CA bundle, or 503 until the CA secret is synced.
+func (d *caDistributor) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+	d.mutex.RLock()
+	caBundle := d.caBundle
+	d.mutex.RUnlock()
+	if len(caBundle) == 0 {
+		http.Error(rw, "the webhook's CA isn't available yet", http.StatusServiceUnavailable)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/x-pem-file")
+	if _, err := rw.Write(caBundle); err != nil {
+		logrus.Errorf("failed to write CA bundle response: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCADistributor(t *testing.T) {
+	t.Parallel()
+	caSecret := func(ca string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: caName, Namespace: namespace},
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte(ca)},
+		}
+	}
+	configMap := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: caConfigMapName, Namespace: namespace}, Data: data}
+	}
+
+	tests := []struct {
+		name          string
+		secret        *corev1.Secret
+		configMap     *corev1.ConfigMap
+		wantCreated   bool
+		wantUpdated   bool
+		wantPublished bool
+	}{
+		{
+			name:   "other secret",
+			secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: namespace}},
+		},
+		{
+			name:          "ConfigMap is created",
+			secret:        caSecret("ca"),
+			wantCreated:   true,
+			wantPublished: true,
+		},
+		{
+			name:          "ConfigMap is updated",
+			secret:        caSecret("ca"),
+			configMap:     configMap(map[string]string{caConfigMapKey: "previous", "other": "kept"}),
+			wantUpdated:   true,
+			wantPublished: true,
+		},
+		{
+			name:          "ConfigMap is up to date",
+			secret:        caSecret("ca"),
+			configMap:     configMap(map[string]string{caConfigMapKey: "ca"}),
+			wantPublished: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			configMaps := fake.NewMockControllerInterface[*corev1.ConfigMap, *corev1.ConfigMapList](ctrl)
+			if test.wantPublished {
+				if test.configMap == nil {
+					configMaps.EXPECT().Get(namespace, caConfigMapName, gomock.Any()).Return(nil, apierrors.NewNotFound(corev1.Resource("configmaps"), caConfigMapName))
+				} else {
+					configMaps.EXPECT().Get(namespace, caConfigMapName, gomock.Any()).Return(test.configMap, nil)
+				}
+			}
+			if test.wantCreated {
+				configMaps.EXPECT().Create(gomock.Any()).DoAndReturn(func(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+					assert.Equal(t, map[string]string{caConfigMapKey: "ca"}, configMap.Data)
+					return configMap, nil
+				})
+			}
+			if test.wantUpdated {
+				configMaps.EXPECT().Update(gomock.Any()).DoAndReturn(func(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+					assert.Equal(t, map[string]string{caConfigMapKey: "ca", "other": "kept"}, configMap.Data)
+					return configMap, nil
+				})
+			}
+
+			distributor := &caDistributor{configMaps: configMaps}
+			rw := httptest.NewRecorder()
+			distributor.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, caBundlePath, nil))
+			assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+
+			_, err := distributor.sync("", test.secret)
+			require.NoError(t, err)
+			rw = httptest.NewRecorder()
+			distributor.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, caBundlePath, nil))
+			if !test.wantPublished {
+				assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+				return
+			}
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Equal(t, "ca", rw.Body.String())
+		})
+	}
+}
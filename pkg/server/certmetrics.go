@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	certExpiryWarningEnvKey = "CATTLE_WEBHOOK_CERT_EXPIRY_WARNING"
+
+	// defaultCertExpiryWarning is how long before the serving certificate or the CA expires a warning is logged.
+	defaultCertExpiryWarning = 30 * 24 * time.Hour
+	// certExpiryCheckInterval is how often the expiry of the certificates is checked for warnings.
+	certExpiryCheckInterval = time.Hour
+)
+
+var (
+	servingCertExpiry = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "rancher_webhook",
+		Name:      "serving_certificate_expiry_timestamp_seconds",
+		Help:      "Time the serving certificate of the webhook expires, in seconds since the epoch.",
+	})
+	caCertExpiry = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "rancher_webhook",
+		Name:      "ca_certificate_expiry_timestamp_seconds",
+		Help:      "Time the CA signing the serving certificate of the webhook expires, in seconds since the epoch.",
+	})
+	configurationApplied = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "rancher_webhook",
+		Name:      "configuration_applied_timestamp_seconds",
+		Help:      "Time the webhook configurations were last applied, in seconds since the epoch.",
+	})
+
+	// certificates tracks the certificates of the webhook for the metrics.
+	certificates = &certMonitor{now: time.Now, warnBefore: defaultCertExpiryWarning}
+)
+
+func init() {
+	prometheus.MustRegister(servingCertExpiry, caCertExpiry, configurationApplied, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "rancher_webhook",
+		Name:      "serving_certificate_rotation_age_seconds",
+		Help:      "Time since the serving certificate of the webhook was last rotated, in seconds.",
+	}, certificates.rotationAge))
+}
+
+// certMonitor watches the serving certificate and CA secrets of the webhook, to expose their expiry and rotation as
+// metrics and warn before they expire, since admission silently breaks once they do.
+type certMonitor struct {
+	mutex sync.Mutex
+	// certName is the name of the serving certificate secret.
+	certName string
+	// warnBefore is how long before a certificate expires a warning is logged.
+	warnBefore time.Duration
+	now        func() time.Time
+
+	serving      *x509.Certificate
+	ca           *x509.Certificate
+	lastRotation time.Time
+}
+
+// certExpiryWarningFromEnv returns how long before a certificate expires a warning is logged.
+func certExpiryWarningFromEnv() (time.Duration, error) {
+	value := os.Getenv(certExpiryWarningEnvKey)
+	if value == "" {
+		return defaultCertExpiryWarning, nil
+	}
+	warnBefore, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode %s value '%s': %w", certExpiryWarningEnvKey, value, err)
+	}
+	return warnBefore, nil
+}
+
+// configure sets the name of the serving certificate secret and the warning threshold.
+func (m *certMonitor) configure(certName string, warnBefore time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.certName = certName
+	m.warnBefore = warnBefore
+}
+
+// sync records the certificate of the serving certificate and CA secrets whenever they change.
+func (m *certMonitor) sync(_ string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil || secret.Namespace != namespace {
+		return nil, nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if secret.Name != caName && secret.Name != m.certName {
+		return nil, nil
+	}
+	cert, err := parseCertificate(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		logrus.Warnf("[certMonitor] failed to parse the certificate of secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		return nil, nil
+	}
+	if secret.Name == caName {
+		m.setCA(cert)
+	} else {
+		// the certificate doesn't tell when it was issued, since it's valid from the time the CA was created
+		m.setServing(cert, lastUpdate(secret))
+	}
+	m.warnExpiry()
+	return nil, nil
+}
+
+// record records the certificates served from a certificate source, whenever they change.
+func (m *certMonitor) record(serving, ca *x509.Certificate) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if ca != nil {
+		m.setCA(ca)
+	}
+	if serving != nil {
+		m.setServing(serving, m.now())
+	}
+	m.warnExpiry()
+}
+
+// setCA records the CA. The mutex must be held.
+func (m *certMonitor) setCA(ca *x509.Certificate) {
+	m.ca = ca
+	caCertExpiry.Set(float64(ca.NotAfter.Unix()))
+}
+
+// setServing records the serving certificate, which was rotated at the given time if it changed. The mutex must be
+// held.
+func (m *certMonitor) setServing(serving *x509.Certificate, rotated time.Time) {
+	if m.serving == nil || !m.serving.Equal(serving) {
+		m.lastRotation = rotated
+	}
+	m.serving = serving
+	servingCertExpiry.Set(float64(serving.NotAfter.Unix()))
+}
+
+// Name returns the name of the readiness check of the serving certificate.
+func (m *certMonitor) Name() string { return "Certificate Available" }
+
+// Check returns an error until a serving certificate is served, or once it expired, since the API server can't call
+// the webhook then.
+func (m *certMonitor) Check(_ *http.Request) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.serving == nil {
+		return errors.New("no serving certificate yet")
+	}
+	if m.now().After(m.serving.NotAfter) {
+		return fmt.Errorf("the serving certificate expired at %s", m.serving.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// run warns about the certificates expiring soon every certExpiryCheckInterval until the context is done.
+func (m *certMonitor) run(ctx context.Context) {
+	ticker := time.NewTicker(certExpiryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.mutex.Lock()
+			m.warnExpiry()
+			m.mutex.Unlock()
+		}
+	}
+}
+
+// warnExpiry logs a warning for the certificates expiring within the warning threshold. The mutex must be held.
+func (m *certMonitor) warnExpiry() {
+	now := m.now()
+	for _, watched := range []struct {
+		name string
+		cert *x509.Certificate
+	}{{"serving certificate", m.serving}, {"CA", m.ca}} {
+		if watched.cert == nil || watched.cert.NotAfter.Sub(now) > m.warnBefore {
+			continue
+		}
+		if now.After(watched.cert.NotAfter) {
+			logrus.Errorf("[certMonitor] the webhook %s expired at %s, the API server can't call the webhook", watched.name, watched.cert.NotAfter.Format(time.RFC3339))
+			continue
+		}
+		logrus.Warnf("[certMonitor] the webhook %s expires at %s, in %s", watched.name, watched.cert.NotAfter.Format(time.RFC3339), watched.cert.NotAfter.Sub(now).Round(time.Minute))
+	}
+}
+
+// rotationAge returns the number of seconds since the serving certificate was rotated, or 0 if it isn't known yet.
+func (m *certMonitor) rotationAge() float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.lastRotation.IsZero() {
+		return 0
+	}
+	return m.now().Sub(m.lastRotation).Seconds()
+}
+
+// lastUpdate returns the last time the secret was written, which is when its certificate was rotated.
+func lastUpdate(secret *corev1.Secret) time.Time {
+	updated := secret.CreationTimestamp.Time
+	for _, entry := range secret.ManagedFields {
+		if entry.Time != nil && entry.Time.After(updated) {
+			updated = entry.Time.Time
+		}
+	}
+	return updated
+}
+
+// parseCertificate returns the first certificate of the PEM encoded data.
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
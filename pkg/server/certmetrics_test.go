@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCertMonitor(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t, "ca")
+	served := ca.serve(t)[0]
+	rotated := ca.serve(t)[0]
+	now := time.Now()
+	monitor := &certMonitor{now: func() time.Time { return now }}
+	monitor.configure(certName, time.Hour)
+	created := metav1.NewTime(now.Add(-2 * time.Hour).Truncate(time.Second))
+	updated := metav1.NewTime(now.Add(-time.Hour).Truncate(time.Second))
+	secret := func(name string, der []byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         namespace,
+				Name:              name,
+				CreationTimestamp: created,
+				ManagedFields:     []metav1.ManagedFieldsEntry{{Manager: "webhook", Time: &updated}},
+			},
+			Data: map[string][]byte{corev1.TLSCertKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})},
+		}
+	}
+
+	assert.Zero(t, monitor.rotationAge(), "the rotation is unknown before the certificate is seen")
+	_, err := monitor.sync("", secret("other", served.Raw))
+	assert.NoError(t, err)
+	assert.Nil(t, monitor.serving)
+
+	_, err = monitor.sync("", secret(caName, ca.cert.Raw))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(ca.cert.NotAfter.Unix()), testutil.ToFloat64(caCertExpiry))
+
+	// the serving certificate was last rotated when its secret was last written
+	_, err = monitor.sync("", secret(certName, served.Raw))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(served.NotAfter.Unix()), testutil.ToFloat64(servingCertExpiry))
+	assert.Equal(t, now.Sub(updated.Time).Seconds(), monitor.rotationAge())
+
+	// updates of the secret that don't change the certificate aren't rotations
+	now = now.Add(time.Minute)
+	unchanged := secret(certName, served.Raw)
+	unchanged.ManagedFields[0].Time = &metav1.Time{Time: now}
+	_, err = monitor.sync("", unchanged)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Sub(updated.Time).Seconds(), monitor.rotationAge())
+
+	rotatedSecret := secret(certName, rotated.Raw)
+	rotatedSecret.ManagedFields[0].Time = &metav1.Time{Time: now}
+	_, err = monitor.sync("", rotatedSecret)
+	assert.NoError(t, err)
+	assert.Zero(t, monitor.rotationAge())
+
+	// secrets without a certificate are ignored
+	_, err = monitor.sync("", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: certName}})
+	assert.NoError(t, err)
+	assert.Equal(t, rotated, monitor.serving)
+}
+
+func TestCertMonitorCheck(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t, "ca")
+	served := ca.serve(t)[0]
+	now := time.Now()
+	monitor := &certMonitor{now: func() time.Time { return now }, warnBefore: time.Hour}
+
+	assert.Error(t, monitor.Check(nil), "the webhook isn't ready before a certificate is served")
+	monitor.record(served, ca.cert)
+	assert.NoError(t, monitor.Check(nil))
+
+	now = served.NotAfter.Add(time.Second)
+	assert.ErrorContains(t, monitor.Check(nil), "expired")
+}
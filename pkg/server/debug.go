@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// debugPortEnvKey is the port of the listener serving the debug endpoints on localhost. The debug endpoints expose
+	// the names of users and objects, flush caches and inject faults, so they aren't served without it, and never on
+	// the port of the webhooks, which lets any client in when no client CA is configured.
+	debugPortEnvKey = "CATTLE_WEBHOOK_DEBUG_PORT"
+	// debugAddress is the address of the debug listener, only reachable from the pod, e.g. with kubectl port-forward.
+	debugAddress = "127.0.0.1"
+	// debugReadHeaderTimeout bounds the time taken by the clients of the debug endpoints to send the request headers.
+	debugReadHeaderTimeout = 10 * time.Second
+)
+
+// debugPortFromEnv returns the port of the debug listener, 0 if the debug endpoints aren't served.
+func debugPortFromEnv() (int, error) {
+	value := os.Getenv(debugPortEnvKey)
+	if value == "" {
+		return 0, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode %s value '%s': %w", debugPortEnvKey, value, err)
+	}
+	if errs := validation.IsValidPortNum(port); len(errs) != 0 {
+		return 0, fmt.Errorf("invalid %s value '%s': %v", debugPortEnvKey, value, errs)
+	}
+	return port, nil
+}
+
+// serveDebug serves the debug endpoints of the router over HTTP on localhost until the context is done.
+func serveDebug(ctx context.Context, port int, router *mux.Router) error {
+	address := net.JoinHostPort(debugAddress, strconv.Itoa(port))
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s for the debug endpoints: %w", address, err)
+	}
+	debugServer := &http.Server{
+		Handler:           router,
+		ReadHeaderTimeout: debugReadHeaderTimeout,
+		ErrorLog:          log.New(logrus.StandardLogger().WriterLevel(logrus.ErrorLevel), "", log.LstdFlags),
+	}
+	go func() {
+		<-ctx.Done()
+		_ = debugServer.Close()
+	}()
+	go func() {
+		logrus.Infof("Serving the debug endpoints on %s", address)
+		if err := debugServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logrus.Errorf("debug server failed: %v", err)
+		}
+	}()
+	return nil
+}
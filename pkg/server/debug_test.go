@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugPortFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr string
+	}{
+		{
+			name: "not served by default",
+		},
+		{
+			name:  "configured",
+			value: "6060",
+			want:  6060,
+		},
+		{
+			name:    "invalid",
+			value:   "debug",
+			wantErr: "failed to decode CATTLE_WEBHOOK_DEBUG_PORT value 'debug'",
+		},
+		{
+			name:    "out of range",
+			value:   "70000",
+			wantErr: "invalid CATTLE_WEBHOOK_DEBUG_PORT value '70000'",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(debugPortEnvKey, test.value)
+			port, err := debugPortFromEnv()
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, port)
+		})
+	}
+}
+
+func TestServeDebug(t *testing.T) {
+	t.Parallel()
+	// find a free port on localhost
+	listener, err := net.Listen("tcp", debugAddress+":0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/debug/test", func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, serveDebug(ctx, port, router))
+
+	url := fmt.Sprintf("http://%s:%d/debug/test", debugAddress, port)
+	response, err := http.Get(url)
+	require.NoError(t, err)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, response.Body.Close())
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+
+	cancel()
+	require.Eventually(t, func() bool {
+		_, err := http.Get(url)
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "the debug server stops with the context")
+}
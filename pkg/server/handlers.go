@@ -1,14 +1,22 @@
 package server
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/rancher/webhook/pkg/admission"
 	"github.com/rancher/webhook/pkg/clients"
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	webhookv1 "github.com/rancher/webhook/pkg/generated/controllers/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/policy"
 	"github.com/rancher/webhook/pkg/resolvers"
 	"github.com/rancher/webhook/pkg/resources/catalog.cattle.io/v1/clusterrepo"
 	"github.com/rancher/webhook/pkg/resources/cluster.cattle.io/v3/clusterauthtoken"
+	"github.com/rancher/webhook/pkg/resources/common"
 	nshandler "github.com/rancher/webhook/pkg/resources/core/v1/namespace"
 	"github.com/rancher/webhook/pkg/resources/core/v1/secret"
+	"github.com/rancher/webhook/pkg/resources/fleet.cattle.io/v1alpha1/bundle"
 	managementCluster "github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/cluster"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/clusterproxyconfig"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/clusterroletemplatebinding"
@@ -30,22 +38,44 @@ import (
 	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/role"
 	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/rolebinding"
 	"github.com/rancher/webhook/pkg/resources/rke-machine-config.cattle.io/v1/machineconfig"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/clustertierpolicy"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/custompolicy"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/maintenancewindow"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/policyexceptionrequest"
+	"github.com/rancher/webhook/pkg/resources/webhook.cattle.io/v1/taintpolicy"
+	"github.com/rancher/webhook/pkg/rules"
 )
 
 // Validation returns a list of all ValidatingAdmissionHandlers used by the webhook.
 func Validation(clients *clients.Clients) ([]admission.ValidatingAdmissionHandler, error) {
-	var userCache v3.UserCache
 	var settingCache v3.SettingCache
+	var windowCache webhookv1.MaintenanceWindowCache
+	var projectCache v3.ProjectCache
+	var deniedObjectClient admission.DeniedObjectClient
+	var exceptionCache admission.PolicyExceptionLister
 	if clients.MultiClusterManagement {
-		userCache = clients.Management.User().Cache()
+		projectCache = clients.Management.Project().Cache()
 		settingCache = clients.Management.Setting().Cache()
+		windowCache = clients.Webhook.MaintenanceWindow().Cache()
+		deniedObjectClient = clients.Webhook.DeniedObject()
+		exceptionCache = clients.Webhook.PolicyExceptionRequest().Cache()
 	}
+	// rules are enforced by severity, denied objects aren't logged or retained, and no exceptions are honored, on
+	// downstream clusters, which have no settings, maintenance windows or policy exception requests
+	rules.ConfigureEnforcement(settingCache)
+	rules.ConfigureMaintenanceWindows(windowCache)
+	admission.ConfigureDeniedObjectLogging(settingCache)
+	admission.ConfigureDeniedObjectRetention(deniedObjectClient)
+	admission.ConfigurePolicyExceptions(exceptionCache)
+	admission.ConfigureOwners(clients.Core.Namespace().Cache())
 
 	clusters := managementCluster.NewValidator(
-		clients.K8s.AuthorizationV1().SubjectAccessReviews(),
+		clients.SubjectAccessReviews(),
 		clients.Management.PodSecurityAdmissionConfigurationTemplate().Cache(),
-		userCache,
+		clients.Identities,
 		settingCache,
+		clients.RoleTemplateResolver, // the role template resolver is nil for downstream clusters
+		clients.DefaultResolver,
 	)
 
 	handlers := []admission.ValidatingAdmissionHandler{
@@ -53,8 +83,11 @@ func Validation(clients *clients.Clients) ([]admission.ValidatingAdmissionHandle
 		clusters,
 		provisioningCluster.NewProvisioningClusterValidator(clients),
 		machineconfig.NewValidator(),
-		nshandler.NewValidator(clients.K8s.AuthorizationV1().SubjectAccessReviews()),
+		nshandler.NewValidator(clients.SubjectAccessReviews(), projectCache, clients.Core.Namespace().Cache()),
 		clusterrepo.NewValidator(),
+		// bundles are stored on the cluster running the fleet controller, which is the local cluster with Rancher
+		// and the downstream cluster with a standalone fleet
+		bundle.NewValidator(),
 	}
 
 	if clients.MultiClusterManagement {
@@ -66,14 +99,15 @@ func Validation(clients *clients.Clients) ([]admission.ValidatingAdmissionHandle
 			handlers,
 			clusterproxyconfig.NewValidator(clients.Management.ClusterProxyConfig().Cache()),
 			podsecurityadmissionconfigurationtemplate.NewValidator(clients.Management.Cluster().Cache(), clients.Provisioning.Cluster().Cache()),
-			globalrole.NewValidator(clients.DefaultResolver, grbResolvers, clients.K8s.AuthorizationV1().SubjectAccessReviews(), clients.GlobalRoleResolver),
-			globalrolebinding.NewValidator(clients.DefaultResolver, grbResolvers, clients.K8s.AuthorizationV1().SubjectAccessReviews(), clients.GlobalRoleResolver),
+			globalrole.NewValidator(clients.DefaultResolver, grbResolvers, clients.SubjectAccessReviews(), clients.GlobalRoleResolver),
+			globalrolebinding.NewValidator(clients.DefaultResolver, grbResolvers, clients.SubjectAccessReviews(), clients.GlobalRoleResolver),
 			projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, clients.DefaultResolver, clients.RoleTemplateResolver, clients.Management.Cluster().Cache(), clients.Management.Project().Cache()),
 			clusterroletemplatebinding.NewValidator(crtbResolver, clients.DefaultResolver, clients.RoleTemplateResolver, clients.Management.GlobalRoleBinding().Cache(), clients.Management.Cluster().Cache()),
-			roletemplate.NewValidator(clients.DefaultResolver, clients.RoleTemplateResolver, clients.K8s.AuthorizationV1().SubjectAccessReviews(), clients.Management.GlobalRole().Cache()),
+			roletemplate.NewValidator(clients.DefaultResolver, clients.RoleTemplateResolver, clients.SubjectAccessReviews(), clients.Management.GlobalRole().Cache()),
 			secret.NewValidator(clients.RBAC.Role().Cache(), clients.RBAC.RoleBinding().Cache()),
 			nodedriver.NewValidator(clients.Management.Node().Cache(), clients.Dynamic),
-			project.NewValidator(clients.Management.Cluster().Cache(), clients.Management.User().Cache()),
+			project.NewValidator(clients.Management.Cluster().Cache(), clients.Management.Project().Cache(), clients.Identities, clients.SubjectAccessReviews(), clients.Management.GlobalRoleBinding().Cache(), clients.Webhook.ClusterTierPolicy().Cache(), settingCache,
+				clients.Core.Namespace().Cache(), clients.ResourceQuotaCache()),
 			role.NewValidator(),
 			rolebinding.NewValidator(),
 			setting.NewValidator(clients.Management.Cluster().Cache(), clients.Management.Setting().Cache()),
@@ -81,11 +115,21 @@ func Validation(clients *clients.Clients) ([]admission.ValidatingAdmissionHandle
 			userattribute.NewValidator(),
 			clusterrole.NewValidator(),
 			clusterrolebinding.NewValidator(),
+			maintenancewindow.NewValidator(),
+			taintpolicy.NewValidator(),
+			clustertierpolicy.NewValidator(),
+			custompolicy.NewValidator(),
+			policyexceptionrequest.NewValidator(clients.SubjectAccessReviews()),
 		)
 	} else {
 		handlers = append(handlers, clusterauthtoken.NewValidator())
 	}
 
+	handlers = common.WithReservedMetadataCheck(handlers)
+	if clients.Webhook != nil {
+		// custom policies are only defined on the local cluster, after the built-in checks
+		handlers = policy.WithCustomPolicies(handlers, clients.Webhook.CustomPolicy().Cache())
+	}
 	return handlers, nil
 }
 
@@ -93,17 +137,79 @@ func Validation(clients *clients.Clients) ([]admission.ValidatingAdmissionHandle
 func Mutation(clients *clients.Clients) ([]admission.MutatingAdmissionHandler, error) {
 	mutators := []admission.MutatingAdmissionHandler{
 		provisioningCluster.NewProvisioningClusterMutator(clients.Core.Secret(), clients.Management.PodSecurityAdmissionConfigurationTemplate().Cache()),
-		managementCluster.NewManagementClusterMutator(clients.Management.PodSecurityAdmissionConfigurationTemplate().Cache()),
+		managementCluster.NewManagementClusterMutator(clients.Management.PodSecurityAdmissionConfigurationTemplate().Cache(), clients.Identities, clients.SubjectAccessReviews()),
 		fleetworkspace.NewMutator(clients),
 		&machineconfig.Mutator{},
 	}
 
 	if clients.MultiClusterManagement {
 		secrets := secret.NewMutator(clients.RBAC.Role(), clients.RBAC.RoleBinding())
-		projects := project.NewMutator(clients.Management.RoleTemplate().Cache())
+		projects := project.NewMutator(clients.Management.RoleTemplate().Cache(), clients.Management.Cluster().Cache(), clients.Identities, clients.SubjectAccessReviews())
 		grbs := globalrolebinding.NewMutator(clients.Management.GlobalRole().Cache())
-		mutators = append(mutators, secrets, projects, grbs)
+		crtbs := clusterroletemplatebinding.NewMutator()
+		mutators = append(mutators, secrets, projects, grbs, crtbs)
 	}
 
 	return mutators, nil
 }
+
+// Handlers returns the validating and mutating handlers named in enabledHandlers, or all of them if it is empty.
+// See FilterHandlers.
+func Handlers(clients *clients.Clients, enabledHandlers []string) ([]admission.ValidatingAdmissionHandler, []admission.MutatingAdmissionHandler, error) {
+	validators, err := Validation(clients)
+	if err != nil {
+		return nil, nil, err
+	}
+	mutators, err := Mutation(clients)
+	if err != nil {
+		return nil, nil, err
+	}
+	return FilterHandlers(validators, mutators, enabledHandlers)
+}
+
+// FilterHandlers returns the validators and mutators whose names are in enabled. The name of a handler is the
+// sub path of its webhook, e.g. clusters.management.cattle.io, so a name enables both the validator and the mutator
+// of a resource. All handlers are returned if enabled is empty. An error is returned if a name doesn't match any handler.
+func FilterHandlers(validators []admission.ValidatingAdmissionHandler, mutators []admission.MutatingAdmissionHandler, enabled []string) ([]admission.ValidatingAdmissionHandler, []admission.MutatingAdmissionHandler, error) {
+	if len(enabled) == 0 {
+		return validators, mutators, nil
+	}
+
+	known := map[string]bool{}
+	for _, validator := range validators {
+		known[admission.SubPath(validator.GVR())] = true
+	}
+	for _, mutator := range mutators {
+		known[admission.SubPath(mutator.GVR())] = true
+	}
+	enabledSet := map[string]bool{}
+	var unknown []string
+	for _, name := range enabled {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+		enabledSet[name] = true
+	}
+	if len(unknown) != 0 {
+		names := make([]string, 0, len(known))
+		for name := range known {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, nil, fmt.Errorf("unknown handlers [%s], available handlers are [%s]", strings.Join(unknown, ", "), strings.Join(names, ", "))
+	}
+
+	var enabledValidators []admission.ValidatingAdmissionHandler
+	for _, validator := range validators {
+		if enabledSet[admission.SubPath(validator.GVR())] {
+			enabledValidators = append(enabledValidators, validator)
+		}
+	}
+	var enabledMutators []admission.MutatingAdmissionHandler
+	for _, mutator := range mutators {
+		if enabledSet[admission.SubPath(mutator.GVR())] {
+			enabledMutators = append(enabledMutators, mutator)
+		}
+	}
+	return enabledValidators, enabledMutators, nil
+}
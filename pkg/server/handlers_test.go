@@ -0,0 +1,89 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeHandler struct {
+	gvr schema.GroupVersionResource
+}
+
+func (f *fakeHandler) GVR() schema.GroupVersionResource { return f.gvr }
+
+func (f *fakeHandler) Operations() []v1.OperationType { return nil }
+
+func (f *fakeHandler) ValidatingWebhook(_ v1.WebhookClientConfig) []v1.ValidatingWebhook { return nil }
+
+func (f *fakeHandler) MutatingWebhook(_ v1.WebhookClientConfig) []v1.MutatingWebhook { return nil }
+
+func (f *fakeHandler) Admitters() []admission.Admitter { return nil }
+
+func (f *fakeHandler) Admit(_ *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	return admission.ResponseAllowed(), nil
+}
+
+func TestFilterHandlers(t *testing.T) {
+	t.Parallel()
+	clusters := &fakeHandler{gvr: schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "clusters"}}
+	provisioningClusters := &fakeHandler{gvr: schema.GroupVersionResource{Group: "provisioning.cattle.io", Version: "v1", Resource: "clusters"}}
+	machineConfigs := &fakeHandler{gvr: schema.GroupVersionResource{Group: "rke-machine-config.cattle.io", Version: "v1", Resource: "*"}}
+	validators := []admission.ValidatingAdmissionHandler{clusters, provisioningClusters, machineConfigs}
+	mutators := []admission.MutatingAdmissionHandler{clusters, machineConfigs}
+
+	tests := []struct {
+		name           string
+		enabled        []string
+		wantValidators []admission.ValidatingAdmissionHandler
+		wantMutators   []admission.MutatingAdmissionHandler
+		wantErr        bool
+	}{
+		{
+			name:           "all handlers enabled by default",
+			wantValidators: validators,
+			wantMutators:   mutators,
+		},
+		{
+			name:           "validator and mutator enabled by the same name",
+			enabled:        []string{"clusters.management.cattle.io"},
+			wantValidators: []admission.ValidatingAdmissionHandler{clusters},
+			wantMutators:   []admission.MutatingAdmissionHandler{clusters},
+		},
+		{
+			name:           "validator only",
+			enabled:        []string{"clusters.provisioning.cattle.io"},
+			wantValidators: []admission.ValidatingAdmissionHandler{provisioningClusters},
+		},
+		{
+			name:           "group wide handler",
+			enabled:        []string{"rke-machine-config.cattle.io", "clusters.provisioning.cattle.io"},
+			wantValidators: []admission.ValidatingAdmissionHandler{provisioningClusters, machineConfigs},
+			wantMutators:   []admission.MutatingAdmissionHandler{machineConfigs},
+		},
+		{
+			name:    "unknown handler",
+			enabled: []string{"clusters.management.cattle.io", "clusters"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			gotValidators, gotMutators, err := FilterHandlers(validators, mutators, test.enabled)
+			if test.wantErr {
+				assert.ErrorContains(t, err, "clusters.provisioning.cattle.io")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantValidators, gotValidators)
+			assert.Equal(t, test.wantMutators, gotMutators)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/dynamiclistener"
+	"github.com/rancher/dynamiclistener/storage/kubernetes"
+	"github.com/rancher/dynamiclistener/storage/memory"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+)
+
+const (
+	gzipEnvKey                      = "CATTLE_WEBHOOK_GZIP"
+	http2MaxConcurrentStreamsEnvKey = "CATTLE_WEBHOOK_HTTP2_MAX_CONCURRENT_STREAMS"
+	idleTimeoutEnvKey               = "CATTLE_WEBHOOK_IDLE_TIMEOUT"
+	keepAlivesEnvKey                = "CATTLE_WEBHOOK_KEEP_ALIVES"
+	tcpKeepAlivePeriodEnvKey        = "CATTLE_WEBHOOK_TCP_KEEP_ALIVE_PERIOD"
+	shutdownDelayEnvKey             = "CATTLE_WEBHOOK_SHUTDOWN_DELAY"
+	shutdownTimeoutEnvKey           = "CATTLE_WEBHOOK_SHUTDOWN_TIMEOUT"
+
+	// defaultTCPKeepAlivePeriod matches the keep-alive period used by dynamiclistener.
+	defaultTCPKeepAlivePeriod = 3 * time.Minute
+	// defaultShutdownDelay leaves the endpoints time to stop routing requests to a terminating replica.
+	defaultShutdownDelay = 5 * time.Second
+	// defaultShutdownTimeout keeps the delay and the draining within the default termination grace period of 30s.
+	defaultShutdownTimeout = 20 * time.Second
+)
+
+// httpServerConfig holds the tuning options of the webhook's https server.
+type httpServerConfig struct {
+	// gzip enables compressing responses for clients that accept gzip encoding.
+	gzip bool
+	// http2MaxConcurrentStreams is the number of concurrent streams allowed per HTTP/2 connection. Zero uses the http2 default.
+	http2MaxConcurrentStreams uint32
+	// idleTimeout is how long an idle keep-alive connection is kept open. Zero means connections are never closed for being idle.
+	idleTimeout time.Duration
+	// keepAlivesEnabled controls whether HTTP/1.1 keep-alives are used.
+	keepAlivesEnabled bool
+	// tcpKeepAlivePeriod is the interval between TCP keep-alive probes on accepted connections.
+	tcpKeepAlivePeriod time.Duration
+	// shutdownDelay is how long the server keeps accepting requests once its context is done, while failing readiness.
+	shutdownDelay time.Duration
+	// shutdownTimeout is how long the server waits for in-flight requests to complete once it stopped accepting new
+	// ones, before closing their connections.
+	shutdownTimeout time.Duration
+}
+
+// httpServerConfigFromEnv reads the https server tuning options from the environment.
+func httpServerConfigFromEnv() (httpServerConfig, error) {
+	config := httpServerConfig{
+		keepAlivesEnabled:  true,
+		tcpKeepAlivePeriod: defaultTCPKeepAlivePeriod,
+		shutdownDelay:      defaultShutdownDelay,
+		shutdownTimeout:    defaultShutdownTimeout,
+	}
+	var err error
+	if value := os.Getenv(gzipEnvKey); value != "" {
+		if config.gzip, err = strconv.ParseBool(value); err != nil {
+			return config, fmt.Errorf("failed to decode %s value '%s': %w", gzipEnvKey, value, err)
+		}
+	}
+	if value := os.Getenv(http2MaxConcurrentStreamsEnvKey); value != "" {
+		streams, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return config, fmt.Errorf("failed to decode %s value '%s': %w", http2MaxConcurrentStreamsEnvKey, value, err)
+		}
+		config.http2MaxConcurrentStreams = uint32(streams)
+	}
+	if value := os.Getenv(idleTimeoutEnvKey); value != "" {
+		if config.idleTimeout, err = time.ParseDuration(value); err != nil {
+			return config, fmt.Errorf("failed to decode %s value '%s': %w", idleTimeoutEnvKey, value, err)
+		}
+	}
+	if value := os.Getenv(keepAlivesEnvKey); value != "" {
+		if config.keepAlivesEnabled, err = strconv.ParseBool(value); err != nil {
+			return config, fmt.Errorf("failed to decode %s value '%s': %w", keepAlivesEnvKey, value, err)
+		}
+	}
+	if value := os.Getenv(tcpKeepAlivePeriodEnvKey); value != "" {
+		if config.tcpKeepAlivePeriod, err = time.ParseDuration(value); err != nil {
+			return config, fmt.Errorf("failed to decode %s value '%s': %w", tcpKeepAlivePeriodEnvKey, value, err)
+		}
+	}
+	for key, duration := range map[string]*time.Duration{
+		shutdownDelayEnvKey:   &config.shutdownDelay,
+		shutdownTimeoutEnvKey: &config.shutdownTimeout,
+	} {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		if *duration, err = time.ParseDuration(value); err != nil {
+			return config, fmt.Errorf("failed to decode %s value '%s': %w", key, value, err)
+		}
+		if *duration < 0 {
+			return config, fmt.Errorf("%s value '%s' must not be negative", key, value)
+		}
+	}
+	return config, nil
+}
+
+// serveTLS serves the handler over https on the given port of the bind addresses, or of all addresses, using a dynamiclistener certificate stored in the secret
+// with the given name, and signed by the CA in caName. It mirrors dynamiclistener's server.ListenAndServe, but owns the http.Server so that HTTP/2 and
+// keep-alive behavior can be tuned.
+func serveTLS(ctx context.Context, addresses []net.IP, port int, handler http.Handler, secrets corecontrollers.SecretController, certName string, listenerConfig dynamiclistener.Config, config httpServerConfig) error {
+	listenConfig := net.ListenConfig{KeepAlive: config.tcpKeepAlivePeriod}
+	tcpListener, err := listen(ctx, listenConfig, addresses, port)
+	if err != nil {
+		return err
+	}
+
+	if len(listenerConfig.TLSConfig.NextProtos) == 0 {
+		listenerConfig.TLSConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+	}
+	caCert, caKey, err := kubernetes.LoadOrGenCAChain(secrets, namespace, caName)
+	if err != nil {
+		return fmt.Errorf("failed to load webhook CA: %w", err)
+	}
+	storage := kubernetes.Load(ctx, secrets, namespace, certName, memory.New())
+	tlsListener, certHandler, err := dynamiclistener.NewListenerWithChain(tcpListener, storage, caCert, caKey, listenerConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create TLS listener: %w", err)
+	}
+
+	if config.gzip {
+		handler = gzipHandler(handler)
+	}
+	next := handler
+	handler = dynamiclistener.HTTPRedirect(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		certHandler.ServeHTTP(rw, req)
+		next.ServeHTTP(rw, req)
+	}))
+	return serve(ctx, tlsListener, addresses, port, handler, config)
+}
+
+// serveTLSWithSource serves the handler over https on the given port of the bind addresses, or of all addresses,
+// using the serving certificate returned by the GetCertificate function of the TLS config.
+func serveTLSWithSource(ctx context.Context, addresses []net.IP, port int, handler http.Handler, tlsConfig *tls.Config, config httpServerConfig) error {
+	listenConfig := net.ListenConfig{KeepAlive: config.tcpKeepAlivePeriod}
+	tcpListener, err := listen(ctx, listenConfig, addresses, port)
+	if err != nil {
+		return err
+	}
+	if len(tlsConfig.NextProtos) == 0 {
+		tlsConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+	}
+	if config.gzip {
+		handler = gzipHandler(handler)
+	}
+	return serve(ctx, tls.NewListener(tcpListener, tlsConfig), addresses, port, handler, config)
+}
+
+// serve serves the handler on the TLS listener until the context is done, and then drains the server, see
+// gracefulShutdown.
+func serve(ctx context.Context, tlsListener net.Listener, addresses []net.IP, port int, handler http.Handler, config httpServerConfig) error {
+	tlsServer := &http.Server{
+		Handler:     handler,
+		IdleTimeout: config.idleTimeout,
+		// the requests in flight when the context is done are drained rather than canceled
+		BaseContext: func(_ net.Listener) context.Context {
+			return context.WithoutCancel(ctx)
+		},
+		ErrorLog: log.New(logrus.StandardLogger().WriterLevel(logrus.ErrorLevel), "", log.LstdFlags),
+	}
+	tlsServer.SetKeepAlivesEnabled(config.keepAlivesEnabled)
+	err := http2.ConfigureServer(tlsServer, &http2.Server{
+		MaxConcurrentStreams: config.http2MaxConcurrentStreams,
+		IdleTimeout:          config.idleTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure HTTP/2: %w", err)
+	}
+
+	go func() {
+		logrus.Infof("Listening on %s", listenDescription(addresses, port))
+		err := tlsServer.Serve(tlsListener)
+		if err != http.ErrServerClosed && err != nil {
+			logrus.Fatalf("https server failed: %v", err)
+		}
+	}()
+	shutdown.drain(ctx, tlsServer, config)
+	return nil
+}
+
+// listenDescription describes the addresses the webhook listens on, for the logs.
+func listenDescription(addresses []net.IP, port int) string {
+	if len(addresses) == 0 {
+		return fmt.Sprintf(":%d", port)
+	}
+	described := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		described = append(described, net.JoinHostPort(address.String(), strconv.Itoa(port)))
+	}
+	return strings.Join(described, ", ")
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// gzipHandler compresses responses for requests that accept gzip encoding.
+func gzipHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !acceptsGzip(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		gz := gzipWriterPool.Get().(*gzip.Writer)
+		defer gzipWriterPool.Put(gz)
+		gz.Reset(rw)
+		defer gz.Close()
+
+		rw.Header().Set("Content-Encoding", "gzip")
+		rw.Header().Add("Vary", "Accept-Encoding")
+		next.ServeHTTP(&gzipResponseWriter{ResponseWriter: rw, writer: gz}, req)
+	})
+}
+
+// acceptsGzip returns true if the request's Accept-Encoding header allows gzip.
+func acceptsGzip(req *http.Request) bool {
+	for _, encoding := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.SplitN(encoding, ";", 2)[0]) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter writes the response body through a gzip.Writer.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer *gzip.Writer
+}
+
+// WriteHeader drops the Content-Length header, which no longer matches the compressed body.
+func (g *gzipResponseWriter) WriteHeader(statusCode int) {
+	g.Header().Del("Content-Length")
+	g.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write compresses the given bytes into the response.
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	g.Header().Del("Content-Length")
+	return g.writer.Write(b)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServerConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    httpServerConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: httpServerConfig{
+				keepAlivesEnabled:  true,
+				tcpKeepAlivePeriod: defaultTCPKeepAlivePeriod,
+				shutdownDelay:      defaultShutdownDelay,
+				shutdownTimeout:    defaultShutdownTimeout,
+			},
+		},
+		{
+			name: "all options set",
+			env: map[string]string{
+				gzipEnvKey:                      "true",
+				http2MaxConcurrentStreamsEnvKey: "500",
+				idleTimeoutEnvKey:               "90s",
+				keepAlivesEnvKey:                "false",
+				tcpKeepAlivePeriodEnvKey:        "30s",
+				shutdownDelayEnvKey:             "0s",
+				shutdownTimeoutEnvKey:           "45s",
+			},
+			want: httpServerConfig{
+				gzip:                      true,
+				http2MaxConcurrentStreams: 500,
+				idleTimeout:               90 * time.Second,
+				keepAlivesEnabled:         false,
+				tcpKeepAlivePeriod:        30 * time.Second,
+				shutdownTimeout:           45 * time.Second,
+			},
+		},
+		{
+			name:    "invalid gzip",
+			env:     map[string]string{gzipEnvKey: "yes please"},
+			wantErr: true,
+		},
+		{
+			name:    "negative max concurrent streams",
+			env:     map[string]string{http2MaxConcurrentStreamsEnvKey: "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid idle timeout",
+			env:     map[string]string{idleTimeoutEnvKey: "90"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid keep-alives",
+			env:     map[string]string{keepAlivesEnvKey: "off"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid tcp keep-alive period",
+			env:     map[string]string{tcpKeepAlivePeriodEnvKey: "often"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid shutdown delay",
+			env:     map[string]string{shutdownDelayEnvKey: "5"},
+			wantErr: true,
+		},
+		{
+			name:    "negative shutdown timeout",
+			env:     map[string]string{shutdownTimeoutEnvKey: "-1s"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, key := range []string{gzipEnvKey, http2MaxConcurrentStreamsEnvKey, idleTimeoutEnvKey, keepAlivesEnvKey, tcpKeepAlivePeriodEnvKey,
+				shutdownDelayEnvKey, shutdownTimeoutEnvKey} {
+				t.Setenv(key, test.env[key])
+			}
+			got, err := httpServerConfigFromEnv()
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestGzipHandler(t *testing.T) {
+	t.Parallel()
+	const body = `{"response":{"allowed":true}}`
+	handler := gzipHandler(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Length", "29")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(body))
+	}))
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{
+			name: "no accept-encoding",
+		},
+		{
+			name:           "gzip",
+			acceptEncoding: "gzip",
+			wantGzip:       true,
+		},
+		{
+			name:           "gzip with quality",
+			acceptEncoding: "deflate, gzip;q=0.8",
+			wantGzip:       true,
+		},
+		{
+			name:           "other encoding",
+			acceptEncoding: "br",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodPost, "/v1/webhook/validation", nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			resp := recorder.Result()
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			if !test.wantGzip {
+				assert.Empty(t, resp.Header.Get("Content-Encoding"))
+				got, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, body, string(got))
+				return
+			}
+			assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+			assert.Empty(t, resp.Header.Get("Content-Length"))
+			reader, err := gzip.NewReader(resp.Body)
+			require.NoError(t, err)
+			got, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(got))
+		})
+	}
+}
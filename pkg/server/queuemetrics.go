@@ -0,0 +1,43 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/component-base/metrics/legacyregistry"
+	// registers the provider of the work queue metrics, which must be set before the controllers create their queues
+	_ "k8s.io/component-base/metrics/prometheus/workqueue"
+)
+
+// workQueueMetricsPrefix is the prefix of the work queue metrics in the Kubernetes registry.
+const workQueueMetricsPrefix = "workqueue_"
+
+// workQueueGatherer gathers the metrics of the controllers' work queues from the Kubernetes registry, where the
+// client-go work queues report them, leaving out the other metrics of that registry, e.g. the Go runtime metrics
+// already gathered by the default registry.
+//
+// The informer event handlers of the webhook don't run on the goroutines serving admission requests: each controller
+// queues the keys of changed objects on its own rate limited work queue, which coalesces repeated events for the same
+// object, and handles them on its workers. An event storm only grows the depth of these queues, which these metrics
+// expose, e.g. as workqueue_depth{name="secrets"}.
+type workQueueGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Gather returns the work queue metric families.
+func (w workQueueGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := w.gatherer.Gather()
+	var queueFamilies []*dto.MetricFamily
+	for _, family := range families {
+		if strings.HasPrefix(family.GetName(), workQueueMetricsPrefix) {
+			queueFamilies = append(queueFamilies, family)
+		}
+	}
+	return queueFamilies, err
+}
+
+// metricsGatherer gathers the metrics served on the metrics endpoint: the webhook's metrics and the work queue metrics.
+func metricsGatherer() prometheus.Gatherer {
+	return prometheus.Gatherers{prometheus.DefaultGatherer, workQueueGatherer{gatherer: legacyregistry.DefaultGatherer}}
+}
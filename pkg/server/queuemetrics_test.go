@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestWorkQueueMetrics(t *testing.T) {
+	t.Parallel()
+	queue := workqueue.NewTypedRateLimitingQueueWithConfig(workqueue.DefaultTypedControllerRateLimiter[string](),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "test-queue-metrics"})
+	defer queue.ShutDown()
+	// repeated events for the same key are coalesced
+	queue.Add("cattle-system/secret")
+	queue.Add("cattle-system/secret")
+	queue.Add("cattle-system/other")
+
+	families, err := metricsGatherer().Gather()
+	require.NoError(t, err)
+	depth := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "workqueue_depth" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "name" {
+					depth[label.GetValue()] = metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	assert.Equal(t, float64(2), depth["test-queue-metrics"])
+}
+
+func TestWorkQueueGathererFiltersMetrics(t *testing.T) {
+	t.Parallel()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGauge(prometheus.GaugeOpts{Name: "workqueue_depth", Help: "depth"}),
+		prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines", Help: "goroutines"}),
+	)
+	families, err := workQueueGatherer{gatherer: registry}.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "workqueue_depth", families[0].GetName())
+}
@@ -12,37 +12,56 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rancher/dynamiclistener"
-	"github.com/rancher/dynamiclistener/server"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/cache"
+	"github.com/rancher/webhook/pkg/certs"
 	"github.com/rancher/webhook/pkg/clients"
+	"github.com/rancher/webhook/pkg/config"
+	"github.com/rancher/webhook/pkg/faults"
 	"github.com/rancher/webhook/pkg/health"
+	managementCluster "github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/cluster"
+	"github.com/rancher/webhook/pkg/rules"
 	admissionregistration "github.com/rancher/wrangler/v3/pkg/generated/controllers/admissionregistration.k8s.io/v1"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 )
 
 const (
 	serviceName             = "rancher-webhook"
 	namespace               = "cattle-system"
-	tlsName                 = "rancher-webhook.cattle-system.svc"
 	certName                = "cattle-webhook-tls"
 	caName                  = "cattle-webhook-ca"
 	validationPath          = "/v1/webhook/validation"
 	mutationPath            = "/v1/webhook/mutation"
 	clientPort              = int32(443)
-	webhookHTTPPort         = 0 // value of 0 indicates we do not want to use http.
 	defaultWebhookHTTPSPort = 9443
 	webhookPortEnvKey       = "CATTLE_PORT"
 	webhookURLEnvKey        = "CATTLE_WEBHOOK_URL"
 	allowedCNsEnv           = "ALLOWED_CNS"
+	metricsPath             = "/metrics"
+	// denialConditionsEnvKey enables setting the WebhookDenied condition on the objects whose requests are repeatedly
+	// denied.
+	denialConditionsEnvKey = "CATTLE_WEBHOOK_DENIAL_CONDITIONS"
+	// importPreChecksEnvKey enables probing the kubeconfig of imported clusters and setting the ImportPreChecked
+	// condition with the result.
+	importPreChecksEnvKey = "CATTLE_WEBHOOK_IMPORT_PRECHECKS"
+	// webhookConfigurationName is the name of both the validating and the mutating webhook configuration.
+	webhookConfigurationName = "rancher.cattle.io"
+	// applyRetryInterval is how long failing to apply the webhook configurations trusting the CA bundle of a
+	// certificate source waits before it's retried.
+	applyRetryInterval = 30 * time.Second
 )
 
 var caFile = filepath.Join(os.TempDir(), "k8s-webhook-server", "client-ca", "ca.crt")
@@ -62,7 +81,23 @@ var tlsOpt = func(config *tls.Config) {
 }
 
 // ListenAndServe starts the webhook server.
-func ListenAndServe(ctx context.Context, cfg *rest.Config, mcmEnabled bool) error {
+// Only the handlers named in enabledHandlers are served, or all of them if it is empty. See FilterHandlers.
+// The webhooks are only called for the objects of the given shard, and the serving certificate is provided as
+// configured by certOpts.
+func ListenAndServe(ctx context.Context, cfg *rest.Config, mcmEnabled bool, enabledHandlers []string, shard Shard, certOpts certs.Options) error {
+	if err := checkRulesetHash(); err != nil {
+		return err
+	}
+	if devURL, ok := os.LookupEnv(webhookURLEnvKey); ok {
+		if err := CheckWebhookURL(devURL); err != nil {
+			return fmt.Errorf("invalid %s value '%s': %w", webhookURLEnvKey, devURL, err)
+		}
+	}
+	if faults.Enabled {
+		logrus.Warnf("Built with fault injection, faults are configured with the %s endpoint of the %s port", faults.Path, debugPortEnvKey)
+		cfg = rest.CopyConfig(cfg)
+		cfg.Wrap(faults.WrapTransport)
+	}
 	clients, err := clients.New(ctx, cfg, mcmEnabled)
 	if err != nil {
 		return fmt.Errorf("failed to create a new client: %w", err)
@@ -75,17 +110,16 @@ func ListenAndServe(ctx context.Context, cfg *rest.Config, mcmEnabled bool) erro
 		logrus.Infof("[ListenAndServe] could not set certificate expiration days via environment variable: %v", err)
 	}
 
-	validators, err := Validation(clients)
+	validators, mutators, err := Handlers(clients, enabledHandlers)
 	if err != nil {
 		return err
 	}
-
-	mutators, err := Mutation(clients)
-	if err != nil {
-		return err
+	logrus.Infof("Serving %d validating and %d mutating webhooks", len(validators), len(mutators))
+	if shard.Name != "" {
+		logrus.Infof("Serving shard %s for the objects matching %s", shard.Name, metav1.FormatLabelSelector(shard.Selector))
 	}
 
-	if err = listenAndServe(ctx, clients, validators, mutators); err != nil {
+	if err = listenAndServe(ctx, clients, validators, mutators, shard, certOpts); err != nil {
 		return err
 	}
 
@@ -107,32 +141,162 @@ func setCertificateExpirationDays() error {
 	return nil
 }
 
-func listenAndServe(ctx context.Context, clients *clients.Clients, validators []admission.ValidatingAdmissionHandler, mutators []admission.MutatingAdmissionHandler) (rErr error) {
+// enabledFromEnv returns whether the optional feature toggled by the environment variable is enabled. Optional
+// features are disabled by default.
+func enabledFromEnv(key string) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode %s value '%s': %w", key, value, err)
+	}
+	return enabled, nil
+}
+
+func listenAndServe(ctx context.Context, clients *clients.Clients, validators []admission.ValidatingAdmissionHandler, mutators []admission.MutatingAdmissionHandler, shard Shard, certOpts certs.Options) (rErr error) {
+	// the certificate source is nil in secret mode, where the certificates are stored in secrets by dynamiclistener
+	certOpts.SecretNamespace = namespace
+	certSource, err := certs.New(ctx, certOpts, []string{shard.tlsName()}, clients.Core.Secret())
+	if err != nil {
+		return fmt.Errorf("failed to load the serving certificate: %w", err)
+	}
 	router := mux.NewRouter()
 	errChecker := health.NewErrorChecker("Config Applied")
 	health.RegisterHealthCheckers(router, errChecker)
+	health.RegisterReadinessCheckers(router, errChecker, healthz.NamedCheck("Caches Synced", clients.CheckCachesSynced), certificates, shutdown)
+	// requests reaching the webhook before its caches synced are denied with a retry-after hint instead of reviewed
+	admission.ConfigureWarmUp(func() error { return clients.CheckCachesSynced(nil) })
+	rules.RegisterHandlers(router)
+	admission.RegisterDenialCatalogHandlers(router)
+	watchdogConfig, err := watchdogConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	requestWatchdog := newWatchdog(watchdogConfig)
+	debugPort, err := debugPortFromEnv()
+	if err != nil {
+		return err
+	}
+	if debugPort != 0 {
+		debugRouter := mux.NewRouter()
+		admission.RegisterDenialHandlers(debugRouter)
+		faults.RegisterHandlers(debugRouter)
+		cache.RegisterHandlers(debugRouter)
+		requestWatchdog.RegisterHandlers(debugRouter)
+		if err := serveDebug(ctx, debugPort, debugRouter); err != nil {
+			return err
+		}
+	}
+	go admission.SummarizeDenials(ctx)
+	go admission.ExportDecisions(ctx)
+	go admission.RetainDeniedObjects(ctx)
+	auditLog, err := admission.AuditLogFromEnv()
+	if err != nil {
+		return err
+	}
+	admission.StartAuditLog(ctx, auditLog)
+	externalPolicies, err := admission.ExternalPoliciesFromEnv()
+	if err != nil {
+		return err
+	}
+	if err := admission.ConfigureExternalPolicies(externalPolicies); err != nil {
+		return err
+	}
+	auditMode, err := admission.AuditModeFromEnv()
+	if err != nil {
+		return err
+	}
+	admission.ConfigureAuditMode(auditMode)
+	rateLimits, err := admission.RateLimitsFromEnv()
+	if err != nil {
+		return err
+	}
+	admission.ConfigureRateLimits(rateLimits)
+	admission.ConfigurePrefetch(clients.SARPrefetcher.PrefetchRequest)
+	denialConditions, err := enabledFromEnv(denialConditionsEnvKey)
+	if err != nil {
+		return err
+	}
+	if denialConditions {
+		dynamicClient, err := dynamic.NewForConfig(clients.RESTConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create the client of the denial conditions: %w", err)
+		}
+		go admission.WriteDenialConditions(ctx, dynamicClient)
+	}
+	router.Handle(metricsPath, promhttp.HandlerFor(metricsGatherer(), promhttp.HandlerOpts{})).Methods(http.MethodGet)
+	caDistributor := &caDistributor{configMaps: clients.Core.ConfigMap()}
+	router.Handle(caBundlePath, caDistributor).Methods(http.MethodGet)
 	router.Use(certAuth())
 
 	logrus.Debug("Creating Webhook routes")
 	for _, webhook := range validators {
-		route := router.HandleFunc(admission.Path(validationPath, webhook), admission.NewValidatingHandlerFunc(webhook))
+		name := admission.SubPath(webhook.GVR())
+		route := router.Handle(admission.Path(validationPath, webhook), requestWatchdog.Handler("validation/"+name, resetFunc(webhook),
+			faults.Handler(name, admission.NewValidatingHandlerFunc(webhook))))
 		path, _ := route.GetPathTemplate()
 		logrus.Debugf("creating route: %s", path)
 	}
 	for _, webhook := range mutators {
-		route := router.HandleFunc(admission.Path(mutationPath, webhook), admission.NewMutatingHandlerFunc(webhook))
+		name := admission.SubPath(webhook.GVR())
+		route := router.Handle(admission.Path(mutationPath, webhook), requestWatchdog.Handler("mutation/"+name, resetFunc(webhook),
+			faults.Handler(name, admission.NewMutatingHandlerFunc(webhook))))
 		path, _ := route.GetPathTemplate()
 		logrus.Debugf("creating route: %s", path)
 	}
 
+	webhookConfig := config.NewWatcher()
 	handler := &secretHandler{
 		validators:           validators,
 		mutators:             mutators,
+		shard:                shard,
+		config:               webhookConfig,
 		errChecker:           errChecker,
 		validatingController: clients.Admission.ValidatingWebhookConfiguration(),
 		mutatingController:   clients.Admission.MutatingWebhookConfiguration(),
 	}
-	clients.Core.Secret().OnChange(ctx, "secrets", handler.sync)
+	webhookConfig.OnChange(handler.reconfigure)
+	if err := webhookConfig.WatchFromEnv(ctx, clients.Core.ConfigMap()); err != nil {
+		return err
+	}
+	clients.Core.Secret().OnChange(ctx, "decision-export", admission.SyncDecisionExport)
+	certExpiryWarning, err := certExpiryWarningFromEnv()
+	if err != nil {
+		return err
+	}
+	certificates.configure(shard.certName(), certExpiryWarning)
+	if certSource == nil {
+		clients.Core.Secret().OnChange(ctx, "secrets", handler.sync)
+		clients.Core.Secret().OnChange(ctx, "ca-distribution", caDistributor.sync)
+		clients.Core.Secret().OnChange(ctx, "certificate-metrics", certificates.sync)
+	} else {
+		certSource.OnChange(func(caBundle []byte) {
+			handler.rotate(caBundle)
+			if err := caDistributor.setCABundle(caBundle); err != nil {
+				logrus.Errorf("[caDistributor] failed to publish the webhook's CA: %v", err)
+			}
+		})
+		certSource.OnServe(certificates.record)
+		certificates.record(certSource.Certificates())
+		go handler.start(ctx, certSource)
+		go func() {
+			if err := caDistributor.setCABundle(certSource.CABundle()); err != nil {
+				logrus.Errorf("[caDistributor] failed to publish the webhook's CA: %v", err)
+			}
+		}()
+		go certSource.Run(ctx)
+	}
+	go certificates.run(ctx)
+	importPreChecks, err := enabledFromEnv(importPreChecksEnvKey)
+	if err != nil {
+		return err
+	}
+	if importPreChecks && clients.MultiClusterManagement {
+		clients.Management.Cluster().OnChange(ctx, "import-prechecks", managementCluster.NewImportPreChecker(clients.Management.Cluster()).Sync)
+	}
+	registerVersionHandler(router, newVersionInfo(featureGates(clients.MultiClusterManagement, denialConditions, importPreChecks, watchdogConfig)))
 
 	defer func() {
 		if rErr != nil {
@@ -151,28 +315,49 @@ func listenAndServe(ctx context.Context, clients *clients.Clients, validators []
 			return fmt.Errorf("failed to decode webhook port value '%s': %w", portStr, err)
 		}
 	}
-	return server.ListenAndServe(ctx, webhookHTTPSPort, webhookHTTPPort, router, &server.ListenOpts{
-		Secrets:       clients.Core.Secret(),
-		CertNamespace: namespace,
-		CertName:      certName,
-		CAName:        caName,
-		TLSListenerConfig: dynamiclistener.Config{
-			SANs: []string{
-				tlsName,
-			},
-			FilterCN:  dynamiclistener.OnlyAllow(tlsName),
-			TLSConfig: tlsConfig,
+	serverConfig, err := httpServerConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	bindAddresses, err := bindAddressesFromEnv()
+	if err != nil {
+		return err
+	}
+	caBundleCheckInterval, err := caBundleCheckIntervalFromEnv()
+	if err != nil {
+		return err
+	}
+	if caBundleCheckInterval > 0 {
+		checker := newCABundleChecker(localAddress(bindAddresses, webhookHTTPSPort), shard, clients.Core.Secret().Cache(), certSource, clients.Admission.ValidatingWebhookConfiguration(), clients.Admission.MutatingWebhookConfiguration())
+		go checker.run(ctx, caBundleCheckInterval)
+	}
+	if certSource != nil {
+		tlsConfig.GetCertificate = certSource.GetCertificate
+		return serveTLSWithSource(ctx, bindAddresses, webhookHTTPSPort, router, tlsConfig, serverConfig)
+	}
+	return serveTLS(ctx, bindAddresses, webhookHTTPSPort, router, clients.Core.Secret(), shard.certName(), dynamiclistener.Config{
+		SANs: []string{
+			shard.tlsName(),
 		},
-		DisplayServerLogs: true,
-	})
+		FilterCN:  dynamiclistener.OnlyAllow(shard.tlsName()),
+		TLSConfig: tlsConfig,
+	}, serverConfig)
 }
 
 type secretHandler struct {
-	validators           []admission.ValidatingAdmissionHandler
-	mutators             []admission.MutatingAdmissionHandler
+	validators []admission.ValidatingAdmissionHandler
+	mutators   []admission.MutatingAdmissionHandler
+	shard      Shard
+	// config holds the webhook configuration applied to the webhooks of the handlers.
+	config               *config.Watcher
 	errChecker           *health.ErrorChecker
 	validatingController admissionregistration.ValidatingWebhookConfigurationClient
 	mutatingController   admissionregistration.MutatingWebhookConfigurationClient
+
+	// mutex serializes applying the webhook configurations.
+	mutex sync.Mutex
+	// caBundle is the CA the applied webhook configurations trust, nil until the CA is ready.
+	caBundle []byte
 }
 
 // sync updates the validating admission configuration whenever the TLS cert changes.
@@ -185,24 +370,94 @@ func (s *secretHandler) sync(_ string, secret *corev1.Secret) (*corev1.Secret, e
 	// Sleep here to make sure server is listening and all caches are primed
 	time.Sleep(15 * time.Second)
 
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.caBundle = secret.Data[corev1.TLSCertKey]
+	return secret, s.apply(s.config.Current())
+}
+
+// start applies the webhook configurations trusting the CA bundle of the certificate source, once the server is
+// listening and the caches are primed, like sync does for the CA secret. Failures are retried until they succeed.
+func (s *secretHandler) start(ctx context.Context, source certs.Source) {
+	logrus.Info("Sleeping for 15 seconds then applying webhook config")
+	for delay := 15 * time.Second; ; delay = applyRetryInterval {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		s.mutex.Lock()
+		s.caBundle = source.CABundle()
+		err := s.apply(s.config.Current())
+		s.mutex.Unlock()
+		if err == nil {
+			return
+		}
+	}
+}
+
+// rotate applies the webhook configurations again with the rotated CA bundle of the certificate source. Nothing is
+// applied before start first applied them, as start reads the current CA bundle. A failure is left to the caBundle
+// check to repair.
+func (s *secretHandler) rotate(caBundle []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.caBundle == nil {
+		return
+	}
+	s.caBundle = caBundle
+	_ = s.apply(s.config.Current())
+}
+
+// reconfigure applies the webhook configurations again with the changed webhook configuration. Nothing is applied
+// before the CA is ready, the configurations are applied with the current webhook configuration once it is.
+func (s *secretHandler) reconfigure(webhookConfig *config.Config) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.caBundle == nil {
+		return
+	}
+	_ = s.apply(webhookConfig)
+}
+
+// apply ensures the webhook configurations registering the handlers with the given webhook configuration, and records
+// the outcome in the health check.
+func (s *secretHandler) apply(webhookConfig *config.Config) error {
+	validatingConfig, mutatingConfig := WebhookConfigurations(s.validators, s.mutators, s.caBundle, s.shard, webhookConfig)
+	err := s.ensureWebhookConfiguration(validatingConfig, mutatingConfig)
+	if err != nil {
+		logrus.Errorf("Failed to ensure configuration: %s", err.Error())
+	} else {
+		configurationApplied.SetToCurrentTime()
+	}
+
+	s.errChecker.Store(err)
+	return err
+}
+
+// WebhookConfigurations returns the validating and mutating webhook configurations registering the given handlers.
+// The webhooks call the rancher-webhook service of the shard and trust the given CA bundle, unless CATTLE_WEBHOOK_URL
+// is set, and are restricted to the objects of the shard. The webhook configuration, which may be nil, disables the
+// webhooks of handlers and overrides their settings.
+func WebhookConfigurations(validators []admission.ValidatingAdmissionHandler, mutators []admission.MutatingAdmissionHandler, caBundle []byte, shard Shard, webhookConfig *config.Config) (*v1.ValidatingWebhookConfiguration, *v1.MutatingWebhookConfiguration) {
 	validationClientConfig := v1.WebhookClientConfig{
 		Service: &v1.ServiceReference{
 			Namespace: namespace,
-			Name:      serviceName,
+			Name:      shard.serviceName(),
 			Path:      admission.Ptr(validationPath),
 			Port:      admission.Ptr(clientPort),
 		},
-		CABundle: secret.Data[corev1.TLSCertKey],
+		CABundle: caBundle,
 	}
 
 	mutationClientConfig := v1.WebhookClientConfig{
 		Service: &v1.ServiceReference{
 			Namespace: namespace,
-			Name:      serviceName,
+			Name:      shard.serviceName(),
 			Path:      admission.Ptr(mutationPath),
 			Port:      admission.Ptr(clientPort),
 		},
-		CABundle: secret.Data[corev1.TLSCertKey],
+		CABundle: caBundle,
 	}
 	if devURL, ok := os.LookupEnv(webhookURLEnvKey); ok {
 		validationURL := devURL + validationPath
@@ -214,34 +469,43 @@ func (s *secretHandler) sync(_ string, secret *corev1.Secret) (*corev1.Secret, e
 			URL: &mutationURL,
 		}
 	}
-	validatingWebhooks := make([]v1.ValidatingWebhook, 0, len(s.validators))
-	for _, webhook := range s.validators {
-		validatingWebhooks = append(validatingWebhooks, webhook.ValidatingWebhook(validationClientConfig)...)
+	validatingWebhooks := make([]v1.ValidatingWebhook, 0, len(validators))
+	for _, handler := range validators {
+		name := admission.SubPath(handler.GVR())
+		if !webhookConfig.ValidatingEnabled(name) {
+			continue
+		}
+		for _, webhook := range handler.ValidatingWebhook(validationClientConfig) {
+			webhookConfig.ApplyValidating(name, &webhook)
+			shard.validatingWebhook(&webhook)
+			validatingWebhooks = append(validatingWebhooks, webhook)
+		}
 	}
-	mutatingWebhooks := make([]v1.MutatingWebhook, 0, len(s.mutators))
-	for _, webhook := range s.mutators {
-		mutatingWebhooks = append(mutatingWebhooks, webhook.MutatingWebhook(mutationClientConfig)...)
+	mutatingWebhooks := make([]v1.MutatingWebhook, 0, len(mutators))
+	for _, handler := range mutators {
+		name := admission.SubPath(handler.GVR())
+		if !webhookConfig.MutatingEnabled(name) {
+			continue
+		}
+		for _, webhook := range handler.MutatingWebhook(mutationClientConfig) {
+			webhookConfig.ApplyMutating(name, &webhook)
+			shard.mutatingWebhook(&webhook)
+			mutatingWebhooks = append(mutatingWebhooks, webhook)
+		}
 	}
 	validatingConfig := &v1.ValidatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "rancher.cattle.io",
+			Name: shard.configurationName(),
 		},
 		Webhooks: validatingWebhooks,
 	}
 	mutatingConfig := &v1.MutatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "rancher.cattle.io",
+			Name: shard.configurationName(),
 		},
 		Webhooks: mutatingWebhooks,
 	}
-	err := s.ensureWebhookConfiguration(validatingConfig, mutatingConfig)
-	if err != nil {
-		logrus.Errorf("Failed to ensure configuration: %s", err.Error())
-	}
-
-	s.errChecker.Store(err)
-	return secret, err
-
+	return validatingConfig, mutatingConfig
 }
 
 // ensureWebhookConfiguration creates or updates the current validating and mutating webhook configuration to have the desired webhook.
@@ -284,7 +548,7 @@ func (s *secretHandler) ensureWebhookConfiguration(validatingConfig *v1.Validati
 
 // certAuth returns a middleware for cert-based authentication.
 // This is done as a middleware instead of using tls.RequireAndVerifyClientCert because an exception
-// needs to be made for the unauthenticated /healthz endpoint.
+// needs to be made for the unauthenticated /healthz, /readyz, /rules and /version endpoints.
 func certAuth() func(next http.Handler) http.Handler {
 	opts := getVerifyOptions()
 	allowedCNs := getAllowedCNs()
@@ -295,7 +559,19 @@ func certAuth() func(next http.Handler) http.Handler {
 				next.ServeHTTP(w, r)
 				return
 			}
-			if r.URL.Path == "/healthz" { // apiserver does not present client cert for health checks
+			if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" { // apiserver and kubelet do not present client cert for health checks
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.URL.Path == rules.Path || strings.HasPrefix(r.URL.Path, rules.Path+"/") { // rule docs are public
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.URL.Path == admission.DenialCatalogPath || r.URL.Path == admission.DenialCatalogSchemaPath { // denial code docs are public
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.URL.Path == versionPath { // checked by operators without client certs
 				next.ServeHTTP(w, r)
 				return
 			}
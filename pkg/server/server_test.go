@@ -3,6 +3,9 @@ package server
 import (
 	"testing"
 
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/config"
+	"github.com/rancher/webhook/pkg/health"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -73,3 +76,96 @@ func TestSecretHandlerEnsureWebhookConfigurationCreate(t *testing.T) {
 	require.Len(t, storedMutatingConfig.Webhooks, 1)
 	assert.Equal(t, mutatingConfig.Webhooks[0].Name, storedMutatingConfig.Webhooks[0].Name)
 }
+
+func TestWebhookConfigurationsConfig(t *testing.T) {
+	t.Parallel()
+	projects := &shardHandler{resource: "projects", scope: v1.NamespacedScope}
+	clusters := &shardHandler{resource: "clusters", scope: v1.ClusterScope}
+	webhookConfig, err := config.Parse([]byte(`webhooks:
+- handler: clusters.management.cattle.io
+  validating: false
+- handler: projects.management.cattle.io
+  failurePolicy: Ignore
+  timeoutSeconds: 3
+`))
+	require.NoError(t, err)
+
+	validatingConfig, mutatingConfig := WebhookConfigurations(
+		[]admission.ValidatingAdmissionHandler{projects, clusters},
+		[]admission.MutatingAdmissionHandler{projects, clusters},
+		[]byte("ca"), Shard{}, webhookConfig)
+	require.Len(t, validatingConfig.Webhooks, 1)
+	assert.Equal(t, "rancher.cattle.io.projects.management.cattle.io", validatingConfig.Webhooks[0].Name)
+	assert.Equal(t, v1.Ignore, *validatingConfig.Webhooks[0].FailurePolicy)
+	assert.Equal(t, int32(3), *validatingConfig.Webhooks[0].TimeoutSeconds)
+	require.Len(t, mutatingConfig.Webhooks, 2)
+	assert.Equal(t, v1.Ignore, *mutatingConfig.Webhooks[0].FailurePolicy)
+	assert.NotEqual(t, v1.Ignore, *mutatingConfig.Webhooks[1].FailurePolicy, "only the configured handler is changed")
+}
+
+func TestSecretHandlerReconfigure(t *testing.T) {
+	t.Parallel()
+	configName := "rancher.cattle.io"
+	ctrl := gomock.NewController(t)
+	var validatingWebhooks []v1.ValidatingWebhook
+	validatingController := fake.NewMockNonNamespacedClientInterface[*v1.ValidatingWebhookConfiguration, *v1.ValidatingWebhookConfigurationList](ctrl)
+	validatingController.EXPECT().Get(configName, gomock.Any()).Return(&v1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: configName}}, nil).AnyTimes()
+	validatingController.EXPECT().Update(gomock.Any()).DoAndReturn(func(obj *v1.ValidatingWebhookConfiguration) (*v1.ValidatingWebhookConfiguration, error) {
+		validatingWebhooks = obj.Webhooks
+		return obj, nil
+	}).Times(1)
+	mutatingController := fake.NewMockNonNamespacedClientInterface[*v1.MutatingWebhookConfiguration, *v1.MutatingWebhookConfigurationList](ctrl)
+	mutatingController.EXPECT().Get(configName, gomock.Any()).Return(&v1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: configName}}, nil).AnyTimes()
+	mutatingController.EXPECT().Update(gomock.Any()).Return(nil, nil).Times(1)
+
+	handler := &secretHandler{
+		validators:           []admission.ValidatingAdmissionHandler{&shardHandler{resource: "projects", scope: v1.NamespacedScope}},
+		config:               config.NewWatcher(),
+		errChecker:           health.NewErrorChecker("Config Applied"),
+		validatingController: validatingController,
+		mutatingController:   mutatingController,
+	}
+	disabled, err := config.Parse([]byte("webhooks:\n- handler: projects.management.cattle.io\n  validating: false\n"))
+	require.NoError(t, err)
+
+	// nothing is applied before the CA is ready
+	handler.reconfigure(disabled)
+	assert.Nil(t, validatingWebhooks)
+
+	handler.caBundle = []byte("ca")
+	handler.reconfigure(disabled)
+	assert.Empty(t, validatingWebhooks)
+}
+
+func TestSecretHandlerRotate(t *testing.T) {
+	t.Parallel()
+	configName := "rancher.cattle.io"
+	ctrl := gomock.NewController(t)
+	var validatingWebhooks []v1.ValidatingWebhook
+	validatingController := fake.NewMockNonNamespacedClientInterface[*v1.ValidatingWebhookConfiguration, *v1.ValidatingWebhookConfigurationList](ctrl)
+	validatingController.EXPECT().Get(configName, gomock.Any()).Return(&v1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: configName}}, nil).AnyTimes()
+	validatingController.EXPECT().Update(gomock.Any()).DoAndReturn(func(obj *v1.ValidatingWebhookConfiguration) (*v1.ValidatingWebhookConfiguration, error) {
+		validatingWebhooks = obj.Webhooks
+		return obj, nil
+	}).Times(1)
+	mutatingController := fake.NewMockNonNamespacedClientInterface[*v1.MutatingWebhookConfiguration, *v1.MutatingWebhookConfigurationList](ctrl)
+	mutatingController.EXPECT().Get(configName, gomock.Any()).Return(&v1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: configName}}, nil).AnyTimes()
+	mutatingController.EXPECT().Update(gomock.Any()).Return(nil, nil).Times(1)
+
+	handler := &secretHandler{
+		validators:           []admission.ValidatingAdmissionHandler{&shardHandler{resource: "projects", scope: v1.NamespacedScope}},
+		config:               config.NewWatcher(),
+		errChecker:           health.NewErrorChecker("Config Applied"),
+		validatingController: validatingController,
+		mutatingController:   mutatingController,
+	}
+
+	// nothing is applied before start applied the configurations
+	handler.rotate([]byte("rotated"))
+	assert.Nil(t, validatingWebhooks)
+
+	handler.caBundle = []byte("ca")
+	handler.rotate([]byte("rotated"))
+	require.Len(t, validatingWebhooks, 1)
+	assert.Equal(t, []byte("rotated"), validatingWebhooks[0].ClientConfig.CABundle)
+}
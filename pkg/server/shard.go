@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Shard restricts a webhook instance to the namespaces and cluster scoped objects matching a label selector, so that
+// admission load can be partitioned between several instances. Each shard registers its own webhook configurations,
+// served by its own service, and all shards share the webhook's CA.
+// The zero Shard handles every object, as a single instance does.
+type Shard struct {
+	// Name identifies the shard. It's appended to the names of the service, the serving certificate secret and the
+	// webhook configurations of the shard.
+	Name string
+	// Selector selects the namespaces, and the cluster scoped objects, the shard handles.
+	Selector *metav1.LabelSelector
+}
+
+// NewShard returns the shard with the given name handling the objects matching the given label selector. Both are
+// either empty, for an unsharded instance, or set.
+func NewShard(name, selector string) (Shard, error) {
+	if name == "" && selector == "" {
+		return Shard{}, nil
+	}
+	if name == "" || selector == "" {
+		return Shard{}, fmt.Errorf("a shard needs both a name and a selector")
+	}
+	if errs := validation.IsDNS1123Label(name); len(errs) != 0 {
+		return Shard{}, fmt.Errorf("invalid shard name %q: %v", name, errs)
+	}
+	labelSelector, err := metav1.ParseToLabelSelector(selector)
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard selector %q: %w", selector, err)
+	}
+	return Shard{Name: name, Selector: labelSelector}, nil
+}
+
+func (s Shard) suffix(name string) string {
+	if s.Name == "" {
+		return name
+	}
+	return name + "-" + s.Name
+}
+
+// serviceName is the name of the service the webhooks of the shard call.
+func (s Shard) serviceName() string {
+	return s.suffix(serviceName)
+}
+
+// tlsName is the name the serving certificate of the shard is valid for.
+func (s Shard) tlsName() string {
+	return s.serviceName() + "." + namespace + ".svc"
+}
+
+// certName is the name of the secret holding the serving certificate of the shard.
+func (s Shard) certName() string {
+	return s.suffix(certName)
+}
+
+// configurationName is the name of the validating and mutating webhook configurations of the shard.
+func (s Shard) configurationName() string {
+	return s.suffix(webhookConfigurationName)
+}
+
+// validatingWebhook restricts the webhook to the objects of the shard.
+func (s Shard) validatingWebhook(webhook *v1.ValidatingWebhook) {
+	if s.Selector == nil {
+		return
+	}
+	webhook.NamespaceSelector = mergeSelectors(webhook.NamespaceSelector, s.Selector)
+	if clusterScoped(webhook.Rules) {
+		webhook.ObjectSelector = mergeSelectors(webhook.ObjectSelector, s.Selector)
+	}
+}
+
+// mutatingWebhook restricts the webhook to the objects of the shard.
+func (s Shard) mutatingWebhook(webhook *v1.MutatingWebhook) {
+	if s.Selector == nil {
+		return
+	}
+	webhook.NamespaceSelector = mergeSelectors(webhook.NamespaceSelector, s.Selector)
+	if clusterScoped(webhook.Rules) {
+		webhook.ObjectSelector = mergeSelectors(webhook.ObjectSelector, s.Selector)
+	}
+}
+
+// clusterScoped returns true if the rules only match cluster scoped objects, which namespaceSelectors don't restrict.
+// Namespaces are left out, namespaceSelectors apply to them.
+func clusterScoped(rules []v1.RuleWithOperations) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	for _, rule := range rules {
+		if rule.Scope == nil || *rule.Scope != v1.ClusterScope {
+			return false
+		}
+		for _, resource := range rule.Resources {
+			if resource == "namespaces" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// mergeSelectors returns a selector matching the objects matched by both selectors.
+func mergeSelectors(selector, shard *metav1.LabelSelector) *metav1.LabelSelector {
+	merged := &metav1.LabelSelector{}
+	if selector != nil {
+		merged = selector.DeepCopy()
+	}
+	for key, value := range shard.MatchLabels {
+		if current, ok := merged.MatchLabels[key]; ok && current != value {
+			// both labels can't match, which a requirement on both values expresses
+			merged.MatchExpressions = append(merged.MatchExpressions,
+				metav1.LabelSelectorRequirement{Key: key, Operator: metav1.LabelSelectorOpIn, Values: []string{value}})
+			continue
+		}
+		if merged.MatchLabels == nil {
+			merged.MatchLabels = map[string]string{}
+		}
+		merged.MatchLabels[key] = value
+	}
+	for _, requirement := range shard.MatchExpressions {
+		merged.MatchExpressions = append(merged.MatchExpressions, *requirement.DeepCopy())
+	}
+	return merged
+}
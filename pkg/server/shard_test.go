@@ -0,0 +1,120 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNewShard(t *testing.T) {
+	t.Parallel()
+	shard, err := NewShard("", "")
+	require.NoError(t, err)
+	assert.Equal(t, Shard{}, shard)
+	assert.Equal(t, "rancher-webhook", shard.serviceName())
+	assert.Equal(t, "rancher-webhook.cattle-system.svc", shard.tlsName())
+	assert.Equal(t, "cattle-webhook-tls", shard.certName())
+	assert.Equal(t, "rancher.cattle.io", shard.configurationName())
+
+	shard, err = NewShard("a", "shard=a")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"shard": "a"}, shard.Selector.MatchLabels)
+	assert.Equal(t, "rancher-webhook-a", shard.serviceName())
+	assert.Equal(t, "rancher-webhook-a.cattle-system.svc", shard.tlsName())
+	assert.Equal(t, "cattle-webhook-tls-a", shard.certName())
+	assert.Equal(t, "rancher.cattle.io-a", shard.configurationName())
+
+	for _, args := range [][2]string{{"a", ""}, {"", "shard=a"}, {"A_1", "shard=a"}, {"a", "shard in a"}} {
+		_, err = NewShard(args[0], args[1])
+		assert.Error(t, err, args)
+	}
+}
+
+func TestWebhookConfigurationsShard(t *testing.T) {
+	t.Parallel()
+	shard, err := NewShard("default", "shard notin (a,b)")
+	require.NoError(t, err)
+	namespaced := &shardHandler{resource: "projects", scope: v1.NamespacedScope}
+	clusterScoped := &shardHandler{resource: "clusters", scope: v1.ClusterScope, objectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"owned": "true"}}}
+	namespaces := &shardHandler{resource: "namespaces", scope: v1.ClusterScope}
+
+	validatingConfig, mutatingConfig := WebhookConfigurations(
+		[]admission.ValidatingAdmissionHandler{namespaced, clusterScoped, namespaces},
+		[]admission.MutatingAdmissionHandler{clusterScoped},
+		[]byte("ca"), shard, nil)
+	assert.Equal(t, "rancher.cattle.io-default", validatingConfig.Name)
+	assert.Equal(t, "rancher.cattle.io-default", mutatingConfig.Name)
+	require.Len(t, validatingConfig.Webhooks, 3)
+	require.Len(t, mutatingConfig.Webhooks, 1)
+	assert.Equal(t, "rancher-webhook-default", validatingConfig.Webhooks[0].ClientConfig.Service.Name)
+
+	matches := func(selector *metav1.LabelSelector, set labels.Set) bool {
+		if selector == nil {
+			return true
+		}
+		parsed, err := metav1.LabelSelectorAsSelector(selector)
+		require.NoError(t, err)
+		return parsed.Matches(set)
+	}
+	for _, webhook := range validatingConfig.Webhooks {
+		assert.True(t, matches(webhook.NamespaceSelector, labels.Set{}), webhook.Name)
+		assert.True(t, matches(webhook.NamespaceSelector, labels.Set{"shard": "c"}), webhook.Name)
+		assert.False(t, matches(webhook.NamespaceSelector, labels.Set{"shard": "a"}), webhook.Name)
+	}
+	// only cluster scoped objects other than namespaces are selected by their own labels
+	assert.Nil(t, validatingConfig.Webhooks[0].ObjectSelector)
+	assert.Nil(t, validatingConfig.Webhooks[2].ObjectSelector)
+	clusterSelector := mutatingConfig.Webhooks[0].ObjectSelector
+	assert.Equal(t, clusterSelector, validatingConfig.Webhooks[1].ObjectSelector)
+	assert.True(t, matches(clusterSelector, labels.Set{"owned": "true"}))
+	assert.False(t, matches(clusterSelector, labels.Set{"owned": "true", "shard": "b"}))
+	assert.False(t, matches(clusterSelector, labels.Set{}), "the handler's own selector still applies")
+	assert.Equal(t, map[string]string{"owned": "true"}, clusterScoped.objectSelector.MatchLabels, "the handler's selector isn't modified")
+}
+
+func TestMergeSelectors(t *testing.T) {
+	t.Parallel()
+	merged := mergeSelectors(&metav1.LabelSelector{MatchLabels: map[string]string{"shard": "a"}}, &metav1.LabelSelector{MatchLabels: map[string]string{"shard": "b"}})
+	selector, err := metav1.LabelSelectorAsSelector(merged)
+	require.NoError(t, err)
+	assert.False(t, selector.Matches(labels.Set{"shard": "a"}))
+	assert.False(t, selector.Matches(labels.Set{"shard": "b"}))
+}
+
+// shardHandler is a validating and mutating handler of a single resource.
+type shardHandler struct {
+	resource       string
+	scope          v1.ScopeType
+	objectSelector *metav1.LabelSelector
+}
+
+func (s *shardHandler) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: s.resource}
+}
+
+func (s *shardHandler) Operations() []v1.OperationType { return []v1.OperationType{v1.Create} }
+
+func (s *shardHandler) Admitters() []admission.Admitter { return nil }
+
+func (s *shardHandler) Admit(_ *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	return admission.ResponseAllowed(), nil
+}
+
+func (s *shardHandler) ValidatingWebhook(clientConfig v1.WebhookClientConfig) []v1.ValidatingWebhook {
+	webhook := admission.NewDefaultValidatingWebhook(s, clientConfig, s.scope, s.Operations())
+	webhook.ObjectSelector = s.objectSelector
+	return []v1.ValidatingWebhook{*webhook}
+}
+
+func (s *shardHandler) MutatingWebhook(clientConfig v1.WebhookClientConfig) []v1.MutatingWebhook {
+	webhook := admission.NewDefaultMutatingWebhook(s, clientConfig, s.scope, s.Operations())
+	webhook.ObjectSelector = s.objectSelector
+	return []v1.MutatingWebhook{*webhook}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// gracefulShutdown drains the webhook's servers once their context is done, e.g. on SIGTERM while the deployment
+// rolls, so that the admission requests reaching a terminating replica don't fail. Each server keeps accepting
+// requests for the shutdown delay while readiness fails, until the endpoints stop routing requests to the replica, then
+// stops accepting new requests and waits up to the shutdown timeout for the in-flight ones to complete.
+type gracefulShutdown struct {
+	draining atomic.Bool
+	// servers tracks the servers that haven't finished draining.
+	servers sync.WaitGroup
+}
+
+var shutdown = &gracefulShutdown{}
+
+// Name returns the name of the readiness check.
+func (s *gracefulShutdown) Name() string {
+	return "Not Shutting Down"
+}
+
+// Check fails once the webhook is shutting down, so that no new requests are routed to it.
+func (s *gracefulShutdown) Check(_ *http.Request) error {
+	if s.draining.Load() {
+		return errors.New("the webhook is shutting down")
+	}
+	return nil
+}
+
+// WaitForShutdown blocks until the servers stopped after their context was done and drained their in-flight requests,
+// or gave up on them after the shutdown timeout. Exiting earlier fails the requests still in flight.
+func WaitForShutdown() {
+	shutdown.servers.Wait()
+}
+
+// drain shuts the server down gracefully once the context is done.
+func (s *gracefulShutdown) drain(ctx context.Context, server *http.Server, config httpServerConfig) {
+	s.servers.Add(1)
+	go func() {
+		defer s.servers.Done()
+		<-ctx.Done()
+		s.draining.Store(true)
+		logrus.Infof("[shutdown] failing readiness, accepting requests for %s before draining", config.shutdownDelay)
+		// HTTP/1.1 clients open new connections, which the endpoints route to other replicas
+		server.SetKeepAlivesEnabled(false)
+		time.Sleep(config.shutdownDelay)
+
+		drainCtx, cancel := context.WithTimeout(context.Background(), config.shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(drainCtx); err != nil {
+			logrus.Warnf("[shutdown] in-flight requests didn't complete within %s, closing their connections: %v", config.shutdownTimeout, err)
+			_ = server.Close()
+			return
+		}
+		logrus.Info("[shutdown] drained the in-flight requests")
+	}()
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	t.Parallel()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		_, _ = rw.Write([]byte("done"))
+	})}
+	go func() { _ = server.Serve(listener) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown := &gracefulShutdown{}
+	shutdown.drain(ctx, server, httpServerConfig{shutdownDelay: 50 * time.Millisecond, shutdownTimeout: 5 * time.Second})
+	require.NoError(t, shutdown.Check(nil))
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{body: string(body), err: err}
+	}()
+	<-started
+	cancel()
+	require.Eventually(t, func() bool { return shutdown.Check(nil) != nil }, time.Second, 10*time.Millisecond,
+		"readiness fails once shutting down")
+
+	drained := make(chan struct{})
+	go func() {
+		shutdown.servers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		t.Fatal("the server stopped before its in-flight request completed")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(release)
+	got := <-results
+	require.NoError(t, got.err)
+	assert.Equal(t, "done", got.body)
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server didn't stop once drained")
+	}
+	_, err = http.Get("http://" + listener.Addr().String())
+	assert.Error(t, err, "no new requests are accepted once drained")
+}
+
+func TestGracefulShutdownTimeout(t *testing.T) {
+	t.Parallel()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	started := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-req.Context().Done()
+	})}
+	go func() { _ = server.Serve(listener) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown := &gracefulShutdown{}
+	shutdown.drain(ctx, server, httpServerConfig{shutdownTimeout: 50 * time.Millisecond})
+	errs := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		errs <- err
+	}()
+	<-started
+	cancel()
+	shutdown.servers.Wait()
+	assert.Error(t, <-errs, "the connections of requests still in flight after the timeout are closed")
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/webhook/pkg/faults"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// versionPath is the path of the endpoint returning the version of the webhook.
+	versionPath = "/version"
+	// RulesetHashEnvKey pins the hash of the webhook's rules: the webhook fails to start if its rules hash differently,
+	// e.g. if the image isn't the expected build.
+	RulesetHashEnvKey = "CATTLE_WEBHOOK_RULESET_HASH"
+	// rancherVersions are the Rancher versions the webhook supports, as declared in the chart.
+	rancherVersions = ">= 2.10.0-0 < 2.11.0-0"
+)
+
+// rulesetHashFormat is the format of the hash of the rules.
+var rulesetHashFormat = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// versionInfo is the response of the version endpoint.
+type versionInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	// RancherVersions is the semver range of the Rancher versions the webhook supports.
+	RancherVersions string `json:"rancherVersions"`
+	// FeatureGates are the optional features and whether they are enabled.
+	FeatureGates map[string]bool `json:"featureGates"`
+	// RulesetHash is the hash of the rules served on the rules endpoint.
+	RulesetHash string `json:"rulesetHash"`
+}
+
+// buildVersion holds the version of the webhook, set when the server starts.
+var buildVersion = struct {
+	mutex     sync.Mutex
+	version   string
+	gitCommit string
+}{version: "dev", gitCommit: "HEAD"}
+
+// SetVersion sets the version and git commit of the webhook, which are returned by the version endpoint.
+func SetVersion(version, gitCommit string) {
+	buildVersion.mutex.Lock()
+	defer buildVersion.mutex.Unlock()
+	buildVersion.version = version
+	buildVersion.gitCommit = gitCommit
+}
+
+// newVersionInfo returns the version of the webhook with the feature gates.
+func newVersionInfo(featureGates map[string]bool) versionInfo {
+	buildVersion.mutex.Lock()
+	defer buildVersion.mutex.Unlock()
+	return versionInfo{
+		Version:         buildVersion.version,
+		GitCommit:       buildVersion.gitCommit,
+		RancherVersions: rancherVersions,
+		FeatureGates:    featureGates,
+		RulesetHash:     rules.Hash(),
+	}
+}
+
+// featureGates returns the optional features and whether they are enabled.
+func featureGates(mcmEnabled, denialConditions, importPreChecks bool, watchdog watchdogConfig) map[string]bool {
+	return map[string]bool{
+		"multiClusterManagement": mcmEnabled,
+		"denialConditions":       denialConditions,
+		"importPreChecks":        importPreChecks,
+		"faultInjection":         faults.Enabled,
+		"watchdog":               watchdog.ceiling > 0,
+	}
+}
+
+// checkRulesetHash returns an error if the hash of the rules isn't the one pinned by the environment, if any.
+func checkRulesetHash() error {
+	pinned := os.Getenv(RulesetHashEnvKey)
+	if pinned == "" {
+		logrus.Infof("Ruleset hash is %s", rules.Hash())
+		return nil
+	}
+	if err := CheckPinnedRulesetHash(pinned); err != nil {
+		return fmt.Errorf("invalid %s value '%s': %w", RulesetHashEnvKey, pinned, err)
+	}
+	logrus.Infof("Ruleset hash %s matches the pinned hash", pinned)
+	return nil
+}
+
+// CheckPinnedRulesetHash returns an error if the pinned hash isn't the hash of the webhook's rules.
+func CheckPinnedRulesetHash(pinned string) error {
+	if !rulesetHashFormat.MatchString(pinned) {
+		return errors.New("must be sha256: followed by 64 hexadecimal digits")
+	}
+	if hash := rules.Hash(); pinned != hash {
+		return fmt.Errorf("doesn't match the ruleset hash %s", hash)
+	}
+	return nil
+}
+
+// registerVersionHandler adds the endpoint returning the version of the webhook to the router.
+func registerVersionHandler(router *mux.Router, info versionInfo) {
+	router.HandleFunc(versionPath, func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(info); err != nil {
+			logrus.Errorf("failed to write version response: %v", err)
+		}
+	}).Methods(http.MethodGet)
+}
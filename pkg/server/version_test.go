@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/webhook/pkg/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRulesetHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		pinned  string
+		wantErr string
+	}{
+		{
+			name: "not pinned",
+		},
+		{
+			name:   "matching hash",
+			pinned: rules.Hash(),
+		},
+		{
+			name:    "other hash",
+			pinned:  "sha256:" + strings.Repeat("0", 64),
+			wantErr: "doesn't match the ruleset hash " + rules.Hash(),
+		},
+		{
+			name:    "invalid hash",
+			pinned:  "0123abcd",
+			wantErr: "invalid CATTLE_WEBHOOK_RULESET_HASH value '0123abcd': must be sha256: followed by 64 hexadecimal digits",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(RulesetHashEnvKey, test.pinned)
+			err := checkRulesetHash()
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	SetVersion("v0.7.0", "1a2b3c4")
+	t.Cleanup(func() { SetVersion("dev", "HEAD") })
+	router := mux.NewRouter()
+	registerVersionHandler(router, newVersionInfo(featureGates(true, false, true, watchdogConfig{})))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, versionPath, nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var info versionInfo
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &info))
+	assert.Equal(t, versionInfo{
+		Version:         "v0.7.0",
+		GitCommit:       "1a2b3c4",
+		RancherVersions: rancherVersions,
+		FeatureGates: map[string]bool{
+			"multiClusterManagement": true,
+			"denialConditions":       false,
+			"importPreChecks":        true,
+			"faultInjection":         false,
+			"watchdog":               false,
+		},
+		RulesetHash: rules.Hash(),
+	}, info)
+}
+
+func TestRancherVersionsMatchChart(t *testing.T) {
+	chart, err := os.ReadFile("../../charts/rancher-webhook/Chart.yaml")
+	require.NoError(t, err)
+	assert.Contains(t, string(chart), `catalog.cattle.io/rancher-version: "`+rancherVersions+`"`)
+}
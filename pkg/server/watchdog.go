@@ -0,0 +1,360 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// watchdogCeilingEnvKey is the hard ceiling on the wall-clock time of a request to a handler. Requests exceeding it
+	// are canceled. Empty or 0 disables the ceiling.
+	watchdogCeilingEnvKey = "CATTLE_WEBHOOK_WATCHDOG_CEILING"
+	// watchdogLeakThresholdEnvKey is the number of canceled requests of a handler still running after which the
+	// handler's shared resources are reset.
+	watchdogLeakThresholdEnvKey = "CATTLE_WEBHOOK_WATCHDOG_LEAK_THRESHOLD"
+
+	defaultWatchdogLeakThreshold = 10
+	// watchdogPath is the path of the debug endpoint returning the diagnostics of the watchdog.
+	watchdogPath = "/debug/watchdog"
+)
+
+// requestRunning, requestFinished and requestCanceled are the states of a request tracked with a ceiling.
+const (
+	requestRunning int32 = iota
+	requestFinished
+	requestCanceled
+)
+
+var (
+	handlerInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "rancher_webhook",
+		Name:      "handler_in_flight_requests",
+		Help:      "Number of requests a handler is admitting.",
+	}, []string{"handler"})
+	handlerCanceled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rancher_webhook",
+		Name:      "handler_canceled_requests_total",
+		Help:      "Number of requests canceled by the watchdog for exceeding the ceiling.",
+	}, []string{"handler"})
+	handlerLeaked = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "rancher_webhook",
+		Name:      "handler_leaked_requests",
+		Help:      "Number of canceled requests of a handler that are still running.",
+	}, []string{"handler"})
+	handlerResets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rancher_webhook",
+		Name:      "handler_resets_total",
+		Help:      "Number of times the watchdog reset the shared resources of a handler after detecting leaked requests.",
+	}, []string{"handler"})
+)
+
+func init() {
+	prometheus.MustRegister(handlerInFlight, handlerCanceled, handlerLeaked, handlerResets)
+}
+
+// watchdogConfig configures the watchdog.
+type watchdogConfig struct {
+	// ceiling is the hard ceiling on the wall-clock time of a request, 0 to never cancel requests.
+	ceiling time.Duration
+	// leakThreshold is the number of leaked requests of a handler after which its shared resources are reset.
+	leakThreshold int
+}
+
+// watchdogConfigFromEnv returns the configuration of the watchdog from the environment.
+func watchdogConfigFromEnv() (watchdogConfig, error) {
+	config := watchdogConfig{leakThreshold: defaultWatchdogLeakThreshold}
+	if value := os.Getenv(watchdogCeilingEnvKey); value != "" {
+		ceiling, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("failed to decode %s value '%s': %w", watchdogCeilingEnvKey, value, err)
+		}
+		if ceiling < 0 {
+			return config, fmt.Errorf("%s value '%s' must not be negative", watchdogCeilingEnvKey, value)
+		}
+		config.ceiling = ceiling
+	}
+	if value := os.Getenv(watchdogLeakThresholdEnvKey); value != "" {
+		threshold, err := strconv.Atoi(value)
+		if err != nil {
+			return config, fmt.Errorf("failed to decode %s value '%s': %w", watchdogLeakThresholdEnvKey, value, err)
+		}
+		if threshold <= 0 {
+			return config, fmt.Errorf("%s value '%s' must be positive", watchdogLeakThresholdEnvKey, value)
+		}
+		config.leakThreshold = threshold
+	}
+	return config, nil
+}
+
+// watchdog tracks the requests of each handler, cancels the ones exceeding the ceiling, and resets the shared
+// resources of handlers whose canceled requests keep running, so that one misbehaving handler can't exhaust the
+// goroutines and memory of the server shared by all handlers.
+type watchdog struct {
+	config watchdogConfig
+	now    func() time.Time
+
+	mutex    sync.Mutex
+	handlers map[string]*handlerWatch
+}
+
+// handlerWatch is the state of the requests of a handler.
+type handlerWatch struct {
+	// inFlight holds the start time of the running requests by ID.
+	inFlight map[uint64]time.Time
+	nextID   uint64
+	canceled int
+	// leaked is the number of canceled requests still running.
+	leaked int
+	resets int
+	// reset resets the shared resources of the handler, nil if it has none.
+	reset func()
+}
+
+// newWatchdog returns a watchdog with the configuration.
+func newWatchdog(config watchdogConfig) *watchdog {
+	return &watchdog{config: config, now: time.Now, handlers: map[string]*handlerWatch{}}
+}
+
+// Handler returns the handler of the requests to the named handler, tracked by the watchdog. reset resets the shared
+// resources of the handler when its leaked requests reach the threshold, it may be nil.
+func (w *watchdog) Handler(name string, reset func(), next http.Handler) http.Handler {
+	w.mutex.Lock()
+	w.handlers[name] = &handlerWatch{inFlight: map[uint64]time.Time{}, reset: reset}
+	w.mutex.Unlock()
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		id := w.start(name)
+		if w.config.ceiling == 0 {
+			defer w.finish(name, id, false)
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), w.config.ceiling)
+		defer cancel()
+		// The response is buffered, so that a request canceled by the watchdog can't write to the connection after the
+		// watchdog replied.
+		buffered := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		// state is settled by whichever of the request finishing or the watchdog canceling it comes first.
+		var state atomic.Int32
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer func() {
+				// net/http only recovers the panics of the goroutine serving the connection.
+				if recovered := recover(); recovered != nil {
+					logrus.Errorf("[watchdog] request to handler %s panicked: %v", name, recovered)
+					buffered.reset(http.StatusInternalServerError)
+				}
+				if !state.CompareAndSwap(requestRunning, requestFinished) {
+					w.finish(name, id, true)
+				}
+			}()
+			next.ServeHTTP(buffered, req.WithContext(ctx))
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if state.CompareAndSwap(requestRunning, requestCanceled) {
+				w.cancel(name, id)
+				http.Error(rw, fmt.Sprintf("request to handler %s exceeded the ceiling of %s", name, w.config.ceiling), http.StatusServiceUnavailable)
+				return
+			}
+			<-done
+		}
+		w.finish(name, id, false)
+		buffered.writeTo(rw)
+	})
+}
+
+// resetFunc returns the function resetting the shared resources of the handler, nil if it doesn't implement
+// admission.Resetter.
+func resetFunc(handler any) func() {
+	if resetter, ok := handler.(admission.Resetter); ok {
+		return resetter.Reset
+	}
+	return nil
+}
+
+// start records the start of a request to the handler and returns its ID.
+func (w *watchdog) start(name string) uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	watch := w.handlers[name]
+	watch.nextID++
+	watch.inFlight[watch.nextID] = w.now()
+	handlerInFlight.WithLabelValues(name).Set(float64(len(watch.inFlight)))
+	return watch.nextID
+}
+
+// finish records the end of a request to the handler, which was canceled before if leaked is true.
+func (w *watchdog) finish(name string, id uint64, leaked bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	watch := w.handlers[name]
+	if leaked {
+		watch.leaked--
+		handlerLeaked.WithLabelValues(name).Set(float64(watch.leaked))
+		return
+	}
+	delete(watch.inFlight, id)
+	handlerInFlight.WithLabelValues(name).Set(float64(len(watch.inFlight)))
+}
+
+// cancel records that a request to the handler was canceled for exceeding the ceiling, and resets the shared resources
+// of the handler when its leaked requests reach the threshold.
+func (w *watchdog) cancel(name string, id uint64) {
+	w.mutex.Lock()
+	watch := w.handlers[name]
+	delete(watch.inFlight, id)
+	watch.canceled++
+	watch.leaked++
+	leaked := watch.leaked
+	reset := leaked == w.config.leakThreshold
+	if reset {
+		watch.resets++
+	}
+	handlerInFlight.WithLabelValues(name).Set(float64(len(watch.inFlight)))
+	handlerLeaked.WithLabelValues(name).Set(float64(leaked))
+	handlerCanceled.WithLabelValues(name).Inc()
+	w.mutex.Unlock()
+
+	logrus.Warnf("[watchdog] canceled a request to handler %s after %s", name, w.config.ceiling)
+	if !reset {
+		return
+	}
+	handlerResets.WithLabelValues(name).Inc()
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	logrus.Errorf("[watchdog] %d canceled requests to handler %s are still running, resetting its shared resources (goroutines=%d heap=%dB)",
+		leaked, name, runtime.NumGoroutine(), memStats.HeapAlloc)
+	if watch.reset != nil {
+		watch.reset()
+	}
+}
+
+// watchdogDiagnostics are the diagnostics returned by the debug endpoint of the watchdog.
+type watchdogDiagnostics struct {
+	Ceiling       string `json:"ceiling"`
+	LeakThreshold int    `json:"leakThreshold"`
+	// Goroutines and HeapBytes are process-wide, since the Go runtime doesn't account them per handler.
+	Goroutines int                `json:"goroutines"`
+	HeapBytes  uint64             `json:"heapBytes"`
+	Handlers   []handlerDiagnosis `json:"handlers"`
+}
+
+// handlerDiagnosis is the state of the requests of a handler.
+type handlerDiagnosis struct {
+	Handler  string `json:"handler"`
+	InFlight int    `json:"inFlight"`
+	// OldestInFlight is the wall-clock time of the oldest running request, empty without running requests.
+	OldestInFlight string `json:"oldestInFlight,omitempty"`
+	Canceled       int    `json:"canceled"`
+	Leaked         int    `json:"leaked"`
+	Resets         int    `json:"resets"`
+}
+
+// diagnostics returns the state of the handlers with requests, sorted by name.
+func (w *watchdog) diagnostics() watchdogDiagnostics {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	diagnostics := watchdogDiagnostics{
+		Ceiling:       w.config.ceiling.String(),
+		LeakThreshold: w.config.leakThreshold,
+		Goroutines:    runtime.NumGoroutine(),
+		HeapBytes:     memStats.HeapAlloc,
+		Handlers:      []handlerDiagnosis{},
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	now := w.now()
+	for name, watch := range w.handlers {
+		if watch.nextID == 0 {
+			continue
+		}
+		diagnosis := handlerDiagnosis{
+			Handler:  name,
+			InFlight: len(watch.inFlight),
+			Canceled: watch.canceled,
+			Leaked:   watch.leaked,
+			Resets:   watch.resets,
+		}
+		var oldest time.Time
+		for _, started := range watch.inFlight {
+			if oldest.IsZero() || started.Before(oldest) {
+				oldest = started
+			}
+		}
+		if !oldest.IsZero() {
+			diagnosis.OldestInFlight = now.Sub(oldest).String()
+		}
+		diagnostics.Handlers = append(diagnostics.Handlers, diagnosis)
+	}
+	sort.Slice(diagnostics.Handlers, func(i, j int) bool { return diagnostics.Handlers[i].Handler < diagnostics.Handlers[j].Handler })
+	return diagnostics
+}
+
+// RegisterHandlers adds the debug endpoint returning the diagnostics of the watchdog to the router.
+func (w *watchdog) RegisterHandlers(router *mux.Router) {
+	router.HandleFunc(watchdogPath, func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(w.diagnostics()); err != nil {
+			logrus.Errorf("failed to write watchdog response: %v", err)
+		}
+	}).Methods(http.MethodGet)
+}
+
+// bufferedResponse buffers the response of a handler.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+// Write implements http.ResponseWriter.
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+// reset discards the buffered response and replaces it with an empty response with the status.
+func (b *bufferedResponse) reset(status int) {
+	b.header = http.Header{}
+	b.status = status
+	b.body.Reset()
+}
+
+// writeTo writes the buffered response to the writer.
+func (b *bufferedResponse) writeTo(rw http.ResponseWriter) {
+	for key, values := range b.header {
+		rw.Header()[key] = values
+	}
+	rw.WriteHeader(b.status)
+	if _, err := rw.Write(b.body.Bytes()); err != nil {
+		logrus.Errorf("failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdogConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name          string
+		ceiling       string
+		leakThreshold string
+		want          watchdogConfig
+		wantErr       string
+	}{
+		{
+			name: "defaults",
+			want: watchdogConfig{leakThreshold: defaultWatchdogLeakThreshold},
+		},
+		{
+			name:          "configured",
+			ceiling:       "15s",
+			leakThreshold: "3",
+			want:          watchdogConfig{ceiling: 15 * time.Second, leakThreshold: 3},
+		},
+		{
+			name:    "invalid ceiling",
+			ceiling: "soon",
+			wantErr: "failed to decode CATTLE_WEBHOOK_WATCHDOG_CEILING value 'soon'",
+		},
+		{
+			name:    "negative ceiling",
+			ceiling: "-1s",
+			wantErr: "must not be negative",
+		},
+		{
+			name:          "zero leak threshold",
+			leakThreshold: "0",
+			wantErr:       "must be positive",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(watchdogCeilingEnvKey, test.ceiling)
+			t.Setenv(watchdogLeakThresholdEnvKey, test.leakThreshold)
+			config, err := watchdogConfigFromEnv()
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, config)
+		})
+	}
+}
+
+func TestWatchdogHandler(t *testing.T) {
+	t.Parallel()
+	dog := newWatchdog(watchdogConfig{ceiling: 50 * time.Millisecond, leakThreshold: 2})
+	var resets atomic.Int32
+	release := make(chan struct{})
+	handler := dog.Handler("validation/test", func() { resets.Add(1) }, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("stuck") != "" {
+			<-release // ignores the context, like a misbehaving validator
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{}`))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `{}`, recorder.Body.String())
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/?stuck=true", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "request to handler validation/test exceeded the ceiling of 50ms")
+	}
+	assert.Equal(t, int32(1), resets.Load(), "the handler is reset once the leaked requests reach the threshold")
+
+	diagnostics := dog.diagnostics()
+	require.Len(t, diagnostics.Handlers, 1)
+	assert.Equal(t, handlerDiagnosis{Handler: "validation/test", Canceled: 2, Leaked: 2, Resets: 1}, diagnostics.Handlers[0])
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return dog.diagnostics().Handlers[0].Leaked == 0
+	}, time.Second, 10*time.Millisecond, "leaked requests are no longer counted once they finish")
+}
+
+func TestWatchdogHandlerPanic(t *testing.T) {
+	t.Parallel()
+	dog := newWatchdog(watchdogConfig{ceiling: time.Second, leakThreshold: 1})
+	handler := dog.Handler("validation/panic", nil, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("validator bug")
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, 0, dog.diagnostics().Handlers[0].InFlight)
+}
+
+func TestWatchdogDiagnosticsEndpoint(t *testing.T) {
+	t.Parallel()
+	dog := newWatchdog(watchdogConfig{leakThreshold: defaultWatchdogLeakThreshold})
+	started := time.Unix(1700000000, 0)
+	dog.now = func() time.Time { return started }
+	release := make(chan struct{})
+	handler := dog.Handler("mutation/test", nil, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	dog.Handler("validation/idle", nil, http.NotFoundHandler())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	}()
+	require.Eventually(t, func() bool {
+		return len(dog.diagnostics().Handlers) == 1
+	}, time.Second, 10*time.Millisecond)
+	dog.now = func() time.Time { return started.Add(3 * time.Second) }
+
+	router := mux.NewRouter()
+	dog.RegisterHandlers(router)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, watchdogPath, nil))
+	close(release)
+	<-done
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var diagnostics watchdogDiagnostics
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &diagnostics))
+	assert.Equal(t, "0s", diagnostics.Ceiling)
+	assert.Positive(t, diagnostics.Goroutines)
+	assert.Equal(t, []handlerDiagnosis{{Handler: "mutation/test", InFlight: 1, OldestInFlight: "3s"}}, diagnostics.Handlers,
+		"handlers without requests aren't listed")
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// update rewrites the golden files of TestWebhookGolden instead of comparing against them:
+//
+//	go test ./pkg/server -run TestWebhookGolden -update
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenClientConfig is the client config the golden webhooks are rendered with, so that they don't depend on the
+// environment.
+var goldenClientConfig = v1.WebhookClientConfig{
+	Service: &v1.ServiceReference{
+		Namespace: namespace,
+		Name:      "rancher-webhook",
+		Path:      admission.Ptr("/v1/webhook"),
+		Port:      admission.Ptr(clientPort),
+	},
+}
+
+// TestWebhookGolden compares the webhooks each handler registers on the local and downstream clusters against the
+// files in testdata/webhooks, so that changes to their rules, scopes, selectors and failure policies are reviewed.
+func TestWebhookGolden(t *testing.T) {
+	// not parallel, the handlers configure the global rule enforcement
+	for _, cluster := range []struct {
+		name string
+		mcm  bool
+	}{
+		{name: "local", mcm: true},
+		{name: "downstream", mcm: false},
+	} {
+		t.Run(cluster.name, func(t *testing.T) {
+			offlineClients, err := clients.NewOffline(cluster.mcm)
+			require.NoError(t, err)
+			validators, mutators, err := Handlers(offlineClients, nil)
+			require.NoError(t, err)
+
+			rendered := map[string]any{}
+			for _, handler := range validators {
+				rendered[filepath.Join("validating", admission.SubPath(handler.GVR())+".yaml")] = handler.ValidatingWebhook(goldenClientConfig)
+			}
+			for _, handler := range mutators {
+				rendered[filepath.Join("mutating", admission.SubPath(handler.GVR())+".yaml")] = handler.MutatingWebhook(goldenClientConfig)
+			}
+
+			dir := filepath.Join("testdata", "webhooks", cluster.name)
+			if *update {
+				require.NoError(t, os.RemoveAll(dir))
+			}
+			for name, webhooks := range rendered {
+				got, err := yaml.Marshal(webhooks)
+				require.NoError(t, err)
+				path := filepath.Join(dir, name)
+				if *update {
+					require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+					require.NoError(t, os.WriteFile(path, got, 0o644))
+					continue
+				}
+				want, err := os.ReadFile(path)
+				require.NoError(t, err, "missing golden file, run the test with -update")
+				assert.Equal(t, string(want), string(got), "%s changed, run the test with -update if this is intended", path)
+			}
+			assert.Equal(t, goldenFiles(t, dir), sortedKeys(rendered), "golden files without a handler, run the test with -update")
+		})
+	}
+}
+
+// goldenFiles returns the sorted paths of the golden files in dir, relative to it.
+func goldenFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, rel)
+		return err
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}